@@ -4,10 +4,13 @@ import (
 	"crypto/rand"
 	"errors"
 	"fmt"
+	"io"
+	"net/netip"
 	"time"
 
 	"github.com/AstaFrode/go-libp2p/core/connmgr"
 	"github.com/AstaFrode/go-libp2p/core/crypto"
+	"github.com/AstaFrode/go-libp2p/core/event"
 	"github.com/AstaFrode/go-libp2p/core/host"
 	"github.com/AstaFrode/go-libp2p/core/metrics"
 	"github.com/AstaFrode/go-libp2p/core/network"
@@ -15,6 +18,7 @@ import (
 	"github.com/AstaFrode/go-libp2p/core/peerstore"
 	"github.com/AstaFrode/go-libp2p/core/pnet"
 	"github.com/AstaFrode/go-libp2p/core/protocol"
+	"github.com/AstaFrode/go-libp2p/core/record"
 	"github.com/AstaFrode/go-libp2p/core/routing"
 	"github.com/AstaFrode/go-libp2p/core/sec"
 	"github.com/AstaFrode/go-libp2p/core/sec/insecure"
@@ -23,18 +27,28 @@ import (
 	"github.com/AstaFrode/go-libp2p/p2p/host/autorelay"
 	bhost "github.com/AstaFrode/go-libp2p/p2p/host/basic"
 	blankhost "github.com/AstaFrode/go-libp2p/p2p/host/blank"
+	"github.com/AstaFrode/go-libp2p/p2p/host/eventbus"
+	"github.com/AstaFrode/go-libp2p/p2p/host/healthcheck"
+	"github.com/AstaFrode/go-libp2p/p2p/host/introspect"
 	"github.com/AstaFrode/go-libp2p/p2p/host/peerstore/pstoremem"
+	"github.com/AstaFrode/go-libp2p/p2p/host/resource-manager/watchdog"
 	routed "github.com/AstaFrode/go-libp2p/p2p/host/routed"
+	"github.com/AstaFrode/go-libp2p/p2p/muxer/raw"
+	"github.com/AstaFrode/go-libp2p/p2p/net/conngater"
 	"github.com/AstaFrode/go-libp2p/p2p/net/swarm"
 	tptu "github.com/AstaFrode/go-libp2p/p2p/net/upgrader"
 	circuitv2 "github.com/AstaFrode/go-libp2p/p2p/protocol/circuitv2/client"
 	relayv2 "github.com/AstaFrode/go-libp2p/p2p/protocol/circuitv2/relay"
 	"github.com/AstaFrode/go-libp2p/p2p/protocol/holepunch"
+	"github.com/AstaFrode/go-libp2p/p2p/protocol/peerexchange"
+	"github.com/AstaFrode/go-libp2p/p2p/protocol/ping"
 	"github.com/AstaFrode/go-libp2p/p2p/transport/quicreuse"
 	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/trace"
 
 	ma "github.com/multiformats/go-multiaddr"
 	madns "github.com/multiformats/go-multiaddr-dns"
+	"github.com/quic-go/quic-go"
 	"go.uber.org/fx"
 	"go.uber.org/fx/fxevent"
 )
@@ -78,26 +92,61 @@ type Config struct {
 	// using the [ProtocolVersion] option.
 	ProtocolVersion string
 
+	// Logger is the structured logger used by the host. It is set using the
+	// [WithLogger] option.
+	Logger bhost.Logger
+
 	PeerKey crypto.PrivKey
+	// PreviousPeerKey is the key this node used before rotating to PeerKey, if any.
+	PreviousPeerKey crypto.PrivKey
+
+	QUICReuse []fx.Option
+	// QUICStatelessResetKey is the key used to derive QUIC stateless reset tokens.
+	// If nil, it's derived from PeerKey.
+	QUICStatelessResetKey *quic.StatelessResetKey
 
-	QUICReuse          []fx.Option
 	Transports         []fx.Option
 	Muxers             []tptu.StreamMuxer
 	SecurityTransports []Security
 	Insecure           bool
 	PSK                pnet.PSK
 
-	DialTimeout time.Duration
+	DialTimeout  time.Duration
+	DialTimeouts []swarm.DialTimeout
+
+	DialConcurrency int
+	MaxDialsPerPeer int
+	MaxDialAddrs    int
+
+	SecurityHandshakeTimeout      time.Duration
+	MuxerNegotiationTimeout       time.Duration
+	FirstStreamNegotiationTimeout time.Duration
+	StreamMiddleware              []bhost.StreamMiddleware
+	UpgradeInterceptors           []tptu.Interceptor
+	Throttler                     *tptu.Throttler
+	InboundAdmission              tptu.AdmissionFunc
+	MaxPendingInboundHandshakes   int
+
+	UDPBlackHoleConfig  *swarm.BlackHoleConfig
+	IPv6BlackHoleConfig *swarm.BlackHoleConfig
+
+	ListenRetryMinBackoff time.Duration
+	ListenRetryMaxBackoff time.Duration
 
 	RelayCustom bool
 	Relay       bool // should the relay transport be used
 
 	EnableRelayService bool // should we run a circuitv2 relay (if publicly reachable)
 	RelayServiceOpts   []relayv2.Option
+	// RelayServiceCustom and the other ...Custom fields are set when the feature was
+	// explicitly enabled or disabled, so that profiles don't override that choice.
+	RelayServiceCustom bool
 
 	ListenAddrs     []ma.Multiaddr
 	AddrsFactory    bhost.AddrsFactory
+	AddrPolicy      *bhost.AddrPolicy
 	ConnectionGater connmgr.ConnectionGater
+	AddressFilters  *conngater.AddressFilters
 
 	ConnManager     connmgr.ConnManager
 	ResourceManager network.ResourceManager
@@ -108,22 +157,76 @@ type Config struct {
 
 	MultiaddrResolver *madns.Resolver
 
+	EnableNAT64   bool
+	NAT64Prefixes []netip.Prefix
+
 	DisablePing bool
+	PingOptions []ping.Option
 
 	Routing RoutingC
 
 	EnableAutoRelay bool
 	AutoRelayOpts   []autorelay.Option
 	AutoNATConfig
+	NATServiceCustom bool
 
 	EnableHolePunching  bool
 	HolePunchingOptions []holepunch.Option
+	HolePunchingCustom  bool
+
+	EnablePeerExchange  bool
+	PeerExchangeOptions []peerexchange.Option
+
+	EnableHealthCheck  bool
+	HealthCheckOptions []healthcheck.Option
+	HealthCheckCustom  bool
+
+	EnableResourceWatchdog  bool
+	ResourceWatchdogOptions []watchdog.Option
+
+	EnableAddrChangeMonitor bool
+	AddrChangeMonitorCustom bool
+	AddrGracePeriod         time.Duration
+
+	ProtocolTags map[protocol.ID]bhost.ProtocolTagWeights
+
+	IdleConnTimeout time.Duration
+
+	EnableStreamMigration bool
+
+	EnableRawStreamMuxer bool
+
+	DisableMetrics             bool
+	PrometheusRegisterer       prometheus.Registerer
+	BandwidthMetricsByProtocol bool
+
+	TracerProvider trace.TracerProvider
+
+	IntrospectionAddr string
+	IntrospectionOpts []introspect.Option
+
+	// Profile is the name of the configuration profile, see libp2p.ProfileServer.
+	Profile string
+
+	// closers are the resources created while applying the options, see AddCloser.
+	closers []io.Closer
+}
 
-	DisableMetrics       bool
-	PrometheusRegisterer prometheus.Registerer
+// AddCloser registers a resource created while applying the options, e.g. a
+// connection manager created by a profile. It is closed if the node can't be
+// constructed, since nobody else holds a reference to it.
+func (cfg *Config) AddCloser(c io.Closer) {
+	cfg.closers = append(cfg.closers, c)
 }
 
-func (cfg *Config) makeSwarm(enableMetrics bool) (*swarm.Swarm, error) {
+func (cfg *Config) closeResources() {
+	for _, c := range cfg.closers {
+		c.Close()
+	}
+	cfg.closers = nil
+}
+
+func (cfg *Config) makeSwarm(eventBus event.Bus, enableMetrics bool) (*swarm.Swarm, error) {
 	if cfg.Peerstore == nil {
 		return nil, fmt.Errorf("no peerstore specified")
 	}
@@ -159,21 +262,54 @@ func (cfg *Config) makeSwarm(enableMetrics bool) (*swarm.Swarm, error) {
 	if cfg.Reporter != nil {
 		opts = append(opts, swarm.WithMetrics(cfg.Reporter))
 	}
-	if cfg.ConnectionGater != nil {
-		opts = append(opts, swarm.WithConnectionGater(cfg.ConnectionGater))
+	if gater := cfg.connectionGater(); gater != nil {
+		opts = append(opts, swarm.WithConnectionGater(gater))
 	}
 	if cfg.DialTimeout != 0 {
 		opts = append(opts, swarm.WithDialTimeout(cfg.DialTimeout))
 	}
+	if len(cfg.DialTimeouts) > 0 {
+		opts = append(opts, swarm.WithDialTimeouts(cfg.DialTimeouts...))
+	}
+	if cfg.DialConcurrency != 0 {
+		opts = append(opts, swarm.WithDialConcurrency(cfg.DialConcurrency))
+	}
+	if cfg.MaxDialsPerPeer != 0 {
+		opts = append(opts, swarm.WithMaxDialsPerPeer(cfg.MaxDialsPerPeer))
+	}
+	if cfg.MaxDialAddrs != 0 {
+		opts = append(opts, swarm.WithMaxDialAddrs(cfg.MaxDialAddrs))
+	}
 	if cfg.ResourceManager != nil {
 		opts = append(opts, swarm.WithResourceManager(cfg.ResourceManager))
 	}
 	if cfg.MultiaddrResolver != nil {
 		opts = append(opts, swarm.WithMultiaddrResolver(cfg.MultiaddrResolver))
 	}
+	if cfg.TracerProvider != nil {
+		opts = append(opts, swarm.WithTracerProvider(cfg.TracerProvider))
+	}
+	if cfg.EnableNAT64 {
+		opts = append(opts, swarm.WithNAT64(cfg.NAT64Prefixes...))
+	}
+	if eventBus != nil {
+		opts = append(opts, swarm.WithEventBus(eventBus))
+	}
+	if cfg.UDPBlackHoleConfig != nil {
+		opts = append(opts, swarm.WithUDPBlackHoleConfig(*cfg.UDPBlackHoleConfig))
+	}
+	if cfg.IPv6BlackHoleConfig != nil {
+		opts = append(opts, swarm.WithIPv6BlackHoleConfig(*cfg.IPv6BlackHoleConfig))
+	}
+	if cfg.ListenRetryMinBackoff > 0 {
+		opts = append(opts, swarm.WithListenRetry(cfg.ListenRetryMinBackoff, cfg.ListenRetryMaxBackoff))
+	}
 	if enableMetrics {
-		opts = append(opts,
-			swarm.WithMetricsTracer(swarm.NewMetricsTracer(swarm.WithRegisterer(cfg.PrometheusRegisterer))))
+		metricsOpts := []swarm.MetricsTracerOption{swarm.WithRegisterer(cfg.PrometheusRegisterer)}
+		if cfg.BandwidthMetricsByProtocol {
+			metricsOpts = append(metricsOpts, swarm.WithProtocolLabels())
+		}
+		opts = append(opts, swarm.WithMetricsTracer(swarm.NewMetricsTracer(metricsOpts...)))
 	}
 	// TODO: Make the swarm implementation configurable.
 	return swarm.NewSwarm(pid, cfg.Peerstore, opts...)
@@ -188,16 +324,19 @@ func (cfg *Config) addTransports(h host.Host) error {
 
 	fxopts := []fx.Option{
 		fx.WithLogger(func() fxevent.Logger { return getFXLogger() }),
-		fx.Provide(fx.Annotate(tptu.New, fx.ParamTags(`name:"security"`))),
+		fx.Provide(fx.Annotate(tptu.New, fx.ParamTags(`name:"security"`, "", "", "", "", `group:"upgraderopts"`))),
 		fx.Supply(cfg.Muxers),
 		fx.Supply(h.ID()),
 		fx.Provide(func() host.Host { return h }),
 		fx.Provide(func() crypto.PrivKey { return h.Peerstore().PrivKey(h.ID()) }),
-		fx.Provide(func() connmgr.ConnectionGater { return cfg.ConnectionGater }),
+		fx.Provide(func() connmgr.ConnectionGater { return cfg.connectionGater() }),
 		fx.Provide(func() pnet.PSK { return cfg.PSK }),
 		fx.Provide(func() network.ResourceManager { return cfg.ResourceManager }),
 		fx.Provide(func() *madns.Resolver { return cfg.MultiaddrResolver }),
 	}
+	for _, opt := range cfg.upgraderOptions() {
+		fxopts = append(fxopts, fx.Supply(fx.Annotate(opt, fx.ResultTags(`group:"upgraderopts"`))))
+	}
 	fxopts = append(fxopts, cfg.Transports...)
 	if cfg.Insecure {
 		fxopts = append(fxopts,
@@ -249,7 +388,12 @@ func (cfg *Config) addTransports(h host.Host) error {
 			)))
 	}
 
-	fxopts = append(fxopts, fx.Provide(PrivKeyToStatelessResetKey))
+	if cfg.QUICStatelessResetKey != nil {
+		key := *cfg.QUICStatelessResetKey
+		fxopts = append(fxopts, fx.Provide(func() quic.StatelessResetKey { return key }))
+	} else {
+		fxopts = append(fxopts, fx.Provide(PrivKeyToStatelessResetKey))
+	}
 	if cfg.QUICReuse != nil {
 		fxopts = append(fxopts, cfg.QUICReuse...)
 	} else {
@@ -280,28 +424,166 @@ func (cfg *Config) addTransports(h host.Host) error {
 	return nil
 }
 
+func (cfg *Config) upgraderOptions() []tptu.Option {
+	var opts []tptu.Option
+	if cfg.SecurityHandshakeTimeout != 0 {
+		opts = append(opts, tptu.WithSecurityHandshakeTimeout(cfg.SecurityHandshakeTimeout))
+	}
+	if cfg.MuxerNegotiationTimeout != 0 {
+		opts = append(opts, tptu.WithMuxerNegotiationTimeout(cfg.MuxerNegotiationTimeout))
+	}
+	for _, i := range cfg.UpgradeInterceptors {
+		opts = append(opts, tptu.WithInterceptor(i))
+	}
+	if cfg.Throttler != nil {
+		opts = append(opts, tptu.WithThrottler(cfg.Throttler))
+	}
+	if cfg.InboundAdmission != nil {
+		opts = append(opts, tptu.WithAdmission(cfg.InboundAdmission))
+	}
+	if cfg.MaxPendingInboundHandshakes > 0 {
+		opts = append(opts, tptu.WithLoadShedding(cfg.MaxPendingInboundHandshakes))
+	}
+	if cfg.TracerProvider != nil {
+		opts = append(opts, tptu.WithTracerProvider(cfg.TracerProvider))
+	}
+	if !cfg.DisableMetrics {
+		opts = append(opts, tptu.WithMetricsTracer(tptu.NewMetricsTracer(tptu.WithRegisterer(cfg.PrometheusRegisterer))))
+	}
+	return opts
+}
+
+// makeKeyRotationRecord creates a key rotation record linking the peer ID of
+// PreviousPeerKey to the peer ID of PeerKey, signed by PreviousPeerKey.
+func (cfg *Config) makeKeyRotationRecord() (*record.Envelope, error) {
+	oldID, err := peer.IDFromPrivateKey(cfg.PreviousPeerKey)
+	if err != nil {
+		return nil, err
+	}
+	newID, err := peer.IDFromPrivateKey(cfg.PeerKey)
+	if err != nil {
+		return nil, err
+	}
+	if oldID == newID {
+		return nil, errors.New("previous identity is the same as the current identity")
+	}
+	rec := &peer.KeyRotationRecord{OldID: oldID, NewID: newID, Seq: peer.TimestampSeq()}
+	env, err := rec.Sign(cfg.PreviousPeerKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign key rotation record: %w", err)
+	}
+	return env, nil
+}
+
+// checkRawStreamMuxer makes sure that the raw stream muxer is only used if it was enabled
+// explicitly, and not together with services that open streams on their own, which
+// would take the single stream of raw connections.
+func (cfg *Config) checkRawStreamMuxer() error {
+	var usesRaw bool
+	for _, m := range cfg.Muxers {
+		if m.ID == raw.ID {
+			usesRaw = true
+		}
+	}
+	if !usesRaw {
+		return nil
+	}
+	if !cfg.EnableRawStreamMuxer {
+		return errors.New("the raw stream muxer can only be used with the EnableRawStreamMuxer option")
+	}
+	switch {
+	case cfg.EnableHolePunching:
+		return errors.New("the raw stream muxer can't be used with hole punching")
+	case cfg.EnableAutoRelay:
+		return errors.New("the raw stream muxer can't be used with AutoRelay")
+	case cfg.EnablePeerExchange:
+		return errors.New("the raw stream muxer can't be used with peer exchange")
+	case cfg.EnableHealthCheck:
+		return errors.New("the raw stream muxer can't be used with health checks")
+	case cfg.EnableStreamMigration:
+		return errors.New("the raw stream muxer can't be used with stream migration")
+	case len(cfg.PingOptions) > 0:
+		return errors.New("the raw stream muxer can't be used with background pings")
+	}
+	return nil
+}
+
 // NewNode constructs a new libp2p Host from the Config.
 //
 // This function consumes the config. Do not reuse it (really!).
-func (cfg *Config) NewNode() (host.Host, error) {
-	swrm, err := cfg.makeSwarm(!cfg.DisableMetrics)
+func (cfg *Config) NewNode() (_ host.Host, err error) {
+	defer func() {
+		if err != nil {
+			cfg.closeResources()
+		}
+	}()
+
+	if err := cfg.checkRawStreamMuxer(); err != nil {
+		return nil, err
+	}
+
+	var eventBus event.Bus
+	if !cfg.DisableMetrics {
+		eventBus = eventbus.NewBus(
+			eventbus.WithMetricsTracer(eventbus.NewMetricsTracer(eventbus.WithRegisterer(cfg.PrometheusRegisterer))))
+	} else {
+		eventBus = eventbus.NewBus()
+	}
+
+	swrm, err := cfg.makeSwarm(eventBus, !cfg.DisableMetrics)
 	if err != nil {
 		return nil, err
 	}
 
+	introspectionOpts := cfg.IntrospectionOpts
+	if cfg.IntrospectionAddr != "" && cfg.Reporter != nil {
+		introspectionOpts = append([]introspect.Option{introspect.WithBandwidthReporter(cfg.Reporter)}, introspectionOpts...)
+	}
+
+	var keyRotationRecord *record.Envelope
+	if cfg.PreviousPeerKey != nil {
+		keyRotationRecord, err = cfg.makeKeyRotationRecord()
+		if err != nil {
+			swrm.Close()
+			return nil, err
+		}
+	}
+
 	h, err := bhost.NewHost(swrm, &bhost.HostOpts{
-		ConnManager:          cfg.ConnManager,
-		AddrsFactory:         cfg.AddrsFactory,
-		NATManager:           cfg.NATManager,
-		EnablePing:           !cfg.DisablePing,
-		UserAgent:            cfg.UserAgent,
-		ProtocolVersion:      cfg.ProtocolVersion,
-		EnableHolePunching:   cfg.EnableHolePunching,
-		HolePunchingOptions:  cfg.HolePunchingOptions,
-		EnableRelayService:   cfg.EnableRelayService,
-		RelayServiceOpts:     cfg.RelayServiceOpts,
-		EnableMetrics:        !cfg.DisableMetrics,
-		PrometheusRegisterer: cfg.PrometheusRegisterer,
+		EventBus:                eventBus,
+		ConnManager:             cfg.ConnManager,
+		AddrsFactory:            cfg.AddrsFactory,
+		AddrPolicy:              cfg.AddrPolicy,
+		NATManager:              cfg.NATManager,
+		EnablePing:              !cfg.DisablePing,
+		PingOptions:             cfg.PingOptions,
+		UserAgent:               cfg.UserAgent,
+		ProtocolVersion:         cfg.ProtocolVersion,
+		Logger:                  cfg.Logger,
+		EnableHolePunching:      cfg.EnableHolePunching,
+		HolePunchingOptions:     cfg.HolePunchingOptions,
+		EnablePeerExchange:      cfg.EnablePeerExchange,
+		PeerExchangeOptions:     cfg.PeerExchangeOptions,
+		EnableHealthCheck:       cfg.EnableHealthCheck,
+		HealthCheckOptions:      cfg.HealthCheckOptions,
+		EnableResourceWatchdog:  cfg.EnableResourceWatchdog,
+		ResourceWatchdogOptions: cfg.ResourceWatchdogOptions,
+		EnableRelayService:      cfg.EnableRelayService,
+		RelayServiceOpts:        cfg.RelayServiceOpts,
+		EnableMetrics:           !cfg.DisableMetrics,
+		PrometheusRegisterer:    cfg.PrometheusRegisterer,
+		IntrospectionAddr:       cfg.IntrospectionAddr,
+		IntrospectionOpts:       introspectionOpts,
+
+		FirstStreamNegotiationTimeout: cfg.FirstStreamNegotiationTimeout,
+		StreamMiddleware:              cfg.StreamMiddleware,
+		EnableAddrChangeMonitor:       cfg.EnableAddrChangeMonitor,
+		AddrGracePeriod:               cfg.AddrGracePeriod,
+		ProtocolTags:                  cfg.ProtocolTags,
+		IdleConnTimeout:               cfg.IdleConnTimeout,
+		EnableStreamMigration:         cfg.EnableStreamMigration,
+		EnableRawStreamMuxer:          cfg.EnableRawStreamMuxer,
+		KeyRotationRecord:             keyRotationRecord,
 	})
 	if err != nil {
 		swrm.Close()
@@ -392,12 +674,18 @@ func (cfg *Config) NewNode() (host.Host, error) {
 			Insecure:           cfg.Insecure,
 			PSK:                cfg.PSK,
 			ConnectionGater:    cfg.ConnectionGater,
+			AddressFilters:     cfg.AddressFilters,
 			Reporter:           cfg.Reporter,
 			PeerKey:            autonatPrivKey,
 			Peerstore:          ps,
+			DisableMetrics:     true,
+			// The dialer dials the addresses of peers requesting a dial-back,
+			// many of which are expected to be unreachable.
+			UDPBlackHoleConfig:  &swarm.BlackHoleConfig{Enabled: false},
+			IPv6BlackHoleConfig: &swarm.BlackHoleConfig{Enabled: false},
 		}
 
-		dialer, err := autoNatCfg.makeSwarm(false)
+		dialer, err := autoNatCfg.makeSwarm(nil, false)
 		if err != nil {
 			h.Close()
 			return nil, err
@@ -452,6 +740,8 @@ func (cfg *Config) Apply(opts ...Option) error {
 			continue
 		}
 		if err := opt(cfg); err != nil {
+			// the node won't be constructed
+			cfg.closeResources()
 			return err
 		}
 	}
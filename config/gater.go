@@ -0,0 +1,66 @@
+package config
+
+import (
+	"context"
+
+	"github.com/AstaFrode/go-libp2p/core/connmgr"
+	"github.com/AstaFrode/go-libp2p/core/network"
+	"github.com/AstaFrode/go-libp2p/core/peer"
+
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// connectionGater returns the connection gater of the node, applying the address filters
+// before the configured connection gater.
+func (cfg *Config) connectionGater() connmgr.ConnectionGater {
+	if cfg.AddressFilters == nil {
+		return cfg.ConnectionGater
+	}
+	if cfg.ConnectionGater == nil {
+		return cfg.AddressFilters
+	}
+	return connmgr.AsGater(gaterChain{
+		connmgr.AsGaterV2(cfg.AddressFilters),
+		connmgr.AsGaterV2(cfg.ConnectionGater),
+	})
+}
+
+// gaterChain consults its gaters in order. It returns the first verdict rejecting the
+// connection, or else the verdict with the longest delay.
+type gaterChain []connmgr.ConnectionGaterV2
+
+var _ connmgr.ConnectionGaterV2 = gaterChain{}
+
+func (c gaterChain) verdict(intercept func(connmgr.ConnectionGaterV2) connmgr.Verdict) connmgr.Verdict {
+	res := connmgr.Allow()
+	for _, g := range c {
+		v := intercept(g)
+		if !v.Allowed() {
+			return v
+		}
+		if v.Kind == connmgr.VerdictDelay && v.Delay > res.Delay {
+			res = v
+		}
+	}
+	return res
+}
+
+func (c gaterChain) InterceptPeerDial(ctx context.Context, p peer.ID) connmgr.Verdict {
+	return c.verdict(func(g connmgr.ConnectionGaterV2) connmgr.Verdict { return g.InterceptPeerDial(ctx, p) })
+}
+
+func (c gaterChain) InterceptAddrDial(ctx context.Context, p peer.ID, a ma.Multiaddr) connmgr.Verdict {
+	return c.verdict(func(g connmgr.ConnectionGaterV2) connmgr.Verdict { return g.InterceptAddrDial(ctx, p, a) })
+}
+
+func (c gaterChain) InterceptAccept(ctx context.Context, addrs network.ConnMultiaddrs) connmgr.Verdict {
+	return c.verdict(func(g connmgr.ConnectionGaterV2) connmgr.Verdict { return g.InterceptAccept(ctx, addrs) })
+}
+
+func (c gaterChain) InterceptSecured(ctx context.Context, dir network.Direction, p peer.ID, addrs network.ConnMultiaddrs) connmgr.Verdict {
+	return c.verdict(func(g connmgr.ConnectionGaterV2) connmgr.Verdict { return g.InterceptSecured(ctx, dir, p, addrs) })
+}
+
+func (c gaterChain) InterceptUpgraded(ctx context.Context, conn network.Conn) connmgr.Verdict {
+	return c.verdict(func(g connmgr.ConnectionGaterV2) connmgr.Verdict { return g.InterceptUpgraded(ctx, conn) })
+}
@@ -0,0 +1,217 @@
+package connmgr
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	ma "github.com/multiformats/go-multiaddr"
+
+	"github.com/AstaFrode/go-libp2p/core/control"
+	"github.com/AstaFrode/go-libp2p/core/network"
+	"github.com/AstaFrode/go-libp2p/core/peer"
+)
+
+// VerdictKind is the decision a ConnectionGaterV2 takes about a connection.
+type VerdictKind int
+
+const (
+	// VerdictAllow allows the connection to proceed.
+	VerdictAllow VerdictKind = iota
+	// VerdictDeny rejects the connection right away.
+	VerdictDeny
+	// VerdictDelay allows the connection to proceed after Verdict.Delay has elapsed.
+	VerdictDelay
+	// VerdictTarpit rejects the connection after Verdict.Delay has elapsed.
+	// For inbound connections, the connection is held open (without reading
+	// from it) for the duration of the delay, slowing down abusive peers.
+	VerdictTarpit
+)
+
+func (k VerdictKind) String() string {
+	switch k {
+	case VerdictAllow:
+		return "allow"
+	case VerdictDeny:
+		return "deny"
+	case VerdictDelay:
+		return "delay"
+	case VerdictTarpit:
+		return "tarpit"
+	default:
+		return fmt.Sprintf("unknown verdict %d", int(k))
+	}
+}
+
+// Verdict is returned by the ConnectionGaterV2 methods.
+type Verdict struct {
+	Kind VerdictKind
+	// Reason is a human-readable explanation why the connection was denied.
+	// It is included in the error returned to the caller.
+	Reason string
+	// Delay is the time to wait before applying the verdict.
+	// Only used for VerdictDelay and VerdictTarpit.
+	Delay time.Duration
+	// DisconnectReason is sent to the remote peer when rejecting an upgraded connection.
+	// See ConnectionGater.InterceptUpgraded.
+	DisconnectReason control.DisconnectReason
+}
+
+// Allow returns a verdict that allows the connection.
+func Allow() Verdict { return Verdict{Kind: VerdictAllow} }
+
+// Deny returns a verdict that rejects the connection.
+func Deny(reason string) Verdict { return Verdict{Kind: VerdictDeny, Reason: reason} }
+
+// Delay returns a verdict that allows the connection after d.
+func Delay(d time.Duration) Verdict { return Verdict{Kind: VerdictDelay, Delay: d} }
+
+// Tarpit returns a verdict that rejects the connection after d.
+func Tarpit(d time.Duration, reason string) Verdict {
+	return Verdict{Kind: VerdictTarpit, Delay: d, Reason: reason}
+}
+
+// Allowed says if the connection is allowed to proceed (after the delay, if any).
+func (v Verdict) Allowed() bool {
+	return v.Kind == VerdictAllow || v.Kind == VerdictDelay
+}
+
+// Wait blocks for the delay mandated by the verdict, and returns whether the
+// connection is allowed to proceed. It returns false if the context is
+// canceled while waiting.
+func (v Verdict) Wait(ctx context.Context) bool {
+	if (v.Kind == VerdictDelay || v.Kind == VerdictTarpit) && v.Delay > 0 {
+		t := time.NewTimer(v.Delay)
+		defer t.Stop()
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return false
+		}
+	}
+	return v.Allowed()
+}
+
+func (v Verdict) String() string {
+	if v.Reason == "" {
+		return v.Kind.String()
+	}
+	return fmt.Sprintf("%s (%s)", v.Kind, v.Reason)
+}
+
+// ConnectionGaterV2 is a context-aware version of the ConnectionGater.
+// It is consulted at the same stages in the lifecycle of a connection, but its
+// methods may block (e.g. to consult an external policy service), as long as
+// they respect the context. Instead of a boolean, they return a Verdict,
+// allowing the gater to give a reason, to delay a connection or to tarpit it.
+//
+// InterceptAccept is called on the accept loop of the listener, and should
+// return quickly. Delays and tarpits are applied asynchronously.
+//
+// Use AsGaterV2 and AsGater to convert between the two interfaces.
+type ConnectionGaterV2 interface {
+	// InterceptPeerDial tests whether we're permitted to Dial the specified peer.
+	InterceptPeerDial(context.Context, peer.ID) Verdict
+
+	// InterceptAddrDial tests whether we're permitted to dial the specified
+	// multiaddr for the given peer.
+	InterceptAddrDial(context.Context, peer.ID, ma.Multiaddr) Verdict
+
+	// InterceptAccept tests whether an incipient inbound connection is allowed.
+	InterceptAccept(context.Context, network.ConnMultiaddrs) Verdict
+
+	// InterceptSecured tests whether a given connection, now authenticated,
+	// is allowed.
+	InterceptSecured(context.Context, network.Direction, peer.ID, network.ConnMultiaddrs) Verdict
+
+	// InterceptUpgraded tests whether a fully capable connection is allowed.
+	InterceptUpgraded(context.Context, network.Conn) Verdict
+}
+
+// AsGaterV2 converts a ConnectionGater into a ConnectionGaterV2.
+// If g was created by AsGater, the original ConnectionGaterV2 is returned.
+func AsGaterV2(g ConnectionGater) ConnectionGaterV2 {
+	if g == nil {
+		return nil
+	}
+	if lg, ok := g.(*legacyGater); ok {
+		return lg.ConnectionGaterV2
+	}
+	return &gaterV2Adapter{g: g}
+}
+
+// AsGater converts a ConnectionGaterV2 into a ConnectionGater, for use with
+// components that don't support the ConnectionGaterV2 yet.
+// The returned gater blocks for the delay mandated by the verdict.
+func AsGater(g ConnectionGaterV2) ConnectionGater {
+	if g == nil {
+		return nil
+	}
+	if a, ok := g.(*gaterV2Adapter); ok {
+		return a.g
+	}
+	return &legacyGater{ConnectionGaterV2: g}
+}
+
+type gaterV2Adapter struct {
+	g ConnectionGater
+}
+
+var _ ConnectionGaterV2 = &gaterV2Adapter{}
+
+func verdictFromBool(allow bool) Verdict {
+	if allow {
+		return Allow()
+	}
+	return Deny("")
+}
+
+func (a *gaterV2Adapter) InterceptPeerDial(_ context.Context, p peer.ID) Verdict {
+	return verdictFromBool(a.g.InterceptPeerDial(p))
+}
+
+func (a *gaterV2Adapter) InterceptAddrDial(_ context.Context, p peer.ID, addr ma.Multiaddr) Verdict {
+	return verdictFromBool(a.g.InterceptAddrDial(p, addr))
+}
+
+func (a *gaterV2Adapter) InterceptAccept(_ context.Context, addrs network.ConnMultiaddrs) Verdict {
+	return verdictFromBool(a.g.InterceptAccept(addrs))
+}
+
+func (a *gaterV2Adapter) InterceptSecured(_ context.Context, dir network.Direction, p peer.ID, addrs network.ConnMultiaddrs) Verdict {
+	return verdictFromBool(a.g.InterceptSecured(dir, p, addrs))
+}
+
+func (a *gaterV2Adapter) InterceptUpgraded(_ context.Context, c network.Conn) Verdict {
+	allow, reason := a.g.InterceptUpgraded(c)
+	v := verdictFromBool(allow)
+	v.DisconnectReason = reason
+	return v
+}
+
+type legacyGater struct {
+	ConnectionGaterV2
+}
+
+var _ ConnectionGater = &legacyGater{}
+
+func (g *legacyGater) InterceptPeerDial(p peer.ID) bool {
+	return g.ConnectionGaterV2.InterceptPeerDial(context.Background(), p).Wait(context.Background())
+}
+
+func (g *legacyGater) InterceptAddrDial(p peer.ID, addr ma.Multiaddr) bool {
+	return g.ConnectionGaterV2.InterceptAddrDial(context.Background(), p, addr).Wait(context.Background())
+}
+
+func (g *legacyGater) InterceptAccept(addrs network.ConnMultiaddrs) bool {
+	return g.ConnectionGaterV2.InterceptAccept(context.Background(), addrs).Wait(context.Background())
+}
+
+func (g *legacyGater) InterceptSecured(dir network.Direction, p peer.ID, addrs network.ConnMultiaddrs) bool {
+	return g.ConnectionGaterV2.InterceptSecured(context.Background(), dir, p, addrs).Wait(context.Background())
+}
+
+func (g *legacyGater) InterceptUpgraded(c network.Conn) (bool, control.DisconnectReason) {
+	v := g.ConnectionGaterV2.InterceptUpgraded(context.Background(), c)
+	return v.Wait(context.Background()), v.DisconnectReason
+}
@@ -0,0 +1,243 @@
+package crypto
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	pb "github.com/AstaFrode/go-libp2p/core/crypto/pb"
+	"github.com/AstaFrode/go-libp2p/core/internal/catch"
+
+	bls "github.com/cloudflare/circl/ecc/bls12381"
+)
+
+// BLS12381SignatureDST is the domain separation tag used when hashing messages to the curve.
+// It's the tag of the basic scheme of the BLS signature draft (draft-irtf-cfrg-bls-signature),
+// with public keys in G1 and signatures in G2.
+const BLS12381SignatureDST = "BLS_SIG_BLS12381G2_XMD:SHA-256_SSWU_RO_NUL_"
+
+// BLS12381PrivateKey is a BLS12-381 private key.
+type BLS12381PrivateKey struct {
+	k   bls.Scalar
+	pub *BLS12381PublicKey
+}
+
+// BLS12381PublicKey is a BLS12-381 public key, a point in G1.
+type BLS12381PublicKey struct {
+	k bls.G1
+}
+
+// GenerateBLS12381Key generates a new BLS12-381 private and public key pair.
+// It fails unless EnableExperimentalKeyTypes was called.
+func GenerateBLS12381Key(src io.Reader) (PrivKey, PubKey, error) {
+	if !experimentalKeyTypes.enabled {
+		return nil, nil, ErrExperimentalKeyTypesDisabled
+	}
+	var k BLS12381PrivateKey
+	for {
+		if err := k.k.Random(src); err != nil {
+			return nil, nil, err
+		}
+		if k.k.IsZero() == 0 {
+			break
+		}
+	}
+	k.pub = blsPublicKey(&k.k)
+	return &k, k.pub, nil
+}
+
+func blsPublicKey(sk *bls.Scalar) *BLS12381PublicKey {
+	var pub BLS12381PublicKey
+	pub.k.ScalarMult(sk, bls.G1Generator())
+	return &pub
+}
+
+// Type of the private key (BLS12381).
+func (k *BLS12381PrivateKey) Type() pb.KeyType {
+	return experimentalKeyTypes.bls12381
+}
+
+// Raw private key bytes.
+func (k *BLS12381PrivateKey) Raw() ([]byte, error) {
+	return k.k.MarshalBinary()
+}
+
+// Equals compares two BLS12-381 private keys.
+func (k *BLS12381PrivateKey) Equals(o Key) bool {
+	blsk, ok := o.(*BLS12381PrivateKey)
+	if !ok {
+		return basicEquals(k, o)
+	}
+
+	return k.k.IsEqual(&blsk.k) == 1
+}
+
+// GetPublic returns a BLS12-381 public key from a private key.
+func (k *BLS12381PrivateKey) GetPublic() PubKey {
+	return k.pub
+}
+
+// Sign returns a signature from an input message.
+func (k *BLS12381PrivateKey) Sign(msg []byte) (res []byte, err error) {
+	defer func() { catch.HandlePanic(recover(), &err, "BLS12-381 signing") }()
+
+	var sig bls.G2
+	sig.Hash(msg, []byte(BLS12381SignatureDST))
+	sig.ScalarMult(&k.k, &sig)
+	return sig.BytesCompressed(), nil
+}
+
+// Type of the public key (BLS12381).
+func (k *BLS12381PublicKey) Type() pb.KeyType {
+	return experimentalKeyTypes.bls12381
+}
+
+// Raw public key bytes, the compressed encoding of the G1 point.
+func (k *BLS12381PublicKey) Raw() ([]byte, error) {
+	return k.k.BytesCompressed(), nil
+}
+
+// Equals compares two BLS12-381 public keys.
+func (k *BLS12381PublicKey) Equals(o Key) bool {
+	blsk, ok := o.(*BLS12381PublicKey)
+	if !ok {
+		return basicEquals(k, o)
+	}
+
+	return k.k.IsEqual(&blsk.k)
+}
+
+// Verify checks a signature against the input data.
+func (k *BLS12381PublicKey) Verify(data []byte, sig []byte) (success bool, err error) {
+	defer func() {
+		catch.HandlePanic(recover(), &err, "BLS12-381 signature verification")
+
+		// To be safe.
+		if err != nil {
+			success = false
+		}
+	}()
+
+	return verifyBLS12381([]*BLS12381PublicKey{k}, [][]byte{data}, sig), nil
+}
+
+// AggregateBLS12381Signatures aggregates BLS12-381 signatures into a single signature.
+// The aggregate signature can be verified using VerifyBLS12381Aggregate.
+func AggregateBLS12381Signatures(sigs ...[]byte) (res []byte, err error) {
+	defer func() { catch.HandlePanic(recover(), &err, "BLS12-381 signature aggregation") }()
+
+	if len(sigs) == 0 {
+		return nil, errors.New("no signatures to aggregate")
+	}
+	var agg bls.G2
+	agg.SetIdentity()
+	for i, s := range sigs {
+		var sig bls.G2
+		if err := sig.SetBytes(s); err != nil {
+			return nil, fmt.Errorf("invalid signature %d: %w", i, err)
+		}
+		agg.Add(&agg, &sig)
+	}
+	return agg.BytesCompressed(), nil
+}
+
+// VerifyBLS12381Aggregate verifies an aggregate signature, where msgs[i] was signed by keys[i].
+// All keys must be BLS12-381 public keys.
+//
+// The messages must be distinct. Aggregating signatures on the same message is
+// vulnerable to rogue key attacks, unless the keys are known to be valid
+// (e.g. using a proof of possession), and is rejected by this function.
+func VerifyBLS12381Aggregate(keys []PubKey, msgs [][]byte, sig []byte) (success bool, err error) {
+	defer func() {
+		catch.HandlePanic(recover(), &err, "BLS12-381 aggregate signature verification")
+
+		// To be safe.
+		if err != nil {
+			success = false
+		}
+	}()
+
+	if len(keys) == 0 || len(keys) != len(msgs) {
+		return false, errors.New("expected the same, non-zero number of keys and messages")
+	}
+	pubs := make([]*BLS12381PublicKey, 0, len(keys))
+	for _, k := range keys {
+		pub, ok := k.(*BLS12381PublicKey)
+		if !ok {
+			return false, ErrBadKeyType
+		}
+		pubs = append(pubs, pub)
+	}
+	seen := make(map[string]struct{}, len(msgs))
+	for _, m := range msgs {
+		if _, ok := seen[string(m)]; ok {
+			return false, errors.New("aggregate signature verification requires distinct messages")
+		}
+		seen[string(m)] = struct{}{}
+	}
+	return verifyBLS12381(pubs, msgs, sig), nil
+}
+
+// verifyBLS12381 checks that e(g1, sig) == e(pk_1, H(msg_1)) * ... * e(pk_n, H(msg_n)).
+func verifyBLS12381(pubs []*BLS12381PublicKey, msgs [][]byte, sig []byte) bool {
+	var s bls.G2
+	if err := s.SetBytes(sig); err != nil || len(sig) != bls.G2SizeCompressed {
+		return false
+	}
+
+	g1s := make([]*bls.G1, 0, len(pubs)+1)
+	g2s := make([]*bls.G2, 0, len(pubs)+1)
+	signs := make([]int, 0, len(pubs)+1)
+	for i, pub := range pubs {
+		// ProdPairFrac normalizes the points in place, so copy the public key.
+		pk := pub.k
+		var h bls.G2
+		h.Hash(msgs[i], []byte(BLS12381SignatureDST))
+		g1s = append(g1s, &pk)
+		g2s = append(g2s, &h)
+		signs = append(signs, 1)
+	}
+	g1s = append(g1s, bls.G1Generator())
+	g2s = append(g2s, &s)
+	signs = append(signs, -1)
+	return bls.ProdPairFrac(g1s, g2s, signs).IsIdentity()
+}
+
+// UnmarshalBLS12381PublicKey returns a public key from input bytes.
+func UnmarshalBLS12381PublicKey(data []byte) (PubKey, error) {
+	if !experimentalKeyTypes.enabled {
+		return nil, ErrExperimentalKeyTypesDisabled
+	}
+	if len(data) != bls.G1SizeCompressed {
+		return nil, fmt.Errorf("expect BLS12-381 public key data size to be %d", bls.G1SizeCompressed)
+	}
+
+	var pub BLS12381PublicKey
+	if err := pub.k.SetBytes(data); err != nil {
+		return nil, err
+	}
+	if pub.k.IsIdentity() {
+		return nil, errors.New("BLS12-381 public key is the identity element")
+	}
+	return &pub, nil
+}
+
+// UnmarshalBLS12381PrivateKey returns a private key from input bytes.
+func UnmarshalBLS12381PrivateKey(data []byte) (PrivKey, error) {
+	if !experimentalKeyTypes.enabled {
+		return nil, ErrExperimentalKeyTypesDisabled
+	}
+	if len(data) != bls.ScalarSize {
+		return nil, fmt.Errorf("expected BLS12-381 data size to be %d, got %d", bls.ScalarSize, len(data))
+	}
+
+	var k BLS12381PrivateKey
+	if err := k.k.UnmarshalBinary(data); err != nil {
+		return nil, err
+	}
+	if k.k.IsZero() == 1 {
+		return nil, errors.New("BLS12-381 private key is zero")
+	}
+	k.pub = blsPublicKey(&k.k)
+	return &k, nil
+}
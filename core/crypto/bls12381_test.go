@@ -0,0 +1,113 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"fmt"
+	"testing"
+)
+
+func TestBLS12381BasicSignAndVerify(t *testing.T) {
+	enableExperimentalKeyTypes(t)
+	priv, pub, err := GenerateBLS12381Key(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := []byte("hello! and welcome to some awesome crypto primitives")
+
+	sig, err := priv.Sign(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ok, err := pub.Verify(data, sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("signature didn't match")
+	}
+
+	// change data
+	data[0] = ^data[0]
+	ok, err = pub.Verify(data, sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok {
+		t.Fatal("signature matched and shouldn't")
+	}
+
+	// a malformed signature doesn't verify
+	ok, err = pub.Verify(data, sig[1:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok {
+		t.Fatal("malformed signature matched")
+	}
+}
+
+func TestBLS12381Aggregate(t *testing.T) {
+	enableExperimentalKeyTypes(t)
+	const n = 4
+	keys := make([]PubKey, 0, n)
+	msgs := make([][]byte, 0, n)
+	sigs := make([][]byte, 0, n)
+	for i := 0; i < n; i++ {
+		priv, pub, err := GenerateBLS12381Key(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		msg := []byte(fmt.Sprintf("message %d", i))
+		sig, err := priv.Sign(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, pub)
+		msgs = append(msgs, msg)
+		sigs = append(sigs, sig)
+	}
+
+	agg, err := AggregateBLS12381Signatures(sigs...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ok, err := VerifyBLS12381Aggregate(keys, msgs, agg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("aggregate signature didn't match")
+	}
+
+	// swapping messages invalidates the signature
+	msgs[0], msgs[1] = msgs[1], msgs[0]
+	ok, err = VerifyBLS12381Aggregate(keys, msgs, agg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok {
+		t.Fatal("aggregate signature matched and shouldn't")
+	}
+
+	// duplicate messages are rejected
+	msgs[0] = msgs[1]
+	if _, err := VerifyBLS12381Aggregate(keys, msgs, agg); err == nil {
+		t.Fatal("expected an error for duplicate messages")
+	}
+}
+
+func TestBLS12381UnmarshalErrors(t *testing.T) {
+	enableExperimentalKeyTypes(t)
+	// the compressed encoding of the identity element
+	identity := make([]byte, 48)
+	identity[0] = 0xc0
+	if _, err := UnmarshalBLS12381PublicKey(identity); err == nil {
+		t.Fatal("expected an error for the identity element")
+	}
+
+	if _, err := UnmarshalBLS12381PrivateKey(make([]byte, 32)); err == nil {
+		t.Fatal("expected an error for a zero private key")
+	}
+}
@@ -0,0 +1,70 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"testing"
+)
+
+func TestEd448BasicSignAndVerify(t *testing.T) {
+	enableExperimentalKeyTypes(t)
+	priv, pub, err := GenerateEd448Key(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := []byte("hello! and welcome to some awesome crypto primitives")
+
+	sig, err := priv.Sign(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ok, err := pub.Verify(data, sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("signature didn't match")
+	}
+
+	// change data
+	data[0] = ^data[0]
+	ok, err = pub.Verify(data, sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok {
+		t.Fatal("signature matched and shouldn't")
+	}
+}
+
+func TestEd448UnmarshalErrors(t *testing.T) {
+	enableExperimentalKeyTypes(t)
+	t.Run("PublicKey", func(t *testing.T) {
+		_, err := UnmarshalEd448PublicKey([]byte{42})
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+
+	t.Run("PrivateKey", func(t *testing.T) {
+		priv, _, err := GenerateEd448Key(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw, err := priv.Raw()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := UnmarshalEd448PrivateKey(raw[:len(raw)-1]); err == nil {
+			t.Fatal("expected an error for a truncated key")
+		}
+
+		// corrupt the public key contained in the private key
+		raw[len(raw)-1] ^= 0xff
+		if _, err := UnmarshalEd448PrivateKey(raw); err == nil {
+			t.Fatal("expected an error for a mismatching public key")
+		}
+	})
+}
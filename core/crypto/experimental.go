@@ -0,0 +1,60 @@
+package crypto
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/AstaFrode/go-libp2p/core/crypto/pb"
+)
+
+// ErrExperimentalKeyTypesDisabled is returned when creating an Ed448 or BLS12-381 key
+// before EnableExperimentalKeyTypes was called.
+var ErrExperimentalKeyTypesDisabled = errors.New("experimental key types are not enabled")
+
+var experimentalKeyTypes struct {
+	enabled  bool
+	ed448    pb.KeyType
+	bls12381 pb.KeyType
+}
+
+// EnableExperimentalKeyTypes enables the Ed448 and BLS12-381 key types.
+//
+// These key types are not part of the libp2p peer ID specification, which doesn't
+// assign them a KeyType. The caller chooses the KeyType values used to encode them
+// on the wire, and all peers of the network need to agree on these values. Other
+// peers can't parse these keys, and fail the handshake.
+//
+// Like changes to PubKeyUnmarshallers and PrivKeyUnmarshallers, this must be called
+// before any key is created or unmarshalled, and isn't safe for concurrent use.
+// Calling it again with the same values is a no-op.
+func EnableExperimentalKeyTypes(ed448, bls12381 pb.KeyType) error {
+	if experimentalKeyTypes.enabled {
+		if ed448 == experimentalKeyTypes.ed448 && bls12381 == experimentalKeyTypes.bls12381 {
+			return nil
+		}
+		return errors.New("experimental key types already enabled with different key type values")
+	}
+	if ed448 == bls12381 {
+		return errors.New("Ed448 and BLS12-381 key types must be different")
+	}
+	for _, typ := range []pb.KeyType{ed448, bls12381} {
+		if _, ok := pb.KeyType_name[int32(typ)]; ok {
+			return fmt.Errorf("key type %d is assigned by the specification", typ)
+		}
+		if _, ok := PubKeyUnmarshallers[typ]; ok {
+			return fmt.Errorf("key type %d is already registered", typ)
+		}
+		if _, ok := PrivKeyUnmarshallers[typ]; ok {
+			return fmt.Errorf("key type %d is already registered", typ)
+		}
+	}
+
+	experimentalKeyTypes.enabled = true
+	experimentalKeyTypes.ed448 = ed448
+	experimentalKeyTypes.bls12381 = bls12381
+	PubKeyUnmarshallers[ed448] = UnmarshalEd448PublicKey
+	PrivKeyUnmarshallers[ed448] = UnmarshalEd448PrivateKey
+	PubKeyUnmarshallers[bls12381] = UnmarshalBLS12381PublicKey
+	PrivKeyUnmarshallers[bls12381] = UnmarshalBLS12381PrivateKey
+	return nil
+}
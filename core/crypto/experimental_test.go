@@ -0,0 +1,99 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"testing"
+
+	"github.com/AstaFrode/go-libp2p/core/crypto/pb"
+)
+
+const (
+	testKeyTypeEd448    pb.KeyType = 1000
+	testKeyTypeBLS12381 pb.KeyType = 1001
+)
+
+func enableExperimentalKeyTypes(t testing.TB) {
+	t.Helper()
+	if err := EnableExperimentalKeyTypes(testKeyTypeEd448, testKeyTypeBLS12381); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExperimentalKeyTypesDisabled(t *testing.T) {
+	saved := experimentalKeyTypes
+	experimentalKeyTypes.enabled = false
+	defer func() { experimentalKeyTypes = saved }()
+
+	if _, _, err := GenerateKeyPairWithReader(Ed448, 0, rand.Reader); err != ErrExperimentalKeyTypesDisabled {
+		t.Fatalf("expected ErrExperimentalKeyTypesDisabled, got %v", err)
+	}
+	if _, _, err := GenerateKeyPairWithReader(BLS12381, 0, rand.Reader); err != ErrExperimentalKeyTypesDisabled {
+		t.Fatalf("expected ErrExperimentalKeyTypesDisabled, got %v", err)
+	}
+	if _, err := UnmarshalEd448PublicKey(nil); err != ErrExperimentalKeyTypesDisabled {
+		t.Fatalf("expected ErrExperimentalKeyTypesDisabled, got %v", err)
+	}
+}
+
+func TestEnableExperimentalKeyTypes(t *testing.T) {
+	enableExperimentalKeyTypes(t)
+	// Enabling again with the same values is fine, but the values can't change.
+	enableExperimentalKeyTypes(t)
+	if err := EnableExperimentalKeyTypes(testKeyTypeEd448+10, testKeyTypeBLS12381+10); err == nil {
+		t.Fatal("expected an error when changing the key type values")
+	}
+
+	for _, typ := range []int{Ed448, BLS12381} {
+		priv, pub, err := GenerateKeyPair(typ, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := pb.KeyType_name[int32(pub.Type())]; ok {
+			t.Fatalf("key type %s is assigned by the specification", pub.Type())
+		}
+		sig, err := priv.Sign([]byte("data"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		bytes, err := MarshalPublicKey(pub)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pub2, err := UnmarshalPublicKey(bytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !pub.Equals(pub2) {
+			t.Fatal("public keys don't match after a marshalling round trip")
+		}
+		if ok, err := pub2.Verify([]byte("data"), sig); err != nil || !ok {
+			t.Fatal("signature didn't match")
+		}
+
+		bytes, err = MarshalPrivateKey(priv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		priv2, err := UnmarshalPrivateKey(bytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !priv.Equals(priv2) {
+			t.Fatal("private keys don't match after a marshalling round trip")
+		}
+	}
+}
+
+func TestEnableExperimentalKeyTypesSpecValues(t *testing.T) {
+	saved := experimentalKeyTypes
+	experimentalKeyTypes.enabled = false
+	defer func() { experimentalKeyTypes = saved }()
+
+	if err := EnableExperimentalKeyTypes(pb.KeyType_ECDSA, testKeyTypeBLS12381); err == nil {
+		t.Fatal("expected an error when using a key type assigned by the specification")
+	}
+	if err := EnableExperimentalKeyTypes(testKeyTypeEd448, testKeyTypeEd448); err == nil {
+		t.Fatal("expected an error when using the same key type twice")
+	}
+}
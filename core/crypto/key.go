@@ -28,6 +28,10 @@ const (
 	Secp256k1
 	// ECDSA is an enum for the supported ECDSA key type
 	ECDSA
+	// Ed448 is an enum for the Ed448 key type, see EnableExperimentalKeyTypes
+	Ed448
+	// BLS12381 is an enum for the BLS12-381 key type, see EnableExperimentalKeyTypes
+	BLS12381
 )
 
 var (
@@ -117,6 +121,10 @@ func GenerateKeyPairWithReader(typ, bits int, src io.Reader) (PrivKey, PubKey, e
 		return GenerateSecp256k1Key(src)
 	case ECDSA:
 		return GenerateECDSAKeyPair(src)
+	case Ed448:
+		return GenerateEd448Key(src)
+	case BLS12381:
+		return GenerateBLS12381Key(src)
 	default:
 		return nil, nil, ErrBadKeyType
 	}
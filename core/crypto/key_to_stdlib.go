@@ -6,6 +6,7 @@ import (
 	"crypto/ed25519"
 	"crypto/rsa"
 
+	"github.com/cloudflare/circl/sign/ed448"
 	"github.com/decred/dcrd/dcrec/secp256k1/v4"
 )
 
@@ -27,6 +28,13 @@ func KeyPairFromStdKey(priv crypto.PrivateKey) (PrivKey, PubKey, error) {
 		pub, _ := pubIfc.(ed25519.PublicKey)
 		return &Ed25519PrivateKey{*p}, &Ed25519PublicKey{pub}, nil
 
+	case ed448.PrivateKey:
+		if !experimentalKeyTypes.enabled {
+			return nil, nil, ErrExperimentalKeyTypesDisabled
+		}
+		pub, _ := p.Public().(ed448.PublicKey)
+		return &Ed448PrivateKey{p}, &Ed448PublicKey{pub}, nil
+
 	case *secp256k1.PrivateKey:
 		sPriv := Secp256k1PrivateKey(*p)
 		sPub := Secp256k1PublicKey(*p.PubKey())
@@ -50,6 +58,8 @@ func PrivKeyToStdKey(priv PrivKey) (crypto.PrivateKey, error) {
 		return p.priv, nil
 	case *Ed25519PrivateKey:
 		return &p.k, nil
+	case *Ed448PrivateKey:
+		return p.k, nil
 	case *Secp256k1PrivateKey:
 		return p, nil
 	default:
@@ -70,6 +80,8 @@ func PubKeyToStdKey(pub PubKey) (crypto.PublicKey, error) {
 		return p.pub, nil
 	case *Ed25519PublicKey:
 		return p.k, nil
+	case *Ed448PublicKey:
+		return p.k, nil
 	case *Secp256k1PublicKey:
 		return p, nil
 	default:
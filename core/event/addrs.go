@@ -24,6 +24,10 @@ const (
 
 	// Removed means that the address was removed from the Host.
 	Removed
+
+	// Deprecated means that the address was removed from the Host, but is still
+	// advertised until its grace period expires. It is then Removed.
+	Deprecated
 )
 
 // UpdatedAddress is used in the EvtLocalAddressesUpdated event to convey
@@ -52,6 +56,9 @@ type UpdatedAddress struct {
 // the Current list was Added by the event producer, or was Maintained without
 // changes. Addresses that were removed from the Host will have the AddrAction
 // of Removed, and will be in the Removed list.
+// If the Host keeps advertising removed addresses for a grace period, they stay
+// in the Current list with the AddrAction of Deprecated until the grace period
+// expires, and are then moved to the Removed list.
 //
 // If the event producer is not capable or producing diffs, the Diffs field will
 // be false, the Removed list will always be empty, and the Action for each
@@ -69,8 +76,8 @@ type EvtLocalAddressesUpdated struct {
 
 	// Current contains all current listen addresses for the Host.
 	// If Diffs == true, the Action field of each UpdatedAddress will tell
-	// you whether an address was Added, or was Maintained from the previous
-	// state.
+	// you whether an address was Added, was Maintained from the previous
+	// state, or is Deprecated.
 	Current []UpdatedAddress
 
 	// Removed contains addresses that were removed from the Host.
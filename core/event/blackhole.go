@@ -0,0 +1,44 @@
+package event
+
+// BlackHoleState is the state of a black hole filter of the swarm.
+//
+// A black hole filter tracks the outcome of dials to a class of addresses (e.g. UDP
+// or IPv6 addresses). If (almost) all of them fail, the network is assumed to
+// drop this kind of traffic, and dials to these addresses are blocked, except for
+// periodic probes.
+type BlackHoleState int
+
+const (
+	// BlackHoleStateProbing means that not enough dials were made yet to decide. All dials are allowed.
+	BlackHoleStateProbing BlackHoleState = iota
+	// BlackHoleStateAllowed means that enough dials succeeded. All dials are allowed.
+	BlackHoleStateAllowed
+	// BlackHoleStateBlocked means that the addresses are considered black holed.
+	// Only a fraction of dials are allowed, to probe if the situation changed.
+	BlackHoleStateBlocked
+)
+
+func (s BlackHoleState) String() string {
+	switch s {
+	case BlackHoleStateProbing:
+		return "probing"
+	case BlackHoleStateAllowed:
+		return "allowed"
+	case BlackHoleStateBlocked:
+		return "blocked"
+	default:
+		return "unknown"
+	}
+}
+
+// EvtBlackHoleStateChanged is emitted when the state of a black hole filter changes.
+type EvtBlackHoleStateChanged struct {
+	// Name is the name of the filter, e.g. "UDP" or "IPv6".
+	Name string
+	// State is the new state of the filter.
+	State BlackHoleState
+	// SuccessfulDials is the number of successful dials in the current window.
+	SuccessfulDials int
+	// TotalDials is the number of dials in the current window.
+	TotalDials int
+}
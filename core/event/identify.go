@@ -15,3 +15,13 @@ type EvtPeerIdentificationFailed struct {
 	// Reason is the reason why identification failed.
 	Reason error
 }
+
+// EvtPeerKeyRotated is emitted when a peer announces (via identify) that it rotated its identity key,
+// and the announcement was verified and stored in the peerstore.
+// Applications can use this event to migrate references from the peer's old ID to its new ID.
+type EvtPeerKeyRotated struct {
+	// OldPeer is the ID the peer used before rotating its key.
+	OldPeer peer.ID
+	// NewPeer is the ID the peer uses now.
+	NewPeer peer.ID
+}
@@ -0,0 +1,24 @@
+package event
+
+import (
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// EvtListenFailure is emitted when the network fails to listen on an address,
+// either when the listener is first created, or when retrying to listen after
+// a listener was closed unexpectedly.
+type EvtListenFailure struct {
+	// Addr is the address we tried to listen on.
+	Addr ma.Multiaddr
+	// Error is the error returned by the transport.
+	Error error
+}
+
+// EvtListenerClosed is emitted when a listener is closed.
+type EvtListenerClosed struct {
+	// Addr is the address of the listener.
+	Addr ma.Multiaddr
+	// Error is the error that caused the listener to close, e.g. because the network
+	// interface was removed. It is nil if the listener was closed intentionally.
+	Error error
+}
@@ -0,0 +1,72 @@
+package event
+
+import (
+	"net"
+	"time"
+)
+
+// NATPortMappingStatus is the status of a port mapping on a NAT device.
+type NATPortMappingStatus int
+
+const (
+	// NATPortMappingEstablished means that a new port mapping was added on the NAT device.
+	NATPortMappingEstablished NATPortMappingStatus = iota
+	// NATPortMappingRenewed means that the lease of an existing port mapping was extended,
+	// but the NAT device assigned a different external port.
+	NATPortMappingRenewed
+	// NATPortMappingFailed means that the NAT device refused to add or renew a port mapping.
+	// The mapping will be retried periodically.
+	NATPortMappingFailed
+	// NATPortMappingRemoved means that the port mapping was removed, usually because
+	// we stopped listening on the internal port.
+	NATPortMappingRemoved
+)
+
+func (s NATPortMappingStatus) String() string {
+	switch s {
+	case NATPortMappingEstablished:
+		return "established"
+	case NATPortMappingRenewed:
+		return "renewed"
+	case NATPortMappingFailed:
+		return "failed"
+	case NATPortMappingRemoved:
+		return "removed"
+	default:
+		return "unknown"
+	}
+}
+
+// EvtNATPortMappingChanged is emitted when the status of a port mapping on the
+// NAT device (using UPnP or NAT-PMP) changes.
+//
+// Renewals that don't change the external port don't trigger an event.
+// Failures are only reported once, until the mapping is established again.
+type EvtNATPortMappingChanged struct {
+	// Protocol is either "tcp" or "udp".
+	Protocol string
+	// InternalPort is the port on the local device.
+	InternalPort int
+	// ExternalPort is the port on the NAT device. It is 0 if the mapping
+	// failed or was removed.
+	ExternalPort int
+	// Status is the new status of the mapping.
+	Status NATPortMappingStatus
+	// Expiry is the time when the lease of the mapping expires, unless renewed.
+	// It is the zero value if the mapping was created without a lease duration.
+	Expiry time.Time
+	// Error is the error returned by the NAT device, if Status is NATPortMappingFailed.
+	Error error
+}
+
+// EvtNATDeviceChanged is emitted when a NAT device (gateway) supporting port
+// mappings is discovered, when it changes (e.g. after switching networks),
+// and when it can't be found anymore.
+type EvtNATDeviceChanged struct {
+	// Found is false if no NAT device supporting port mappings could be found.
+	Found bool
+	// Type is the port mapping protocol used by the device, e.g. "NAT-PMP" or "UPNP (IG2)".
+	Type string
+	// DeviceAddr is the internal address of the NAT device.
+	DeviceAddr net.IP
+}
@@ -1,8 +1,11 @@
 package event
 
 import (
+	"time"
+
 	"github.com/AstaFrode/go-libp2p/core/network"
 	"github.com/AstaFrode/go-libp2p/core/peer"
+	"github.com/AstaFrode/go-libp2p/core/protocol"
 )
 
 // EvtPeerConnectednessChanged should be emitted every time the "connectedness" to a
@@ -53,3 +56,69 @@ type EvtPeerConnectednessChanged struct {
 	// Connectedness is the new connectedness state.
 	Connectedness network.Connectedness
 }
+
+// EvtStreamOpened is emitted when the protocol of a new stream has been negotiated.
+// Streams on which no protocol is negotiated, e.g. because the negotiation failed,
+// don't generate an EvtStreamOpened or EvtStreamClosed event.
+type EvtStreamOpened struct {
+	// Peer is the remote peer of the stream.
+	Peer peer.ID
+	// Protocol is the protocol negotiated on the stream.
+	Protocol protocol.ID
+	// Direction is the direction of the stream.
+	Direction network.Direction
+	// Stream is the stream that was opened.
+	Stream network.Stream
+}
+
+// EvtStreamClosed is emitted when a stream for which an EvtStreamOpened event was
+// emitted is closed or reset.
+type EvtStreamClosed struct {
+	// Peer is the remote peer of the stream.
+	Peer peer.ID
+	// Protocol is the protocol negotiated on the stream.
+	Protocol protocol.ID
+	// Direction is the direction of the stream.
+	Direction network.Direction
+	// Duration is the time between opening and closing the stream.
+	Duration time.Duration
+	// BytesRead and BytesWritten are the number of bytes read from and written to the stream.
+	BytesRead, BytesWritten int64
+	// Reset is true if the stream was reset rather than closed.
+	Reset bool
+}
+
+// EvtPeerConnectionUpgraded is emitted when a direct connection to a peer is established,
+// while we're connected to that peer only via transient connections, e.g. through a relay.
+//
+// Streams stay on the connection they were opened on. Applications can use this event
+// to move their streams from the transient connections to the direct connection.
+// See the StreamMigrationHandler of the basic host for automatic migration of streams.
+type EvtPeerConnectionUpgraded struct {
+	// Peer is the remote peer we established a direct connection to.
+	Peer peer.ID
+	// Direct is the newly established direct connection.
+	Direct network.Conn
+}
+
+// EvtConnectionDead is emitted when the health checker of the host closes a connection
+// because the remote peer didn't respond to liveness checks.
+type EvtConnectionDead struct {
+	// Peer is the remote peer of the connection.
+	Peer peer.ID
+	// Conn is the connection that was closed.
+	Conn network.Conn
+	// Error is the error of the last failed check.
+	Error error
+}
+
+// EvtConnectionIdleClosed is emitted when the host closes a connection because it had
+// no open streams for longer than the idle timeout.
+type EvtConnectionIdleClosed struct {
+	// Peer is the remote peer of the connection.
+	Peer peer.ID
+	// Conn is the connection that was closed.
+	Conn network.Conn
+	// IdleFor is the time the connection was idle.
+	IdleFor time.Duration
+}
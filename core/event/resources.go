@@ -0,0 +1,15 @@
+package event
+
+// EvtResourceLimitsAdjusted is emitted by the resource watchdog of the host when it tightens
+// the limits of the resource manager because the process is running out of file descriptors
+// or memory, and when it restores them once the pressure subsided.
+type EvtResourceLimitsAdjusted struct {
+	// Tightened is true if the limits were tightened, and false if they were restored.
+	Tightened bool
+	// FDs is the number of file descriptors used by the process, and FDLimit the maximum
+	// number of file descriptors the process may use. They are 0 if unknown.
+	FDs, FDLimit int
+	// Memory is the memory used by the process, and MemoryLimit the limit the watchdog
+	// compares it to. They are 0 if unknown.
+	Memory, MemoryLimit int64
+}
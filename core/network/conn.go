@@ -3,6 +3,7 @@ package network
 import (
 	"context"
 	"io"
+	"time"
 
 	ic "github.com/AstaFrode/go-libp2p/core/crypto"
 	"github.com/AstaFrode/go-libp2p/core/peer"
@@ -45,6 +46,16 @@ type ConnectionState struct {
 	Transport string
 	// indicates whether StreamMultiplexer was selected using inlined muxer negotiation
 	UsedEarlyMuxerNegotiation bool
+	// The cipher suite negotiated by the security protocol (if known).
+	// For example: TLS_CHACHA20_POLY1305_SHA256
+	CipherSuite string
+	// indicates whether the handshake resumed a previous session (TLS session resumption or QUIC 0-RTT)
+	Resumed bool
+	// the time it took to secure the connection. Zero if unknown.
+	HandshakeDuration time.Duration
+	// Metadata holds application-defined key / value pairs attached to the connection
+	// during the connection upgrade (see the upgrader's Interceptor). It must not be modified.
+	Metadata map[string]string
 }
 
 // ConnSecurity is the interface that one can mix into a connection interface to
@@ -84,6 +95,14 @@ type ConnStat interface {
 	Stat() ConnStats
 }
 
+// ConnIdentifyOverrides is an interface mixin for connections that override the user agent
+// or the protocol version announced via identify, see WithUserAgent and WithProtocolVersion.
+type ConnIdentifyOverrides interface {
+	// IdentifyOverrides returns the user agent and the protocol version to announce on
+	// this connection. Empty values mean that the host-wide values are used.
+	IdentifyOverrides() (userAgent, protocolVersion string)
+}
+
 // ConnScoper is the interface that one can mix into a connection interface to give it a resource
 // management scope
 type ConnScoper interface {
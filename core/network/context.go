@@ -13,11 +13,17 @@ var DialPeerTimeout = 60 * time.Second
 type noDialCtxKey struct{}
 type dialPeerTimeoutCtxKey struct{}
 type forceDirectDialCtxKey struct{}
+type forceRelayDialCtxKey struct{}
+type dialTransportsCtxKey struct{}
+type dialAddrLimitCtxKey struct{}
 type useTransientCtxKey struct{}
 type simConnectCtxKey struct{ isClient bool }
+type userAgentCtxKey struct{}
+type protocolVersionCtxKey struct{}
 
 var noDial = noDialCtxKey{}
 var forceDirectDial = forceDirectDialCtxKey{}
+var forceRelayDial = forceRelayDialCtxKey{}
 var useTransient = useTransientCtxKey{}
 var simConnectIsServer = simConnectCtxKey{}
 var simConnectIsClient = simConnectCtxKey{isClient: true}
@@ -40,6 +46,82 @@ func GetForceDirectDial(ctx context.Context) (forceDirect bool, reason string) {
 	return false, ""
 }
 
+// EXPERIMENTAL
+// WithForceRelayDial constructs a new context with an option that instructs the network
+// to only use relayed connections to a peer: existing direct connections are ignored,
+// and only relay addresses are dialed. Transient connections are acceptable for
+// opening streams when this option is set.
+func WithForceRelayDial(ctx context.Context, reason string) context.Context {
+	return context.WithValue(ctx, forceRelayDial, reason)
+}
+
+// EXPERIMENTAL
+// GetForceRelayDial returns true if the force relay dial option is set in the context.
+func GetForceRelayDial(ctx context.Context) (forceRelay bool, reason string) {
+	v := ctx.Value(forceRelayDial)
+	if v != nil {
+		return true, v.(string)
+	}
+
+	return false, ""
+}
+
+// EXPERIMENTAL
+// WithDialTransports constructs a new context with an option that restricts the connections
+// used and dialed to addresses containing one of the given multiaddr protocol codes,
+// e.g. ma.P_TCP or ma.P_QUIC_V1.
+func WithDialTransports(ctx context.Context, protocols ...int) context.Context {
+	return context.WithValue(ctx, dialTransportsCtxKey{}, protocols)
+}
+
+// EXPERIMENTAL
+// GetDialTransports returns the multiaddr protocol codes set with WithDialTransports,
+// or nil if all transports can be used.
+func GetDialTransports(ctx context.Context) []int {
+	protocols, _ := ctx.Value(dialTransportsCtxKey{}).([]int)
+	return protocols
+}
+
+// EXPERIMENTAL
+// WithDialAddrLimit constructs a new context with an option that limits the number of
+// addresses dialed when connecting to a peer. The highest ranked addresses are dialed.
+func WithDialAddrLimit(ctx context.Context, limit int) context.Context {
+	return context.WithValue(ctx, dialAddrLimitCtxKey{}, limit)
+}
+
+// EXPERIMENTAL
+// GetDialAddrLimit returns the limit set with WithDialAddrLimit, or 0 if there's no limit.
+func GetDialAddrLimit(ctx context.Context) int {
+	limit, _ := ctx.Value(dialAddrLimitCtxKey{}).(int)
+	return limit
+}
+
+// WithUserAgent constructs a new context with an option that overrides the user agent
+// announced via identify on the connection established by the dial, e.g. to signal a
+// different role to the remote peer. It has no effect on existing connections.
+func WithUserAgent(ctx context.Context, userAgent string) context.Context {
+	return context.WithValue(ctx, userAgentCtxKey{}, userAgent)
+}
+
+// GetUserAgent returns the user agent set with WithUserAgent, or "" if it's not set.
+func GetUserAgent(ctx context.Context) string {
+	userAgent, _ := ctx.Value(userAgentCtxKey{}).(string)
+	return userAgent
+}
+
+// WithProtocolVersion constructs a new context with an option that overrides the protocol
+// version announced via identify on the connection established by the dial.
+// It has no effect on existing connections.
+func WithProtocolVersion(ctx context.Context, protocolVersion string) context.Context {
+	return context.WithValue(ctx, protocolVersionCtxKey{}, protocolVersion)
+}
+
+// GetProtocolVersion returns the protocol version set with WithProtocolVersion, or "" if it's not set.
+func GetProtocolVersion(ctx context.Context) string {
+	protocolVersion, _ := ctx.Value(protocolVersionCtxKey{}).(string)
+	return protocolVersion
+}
+
 // WithSimultaneousConnect constructs a new context with an option that instructs the transport
 // to apply hole punching logic where applicable.
 // EXPERIMENTAL
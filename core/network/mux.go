@@ -3,6 +3,7 @@ package network
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"net"
 	"time"
@@ -11,6 +12,74 @@ import (
 // ErrReset is returned when reading or writing on a reset stream.
 var ErrReset = errors.New("stream reset")
 
+// StreamErrorCode is an application-defined error code, sent to the remote peer
+// when resetting a stream using ResetWithError. The code 0 is used by Reset.
+type StreamErrorCode uint32
+
+// StreamError is returned when reading or writing on a stream that was reset with a
+// non-zero error code. It wraps ErrReset, so errors.Is(err, ErrReset) holds for it.
+// Streams reset using Reset (or with the error code 0) return ErrReset itself.
+//
+// Not all stream multiplexers can transmit error codes (see StreamErrorResetter).
+// Streams reset by the remote peer using such a multiplexer return ErrReset.
+type StreamError struct {
+	ErrorCode StreamErrorCode
+	// Remote is true if the stream was reset by the remote peer.
+	Remote bool
+}
+
+func (e *StreamError) Error() string {
+	side := "local"
+	if e.Remote {
+		side = "remote"
+	}
+	return fmt.Sprintf("stream reset (%s): code: %d", side, e.ErrorCode)
+}
+
+func (e *StreamError) Is(target error) bool {
+	if tse, ok := target.(*StreamError); ok {
+		return tse.ErrorCode == e.ErrorCode && tse.Remote == e.Remote
+	}
+	return false
+}
+
+func (e *StreamError) Unwrap() error {
+	return ErrReset
+}
+
+// ConnErrorCode is an application-defined error code, sent to the remote peer
+// when closing a connection using CloseWithError.
+type ConnErrorCode uint32
+
+// ConnError is returned when opening, accepting, reading or writing streams on a
+// connection that was closed with an error code, if the transport supports
+// transmitting it (see ConnErrorCloser).
+type ConnError struct {
+	ErrorCode ConnErrorCode
+	// Reason is the human-readable reason for closing the connection, if any.
+	Reason string
+	// Remote is true if the connection was closed by the remote peer.
+	Remote bool
+}
+
+func (e *ConnError) Error() string {
+	side := "local"
+	if e.Remote {
+		side = "remote"
+	}
+	if e.Reason == "" {
+		return fmt.Sprintf("connection closed (%s): code: %d", side, e.ErrorCode)
+	}
+	return fmt.Sprintf("connection closed (%s): code: %d, reason: %s", side, e.ErrorCode, e.Reason)
+}
+
+func (e *ConnError) Is(target error) bool {
+	if tce, ok := target.(*ConnError); ok {
+		return tce.ErrorCode == e.ErrorCode && tce.Remote == e.Remote
+	}
+	return false
+}
+
 // MuxedStream is a bidirectional io pipe within a connection.
 type MuxedStream interface {
 	io.Reader
@@ -61,6 +130,47 @@ type MuxedStream interface {
 	SetWriteDeadline(time.Time) error
 }
 
+// StreamErrorResetter is implemented by the streams that can send an error code to
+// the remote peer when they are reset. The QUIC and WebTransport streams implement it,
+// yamux and mplex streams can't transmit error codes.
+type StreamErrorResetter interface {
+	// ResetWithError resets the stream like Reset, and sends errCode to the remote
+	// peer. Reads and writes on the remote end of the stream then fail with a
+	// *StreamError carrying errCode.
+	//
+	// WebTransport only supports codes up to 255: for larger codes, the stream is
+	// reset without the code, and an error is returned.
+	ResetWithError(errCode StreamErrorCode) error
+}
+
+// ResetWithError resets s, sending errCode to the remote peer if s implements
+// StreamErrorResetter. Otherwise, s is reset without the error code.
+func ResetWithError(s MuxedStream, errCode StreamErrorCode) error {
+	if r, ok := s.(StreamErrorResetter); ok {
+		return r.ResetWithError(errCode)
+	}
+	return s.Reset()
+}
+
+// ConnErrorCloser is implemented by the connections that can send an error code and
+// a reason to the remote peer when they are closed. The QUIC and WebTransport
+// connections implement it, yamux and mplex connections can't transmit them.
+type ConnErrorCloser interface {
+	// CloseWithError closes the connection like Close, and sends errCode and reason
+	// to the remote peer. Pending and future operations on the remote end of the
+	// connection then fail with a *ConnError.
+	CloseWithError(errCode ConnErrorCode, reason string) error
+}
+
+// CloseWithError closes c, sending errCode and reason to the remote peer if c
+// implements ConnErrorCloser. Otherwise, c is closed without them.
+func CloseWithError(c io.Closer, errCode ConnErrorCode, reason string) error {
+	if cc, ok := c.(ConnErrorCloser); ok {
+		return cc.CloseWithError(errCode, reason)
+	}
+	return c.Close()
+}
+
 // MuxedConn represents a connection to a remote peer that has been
 // extended to support stream multiplexing.
 //
@@ -12,6 +12,7 @@ import (
 
 	"github.com/AstaFrode/go-libp2p/core/peer"
 	"github.com/AstaFrode/go-libp2p/core/peerstore"
+	"github.com/AstaFrode/go-libp2p/core/protocol"
 
 	ma "github.com/multiformats/go-multiaddr"
 )
@@ -115,6 +116,30 @@ type Stats struct {
 	Extra map[interface{}]interface{}
 }
 
+// StreamStats are the statistics of the streams of a peer or of a protocol.
+type StreamStats struct {
+	// Streams is the number of open streams.
+	Streams int
+	// BytesIn and BytesOut are the number of bytes read from and written to the streams,
+	// including the streams that were closed.
+	BytesIn, BytesOut int64
+}
+
+// NetworkStats are the statistics of the streams of a network, grouped by peer and by protocol.
+type NetworkStats struct {
+	// Peers are the statistics of the connected peers.
+	Peers map[peer.ID]StreamStats
+	// Protocols are the statistics of the protocols negotiated on streams.
+	// Bytes exchanged before a protocol was set on a stream are only accounted for the peer.
+	Protocols map[protocol.ID]StreamStats
+}
+
+// NetworkStatsReporter is implemented by networks that account for the streams they carry.
+type NetworkStatsReporter interface {
+	// NetworkStats returns a snapshot of the statistics of the streams.
+	NetworkStats() NetworkStats
+}
+
 // StreamHandler is the type of function used to listen for
 // streams opened by the remote side.
 type StreamHandler func(Stream)
@@ -1,6 +1,8 @@
 package network
 
 import (
+	"context"
+
 	"github.com/AstaFrode/go-libp2p/core/protocol"
 )
 
@@ -28,3 +30,13 @@ type Stream interface {
 	// Scope returns the user's view of this stream's resource scope
 	Scope() StreamScope
 }
+
+// StreamContext returns the context associated with the stream, if the
+// stream implementation provides one (e.g. carrying the trace span of the
+// stream). Otherwise, it returns context.Background().
+func StreamContext(s Stream) context.Context {
+	if sc, ok := s.(interface{ Context() context.Context }); ok {
+		return sc.Context()
+	}
+	return context.Background()
+}
@@ -0,0 +1,154 @@
+package peer
+
+import (
+	"errors"
+	"fmt"
+
+	ic "github.com/AstaFrode/go-libp2p/core/crypto"
+	"github.com/AstaFrode/go-libp2p/core/internal/catch"
+	"github.com/AstaFrode/go-libp2p/core/peer/pb"
+	"github.com/AstaFrode/go-libp2p/core/record"
+
+	"google.golang.org/protobuf/proto"
+)
+
+//go:generate protoc --proto_path=$PWD:$PWD/../.. --go_out=. --go_opt=Mpb/key_rotation_record.proto=./pb pb/key_rotation_record.proto
+
+var _ record.Record = (*KeyRotationRecord)(nil)
+
+func init() {
+	record.RegisterType(&KeyRotationRecord{})
+}
+
+// KeyRotationRecordEnvelopeDomain is the domain string used for key rotation records contained in a Envelope.
+const KeyRotationRecordEnvelopeDomain = "libp2p-key-rotation-record"
+
+// KeyRotationRecordEnvelopePayloadType is the type hint used to identify key rotation records in a Envelope.
+// There's no multicodec assigned to key rotation records (yet), so we use a string.
+var KeyRotationRecordEnvelopePayloadType = []byte("/libp2p/key-rotation-record")
+
+// ErrKeyRotationSignerMismatch is returned when a key rotation record is not signed by
+// the key of the peer it rotates away from.
+var ErrKeyRotationSignerMismatch = errors.New("key rotation record not signed by the old peer ID")
+
+// KeyRotationRecord announces that a peer rotated its identity key: the peer
+// previously known as OldID is now known as NewID.
+//
+// A KeyRotationRecord must be signed with the private key of OldID, which proves that
+// the owner of the old identity authorized the rotation:
+//
+//	rec := &peer.KeyRotationRecord{OldID: oldID, NewID: newID, Seq: peer.TimestampSeq()}
+//	envelope, err := rec.Sign(oldPrivateKey)
+//
+// When the envelope is received from a peer that authenticated as NewID (as is the
+// case in the identify protocol), this also proves possession of the new key.
+//
+// KeyRotationRecords are ordered in time by their Seq field. If a peer rotates its key
+// more than once, every rotation is described by its own record, and the records form
+// a chain from the original identity to the current one.
+type KeyRotationRecord struct {
+	// OldID is the peer ID that was rotated away from.
+	OldID ID
+
+	// NewID is the peer ID that replaces OldID.
+	NewID ID
+
+	// Seq is a monotonically-increasing sequence counter that's used to order
+	// KeyRotationRecords for the same OldID in time.
+	Seq uint64
+}
+
+// Sign wraps the record in a record.Envelope signed with oldKey, which must be
+// the private key of OldID.
+func (r *KeyRotationRecord) Sign(oldKey ic.PrivKey) (*record.Envelope, error) {
+	signer, err := IDFromPrivateKey(oldKey)
+	if err != nil {
+		return nil, err
+	}
+	if signer != r.OldID {
+		return nil, ErrKeyRotationSignerMismatch
+	}
+	return record.Seal(r, oldKey)
+}
+
+// KeyRotationRecordFromEnvelope returns the KeyRotationRecord contained in the envelope,
+// after checking that the envelope was signed by the record's OldID.
+func KeyRotationRecordFromEnvelope(envelope *record.Envelope) (*KeyRotationRecord, error) {
+	r, err := envelope.Record()
+	if err != nil {
+		return nil, err
+	}
+	rec, ok := r.(*KeyRotationRecord)
+	if !ok {
+		return nil, fmt.Errorf("envelope does not contain a key rotation record, got %T", r)
+	}
+	signer, err := IDFromPublicKey(envelope.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	if signer != rec.OldID {
+		return nil, ErrKeyRotationSignerMismatch
+	}
+	if rec.OldID == rec.NewID {
+		return nil, errors.New("key rotation record rotates to the same peer ID")
+	}
+	return rec, nil
+}
+
+// Domain is used when signing and validating KeyRotationRecords contained in Envelopes.
+// It is constant for all KeyRotationRecord instances.
+func (r *KeyRotationRecord) Domain() string {
+	return KeyRotationRecordEnvelopeDomain
+}
+
+// Codec is a binary identifier for the KeyRotationRecord type. It is constant for all KeyRotationRecord instances.
+func (r *KeyRotationRecord) Codec() []byte {
+	return KeyRotationRecordEnvelopePayloadType
+}
+
+// UnmarshalRecord parses a KeyRotationRecord from a byte slice.
+// This method is called automatically when consuming a record.Envelope
+// whose PayloadType indicates that it contains a KeyRotationRecord.
+func (r *KeyRotationRecord) UnmarshalRecord(bytes []byte) (err error) {
+	if r == nil {
+		return fmt.Errorf("cannot unmarshal KeyRotationRecord to nil receiver")
+	}
+
+	defer func() { catch.HandlePanic(recover(), &err, "libp2p key rotation record unmarshal") }()
+
+	var msg pb.KeyRotationRecord
+	if err := proto.Unmarshal(bytes, &msg); err != nil {
+		return err
+	}
+
+	var oldID, newID ID
+	if err := oldID.UnmarshalBinary(msg.OldPeerId); err != nil {
+		return err
+	}
+	if err := newID.UnmarshalBinary(msg.NewPeerId); err != nil {
+		return err
+	}
+	*r = KeyRotationRecord{OldID: oldID, NewID: newID, Seq: msg.Seq}
+	return nil
+}
+
+// MarshalRecord serializes a KeyRotationRecord to a byte slice.
+// This method is called automatically when constructing a routing.Envelope
+// using Seal or KeyRotationRecord.Sign.
+func (r *KeyRotationRecord) MarshalRecord() (res []byte, err error) {
+	defer func() { catch.HandlePanic(recover(), &err, "libp2p key rotation record marshal") }()
+
+	oldID, err := r.OldID.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	newID, err := r.NewID.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return proto.Marshal(&pb.KeyRotationRecord{
+		OldPeerId: oldID,
+		NewPeerId: newID,
+		Seq:       r.Seq,
+	})
+}
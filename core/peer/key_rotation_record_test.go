@@ -0,0 +1,55 @@
+package peer_test
+
+import (
+	"testing"
+
+	"github.com/AstaFrode/go-libp2p/core/crypto"
+	. "github.com/AstaFrode/go-libp2p/core/peer"
+	"github.com/AstaFrode/go-libp2p/core/record"
+	"github.com/AstaFrode/go-libp2p/core/test"
+
+	"github.com/stretchr/testify/require"
+)
+
+func genKeyRotation(t *testing.T) (oldKey, newKey crypto.PrivKey, rec *KeyRotationRecord) {
+	t.Helper()
+	oldKey, _, err := test.RandTestKeyPair(crypto.Ed25519, 256)
+	require.NoError(t, err)
+	newKey, _, err = test.RandTestKeyPair(crypto.Ed25519, 256)
+	require.NoError(t, err)
+	oldID, err := IDFromPrivateKey(oldKey)
+	require.NoError(t, err)
+	newID, err := IDFromPrivateKey(newKey)
+	require.NoError(t, err)
+	return oldKey, newKey, &KeyRotationRecord{OldID: oldID, NewID: newID, Seq: TimestampSeq()}
+}
+
+func TestKeyRotationRecord(t *testing.T) {
+	oldKey, _, rec := genKeyRotation(t)
+
+	env, err := rec.Sign(oldKey)
+	require.NoError(t, err)
+	b, err := env.Marshal()
+	require.NoError(t, err)
+
+	env2, untyped, err := record.ConsumeEnvelope(b, KeyRotationRecordEnvelopeDomain)
+	require.NoError(t, err)
+	require.Equal(t, rec, untyped)
+
+	rec2, err := KeyRotationRecordFromEnvelope(env2)
+	require.NoError(t, err)
+	require.Equal(t, rec, rec2)
+}
+
+func TestKeyRotationRecordSignedByWrongKey(t *testing.T) {
+	_, newKey, rec := genKeyRotation(t)
+
+	_, err := rec.Sign(newKey)
+	require.ErrorIs(t, err, ErrKeyRotationSignerMismatch)
+
+	// bypass the check in Sign
+	env, err := record.Seal(rec, newKey)
+	require.NoError(t, err)
+	_, err = KeyRotationRecordFromEnvelope(env)
+	require.ErrorIs(t, err, ErrKeyRotationSignerMismatch)
+}
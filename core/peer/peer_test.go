@@ -249,6 +249,30 @@ func TestPublicKeyExtraction(t *testing.T) {
 	}
 }
 
+func TestIDFromExtendedKeyTypes(t *testing.T) {
+	if err := ic.EnableExperimentalKeyTypes(1000, 1001); err != nil {
+		t.Fatal(err)
+	}
+	for _, typ := range []int{ic.Ed448, ic.BLS12381} {
+		sk, pk, err := ic.GenerateKeyPair(typ, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		id, err := IDFromPublicKey(pk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !id.MatchesPrivateKey(sk) {
+			t.Fatal("peer ID doesn't match the private key")
+		}
+
+		// Both key types are too large to be inlined, so the peer ID is a hash of the key.
+		if _, err := id.ExtractPublicKey(); err != ErrNoPublicKey {
+			t.Fatalf("expected ErrNoPublicKey, got %v", err)
+		}
+	}
+}
+
 func TestValidate(t *testing.T) {
 	// Empty peer ID invalidates
 	err := ID("").Validate()
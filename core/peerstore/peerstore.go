@@ -193,6 +193,115 @@ func GetCertifiedAddrBook(ab AddrBook) (cab CertifiedAddrBook, ok bool) {
 	return cab, ok
 }
 
+// KeyRotationBook tracks identity key rotations of peers, as announced by signed
+// KeyRotationRecords. It allows applications to resolve references to a peer that
+// has since rotated its identity key to the peer's current ID.
+type KeyRotationBook interface {
+	// ConsumeKeyRotationRecord stores a signed KeyRotationRecord.
+	//
+	// The envelope must contain a peer.KeyRotationRecord signed by the record's OldID,
+	// or an error is returned. If a record for the same OldID with a greater or equal
+	// sequence number has already been stored, the record is ignored, and the
+	// 'accepted' bool return value will be false.
+	// At most one record rotating to each NewID is kept: an accepted record replaces
+	// the previous record with the same NewID.
+	//
+	// Callers are responsible for checking that the new key is controlled by the
+	// record's NewID, e.g. by only accepting records from peers authenticated as NewID.
+	ConsumeKeyRotationRecord(s *record.Envelope) (accepted bool, err error)
+
+	// GetKeyRotationRecord returns the Envelope containing the KeyRotationRecord
+	// that rotates away from the given peer id, if one exists.
+	// Returns nil if no KeyRotationRecord exists for the peer.
+	GetKeyRotationRecord(p peer.ID) *record.Envelope
+
+	// ResolveRotatedPeer follows the chain of key rotations starting at p, and returns
+	// the peer's current ID. It returns p if the peer didn't rotate its key.
+	ResolveRotatedPeer(p peer.ID) peer.ID
+}
+
+// GetKeyRotationBook is a helper to "upcast" a Peerstore to a KeyRotationBook
+// by using type assertion. Returns (nil, false) if the Peerstore doesn't track
+// key rotations.
+func GetKeyRotationBook(ps Peerstore) (krb KeyRotationBook, ok bool) {
+	krb, ok = ps.(KeyRotationBook)
+	return krb, ok
+}
+
+// DialStats are the statistics of the dials to an address of a peer.
+type DialStats struct {
+	Successes int
+	Failures  int
+	// FailuresSinceSuccess is the number of failed dials since the last successful dial.
+	FailuresSinceSuccess int
+	// LastSuccess is the time of the last successful dial. It's zero if no dial succeeded.
+	LastSuccess time.Time
+	// MedianHandshake is the median time it took to establish a connection, among
+	// the recent successful dials.
+	MedianHandshake time.Duration
+}
+
+// SuccessRate returns the share of the dials that succeeded, or 0 if the address
+// was never dialed.
+func (s DialStats) SuccessRate() float64 {
+	if s.Successes+s.Failures == 0 {
+		return 0
+	}
+	return float64(s.Successes) / float64(s.Successes+s.Failures)
+}
+
+// DialHistoryBook records the outcome of the dials to the addresses of peers.
+// The swarm uses it to dial the addresses that worked in the past first.
+type DialHistoryBook interface {
+	// RecordDial records the outcome of a dial to an address of a peer. handshake is
+	// the time it took to establish the connection; it's ignored for failed dials.
+	RecordDial(p peer.ID, addr ma.Multiaddr, success bool, handshake time.Duration)
+
+	// DialStats returns the statistics of the dials to an address of a peer.
+	// They are zero if the address was never dialed.
+	DialStats(p peer.ID, addr ma.Multiaddr) DialStats
+}
+
+// GetDialHistoryBook is a helper to "upcast" a Peerstore to a DialHistoryBook
+// by using type assertion. Returns (nil, false) if the Peerstore doesn't record
+// dial outcomes.
+func GetDialHistoryBook(ps Peerstore) (dhb DialHistoryBook, ok bool) {
+	dhb, ok = ps.(DialHistoryBook)
+	return dhb, ok
+}
+
+// Sources of the peer score observations reported by libp2p.
+const (
+	ScoreSourceAutoNAT   = "autonat"
+	ScoreSourceRelay     = "relay"
+	ScoreSourceHolePunch = "holepunch"
+)
+
+// ScoreBook aggregates the observations about the quality of peers made by different
+// subsystems (e.g. AutoNAT, relays, hole punching or application protocols) into a
+// single score per peer, which the connection manager, the connection gater and the
+// dialer can consult.
+// Observations decay over time, such that the score reflects the recent behavior of a peer.
+type ScoreBook interface {
+	// RecordObservation records an observation about a peer, reported by source.
+	// Positive values reward the peer, negative values penalize it.
+	RecordObservation(p peer.ID, source string, value float64)
+
+	// Score returns the score of a peer, i.e. the sum of the decayed observations
+	// reported by all sources. It's 0 if there are no observations about the peer.
+	Score(p peer.ID) float64
+
+	// ScoresBySource returns the score of a peer broken down by source.
+	ScoresBySource(p peer.ID) map[string]float64
+}
+
+// GetScoreBook is a helper to "upcast" a Peerstore to a ScoreBook by using type
+// assertion. Returns (nil, false) if the Peerstore doesn't keep track of peer scores.
+func GetScoreBook(ps Peerstore) (sb ScoreBook, ok bool) {
+	sb, ok = ps.(ScoreBook)
+	return sb, ok
+}
+
 // KeyBook tracks the keys of Peers.
 type KeyBook interface {
 	// PubKey stores the public key of a peer.
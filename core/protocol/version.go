@@ -0,0 +1,87 @@
+package protocol
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is the semantic version of a versioned protocol, i.e. a protocol whose
+// ID ends with a version component, e.g. 1.2.0 for /myapp/req/1.2.0.
+//
+// Following semantic versioning, an implementation of a protocol version is
+// expected to be able to serve all previous versions with the same major version.
+type Version struct {
+	Major, Minor, Patch uint64
+}
+
+// ParseVersion parses a version of the form major[.minor[.patch]].
+// Missing components are 0.
+func ParseVersion(s string) (Version, error) {
+	parts := strings.Split(s, ".")
+	if len(parts) > 3 {
+		return Version{}, fmt.Errorf("invalid version: %s", s)
+	}
+	var comps [3]uint64
+	for i, part := range parts {
+		n, err := strconv.ParseUint(part, 10, 64)
+		if err != nil {
+			return Version{}, fmt.Errorf("invalid version: %s", s)
+		}
+		comps[i] = n
+	}
+	return Version{Major: comps[0], Minor: comps[1], Patch: comps[2]}, nil
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// Compare returns -1 if v is lower than o, 1 if v is higher than o, and 0 if they are equal.
+func (v Version) Compare(o Version) int {
+	switch {
+	case v.Major != o.Major:
+		return cmpUint(v.Major, o.Major)
+	case v.Minor != o.Minor:
+		return cmpUint(v.Minor, o.Minor)
+	default:
+		return cmpUint(v.Patch, o.Patch)
+	}
+}
+
+// Serves returns true if an implementation of version v can serve version o,
+// i.e. if they have the same major version, and o is not higher than v.
+func (v Version) Serves(o Version) bool {
+	return v.Major == o.Major && v.Compare(o) >= 0
+}
+
+func cmpUint(a, b uint64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// SplitVersion splits a versioned protocol ID into the base protocol ID and the version,
+// e.g. /myapp/req/1.2.0 into /myapp/req and 1.2.0.
+func SplitVersion(id ID) (ID, Version, error) {
+	i := strings.LastIndexByte(string(id), '/')
+	if i <= 0 {
+		return "", Version{}, fmt.Errorf("protocol %s is not versioned", id)
+	}
+	v, err := ParseVersion(string(id[i+1:]))
+	if err != nil {
+		return "", Version{}, fmt.Errorf("protocol %s is not versioned: %w", id, err)
+	}
+	return id[:i], v, nil
+}
+
+// WithVersion returns the ID of version v of the base protocol, e.g. /myapp/req/1.2.0
+// for /myapp/req and 1.2.0.
+func WithVersion(base ID, v Version) ID {
+	return base + "/" + ID(v.String())
+}
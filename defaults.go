@@ -111,6 +111,7 @@ var DefaultResourceManager = func(cfg *Config) error {
 	if err != nil {
 		return err
 	}
+	cfg.AddCloser(mgr)
 
 	return cfg.Apply(ResourceManager(mgr))
 }
@@ -121,6 +122,7 @@ var DefaultConnectionManager = func(cfg *Config) error {
 	if err != nil {
 		return err
 	}
+	cfg.AddCloser(mgr)
 
 	return cfg.Apply(ConnectionManager(mgr))
 }
@@ -139,10 +141,7 @@ var DefaultPrometheusRegisterer = func(cfg *Config) error {
 //
 // Please *DON'T* specify default options any other way. Putting this all here
 // makes tracking defaults *much* easier.
-var defaults = []struct {
-	fallback func(cfg *Config) bool
-	opt      Option
-}{
+var defaults = []fallbackOption{
 	{
 		fallback: func(cfg *Config) bool { return cfg.Transports == nil && cfg.ListenAddrs == nil },
 		opt:      DefaultListenAddrs,
@@ -207,8 +206,11 @@ var Defaults Option = func(cfg *Config) error {
 // FallbackDefaults applies default options to the libp2p node if and only if no
 // other relevant options have been applied. will be appended to the options
 // passed into New.
+// The defaults of the profile, if one was selected, take precedence over the
+// other defaults.
 var FallbackDefaults Option = func(cfg *Config) error {
-	for _, def := range defaults {
+	fallbacks := append(append([]fallbackOption{}, profiles[cfg.Profile]...), defaults...)
+	for _, def := range fallbacks {
 		if !def.fallback(cfg) {
 			continue
 		}
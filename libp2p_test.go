@@ -2,24 +2,43 @@ package libp2p
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/x509"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"regexp"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/AstaFrode/go-libp2p/core/connmgr"
 	"github.com/AstaFrode/go-libp2p/core/crypto"
 	"github.com/AstaFrode/go-libp2p/core/host"
+	"github.com/AstaFrode/go-libp2p/core/network"
 	"github.com/AstaFrode/go-libp2p/core/peer"
+	"github.com/AstaFrode/go-libp2p/core/peerstore"
+	"github.com/AstaFrode/go-libp2p/core/protocol"
 	"github.com/AstaFrode/go-libp2p/core/transport"
+	"github.com/AstaFrode/go-libp2p/p2p/keystore"
+	"github.com/AstaFrode/go-libp2p/p2p/muxer/raw"
+	"github.com/AstaFrode/go-libp2p/p2p/net/conngater"
+	netconnmgr "github.com/AstaFrode/go-libp2p/p2p/net/connmgr"
 	"github.com/AstaFrode/go-libp2p/p2p/net/swarm"
 	"github.com/AstaFrode/go-libp2p/p2p/security/noise"
 	tls "github.com/AstaFrode/go-libp2p/p2p/security/tls"
+	"github.com/AstaFrode/go-libp2p/p2p/transport/memory"
 	quic "github.com/AstaFrode/go-libp2p/p2p/transport/quic"
+	"github.com/AstaFrode/go-libp2p/p2p/transport/quicreuse"
 	"github.com/AstaFrode/go-libp2p/p2p/transport/tcp"
 
 	ma "github.com/multiformats/go-multiaddr"
+	quicgo "github.com/quic-go/quic-go"
 	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func TestNewHost(t *testing.T) {
@@ -197,6 +216,85 @@ func TestTransportConstructorQUIC(t *testing.T) {
 	require.Contains(t, err.Error(), swarm.ErrNoTransport.Error())
 }
 
+func TestTransportConstructorQUICWithOpts(t *testing.T) {
+	h1, err := New(
+		Transport(quic.NewTransport, quic.WithIdentityOptions(tls.WithCertVerifier(func(*x509.Certificate, crypto.PubKey) error {
+			return errors.New("rejected")
+		}))),
+		ListenAddrStrings("/ip4/127.0.0.1/udp/0/quic-v1"),
+		DisableRelay(),
+	)
+	require.NoError(t, err)
+	defer h1.Close()
+	h2, err := New(
+		Transport(quic.NewTransport),
+		ListenAddrStrings("/ip4/127.0.0.1/udp/0/quic-v1"),
+		DisableRelay(),
+	)
+	require.NoError(t, err)
+	defer h2.Close()
+	require.Error(t, h1.Connect(context.Background(), peer.AddrInfo{ID: h2.ID(), Addrs: h2.Addrs()}))
+}
+
+func TestTransportConstructorMemory(t *testing.T) {
+	newHost := func() host.Host {
+		h, err := New(
+			Transport(memory.NewTransport),
+			ListenAddrStrings("/memory/0"),
+			DisableRelay(),
+		)
+		require.NoError(t, err)
+		t.Cleanup(func() { h.Close() })
+		return h
+	}
+	h1 := newHost()
+	h2 := newHost()
+	require.Len(t, h2.Addrs(), 1)
+	require.NoError(t, h1.Connect(context.Background(), peer.AddrInfo{ID: h2.ID(), Addrs: h2.Addrs()}))
+	h2.SetStreamHandler("/echo", func(s network.Stream) {
+		defer s.Close()
+		io.Copy(s, s)
+	})
+	s, err := h1.NewStream(context.Background(), h2.ID(), "/echo")
+	require.NoError(t, err)
+	defer s.Close()
+	_, err = s.Write([]byte("foobar"))
+	require.NoError(t, err)
+	b := make([]byte, 6)
+	_, err = io.ReadFull(s, b)
+	require.NoError(t, err)
+	require.Equal(t, "foobar", string(b))
+}
+
+func TestQUICStatelessResetKey(t *testing.T) {
+	newHost := func(t *testing.T, priv crypto.PrivKey, opts ...Option) quicgo.StatelessResetKey {
+		t.Helper()
+		var key quicgo.StatelessResetKey
+		h, err := New(append(opts,
+			Identity(priv),
+			QUICReuse(func(k quicgo.StatelessResetKey, opts ...quicreuse.Option) (*quicreuse.ConnManager, error) {
+				key = k
+				return quicreuse.NewConnManager(k, opts...)
+			}),
+			Transport(quic.NewTransport),
+			NoListenAddrs,
+			DisableRelay(),
+		)...)
+		require.NoError(t, err)
+		h.Close()
+		return key
+	}
+
+	priv, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	require.NoError(t, err)
+	// by default, the key is derived from the identity
+	require.Equal(t, newHost(t, priv), newHost(t, priv))
+
+	var key quicgo.StatelessResetKey
+	rand.Read(key[:])
+	require.Equal(t, key, newHost(t, priv, QUICStatelessResetKey(key)))
+}
+
 type mockTransport struct{}
 
 func (m mockTransport) Dial(context.Context, ma.Multiaddr, peer.ID) (transport.CapableConn, error) {
@@ -248,7 +346,7 @@ func TestTransportConstructorWithWrongOpts(t *testing.T) {
 		Transport(quic.NewTransport, tcp.DisableReuseport()),
 		DisableRelay(),
 	)
-	require.EqualError(t, err, "transport constructor doesn't take any options")
+	require.EqualError(t, err, "transport option of type tcp.Option not assignable to libp2pquic.Option")
 }
 
 func TestSecurityConstructor(t *testing.T) {
@@ -289,3 +387,233 @@ func TestSecurityConstructor(t *testing.T) {
 	require.Contains(t, err.Error(), "failed to negotiate security protocol")
 	require.NoError(t, h2.Connect(context.Background(), ai))
 }
+
+func TestTracerProvider(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+
+	h1, err := New(
+		ListenAddrStrings("/ip4/127.0.0.1/tcp/0"),
+		Transport(tcp.NewTCPTransport),
+		DisableRelay(),
+		TracerProvider(tp),
+	)
+	require.NoError(t, err)
+	defer h1.Close()
+	h2, err := New(
+		ListenAddrStrings("/ip4/127.0.0.1/tcp/0"),
+		Transport(tcp.NewTCPTransport),
+		DisableRelay(),
+	)
+	require.NoError(t, err)
+	defer h2.Close()
+
+	handlerSpan := make(chan trace.SpanContext, 1)
+	h1.SetStreamHandler("/test", func(s network.Stream) {
+		handlerSpan <- trace.SpanContextFromContext(network.StreamContext(s))
+		s.Close()
+	})
+
+	require.NoError(t, h1.Connect(context.Background(), peer.AddrInfo{ID: h2.ID(), Addrs: h2.Addrs()}))
+	h2.Peerstore().AddAddrs(h1.ID(), h1.Addrs(), time.Hour)
+	s, err := h2.NewStream(context.Background(), h1.ID(), "/test")
+	require.NoError(t, err)
+	defer s.Close()
+	_, err = s.Write([]byte("foobar"))
+	require.NoError(t, err)
+	select {
+	case sc := <-handlerSpan:
+		require.True(t, sc.IsValid())
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream handler not called")
+	}
+
+	names := make(map[string]bool)
+	for _, s := range sr.Ended() {
+		names[s.Name()] = true
+	}
+	for _, name := range []string{
+		"swarm.DialPeer",
+		"swarm.ResolveAddrs",
+		"swarm.DialAddr",
+		"upgrader.SecurityHandshake",
+		"upgrader.MuxerNegotiation",
+	} {
+		require.Contains(t, names, name)
+	}
+}
+
+func TestIdentityFromKeystore(t *testing.T) {
+	dir := t.TempDir()
+	h1, err := New(NoListenAddrs, IdentityFromKeystore(dir, "secret"))
+	require.NoError(t, err)
+	h1.Close()
+
+	// the key generated for the first host is reused
+	h2, err := New(NoListenAddrs, IdentityFromKeystore(dir, "secret"))
+	require.NoError(t, err)
+	defer h2.Close()
+	require.Equal(t, h1.ID(), h2.ID())
+
+	_, err = New(NoListenAddrs, IdentityFromKeystore(dir, "wrong"))
+	require.Error(t, err)
+}
+
+func TestIdentityFromKeystoreRotated(t *testing.T) {
+	dir := t.TempDir()
+	h1, err := New(NoListenAddrs, IdentityFromKeystore(dir, "secret"))
+	require.NoError(t, err)
+	h1.Close()
+
+	ks, err := keystore.Open(dir, []byte("secret"))
+	require.NoError(t, err)
+	sk, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	require.NoError(t, err)
+	require.NoError(t, ks.Rotate(keystore.HostKeyName, sk))
+
+	// the new host announces the rotation away from the previous key
+	h2, err := New(NoListenAddrs, IdentityFromKeystore(dir, "secret"))
+	require.NoError(t, err)
+	defer h2.Close()
+	require.NotEqual(t, h1.ID(), h2.ID())
+	krb, ok := peerstore.GetKeyRotationBook(h2.Peerstore())
+	require.True(t, ok)
+	require.Equal(t, h2.ID(), krb.ResolveRotatedPeer(h1.ID()))
+}
+
+func TestAddressFilters(t *testing.T) {
+	_, loopback, err := net.ParseCIDR("127.0.0.0/8")
+	require.NoError(t, err)
+	filters := conngater.NewAddressFilters(nil, []*net.IPNet{loopback})
+	gater, err := conngater.NewBasicConnectionGater(nil)
+	require.NoError(t, err)
+	h1, err := New(
+		Transport(tcp.NewTCPTransport),
+		ListenAddrStrings("/ip4/127.0.0.1/tcp/0"),
+		AddressFilters(filters),
+		ConnectionGater(gater),
+	)
+	require.NoError(t, err)
+	defer h1.Close()
+	h2, err := New(Transport(tcp.NewTCPTransport), ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+	require.NoError(t, err)
+	defer h2.Close()
+
+	require.Error(t, h1.Connect(context.Background(), peer.AddrInfo{ID: h2.ID(), Addrs: h2.Addrs()}))
+	require.Error(t, h2.Connect(context.Background(), peer.AddrInfo{ID: h1.ID(), Addrs: h1.Addrs()}))
+	stats := filters.Stats()
+	require.NotZero(t, stats.BlockedDials)
+	require.NotZero(t, stats.BlockedAccepts)
+
+	// the filters can be updated at runtime
+	filters.SetDenied(nil)
+	h2.Network().(*swarm.Swarm).Backoff().Clear(h1.ID())
+	h1.Network().(*swarm.Swarm).Backoff().Clear(h2.ID())
+	require.NoError(t, h1.Connect(context.Background(), peer.AddrInfo{ID: h2.ID(), Addrs: h2.Addrs()}))
+
+	// the filters apply to remote peers: an allow-only config keeps the host's own addresses
+	_, allowed, err := net.ParseCIDR("10.0.0.0/8")
+	require.NoError(t, err)
+	h3, err := New(
+		Transport(tcp.NewTCPTransport),
+		ListenAddrStrings("/ip4/127.0.0.1/tcp/0"),
+		AddressFilters(conngater.NewAddressFilters([]*net.IPNet{allowed}, nil)),
+	)
+	require.NoError(t, err)
+	defer h3.Close()
+	require.Len(t, h3.Addrs(), 1)
+	require.True(t, strings.HasPrefix(h3.Addrs()[0].String(), "/ip4/127.0.0.1/tcp/"))
+}
+
+func TestProfiles(t *testing.T) {
+	for _, profile := range []Option{ProfileServer(), ProfileEdge(), ProfileBrowserGateway(), ProfileMobile()} {
+		h, err := New(profile, ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+		require.NoError(t, err)
+		h.Close()
+	}
+
+	// options override the profile
+	cm, err := netconnmgr.NewConnManager(1, 2)
+	require.NoError(t, err)
+	h, err := New(ProfileMobile(), ConnectionManager(cm), ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+	require.NoError(t, err)
+	defer h.Close()
+	require.Equal(t, cm, h.ConnManager())
+
+	_, err = New(ProfileServer(), ProfileMobile())
+	require.EqualError(t, err, "cannot apply multiple profiles")
+
+	// features enabled by the profile can be disabled explicitly
+	var cfg Config
+	require.NoError(t, cfg.Apply(ProfileMobile(), DisableHolePunching(), DisableHealthCheck(), FallbackDefaults))
+	defer cfg.ConnManager.Close()
+	defer cfg.ResourceManager.Close()
+	require.False(t, cfg.EnableHolePunching)
+	require.False(t, cfg.EnableHealthCheck)
+	require.True(t, cfg.EnableAddrChangeMonitor)
+}
+
+type testCloser struct{ closed bool }
+
+func (c *testCloser) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestConfigClosesResourcesOnError(t *testing.T) {
+	var cfg Config
+	c := &testCloser{}
+	cfg.AddCloser(c)
+	require.Error(t, cfg.Apply(func(*Config) error { return errors.New("failed") }))
+	require.True(t, c.closed)
+
+	cfg = Config{}
+	c = &testCloser{}
+	cfg.AddCloser(c)
+	// fails, since there's no peerstore
+	_, err := cfg.NewNode()
+	require.Error(t, err)
+	require.True(t, c.closed)
+}
+
+func TestRawStreamMuxer(t *testing.T) {
+	newHost := func() host.Host {
+		h, err := New(
+			EnableRawStreamMuxer(),
+			Transport(tcp.NewTCPTransport),
+			ListenAddrStrings("/ip4/127.0.0.1/tcp/0"),
+		)
+		require.NoError(t, err)
+		t.Cleanup(func() { h.Close() })
+		return h
+	}
+	h1 := newHost()
+	h2 := newHost()
+	h2.SetStreamHandler("/echo", func(s network.Stream) {
+		defer s.Close()
+		io.Copy(s, s)
+	})
+
+	require.NoError(t, h1.Connect(context.Background(), peer.AddrInfo{ID: h2.ID(), Addrs: h2.Addrs()}))
+	conns := h1.Network().ConnsToPeer(h2.ID())
+	require.Len(t, conns, 1)
+	require.Equal(t, protocol.ID(raw.ID), conns[0].ConnState().StreamMultiplexer)
+
+	// identify doesn't take the stream of the connection
+	s, err := h1.NewStream(context.Background(), h2.ID(), "/echo")
+	require.NoError(t, err)
+	_, err = s.Write([]byte("foobar"))
+	require.NoError(t, err)
+	data := make([]byte, 6)
+	_, err = io.ReadFull(s, data)
+	require.NoError(t, err)
+	require.Equal(t, "foobar", string(data))
+	s.Close()
+}
+
+func TestRawStreamMuxerGuard(t *testing.T) {
+	_, err := New(Muxer(raw.ID, raw.DefaultTransport), NoListenAddrs)
+	require.EqualError(t, err, "the raw stream muxer can only be used with the EnableRawStreamMuxer option")
+	_, err = New(EnableRawStreamMuxer(), EnableHolePunching(), NoListenAddrs)
+	require.EqualError(t, err, "the raw stream muxer can't be used with hole punching")
+}
@@ -8,6 +8,7 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"net/netip"
 	"reflect"
 	"time"
 
@@ -21,16 +22,28 @@ import (
 	"github.com/AstaFrode/go-libp2p/core/pnet"
 	"github.com/AstaFrode/go-libp2p/core/protocol"
 	"github.com/AstaFrode/go-libp2p/core/transport"
+	"github.com/AstaFrode/go-libp2p/p2p/host/authz"
 	"github.com/AstaFrode/go-libp2p/p2p/host/autorelay"
 	bhost "github.com/AstaFrode/go-libp2p/p2p/host/basic"
+	"github.com/AstaFrode/go-libp2p/p2p/host/healthcheck"
+	"github.com/AstaFrode/go-libp2p/p2p/host/introspect"
+	"github.com/AstaFrode/go-libp2p/p2p/host/resource-manager/watchdog"
+	"github.com/AstaFrode/go-libp2p/p2p/keystore"
+	"github.com/AstaFrode/go-libp2p/p2p/muxer/raw"
+	"github.com/AstaFrode/go-libp2p/p2p/net/conngater"
+	"github.com/AstaFrode/go-libp2p/p2p/net/swarm"
 	tptu "github.com/AstaFrode/go-libp2p/p2p/net/upgrader"
 	relayv2 "github.com/AstaFrode/go-libp2p/p2p/protocol/circuitv2/relay"
 	"github.com/AstaFrode/go-libp2p/p2p/protocol/holepunch"
+	"github.com/AstaFrode/go-libp2p/p2p/protocol/peerexchange"
+	"github.com/AstaFrode/go-libp2p/p2p/protocol/ping"
 	"github.com/AstaFrode/go-libp2p/p2p/transport/quicreuse"
 	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/trace"
 
 	ma "github.com/multiformats/go-multiaddr"
 	madns "github.com/multiformats/go-multiaddr-dns"
+	"github.com/quic-go/quic-go"
 	"go.uber.org/fx"
 )
 
@@ -99,6 +112,26 @@ func Muxer(name string, muxer network.Multiplexer) Option {
 	}
 }
 
+// EnableRawStreamMuxer enables the experimental raw stream muxer (see package raw):
+// connections negotiating it carry a single stream, opened by the dialer, saving the
+// memory of a stream multiplexer and a round trip. It's only used with peers that
+// enabled it as well, and is preferred over the muxers configured using Muxer. If no
+// other muxer is configured, the host only supports the raw stream muxer.
+//
+// The host doesn't run identify on raw connections. Services that open streams on their
+// own (hole punching, AutoRelay, peer exchange, health checks, background pings and
+// stream migration) can't be used together with this option.
+func EnableRawStreamMuxer() Option {
+	return func(cfg *Config) error {
+		if cfg.EnableRawStreamMuxer {
+			return errors.New("raw stream muxer already enabled")
+		}
+		cfg.EnableRawStreamMuxer = true
+		cfg.Muxers = append([]tptu.StreamMuxer{{ID: raw.ID, Muxer: raw.DefaultTransport}}, cfg.Muxers...)
+		return nil
+	}
+}
+
 func QUICReuse(constructor interface{}, opts ...quicreuse.Option) Option {
 	return func(cfg *Config) error {
 		tag := `group:"quicreuseopts"`
@@ -126,6 +159,26 @@ func QUICReuse(constructor interface{}, opts ...quicreuse.Option) Option {
 	}
 }
 
+// QUICStatelessResetKey sets the key used to derive QUIC stateless reset tokens.
+//
+// Stateless resets allow a node that lost the state of a connection, e.g. because it
+// was restarted, to reset the connection, instead of the peer waiting for the idle
+// timeout. This only works if the node uses the same key across restarts.
+// By default, the key is derived from the node's private key, so this option is only
+// needed if the identity is not persisted, or the key should be managed independently.
+//
+// Note that the key used to generate address validation tokens is always generated
+// randomly by quic-go, so tokens issued before a restart are not accepted afterwards.
+func QUICStatelessResetKey(key quic.StatelessResetKey) Option {
+	return func(cfg *Config) error {
+		if cfg.QUICStatelessResetKey != nil {
+			return errors.New("QUIC stateless reset key already set")
+		}
+		cfg.QUICStatelessResetKey = &key
+		return nil
+	}
+}
+
 // Transport configures libp2p to use the given transport (or transport
 // constructor).
 //
@@ -242,6 +295,64 @@ func Identity(sk crypto.PrivKey) Option {
 	}
 }
 
+// IdentityFromKeystore configures libp2p to use the identity key stored in the
+// encrypted keystore at path, under the name keystore.HostKeyName.
+// If the keystore doesn't contain a host key yet, a new Ed25519 key is generated
+// and stored in the keystore.
+//
+// If the host key was rotated using keystore.Rotate, and the keystore still holds the
+// previous key, the host announces the rotation to its peers (see RotatedIdentity).
+func IdentityFromKeystore(path, passphrase string) Option {
+	return func(cfg *Config) error {
+		if cfg.PeerKey != nil {
+			return fmt.Errorf("cannot specify multiple identities")
+		}
+
+		ks, err := keystore.Open(path, []byte(passphrase))
+		if err != nil {
+			return err
+		}
+		sk, err := ks.Get(keystore.HostKeyName)
+		if errors.Is(err, keystore.ErrNoSuchKey) {
+			sk, _, err = crypto.GenerateEd25519Key(rand.Reader)
+			if err != nil {
+				return err
+			}
+			err = ks.Put(keystore.HostKeyName, sk)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to load identity from keystore: %w", err)
+		}
+		cfg.PeerKey = sk
+
+		if cfg.PreviousPeerKey == nil {
+			prev, err := ks.Get(keystore.HostKeyName + keystore.PreviousKeySuffix)
+			switch {
+			case err == nil:
+				cfg.PreviousPeerKey = prev
+			case !errors.Is(err, keystore.ErrNoSuchKey):
+				return fmt.Errorf("failed to load previous identity from keystore: %w", err)
+			}
+		}
+		return nil
+	}
+}
+
+// RotatedIdentity announces that this host rotated its identity key, and that
+// oldKey is the key it used before. The host signs a record linking the old peer
+// ID to the new one with oldKey, and sends it to peers via identify. Peers store
+// the record in their peerstore (see peerstore.KeyRotationBook), which allows
+// applications to migrate references from the old peer ID to the new one.
+func RotatedIdentity(oldKey crypto.PrivKey) Option {
+	return func(cfg *Config) error {
+		if cfg.PreviousPeerKey != nil {
+			return fmt.Errorf("cannot specify multiple previous identities")
+		}
+		cfg.PreviousPeerKey = oldKey
+		return nil
+	}
+}
+
 // ConnectionManager configures libp2p to use the given connection manager.
 //
 // The current "standard" connection manager lives in github.com/libp2p/go-libp2p-connmgr. See
@@ -267,6 +378,19 @@ func AddrsFactory(factory config.AddrsFactory) Option {
 	}
 }
 
+// AddrPolicy configures the policy controlling which addresses are advertised.
+// The policy is applied to the output of the address factory, and can be updated
+// at runtime using the SetAddrPolicy method of the host.
+func AddrPolicy(policy bhost.AddrPolicy) Option {
+	return func(cfg *Config) error {
+		if cfg.AddrPolicy != nil {
+			return fmt.Errorf("cannot specify multiple address policies")
+		}
+		cfg.AddrPolicy = &policy
+		return nil
+	}
+}
+
 // EnableRelay configures libp2p to enable the relay transport.
 // This option only configures libp2p to accept inbound connections from relays
 // and make outbound connections_through_ relays when requested by the remote peer.
@@ -294,11 +418,23 @@ func DisableRelay() Option {
 func EnableRelayService(opts ...relayv2.Option) Option {
 	return func(cfg *Config) error {
 		cfg.EnableRelayService = true
+		cfg.RelayServiceCustom = true
 		cfg.RelayServiceOpts = opts
 		return nil
 	}
 }
 
+// DisableRelayService configures libp2p not to run a circuit v2 relay,
+// even if the selected profile enables it.
+func DisableRelayService() Option {
+	return func(cfg *Config) error {
+		cfg.EnableRelayService = false
+		cfg.RelayServiceCustom = true
+		cfg.RelayServiceOpts = nil
+		return nil
+	}
+}
+
 // EnableAutoRelay configures libp2p to enable the AutoRelay subsystem.
 //
 // Dependencies:
@@ -370,6 +506,17 @@ func ForceReachabilityPrivate() Option {
 func EnableNATService() Option {
 	return func(cfg *Config) error {
 		cfg.AutoNATConfig.EnableService = true
+		cfg.NATServiceCustom = true
+		return nil
+	}
+}
+
+// DisableNATService configures libp2p not to provide the AutoNAT service to peers,
+// even if the selected profile enables it.
+func DisableNATService() Option {
+	return func(cfg *Config) error {
+		cfg.AutoNATConfig.EnableService = false
+		cfg.NATServiceCustom = true
 		return nil
 	}
 }
@@ -402,6 +549,38 @@ func ConnectionGater(cg connmgr.ConnectionGater) Option {
 	}
 }
 
+// AddressFilters filters the addresses the node dials and accepts connections from by
+// IP range, using the allowed and denied subnets of f. The subnets can be updated while
+// the node is running, and f counts the connection attempts it blocked.
+// The filters are applied before the connection gater, if one is configured.
+//
+// The filters only apply to remote addresses: they don't change the addresses the
+// node listens on and advertises.
+func AddressFilters(f *conngater.AddressFilters) Option {
+	return func(cfg *Config) error {
+		if cfg.AddressFilters != nil {
+			return errors.New("cannot configure multiple address filters")
+		}
+		cfg.AddressFilters = f
+		return nil
+	}
+}
+
+// ConnectionGaterV2 configures libp2p to use the given context-aware
+// ConnectionGaterV2. It can't be combined with ConnectionGater.
+//
+// Transports that don't support the ConnectionGaterV2 yet (e.g. QUIC) use it
+// through the connmgr.AsGater adapter.
+func ConnectionGaterV2(cg connmgr.ConnectionGaterV2) Option {
+	return func(cfg *Config) error {
+		if cfg.ConnectionGater != nil {
+			return errors.New("cannot configure multiple connection gaters, or cannot configure both Filters and ConnectionGater")
+		}
+		cfg.ConnectionGater = connmgr.AsGater(cg)
+		return nil
+	}
+}
+
 // ResourceManager configures libp2p to use the given ResourceManager.
 // When using the p2p/host/resource-manager implementation of the ResourceManager interface,
 // it is recommended to set limits for libp2p protocol by calling SetDefaultServiceLimits.
@@ -441,6 +620,19 @@ func Ping(enable bool) Option {
 	}
 }
 
+// BackgroundPing makes the ping service ping all connected peers every interval.
+// The RTTs are recorded in the peerstore's latency metrics, which are used e.g. to
+// select relays. Has no effect if the ping service is disabled.
+func BackgroundPing(interval time.Duration) Option {
+	return func(cfg *Config) error {
+		if interval <= 0 {
+			return errors.New("ping interval must be positive")
+		}
+		cfg.PingOptions = append(cfg.PingOptions, ping.WithBackgroundPing(interval))
+		return nil
+	}
+}
+
 // Routing will configure libp2p to use routing.
 func Routing(rt config.RoutingC) Option {
 	return func(cfg *Config) error {
@@ -493,6 +685,19 @@ func UserAgent(userAgent string) Option {
 	}
 }
 
+// WithLogger sets the structured logger used by the host, e.g. a *zap.SugaredLogger,
+// allowing to route the host's logs with the application's logs.
+// By default, the host logs to the "basichost" go-log logger.
+func WithLogger(l bhost.Logger) Option {
+	return func(cfg *Config) error {
+		if cfg.Logger != nil {
+			return errors.New("cannot specify multiple loggers")
+		}
+		cfg.Logger = l
+		return nil
+	}
+}
+
 // MultiaddrResolver sets the libp2p dns resolver
 func MultiaddrResolver(rslv *madns.Resolver) Option {
 	return func(cfg *Config) error {
@@ -501,6 +706,19 @@ func MultiaddrResolver(rslv *madns.Resolver) Option {
 	}
 }
 
+// EnableNAT64 allows dialing IPv4-only peers from IPv6-only networks.
+// When the node detects that it is on an IPv6-only network, it synthesizes
+// NAT64 addresses for public IPv4 addresses of the peer it is dialing.
+// If no prefixes are given, the NAT64 prefix is discovered by resolving
+// ipv4only.arpa (RFC 7050).
+func EnableNAT64(prefixes ...netip.Prefix) Option {
+	return func(cfg *Config) error {
+		cfg.EnableNAT64 = true
+		cfg.NAT64Prefixes = prefixes
+		return nil
+	}
+}
+
 // Experimental
 // EnableHolePunching enables NAT traversal by enabling NATT'd peers to both initiate and respond to hole punching attempts
 // to create direct/NAT-traversed connections with other peers. (default: disabled)
@@ -535,11 +753,163 @@ func MultiaddrResolver(rslv *madns.Resolver) Option {
 func EnableHolePunching(opts ...holepunch.Option) Option {
 	return func(cfg *Config) error {
 		cfg.EnableHolePunching = true
+		cfg.HolePunchingCustom = true
 		cfg.HolePunchingOptions = opts
 		return nil
 	}
 }
 
+// DisableHolePunching disables NAT traversal by hole punching,
+// even if the selected profile enables it.
+func DisableHolePunching() Option {
+	return func(cfg *Config) error {
+		cfg.EnableHolePunching = false
+		cfg.HolePunchingCustom = true
+		cfg.HolePunchingOptions = nil
+		return nil
+	}
+}
+
+// EnablePeerExchange enables the exchange of the signed peer records of recently seen peers
+// with connected peers that support the peer exchange protocol. (default: disabled)
+//
+// This helps bootstrapping connectivity in networks without a DHT. Only peer records signed
+// by the peers they describe are exchanged, so peers can't announce forged addresses.
+func EnablePeerExchange(opts ...peerexchange.Option) Option {
+	return func(cfg *Config) error {
+		cfg.EnablePeerExchange = true
+		cfg.PeerExchangeOptions = opts
+		return nil
+	}
+}
+
+// EnableHealthCheck enables the periodic liveness check of idle connections. (default: disabled)
+//
+// Connections without open streams are pinged at a regular interval. Connections on which the
+// remote peer stopped responding, e.g. because a NAT dropped its mapping, are closed, and an
+// event.EvtConnectionDead is emitted.
+func EnableHealthCheck(opts ...healthcheck.Option) Option {
+	return func(cfg *Config) error {
+		cfg.EnableHealthCheck = true
+		cfg.HealthCheckCustom = true
+		cfg.HealthCheckOptions = opts
+		return nil
+	}
+}
+
+// DisableHealthCheck disables the liveness check of idle connections,
+// even if the selected profile enables it.
+func DisableHealthCheck() Option {
+	return func(cfg *Config) error {
+		cfg.EnableHealthCheck = false
+		cfg.HealthCheckCustom = true
+		cfg.HealthCheckOptions = nil
+		return nil
+	}
+}
+
+// EnableResourceWatchdog makes the host monitor the file descriptors and the memory used by
+// the process. When they approach the limits of the process, the system limits of the resource
+// manager are tightened, and restored once the pressure subsided. (default: disabled)
+//
+// Every adjustment is announced by an event.EvtResourceLimitsAdjusted. Pass
+// watchdog.WithConnManager to also trim connections when tightening the limits.
+//
+// File descriptors are only monitored on Linux. On other platforms, the watchdog only reacts
+// to memory pressure, which requires a memory limit (GOMEMLIMIT or watchdog.WithMemoryLimit).
+func EnableResourceWatchdog(opts ...watchdog.Option) Option {
+	return func(cfg *Config) error {
+		cfg.EnableResourceWatchdog = true
+		cfg.ResourceWatchdogOptions = opts
+		return nil
+	}
+}
+
+// EnableAddrChangeMonitor makes the host watch the network interfaces of the machine,
+// using the operating system's change notifications where available. When interfaces
+// or their addresses change, e.g. when a laptop switches networks, the host updates its
+// addresses immediately: addresses of removed interfaces are withdrawn, addresses of new
+// interfaces are advertised, and connected peers are informed via identify push.
+func EnableAddrChangeMonitor() Option {
+	return func(cfg *Config) error {
+		cfg.EnableAddrChangeMonitor = true
+		cfg.AddrChangeMonitorCustom = true
+		return nil
+	}
+}
+
+// DisableAddrChangeMonitor disables watching the network interfaces of the machine,
+// even if the selected profile enables it.
+func DisableAddrChangeMonitor() Option {
+	return func(cfg *Config) error {
+		cfg.EnableAddrChangeMonitor = false
+		cfg.AddrChangeMonitorCustom = true
+		return nil
+	}
+}
+
+// AddrGracePeriod makes the host keep advertising addresses that it stopped using for
+// the given duration, so that peers that just learned them (e.g. peers in the middle of
+// a handshake) can still reach us. During the grace period, EvtLocalAddressesUpdated
+// events mark these addresses as Deprecated, and once it expires as Removed.
+func AddrGracePeriod(d time.Duration) Option {
+	return func(cfg *Config) error {
+		if d <= 0 {
+			return errors.New("address grace period needs to be positive")
+		}
+		cfg.AddrGracePeriod = d
+		return nil
+	}
+}
+
+// IdleConnTimeout makes the host close connections that had no open streams for the
+// given duration. Connections to peers protected in the connection manager are kept open.
+// An EvtConnectionIdleClosed event is emitted for every connection closed.
+func IdleConnTimeout(d time.Duration) Option {
+	return func(cfg *Config) error {
+		if d <= 0 {
+			return errors.New("idle connection timeout needs to be positive")
+		}
+		cfg.IdleConnTimeout = d
+		return nil
+	}
+}
+
+// TagPeersByProtocol makes the host tag peers in the connection manager depending on
+// the protocols they support, as learned via identify, so that the connection manager
+// preferentially keeps the peers that are relevant to the application.
+// A peer supporting a protocol is tagged with the Supported weight of that protocol.
+// Every stream opened with that protocol bumps a decaying tag by the Active weight,
+// which requires a connection manager supporting decaying tags.
+func TagPeersByProtocol(weights map[protocol.ID]bhost.ProtocolTagWeights) Option {
+	return func(cfg *Config) error {
+		if cfg.ProtocolTags != nil {
+			return errors.New("cannot specify multiple protocol tag weights")
+		}
+		for p, w := range weights {
+			if w.Supported < 0 || w.Active < 0 {
+				return fmt.Errorf("negative tag weight for protocol %s", p)
+			}
+		}
+		cfg.ProtocolTags = weights
+		return nil
+	}
+}
+
+// EnableStreamMigration makes the host migrate streams from transient connections
+// (e.g. relayed connections) to a direct connection to the same peer, as soon as one
+// is established, e.g. by hole punching.
+// Only outbound streams using protocols with a migration handler are migrated,
+// see basichost.BasicHost.SetStreamMigrationHandler.
+// Independently of this option, the host emits an event.EvtPeerConnectionUpgraded
+// when a direct connection replaces the transient connections to a peer.
+func EnableStreamMigration() Option {
+	return func(cfg *Config) error {
+		cfg.EnableStreamMigration = true
+		return nil
+	}
+}
+
 func WithDialTimeout(t time.Duration) Option {
 	return func(cfg *Config) error {
 		if t <= 0 {
@@ -550,6 +920,222 @@ func WithDialTimeout(t time.Duration) Option {
 	}
 }
 
+// DialTimeouts sets dial timeouts for specific transports and address scopes
+// (private, public or relayed addresses), e.g. a short timeout for QUIC dials on the
+// local network, and a long one for relayed dials:
+//
+//	libp2p.DialTimeouts(
+//		swarm.DialTimeout{Transport: ma.P_QUIC_V1, Scope: swarm.AddrScopePrivate, Timeout: time.Second},
+//		swarm.DialTimeout{Scope: swarm.AddrScopeRelay, Timeout: 30 * time.Second},
+//	)
+//
+// Addresses not matching any of the timeouts use the timeout set by WithDialTimeout.
+// See swarm.WithDialTimeouts for how the timeout for an address is selected.
+func DialTimeouts(timeouts ...swarm.DialTimeout) Option {
+	return func(cfg *Config) error {
+		cfg.DialTimeouts = append(cfg.DialTimeouts, timeouts...)
+		return nil
+	}
+}
+
+// DialConcurrency limits the number of concurrent outbound dials over transports
+// that consume file descriptors (e.g. TCP and WebSocket), across all peers.
+// Dials exceeding the limit wait for a slot to become available.
+func DialConcurrency(n int) Option {
+	return func(cfg *Config) error {
+		if n <= 0 {
+			return errors.New("dial concurrency needs to be positive")
+		}
+		cfg.DialConcurrency = n
+		return nil
+	}
+}
+
+// MaxDialsPerPeer limits the number of concurrent outbound dials to a single peer.
+func MaxDialsPerPeer(n int) Option {
+	return func(cfg *Config) error {
+		if n <= 0 {
+			return errors.New("max dials per peer needs to be positive")
+		}
+		cfg.MaxDialsPerPeer = n
+		return nil
+	}
+}
+
+// MaxDialAddrs limits the number of addresses attempted when dialing a peer.
+// Only the best ranked addresses are attempted.
+// Addresses dropped due to this limit are counted by the
+// libp2p_swarm_dials_capped_total metric.
+func MaxDialAddrs(n int) Option {
+	return func(cfg *Config) error {
+		if n <= 0 {
+			return errors.New("max dial addresses needs to be positive")
+		}
+		cfg.MaxDialAddrs = n
+		return nil
+	}
+}
+
+// SecurityHandshakeTimeout limits the duration of the security handshake
+// (including the negotiation of the security protocol) when upgrading a connection.
+func SecurityHandshakeTimeout(t time.Duration) Option {
+	return func(cfg *Config) error {
+		if t <= 0 {
+			return errors.New("security handshake timeout needs to be positive")
+		}
+		cfg.SecurityHandshakeTimeout = t
+		return nil
+	}
+}
+
+// MuxerNegotiationTimeout limits the duration of the stream multiplexer
+// negotiation when upgrading a connection.
+func MuxerNegotiationTimeout(t time.Duration) Option {
+	return func(cfg *Config) error {
+		if t <= 0 {
+			return errors.New("muxer negotiation timeout needs to be positive")
+		}
+		cfg.MuxerNegotiationTimeout = t
+		return nil
+	}
+}
+
+// FirstStreamNegotiationTimeout limits the duration of the protocol negotiation
+// of the first stream opened by the remote peer on an inbound connection.
+// Other streams use the default negotiation timeout.
+func FirstStreamNegotiationTimeout(t time.Duration) Option {
+	return func(cfg *Config) error {
+		if t <= 0 {
+			return errors.New("first stream negotiation timeout needs to be positive")
+		}
+		cfg.FirstStreamNegotiationTimeout = t
+		return nil
+	}
+}
+
+// StreamMiddleware adds middleware wrapping the handlers of incoming streams, e.g. to recover
+// from panics, log streams or check that the remote peer is authorized. Middleware runs in the
+// order it was added: the first middleware is the outermost one.
+// See basichost.RecoverMiddleware for a middleware recovering from panics in stream handlers,
+// which takes the logger set using WithLogger.
+func StreamMiddleware(mw ...bhost.StreamMiddleware) Option {
+	return func(cfg *Config) error {
+		cfg.StreamMiddleware = append(cfg.StreamMiddleware, mw...)
+		return nil
+	}
+}
+
+// Authorization authorizes inbound streams using policy p, after their protocol was negotiated.
+// Streams denied by the policy are reset. See the authz package for the available policies.
+func Authorization(p authz.Policy) Option {
+	return StreamMiddleware(authz.Middleware(p))
+}
+
+// UpgradeInterceptor adds an interceptor that is invoked at every step of
+// the upgrade of TCP and WebSocket connections (raw connection, security handshake
+// and muxer negotiation). Interceptors can abort the upgrade, and attach metadata
+// to the connection, which is available in its network.ConnectionState.
+func UpgradeInterceptor(i tptu.Interceptor) Option {
+	return func(cfg *Config) error {
+		if i == nil {
+			return errors.New("upgrade interceptor cannot be nil")
+		}
+		cfg.UpgradeInterceptors = append(cfg.UpgradeInterceptors, i)
+		return nil
+	}
+}
+
+// BandwidthThrottler limits the bandwidth of TCP and WebSocket connections, globally,
+// per peer and per connection. The limits can be updated at runtime using
+// Throttler.SetLimits, and the statistics are available using Throttler.Stats.
+// To export metrics, pass a MetricsTracer using tptu.WithThrottlerMetricsTracer.
+func BandwidthThrottler(t *tptu.Throttler) Option {
+	return func(cfg *Config) error {
+		if t == nil {
+			return errors.New("throttler cannot be nil")
+		}
+		if cfg.Throttler != nil {
+			return errors.New("cannot specify multiple throttlers")
+		}
+		cfg.Throttler = t
+		return nil
+	}
+}
+
+// InboundAdmission sets a function deciding whether inbound TCP and WebSocket connections
+// are upgraded, given the current load of the host. It can reject connections before the
+// security handshake, or queue them until a pending handshake completes.
+// QUIC and WebTransport connections are not covered: their handshakes are performed by
+// the QUIC stack, without going through the upgrader.
+func InboundAdmission(f tptu.AdmissionFunc) Option {
+	return func(cfg *Config) error {
+		if f == nil {
+			return errors.New("admission func cannot be nil")
+		}
+		if cfg.InboundAdmission != nil {
+			return errors.New("cannot specify multiple admission funcs")
+		}
+		cfg.InboundAdmission = f
+		return nil
+	}
+}
+
+// InboundLoadShedding makes the host reject inbound TCP and WebSocket connections as long
+// as maxPendingHandshakes inbound connections are being upgraded, or the resource manager
+// has no headroom for another inbound connection. This keeps floods of handshakes from
+// saturating the CPU.
+// QUIC and WebTransport connections are not covered: their handshakes are performed by
+// the QUIC stack, without going through the upgrader. They are only limited by the
+// resource manager.
+func InboundLoadShedding(maxPendingHandshakes int) Option {
+	return func(cfg *Config) error {
+		if maxPendingHandshakes <= 0 {
+			return errors.New("max pending handshakes needs to be positive")
+		}
+		cfg.MaxPendingInboundHandshakes = maxPendingHandshakes
+		return nil
+	}
+}
+
+// UDPBlackHoleFilter configures the detection of networks that drop UDP traffic.
+// The swarm tracks the results of the last n dials to public UDP addresses. If fewer than
+// minSuccesses of them succeeded, dials to UDP addresses are blocked, except for one in n
+// dials, which is used to probe if the situation changed.
+// The filter is disabled by default, enable it with UDPBlackHoleFilter(true, 100, 5).
+// Use Swarm.BlackHoleStatus to query the state, and subscribe to
+// event.EvtBlackHoleStateChanged to be notified when it changes.
+func UDPBlackHoleFilter(enabled bool, n, minSuccesses int) Option {
+	return func(cfg *Config) error {
+		cfg.UDPBlackHoleConfig = &swarm.BlackHoleConfig{Enabled: enabled, N: n, MinSuccesses: minSuccesses}
+		return nil
+	}
+}
+
+// IPv6BlackHoleFilter configures the detection of networks without IPv6 connectivity.
+// The filter is disabled by default. See UDPBlackHoleFilter for details.
+func IPv6BlackHoleFilter(enabled bool, n, minSuccesses int) Option {
+	return func(cfg *Config) error {
+		cfg.IPv6BlackHoleConfig = &swarm.BlackHoleConfig{Enabled: enabled, N: n, MinSuccesses: minSuccesses}
+		return nil
+	}
+}
+
+// ListenRetry makes libp2p retry listening on an address when its listener is closed
+// unexpectedly, e.g. when a network interface is removed, or after resuming from sleep.
+// Retries use an exponential backoff between minBackoff and maxBackoff.
+// Subscribe to event.EvtListenerClosed and event.EvtListenFailure to be notified of
+// listener failures.
+func ListenRetry(minBackoff, maxBackoff time.Duration) Option {
+	return func(cfg *Config) error {
+		if minBackoff <= 0 || maxBackoff < minBackoff {
+			return errors.New("invalid listen retry backoff")
+		}
+		cfg.ListenRetryMinBackoff = minBackoff
+		cfg.ListenRetryMaxBackoff = maxBackoff
+		return nil
+	}
+}
+
 // DisableMetrics configures libp2p to disable prometheus metrics
 func DisableMetrics() Option {
 	return func(cfg *Config) error {
@@ -558,6 +1144,19 @@ func DisableMetrics() Option {
 	}
 }
 
+// BandwidthMetricsByProtocol configures libp2p to break down the stream
+// bandwidth metrics by protocol ID, in addition to transport and direction.
+// Note that this increases the cardinality of the metrics.
+func BandwidthMetricsByProtocol() Option {
+	return func(cfg *Config) error {
+		if cfg.DisableMetrics {
+			return errors.New("cannot enable protocol metrics when metrics are disabled")
+		}
+		cfg.BandwidthMetricsByProtocol = true
+		return nil
+	}
+}
+
 // PrometheusRegisterer configures libp2p to use reg as the Registerer for all metrics subsystems
 func PrometheusRegisterer(reg prometheus.Registerer) Option {
 	return func(cfg *Config) error {
@@ -574,3 +1173,34 @@ func PrometheusRegisterer(reg prometheus.Registerer) Option {
 		return nil
 	}
 }
+
+// TracerProvider configures libp2p to record OpenTelemetry spans for address
+// resolution, dials, security handshakes, muxer negotiation and streams.
+// Stream handlers can access the span of a stream using network.StreamContext.
+func TracerProvider(tp trace.TracerProvider) Option {
+	return func(cfg *Config) error {
+		if tp == nil {
+			return errors.New("tracer provider cannot be nil")
+		}
+		cfg.TracerProvider = tp
+		return nil
+	}
+}
+
+// Introspection runs a local introspection server on the given TCP address
+// (e.g. "127.0.0.1:5001"), which exposes the live state of the host
+// (connections, streams, traffic and events) as JSON and over a WebSocket.
+// See the introspect package for details.
+func Introspection(listenAddr string, opts ...introspect.Option) Option {
+	return func(cfg *Config) error {
+		if cfg.IntrospectionAddr != "" {
+			return errors.New("introspection server already configured")
+		}
+		if listenAddr == "" {
+			return errors.New("introspection listen address cannot be empty")
+		}
+		cfg.IntrospectionAddr = listenAddr
+		cfg.IntrospectionOpts = append(cfg.IntrospectionOpts, opts...)
+		return nil
+	}
+}
@@ -0,0 +1,235 @@
+// Package dnsaddr implements peer discovery using DNS.
+//
+// Peers are published as dnsaddr TXT records, as described in
+// https://github.com/multiformats/multiaddr/blob/master/protocols/DNSADDR.md:
+//
+//	_dnsaddr.example.com. TXT "dnsaddr=/ip4/1.2.3.4/tcp/4001/p2p/12D3KooW..."
+//
+// TXT records can point to other dnsaddr records, which are resolved recursively.
+// This complements mDNS for server deployments where multicast is unavailable.
+package dnsaddr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/AstaFrode/go-libp2p/core/discovery"
+	"github.com/AstaFrode/go-libp2p/core/host"
+	"github.com/AstaFrode/go-libp2p/core/peer"
+
+	logging "github.com/ipfs/go-log/v2"
+	ma "github.com/multiformats/go-multiaddr"
+	madns "github.com/multiformats/go-multiaddr-dns"
+)
+
+var log = logging.Logger("dnsaddr")
+
+const (
+	defaultRefreshInterval = 5 * time.Minute
+	resolveTimeout         = 30 * time.Second
+	// maxResolutionDepth limits the number of nested dnsaddr records we follow.
+	maxResolutionDepth = 8
+)
+
+// Notifee is notified about peers found during a refresh.
+type Notifee interface {
+	HandlePeerFound(peer.AddrInfo)
+}
+
+// Option is an option for the DNS discovery service.
+type Option func(*Service) error
+
+// WithResolver sets the resolver used to look up the TXT records.
+// By default, madns.DefaultResolver is used.
+func WithResolver(r *madns.Resolver) Option {
+	return func(s *Service) error {
+		if r == nil {
+			return errors.New("resolver cannot be nil")
+		}
+		s.resolver = r
+		return nil
+	}
+}
+
+// WithRefreshInterval sets the interval at which the domains are resolved.
+// Defaults to 5 minutes.
+func WithRefreshInterval(d time.Duration) Option {
+	return func(s *Service) error {
+		if d <= 0 {
+			return errors.New("refresh interval must be positive")
+		}
+		s.refreshInterval = d
+		return nil
+	}
+}
+
+// Service periodically resolves the dnsaddr records of a list of domains, and
+// passes the peers found to the Notifee.
+//
+// It also implements discovery.Discoverer, interpreting the namespace passed
+// to FindPeers as a domain name.
+type Service struct {
+	host            host.Host
+	domains         []string
+	notifee         Notifee
+	resolver        *madns.Resolver
+	refreshInterval time.Duration
+
+	ctx       context.Context
+	ctxCancel context.CancelFunc
+	refCount  sync.WaitGroup
+}
+
+var (
+	_ discovery.Discoverer = &Service{}
+	_ io.Closer            = &Service{}
+)
+
+// NewService creates a new DNS discovery service. Call Start to start the
+// periodic resolution of the domains.
+// The host and the notifee can be nil if the Service is only used as a
+// discovery.Discoverer.
+func NewService(h host.Host, domains []string, notifee Notifee, opts ...Option) (*Service, error) {
+	s := &Service{
+		host:            h,
+		domains:         domains,
+		notifee:         notifee,
+		resolver:        madns.DefaultResolver,
+		refreshInterval: defaultRefreshInterval,
+	}
+	for _, opt := range opts {
+		if err := opt(s); err != nil {
+			return nil, err
+		}
+	}
+	s.ctx, s.ctxCancel = context.WithCancel(context.Background())
+	return s, nil
+}
+
+// Start starts resolving the domains, once right away and then on every refresh interval.
+func (s *Service) Start() error {
+	if s.notifee == nil {
+		return errors.New("no notifee set")
+	}
+	if len(s.domains) == 0 {
+		return errors.New("no domains to resolve")
+	}
+	s.refCount.Add(1)
+	go s.background()
+	return nil
+}
+
+// Close stops the service.
+func (s *Service) Close() error {
+	s.ctxCancel()
+	s.refCount.Wait()
+	return nil
+}
+
+func (s *Service) background() {
+	defer s.refCount.Done()
+
+	t := time.NewTicker(s.refreshInterval)
+	defer t.Stop()
+	for {
+		s.refresh()
+		select {
+		case <-t.C:
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *Service) refresh() {
+	for _, domain := range s.domains {
+		ctx, cancel := context.WithTimeout(s.ctx, resolveTimeout)
+		infos, err := s.resolve(ctx, domain)
+		cancel()
+		if err != nil {
+			log.Debugw("failed to resolve dnsaddr records", "domain", domain, "error", err)
+			continue
+		}
+		for _, info := range infos {
+			if s.host != nil && info.ID == s.host.ID() {
+				continue
+			}
+			s.notifee.HandlePeerFound(info)
+		}
+	}
+}
+
+// FindPeers resolves the dnsaddr records of the domain given as namespace.
+func (s *Service) FindPeers(ctx context.Context, ns string, opts ...discovery.Option) (<-chan peer.AddrInfo, error) {
+	var options discovery.Options
+	if err := options.Apply(opts...); err != nil {
+		return nil, err
+	}
+	infos, err := s.resolve(ctx, ns)
+	if err != nil {
+		return nil, err
+	}
+	if options.Limit > 0 && len(infos) > options.Limit {
+		infos = infos[:options.Limit]
+	}
+	ch := make(chan peer.AddrInfo, len(infos))
+	for _, info := range infos {
+		ch <- info
+	}
+	close(ch)
+	return ch, nil
+}
+
+func (s *Service) resolve(ctx context.Context, domain string) ([]peer.AddrInfo, error) {
+	return Resolve(ctx, s.resolver, domain)
+}
+
+// Resolve resolves the dnsaddr records of domain recursively, and returns the
+// peers found. Addresses without a /p2p component are ignored.
+func Resolve(ctx context.Context, resolver *madns.Resolver, domain string) ([]peer.AddrInfo, error) {
+	maddr, err := ma.NewMultiaddr("/dnsaddr/" + domain)
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := resolveRecursive(ctx, resolver, maddr, 0)
+	if err != nil {
+		return nil, err
+	}
+	var p2pAddrs []ma.Multiaddr
+	for _, addr := range addrs {
+		if _, last := ma.SplitLast(addr); last == nil || last.Protocol().Code != ma.P_P2P {
+			log.Debugw("ignoring dnsaddr record without peer ID", "domain", domain, "addr", addr)
+			continue
+		}
+		p2pAddrs = append(p2pAddrs, addr)
+	}
+	return peer.AddrInfosFromP2pAddrs(p2pAddrs...)
+}
+
+func resolveRecursive(ctx context.Context, resolver *madns.Resolver, maddr ma.Multiaddr, depth int) ([]ma.Multiaddr, error) {
+	if depth > maxResolutionDepth {
+		return nil, fmt.Errorf("exceeded maximum dnsaddr resolution depth resolving %s", maddr)
+	}
+	resolved, err := resolver.Resolve(ctx, maddr)
+	if err != nil {
+		return nil, err
+	}
+	var out []ma.Multiaddr
+	for _, addr := range resolved {
+		if first, _ := ma.SplitFirst(addr); first == nil || first.Protocol().Code != ma.P_DNSADDR {
+			out = append(out, addr)
+			continue
+		}
+		nested, err := resolveRecursive(ctx, resolver, addr, depth+1)
+		if err != nil {
+			log.Debugw("failed to resolve nested dnsaddr record", "addr", addr, "error", err)
+			continue
+		}
+		out = append(out, nested...)
+	}
+	return out, nil
+}
@@ -0,0 +1,89 @@
+package dnsaddr
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AstaFrode/go-libp2p/core/discovery"
+	"github.com/AstaFrode/go-libp2p/core/peer"
+	"github.com/AstaFrode/go-libp2p/core/test"
+
+	ma "github.com/multiformats/go-multiaddr"
+	madns "github.com/multiformats/go-multiaddr-dns"
+	"github.com/stretchr/testify/require"
+)
+
+type notif struct {
+	mx    sync.Mutex
+	infos []peer.AddrInfo
+}
+
+func (n *notif) HandlePeerFound(info peer.AddrInfo) {
+	n.mx.Lock()
+	defer n.mx.Unlock()
+	n.infos = append(n.infos, info)
+}
+
+func (n *notif) peers() []peer.AddrInfo {
+	n.mx.Lock()
+	defer n.mx.Unlock()
+	return append([]peer.AddrInfo{}, n.infos...)
+}
+
+func newResolver(t *testing.T, p1, p2 peer.ID) *madns.Resolver {
+	t.Helper()
+	mock := &madns.MockResolver{TXT: map[string][]string{
+		"_dnsaddr.example.com": {
+			"dnsaddr=/ip4/1.2.3.4/tcp/4001/p2p/" + p1.String(),
+			"dnsaddr=/dnsaddr/nested.example.com",
+			"dnsaddr=/ip4/1.2.3.4/tcp/4002", // no peer ID
+		},
+		"_dnsaddr.nested.example.com": {
+			"dnsaddr=/ip4/5.6.7.8/udp/4001/quic-v1/p2p/" + p2.String(),
+		},
+	}}
+	r, err := madns.NewResolver(madns.WithDefaultResolver(mock))
+	require.NoError(t, err)
+	return r
+}
+
+func TestResolve(t *testing.T) {
+	p1, p2 := test.RandPeerIDFatal(t), test.RandPeerIDFatal(t)
+	infos, err := Resolve(context.Background(), newResolver(t, p1, p2), "example.com")
+	require.NoError(t, err)
+	require.ElementsMatch(t, []peer.AddrInfo{
+		{ID: p1, Addrs: []ma.Multiaddr{ma.StringCast("/ip4/1.2.3.4/tcp/4001")}},
+		{ID: p2, Addrs: []ma.Multiaddr{ma.StringCast("/ip4/5.6.7.8/udp/4001/quic-v1")}},
+	}, infos)
+}
+
+func TestFindPeers(t *testing.T) {
+	p1, p2 := test.RandPeerIDFatal(t), test.RandPeerIDFatal(t)
+	s, err := NewService(nil, nil, nil, WithResolver(newResolver(t, p1, p2)))
+	require.NoError(t, err)
+
+	ch, err := s.FindPeers(context.Background(), "example.com", discovery.Limit(1))
+	require.NoError(t, err)
+	var found []peer.AddrInfo
+	for info := range ch {
+		found = append(found, info)
+	}
+	require.Len(t, found, 1)
+}
+
+func TestServiceRefresh(t *testing.T) {
+	p1, p2 := test.RandPeerIDFatal(t), test.RandPeerIDFatal(t)
+	n := &notif{}
+	s, err := NewService(nil, []string{"example.com"}, n,
+		WithResolver(newResolver(t, p1, p2)),
+		WithRefreshInterval(50*time.Millisecond),
+	)
+	require.NoError(t, err)
+	require.NoError(t, s.Start())
+	defer s.Close()
+
+	// peers are passed to the notifee on every refresh
+	require.Eventually(t, func() bool { return len(n.peers()) >= 4 }, 5*time.Second, 10*time.Millisecond)
+}
@@ -3,10 +3,13 @@ package mdns
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"math/rand"
+	"net"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/AstaFrode/go-libp2p/core/host"
 	"github.com/AstaFrode/go-libp2p/core/peer"
@@ -22,6 +25,7 @@ const (
 	ServiceName   = "_p2p._udp"
 	mdnsDomain    = "local"
 	dnsaddrPrefix = "dnsaddr="
+	tagPrefix     = "tag="
 )
 
 var log = logging.Logger("mdns")
@@ -35,11 +39,76 @@ type Notifee interface {
 	HandlePeerFound(peer.AddrInfo)
 }
 
+// Option is an option for the mDNS service.
+type Option func(*mdnsService)
+
+// WithInterfaces restricts the mDNS service to the given network interfaces.
+// By default, all multicast-capable interfaces are used.
+func WithInterfaces(ifaces ...net.Interface) Option {
+	return func(s *mdnsService) {
+		s.ifaces = ifaces
+	}
+}
+
+// WithServiceTag sets a tag that is used to separate application-specific
+// networks that use the same service name: the service is registered with the
+// tag as a DNS-SD subtype, only tagged services are queried, and peers that
+// announce a different (or no) tag are ignored.
+//
+// Peers running older versions that don't support tags will still discover
+// tagged peers. Use a different service name for full isolation.
+func WithServiceTag(tag string) Option {
+	return func(s *mdnsService) {
+		s.tag = tag
+	}
+}
+
+// DisableIPv4 stops the service from querying for peers over IPv4, and from
+// announcing and reporting IPv4 addresses.
+// The responder still answers queries received over IPv4, as zeroconf doesn't
+// allow restricting it to one IP version, but the answers don't contain IPv4
+// addresses.
+func DisableIPv4() Option {
+	return func(s *mdnsService) {
+		s.disableIPv4 = true
+	}
+}
+
+// DisableIPv6 stops the service from querying for peers over IPv6, and from
+// announcing and reporting IPv6 addresses.
+// The responder still answers queries received over IPv6, as zeroconf doesn't
+// allow restricting it to one IP version, but the answers don't contain IPv6
+// addresses.
+func DisableIPv6() Option {
+	return func(s *mdnsService) {
+		s.disableIPv6 = true
+	}
+}
+
+// WithPeerRateLimit makes the service notify the Notifee about the same peer at
+// most once per interval. Since most Notifees connect to the peers they are
+// notified about, this limits the rate of connection attempts.
+// By default, every mDNS response is passed on to the Notifee.
+func WithPeerRateLimit(interval time.Duration) Option {
+	return func(s *mdnsService) {
+		s.rateLimit = interval
+	}
+}
+
 type mdnsService struct {
 	host        host.Host
 	serviceName string
 	peerName    string
 
+	ifaces      []net.Interface
+	tag         string
+	disableIPv4 bool
+	disableIPv6 bool
+	rateLimit   time.Duration
+
+	lastNotifiedMx sync.Mutex
+	lastNotified   map[peer.ID]time.Time
+
 	// The context is canceled when Close() is called.
 	ctx       context.Context
 	ctxCancel context.CancelFunc
@@ -50,21 +119,28 @@ type mdnsService struct {
 	notifee Notifee
 }
 
-func NewMdnsService(host host.Host, serviceName string, notifee Notifee) *mdnsService {
+func NewMdnsService(host host.Host, serviceName string, notifee Notifee, opts ...Option) *mdnsService {
 	if serviceName == "" {
 		serviceName = ServiceName
 	}
 	s := &mdnsService{
-		host:        host,
-		serviceName: serviceName,
-		peerName:    randomString(32 + rand.Intn(32)), // generate a random string between 32 and 63 characters long
-		notifee:     notifee,
+		host:         host,
+		serviceName:  serviceName,
+		peerName:     randomString(32 + rand.Intn(32)), // generate a random string between 32 and 63 characters long
+		notifee:      notifee,
+		lastNotified: make(map[peer.ID]time.Time),
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
 	s.ctx, s.ctxCancel = context.WithCancel(context.Background())
 	return s
 }
 
 func (s *mdnsService) Start() error {
+	if s.disableIPv4 && s.disableIPv6 {
+		return errors.New("cannot disable both IPv4 and IPv6")
+	}
 	if err := s.startServer(); err != nil {
 		return err
 	}
@@ -90,6 +166,9 @@ func (s *mdnsService) getIPs(addrs []ma.Multiaddr) ([]string, error) {
 		if first == nil {
 			continue
 		}
+		if !s.addrAllowed(addr) {
+			continue
+		}
 		if ip4 == "" && first.Protocol().Code == ma.P_IP4 {
 			ip4 = first.Value()
 		} else if ip6 == "" && first.Protocol().Code == ma.P_IP6 {
@@ -109,6 +188,67 @@ func (s *mdnsService) getIPs(addrs []ma.Multiaddr) ([]string, error) {
 	return ips, nil
 }
 
+// addrAllowed checks that addr doesn't use a disabled IP version.
+func (s *mdnsService) addrAllowed(addr ma.Multiaddr) bool {
+	first, _ := ma.SplitFirst(addr)
+	if first == nil {
+		return true
+	}
+	switch first.Protocol().Code {
+	case ma.P_IP4:
+		return !s.disableIPv4
+	case ma.P_IP6:
+		return !s.disableIPv6
+	default:
+		return true
+	}
+}
+
+// service returns the service name to register and browse for, including the
+// subtype, if a tag was set.
+func (s *mdnsService) service() string {
+	if s.tag == "" {
+		return s.serviceName
+	}
+	return fmt.Sprintf("%s,_%s", s.serviceName, s.tag)
+}
+
+func (s *mdnsService) browseOptions() []zeroconf.ClientOption {
+	var opts []zeroconf.ClientOption
+	if len(s.ifaces) > 0 {
+		opts = append(opts, zeroconf.SelectIfaces(s.ifaces))
+	}
+	switch {
+	case s.disableIPv4:
+		opts = append(opts, zeroconf.SelectIPTraffic(zeroconf.IPv6))
+	case s.disableIPv6:
+		opts = append(opts, zeroconf.SelectIPTraffic(zeroconf.IPv4))
+	}
+	return opts
+}
+
+// shouldNotify applies the rate limit to notifications about p.
+func (s *mdnsService) shouldNotify(p peer.ID) bool {
+	if s.rateLimit <= 0 {
+		return true
+	}
+	s.lastNotifiedMx.Lock()
+	defer s.lastNotifiedMx.Unlock()
+
+	now := time.Now()
+	if t, ok := s.lastNotified[p]; ok && now.Sub(t) < s.rateLimit {
+		return false
+	}
+	// garbage collect old entries
+	for id, t := range s.lastNotified {
+		if now.Sub(t) >= s.rateLimit {
+			delete(s.lastNotified, id)
+		}
+	}
+	s.lastNotified[p] = now
+	return true
+}
+
 func (s *mdnsService) startServer() error {
 	interfaceAddrs, err := s.host.Network().InterfaceListenAddresses()
 	if err != nil {
@@ -123,10 +263,13 @@ func (s *mdnsService) startServer() error {
 	}
 	var txts []string
 	for _, addr := range addrs {
-		if manet.IsThinWaist(addr) { // don't announce circuit addresses
+		if manet.IsThinWaist(addr) && s.addrAllowed(addr) { // don't announce circuit addresses
 			txts = append(txts, dnsaddrPrefix+addr.String())
 		}
 	}
+	if s.tag != "" {
+		txts = append(txts, tagPrefix+s.tag)
+	}
 
 	ips, err := s.getIPs(addrs)
 	if err != nil {
@@ -135,13 +278,13 @@ func (s *mdnsService) startServer() error {
 
 	server, err := zeroconf.RegisterProxy(
 		s.peerName,
-		s.serviceName,
+		s.service(),
 		mdnsDomain,
 		4001, // we have to pass in a port number here, but libp2p only uses the TXT records
 		s.peerName,
 		ips,
 		txts,
-		nil,
+		s.ifaces,
 	)
 	if err != nil {
 		return err
@@ -159,25 +302,37 @@ func (s *mdnsService) startResolver(ctx context.Context) {
 			// We only care about the TXT records.
 			// Ignore A, AAAA and PTR.
 			addrs := make([]ma.Multiaddr, 0, len(entry.Text)) // assume that all TXT records are dnsaddrs
-			for _, s := range entry.Text {
-				if !strings.HasPrefix(s, dnsaddrPrefix) {
+			var tag string
+			for _, txt := range entry.Text {
+				if strings.HasPrefix(txt, tagPrefix) {
+					tag = txt[len(tagPrefix):]
+					continue
+				}
+				if !strings.HasPrefix(txt, dnsaddrPrefix) {
 					log.Debug("missing dnsaddr prefix")
 					continue
 				}
-				addr, err := ma.NewMultiaddr(s[len(dnsaddrPrefix):])
+				addr, err := ma.NewMultiaddr(txt[len(dnsaddrPrefix):])
 				if err != nil {
 					log.Debugf("failed to parse multiaddr: %s", err)
 					continue
 				}
+				if !s.addrAllowed(addr) {
+					continue
+				}
 				addrs = append(addrs, addr)
 			}
+			if tag != s.tag {
+				log.Debugf("ignoring peer with service tag %q", tag)
+				continue
+			}
 			infos, err := peer.AddrInfosFromP2pAddrs(addrs...)
 			if err != nil {
 				log.Debugf("failed to get peer info: %s", err)
 				continue
 			}
 			for _, info := range infos {
-				if info.ID == s.host.ID() {
+				if info.ID == s.host.ID() || !s.shouldNotify(info.ID) {
 					continue
 				}
 				go s.notifee.HandlePeerFound(info)
@@ -186,7 +341,7 @@ func (s *mdnsService) startResolver(ctx context.Context) {
 	}()
 	go func() {
 		defer s.resolverWG.Done()
-		if err := zeroconf.Browse(ctx, s.serviceName, mdnsDomain, entryChan); err != nil {
+		if err := zeroconf.Browse(ctx, s.service(), mdnsDomain, entryChan, s.browseOptions()...); err != nil {
 			log.Debugf("zeroconf browsing failed: %s", err)
 		}
 	}()
@@ -8,15 +8,16 @@ import (
 	"github.com/AstaFrode/go-libp2p"
 	"github.com/AstaFrode/go-libp2p/core/peer"
 
+	ma "github.com/multiformats/go-multiaddr"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
-func setupMDNS(t *testing.T, notifee Notifee) peer.ID {
+func setupMDNS(t *testing.T, notifee Notifee, opts ...Option) peer.ID {
 	t.Helper()
 	host, err := libp2p.New(libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
 	require.NoError(t, err)
-	s := NewMdnsService(host, "", notifee)
+	s := NewMdnsService(host, "", notifee, opts...)
 	require.NoError(t, s.Start())
 	t.Cleanup(func() {
 		host.Close()
@@ -96,3 +97,53 @@ func TestOtherDiscovery(t *testing.T) {
 		"expected peers to find each other",
 	)
 }
+
+func TestServiceTag(t *testing.T) {
+	fooNotif1, fooNotif2, barNotif := &notif{}, &notif{}, &notif{}
+	foo1 := setupMDNS(t, fooNotif1, WithServiceTag("foo"))
+	foo2 := setupMDNS(t, fooNotif2, WithServiceTag("foo"))
+	bar := setupMDNS(t, barNotif, WithServiceTag("bar"))
+
+	found := func(n *notif, id peer.ID) bool {
+		for _, info := range n.GetPeers() {
+			if info.ID == id {
+				return true
+			}
+		}
+		return false
+	}
+	require.Eventually(t, func() bool {
+		return found(fooNotif1, foo2) && found(fooNotif2, foo1)
+	}, 25*time.Second, 5*time.Millisecond)
+	require.False(t, found(fooNotif1, bar))
+	require.False(t, found(fooNotif2, bar))
+	require.False(t, found(barNotif, foo1))
+	require.False(t, found(barNotif, foo2))
+}
+
+func TestPeerRateLimit(t *testing.T) {
+	s := NewMdnsService(nil, "", nil, WithPeerRateLimit(time.Hour))
+	p1, p2 := peer.ID("p1"), peer.ID("p2")
+	require.True(t, s.shouldNotify(p1))
+	require.False(t, s.shouldNotify(p1))
+	require.True(t, s.shouldNotify(p2))
+
+	s.lastNotified[p1] = time.Now().Add(-time.Hour)
+	require.True(t, s.shouldNotify(p1))
+}
+
+func TestDisableIPVersions(t *testing.T) {
+	h, err := libp2p.New(libp2p.NoListenAddrs)
+	require.NoError(t, err)
+	defer h.Close()
+	s := NewMdnsService(h, "", &notif{}, DisableIPv4(), DisableIPv6())
+	require.Error(t, s.Start())
+
+	s = NewMdnsService(h, "", &notif{}, DisableIPv6())
+	ips, err := s.getIPs([]ma.Multiaddr{
+		ma.StringCast("/ip6/::1/tcp/1234"),
+		ma.StringCast("/ip4/127.0.0.1/tcp/1234"),
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"127.0.0.1"}, ips)
+}
@@ -0,0 +1,201 @@
+package rendezvous
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/AstaFrode/go-libp2p/core/discovery"
+	"github.com/AstaFrode/go-libp2p/core/host"
+	"github.com/AstaFrode/go-libp2p/core/network"
+	"github.com/AstaFrode/go-libp2p/core/peer"
+	"github.com/AstaFrode/go-libp2p/core/peerstore"
+	"github.com/AstaFrode/go-libp2p/core/record"
+	"github.com/AstaFrode/go-libp2p/p2p/discovery/rendezvous/pb"
+
+	"github.com/libp2p/go-msgio/pbio"
+)
+
+// Client talks to a single rendezvous point.
+type Client struct {
+	host   host.Host
+	server peer.ID
+}
+
+var _ discovery.Discovery = &Client{}
+
+// NewClient creates a new client for the rendezvous point server.
+// The host must already know the addresses of the rendezvous point, or be connected to it.
+func NewClient(h host.Host, server peer.ID) *Client {
+	return &Client{host: h, server: server}
+}
+
+// Register registers the host under the namespace ns, using its signed peer record.
+// If ttl is 0, the rendezvous point uses its default TTL.
+// It returns the TTL granted by the rendezvous point.
+func (c *Client) Register(ctx context.Context, ns string, ttl time.Duration) (time.Duration, error) {
+	cab, ok := peerstore.GetCertifiedAddrBook(c.host.Peerstore())
+	if !ok {
+		return 0, errors.New("peerstore doesn't support signed peer records")
+	}
+	env := cab.GetPeerRecord(c.host.ID())
+	if env == nil {
+		return 0, errors.New("no signed peer record available")
+	}
+	rec, err := env.Marshal()
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := c.request(ctx, &pb.Message{
+		Type: pb.Message_REGISTER.Enum(),
+		Register: &pb.Message_Register{
+			Ns:               &ns,
+			SignedPeerRecord: rec,
+			Ttl:              ttlToProto(ttl),
+		},
+	}, pb.Message_REGISTER_RESPONSE)
+	if err != nil {
+		return 0, err
+	}
+	r := resp.GetRegisterResponse()
+	if r.GetStatus() != pb.Message_OK {
+		return 0, &Error{Status: r.GetStatus(), Text: r.GetStatusText()}
+	}
+	return time.Duration(r.GetTtl()) * time.Second, nil
+}
+
+// Unregister removes the registration of the host under the namespace ns.
+func (c *Client) Unregister(ctx context.Context, ns string) error {
+	s, err := c.newStream(ctx)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	// UNREGISTER doesn't have a response
+	if err := pbio.NewDelimitedWriter(s).WriteMsg(&pb.Message{
+		Type:       pb.Message_UNREGISTER.Enum(),
+		Unregister: &pb.Message_Unregister{Ns: &ns},
+	}); err != nil {
+		s.Reset()
+		return err
+	}
+	return nil
+}
+
+// Discover asks the rendezvous point for peers registered under the namespace ns.
+// An empty namespace returns peers from all namespaces.
+// If limit is 0, the rendezvous point decides how many peers to return.
+//
+// The returned cookie can be passed to a subsequent call to only receive
+// registrations that were added since this call.
+func (c *Client) Discover(ctx context.Context, ns string, limit int, cookie []byte) ([]peer.AddrInfo, []byte, error) {
+	req := &pb.Message_Discover{Cookie: cookie}
+	if ns != "" {
+		req.Ns = &ns
+	}
+	if limit > 0 {
+		l := uint64(limit)
+		req.Limit = &l
+	}
+	resp, err := c.request(ctx, &pb.Message{
+		Type:     pb.Message_DISCOVER.Enum(),
+		Discover: req,
+	}, pb.Message_DISCOVER_RESPONSE)
+	if err != nil {
+		return nil, nil, err
+	}
+	r := resp.GetDiscoverResponse()
+	if r.GetStatus() != pb.Message_OK {
+		return nil, nil, &Error{Status: r.GetStatus(), Text: r.GetStatusText()}
+	}
+
+	infos := make([]peer.AddrInfo, 0, len(r.GetRegistrations()))
+	for _, reg := range r.GetRegistrations() {
+		var rec peer.PeerRecord
+		if _, err := record.ConsumeTypedEnvelope(reg.GetSignedPeerRecord(), &rec); err != nil {
+			log.Debugw("invalid signed peer record in DISCOVER response", "server", c.server, "error", err)
+			continue
+		}
+		infos = append(infos, peer.AddrInfo{ID: rec.PeerID, Addrs: rec.Addrs})
+	}
+	return infos, r.GetCookie(), nil
+}
+
+// Advertise registers the host under the namespace ns.
+func (c *Client) Advertise(ctx context.Context, ns string, opts ...discovery.Option) (time.Duration, error) {
+	var options discovery.Options
+	if err := options.Apply(opts...); err != nil {
+		return 0, err
+	}
+	return c.Register(ctx, ns, options.Ttl)
+}
+
+// FindPeers discovers the peers registered under the namespace ns.
+func (c *Client) FindPeers(ctx context.Context, ns string, opts ...discovery.Option) (<-chan peer.AddrInfo, error) {
+	var options discovery.Options
+	if err := options.Apply(opts...); err != nil {
+		return nil, err
+	}
+	infos, _, err := c.Discover(ctx, ns, options.Limit, nil)
+	if err != nil {
+		return nil, err
+	}
+	ch := make(chan peer.AddrInfo, len(infos))
+	for _, info := range infos {
+		ch <- info
+	}
+	close(ch)
+	return ch, nil
+}
+
+func (c *Client) newStream(ctx context.Context) (network.Stream, error) {
+	s, err := c.host.NewStream(ctx, c.server, ProtocolID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Scope().SetService(ServiceName); err != nil {
+		s.Reset()
+		return nil, fmt.Errorf("error attaching stream to rendezvous service: %w", err)
+	}
+	return s, nil
+}
+
+func (c *Client) request(ctx context.Context, req *pb.Message, respType pb.Message_MessageType) (*pb.Message, error) {
+	s, err := c.newStream(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+
+	deadline := time.Now().Add(StreamTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	s.SetDeadline(deadline)
+
+	if err := pbio.NewDelimitedWriter(s).WriteMsg(req); err != nil {
+		s.Reset()
+		return nil, err
+	}
+	var resp pb.Message
+	if err := pbio.NewDelimitedReader(s, maxMessageSize).ReadMsg(&resp); err != nil {
+		s.Reset()
+		return nil, err
+	}
+	if resp.GetType() != respType {
+		s.Reset()
+		return nil, fmt.Errorf("unexpected response: expected %s, got %s", respType, resp.GetType())
+	}
+	return &resp, nil
+}
+
+func ttlToProto(ttl time.Duration) *uint64 {
+	if ttl <= 0 {
+		return nil
+	}
+	t := uint64(ttl / time.Second)
+	return &t
+}
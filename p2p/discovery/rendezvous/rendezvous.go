@@ -0,0 +1,60 @@
+// Package rendezvous implements the libp2p rendezvous protocol, as described in
+// https://github.com/libp2p/specs/blob/master/rendezvous/README.md.
+//
+// Peers register themselves under a namespace at a rendezvous point (the Server),
+// and other peers discover them by querying the rendezvous point for that namespace.
+// Registrations carry the signed peer record of the registering peer, and expire
+// after their TTL.
+package rendezvous
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/AstaFrode/go-libp2p/core/protocol"
+	"github.com/AstaFrode/go-libp2p/p2p/discovery/rendezvous/pb"
+
+	logging "github.com/ipfs/go-log/v2"
+)
+
+//go:generate protoc --proto_path=$PWD:$PWD/../../.. --go_out=. --go_opt=Mpb/rendezvous.proto=./pb pb/rendezvous.proto
+
+var log = logging.Logger("rendezvous")
+
+const (
+	// ProtocolID is the protocol ID of the rendezvous protocol.
+	ProtocolID = protocol.ID("/rendezvous/1.0.0")
+	// ServiceName is the name of the rendezvous service, used by the resource manager.
+	ServiceName = "libp2p.rendezvous"
+
+	// DefaultTTL is the TTL of a registration if the client doesn't request one.
+	DefaultTTL = 2 * time.Hour
+	// MaxTTL is the maximum TTL of a registration, as defined by the spec.
+	MaxTTL = 72 * time.Hour
+	// MaxNamespaceLength is the maximum length of a namespace, as defined by the spec.
+	MaxNamespaceLength = 255
+	// MaxDiscoverLimit is the maximum number of registrations returned in a single DISCOVER response.
+	MaxDiscoverLimit = 1000
+
+	// StreamTimeout is the timeout for a single request / response exchange.
+	StreamTimeout = time.Minute
+
+	maxMessageSize = 4 << 20
+	// maxRequestSize is the maximum size of a request received by the server.
+	// Requests are small: the largest one is a REGISTER containing a signed peer record.
+	maxRequestSize = 16 << 10
+)
+
+// Error is returned by the client when the rendezvous point responds with
+// a status other than OK.
+type Error struct {
+	Status pb.Message_ResponseStatus
+	Text   string
+}
+
+func (e *Error) Error() string {
+	if e.Text == "" {
+		return fmt.Sprintf("rendezvous error: %s", e.Status)
+	}
+	return fmt.Sprintf("rendezvous error: %s: %s", e.Status, e.Text)
+}
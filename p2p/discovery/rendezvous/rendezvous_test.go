@@ -0,0 +1,197 @@
+package rendezvous
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AstaFrode/go-libp2p"
+	"github.com/AstaFrode/go-libp2p/core/discovery"
+	"github.com/AstaFrode/go-libp2p/core/host"
+	"github.com/AstaFrode/go-libp2p/core/peer"
+	"github.com/AstaFrode/go-libp2p/p2p/discovery/rendezvous/pb"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/require"
+)
+
+func newHost(t *testing.T) host.Host {
+	t.Helper()
+	h, err := libp2p.New(libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+	require.NoError(t, err)
+	t.Cleanup(func() { h.Close() })
+	return h
+}
+
+func newClient(t *testing.T, server host.Host) (host.Host, *Client) {
+	t.Helper()
+	h := newHost(t)
+	require.NoError(t, h.Connect(context.Background(), peer.AddrInfo{ID: server.ID(), Addrs: server.Addrs()}))
+	return h, NewClient(h, server.ID())
+}
+
+func requireStatus(t *testing.T, err error, status pb.Message_ResponseStatus) {
+	t.Helper()
+	var rerr *Error
+	require.True(t, errors.As(err, &rerr), "expected a rendezvous error, got %v", err)
+	require.Equal(t, status, rerr.Status)
+}
+
+func TestRegisterDiscover(t *testing.T) {
+	sh := newHost(t)
+	s, err := NewServer(sh)
+	require.NoError(t, err)
+	defer s.Close()
+
+	h1, c1 := newClient(t, sh)
+	h2, c2 := newClient(t, sh)
+	_, c3 := newClient(t, sh)
+
+	ttl, err := c1.Register(context.Background(), "foo", 0)
+	require.NoError(t, err)
+	require.Equal(t, DefaultTTL, ttl)
+	ttl, err = c2.Register(context.Background(), "foo", time.Hour)
+	require.NoError(t, err)
+	require.Equal(t, time.Hour, ttl)
+	_, err = c2.Register(context.Background(), "bar", 0)
+	require.NoError(t, err)
+
+	infos, cookie, err := c3.Discover(context.Background(), "foo", 0, nil)
+	require.NoError(t, err)
+	require.Len(t, infos, 2)
+	require.Equal(t, h1.ID(), infos[0].ID)
+	require.ElementsMatch(t, h1.Addrs(), infos[0].Addrs)
+	require.Equal(t, h2.ID(), infos[1].ID)
+
+	// the cookie only returns new registrations
+	infos, cookie, err = c3.Discover(context.Background(), "foo", 0, cookie)
+	require.NoError(t, err)
+	require.Empty(t, infos)
+	_, err = c3.Register(context.Background(), "foo", 0)
+	require.NoError(t, err)
+	infos, _, err = c3.Discover(context.Background(), "foo", 0, cookie)
+	require.NoError(t, err)
+	require.Len(t, infos, 1)
+
+	// a cookie is only valid for the namespace it was issued for
+	_, _, err = c3.Discover(context.Background(), "bar", 0, cookie)
+	requireStatus(t, err, pb.Message_E_INVALID_COOKIE)
+
+	// the empty namespace returns registrations from all namespaces
+	infos, _, err = c3.Discover(context.Background(), "", 0, nil)
+	require.NoError(t, err)
+	require.Len(t, infos, 4)
+
+	// limit
+	infos, _, err = c3.Discover(context.Background(), "foo", 1, nil)
+	require.NoError(t, err)
+	require.Len(t, infos, 1)
+
+	require.NoError(t, c1.Unregister(context.Background(), "foo"))
+	require.Eventually(t, func() bool {
+		infos, _, err := c3.Discover(context.Background(), "foo", 0, nil)
+		return err == nil && len(infos) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestDiscoveryInterface(t *testing.T) {
+	sh := newHost(t)
+	s, err := NewServer(sh)
+	require.NoError(t, err)
+	defer s.Close()
+
+	h1, c1 := newClient(t, sh)
+	_, c2 := newClient(t, sh)
+
+	ttl, err := c1.Advertise(context.Background(), "foo", discovery.TTL(time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, time.Hour, ttl)
+
+	ch, err := c2.FindPeers(context.Background(), "foo")
+	require.NoError(t, err)
+	var found []peer.ID
+	for info := range ch {
+		found = append(found, info.ID)
+	}
+	require.Equal(t, []peer.ID{h1.ID()}, found)
+}
+
+func TestRegisterValidation(t *testing.T) {
+	sh := newHost(t)
+	s, err := NewServer(sh, WithMaxTTL(time.Hour), WithMaxRegistrationsPerPeer(2))
+	require.NoError(t, err)
+	defer s.Close()
+
+	_, c := newClient(t, sh)
+
+	_, err = c.Register(context.Background(), "", 0)
+	requireStatus(t, err, pb.Message_E_INVALID_NAMESPACE)
+	_, err = c.Register(context.Background(), strings.Repeat("a", MaxNamespaceLength+1), 0)
+	requireStatus(t, err, pb.Message_E_INVALID_NAMESPACE)
+	_, err = c.Register(context.Background(), "foo", 2*time.Hour)
+	requireStatus(t, err, pb.Message_E_INVALID_TTL)
+
+	_, err = c.Register(context.Background(), "foo", time.Hour)
+	require.NoError(t, err)
+	_, err = c.Register(context.Background(), "bar", time.Hour)
+	require.NoError(t, err)
+	// refreshing an existing registration doesn't count against the quota
+	_, err = c.Register(context.Background(), "foo", time.Hour)
+	require.NoError(t, err)
+	_, err = c.Register(context.Background(), "baz", time.Hour)
+	requireStatus(t, err, pb.Message_E_NOT_AUTHORIZED)
+}
+
+func TestRegistrationExpiry(t *testing.T) {
+	cl := clock.NewMock()
+	sh := newHost(t)
+	s, err := NewServer(sh, WithClock(cl))
+	require.NoError(t, err)
+	defer s.Close()
+
+	_, c1 := newClient(t, sh)
+	_, c2 := newClient(t, sh)
+
+	_, err = c1.Register(context.Background(), "foo", time.Hour)
+	require.NoError(t, err)
+	_, err = c2.Register(context.Background(), "foo", 3*time.Hour)
+	require.NoError(t, err)
+
+	cl.Add(time.Hour)
+	infos, _, err := c1.Discover(context.Background(), "foo", 0, nil)
+	require.NoError(t, err)
+	require.Len(t, infos, 1)
+
+	// expired registrations are garbage collected, freeing up the quota
+	cl.Add(3 * time.Hour)
+	require.Eventually(t, func() bool {
+		s.mx.Lock()
+		defer s.mx.Unlock()
+		return s.total == 0 && len(s.registrations) == 0 && len(s.perPeer) == 0 &&
+			len(s.byNamespace) == 0 && len(s.all.regs) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestRegIndex(t *testing.T) {
+	var x regIndex
+	regs := make([]*registration, 10)
+	for i := range regs {
+		regs[i] = &registration{id: uint64(i + 1)}
+		x.add(regs[i])
+	}
+	require.Equal(t, regs[5:], x.after(5))
+	require.Empty(t, x.after(10))
+
+	// removed registrations are kept until they make up half of the list
+	for _, r := range regs[:5] {
+		r.removed = true
+		x.remove()
+	}
+	require.Len(t, x.regs, 10)
+	regs[5].removed = true
+	x.remove()
+	require.Equal(t, regs[6:], x.regs)
+	require.Equal(t, regs[8:], x.after(8))
+}
@@ -0,0 +1,453 @@
+package rendezvous
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/AstaFrode/go-libp2p/core/host"
+	"github.com/AstaFrode/go-libp2p/core/network"
+	"github.com/AstaFrode/go-libp2p/core/peer"
+	"github.com/AstaFrode/go-libp2p/core/record"
+	"github.com/AstaFrode/go-libp2p/p2p/discovery/rendezvous/pb"
+
+	"github.com/benbjohnson/clock"
+	"github.com/libp2p/go-msgio/pbio"
+)
+
+const (
+	defaultMaxRegistrationsPerPeer = 1000
+	defaultMaxRegistrations        = 100000
+	defaultDiscoverLimit           = 100
+)
+
+// gcInterval is the interval at which expired registrations are removed.
+var gcInterval = time.Minute
+
+// Option is an option for the rendezvous server.
+type Option func(*Server) error
+
+// WithMaxTTL sets the maximum TTL a client can request.
+// Registrations with a larger TTL are rejected. Defaults to MaxTTL.
+func WithMaxTTL(ttl time.Duration) Option {
+	return func(s *Server) error {
+		if ttl < time.Second || ttl > MaxTTL {
+			return errors.New("max TTL must be between 1s and 72h")
+		}
+		s.maxTTL = ttl
+		return nil
+	}
+}
+
+// WithMaxRegistrationsPerPeer sets the maximum number of namespaces a single peer
+// can be registered under. Defaults to 1000.
+func WithMaxRegistrationsPerPeer(n int) Option {
+	return func(s *Server) error {
+		if n <= 0 {
+			return errors.New("max registrations per peer must be positive")
+		}
+		s.maxRegistrationsPerPeer = n
+		return nil
+	}
+}
+
+// WithMaxRegistrations sets the maximum number of registrations the server stores
+// in total. Defaults to 100000.
+func WithMaxRegistrations(n int) Option {
+	return func(s *Server) error {
+		if n <= 0 {
+			return errors.New("max registrations must be positive")
+		}
+		s.maxRegistrations = n
+		return nil
+	}
+}
+
+// WithClock sets the clock used to expire registrations.
+func WithClock(cl clock.Clock) Option {
+	return func(s *Server) error {
+		s.clock = cl
+		return nil
+	}
+}
+
+type registration struct {
+	id      uint64
+	peer    peer.ID
+	ns      string
+	record  []byte
+	expiry  time.Time
+	removed bool
+}
+
+// regIndex is a list of registrations ordered by ID, which is the order of the
+// discovery cookies. IDs are assigned in increasing order, so new registrations
+// are appended. Removed registrations are skipped, and dropped once they make up
+// half of the list.
+type regIndex struct {
+	regs    []*registration
+	removed int
+}
+
+func (x *regIndex) add(r *registration) {
+	x.regs = append(x.regs, r)
+}
+
+func (x *regIndex) remove() {
+	x.removed++
+	if x.removed <= len(x.regs)/2 {
+		return
+	}
+	regs := make([]*registration, 0, len(x.regs)-x.removed)
+	for _, r := range x.regs {
+		if !r.removed {
+			regs = append(regs, r)
+		}
+	}
+	x.regs = regs
+	x.removed = 0
+}
+
+// after returns the registrations with an ID larger than id, including removed ones.
+func (x *regIndex) after(id uint64) []*registration {
+	i := sort.Search(len(x.regs), func(i int) bool { return x.regs[i].id > id })
+	return x.regs[i:]
+}
+
+// Server is a rendezvous point. It stores registrations in memory.
+type Server struct {
+	host host.Host
+
+	maxTTL                  time.Duration
+	maxRegistrationsPerPeer int
+	maxRegistrations        int
+	clock                   clock.Clock
+
+	mx            sync.Mutex
+	closed        bool
+	nextID        uint64
+	registrations map[string]map[peer.ID]*registration // namespace -> peer -> registration
+	byNamespace   map[string]*regIndex
+	all           regIndex
+	perPeer       map[peer.ID]int
+	total         int
+
+	closeChan chan struct{}
+	refCount  sync.WaitGroup
+}
+
+var _ io.Closer = &Server{}
+
+// NewServer creates a new rendezvous point and registers the protocol handler on the host.
+func NewServer(h host.Host, opts ...Option) (*Server, error) {
+	s := &Server{
+		host:                    h,
+		maxTTL:                  MaxTTL,
+		maxRegistrationsPerPeer: defaultMaxRegistrationsPerPeer,
+		maxRegistrations:        defaultMaxRegistrations,
+		clock:                   clock.New(),
+		registrations:           make(map[string]map[peer.ID]*registration),
+		byNamespace:             make(map[string]*regIndex),
+		perPeer:                 make(map[peer.ID]int),
+		closeChan:               make(chan struct{}),
+	}
+	for _, opt := range opts {
+		if err := opt(s); err != nil {
+			return nil, err
+		}
+	}
+
+	s.refCount.Add(1)
+	go s.background()
+	h.SetStreamHandler(ProtocolID, s.handleStream)
+	return s, nil
+}
+
+// Close removes the protocol handler and stops the server.
+func (s *Server) Close() error {
+	s.mx.Lock()
+	if s.closed {
+		s.mx.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.mx.Unlock()
+
+	s.host.RemoveStreamHandler(ProtocolID)
+	close(s.closeChan)
+	s.refCount.Wait()
+	return nil
+}
+
+func (s *Server) background() {
+	defer s.refCount.Done()
+
+	t := s.clock.Ticker(gcInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			s.gc()
+		case <-s.closeChan:
+			return
+		}
+	}
+}
+
+func (s *Server) gc() {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	now := s.clock.Now()
+	for _, regs := range s.registrations {
+		for _, r := range regs {
+			if !r.expiry.After(now) {
+				s.removeLocked(r)
+			}
+		}
+	}
+}
+
+func (s *Server) handleStream(str network.Stream) {
+	defer str.Close()
+
+	if err := str.Scope().SetService(ServiceName); err != nil {
+		log.Debugf("error attaching stream to rendezvous service: %s", err)
+		str.Reset()
+		return
+	}
+	if err := str.Scope().ReserveMemory(maxRequestSize, network.ReservationPriorityAlways); err != nil {
+		log.Debugf("error reserving memory for stream: %s", err)
+		str.Reset()
+		return
+	}
+	defer str.Scope().ReleaseMemory(maxRequestSize)
+
+	rd := pbio.NewDelimitedReader(str, maxRequestSize)
+	w := pbio.NewDelimitedWriter(str)
+	remote := str.Conn().RemotePeer()
+
+	// clients may send multiple requests on the same stream
+	for {
+		str.SetDeadline(time.Now().Add(StreamTimeout))
+
+		var req pb.Message
+		if err := rd.ReadMsg(&req); err != nil {
+			if err != io.EOF {
+				log.Debugw("error reading rendezvous request", "peer", remote, "error", err)
+				str.Reset()
+			}
+			return
+		}
+
+		var resp *pb.Message
+		switch req.GetType() {
+		case pb.Message_REGISTER:
+			resp = &pb.Message{
+				Type:             pb.Message_REGISTER_RESPONSE.Enum(),
+				RegisterResponse: s.handleRegister(remote, req.GetRegister()),
+			}
+		case pb.Message_UNREGISTER:
+			s.handleUnregister(remote, req.GetUnregister())
+			continue
+		case pb.Message_DISCOVER:
+			resp = &pb.Message{
+				Type:             pb.Message_DISCOVER_RESPONSE.Enum(),
+				DiscoverResponse: s.handleDiscover(req.GetDiscover()),
+			}
+		default:
+			log.Debugw("unexpected rendezvous message", "peer", remote, "type", req.GetType())
+			str.Reset()
+			return
+		}
+		if err := w.WriteMsg(resp); err != nil {
+			log.Debugw("error writing rendezvous response", "peer", remote, "error", err)
+			str.Reset()
+			return
+		}
+	}
+}
+
+func registerError(status pb.Message_ResponseStatus, text string) *pb.Message_RegisterResponse {
+	return &pb.Message_RegisterResponse{Status: status.Enum(), StatusText: &text}
+}
+
+func (s *Server) handleRegister(p peer.ID, req *pb.Message_Register) *pb.Message_RegisterResponse {
+	ns := req.GetNs()
+	if ns == "" || len(ns) > MaxNamespaceLength {
+		return registerError(pb.Message_E_INVALID_NAMESPACE, "invalid namespace")
+	}
+
+	ttl := DefaultTTL
+	if req.Ttl != nil {
+		ttl = time.Duration(req.GetTtl()) * time.Second
+		if ttl <= 0 || ttl > s.maxTTL {
+			return registerError(pb.Message_E_INVALID_TTL, "invalid TTL")
+		}
+	}
+
+	var rec peer.PeerRecord
+	if _, err := record.ConsumeTypedEnvelope(req.GetSignedPeerRecord(), &rec); err != nil {
+		return registerError(pb.Message_E_INVALID_SIGNED_PEER_RECORD, "invalid signed peer record")
+	}
+	if rec.PeerID != p {
+		return registerError(pb.Message_E_INVALID_SIGNED_PEER_RECORD, "signed peer record doesn't match peer ID")
+	}
+
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	if s.closed {
+		return registerError(pb.Message_E_UNAVAILABLE, "server closed")
+	}
+	old, replacing := s.registrations[ns][p]
+	if !replacing {
+		if s.perPeer[p] >= s.maxRegistrationsPerPeer {
+			return registerError(pb.Message_E_NOT_AUTHORIZED, "too many registrations")
+		}
+		if s.total >= s.maxRegistrations {
+			return registerError(pb.Message_E_UNAVAILABLE, "registration limit reached")
+		}
+	} else {
+		s.removeLocked(old)
+	}
+
+	s.nextID++
+	r := &registration{
+		id:     s.nextID,
+		peer:   p,
+		ns:     ns,
+		record: req.GetSignedPeerRecord(),
+		expiry: s.clock.Now().Add(ttl),
+	}
+	regs, ok := s.registrations[ns]
+	if !ok {
+		regs = make(map[peer.ID]*registration)
+		s.registrations[ns] = regs
+		s.byNamespace[ns] = &regIndex{}
+	}
+	regs[p] = r
+	s.byNamespace[ns].add(r)
+	s.all.add(r)
+	s.perPeer[p]++
+	s.total++
+
+	t := uint64(ttl / time.Second)
+	return &pb.Message_RegisterResponse{Status: pb.Message_OK.Enum(), Ttl: &t}
+}
+
+func (s *Server) handleUnregister(p peer.ID, req *pb.Message_Unregister) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	if r, ok := s.registrations[req.GetNs()][p]; ok {
+		s.removeLocked(r)
+	}
+}
+
+func discoverError(status pb.Message_ResponseStatus, text string) *pb.Message_DiscoverResponse {
+	return &pb.Message_DiscoverResponse{Status: status.Enum(), StatusText: &text}
+}
+
+func (s *Server) handleDiscover(req *pb.Message_Discover) *pb.Message_DiscoverResponse {
+	ns := req.GetNs()
+	if len(ns) > MaxNamespaceLength {
+		return discoverError(pb.Message_E_INVALID_NAMESPACE, "invalid namespace")
+	}
+	var after uint64
+	if req.Cookie != nil {
+		var ok bool
+		after, ok = parseCookie(req.GetCookie(), ns)
+		if !ok {
+			return discoverError(pb.Message_E_INVALID_COOKIE, "invalid cookie")
+		}
+	}
+	limit := defaultDiscoverLimit
+	if l := req.GetLimit(); l > 0 {
+		limit = MaxDiscoverLimit
+		if l < MaxDiscoverLimit {
+			limit = int(l)
+		}
+	}
+
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	now := s.clock.Now()
+	idx := &s.all
+	if ns != "" {
+		idx = s.byNamespace[ns]
+	}
+	var regs []*registration
+	if idx != nil {
+		for _, r := range idx.after(after) {
+			if len(regs) == limit {
+				break
+			}
+			// expired registrations are removed by the next gc run
+			if r.removed || !r.expiry.After(now) {
+				continue
+			}
+			regs = append(regs, r)
+		}
+	}
+
+	resp := &pb.Message_DiscoverResponse{
+		Status:        pb.Message_OK.Enum(),
+		Registrations: make([]*pb.Message_Register, 0, len(regs)),
+	}
+	last := after
+	for _, r := range regs {
+		ns := r.ns
+		ttl := uint64(r.expiry.Sub(now) / time.Second)
+		resp.Registrations = append(resp.Registrations, &pb.Message_Register{
+			Ns:               &ns,
+			SignedPeerRecord: r.record,
+			Ttl:              &ttl,
+		})
+		last = r.id
+	}
+	resp.Cookie = makeCookie(last, ns)
+	return resp
+}
+
+func (s *Server) removeLocked(r *registration) {
+	regs := s.registrations[r.ns]
+	if regs[r.peer] != r {
+		return
+	}
+	delete(regs, r.peer)
+	r.removed = true
+	s.all.remove()
+	if len(regs) == 0 {
+		delete(s.registrations, r.ns)
+		delete(s.byNamespace, r.ns)
+	} else {
+		s.byNamespace[r.ns].remove()
+	}
+	s.perPeer[r.peer]--
+	if s.perPeer[r.peer] == 0 {
+		delete(s.perPeer, r.peer)
+	}
+	s.total--
+}
+
+// The cookie is the ID of the last registration returned, followed by the namespace.
+// It is only valid for the namespace it was issued for.
+func makeCookie(id uint64, ns string) []byte {
+	b := make([]byte, 8+len(ns))
+	binary.BigEndian.PutUint64(b, id)
+	copy(b[8:], ns)
+	return b
+}
+
+func parseCookie(b []byte, ns string) (uint64, bool) {
+	if len(b) < 8 || !bytes.Equal(b[8:], []byte(ns)) {
+		return 0, false
+	}
+	return binary.BigEndian.Uint64(b), true
+}
@@ -0,0 +1,108 @@
+// Package authz implements the authorization of inbound streams, based on the remote peer,
+// the protocol and the state of the connection.
+//
+// Connection gating decides whether to accept a connection from a peer, before knowing which
+// protocols it is going to use. On nodes serving multiple tenants or exposing administrative
+// protocols, the decision needs to be taken per protocol. Policies are evaluated when an inbound
+// stream is opened, after the protocol was negotiated, and before the stream is passed to its
+// handler. Streams that are denied are reset.
+//
+// Use Middleware to install a policy on a host, or libp2p.Authorization.
+package authz
+
+import (
+	"github.com/AstaFrode/go-libp2p/core/network"
+	"github.com/AstaFrode/go-libp2p/core/peer"
+	"github.com/AstaFrode/go-libp2p/core/protocol"
+	basichost "github.com/AstaFrode/go-libp2p/p2p/host/basic"
+
+	logging "github.com/ipfs/go-log/v2"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+var log = logging.Logger("authz")
+
+// Decision is the outcome of the evaluation of a policy.
+type Decision int
+
+const (
+	// Abstain means that the policy doesn't apply to the request.
+	// Streams for which no policy took a decision are allowed.
+	Abstain Decision = iota
+	// Allow means that the stream is allowed.
+	Allow
+	// Deny means that the stream is denied.
+	Deny
+)
+
+func (d Decision) String() string {
+	switch d {
+	case Abstain:
+		return "abstain"
+	case Allow:
+		return "allow"
+	case Deny:
+		return "deny"
+	default:
+		return "unknown"
+	}
+}
+
+// Request describes an inbound stream to authorize.
+type Request struct {
+	// Peer is the remote peer.
+	Peer peer.ID
+	// Protocol is the protocol negotiated on the stream.
+	Protocol protocol.ID
+	// RemoteAddr is the remote address of the connection.
+	RemoteAddr ma.Multiaddr
+	// Transient is true if the stream was opened on a transient connection, e.g. through a relay.
+	Transient bool
+	// ConnState is the state of the connection, including the metadata attached to it
+	// by the upgrader's interceptors.
+	ConnState network.ConnectionState
+}
+
+func newRequest(s network.Stream) *Request {
+	c := s.Conn()
+	return &Request{
+		Peer:       c.RemotePeer(),
+		Protocol:   s.Protocol(),
+		RemoteAddr: c.RemoteMultiaddr(),
+		Transient:  c.Stat().Transient,
+		ConnState:  c.ConnState(),
+	}
+}
+
+// Policy decides whether an inbound stream is allowed.
+type Policy interface {
+	// Authorize returns the decision for the request, and the reason of the decision,
+	// which is logged when the stream is denied.
+	Authorize(r *Request) (Decision, string)
+}
+
+// PolicyFunc is a Policy implemented by a function.
+type PolicyFunc func(r *Request) (Decision, string)
+
+func (f PolicyFunc) Authorize(r *Request) (Decision, string) {
+	return f(r)
+}
+
+// Middleware returns a stream middleware authorizing inbound streams using policy p.
+// Streams are allowed, unless p denies them.
+//
+// The middleware applies to the streams of all protocols, including the protocols of the
+// services of the host, e.g. identify. Use ForProtocols to restrict a policy to some protocols.
+func Middleware(p Policy) basichost.StreamMiddleware {
+	return func(next network.StreamHandler) network.StreamHandler {
+		return func(s network.Stream) {
+			r := newRequest(s)
+			if d, reason := p.Authorize(r); d == Deny {
+				log.Debugw("denied stream", "peer", r.Peer, "protocol", r.Protocol, "addr", r.RemoteAddr, "reason", reason)
+				s.Reset()
+				return
+			}
+			next(s)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package authz_test
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/AstaFrode/go-libp2p/core/network"
+	"github.com/AstaFrode/go-libp2p/core/peer"
+	"github.com/AstaFrode/go-libp2p/core/protocol"
+	"github.com/AstaFrode/go-libp2p/p2p/host/authz"
+	bhost "github.com/AstaFrode/go-libp2p/p2p/host/basic"
+	swarmt "github.com/AstaFrode/go-libp2p/p2p/net/swarm/testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newHost(t *testing.T, opts *bhost.HostOpts) *bhost.BasicHost {
+	t.Helper()
+	h, err := bhost.NewHost(swarmt.GenSwarm(t), opts)
+	require.NoError(t, err)
+	h.Start()
+	t.Cleanup(func() { h.Close() })
+	h.SetStreamHandler("/public", echo)
+	h.SetStreamHandler("/admin", echo)
+	return h
+}
+
+func echo(s network.Stream) {
+	defer s.Close()
+	io.Copy(s, s)
+}
+
+// request sends a request to h using protocol proto, and returns true if it was answered.
+func request(t *testing.T, from, to *bhost.BasicHost, proto protocol.ID) bool {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, from.Connect(ctx, peer.AddrInfo{ID: to.ID(), Addrs: to.Addrs()}))
+	s, err := from.NewStream(ctx, to.ID(), proto)
+	if err != nil {
+		return false
+	}
+	defer s.Close()
+	if _, err := s.Write([]byte("ping")); err != nil {
+		return false
+	}
+	s.CloseWrite()
+	b, err := io.ReadAll(s)
+	return err == nil && string(b) == "ping"
+}
+
+func TestPerProtocolAllowlist(t *testing.T) {
+	admin := newHost(t, &bhost.HostOpts{})
+	other := newHost(t, &bhost.HostOpts{})
+	server := newHost(t, &bhost.HostOpts{
+		StreamMiddleware: []bhost.StreamMiddleware{
+			authz.Middleware(authz.ForProtocols(authz.Allowlist(admin.ID()), "/admin")),
+		},
+	})
+
+	require.True(t, request(t, admin, server, "/admin"))
+	require.True(t, request(t, admin, server, "/public"))
+	require.False(t, request(t, other, server, "/admin"))
+	require.True(t, request(t, other, server, "/public"))
+}
+
+func TestPolicies(t *testing.T) {
+	p1, p2 := peer.ID("peer1"), peer.ID("peer2")
+
+	p := authz.FirstMatch(
+		authz.Denylist(p2),
+		authz.DenyTransient(),
+		authz.ForProtocols(authz.MetadataToken("token", func(p peer.ID, token string) bool {
+			return token == "secret-"+string(p)
+		}), "/tenant"),
+	)
+	for _, tc := range []struct {
+		name     string
+		req      authz.Request
+		decision authz.Decision
+	}{
+		{name: "no policy applies", req: authz.Request{Peer: p1, Protocol: "/other"}, decision: authz.Abstain},
+		{name: "denylisted", req: authz.Request{Peer: p2, Protocol: "/other"}, decision: authz.Deny},
+		{name: "transient", req: authz.Request{Peer: p1, Protocol: "/other", Transient: true}, decision: authz.Deny},
+		{name: "missing token", req: authz.Request{Peer: p1, Protocol: "/tenant"}, decision: authz.Deny},
+		{
+			name: "invalid token",
+			req: authz.Request{Peer: p1, Protocol: "/tenant",
+				ConnState: network.ConnectionState{Metadata: map[string]string{"token": "secret-peer2"}}},
+			decision: authz.Deny,
+		},
+		{
+			name: "valid token",
+			req: authz.Request{Peer: p1, Protocol: "/tenant",
+				ConnState: network.ConnectionState{Metadata: map[string]string{"token": "secret-peer1"}}},
+			decision: authz.Allow,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			d, reason := p.Authorize(&tc.req)
+			require.Equal(t, tc.decision, d)
+			if d != authz.Abstain {
+				require.NotEmpty(t, reason)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package authz
+
+import (
+	"github.com/AstaFrode/go-libp2p/core/peer"
+	"github.com/AstaFrode/go-libp2p/core/protocol"
+)
+
+// FirstMatch combines policies: it returns the decision of the first policy that doesn't
+// abstain, or Abstain if all of them abstain.
+func FirstMatch(policies ...Policy) Policy {
+	return PolicyFunc(func(r *Request) (Decision, string) {
+		for _, p := range policies {
+			if d, reason := p.Authorize(r); d != Abstain {
+				return d, reason
+			}
+		}
+		return Abstain, ""
+	})
+}
+
+// ForProtocols restricts policy p to the streams of the given protocols.
+// It abstains for the streams of other protocols.
+func ForProtocols(p Policy, protos ...protocol.ID) Policy {
+	set := make(map[protocol.ID]struct{}, len(protos))
+	for _, proto := range protos {
+		set[proto] = struct{}{}
+	}
+	return PolicyFunc(func(r *Request) (Decision, string) {
+		if _, ok := set[r.Protocol]; !ok {
+			return Abstain, ""
+		}
+		return p.Authorize(r)
+	})
+}
+
+// Allowlist allows the streams of the given peers, and denies the streams of all other peers.
+func Allowlist(peers ...peer.ID) Policy {
+	set := make(map[peer.ID]struct{}, len(peers))
+	for _, p := range peers {
+		set[p] = struct{}{}
+	}
+	return PolicyFunc(func(r *Request) (Decision, string) {
+		if _, ok := set[r.Peer]; ok {
+			return Allow, "peer is allowlisted"
+		}
+		return Deny, "peer is not allowlisted"
+	})
+}
+
+// Denylist denies the streams of the given peers, and abstains for all other peers.
+func Denylist(peers ...peer.ID) Policy {
+	set := make(map[peer.ID]struct{}, len(peers))
+	for _, p := range peers {
+		set[p] = struct{}{}
+	}
+	return PolicyFunc(func(r *Request) (Decision, string) {
+		if _, ok := set[r.Peer]; ok {
+			return Deny, "peer is denylisted"
+		}
+		return Abstain, ""
+	})
+}
+
+// DenyTransient denies the streams opened on transient connections, e.g. through a relay.
+// It abstains for the streams opened on other connections.
+func DenyTransient() Policy {
+	return PolicyFunc(func(r *Request) (Decision, string) {
+		if r.Transient {
+			return Deny, "transient connection"
+		}
+		return Abstain, ""
+	})
+}
+
+// MetadataToken authorizes streams using a token attached to the connection by an upgrade
+// interceptor, as the metadata value key. The stream is allowed if valid returns true for the
+// token, and denied otherwise, including when the connection has no token.
+func MetadataToken(key string, valid func(p peer.ID, token string) bool) Policy {
+	return PolicyFunc(func(r *Request) (Decision, string) {
+		token, ok := r.ConnState.Metadata[key]
+		if !ok {
+			return Deny, "missing token"
+		}
+		if !valid(r.Peer, token) {
+			return Deny, "invalid token"
+		}
+		return Allow, "valid token"
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"math/rand"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -11,6 +12,7 @@ import (
 	"github.com/AstaFrode/go-libp2p/core/host"
 	"github.com/AstaFrode/go-libp2p/core/network"
 	"github.com/AstaFrode/go-libp2p/core/peer"
+	"github.com/AstaFrode/go-libp2p/core/peerstore"
 	"github.com/AstaFrode/go-libp2p/p2p/host/eventbus"
 
 	logging "github.com/ipfs/go-log/v2"
@@ -20,6 +22,18 @@ import (
 
 var log = logging.Logger("autonat")
 
+// probeResultTTL is how long we keep the probe results of an address after the last
+// successful probe.
+const probeResultTTL = 24 * time.Hour
+
+// The observations about AutoNAT servers reported to the peerstore.ScoreBook.
+// A server that fails to respond to a dial back request is penalized. Dial errors
+// are valid responses.
+const (
+	scoreDialBackResponse = 1
+	scoreDialBackFailure  = -1
+)
+
 // AmbientAutoNAT is the implementation of ambient NAT autodiscovery
 type AmbientAutoNAT struct {
 	host host.Host
@@ -45,6 +59,9 @@ type AmbientAutoNAT struct {
 	lastProbe    time.Time
 	recentProbes map[peer.ID]time.Time
 
+	probeResultsMx sync.Mutex
+	probeResults   map[string]*ProbeResult // addr bytes -> result
+
 	service *autoNATService
 
 	emitReachabilityChanged event.Emitter
@@ -116,6 +133,7 @@ func New(h host.Host, options ...Option) (AutoNAT, error) {
 		emitReachabilityChanged: emitReachabilityChanged,
 		service:                 service,
 		recentProbes:            make(map[peer.ID]time.Time),
+		probeResults:            make(map[string]*ProbeResult),
 	}
 	as.status.Store(&autoNATResult{network.ReachabilityUnknown, nil})
 
@@ -288,8 +306,54 @@ func (as *AmbientAutoNAT) scheduleProbe() time.Duration {
 	return nextProbe.Sub(fixedNow)
 }
 
+// ProbeResults returns the outcome of the recent probes, for each address that was
+// successfully dialed back.
+func (as *AmbientAutoNAT) ProbeResults() []ProbeResult {
+	as.probeResultsMx.Lock()
+	defer as.probeResultsMx.Unlock()
+
+	results := make([]ProbeResult, 0, len(as.probeResults))
+	for _, r := range as.probeResults {
+		results = append(results, *r)
+	}
+	return results
+}
+
+func (as *AmbientAutoNAT) recordProbeResult(observation autoNATResult) {
+	as.probeResultsMx.Lock()
+	defer as.probeResultsMx.Unlock()
+
+	now := time.Now()
+	switch observation.Reachability {
+	case network.ReachabilityPublic:
+		if observation.address == nil {
+			break
+		}
+		key := string(observation.address.Bytes())
+		r, ok := as.probeResults[key]
+		if !ok {
+			r = &ProbeResult{Addr: observation.address}
+			as.probeResults[key] = r
+		}
+		r.Successes++
+		r.Failures = 0
+		r.LastSuccess = now
+	case network.ReachabilityPrivate:
+		// The dial back failed on all of our addresses.
+		for _, r := range as.probeResults {
+			r.Failures++
+		}
+	}
+	for key, r := range as.probeResults {
+		if now.Sub(r.LastSuccess) > probeResultTTL {
+			delete(as.probeResults, key)
+		}
+	}
+}
+
 // Update the current status based on an observed result.
 func (as *AmbientAutoNAT) recordObservation(observation autoNATResult) {
+	as.recordProbeResult(observation)
 	currentStatus := as.status.Load()
 
 	if observation.Reachability == network.ReachabilityPublic {
@@ -391,6 +455,14 @@ func (as *AmbientAutoNAT) probe(pi *peer.AddrInfo) {
 		result.Reachability = network.ReachabilityUnknown
 	}
 
+	if sb, ok := peerstore.GetScoreBook(as.host.Peerstore()); ok {
+		if result.Reachability == network.ReachabilityUnknown {
+			sb.RecordObservation(pi.ID, peerstore.ScoreSourceAutoNAT, scoreDialBackFailure)
+		} else {
+			sb.RecordObservation(pi.ID, peerstore.ScoreSourceAutoNAT, scoreDialBackResponse)
+		}
+	}
+
 	select {
 	case as.observations <- result:
 	case <-as.ctx.Done():
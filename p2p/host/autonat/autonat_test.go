@@ -252,6 +252,37 @@ func TestAutoNATObservationRecording(t *testing.T) {
 	}
 }
 
+func TestAutoNATProbeResults(t *testing.T) {
+	hs := makeAutoNATServicePublic(t)
+	defer hs.Close()
+	hc, ani := makeAutoNAT(t, hs)
+	defer hc.Close()
+	defer ani.Close()
+	require.Implements(t, (*ProbeResultsProvider)(nil), ani)
+	an := ani.(*AmbientAutoNAT)
+	require.Empty(t, an.ProbeResults())
+
+	addr := ma.StringCast("/ip4/1.2.3.4/udp/1234")
+	an.recordObservation(autoNATResult{network.ReachabilityPublic, addr})
+	an.recordObservation(autoNATResult{network.ReachabilityPublic, addr})
+	an.recordObservation(autoNATResult{network.ReachabilityPrivate, nil})
+	an.recordObservation(autoNATResult{network.ReachabilityUnknown, nil})
+
+	results := an.ProbeResults()
+	require.Len(t, results, 1)
+	require.True(t, results[0].Addr.Equal(addr))
+	require.Equal(t, 2, results[0].Successes)
+	require.Equal(t, 1, results[0].Failures)
+	require.WithinDuration(t, time.Now(), results[0].LastSuccess, time.Second)
+
+	// a success resets the failures
+	an.recordObservation(autoNATResult{network.ReachabilityPublic, addr})
+	results = an.ProbeResults()
+	require.Len(t, results, 1)
+	require.Equal(t, 3, results[0].Successes)
+	require.Zero(t, results[0].Failures)
+}
+
 func TestStaticNat(t *testing.T) {
 	_, cancel := context.WithCancel(context.Background())
 	defer cancel()
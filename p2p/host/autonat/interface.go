@@ -3,6 +3,7 @@ package autonat
 import (
 	"context"
 	"io"
+	"time"
 
 	"github.com/AstaFrode/go-libp2p/core/network"
 	"github.com/AstaFrode/go-libp2p/core/peer"
@@ -20,6 +21,26 @@ type AutoNAT interface {
 	io.Closer
 }
 
+// ProbeResultsProvider is implemented by the AutoNATs that probe the reachability
+// of the addresses, like the AutoNAT returned by New.
+type ProbeResultsProvider interface {
+	// ProbeResults returns the outcome of the recent probes, for each address that
+	// was successfully dialed back.
+	ProbeResults() []ProbeResult
+}
+
+// ProbeResult summarizes the outcome of the AutoNAT probes for an address.
+type ProbeResult struct {
+	Addr ma.Multiaddr
+	// Successes is the number of probes that successfully dialed back the address.
+	Successes int
+	// Failures is the number of probes that failed to dial back any of our
+	// addresses since the last success for this address.
+	Failures int
+	// LastSuccess is the time of the last successful probe.
+	LastSuccess time.Time
+}
+
 // Client is a stateless client interface to AutoNAT peers
 type Client interface {
 	// DialBack requests from a peer providing AutoNAT services to test dial back
@@ -3,6 +3,7 @@ package autorelay
 import (
 	"context"
 	"sync"
+	"time"
 
 	"github.com/AstaFrode/go-libp2p/core/event"
 	"github.com/AstaFrode/go-libp2p/core/host"
@@ -10,6 +11,7 @@ import (
 	basic "github.com/AstaFrode/go-libp2p/p2p/host/basic"
 	"github.com/AstaFrode/go-libp2p/p2p/host/eventbus"
 
+	"github.com/benbjohnson/clock"
 	logging "github.com/ipfs/go-log/v2"
 	ma "github.com/multiformats/go-multiaddr"
 )
@@ -68,6 +70,17 @@ func (r *AutoRelay) background() {
 	}
 	defer subReachability.Close()
 
+	// releaseTimer is running while we're publicly reachable, until the reservations are released
+	var releaseTimer *clock.Timer
+	var releaseTimerC <-chan time.Time
+	stopReleaseTimer := func() {
+		if releaseTimer != nil {
+			releaseTimer.Stop()
+		}
+		releaseTimer, releaseTimerC = nil, nil
+	}
+	defer stopReleaseTimer()
+
 	for {
 		select {
 		case <-r.ctx.Done():
@@ -76,19 +89,31 @@ func (r *AutoRelay) background() {
 			if !ok {
 				return
 			}
-			// TODO: push changed addresses
 			evt := ev.(event.EvtLocalReachabilityChanged)
 			switch evt.Reachability {
 			case network.ReachabilityPrivate, network.ReachabilityUnknown:
+				stopReleaseTimer()
 				if err := r.relayFinder.Start(); err != nil {
 					log.Errorw("failed to start relay finder", "error", err)
 				}
 			case network.ReachabilityPublic:
 				r.relayFinder.Stop()
+				if r.conf.releaseDelay >= 0 && releaseTimer == nil {
+					releaseTimer = r.conf.clock.Timer(r.conf.releaseDelay)
+					releaseTimerC = releaseTimer.C
+				}
 			}
 			r.mx.Lock()
+			changed := r.status != evt.Reachability
 			r.status = evt.Reachability
 			r.mx.Unlock()
+			if changed {
+				// relay addresses are only advertised when we're private
+				r.relayFinder.clearCachedAddrsAndSignalAddressChange()
+			}
+		case <-releaseTimerC:
+			releaseTimer, releaseTimerC = nil, nil
+			r.relayFinder.releaseReservations()
 		}
 	}
 }
@@ -2,16 +2,19 @@ package autorelay_test
 
 import (
 	"context"
+	"io"
 	"strings"
 	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/AstaFrode/go-libp2p"
+	"github.com/AstaFrode/go-libp2p/core/event"
 	"github.com/AstaFrode/go-libp2p/core/host"
 	"github.com/AstaFrode/go-libp2p/core/network"
 	"github.com/AstaFrode/go-libp2p/core/peer"
 	"github.com/AstaFrode/go-libp2p/p2p/host/autorelay"
+	"github.com/AstaFrode/go-libp2p/p2p/host/eventbus"
 	circuitv2_proto "github.com/AstaFrode/go-libp2p/p2p/protocol/circuitv2/proto"
 
 	"github.com/benbjohnson/clock"
@@ -450,3 +453,133 @@ func TestMinInterval(t *testing.T) {
 	cl.Add(500 * time.Millisecond)
 	require.Eventually(t, func() bool { return numRelays(h) > 0 }, 10*time.Second, 100*time.Millisecond)
 }
+
+func TestManageReservations(t *testing.T) {
+	r1 := newRelay(t)
+	t.Cleanup(func() { r1.Close() })
+	r2 := newRelay(t)
+	t.Cleanup(func() { r2.Close() })
+
+	h := newPrivateNodeWithStaticRelays(t,
+		[]peer.AddrInfo{{ID: r1.ID(), Addrs: r1.Addrs()}},
+		autorelay.WithNumRelays(1),
+		autorelay.WithBootDelay(0),
+	)
+	defer h.Close()
+	ar := h.(*autorelay.AutoRelayHost).AutoRelay()
+
+	require.Eventually(t, func() bool { return numRelays(h) > 0 }, 10*time.Second, 50*time.Millisecond)
+	rsvps := ar.Reservations()
+	require.Len(t, rsvps, 1)
+	rsvp, ok := rsvps[r1.ID()]
+	require.True(t, ok)
+	require.NotNil(t, rsvp.Voucher)
+	require.True(t, rsvp.Expiration.After(time.Now()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	require.NoError(t, ar.RefreshReservations(ctx))
+	require.False(t, ar.Reservations()[r1.ID()].Expiration.Before(rsvp.Expiration))
+
+	// add a relay that's not a static relay
+	require.NoError(t, ar.AddRelay(ctx, peer.AddrInfo{ID: r2.ID(), Addrs: r2.Addrs()}))
+	require.Len(t, ar.Reservations(), 2)
+	require.Eventually(t, func() bool { return numRelays(h) == 2 }, 5*time.Second, 50*time.Millisecond)
+
+	require.NoError(t, ar.DropRelay(r1.ID()))
+	require.ErrorIs(t, ar.DropRelay(r1.ID()), autorelay.ErrNotUsingRelay)
+	require.Len(t, ar.Reservations(), 1)
+	require.Contains(t, ar.Reservations(), r2.ID())
+	require.Eventually(t, func() bool {
+		relays := usedRelays(h)
+		return len(relays) == 1 && relays[0] == r2.ID()
+	}, 5*time.Second, 50*time.Millisecond)
+}
+
+func TestReleaseReservationsWhenPublic(t *testing.T) {
+	r := newRelay(t)
+	t.Cleanup(func() { r.Close() })
+
+	h := newPrivateNodeWithStaticRelays(t,
+		[]peer.AddrInfo{{ID: r.ID(), Addrs: r.Addrs()}},
+		autorelay.WithNumRelays(1),
+		autorelay.WithBootDelay(0),
+		autorelay.WithReleaseDelay(0),
+	)
+	defer h.Close()
+	ar := h.(*autorelay.AutoRelayHost).AutoRelay()
+	require.Eventually(t, func() bool { return numRelays(h) > 0 }, 10*time.Second, 50*time.Millisecond)
+
+	emitter, err := h.EventBus().Emitter(new(event.EvtLocalReachabilityChanged), eventbus.Stateful)
+	require.NoError(t, err)
+	defer emitter.Close()
+
+	// relay addresses are withdrawn and the reservation is released once we're public
+	require.NoError(t, emitter.Emit(event.EvtLocalReachabilityChanged{Reachability: network.ReachabilityPublic}))
+	require.Eventually(t, func() bool { return numRelays(h) == 0 }, 5*time.Second, 50*time.Millisecond)
+	require.Eventually(t, func() bool { return len(ar.Reservations()) == 0 }, 5*time.Second, 50*time.Millisecond)
+	require.Eventually(t, func() bool { return h.Network().Connectedness(r.ID()) != network.Connected }, 5*time.Second, 50*time.Millisecond)
+
+	// and acquired again once we're private
+	require.NoError(t, emitter.Emit(event.EvtLocalReachabilityChanged{Reachability: network.ReachabilityPrivate}))
+	require.Eventually(t, func() bool { return numRelays(h) > 0 }, 10*time.Second, 50*time.Millisecond)
+	require.Contains(t, ar.Reservations(), r.ID())
+}
+
+func TestReleaseReservationsKeepsConnsInUse(t *testing.T) {
+	r := newRelay(t)
+	t.Cleanup(func() { r.Close() })
+	r.SetStreamHandler("/app", func(s network.Stream) { io.Copy(s, s) })
+
+	h := newPrivateNodeWithStaticRelays(t,
+		[]peer.AddrInfo{{ID: r.ID(), Addrs: r.Addrs()}},
+		autorelay.WithNumRelays(1),
+		autorelay.WithBootDelay(0),
+		autorelay.WithReleaseDelay(0),
+	)
+	defer h.Close()
+	ar := h.(*autorelay.AutoRelayHost).AutoRelay()
+	require.Eventually(t, func() bool { return numRelays(h) > 0 }, 10*time.Second, 50*time.Millisecond)
+	str, err := h.NewStream(context.Background(), r.ID(), "/app")
+	require.NoError(t, err)
+	defer str.Close()
+
+	emitter, err := h.EventBus().Emitter(new(event.EvtLocalReachabilityChanged), eventbus.Stateful)
+	require.NoError(t, err)
+	defer emitter.Close()
+
+	// the reservation is released, but the connection carrying the application stream is kept
+	require.NoError(t, emitter.Emit(event.EvtLocalReachabilityChanged{Reachability: network.ReachabilityPublic}))
+	require.Eventually(t, func() bool { return len(ar.Reservations()) == 0 }, 5*time.Second, 50*time.Millisecond)
+	_, err = str.Write([]byte("foobar"))
+	require.NoError(t, err)
+	b := make([]byte, 6)
+	_, err = io.ReadFull(str, b)
+	require.NoError(t, err)
+	require.Equal(t, "foobar", string(b))
+	require.Equal(t, network.Connected, h.Network().Connectedness(r.ID()))
+}
+
+func TestKeepReservationsWhenPublic(t *testing.T) {
+	r := newRelay(t)
+	t.Cleanup(func() { r.Close() })
+
+	h := newPrivateNodeWithStaticRelays(t,
+		[]peer.AddrInfo{{ID: r.ID(), Addrs: r.Addrs()}},
+		autorelay.WithNumRelays(1),
+		autorelay.WithBootDelay(0),
+		autorelay.WithReleaseDelay(-1),
+	)
+	defer h.Close()
+	ar := h.(*autorelay.AutoRelayHost).AutoRelay()
+	require.Eventually(t, func() bool { return numRelays(h) > 0 }, 10*time.Second, 50*time.Millisecond)
+
+	emitter, err := h.EventBus().Emitter(new(event.EvtLocalReachabilityChanged), eventbus.Stateful)
+	require.NoError(t, err)
+	defer emitter.Close()
+
+	require.NoError(t, emitter.Emit(event.EvtLocalReachabilityChanged{Reachability: network.ReachabilityPublic}))
+	require.Eventually(t, func() bool { return numRelays(h) == 0 }, 5*time.Second, 50*time.Millisecond)
+	require.Never(t, func() bool { return len(ar.Reservations()) == 0 }, 200*time.Millisecond, 50*time.Millisecond)
+	require.Equal(t, network.Connected, h.Network().Connectedness(r.ID()))
+}
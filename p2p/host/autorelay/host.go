@@ -18,6 +18,12 @@ func (h *AutoRelayHost) Start() {
 	h.ar.Start()
 }
 
+// AutoRelay returns the AutoRelay used by the host. It can be used to inspect and
+// manage the reservations with relays.
+func (h *AutoRelayHost) AutoRelay() *AutoRelay {
+	return h.ar
+}
+
 func NewAutoRelayHost(h host.Host, ar *AutoRelay) *AutoRelayHost {
 	return &AutoRelayHost{Host: h, ar: ar}
 }
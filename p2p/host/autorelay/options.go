@@ -42,6 +42,8 @@ type config struct {
 	// see WithMaxCandidateAge
 	maxCandidateAge  time.Duration
 	setMinCandidates bool
+	// see WithReleaseDelay
+	releaseDelay time.Duration
 }
 
 var defaultConfig = config{
@@ -53,6 +55,7 @@ var defaultConfig = config{
 	desiredRelays:   2,
 	maxCandidateAge: 30 * time.Minute,
 	minInterval:     30 * time.Second,
+	releaseDelay:    time.Minute,
 }
 
 var (
@@ -177,3 +180,18 @@ func WithMinInterval(interval time.Duration) Option {
 		return nil
 	}
 }
+
+// WithReleaseDelay sets how long the node has to be publicly reachable before AutoRelay releases
+// its reservations. Relay addresses are withdrawn as soon as the node becomes publicly reachable,
+// but the reservations are kept for this duration, so that they can be used again if the
+// reachability changes back quickly. Reservations are released by closing the connections to
+// the relays, which frees the resources the relays allocated for them. They are acquired again
+// once the node stops being publicly reachable.
+// A negative duration disables the release: the reservations are kept until they expire.
+// Default: 1 minute.
+func WithReleaseDelay(d time.Duration) Option {
+	return func(c *config) error {
+		c.releaseDelay = d
+		return nil
+	}
+}
@@ -0,0 +1,174 @@
+package autorelay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/AstaFrode/go-libp2p/core/network"
+	"github.com/AstaFrode/go-libp2p/core/peer"
+	circuitv2 "github.com/AstaFrode/go-libp2p/p2p/protocol/circuitv2/client"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// ErrNotUsingRelay is returned when dropping a relay we don't hold a reservation with.
+var ErrNotUsingRelay = errors.New("no reservation with relay")
+
+// Reservations returns the reservations we currently hold with relays.
+// The returned reservations are copies, modifying them has no effect.
+func (r *AutoRelay) Reservations() map[peer.ID]circuitv2.Reservation {
+	return r.relayFinder.reservations()
+}
+
+// RefreshReservations refreshes all reservations now, instead of waiting for them
+// to be close to their expiration.
+// Relays that refuse to refresh the reservation are dropped, and the error is returned.
+func (r *AutoRelay) RefreshReservations(ctx context.Context) error {
+	return r.relayFinder.refreshAllReservations(ctx)
+}
+
+// DropRelay stops using the given relay: its addresses are no longer announced,
+// and the reservation is not refreshed anymore. The reservation is not canceled
+// on the relay, it expires eventually.
+// The relay is backed off, and a new relay is selected among the candidates, if needed.
+func (r *AutoRelay) DropRelay(p peer.ID) error {
+	return r.relayFinder.dropRelay(p)
+}
+
+// AddRelay connects to the given relay and reserves a slot, without waiting for the
+// relay to be selected among the candidates. Once added, the reservation is treated
+// like the reservations with automatically selected relays: it's refreshed before
+// expiring, and dropped if we get disconnected from the relay.
+// Manually added relays count towards the number of relays configured using WithNumRelays.
+func (r *AutoRelay) AddRelay(ctx context.Context, pi peer.AddrInfo) error {
+	return r.relayFinder.addRelay(ctx, pi)
+}
+
+func (rf *relayFinder) reservations() map[peer.ID]circuitv2.Reservation {
+	rf.relayMx.Lock()
+	defer rf.relayMx.Unlock()
+
+	rsvps := make(map[peer.ID]circuitv2.Reservation, len(rf.relays))
+	for p, rsvp := range rf.relays {
+		rsvps[p] = *rsvp
+	}
+	return rsvps
+}
+
+func (rf *relayFinder) refreshAllReservations(ctx context.Context) error {
+	rf.relayMx.Lock()
+	g := new(errgroup.Group)
+	for p := range rf.relays {
+		p := p
+		g.Go(func() error { return rf.refreshRelayReservation(ctx, p) })
+	}
+	rf.relayMx.Unlock()
+
+	err := g.Wait()
+	if err != nil {
+		rf.clearCachedAddrsAndSignalAddressChange()
+		rf.notifyMaybeConnectToRelay()
+	}
+	return err
+}
+
+func (rf *relayFinder) dropRelay(p peer.ID) error {
+	rf.relayMx.Lock()
+	if !rf.usingRelay(p) {
+		rf.relayMx.Unlock()
+		return ErrNotUsingRelay
+	}
+	delete(rf.relays, p)
+	rf.relayMx.Unlock()
+	rf.host.ConnManager().Unprotect(p, autorelayTag)
+
+	// don't immediately select the relay again
+	rf.candidateMx.Lock()
+	rf.backoff[p] = rf.conf.clock.Now()
+	delete(rf.candidates, p)
+	rf.candidateMx.Unlock()
+
+	log.Debugw("dropped relay", "id", p)
+	rf.clearCachedAddrsAndSignalAddressChange()
+	rf.notifyMaybeConnectToRelay()
+	rf.notifyMaybeNeedNewCandidates()
+	return nil
+}
+
+func (rf *relayFinder) addRelay(ctx context.Context, pi peer.AddrInfo) error {
+	rf.relayMx.Lock()
+	usingRelay := rf.usingRelay(pi.ID)
+	rf.relayMx.Unlock()
+	if usingRelay {
+		return nil
+	}
+
+	rsvp, err := circuitv2.Reserve(ctx, rf.host, pi)
+	if err != nil {
+		return fmt.Errorf("failed to reserve slot: %w", err)
+	}
+	log.Debugw("adding new relay", "id", pi.ID)
+	rf.relayMx.Lock()
+	rf.relays[pi.ID] = rsvp
+	rf.relayMx.Unlock()
+	rf.host.ConnManager().Protect(pi.ID, autorelayTag)
+
+	rf.clearCachedAddrsAndSignalAddressChange()
+	return nil
+}
+
+// releaseReservations drops all reservations, and closes the connections to the relays that
+// aren't used for anything else, so that they free the resources allocated for the reservations. The relays are kept as
+// candidates, so that we can reserve a slot with them again when we need to.
+// It must only be called while the relay finder is stopped.
+func (rf *relayFinder) releaseReservations() {
+	rf.relayMx.Lock()
+	relays := make([]peer.ID, 0, len(rf.relays))
+	for p := range rf.relays {
+		relays = append(relays, p)
+	}
+	rf.relays = make(map[peer.ID]*circuitv2.Reservation)
+	rf.cachedAddrs = nil
+	rf.relayMx.Unlock()
+	if len(relays) == 0 {
+		return
+	}
+
+	now := rf.conf.clock.Now()
+	rf.candidateMx.Lock()
+	for _, p := range relays {
+		rf.candidates[p] = &candidate{
+			added:           now,
+			supportsRelayV2: true,
+			ai:              peer.AddrInfo{ID: p, Addrs: rf.host.Peerstore().Addrs(p)},
+		}
+	}
+	rf.candidateMx.Unlock()
+
+	for _, p := range relays {
+		log.Debugw("releasing relay reservation", "id", p)
+		rf.host.ConnManager().Unprotect(p, autorelayTag)
+		for _, c := range rf.host.Network().ConnsToPeer(p) {
+			if !onlyReservationStreams(c) {
+				// The connection is used for other purposes. The relay frees
+				// the reservation once it expires.
+				continue
+			}
+			if err := c.Close(); err != nil {
+				log.Debugw("failed to close connection to relay", "id", p, "error", err)
+			}
+		}
+	}
+	rf.host.SignalAddressChange()
+}
+
+// onlyReservationStreams says if c only carries the streams used to reserve slots with the relay.
+func onlyReservationStreams(c network.Conn) bool {
+	for _, s := range c.GetStreams() {
+		if s.Protocol() != protoIDv2 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,104 @@
+package basichost
+
+import (
+	"time"
+
+	"github.com/AstaFrode/go-libp2p/p2p/host/autonat"
+	"github.com/AstaFrode/go-libp2p/p2p/protocol/identify"
+
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// autonatSuccessesForFullConfidence is the number of successful AutoNAT probes after
+// which the AutoNAT evidence for an address reaches its full weight.
+const autonatSuccessesForFullConfidence = 3
+
+// AddrConfidence is the confidence that an address advertised by the host is reachable
+// by other peers, with the evidence it's based on.
+type AddrConfidence struct {
+	Addr ma.Multiaddr
+	// Score is between 0 (no evidence that the address is reachable) and 1.
+	// Half of it comes from the observations of our peers, the other half from the
+	// AutoNAT probes.
+	Score float64
+
+	// Observers is the number of distinct peers that recently observed the address,
+	// and InboundObservers the number of those that dialed us.
+	Observers        int
+	InboundObservers int
+	// AutoNATSuccesses is the number of AutoNAT probes that dialed back the address,
+	// and AutoNATFailures the number of failed probes since the last success.
+	AutoNATSuccesses int
+	AutoNATFailures  int
+
+	// FirstSeen is the time the address was first observed by our peers. It's zero if
+	// the address wasn't observed.
+	FirstSeen time.Time
+	// LastSeen is the time the address was last observed by our peers or dialed back
+	// by an AutoNAT probe, whichever is the latest.
+	LastSeen time.Time
+}
+
+// AddrConfidence returns the confidence that each address returned by Addrs is
+// reachable by other peers. Applications can use it to decide which addresses
+// are worth announcing, e.g. in provider records.
+func (h *BasicHost) AddrConfidence() []AddrConfidence {
+	var observed []identify.ObservedAddrInfo
+	if h.ids != nil {
+		observed = h.ids.OwnObservedAddrInfos()
+	}
+	var probes []autonat.ProbeResult
+	if pr, ok := h.GetAutoNat().(autonat.ProbeResultsProvider); ok {
+		probes = pr.ProbeResults()
+	}
+
+	addrs := h.Addrs()
+	confidence := make([]AddrConfidence, 0, len(addrs))
+	for _, a := range addrs {
+		c := AddrConfidence{Addr: a}
+		for _, o := range observed {
+			if o.Addr.Equal(a) {
+				c.Observers = o.Observers
+				c.InboundObservers = o.InboundObservers
+				c.FirstSeen = o.FirstSeen
+				c.LastSeen = o.LastSeen
+				break
+			}
+		}
+		for _, p := range probes {
+			if p.Addr.Equal(a) {
+				c.AutoNATSuccesses = p.Successes
+				c.AutoNATFailures = p.Failures
+				if p.LastSuccess.After(c.LastSeen) {
+					c.LastSeen = p.LastSuccess
+				}
+				break
+			}
+		}
+		c.Score = addrConfidenceScore(c)
+		confidence = append(confidence, c)
+	}
+	return confidence
+}
+
+func addrConfidenceScore(c AddrConfidence) float64 {
+	// Observations count fully once the address is observed by enough peers to be
+	// advertised. Being dialed by one of them is a strong hint that the address is
+	// reachable, as long as it's not behind a symmetric NAT.
+	observers := float64(c.Observers) / float64(identify.ActivationThresh)
+	if observers > 1 {
+		observers = 1
+	}
+	score := 0.4 * observers
+	if c.InboundObservers > 0 {
+		score += 0.1
+	}
+
+	// Failed probes since the last success make the AutoNAT evidence less relevant.
+	probes := float64(c.AutoNATSuccesses) / autonatSuccessesForFullConfidence
+	if probes > 1 {
+		probes = 1
+	}
+	score += 0.5 * probes / float64(1+c.AutoNATFailures)
+	return score
+}
@@ -0,0 +1,56 @@
+package basichost
+
+import (
+	"testing"
+	"time"
+
+	"github.com/AstaFrode/go-libp2p/core/network"
+	"github.com/AstaFrode/go-libp2p/p2p/host/autonat"
+	swarmt "github.com/AstaFrode/go-libp2p/p2p/net/swarm/testing"
+
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/require"
+)
+
+type probingAutoNAT struct {
+	results []autonat.ProbeResult
+}
+
+func (a *probingAutoNAT) Status() network.Reachability        { return network.ReachabilityPublic }
+func (a *probingAutoNAT) PublicAddr() (ma.Multiaddr, error)   { return nil, nil }
+func (a *probingAutoNAT) ProbeResults() []autonat.ProbeResult { return a.results }
+func (a *probingAutoNAT) Close() error                        { return nil }
+
+func TestAddrConfidence(t *testing.T) {
+	confirmed := ma.StringCast("/ip4/1.2.3.4/tcp/1234")
+	unconfirmed := ma.StringCast("/ip4/1.2.3.4/tcp/1235")
+	h, err := NewHost(swarmt.GenSwarm(t), &HostOpts{
+		AddrsFactory: func([]ma.Multiaddr) []ma.Multiaddr { return []ma.Multiaddr{confirmed, unconfirmed} },
+	})
+	require.NoError(t, err)
+	defer h.Close()
+
+	now := time.Now()
+	h.SetAutoNat(&probingAutoNAT{results: []autonat.ProbeResult{
+		{Addr: confirmed, Successes: 4, LastSuccess: now},
+	}})
+
+	confidence := h.AddrConfidence()
+	require.Len(t, confidence, 2)
+	require.True(t, confidence[0].Addr.Equal(confirmed))
+	require.Equal(t, 4, confidence[0].AutoNATSuccesses)
+	require.Equal(t, now, confidence[0].LastSeen)
+	require.Equal(t, 0.5, confidence[0].Score)
+	require.True(t, confidence[1].Addr.Equal(unconfirmed))
+	require.Zero(t, confidence[1].Score)
+	require.True(t, confidence[1].LastSeen.IsZero())
+}
+
+func TestAddrConfidenceScore(t *testing.T) {
+	require.Zero(t, addrConfidenceScore(AddrConfidence{}))
+	require.Equal(t, 0.2, addrConfidenceScore(AddrConfidence{Observers: 2}))
+	require.Equal(t, 0.5, addrConfidenceScore(AddrConfidence{Observers: 10, InboundObservers: 1}))
+	require.Equal(t, 1.0, addrConfidenceScore(AddrConfidence{Observers: 4, InboundObservers: 2, AutoNATSuccesses: 3}))
+	// failures since the last success reduce the AutoNAT evidence
+	require.Equal(t, 0.25, addrConfidenceScore(AddrConfidence{AutoNATSuccesses: 3, AutoNATFailures: 1}))
+}
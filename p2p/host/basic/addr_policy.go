@@ -0,0 +1,164 @@
+package basichost
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/AstaFrode/go-libp2p/core/network"
+
+	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+// AddrClass is the class of an address, as used by AddrPolicy.
+type AddrClass int
+
+const (
+	// AddrClassPublic is the class of non-relayed, publicly routable addresses.
+	AddrClassPublic AddrClass = iota
+	// AddrClassPrivate is the class of non-relayed addresses that are not publicly
+	// routable, e.g. LAN and loopback addresses.
+	AddrClassPrivate
+	// AddrClassRelay is the class of relayed (p2p-circuit) addresses.
+	AddrClassRelay
+)
+
+func (c AddrClass) String() string {
+	switch c {
+	case AddrClassPublic:
+		return "public"
+	case AddrClassPrivate:
+		return "private"
+	case AddrClassRelay:
+		return "relay"
+	default:
+		return "unknown"
+	}
+}
+
+// ClassifyAddr returns the class of the address.
+func ClassifyAddr(a ma.Multiaddr) AddrClass {
+	if _, err := a.ValueForProtocol(ma.P_CIRCUIT); err == nil {
+		return AddrClassRelay
+	}
+	if manet.IsPublicAddr(a) {
+		return AddrClassPublic
+	}
+	return AddrClassPrivate
+}
+
+// AddrPolicy controls which of the host's addresses are advertised.
+// It is applied to the output of the AddrsFactory. Filter and TTL are only called when
+// the host's addresses change, so they must not depend on anything else.
+type AddrPolicy struct {
+	// Filter, if set, is called for every address. Returning false prevents the address
+	// from being advertised.
+	Filter func(a ma.Multiaddr, class AddrClass) bool
+	// SuppressPrivate prevents private addresses from being advertised once a public
+	// address is confirmed, i.e. when AutoNAT determined that the host is publicly
+	// reachable, and the host has at least one public address.
+	SuppressPrivate bool
+	// TTL, if set, returns how long the address is kept in the host's own peerstore
+	// entry, counting from the last change of the host's addresses. This entry backs the
+	// host's signed peer record: once all its addresses expired, the record is no longer
+	// handed out (e.g. by identify or rendezvous). It doesn't affect the addresses
+	// returned by Addrs.
+	// A TTL of 0 means that the address is kept as long as the host has it.
+	TTL func(a ma.Multiaddr, class AddrClass) time.Duration
+}
+
+type addrPolicyState struct {
+	policy atomic.Pointer[AddrPolicy]
+	// result of the last application of the policy
+	cache atomic.Pointer[addrPolicyResult]
+}
+
+type addrPolicyResult struct {
+	policy          *AddrPolicy
+	suppressPrivate bool
+	in, out         []ma.Multiaddr
+}
+
+// SetAddrPolicy sets the policy controlling which addresses are advertised.
+// A nil policy advertises all addresses returned by the AddrsFactory.
+//
+// If the set of advertised addresses changes, an EvtLocalAddressesUpdated is emitted,
+// and the new addresses are pushed to connected peers using identify push.
+func (h *BasicHost) SetAddrPolicy(p *AddrPolicy) {
+	h.addrPolicy.policy.Store(p)
+	h.SignalAddressChange()
+}
+
+// AddrPolicy returns the policy controlling which addresses are advertised, if any.
+func (h *BasicHost) AddrPolicy() *AddrPolicy {
+	return h.addrPolicy.policy.Load()
+}
+
+func (h *BasicHost) applyAddrPolicy(addrs []ma.Multiaddr) []ma.Multiaddr {
+	p := h.addrPolicy.policy.Load()
+	if p == nil {
+		return addrs
+	}
+
+	var suppressPrivate bool
+	if p.SuppressPrivate && h.publiclyReachable() {
+		for _, a := range addrs {
+			if ClassifyAddr(a) == AddrClassPublic {
+				suppressPrivate = true
+				break
+			}
+		}
+	}
+
+	// The policy is only applied again if the addresses changed.
+	if c := h.addrPolicy.cache.Load(); c != nil && c.policy == p && c.suppressPrivate == suppressPrivate && equalAddrs(c.in, addrs) {
+		return c.out[:len(c.out):len(c.out)]
+	}
+
+	out := make([]ma.Multiaddr, 0, len(addrs))
+	for _, a := range addrs {
+		class := ClassifyAddr(a)
+		if suppressPrivate && class == AddrClassPrivate {
+			continue
+		}
+		if p.Filter != nil && !p.Filter(a, class) {
+			continue
+		}
+		out = append(out, a)
+	}
+	h.addrPolicy.cache.Store(&addrPolicyResult{policy: p, suppressPrivate: suppressPrivate, in: addrs, out: out})
+	return out[:len(out):len(out)]
+}
+
+// applyAddrTTLs sets the TTL of the advertised addresses in the peerstore,
+// as configured by the address policy.
+func (h *BasicHost) applyAddrTTLs(addrs []ma.Multiaddr) {
+	p := h.addrPolicy.policy.Load()
+	if p == nil || p.TTL == nil {
+		return
+	}
+	for _, a := range addrs {
+		if ttl := p.TTL(a, ClassifyAddr(a)); ttl > 0 {
+			h.Peerstore().SetAddr(h.ID(), a, ttl)
+		}
+	}
+}
+
+func equalAddrs(a, b []ma.Multiaddr) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].Equal(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (h *BasicHost) publiclyReachable() bool {
+	h.addrMu.RLock()
+	autonat := h.autoNat
+	h.addrMu.RUnlock()
+	return autonat != nil && autonat.Status() == network.ReachabilityPublic
+}
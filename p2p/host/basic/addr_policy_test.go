@@ -0,0 +1,125 @@
+package basichost
+
+import (
+	"testing"
+	"time"
+
+	"github.com/AstaFrode/go-libp2p/core/event"
+	"github.com/AstaFrode/go-libp2p/core/network"
+	"github.com/AstaFrode/go-libp2p/p2p/host/autonat"
+	swarmt "github.com/AstaFrode/go-libp2p/p2p/net/swarm/testing"
+
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassifyAddr(t *testing.T) {
+	require.Equal(t, AddrClassPublic, ClassifyAddr(ma.StringCast("/ip4/1.2.3.4/tcp/1")))
+	require.Equal(t, AddrClassPrivate, ClassifyAddr(ma.StringCast("/ip4/192.168.1.1/tcp/1")))
+	require.Equal(t, AddrClassPrivate, ClassifyAddr(ma.StringCast("/ip4/127.0.0.1/udp/1/quic-v1")))
+	require.Equal(t, AddrClassRelay, ClassifyAddr(ma.StringCast("/ip4/1.2.3.4/tcp/1/p2p/QmcgpsyWgH8Y8ajJz1Cu72KnS5uo2Aa2LpzU7kinSupNKC/p2p-circuit")))
+}
+
+func TestAddrPolicy(t *testing.T) {
+	public := ma.StringCast("/ip4/1.2.3.4/tcp/1")
+	private := ma.StringCast("/ip4/192.168.1.1/tcp/1")
+	relay := ma.StringCast("/ip4/1.2.3.4/tcp/1/p2p/QmcgpsyWgH8Y8ajJz1Cu72KnS5uo2Aa2LpzU7kinSupNKC/p2p-circuit")
+
+	h, err := NewHost(swarmt.GenSwarm(t), &HostOpts{
+		AddrsFactory: func([]ma.Multiaddr) []ma.Multiaddr { return []ma.Multiaddr{public, private, relay} },
+		AddrPolicy: &AddrPolicy{
+			Filter: func(_ ma.Multiaddr, class AddrClass) bool { return class != AddrClassRelay },
+		},
+	})
+	require.NoError(t, err)
+	defer h.Close()
+	require.Equal(t, []ma.Multiaddr{public, private}, h.Addrs())
+
+	sub, err := h.EventBus().Subscribe(&event.EvtLocalAddressesUpdated{})
+	require.NoError(t, err)
+	defer sub.Close()
+	h.Start()
+	waitForAddrs := func(t *testing.T, expected ...ma.Multiaddr) {
+		t.Helper()
+		for {
+			select {
+			case e := <-sub.Out():
+				evt := e.(event.EvtLocalAddressesUpdated)
+				current := make([]ma.Multiaddr, 0, len(evt.Current))
+				for _, u := range evt.Current {
+					current = append(current, u.Address)
+				}
+				if ma.Join(current...).Equal(ma.Join(expected...)) {
+					return
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("expected addresses to be updated to %s", expected)
+			}
+		}
+	}
+	waitForAddrs(t, public, private)
+
+	t.Run("runtime update", func(t *testing.T) {
+		h.SetAddrPolicy(nil)
+		waitForAddrs(t, public, private, relay)
+	})
+
+	t.Run("suppress private addresses", func(t *testing.T) {
+		h.SetAddrPolicy(&AddrPolicy{SuppressPrivate: true})
+		// the public address is not confirmed yet
+		require.Equal(t, []ma.Multiaddr{public, private, relay}, h.Addrs())
+
+		an, err := autonat.New(h, autonat.WithReachability(network.ReachabilityPublic))
+		require.NoError(t, err)
+		h.SetAutoNat(an)
+		h.SignalAddressChange()
+		waitForAddrs(t, public, relay)
+	})
+
+	t.Run("TTL", func(t *testing.T) {
+		relayTTL := func(ttl time.Duration) *AddrPolicy {
+			return &AddrPolicy{
+				TTL: func(_ ma.Multiaddr, class AddrClass) time.Duration {
+					if class == AddrClassRelay {
+						return ttl
+					}
+					return 0
+				},
+			}
+		}
+		h.SetAddrPolicy(relayTTL(time.Hour))
+		// the TTL doesn't affect the advertised addresses
+		require.Equal(t, []ma.Multiaddr{public, private, relay}, h.Addrs())
+		// but it's used for the host's own addresses in the peerstore
+		h.SetAddrPolicy(relayTTL(50 * time.Millisecond))
+		h.applyAddrTTLs(h.Addrs())
+		require.Eventually(t, func() bool {
+			addrs := h.Peerstore().Addrs(h.ID())
+			return ma.Contains(addrs, public) && !ma.Contains(addrs, relay)
+		}, time.Second, 10*time.Millisecond)
+		require.Equal(t, []ma.Multiaddr{public, private, relay}, h.Addrs())
+	})
+}
+
+func TestAddrPolicyCached(t *testing.T) {
+	addrs := []ma.Multiaddr{ma.StringCast("/ip4/1.2.3.4/tcp/1"), ma.StringCast("/ip4/1.2.3.4/udp/1/quic-v1")}
+	var calls int
+	h, err := NewHost(swarmt.GenSwarm(t), &HostOpts{
+		AddrsFactory: func([]ma.Multiaddr) []ma.Multiaddr { return addrs },
+		AddrPolicy: &AddrPolicy{
+			Filter: func(ma.Multiaddr, AddrClass) bool { calls++; return true },
+		},
+	})
+	require.NoError(t, err)
+	defer h.Close()
+
+	require.Equal(t, addrs, h.Addrs())
+	require.Equal(t, 2, calls)
+	require.Equal(t, addrs, h.Addrs())
+	require.Equal(t, 2, calls)
+
+	// the policy is applied again when the addresses change
+	addrs = addrs[:1]
+	require.Equal(t, addrs, h.Addrs())
+	require.Equal(t, 3, calls)
+}
@@ -7,6 +7,7 @@ import (
 	"io"
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/AstaFrode/go-libp2p/core/connmgr"
@@ -20,12 +21,18 @@ import (
 	"github.com/AstaFrode/go-libp2p/core/record"
 	"github.com/AstaFrode/go-libp2p/p2p/host/autonat"
 	"github.com/AstaFrode/go-libp2p/p2p/host/eventbus"
+	"github.com/AstaFrode/go-libp2p/p2p/host/healthcheck"
+	"github.com/AstaFrode/go-libp2p/p2p/host/introspect"
 	"github.com/AstaFrode/go-libp2p/p2p/host/pstoremanager"
 	"github.com/AstaFrode/go-libp2p/p2p/host/relaysvc"
+	"github.com/AstaFrode/go-libp2p/p2p/host/resource-manager/watchdog"
+	"github.com/AstaFrode/go-libp2p/p2p/muxer/raw"
 	inat "github.com/AstaFrode/go-libp2p/p2p/net/nat"
+	"github.com/AstaFrode/go-libp2p/p2p/net/netmon"
 	relayv2 "github.com/AstaFrode/go-libp2p/p2p/protocol/circuitv2/relay"
 	"github.com/AstaFrode/go-libp2p/p2p/protocol/holepunch"
 	"github.com/AstaFrode/go-libp2p/p2p/protocol/identify"
+	"github.com/AstaFrode/go-libp2p/p2p/protocol/peerexchange"
 	"github.com/AstaFrode/go-libp2p/p2p/protocol/ping"
 	"github.com/prometheus/client_golang/prometheus"
 
@@ -68,33 +75,62 @@ type BasicHost struct {
 	closeSync sync.Once
 	// keep track of resources we need to wait on before shutting down
 	refCount sync.WaitGroup
+	// closed is set when Close is called, after which refCount must not be incremented
+	closeMx sync.RWMutex
+	closed  bool
 
 	network      network.Network
 	psManager    *pstoremanager.PeerstoreManager
 	mux          *msmux.MultistreamMuxer[protocol.ID]
 	ids          identify.IDService
 	hps          *holepunch.Service
+	pex          *peerexchange.Service
+	healthCheck  *healthcheck.HealthChecker
+	watchdog     *watchdog.Watchdog
 	pings        *ping.PingService
 	natmgr       NATManager
 	maResolver   *madns.Resolver
 	cmgr         connmgr.ConnManager
 	eventbus     event.Bus
 	relayManager *relaysvc.RelayManager
+	introspect   *introspect.Server
+	netmon       *netmon.Monitor
 
 	AddrsFactory AddrsFactory
+	addrPolicy   addrPolicyState
 
 	negtimeout time.Duration
 
+	firstStreamNegTimeout time.Duration
+	firstStreamMx         sync.Mutex
+	// inbound connections on which no stream has been negotiated yet
+	firstStreamPending map[network.Conn]struct{}
+
+	middlewareMx sync.Mutex
+	middleware   atomic.Pointer[[]StreamMiddleware]
+
 	emitters struct {
-		evtLocalProtocolsUpdated event.Emitter
-		evtLocalAddrsUpdated     event.Emitter
+		evtLocalProtocolsUpdated  event.Emitter
+		evtLocalAddrsUpdated      event.Emitter
+		evtPeerConnectionUpgraded event.Emitter
 	}
 
+	migrator streamMigrator
+
+	protocolTagger *protocolTagger
+	idleConns      *idleConnCloser
+
 	addrChangeChan chan struct{}
 
 	addrMu                 sync.RWMutex
 	filteredInterfaceAddrs []ma.Multiaddr
 	allInterfaceAddrs      []ma.Multiaddr
+	// addresses that are still advertised until their grace period expires, by expiry
+	deprecatedAddrs []deprecatedAddr
+
+	addrGracePeriod time.Duration
+
+	logger Logger
 
 	disableSignedPeerRecord bool
 	signKey                 crypto.PrivKey
@@ -105,21 +141,49 @@ type BasicHost struct {
 
 var _ host.Host = (*BasicHost)(nil)
 
+type deprecatedAddr struct {
+	addr   ma.Multiaddr
+	expiry time.Time
+}
+
 // HostOpts holds options that can be passed to NewHost in order to
 // customize construction of the *BasicHost.
 type HostOpts struct {
 	// MultistreamMuxer is essential for the *BasicHost and will use a sensible default value if omitted.
 	MultistreamMuxer *msmux.MultistreamMuxer[protocol.ID]
 
+	// Logger is the logger used by the host. If omitted, the host logs to the
+	// "basichost" go-log logger.
+	Logger Logger
+
+	// EventBus sets the event bus. Pass the event bus used by the network,
+	// so that its events are delivered to the subscribers of the host.
+	// If omitted, a new event bus is created.
+	EventBus event.Bus
+
 	// NegotiationTimeout determines the read and write timeouts on streams.
 	// If 0 or omitted, it will use DefaultNegotiationTimeout.
 	// If below 0, timeouts on streams will be deactivated.
 	NegotiationTimeout time.Duration
 
+	// FirstStreamNegotiationTimeout overrides NegotiationTimeout for the first stream
+	// opened by the remote peer on an inbound connection. This stream is often
+	// negotiated while the connection is still slow, e.g. on mobile links.
+	// If 0 or omitted, NegotiationTimeout is used.
+	// If below 0, timeouts on these streams will be deactivated.
+	FirstStreamNegotiationTimeout time.Duration
+
+	// StreamMiddleware is the middleware wrapping the handlers of incoming streams. See Use.
+	StreamMiddleware []StreamMiddleware
+
 	// AddrsFactory holds a function which can be used to override or filter the result of Addrs.
 	// If omitted, there's no override or filtering, and the results of Addrs and AllAddrs are the same.
 	AddrsFactory AddrsFactory
 
+	// AddrPolicy controls which addresses are advertised. It's applied to the output
+	// of AddrsFactory, and can be updated using SetAddrPolicy.
+	AddrPolicy *AddrPolicy
+
 	// MultiaddrResolves holds the go-multiaddr-dns.Resolver used for resolving
 	// /dns4, /dns6, and /dnsaddr addresses before trying to connect to a peer.
 	MultiaddrResolver *madns.Resolver
@@ -133,6 +197,8 @@ type HostOpts struct {
 
 	// EnablePing indicates whether to instantiate the ping service
 	EnablePing bool
+	// PingOptions are options for the ping service.
+	PingOptions []ping.Option
 
 	// EnableRelayService enables the circuit v2 relay (if we're publicly reachable).
 	EnableRelayService bool
@@ -153,6 +219,61 @@ type HostOpts struct {
 	// HolePunchingOptions are options for the hole punching service
 	HolePunchingOptions []holepunch.Option
 
+	// EnablePeerExchange enables the exchange of the signed peer records of recently seen peers
+	// with connected peers.
+	EnablePeerExchange bool
+	// PeerExchangeOptions are options for the peer exchange service
+	PeerExchangeOptions []peerexchange.Option
+
+	// EnableHealthCheck enables the periodic liveness check of idle connections.
+	EnableHealthCheck bool
+	// HealthCheckOptions are options for the health checker
+	HealthCheckOptions []healthcheck.Option
+
+	// EnableResourceWatchdog enables the adjustment of the resource manager limits to the file
+	// descriptors and memory left to the process.
+	EnableResourceWatchdog bool
+	// ResourceWatchdogOptions are options for the resource watchdog
+	ResourceWatchdogOptions []watchdog.Option
+
+	// EnableAddrChangeMonitor makes the host watch the network interfaces, and update its
+	// addresses as soon as they change, instead of waiting for the next periodic update.
+	EnableAddrChangeMonitor bool
+
+	// AddrGracePeriod is the duration during which addresses that the host stopped using
+	// are still advertised, marked as Deprecated in EvtLocalAddressesUpdated events.
+	// If 0 or omitted, addresses are withdrawn immediately.
+	AddrGracePeriod time.Duration
+
+	// ProtocolTags are the weights of the connection manager tags applied to peers
+	// depending on the protocols they support and use, so that the connection manager
+	// preferentially keeps the peers that are relevant to the application.
+	ProtocolTags map[protocol.ID]ProtocolTagWeights
+
+	// IdleConnTimeout is the duration after which connections without open streams are
+	// closed, unless the peer is protected in the connection manager.
+	// If 0 or omitted, idle connections are kept open.
+	IdleConnTimeout time.Duration
+
+	// EnableStreamMigration enables the automatic migration of outbound streams from transient
+	// connections to a direct connection, once one is established.
+	// Only streams using protocols with a StreamMigrationHandler are migrated.
+	EnableStreamMigration bool
+
+	// EnableRawStreamMuxer makes the host not run identify on connections using the raw
+	// stream muxer, which carry a single stream opened by the dialer.
+	EnableRawStreamMuxer bool
+
+	// KeyRotationRecord is a signed peer.KeyRotationRecord announcing that this host rotated
+	// its identity key. If set, it is sent to peers via identify.
+	KeyRotationRecord *record.Envelope
+
+	// IntrospectionAddr is the TCP address to run the introspection server on.
+	// If empty, the introspection server is disabled.
+	IntrospectionAddr string
+	// IntrospectionOpts are options for the introspection server.
+	IntrospectionOpts []introspect.Option
+
 	// EnableMetrics enables the metrics subsystems
 	EnableMetrics bool
 	// PrometheusRegisterer is the PrometheusRegisterer used for metrics
@@ -165,12 +286,14 @@ func NewHost(n network.Network, opts *HostOpts) (*BasicHost, error) {
 		opts = &HostOpts{}
 	}
 
-	var eventBus event.Bus
-	if opts.EnableMetrics {
-		eventBus = eventbus.NewBus(
-			eventbus.WithMetricsTracer(eventbus.NewMetricsTracer(eventbus.WithRegisterer(opts.PrometheusRegisterer))))
-	} else {
-		eventBus = eventbus.NewBus()
+	eventBus := opts.EventBus
+	if eventBus == nil {
+		if opts.EnableMetrics {
+			eventBus = eventbus.NewBus(
+				eventbus.WithMetricsTracer(eventbus.NewMetricsTracer(eventbus.WithRegisterer(opts.PrometheusRegisterer))))
+		} else {
+			eventBus = eventbus.NewBus()
+		}
 	}
 
 	psManager, err := pstoremanager.NewPeerstoreManager(n.Peerstore(), eventBus)
@@ -191,6 +314,11 @@ func NewHost(n network.Network, opts *HostOpts) (*BasicHost, error) {
 		ctx:                     hostCtx,
 		ctxCancel:               cancel,
 		disableSignedPeerRecord: opts.DisableSignedPeerRecord,
+		addrGracePeriod:         opts.AddrGracePeriod,
+		logger:                  log,
+	}
+	if opts.Logger != nil {
+		h.logger = opts.Logger
 	}
 
 	h.updateLocalIpAddr()
@@ -206,6 +334,14 @@ func NewHost(n network.Network, opts *HostOpts) (*BasicHost, error) {
 		return nil, err
 	}
 	h.Network().Notify(newPeerConnectWatcher(evtPeerConnectednessChanged))
+	if h.emitters.evtPeerConnectionUpgraded, err = h.eventbus.Emitter(&event.EvtPeerConnectionUpgraded{}); err != nil {
+		return nil, err
+	}
+	h.Network().Notify(&connUpgradeWatcher{
+		h:       h,
+		emitter: h.emitters.evtPeerConnectionUpgraded,
+		migrate: opts.EnableStreamMigration,
+	})
 
 	if !h.disableSignedPeerRecord {
 		cab, ok := peerstore.GetCertifiedAddrBook(n.Peerstore())
@@ -231,6 +367,7 @@ func NewHost(n network.Network, opts *HostOpts) (*BasicHost, error) {
 		if _, err := cab.ConsumePeerRecord(ev, peerstore.PermanentAddrTTL); err != nil {
 			return nil, fmt.Errorf("failed to persist signed record to peerstore: %w", err)
 		}
+		h.applyAddrTTLs(rec.Addrs)
 	}
 
 	if opts.MultistreamMuxer != nil {
@@ -246,12 +383,27 @@ func NewHost(n network.Network, opts *HostOpts) (*BasicHost, error) {
 	if h.disableSignedPeerRecord {
 		idOpts = append(idOpts, identify.DisableSignedPeerRecord())
 	}
+	if opts.KeyRotationRecord != nil {
+		idOpts = append(idOpts, identify.KeyRotationRecord(opts.KeyRotationRecord))
+		// remember our own rotation, so that lookups of our old peer ID resolve to us.
+		if krb, ok := peerstore.GetKeyRotationBook(h.Peerstore()); ok {
+			if _, err := krb.ConsumeKeyRotationRecord(opts.KeyRotationRecord); err != nil {
+				return nil, fmt.Errorf("failed to persist key rotation record to peerstore: %w", err)
+			}
+		}
+	}
 	if opts.EnableMetrics {
 		idOpts = append(idOpts,
 			identify.WithMetricsTracer(
 				identify.NewMetricsTracer(identify.WithRegisterer(opts.PrometheusRegisterer))))
 	}
 
+	if opts.EnableRawStreamMuxer {
+		idOpts = append(idOpts, identify.SkipConns(func(c network.Conn) bool {
+			return c.ConnState().StreamMultiplexer == raw.ID
+		}))
+	}
+
 	h.ids, err = identify.NewIDService(h, idOpts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Identify service: %s", err)
@@ -264,16 +416,68 @@ func NewHost(n network.Network, opts *HostOpts) (*BasicHost, error) {
 		}
 	}
 
+	if opts.EnablePeerExchange {
+		h.pex, err = peerexchange.NewService(h, opts.PeerExchangeOptions...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create peer exchange service: %w", err)
+		}
+	}
+
+	if opts.EnableHealthCheck {
+		h.healthCheck, err = healthcheck.NewHealthChecker(h, opts.HealthCheckOptions...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create health checker: %w", err)
+		}
+	}
+
+	if opts.EnableResourceWatchdog {
+		h.watchdog, err = watchdog.NewWatchdog(n.ResourceManager(), h.eventbus, opts.ResourceWatchdogOptions...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create resource watchdog: %w", err)
+		}
+	}
+
 	if uint64(opts.NegotiationTimeout) != 0 {
 		h.negtimeout = opts.NegotiationTimeout
 	}
 
+	if len(opts.StreamMiddleware) > 0 {
+		h.Use(opts.StreamMiddleware...)
+	}
+
+	if opts.FirstStreamNegotiationTimeout != 0 {
+		h.firstStreamNegTimeout = opts.FirstStreamNegotiationTimeout
+		h.firstStreamPending = make(map[network.Conn]struct{})
+		n.Notify(&network.NotifyBundle{
+			ConnectedF: func(_ network.Network, c network.Conn) {
+				if c.Stat().Direction != network.DirInbound {
+					return
+				}
+				h.firstStreamMx.Lock()
+				h.firstStreamPending[c] = struct{}{}
+				h.firstStreamMx.Unlock()
+			},
+			DisconnectedF: func(_ network.Network, c network.Conn) {
+				h.firstStreamMx.Lock()
+				delete(h.firstStreamPending, c)
+				h.firstStreamMx.Unlock()
+			},
+		})
+	}
+
 	if opts.AddrsFactory != nil {
 		h.AddrsFactory = opts.AddrsFactory
 	}
+	h.addrPolicy.policy.Store(opts.AddrPolicy)
 
 	if opts.NATManager != nil {
 		h.natmgr = opts.NATManager(n)
+		if nm, ok := h.natmgr.(*natManager); ok {
+			nm.setLogger(h.logger)
+			if err := nm.setEventBus(h.eventbus); err != nil {
+				return nil, err
+			}
+		}
 	}
 
 	if opts.MultiaddrResolver != nil {
@@ -287,12 +491,55 @@ func NewHost(n network.Network, opts *HostOpts) (*BasicHost, error) {
 		n.Notify(h.cmgr.Notifee())
 	}
 
+	if len(opts.ProtocolTags) > 0 {
+		h.protocolTagger, err = newProtocolTagger(h, opts.ProtocolTags)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create protocol tagger: %w", err)
+		}
+		h.refCount.Add(1)
+		go func() {
+			defer h.refCount.Done()
+			h.protocolTagger.run(h.Peerstore().SupportsProtocols)
+		}()
+	}
+
+	if opts.IdleConnTimeout > 0 {
+		h.idleConns, err = newIdleConnCloser(h, opts.IdleConnTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create idle connection closer: %w", err)
+		}
+		h.refCount.Add(1)
+		go func() {
+			defer h.refCount.Done()
+			h.idleConns.run()
+		}()
+	}
+
 	if opts.EnableRelayService {
 		h.relayManager = relaysvc.NewRelayManager(h, opts.RelayServiceOpts...)
 	}
 
 	if opts.EnablePing {
-		h.pings = ping.NewPingService(h)
+		pingOpts := opts.PingOptions
+		if opts.EnableMetrics {
+			pingOpts = append(pingOpts,
+				ping.WithMetricsTracer(ping.NewMetricsTracer(ping.WithRegisterer(opts.PrometheusRegisterer))))
+		}
+		h.pings = ping.NewPingService(h, pingOpts...)
+	}
+
+	if opts.EnableAddrChangeMonitor {
+		h.netmon, err = netmon.New()
+		if err != nil {
+			return nil, fmt.Errorf("failed to create network interface monitor: %w", err)
+		}
+	}
+
+	if opts.IntrospectionAddr != "" {
+		h.introspect, err = introspect.New(h, opts.IntrospectionAddr, opts.IntrospectionOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to start introspection server: %w", err)
+		}
 	}
 
 	n.SetStreamHandler(h.newStreamHandler)
@@ -320,10 +567,10 @@ func (h *BasicHost) updateLocalIpAddr() {
 	// Try to use the default ipv4/6 addresses.
 
 	if r, err := netroute.New(); err != nil {
-		log.Debugw("failed to build Router for kernel's routing table", "error", err)
+		h.logger.Debugw("failed to build Router for kernel's routing table", "error", err)
 	} else {
 		if _, _, localIPv4, err := r.Route(net.IPv4zero); err != nil {
-			log.Debugw("failed to fetch local IPv4 address", "error", err)
+			h.logger.Debugw("failed to fetch local IPv4 address", "error", err)
 		} else if localIPv4.IsGlobalUnicast() {
 			maddr, err := manet.FromIP(localIPv4)
 			if err == nil {
@@ -332,7 +579,7 @@ func (h *BasicHost) updateLocalIpAddr() {
 		}
 
 		if _, _, localIPv6, err := r.Route(net.IPv6unspecified); err != nil {
-			log.Debugw("failed to fetch local IPv6 address", "error", err)
+			h.logger.Debugw("failed to fetch local IPv6 address", "error", err)
 		} else if localIPv6.IsGlobalUnicast() {
 			maddr, err := manet.FromIP(localIPv6)
 			if err == nil {
@@ -346,7 +593,7 @@ func (h *BasicHost) updateLocalIpAddr() {
 	if err != nil {
 		// This usually shouldn't happen, but we could be in some kind
 		// of funky restricted environment.
-		log.Errorw("failed to resolve local interface addresses", "error", err)
+		h.logger.Errorw("failed to resolve local interface addresses", "error", err)
 
 		// Add the loopback addresses to the filtered addrs and use them as the non-filtered addrs.
 		// Then bail. There's nothing else we can do here.
@@ -383,6 +630,12 @@ func (h *BasicHost) Start() {
 	h.psManager.Start()
 	h.refCount.Add(1)
 	h.ids.Start()
+	if h.healthCheck != nil {
+		h.healthCheck.Start()
+	}
+	if h.watchdog != nil {
+		h.watchdog.Start()
+	}
 	go h.background()
 }
 
@@ -391,9 +644,14 @@ func (h *BasicHost) Start() {
 func (h *BasicHost) newStreamHandler(s network.Stream) {
 	before := time.Now()
 
-	if h.negtimeout > 0 {
-		if err := s.SetDeadline(time.Now().Add(h.negtimeout)); err != nil {
-			log.Debug("setting stream deadline: ", err)
+	negtimeout := h.negtimeout
+	if h.isFirstStream(s.Conn()) {
+		negtimeout = h.firstStreamNegTimeout
+	}
+
+	if negtimeout > 0 {
+		if err := s.SetDeadline(time.Now().Add(negtimeout)); err != nil {
+			h.logger.Debugw("failed to set stream deadline", streamFields(s, "error", err)...)
 			s.Reset()
 			return
 		}
@@ -403,37 +661,57 @@ func (h *BasicHost) newStreamHandler(s network.Stream) {
 	took := time.Since(before)
 	if err != nil {
 		if err == io.EOF {
-			logf := log.Debugf
+			logw := h.logger.Debugw
 			if took > time.Second*10 {
-				logf = log.Warnf
+				logw = h.logger.Warnw
 			}
-			logf("protocol EOF: %s (took %s)", s.Conn().RemotePeer(), took)
+			logw("protocol EOF", streamFields(s, "took", took)...)
 		} else {
-			log.Debugf("protocol mux failed: %s (took %s)", err, took)
+			h.logger.Debugw("protocol mux failed", streamFields(s, "error", err, "took", took)...)
 		}
 		s.Reset()
 		return
 	}
 
-	if h.negtimeout > 0 {
+	if negtimeout > 0 {
 		if err := s.SetDeadline(time.Time{}); err != nil {
-			log.Debugf("resetting stream deadline: ", err)
+			h.logger.Debugw("failed to reset stream deadline", streamFields(s, "error", err)...)
 			s.Reset()
 			return
 		}
 	}
 
 	if err := s.SetProtocol(protoID); err != nil {
-		log.Debugf("error setting stream protocol: %s", err)
+		h.logger.Debugw("failed to set stream protocol", streamFields(s, "error", err)...)
 		s.Reset()
 		return
 	}
 
-	log.Debugf("negotiated: %s (took %s)", protoID, took)
+	h.logger.Debugw("negotiated protocol", streamFields(s, "protocol", protoID, "took", took)...)
+	if h.protocolTagger != nil {
+		h.protocolTagger.streamOpened(s.Conn().RemotePeer(), protoID)
+	}
+	if h.idleConns != nil {
+		h.idleConns.streamOpened(s.Conn())
+	}
 
 	go handle(protoID, s)
 }
 
+// isFirstStream reports whether a stream is the first one accepted on the inbound connection c.
+func (h *BasicHost) isFirstStream(c network.Conn) bool {
+	if h.firstStreamPending == nil {
+		return false
+	}
+	h.firstStreamMx.Lock()
+	defer h.firstStreamMx.Unlock()
+	if _, ok := h.firstStreamPending[c]; !ok {
+		return false
+	}
+	delete(h.firstStreamPending, c)
+	return true
+}
+
 // SignalAddressChange signals to the host that it needs to determine whether our listen addresses have recently
 // changed.
 // Warning: this interface is unstable and may disappear in the future.
@@ -444,14 +722,19 @@ func (h *BasicHost) SignalAddressChange() {
 	}
 }
 
-func makeUpdatedAddrEvent(prev, current []ma.Multiaddr) *event.EvtLocalAddressesUpdated {
+func makeUpdatedAddrEvent(prev, current, prevDeprecated, deprecated []ma.Multiaddr) *event.EvtLocalAddressesUpdated {
 	prevmap := make(map[string]ma.Multiaddr, len(prev))
+	prevDeprecatedMap := make(map[string]ma.Multiaddr, len(prevDeprecated))
 	evt := event.EvtLocalAddressesUpdated{Diffs: true}
 	addrsAdded := false
+	addrsDeprecated := false
 
 	for _, addr := range prev {
 		prevmap[string(addr.Bytes())] = addr
 	}
+	for _, addr := range prevDeprecated {
+		prevDeprecatedMap[string(addr.Bytes())] = addr
+	}
 	for _, addr := range current {
 		_, ok := prevmap[string(addr.Bytes())]
 		updated := event.UpdatedAddress{Address: addr}
@@ -463,13 +746,26 @@ func makeUpdatedAddrEvent(prev, current []ma.Multiaddr) *event.EvtLocalAddresses
 		}
 		evt.Current = append(evt.Current, updated)
 		delete(prevmap, string(addr.Bytes()))
+		delete(prevDeprecatedMap, string(addr.Bytes()))
+	}
+	for _, addr := range deprecated {
+		if _, ok := prevDeprecatedMap[string(addr.Bytes())]; !ok {
+			addrsDeprecated = true
+		}
+		evt.Current = append(evt.Current, event.UpdatedAddress{Action: event.Deprecated, Address: addr})
+		delete(prevmap, string(addr.Bytes()))
+		delete(prevDeprecatedMap, string(addr.Bytes()))
 	}
 	for _, addr := range prevmap {
 		updated := event.UpdatedAddress{Action: event.Removed, Address: addr}
 		evt.Removed = append(evt.Removed, updated)
 	}
+	for _, addr := range prevDeprecatedMap {
+		updated := event.UpdatedAddress{Action: event.Removed, Address: addr}
+		evt.Removed = append(evt.Removed, updated)
+	}
 
-	if !addrsAdded && len(evt.Removed) == 0 {
+	if !addrsAdded && !addrsDeprecated && len(evt.Removed) == 0 {
 		return nil
 	}
 
@@ -491,15 +787,15 @@ func (h *BasicHost) makeSignedPeerRecord(evt *event.EvtLocalAddressesUpdated) (*
 
 func (h *BasicHost) background() {
 	defer h.refCount.Done()
-	var lastAddrs []ma.Multiaddr
+	var lastAddrs, lastDeprecated []ma.Multiaddr
 
-	emitAddrChange := func(currentAddrs []ma.Multiaddr, lastAddrs []ma.Multiaddr) {
+	emitAddrChange := func(currentAddrs, lastAddrs, deprecated, lastDeprecated []ma.Multiaddr) {
 		// nothing to do if both are nil..defensive check
-		if currentAddrs == nil && lastAddrs == nil {
+		if currentAddrs == nil && lastAddrs == nil && deprecated == nil && lastDeprecated == nil {
 			return
 		}
 
-		changeEvt := makeUpdatedAddrEvent(lastAddrs, currentAddrs)
+		changeEvt := makeUpdatedAddrEvent(lastAddrs, currentAddrs, lastDeprecated, deprecated)
 
 		if changeEvt == nil {
 			return
@@ -509,21 +805,22 @@ func (h *BasicHost) background() {
 			// add signed peer record to the event
 			sr, err := h.makeSignedPeerRecord(changeEvt)
 			if err != nil {
-				log.Errorf("error creating a signed peer record from the set of current addresses, err=%s", err)
+				h.logger.Errorw("failed to create a signed peer record from the set of current addresses", "error", err)
 				return
 			}
 			changeEvt.SignedPeerRecord = sr
 
 			// persist the signed record to the peerstore
 			if _, err := h.caBook.ConsumePeerRecord(sr, peerstore.PermanentAddrTTL); err != nil {
-				log.Errorf("failed to persist signed peer record in peer store, err=%s", err)
+				h.logger.Errorw("failed to persist signed peer record in peer store", "error", err)
 				return
 			}
+			h.applyAddrTTLs(currentAddrs)
 		}
 
 		// emit addr change event on the bus
 		if err := h.emitters.evtLocalAddrsUpdated.Emit(*changeEvt); err != nil {
-			log.Warnf("error emitting event for updated addrs: %s", err)
+			h.logger.Warnw("failed to emit event for updated addrs", "error", err)
 		}
 	}
 
@@ -532,23 +829,89 @@ func (h *BasicHost) background() {
 	ticker := time.NewTicker(addrChangeTickrInterval)
 	defer ticker.Stop()
 
+	// interface changes are only monitored if enabled, otherwise this channel is nil
+	var ifaceChanges <-chan struct{}
+	if h.netmon != nil {
+		ifaceChanges = h.netmon.Changes()
+	}
+
 	for {
 		if len(h.network.ListenAddresses()) > 0 {
 			h.updateLocalIpAddr()
 		}
 		// Request addresses anyways because, technically, address filters still apply.
 		// The underlying AllAddrs call is effectivley a no-op.
-		curr := h.Addrs()
-		emitAddrChange(curr, lastAddrs)
+		curr := h.currentAddrs()
+		deprecated, nextExpiry := h.updateDeprecatedAddrs(lastAddrs, curr)
+		emitAddrChange(curr, lastAddrs, deprecated, lastDeprecated)
 		lastAddrs = curr
+		lastDeprecated = deprecated
+
+		// wake up when the grace period of a deprecated address expires
+		var graceTimer *time.Timer
+		var graceExpired <-chan time.Time
+		if !nextExpiry.IsZero() {
+			graceTimer = time.NewTimer(time.Until(nextExpiry))
+			graceExpired = graceTimer.C
+		}
 
 		select {
 		case <-ticker.C:
 		case <-h.addrChangeChan:
+		case <-ifaceChanges:
+			h.logger.Debugw("network interfaces changed, updating addresses")
+		case <-graceExpired:
 		case <-h.ctx.Done():
+			if graceTimer != nil {
+				graceTimer.Stop()
+			}
 			return
 		}
+		if graceTimer != nil {
+			graceTimer.Stop()
+		}
+	}
+}
+
+// updateDeprecatedAddrs deprecates the addresses of prev that are not in curr, and drops the
+// deprecated addresses whose grace period expired or that are in use again.
+// It returns the deprecated addresses, and the time at which the next grace period expires.
+func (h *BasicHost) updateDeprecatedAddrs(prev, curr []ma.Multiaddr) ([]ma.Multiaddr, time.Time) {
+	if h.addrGracePeriod <= 0 {
+		return nil, time.Time{}
+	}
+
+	h.addrMu.Lock()
+	defer h.addrMu.Unlock()
+
+	now := time.Now()
+	inUse := make(map[string]struct{}, len(curr))
+	for _, a := range curr {
+		inUse[string(a.Bytes())] = struct{}{}
+	}
+	deprecated := h.deprecatedAddrs[:0]
+	for _, d := range h.deprecatedAddrs {
+		if _, ok := inUse[string(d.addr.Bytes())]; ok || !now.Before(d.expiry) {
+			continue
+		}
+		inUse[string(d.addr.Bytes())] = struct{}{}
+		deprecated = append(deprecated, d)
+	}
+	for _, a := range prev {
+		if _, ok := inUse[string(a.Bytes())]; !ok {
+			deprecated = append(deprecated, deprecatedAddr{addr: a, expiry: now.Add(h.addrGracePeriod)})
+		}
+	}
+	h.deprecatedAddrs = deprecated
+
+	if len(deprecated) == 0 {
+		return nil, time.Time{}
+	}
+	addrs := make([]ma.Multiaddr, 0, len(deprecated))
+	for _, d := range deprecated {
+		addrs = append(addrs, d.addr)
 	}
+	return addrs, deprecated[0].expiry
 }
 
 // ID returns the (local) peer.ID associated with this Host
@@ -566,6 +929,15 @@ func (h *BasicHost) Network() network.Network {
 	return h.network
 }
 
+// NetworkStats returns the statistics of the streams of the host, grouped by peer and by
+// protocol. It returns empty statistics if the network doesn't account for its streams.
+func (h *BasicHost) NetworkStats() network.NetworkStats {
+	if r, ok := h.network.(network.NetworkStatsReporter); ok {
+		return r.NetworkStats()
+	}
+	return network.NetworkStats{}
+}
+
 // Mux returns the Mux multiplexing incoming streams to protocol handlers
 func (h *BasicHost) Mux() protocol.Switch {
 	return h.mux
@@ -576,6 +948,11 @@ func (h *BasicHost) IDService() identify.IDService {
 	return h.ids
 }
 
+// PeerExchange returns the peer exchange service, if peer exchange is enabled.
+func (h *BasicHost) PeerExchange() *peerexchange.Service {
+	return h.pex
+}
+
 func (h *BasicHost) EventBus() event.Bus {
 	return h.eventbus
 }
@@ -590,7 +967,7 @@ func (h *BasicHost) SetStreamHandler(pid protocol.ID, handler network.StreamHand
 	h.Mux().AddHandler(pid, func(p protocol.ID, rwc io.ReadWriteCloser) error {
 		is := rwc.(network.Stream)
 		is.SetProtocol(p)
-		handler(is)
+		h.wrapHandler(handler)(is)
 		return nil
 	})
 	h.emitters.evtLocalProtocolsUpdated.Emit(event.EvtLocalProtocolsUpdated{
@@ -604,7 +981,7 @@ func (h *BasicHost) SetStreamHandlerMatch(pid protocol.ID, m func(protocol.ID) b
 	h.Mux().AddHandlerWithFunc(pid, m, func(p protocol.ID, rwc io.ReadWriteCloser) error {
 		is := rwc.(network.Stream)
 		is.SetProtocol(p)
-		handler(is)
+		h.wrapHandler(handler)(is)
 		return nil
 	})
 	h.emitters.evtLocalProtocolsUpdated.Emit(event.EvtLocalProtocolsUpdated{
@@ -660,16 +1037,33 @@ func (h *BasicHost) NewStream(ctx context.Context, p peer.ID, pids ...protocol.I
 	}
 
 	if pref != "" {
-		s.SetProtocol(pref)
-		lzcon := msmux.NewMSSelect(s, pref)
-		return &streamWrapper{
-			Stream: s,
-			rw:     lzcon,
-		}, nil
+		// The protocol tag is only bumped once the peer confirmed the protocol.
+		if h.idleConns != nil {
+			h.idleConns.streamOpened(s.Conn())
+		}
+		// select the protocol optimistically, and fall back to full negotiation
+		// if the peer doesn't actually support it
+		return newOptimisticStream(h, s, pref, pids), nil
+	}
+
+	if err := h.negotiateProtocol(ctx, s, pids); err != nil {
+		return nil, err
+	}
+	if h.protocolTagger != nil {
+		h.protocolTagger.streamOpened(p, s.Protocol())
+	}
+	if h.idleConns != nil {
+		h.idleConns.streamOpened(s.Conn())
 	}
+	return s, nil
+}
 
+// negotiateProtocol selects one of pids on s using full multistream negotiation.
+// The stream is reset if the negotiation fails.
+func (h *BasicHost) negotiateProtocol(ctx context.Context, s network.Stream, pids []protocol.ID) error {
 	// Negotiate the protocol in the background, obeying the context.
 	var selected protocol.ID
+	var err error
 	errCh := make(chan error, 1)
 	go func() {
 		selected, err = msmux.SelectOneOf(pids, s)
@@ -679,18 +1073,18 @@ func (h *BasicHost) NewStream(ctx context.Context, p peer.ID, pids ...protocol.I
 	case err = <-errCh:
 		if err != nil {
 			s.Reset()
-			return nil, err
+			return err
 		}
 	case <-ctx.Done():
 		s.Reset()
 		// wait for `SelectOneOf` to error out because of resetting the stream.
 		<-errCh
-		return nil, ctx.Err()
+		return ctx.Err()
 	}
 
 	s.SetProtocol(selected)
-	h.Peerstore().AddProtocols(p, selected)
-	return s, nil
+	h.Peerstore().AddProtocols(s.Conn().RemotePeer(), selected)
+	return nil
 }
 
 func (h *BasicHost) preferredProtocol(p peer.ID, pids []protocol.ID) (protocol.ID, error) {
@@ -716,7 +1110,8 @@ func (h *BasicHost) Connect(ctx context.Context, pi peer.AddrInfo) error {
 	h.Peerstore().AddAddrs(pi.ID, pi.Addrs, peerstore.TempAddrTTL)
 
 	forceDirect, _ := network.GetForceDirectDial(ctx)
-	if !forceDirect {
+	forceRelay, _ := network.GetForceRelayDial(ctx)
+	if !forceDirect && !forceRelay && network.GetDialTransports(ctx) == nil {
 		if h.Network().Connectedness(pi.ID) == network.Connected {
 			return nil
 		}
@@ -728,7 +1123,7 @@ func (h *BasicHost) Connect(ctx context.Context, pi peer.AddrInfo) error {
 // dialPeer opens a connection to peer, and makes sure to identify
 // the connection once it has been opened.
 func (h *BasicHost) dialPeer(ctx context.Context, p peer.ID) error {
-	log.Debugf("host %s dialing %s", h.ID(), p)
+	h.logger.Debugw("dialing peer", "peer", p)
 	c, err := h.Network().DialPeer(ctx, p)
 	if err != nil {
 		return err
@@ -745,7 +1140,7 @@ func (h *BasicHost) dialPeer(ctx context.Context, p peer.ID) error {
 		return ctx.Err()
 	}
 
-	log.Debugf("host %s finished dialing %s", h.ID(), p)
+	h.logger.Debugw("finished dialing peer", "peer", p, "conn", c.ID())
 	return nil
 }
 
@@ -754,9 +1149,30 @@ func (h *BasicHost) ConnManager() connmgr.ConnManager {
 }
 
 // Addrs returns listening addresses that are safe to announce to the network.
-// The output is the same as AllAddrs, but processed by AddrsFactory.
+// The output is the same as AllAddrs, but processed by AddrsFactory and the AddrPolicy.
+// If an address grace period is configured, it also contains the addresses the host
+// stopped using, until their grace period expires.
 func (h *BasicHost) Addrs() []ma.Multiaddr {
-	return h.AddrsFactory(h.AllAddrs())
+	addrs := h.currentAddrs()
+	if h.addrGracePeriod <= 0 {
+		return addrs
+	}
+
+	h.addrMu.RLock()
+	defer h.addrMu.RUnlock()
+	if len(h.deprecatedAddrs) == 0 {
+		return addrs
+	}
+	all := make([]ma.Multiaddr, 0, len(addrs)+len(h.deprecatedAddrs))
+	all = append(all, addrs...)
+	for _, d := range h.deprecatedAddrs {
+		all = append(all, d.addr)
+	}
+	return dedupAddrs(all)
+}
+
+func (h *BasicHost) currentAddrs() []ma.Multiaddr {
+	return h.applyAddrPolicy(h.AddrsFactory(h.AllAddrs()))
 }
 
 // mergeAddrs merges input address lists, leave only unique addresses
@@ -793,7 +1209,7 @@ func (h *BasicHost) AllAddrs() []ma.Multiaddr {
 	if resolved, err := manet.ResolveUnspecifiedAddresses(listenAddrs, filteredIfaceAddrs); err != nil {
 		// This can happen if we're listening on no addrs, or listening
 		// on IPv6 addrs, but only have IPv4 interface addrs.
-		log.Debugw("failed to resolve listen addrs", "error", err)
+		h.logger.Debugw("failed to resolve listen addrs", "error", err)
 	} else {
 		finalAddrs = append(finalAddrs, resolved...)
 	}
@@ -813,13 +1229,7 @@ func (h *BasicHost) AllAddrs() []ma.Multiaddr {
 
 	finalAddrs = dedupAddrs(finalAddrs)
 
-	var natMappings []inat.Mapping
-
-	// natmgr is nil if we do not use nat option;
-	// h.natmgr.NAT() is nil if not ready, or no nat is available.
-	if h.natmgr != nil && h.natmgr.NAT() != nil {
-		natMappings = h.natmgr.NAT().Mappings()
-	}
+	natMappings := h.NATMappings()
 
 	if len(natMappings) > 0 {
 		// We have successfully mapped ports on our NAT. Use those
@@ -861,7 +1271,7 @@ func (h *BasicHost) AllAddrs() []ma.Multiaddr {
 
 			naddr, err := manet.ToNetAddr(transport)
 			if err != nil {
-				log.Error("error parsing net multiaddr %q: %s", transport, err)
+				h.logger.Errorw("failed to parse net multiaddr", "addr", transport, "error", err)
 				continue
 			}
 
@@ -897,7 +1307,7 @@ func (h *BasicHost) AllAddrs() []ma.Multiaddr {
 
 			mappedMaddr, err := manet.FromNetAddr(mappedAddr)
 			if err != nil {
-				log.Errorf("mapped addr can't be turned into a multiaddr %q: %s", mappedAddr, err)
+				h.logger.Errorw("mapped addr can't be turned into a multiaddr", "addr", mappedAddr, "error", err)
 				continue
 			}
 
@@ -911,7 +1321,7 @@ func (h *BasicHost) AllAddrs() []ma.Multiaddr {
 				// Add in the mapped addr.
 				finalAddrs = append(finalAddrs, extMaddr)
 			} else {
-				log.Warn("NAT device reported an unspecified IP as it's external address")
+				h.logger.Warnw("NAT device reported an unspecified IP as its external address", "addr", extMaddr)
 			}
 
 			// Did the router give us a routable public addr?
@@ -965,6 +1375,22 @@ func (h *BasicHost) AllAddrs() []ma.Multiaddr {
 	return dedupAddrs(finalAddrs)
 }
 
+// NATMappings returns the port mappings on the NAT device, including those
+// that could not be established (yet).
+// It returns nil if NAT port mapping is disabled, or no NAT device was found.
+// Subscribe to event.EvtNATPortMappingChanged to be notified about changes.
+func (h *BasicHost) NATMappings() []inat.Mapping {
+	// natmgr is nil if we do not use nat option;
+	// h.natmgr.NAT() is nil if not ready, or no nat is available.
+	if h.natmgr == nil {
+		return nil
+	}
+	if nat := h.natmgr.NAT(); nat != nil {
+		return nat.Mappings()
+	}
+	return nil
+}
+
 // SetAutoNat sets the autonat service for the host.
 func (h *BasicHost) SetAutoNat(a autonat.AutoNAT) {
 	h.addrMu.Lock()
@@ -984,6 +1410,9 @@ func (h *BasicHost) GetAutoNat() autonat.AutoNAT {
 // Close shuts down the Host's services (network, etc).
 func (h *BasicHost) Close() error {
 	h.closeSync.Do(func() {
+		h.closeMx.Lock()
+		h.closed = true
+		h.closeMx.Unlock()
 		h.ctxCancel()
 		if h.natmgr != nil {
 			h.natmgr.Close()
@@ -1003,9 +1432,34 @@ func (h *BasicHost) Close() error {
 		if h.hps != nil {
 			h.hps.Close()
 		}
+		if h.pex != nil {
+			h.pex.Close()
+		}
+		if h.healthCheck != nil {
+			h.healthCheck.Close()
+		}
+		if h.watchdog != nil {
+			h.watchdog.Close()
+		}
+		if h.pings != nil {
+			h.pings.Close()
+		}
+		if h.introspect != nil {
+			h.introspect.Close()
+		}
+		if h.netmon != nil {
+			h.netmon.Close()
+		}
+		if h.protocolTagger != nil {
+			h.protocolTagger.Close()
+		}
+		if h.idleConns != nil {
+			h.idleConns.Close()
+		}
 
 		_ = h.emitters.evtLocalProtocolsUpdated.Close()
 		_ = h.emitters.evtLocalAddrsUpdated.Close()
+		_ = h.emitters.evtPeerConnectionUpgraded.Close()
 		h.Network().Close()
 
 		h.psManager.Close()
@@ -1022,32 +1476,3 @@ func (h *BasicHost) Close() error {
 
 	return nil
 }
-
-type streamWrapper struct {
-	network.Stream
-	rw io.ReadWriteCloser
-}
-
-func (s *streamWrapper) Read(b []byte) (int, error) {
-	return s.rw.Read(b)
-}
-
-func (s *streamWrapper) Write(b []byte) (int, error) {
-	return s.rw.Write(b)
-}
-
-func (s *streamWrapper) Close() error {
-	return s.rw.Close()
-}
-
-func (s *streamWrapper) CloseWrite() error {
-	// Flush the handshake before closing, but ignore the error. The other
-	// end may have closed their side for reading.
-	//
-	// If something is wrong with the stream, the user will get on error on
-	// read instead.
-	if flusher, ok := s.rw.(interface{ Flush() error }); ok {
-		_ = flusher.Flush()
-	}
-	return s.Stream.CloseWrite()
-}
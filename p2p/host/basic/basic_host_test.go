@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"os"
 	"reflect"
 	"strings"
 	"sync"
@@ -164,6 +165,15 @@ func TestProtocolHandlerEvents(t *testing.T) {
 	assert(nil, []protocol.ID{protocol.TestingID})
 }
 
+func TestAddrChangeMonitor(t *testing.T) {
+	h, err := NewHost(swarmt.GenSwarm(t), &HostOpts{EnableAddrChangeMonitor: true})
+	require.NoError(t, err)
+	require.NotNil(t, h.netmon)
+	h.Start()
+	require.NotEmpty(t, h.Addrs())
+	require.NoError(t, h.Close())
+}
+
 func TestHostAddrsFactory(t *testing.T) {
 	maddr := ma.StringCast("/ip4/1.2.3.4/tcp/1234")
 	addrsFactory := func(addrs []ma.Multiaddr) []ma.Multiaddr {
@@ -525,6 +535,55 @@ func TestProtoDowngrade(t *testing.T) {
 	assertWait(t, connectedOn, "/testing")
 }
 
+func TestOptimisticNegotiationFallback(t *testing.T) {
+	h1, h2 := getHostPair(t)
+	defer h1.Close()
+	defer h2.Close()
+
+	const (
+		protoOld = "/testing/1.0.0"
+		protoNew = "/testing/2.0.0"
+	)
+	h2.SetStreamHandler(protoOld, func(s network.Stream) {
+		defer s.Close()
+		buf := make([]byte, 6)
+		_, err := io.ReadFull(s, buf)
+		assert.NoError(t, err)
+		_, err = s.Write(buf)
+		assert.NoError(t, err)
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	select {
+	case <-h1.(*BasicHost).ids.IdentifyWait(h1.Network().ConnsToPeer(h2.ID())[0]):
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for identify")
+	}
+	// pretend that we learned that h2 supports the new protocol
+	require.NoError(t, h1.Peerstore().AddProtocols(h2.ID(), protoNew))
+
+	s, err := h1.NewStream(ctx, h2.ID(), protoNew, protoOld)
+	require.NoError(t, err)
+	require.Equal(t, protocol.ID(protoNew), s.Protocol())
+
+	_, err = s.Write([]byte("foo"))
+	require.NoError(t, err)
+	_, err = s.Write([]byte("bar"))
+	require.NoError(t, err)
+	data := make([]byte, 6)
+	_, err = io.ReadFull(s, data)
+	require.NoError(t, err)
+	require.Equal(t, "foobar", string(data))
+	require.Equal(t, protocol.ID(protoOld), s.Protocol())
+	s.Close()
+
+	// the stale protocol was removed from the peerstore
+	supported, err := h1.Peerstore().SupportsProtocols(h2.ID(), protoNew)
+	require.NoError(t, err)
+	require.Empty(t, supported)
+}
+
 func TestAddrChangeImmediatelyIfAddressNonEmpty(t *testing.T) {
 	ctx := context.Background()
 	taddrs := []ma.Multiaddr{ma.StringCast("/ip4/1.2.3.4/tcp/1234")}
@@ -679,6 +738,62 @@ func TestHostAddrChangeDetection(t *testing.T) {
 	}
 }
 
+func TestHostAddrGracePeriod(t *testing.T) {
+	a := ma.StringCast("/ip4/1.2.3.4/tcp/1234")
+	b := ma.StringCast("/ip4/2.3.4.5/tcp/1234")
+	c := ma.StringCast("/ip4/3.4.5.6/tcp/1234")
+
+	var lk sync.Mutex
+	addrs := []ma.Multiaddr{a, b}
+	addrsFactory := func([]ma.Multiaddr) []ma.Multiaddr {
+		lk.Lock()
+		defer lk.Unlock()
+		return addrs
+	}
+
+	const gracePeriod = 500 * time.Millisecond
+	h, err := NewHost(swarmt.GenSwarm(t), &HostOpts{AddrsFactory: addrsFactory, AddrGracePeriod: gracePeriod})
+	require.NoError(t, err)
+	sub, err := h.EventBus().Subscribe(&event.EvtLocalAddressesUpdated{}, eventbus.BufSize(10))
+	require.NoError(t, err)
+	defer sub.Close()
+	h.Start()
+	defer h.Close()
+
+	ctx := context.Background()
+	waitForAddrChangeEvent(ctx, sub, t)
+
+	lk.Lock()
+	addrs = []ma.Multiaddr{b, c}
+	lk.Unlock()
+	start := time.Now()
+	h.SignalAddressChange()
+	evt := waitForAddrChangeEvent(ctx, sub, t)
+	require.True(t, updatedAddrEventsEqual(event.EvtLocalAddressesUpdated{
+		Diffs: true,
+		Current: []event.UpdatedAddress{
+			{Action: event.Maintained, Address: b},
+			{Action: event.Added, Address: c},
+			{Action: event.Deprecated, Address: a},
+		},
+	}, evt), "unexpected event: %v", evt)
+	require.ElementsMatch(t, []ma.Multiaddr{a, b, c}, peerRecordFromEnvelope(t, evt.SignedPeerRecord).Addrs)
+	require.ElementsMatch(t, []ma.Multiaddr{a, b, c}, h.Addrs())
+
+	// the deprecated address is removed once the grace period expires
+	evt = waitForAddrChangeEvent(ctx, sub, t)
+	require.GreaterOrEqual(t, time.Since(start), gracePeriod)
+	require.True(t, updatedAddrEventsEqual(event.EvtLocalAddressesUpdated{
+		Diffs: true,
+		Current: []event.UpdatedAddress{
+			{Action: event.Maintained, Address: b},
+			{Action: event.Maintained, Address: c},
+		},
+		Removed: []event.UpdatedAddress{{Action: event.Removed, Address: a}},
+	}, evt), "unexpected event: %v", evt)
+	require.ElementsMatch(t, []ma.Multiaddr{b, c}, h.Addrs())
+}
+
 func TestNegotiationCancel(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -792,3 +907,36 @@ func peerRecordFromEnvelope(t *testing.T, ev *record.Envelope) *peer.PeerRecord
 	}
 	return peerRec
 }
+
+func TestFirstStreamNegotiationTimeout(t *testing.T) {
+	h, err := NewHost(swarmt.GenSwarm(t), &HostOpts{FirstStreamNegotiationTimeout: 100 * time.Millisecond})
+	require.NoError(t, err)
+	defer h.Close()
+	h.Start()
+
+	// use a bare swarm, so no identify stream is opened on the connection
+	s := swarmt.GenSwarm(t)
+	defer s.Close()
+	s.Peerstore().AddAddrs(h.ID(), h.Addrs(), peerstore.PermanentAddrTTL)
+	_, err = s.DialPeer(context.Background(), h.ID())
+	require.NoError(t, err)
+
+	// the first stream is reset if it's not negotiated in time
+	str1, err := s.NewStream(context.Background(), h.ID())
+	require.NoError(t, err)
+	// streams are opened lazily, so send the beginning of a multistream header
+	_, err = str1.Write([]byte{19})
+	require.NoError(t, err)
+	str1.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, err = io.ReadAll(str1)
+	require.ErrorIs(t, err, network.ErrReset)
+
+	// subsequent streams use the default timeout
+	str2, err := s.NewStream(context.Background(), h.ID())
+	require.NoError(t, err)
+	_, err = str2.Write([]byte{19})
+	require.NoError(t, err)
+	str2.SetReadDeadline(time.Now().Add(500 * time.Millisecond))
+	_, err = io.ReadAll(str2)
+	require.ErrorIs(t, err, os.ErrDeadlineExceeded)
+}
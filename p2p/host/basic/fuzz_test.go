@@ -0,0 +1,54 @@
+package basichost
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/AstaFrode/go-libp2p/core/network"
+	"github.com/AstaFrode/go-libp2p/core/protocol"
+	swarmt "github.com/AstaFrode/go-libp2p/p2p/net/swarm/testing"
+
+	msmux "github.com/multiformats/go-multistream"
+	"github.com/stretchr/testify/require"
+)
+
+type fuzzStream struct {
+	io.Reader
+	io.Writer
+}
+
+func (s *fuzzStream) Close() error { return nil }
+
+// FuzzNegotiate runs the multistream negotiation of inbound streams on arbitrary input.
+func FuzzNegotiate(f *testing.F) {
+	h, err := NewHost(swarmt.GenSwarmTB(f), nil)
+	require.NoError(f, err)
+	defer h.Close()
+	h.SetStreamHandler("/foo/1.0.0", func(network.Stream) {})
+	h.SetStreamHandlerMatch("/bar", func(id protocol.ID) bool { return bytes.HasPrefix([]byte(id), []byte("/bar/")) }, func(network.Stream) {})
+
+	f.Add([]byte("\x13/multistream/1.0.0\n\x0b/foo/1.0.0\n"))
+	f.Add([]byte("\x13/multistream/1.0.0\n\x0b/bar/2.0.0\n"))
+	f.Add([]byte("\x13/multistream/1.0.0\n\x03ls\n"))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		h.Mux().Negotiate(&fuzzStream{Reader: bytes.NewReader(data), Writer: io.Discard})
+	})
+}
+
+// FuzzSelect runs the multistream negotiation of outbound streams on arbitrary responses.
+func FuzzSelect(f *testing.F) {
+	f.Add([]byte("\x13/multistream/1.0.0\n\x0b/foo/1.0.0\n"))
+	f.Add([]byte("\x13/multistream/1.0.0\n\x03na\n\x0b/bar/1.0.0\n"))
+
+	pids := []protocol.ID{"/foo/1.0.0", "/bar/1.0.0"}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		msmux.SelectOneOf(pids, &fuzzStream{Reader: bytes.NewReader(data), Writer: io.Discard})
+
+		// the lazy negotiation used by optimistic streams
+		lazy := msmux.NewMSSelect(&fuzzStream{Reader: bytes.NewReader(data), Writer: io.Discard}, pids[0])
+		lazy.Write([]byte("foo"))
+		io.ReadAll(io.LimitReader(lazy, 1<<10))
+	})
+}
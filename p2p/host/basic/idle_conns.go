@@ -0,0 +1,123 @@
+package basichost
+
+import (
+	"sync"
+	"time"
+
+	"github.com/AstaFrode/go-libp2p/core/event"
+	"github.com/AstaFrode/go-libp2p/core/network"
+)
+
+// idleConnCloser closes connections that haven't been used for a while.
+// A connection is in use while it has open streams. Streams that are opened and closed
+// between two checks are accounted for by the host calling streamOpened.
+type idleConnCloser struct {
+	h       *BasicHost
+	timeout time.Duration
+	emitter event.Emitter
+
+	mx         sync.Mutex
+	lastActive map[network.Conn]time.Time
+}
+
+func newIdleConnCloser(h *BasicHost, timeout time.Duration) (*idleConnCloser, error) {
+	emitter, err := h.eventbus.Emitter(new(event.EvtConnectionIdleClosed))
+	if err != nil {
+		return nil, err
+	}
+	c := &idleConnCloser{
+		h:          h,
+		timeout:    timeout,
+		emitter:    emitter,
+		lastActive: make(map[network.Conn]time.Time),
+	}
+	h.Network().Notify(&network.NotifyBundle{
+		DisconnectedF: func(_ network.Network, conn network.Conn) {
+			c.mx.Lock()
+			delete(c.lastActive, conn)
+			c.mx.Unlock()
+		},
+	})
+	return c, nil
+}
+
+// run checks for idle connections until the host is closed.
+func (c *idleConnCloser) run() {
+	ticker := time.NewTicker(c.timeout / 4)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			c.closeIdle(now)
+		case <-c.h.ctx.Done():
+			return
+		}
+	}
+}
+
+// streamOpened marks conn as active.
+func (c *idleConnCloser) streamOpened(conn network.Conn) {
+	c.mx.Lock()
+	c.lastActive[conn] = time.Now()
+	c.mx.Unlock()
+}
+
+func (c *idleConnCloser) closeIdle(now time.Time) {
+	conns := c.h.Network().Conns()
+	c.gc(conns)
+	for _, conn := range conns {
+		idleFor, ok := c.idleFor(conn, now)
+		if !ok || idleFor < c.timeout {
+			continue
+		}
+		p := conn.RemotePeer()
+		if c.h.cmgr.IsProtected(p, "") {
+			continue
+		}
+		c.h.logger.Debugw("closing idle connection", "peer", p, "addr", conn.RemoteMultiaddr(), "idle", idleFor)
+		conn.Close()
+		c.mx.Lock()
+		delete(c.lastActive, conn)
+		c.mx.Unlock()
+		c.emitter.Emit(event.EvtConnectionIdleClosed{Peer: p, Conn: conn, IdleFor: idleFor})
+	}
+}
+
+// gc forgets the connections that are not open anymore. streamOpened can race with the
+// Disconnected notification and add a connection after it was closed.
+func (c *idleConnCloser) gc(conns []network.Conn) {
+	open := make(map[network.Conn]struct{}, len(conns))
+	for _, conn := range conns {
+		open[conn] = struct{}{}
+	}
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	for conn := range c.lastActive {
+		if _, ok := open[conn]; !ok {
+			delete(c.lastActive, conn)
+		}
+	}
+}
+
+// idleFor returns the time since conn was last used. It returns false if conn has open streams.
+func (c *idleConnCloser) idleFor(conn network.Conn, now time.Time) (time.Duration, bool) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	if len(conn.GetStreams()) > 0 {
+		c.lastActive[conn] = now
+		return 0, false
+	}
+	last, ok := c.lastActive[conn]
+	if !ok {
+		last = conn.Stat().Opened
+		if last.IsZero() {
+			last = now
+		}
+		c.lastActive[conn] = last
+	}
+	return now.Sub(last), true
+}
+
+func (c *idleConnCloser) Close() error {
+	return c.emitter.Close()
+}
@@ -0,0 +1,94 @@
+package basichost
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/AstaFrode/go-libp2p/core/event"
+	"github.com/AstaFrode/go-libp2p/core/network"
+	"github.com/AstaFrode/go-libp2p/core/peer"
+	"github.com/AstaFrode/go-libp2p/p2p/net/connmgr"
+	swarmt "github.com/AstaFrode/go-libp2p/p2p/net/swarm/testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdleConnTimeout(t *testing.T) {
+	const timeout = 200 * time.Millisecond
+	cm, err := connmgr.NewConnManager(10, 20)
+	require.NoError(t, err)
+	h1, err := NewHost(swarmt.GenSwarm(t), &HostOpts{ConnManager: cm, IdleConnTimeout: timeout})
+	require.NoError(t, err)
+	defer h1.Close()
+	h1.Start()
+	sub, err := h1.EventBus().Subscribe(new(event.EvtConnectionIdleClosed))
+	require.NoError(t, err)
+	defer sub.Close()
+
+	newPeer := func() *BasicHost {
+		h, err := NewHost(swarmt.GenSwarm(t), nil)
+		require.NoError(t, err)
+		t.Cleanup(func() { h.Close() })
+		h.Start()
+		h.SetStreamHandler("/test", func(s network.Stream) {})
+		require.NoError(t, h1.Connect(context.Background(), peer.AddrInfo{ID: h.ID(), Addrs: h.Addrs()}))
+		return h
+	}
+	idle := newPeer()
+	busy := newPeer()
+	protected := newPeer()
+	cm.Protect(protected.ID(), "test")
+
+	// keep a stream open to busy
+	s, err := h1.NewStream(context.Background(), busy.ID(), "/test")
+	require.NoError(t, err)
+	defer s.Reset()
+
+	select {
+	case e := <-sub.Out():
+		evt := e.(event.EvtConnectionIdleClosed)
+		require.Equal(t, idle.ID(), evt.Peer)
+		require.GreaterOrEqual(t, evt.IdleFor, timeout)
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the idle connection to be closed")
+	}
+	require.Equal(t, network.NotConnected, h1.Network().Connectedness(idle.ID()))
+
+	time.Sleep(2 * timeout)
+	require.Equal(t, network.Connected, h1.Network().Connectedness(busy.ID()))
+	require.Equal(t, network.Connected, h1.Network().Connectedness(protected.ID()))
+	select {
+	case e := <-sub.Out():
+		t.Fatalf("unexpected event: %v", e)
+	default:
+	}
+}
+
+func TestIdleConnClosedConnForgotten(t *testing.T) {
+	h1, err := NewHost(swarmt.GenSwarm(t), &HostOpts{IdleConnTimeout: time.Hour})
+	require.NoError(t, err)
+	defer h1.Close()
+	h2, err := NewHost(swarmt.GenSwarm(t), nil)
+	require.NoError(t, err)
+	defer h2.Close()
+	require.NoError(t, h1.Connect(context.Background(), peer.AddrInfo{ID: h2.ID(), Addrs: h2.Addrs()}))
+	conn := h1.Network().ConnsToPeer(h2.ID())[0]
+	h1.idleConns.streamOpened(conn)
+	require.NoError(t, conn.Close())
+
+	// wait for the Disconnected notification, then simulate a stream opened concurrently
+	// with the close, marking the conn after it was removed
+	require.Eventually(t, func() bool {
+		h1.idleConns.mx.Lock()
+		defer h1.idleConns.mx.Unlock()
+		_, ok := h1.idleConns.lastActive[conn]
+		return !ok
+	}, 5*time.Second, 10*time.Millisecond)
+	h1.idleConns.streamOpened(conn)
+
+	h1.idleConns.closeIdle(time.Now())
+	h1.idleConns.mx.Lock()
+	defer h1.idleConns.mx.Unlock()
+	require.NotContains(t, h1.idleConns.lastActive, conn)
+}
@@ -0,0 +1,32 @@
+package basichost
+
+import "github.com/AstaFrode/go-libp2p/core/network"
+
+// Logger is a structured, leveled logger. The key-value pairs are passed as
+// alternating keys and values, like zap's SugaredLogger, which implements it.
+//
+// The host logs the peer ID, and the connection and stream IDs where applicable,
+// so that logs of the same connection can be correlated.
+type Logger interface {
+	Debugw(msg string, keysAndValues ...interface{})
+	Infow(msg string, keysAndValues ...interface{})
+	Warnw(msg string, keysAndValues ...interface{})
+	Errorw(msg string, keysAndValues ...interface{})
+}
+
+var _ Logger = log
+
+// streamFields returns the key-value pairs identifying the stream s, followed by
+// keysAndValues. The connection and stream IDs are only formatted when the entry is
+// actually logged.
+func streamFields(s network.Stream, keysAndValues ...interface{}) []interface{} {
+	return append([]interface{}{"peer", s.Conn().RemotePeer(), "conn", connID{s.Conn()}, "stream", streamID{s}}, keysAndValues...)
+}
+
+type connID struct{ c network.Conn }
+
+func (id connID) String() string { return id.c.ID() }
+
+type streamID struct{ s network.Stream }
+
+func (id streamID) String() string { return id.s.ID() }
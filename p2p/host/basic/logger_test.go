@@ -0,0 +1,80 @@
+package basichost
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AstaFrode/go-libp2p/core/peer"
+	swarmt "github.com/AstaFrode/go-libp2p/p2p/net/swarm/testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type logEntry struct {
+	level, msg string
+	fields     map[string]interface{}
+}
+
+type recordingLogger struct {
+	mx      sync.Mutex
+	entries []logEntry
+}
+
+func (l *recordingLogger) log(level, msg string, keysAndValues []interface{}) {
+	fields := make(map[string]interface{}, len(keysAndValues)/2)
+	for i := 0; i+1 < len(keysAndValues); i += 2 {
+		fields[fmt.Sprint(keysAndValues[i])] = keysAndValues[i+1]
+	}
+	l.mx.Lock()
+	l.entries = append(l.entries, logEntry{level: level, msg: msg, fields: fields})
+	l.mx.Unlock()
+}
+
+func (l *recordingLogger) find(msg string) (logEntry, bool) {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+	for _, e := range l.entries {
+		if e.msg == msg {
+			return e, true
+		}
+	}
+	return logEntry{}, false
+}
+
+func (l *recordingLogger) Debugw(msg string, kv ...interface{}) { l.log("debug", msg, kv) }
+func (l *recordingLogger) Infow(msg string, kv ...interface{})  { l.log("info", msg, kv) }
+func (l *recordingLogger) Warnw(msg string, kv ...interface{})  { l.log("warn", msg, kv) }
+func (l *recordingLogger) Errorw(msg string, kv ...interface{}) { l.log("error", msg, kv) }
+
+func TestHostLogger(t *testing.T) {
+	logger := &recordingLogger{}
+	h1, err := NewHost(swarmt.GenSwarm(t), &HostOpts{Logger: logger})
+	require.NoError(t, err)
+	defer h1.Close()
+	h1.Start()
+	h2, err := NewHost(swarmt.GenSwarm(t), nil)
+	require.NoError(t, err)
+	defer h2.Close()
+	h2.Start()
+
+	require.NoError(t, h2.Connect(context.Background(), peer.AddrInfo{ID: h1.ID(), Addrs: h1.Addrs()}))
+	_, err = h2.NewStream(context.Background(), h1.ID(), "/unknown")
+	require.Error(t, err)
+
+	var entry logEntry
+	require.Eventually(t, func() bool {
+		var ok bool
+		entry, ok = logger.find("protocol mux failed")
+		return ok
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, "debug", entry.level)
+	require.Equal(t, h2.ID(), entry.fields["peer"])
+	conns := h1.Network().ConnsToPeer(h2.ID())
+	require.Len(t, conns, 1)
+	require.Equal(t, conns[0].ID(), fmt.Sprint(entry.fields["conn"]))
+	require.NotEmpty(t, fmt.Sprint(entry.fields["stream"]))
+	require.Contains(t, entry.fields, "error")
+}
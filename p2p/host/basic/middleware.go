@@ -0,0 +1,63 @@
+package basichost
+
+import (
+	"runtime/debug"
+
+	"github.com/AstaFrode/go-libp2p/core/network"
+)
+
+// StreamMiddleware wraps the handler of incoming streams, like HTTP middleware wraps HTTP handlers.
+//
+// The protocol of the stream is negotiated before the middleware is called, so it can be read
+// using Stream.Protocol. Middleware can inspect the stream before calling next, reset the stream
+// instead of calling next (e.g. to reject unauthorized peers), or run code once next returned.
+type StreamMiddleware func(next network.StreamHandler) network.StreamHandler
+
+// Use appends middleware to the chain wrapping the handlers of incoming streams.
+//
+// The chain applies to all handlers set using SetStreamHandler and SetStreamHandlerMatch,
+// including the handlers of the services of the host, and the handlers set before Use was
+// called. Middleware runs in the order it was added: the first middleware is the outermost one.
+func (h *BasicHost) Use(mw ...StreamMiddleware) {
+	h.middlewareMx.Lock()
+	defer h.middlewareMx.Unlock()
+
+	var chain []StreamMiddleware
+	if prev := h.middleware.Load(); prev != nil {
+		chain = append(chain, *prev...)
+	}
+	chain = append(chain, mw...)
+	h.middleware.Store(&chain)
+}
+
+// wrapHandler wraps handler in the middleware chain.
+func (h *BasicHost) wrapHandler(handler network.StreamHandler) network.StreamHandler {
+	chain := h.middleware.Load()
+	if chain == nil {
+		return handler
+	}
+	for i := len(*chain) - 1; i >= 0; i-- {
+		handler = (*chain)[i](handler)
+	}
+	return handler
+}
+
+// RecoverMiddleware returns a middleware recovering from panics in stream handlers. The panic is
+// logged to logger with the stack trace, and the stream is reset. If logger is nil, the panic is
+// logged to the "basichost" go-log logger.
+func RecoverMiddleware(logger Logger) StreamMiddleware {
+	if logger == nil {
+		logger = log
+	}
+	return func(next network.StreamHandler) network.StreamHandler {
+		return func(s network.Stream) {
+			defer func() {
+				if rerr := recover(); rerr != nil {
+					logger.Errorw("caught panic in stream handler", streamFields(s, "protocol", s.Protocol(), "panic", rerr, "stack", string(debug.Stack()))...)
+					s.Reset()
+				}
+			}()
+			next(s)
+		}
+	}
+}
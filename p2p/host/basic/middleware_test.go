@@ -0,0 +1,100 @@
+package basichost
+
+import (
+	"context"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AstaFrode/go-libp2p/core/network"
+	"github.com/AstaFrode/go-libp2p/core/protocol"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamMiddleware(t *testing.T) {
+	h1, h2 := getHostPair(t)
+	defer h1.Close()
+	defer h2.Close()
+
+	var mx sync.Mutex
+	var calls []string
+	record := func(name string) StreamMiddleware {
+		return func(next network.StreamHandler) network.StreamHandler {
+			return func(s network.Stream) {
+				// ignore the streams of the services of the host, e.g. identify
+				if p := s.Protocol(); p == "/accepted" || p == "/rejected" {
+					mx.Lock()
+					calls = append(calls, name+" "+string(p))
+					mx.Unlock()
+				}
+				next(s)
+			}
+		}
+	}
+	reject := func(next network.StreamHandler) network.StreamHandler {
+		return func(s network.Stream) {
+			if s.Protocol() == "/rejected" {
+				s.Reset()
+				return
+			}
+			next(s)
+		}
+	}
+
+	handled := make(chan protocol.ID, 2)
+	handler := func(s network.Stream) {
+		defer s.Close()
+		handled <- s.Protocol()
+		s.Write([]byte("ok"))
+	}
+	// the middleware also applies to handlers set before it was added
+	h2.SetStreamHandler("/accepted", handler)
+	h2.(*BasicHost).Use(record("first"), reject)
+	h2.(*BasicHost).Use(record("second"))
+	h2.SetStreamHandler("/rejected", handler)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	s, err := h1.NewStream(ctx, h2.ID(), "/accepted")
+	require.NoError(t, err)
+	b, err := io.ReadAll(s)
+	require.NoError(t, err)
+	require.Equal(t, "ok", string(b))
+	require.Equal(t, protocol.ID("/accepted"), <-handled)
+
+	// depending on timing, the reset is either noticed when negotiating the protocol or when reading
+	s, err = h1.NewStream(ctx, h2.ID(), "/rejected")
+	if err == nil {
+		_, err = io.ReadAll(s)
+	}
+	require.Error(t, err)
+	select {
+	case p := <-handled:
+		t.Fatalf("unexpected stream handled: %s", p)
+	default:
+	}
+
+	mx.Lock()
+	defer mx.Unlock()
+	require.Equal(t, []string{"first /accepted", "second /accepted", "first /rejected"}, calls)
+}
+
+func TestRecoverMiddleware(t *testing.T) {
+	h1, h2 := getHostPair(t)
+	defer h1.Close()
+	defer h2.Close()
+
+	h2.(*BasicHost).Use(RecoverMiddleware(nil))
+	h2.SetStreamHandler("/panic", func(s network.Stream) { panic("oops") })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	s, err := h1.NewStream(ctx, h2.ID(), "/panic")
+	if err == nil {
+		_, err = io.ReadAll(s)
+	}
+	require.Error(t, err)
+}
@@ -6,9 +6,12 @@ import (
 	"net"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/AstaFrode/go-libp2p/core/event"
 	"github.com/AstaFrode/go-libp2p/core/network"
+	"github.com/AstaFrode/go-libp2p/p2p/host/eventbus"
 	inat "github.com/AstaFrode/go-libp2p/p2p/net/nat"
 
 	ma "github.com/multiformats/go-multiaddr"
@@ -25,6 +28,18 @@ type NATManager interface {
 	io.Closer
 }
 
+// gatewayCheckInterval is the interval at which we look for a (new) NAT device.
+// This allows us to notice when we switch networks.
+// If no NAT device is found, the interval is doubled after every attempt,
+// up to maxGatewayCheckInterval.
+var (
+	gatewayCheckInterval    = 5 * time.Minute
+	maxGatewayCheckInterval = time.Hour
+)
+
+// discoverNAT looks for a NAT device. Tests replace it.
+var discoverNAT = inat.DiscoverNAT
+
 // NewNATManager creates a NAT manager.
 func NewNATManager(net network.Network) NATManager {
 	return newNatManager(net)
@@ -37,13 +52,24 @@ func NewNATManager(net network.Network) NATManager {
 //     as the network signals Listen() or ListenClose().
 //   - closing the natManager closes the nat and its mappings.
 type natManager struct {
-	net   network.Network
-	natMx sync.RWMutex
-	nat   *inat.NAT
+	net        network.Network
+	natMx      sync.RWMutex
+	nat        *inat.NAT
+	deviceType string
+	deviceAddr net.IP
+	discovered bool
+
+	emitMx   sync.Mutex
+	emitters struct {
+		evtMappingChanged event.Emitter
+		evtDeviceChanged  event.Emitter
+	}
 
 	ready    chan struct{} // closed once the nat is ready to process port mappings
 	syncFlag chan struct{}
 
+	logger atomic.Pointer[Logger]
+
 	refCount  sync.WaitGroup
 	ctxCancel context.CancelFunc
 }
@@ -66,9 +92,79 @@ func newNatManager(net network.Network) *natManager {
 func (nmgr *natManager) Close() error {
 	nmgr.ctxCancel()
 	nmgr.refCount.Wait()
+	nmgr.emitMx.Lock()
+	defer nmgr.emitMx.Unlock()
+	if nmgr.emitters.evtMappingChanged != nil {
+		nmgr.emitters.evtMappingChanged.Close()
+		nmgr.emitters.evtDeviceChanged.Close()
+	}
+	return nil
+}
+
+// setLogger sets the logger used by the natManager. It defaults to the "basichost" go-log logger.
+func (nmgr *natManager) setLogger(l Logger) {
+	nmgr.logger.Store(&l)
+}
+
+func (nmgr *natManager) log() Logger {
+	if l := nmgr.logger.Load(); l != nil {
+		return *l
+	}
+	return log
+}
+
+// setEventBus makes the natManager emit EvtNATDeviceChanged and
+// EvtNATPortMappingChanged events on the bus.
+func (nmgr *natManager) setEventBus(bus event.Bus) error {
+	mappingEm, err := bus.Emitter(new(event.EvtNATPortMappingChanged))
+	if err != nil {
+		return err
+	}
+	deviceEm, err := bus.Emitter(new(event.EvtNATDeviceChanged), eventbus.Stateful)
+	if err != nil {
+		mappingEm.Close()
+		return err
+	}
+	nmgr.emitMx.Lock()
+	nmgr.emitters.evtMappingChanged = mappingEm
+	nmgr.emitters.evtDeviceChanged = deviceEm
+	nmgr.emitMx.Unlock()
+
+	// The NAT discovery might have finished before the bus was set.
+	nmgr.natMx.RLock()
+	discovered, evt := nmgr.discovered, nmgr.deviceEvent()
+	nmgr.natMx.RUnlock()
+	if discovered {
+		nmgr.emitDeviceChanged(evt)
+	}
 	return nil
 }
 
+// deviceEvent must be called with natMx held.
+func (nmgr *natManager) deviceEvent() event.EvtNATDeviceChanged {
+	return event.EvtNATDeviceChanged{
+		Found:      nmgr.nat != nil,
+		Type:       nmgr.deviceType,
+		DeviceAddr: nmgr.deviceAddr,
+	}
+}
+
+func (nmgr *natManager) emitDeviceChanged(evt event.EvtNATDeviceChanged) {
+	nmgr.emitMx.Lock()
+	defer nmgr.emitMx.Unlock()
+	if nmgr.emitters.evtDeviceChanged != nil {
+		nmgr.emitters.evtDeviceChanged.Emit(evt)
+	}
+}
+
+func (nmgr *natManager) emitMappingChanged(evt event.EvtNATPortMappingChanged) {
+	nmgr.emitMx.Lock()
+	defer nmgr.emitMx.Unlock()
+	if nmgr.emitters.evtMappingChanged != nil {
+		nmgr.emitters.evtMappingChanged.Emit(evt)
+	}
+}
+
 // Ready returns a channel which will be closed when the NAT has been found
 // and is ready to be used, or the search process is done.
 func (nmgr *natManager) Ready() <-chan struct{} {
@@ -86,37 +182,97 @@ func (nmgr *natManager) background(ctx context.Context) {
 		nmgr.natMx.Unlock()
 	}()
 
-	discoverCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
-	defer cancel()
-	natInstance, err := inat.DiscoverNAT(discoverCtx)
-	if err != nil {
-		log.Info("DiscoverNAT error:", err)
-		close(nmgr.ready)
-		return
-	}
-
-	nmgr.natMx.Lock()
-	nmgr.nat = natInstance
-	nmgr.natMx.Unlock()
+	nmgr.discover(ctx)
 	close(nmgr.ready)
 
-	// sign natManager up for network notifications
-	// we need to sign up here to avoid missing some notifs
-	// before the NAT has been found.
 	nmgr.net.Notify((*nmgrNetNotifiee)(nmgr))
 	defer nmgr.net.StopNotify((*nmgrNetNotifiee)(nmgr))
 
+	interval := gatewayCheckInterval
+	t := time.NewTimer(interval)
+	defer t.Stop()
+
 	nmgr.doSync() // sync one first.
 	for {
 		select {
 		case <-nmgr.syncFlag:
 			nmgr.doSync() // sync when our listen addresses chnage.
+		case <-t.C:
+			if nmgr.discover(ctx) {
+				nmgr.doSync() // create the mappings on the new NAT device
+			}
+			interval = nmgr.nextCheckInterval(interval)
+			t.Reset(interval)
 		case <-ctx.Done():
 			return
 		}
 	}
 }
 
+// nextCheckInterval returns the time until we look for a NAT device again.
+// As long as no NAT device is found, we back off exponentially.
+func (nmgr *natManager) nextCheckInterval(interval time.Duration) time.Duration {
+	if nmgr.NAT() != nil {
+		return gatewayCheckInterval
+	}
+	interval *= 2
+	if interval > maxGatewayCheckInterval {
+		interval = maxGatewayCheckInterval
+	}
+	return interval
+}
+
+// discover looks for a NAT device. If a different device was found, it replaces
+// the current NAT (removing all mappings), and returns true.
+// If no device was found, the current NAT and its mappings are kept: the discovery
+// might just have failed transiently.
+func (nmgr *natManager) discover(ctx context.Context) (changed bool) {
+	discoverCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	natInstance, err := discoverNAT(discoverCtx)
+	if err != nil {
+		nmgr.log().Infow("NAT discovery failed", "error", err)
+	}
+	var (
+		typ  string
+		addr net.IP
+	)
+	if natInstance != nil {
+		typ = natInstance.Type()
+		addr, _ = natInstance.DeviceAddress()
+	}
+
+	nmgr.natMx.Lock()
+	old := nmgr.nat
+	if natInstance == nil && old != nil {
+		nmgr.natMx.Unlock()
+		return false
+	}
+	if nmgr.discovered && (old == nil) == (natInstance == nil) && nmgr.deviceType == typ && nmgr.deviceAddr.Equal(addr) {
+		nmgr.natMx.Unlock()
+		if natInstance != nil {
+			natInstance.Close()
+		}
+		return false
+	}
+	if natInstance != nil {
+		natInstance.SetNotifier(nmgr.emitMappingChanged)
+	}
+	nmgr.nat = natInstance
+	nmgr.deviceType = typ
+	nmgr.deviceAddr = addr
+	nmgr.discovered = true
+	evt := nmgr.deviceEvent()
+	nmgr.natMx.Unlock()
+
+	if old != nil {
+		nmgr.log().Infow("NAT device changed", "type", typ, "addr", addr)
+		old.Close()
+	}
+	nmgr.emitDeviceChanged(evt)
+	return true
+}
+
 func (nmgr *natManager) sync() {
 	select {
 	case nmgr.syncFlag <- struct{}{}:
@@ -127,6 +283,10 @@ func (nmgr *natManager) sync() {
 // doSync syncs the current NAT mappings, removing any outdated mappings and adding any
 // new mappings.
 func (nmgr *natManager) doSync() {
+	nat := nmgr.NAT()
+	if nat == nil {
+		return
+	}
 	ports := map[string]map[int]bool{
 		"tcp": {},
 		"udp": {},
@@ -179,7 +339,7 @@ func (nmgr *natManager) doSync() {
 	defer wg.Wait()
 
 	// Close old mappings
-	for _, m := range nmgr.nat.Mappings() {
+	for _, m := range nat.Mappings() {
 		mappedPort := m.InternalPort()
 		if _, ok := ports[m.Protocol()][mappedPort]; !ok {
 			// No longer need this mapping.
@@ -203,9 +363,9 @@ func (nmgr *natManager) doSync() {
 			wg.Add(1)
 			go func(proto string, port int) {
 				defer wg.Done()
-				_, err := nmgr.nat.NewMapping(proto, port)
+				_, err := nat.NewMapping(proto, port)
 				if err != nil {
-					log.Errorf("failed to port-map %s port %d: %s", proto, port, err)
+					nmgr.log().Errorw("failed to port-map", "protocol", proto, "port", port, "error", err)
 				}
 			}(proto, port)
 		}
@@ -0,0 +1,96 @@
+package basichost
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AstaFrode/go-libp2p/core/network"
+	inat "github.com/AstaFrode/go-libp2p/p2p/net/nat"
+
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/require"
+)
+
+type mockGateway struct {
+	addr net.IP
+
+	mx       sync.Mutex
+	mappings map[int]int
+}
+
+func newMockGateway(addr net.IP) *mockGateway {
+	return &mockGateway{addr: addr, mappings: make(map[int]int)}
+}
+
+func (g *mockGateway) Type() string                        { return "mock" }
+func (g *mockGateway) GetDeviceAddress() (net.IP, error)   { return g.addr, nil }
+func (g *mockGateway) GetExternalAddress() (net.IP, error) { return net.IPv4(1, 2, 3, 4), nil }
+func (g *mockGateway) GetInternalAddress() (net.IP, error) { return net.IPv4(192, 168, 0, 2), nil }
+
+func (g *mockGateway) AddPortMapping(_ string, internalPort int, _ string, _ time.Duration) (int, error) {
+	g.mx.Lock()
+	defer g.mx.Unlock()
+	g.mappings[internalPort] = internalPort
+	return internalPort, nil
+}
+
+func (g *mockGateway) DeletePortMapping(_ string, internalPort int) error {
+	g.mx.Lock()
+	defer g.mx.Unlock()
+	delete(g.mappings, internalPort)
+	return nil
+}
+
+func (g *mockGateway) numMappings() int {
+	g.mx.Lock()
+	defer g.mx.Unlock()
+	return len(g.mappings)
+}
+
+type listenAddrsNetwork struct {
+	network.Network
+	addrs []ma.Multiaddr
+}
+
+func (n *listenAddrsNetwork) ListenAddresses() []ma.Multiaddr { return n.addrs }
+
+func TestNATDiscoveryFailureKeepsMappings(t *testing.T) {
+	gateway := newMockGateway(net.IPv4(192, 168, 0, 1))
+	var discoverErr error
+	nat := inat.NewNAT(gateway)
+	defer func(f func(context.Context) (*inat.NAT, error)) { discoverNAT = f }(discoverNAT)
+	discoverNAT = func(context.Context) (*inat.NAT, error) {
+		if discoverErr != nil {
+			return nil, discoverErr
+		}
+		return nat, nil
+	}
+
+	nmgr := &natManager{
+		net:      &listenAddrsNetwork{addrs: []ma.Multiaddr{ma.StringCast("/ip4/0.0.0.0/tcp/4001")}},
+		ready:    make(chan struct{}),
+		syncFlag: make(chan struct{}, 1),
+	}
+	require.True(t, nmgr.discover(context.Background()))
+	nmgr.doSync()
+	require.Len(t, nmgr.NAT().Mappings(), 1)
+	require.Equal(t, 1, gateway.numMappings())
+
+	// a failed discovery keeps the NAT and its mappings
+	discoverErr = errors.New("discovery failed")
+	require.False(t, nmgr.discover(context.Background()))
+	require.Equal(t, nat, nmgr.NAT())
+	require.Len(t, nmgr.NAT().Mappings(), 1)
+	require.Equal(t, 1, gateway.numMappings())
+
+	// a different device replaces the NAT
+	discoverErr = nil
+	nat = inat.NewNAT(newMockGateway(net.IPv4(192, 168, 1, 1)))
+	require.True(t, nmgr.discover(context.Background()))
+	require.Equal(t, nat, nmgr.NAT())
+	nmgr.NAT().Close()
+}
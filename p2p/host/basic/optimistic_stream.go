@@ -0,0 +1,322 @@
+package basichost
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/AstaFrode/go-libp2p/core/network"
+	"github.com/AstaFrode/go-libp2p/core/protocol"
+
+	msmux "github.com/multiformats/go-multistream"
+)
+
+// maxOptimisticReplay is the maximum number of bytes written to an optimistically
+// negotiated stream that are kept, in order to be replayed on a new stream
+// if the peer turns out not to support the protocol.
+const maxOptimisticReplay = 64 << 10
+
+// optimisticStream is a stream on which the protocol is selected optimistically,
+// based on the protocols the peerstore lists for the peer: the multistream header is
+// sent together with the first write, without waiting for the peer to confirm the protocol.
+//
+// If the peer rejects the protocol (the peerstore was outdated), the protocol is removed
+// from the peerstore, and the stream falls back to a new stream, using full negotiation
+// over the remaining protocols. The data written so far is replayed on the new stream,
+// unless it exceeds maxOptimisticReplay.
+type optimisticStream struct {
+	h    *BasicHost
+	pids []protocol.ID
+
+	startOnce sync.Once
+	// closed when the optimistic negotiation succeeded or failed, and the fallback
+	// (if any) was performed
+	negotiated chan struct{}
+
+	mx          sync.Mutex
+	s           network.Stream
+	rw          io.ReadWriteCloser
+	done        bool // negotiation completed, no need to buffer anymore
+	buf         []byte
+	bufOverflow bool
+	closedWrite bool
+	closed      bool
+	reset       bool
+	fallingBack bool // the protocol was rejected, and we're opening a new stream
+	fellBack    bool
+}
+
+var _ network.Stream = &optimisticStream{}
+
+func newOptimisticStream(h *BasicHost, s network.Stream, pref protocol.ID, pids []protocol.ID) *optimisticStream {
+	s.SetProtocol(pref)
+	return &optimisticStream{
+		h:          h,
+		pids:       pids,
+		negotiated: make(chan struct{}),
+		s:          s,
+		rw:         msmux.NewMSSelect(s, pref),
+	}
+}
+
+func (s *optimisticStream) current() (network.Stream, io.ReadWriteCloser) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return s.s, s.rw
+}
+
+// startNegotiation waits for the outcome of the optimistic negotiation in the background.
+// It must be called after the first use of the stream, so that the negotiation is
+// still performed lazily.
+func (s *optimisticStream) startNegotiation() {
+	s.startOnce.Do(func() {
+		_, rw := s.current()
+		go func() {
+			defer close(s.negotiated)
+			// a zero-length read waits for the read half of the handshake
+			_, err := rw.Read(nil)
+			s.handleNegotiated(err)
+		}()
+	})
+}
+
+func (s *optimisticStream) handleNegotiated(err error) {
+	s.mx.Lock()
+	old := s.s
+	pref := old.Protocol()
+	// Only fall back if the peer explicitly rejected the protocol. If the stream was reset,
+	// we can't tell if the peer processed the data we sent, and replaying it isn't safe.
+	var errNotSupported msmux.ErrNotSupported[protocol.ID]
+	if err == nil || !errors.As(err, &errNotSupported) {
+		if err == nil && s.h.protocolTagger != nil {
+			s.h.protocolTagger.streamOpened(old.Conn().RemotePeer(), pref)
+		}
+		s.done = true
+		s.buf = nil
+		s.mx.Unlock()
+		return
+	}
+
+	// the peerstore was outdated
+	p := old.Conn().RemotePeer()
+	s.h.Peerstore().RemoveProtocols(p, pref)
+	if s.bufOverflow || s.reset {
+		s.done = true
+		s.buf = nil
+		s.mx.Unlock()
+		return
+	}
+	buf := s.buf
+	s.fallingBack = true
+	s.mx.Unlock()
+
+	pids := make([]protocol.ID, 0, len(s.pids))
+	for _, pid := range s.pids {
+		if pid != pref {
+			pids = append(pids, pid)
+		}
+	}
+	ns, err := s.fallback(old, pids, buf)
+
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.done = true
+	s.buf = nil
+	if err != nil {
+		s.h.logger.Debugw("protocol negotiation fallback failed", streamFields(old, "error", err)...)
+		return
+	}
+	if s.h.protocolTagger != nil {
+		s.h.protocolTagger.streamOpened(p, ns.Protocol())
+	}
+	if s.reset {
+		ns.Reset()
+		return
+	}
+	if s.closed {
+		ns.Close()
+	} else if s.closedWrite {
+		ns.CloseWrite()
+	}
+	old.Reset()
+	s.s = ns
+	s.rw = ns
+	s.fellBack = true
+}
+
+// fallback opens a new stream on the same connection, fully negotiates one of pids,
+// and replays buf.
+func (s *optimisticStream) fallback(old network.Stream, pids []protocol.ID, buf []byte) (network.Stream, error) {
+	if len(pids) == 0 {
+		return nil, errors.New("no protocols left")
+	}
+	timeout := s.h.negtimeout
+	if timeout <= 0 {
+		timeout = DefaultNegotiationTimeout
+	}
+	ctx, cancel := context.WithTimeout(s.h.ctx, timeout)
+	defer cancel()
+	ctx = network.WithNoDial(ctx, "protocol negotiation fallback")
+	if old.Conn().Stat().Transient {
+		ctx = network.WithUseTransient(ctx, "protocol negotiation fallback")
+	}
+	// Don't use NewStream: it would select the protocol optimistically again.
+	ns, err := s.h.Network().NewStream(ctx, old.Conn().RemotePeer())
+	if err != nil {
+		return nil, err
+	}
+	if err := s.h.negotiateProtocol(ctx, ns, pids); err != nil {
+		return nil, err
+	}
+	if len(buf) > 0 {
+		if _, err := ns.Write(buf); err != nil {
+			ns.Reset()
+			return nil, err
+		}
+	}
+	return ns, nil
+}
+
+// waitFallback waits for the outcome of the negotiation, and returns the stream to use
+// if we fell back to a new stream.
+func (s *optimisticStream) waitFallback() (io.ReadWriteCloser, bool) {
+	<-s.negotiated
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return s.rw, s.fellBack
+}
+
+func (s *optimisticStream) Read(b []byte) (int, error) {
+	_, rw := s.current()
+	s.startNegotiation()
+	n, err := rw.Read(b)
+	if err != nil && n == 0 {
+		if nrw, ok := s.waitFallback(); ok && nrw != rw {
+			return nrw.Read(b)
+		}
+	}
+	return n, err
+}
+
+func (s *optimisticStream) Write(b []byte) (int, error) {
+	s.mx.Lock()
+	if s.fallingBack && !s.done {
+		s.mx.Unlock()
+		rw, _ := s.waitFallback()
+		return rw.Write(b)
+	}
+	if !s.done && !s.bufOverflow {
+		if len(s.buf)+len(b) > maxOptimisticReplay {
+			s.bufOverflow = true
+			s.buf = nil
+		} else {
+			s.buf = append(s.buf, b...)
+		}
+	}
+	rw := s.rw
+	s.mx.Unlock()
+
+	n, err := rw.Write(b)
+	s.startNegotiation()
+	if err != nil {
+		// the data was buffered, and replayed on the new stream
+		if _, ok := s.waitFallback(); ok {
+			return len(b), nil
+		}
+	}
+	return n, err
+}
+
+func (s *optimisticStream) Close() error {
+	s.mx.Lock()
+	s.closed = true
+	rw := s.rw
+	s.mx.Unlock()
+	return rw.Close()
+}
+
+func (s *optimisticStream) CloseWrite() error {
+	s.mx.Lock()
+	s.closedWrite = true
+	st, rw := s.s, s.rw
+	s.mx.Unlock()
+
+	// Flush the handshake before closing, but ignore the error. The other
+	// end may have closed their side for reading.
+	//
+	// If something is wrong with the stream, the user will get on error on
+	// read instead.
+	if flusher, ok := rw.(interface{ Flush() error }); ok {
+		_ = flusher.Flush()
+		s.startNegotiation()
+	}
+	return st.CloseWrite()
+}
+
+func (s *optimisticStream) CloseRead() error {
+	st, _ := s.current()
+	return st.CloseRead()
+}
+
+func (s *optimisticStream) Reset() error {
+	s.mx.Lock()
+	s.reset = true
+	st := s.s
+	s.mx.Unlock()
+	return st.Reset()
+}
+
+func (s *optimisticStream) ResetWithError(errCode network.StreamErrorCode) error {
+	s.mx.Lock()
+	s.reset = true
+	st := s.s
+	s.mx.Unlock()
+	return network.ResetWithError(st, errCode)
+}
+
+func (s *optimisticStream) SetDeadline(t time.Time) error {
+	st, _ := s.current()
+	return st.SetDeadline(t)
+}
+
+func (s *optimisticStream) SetReadDeadline(t time.Time) error {
+	st, _ := s.current()
+	return st.SetReadDeadline(t)
+}
+
+func (s *optimisticStream) SetWriteDeadline(t time.Time) error {
+	st, _ := s.current()
+	return st.SetWriteDeadline(t)
+}
+
+func (s *optimisticStream) ID() string {
+	st, _ := s.current()
+	return st.ID()
+}
+
+func (s *optimisticStream) Protocol() protocol.ID {
+	st, _ := s.current()
+	return st.Protocol()
+}
+
+func (s *optimisticStream) SetProtocol(id protocol.ID) error {
+	st, _ := s.current()
+	return st.SetProtocol(id)
+}
+
+func (s *optimisticStream) Stat() network.Stats {
+	st, _ := s.current()
+	return st.Stat()
+}
+
+func (s *optimisticStream) Conn() network.Conn {
+	st, _ := s.current()
+	return st.Conn()
+}
+
+func (s *optimisticStream) Scope() network.StreamScope {
+	st, _ := s.current()
+	return st.Scope()
+}
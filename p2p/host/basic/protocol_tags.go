@@ -0,0 +1,146 @@
+package basichost
+
+import (
+	"errors"
+	"time"
+
+	"github.com/AstaFrode/go-libp2p/core/connmgr"
+	"github.com/AstaFrode/go-libp2p/core/event"
+	"github.com/AstaFrode/go-libp2p/core/peer"
+	"github.com/AstaFrode/go-libp2p/core/protocol"
+	"github.com/AstaFrode/go-libp2p/p2p/host/eventbus"
+)
+
+const (
+	protocolTagPrefix       = "protocol:"
+	protocolActiveTagPrefix = "protocol-active:"
+)
+
+// protocolActiveTagTTL is the time after which the active tag of a protocol is removed
+// from a peer, if no stream using the protocol was opened with it since.
+const protocolActiveTagTTL = 10 * time.Minute
+
+// ProtocolTagWeights are the weights of the connection manager tags the host applies to
+// peers depending on their use of a protocol. When trimming connections, the connection
+// manager keeps the peers with the highest total weight.
+type ProtocolTagWeights struct {
+	// Supported is the weight of peers that support the protocol, as reported by identify.
+	Supported int
+	// Active is the weight of peers with which a stream using the protocol was opened,
+	// by either side, in the last 10 minutes. It requires a connection manager that
+	// supports decaying tags.
+	Active int
+}
+
+// protocolTagger tags peers in the connection manager, depending on the protocols they
+// support and use.
+type protocolTagger struct {
+	cmgr    connmgr.ConnManager
+	weights map[protocol.ID]ProtocolTagWeights
+	active  map[protocol.ID]connmgr.DecayingTag
+	sub     event.Subscription
+}
+
+func newProtocolTagger(h *BasicHost, weights map[protocol.ID]ProtocolTagWeights) (*protocolTagger, error) {
+	t := &protocolTagger{
+		cmgr:    h.cmgr,
+		weights: weights,
+		active:  make(map[protocol.ID]connmgr.DecayingTag),
+	}
+	decayer, hasDecay := connmgr.SupportsDecay(h.cmgr)
+	for pid, w := range weights {
+		if w.Active == 0 {
+			continue
+		}
+		if !hasDecay {
+			return nil, errors.New("active protocol tags require a connection manager that supports decaying tags")
+		}
+		tag, err := decayer.RegisterDecayingTag(
+			protocolActiveTagPrefix+string(pid),
+			time.Minute,
+			connmgr.DecayExpireWhenInactive(protocolActiveTagTTL),
+			connmgr.BumpOverwrite(),
+		)
+		if err != nil {
+			t.closeTags()
+			return nil, err
+		}
+		t.active[pid] = tag
+	}
+
+	sub, err := h.eventbus.Subscribe(
+		[]interface{}{new(event.EvtPeerIdentificationCompleted), new(event.EvtPeerProtocolsUpdated)},
+		eventbus.Name("protocol tagger"),
+	)
+	if err != nil {
+		t.closeTags()
+		return nil, err
+	}
+	t.sub = sub
+	return t, nil
+}
+
+// run updates the supported protocol tags when the protocols of a peer change, until
+// the subscription is closed.
+func (t *protocolTagger) run(supports func(peer.ID, ...protocol.ID) ([]protocol.ID, error)) {
+	pids := make([]protocol.ID, 0, len(t.weights))
+	for pid, w := range t.weights {
+		if w.Supported != 0 {
+			pids = append(pids, pid)
+		}
+	}
+	for e := range t.sub.Out() {
+		var p peer.ID
+		switch evt := e.(type) {
+		case event.EvtPeerIdentificationCompleted:
+			p = evt.Peer
+		case event.EvtPeerProtocolsUpdated:
+			p = evt.Peer
+		}
+		if len(pids) == 0 {
+			continue
+		}
+		supported, err := supports(p, pids...)
+		if err != nil {
+			continue
+		}
+		t.tagSupported(p, supported)
+	}
+}
+
+func (t *protocolTagger) tagSupported(p peer.ID, supported []protocol.ID) {
+	isSupported := make(map[protocol.ID]struct{}, len(supported))
+	for _, pid := range supported {
+		isSupported[pid] = struct{}{}
+	}
+	for pid, w := range t.weights {
+		if w.Supported == 0 {
+			continue
+		}
+		if _, ok := isSupported[pid]; ok {
+			t.cmgr.TagPeer(p, protocolTagPrefix+string(pid), w.Supported)
+		} else {
+			t.cmgr.UntagPeer(p, protocolTagPrefix+string(pid))
+		}
+	}
+}
+
+// streamOpened bumps the active tag of protocol pid for peer p.
+func (t *protocolTagger) streamOpened(p peer.ID, pid protocol.ID) {
+	if tag, ok := t.active[pid]; ok {
+		tag.Bump(p, t.weights[pid].Active)
+	}
+}
+
+// closeTags unregisters the decaying tags from the connection manager.
+func (t *protocolTagger) closeTags() {
+	for _, tag := range t.active {
+		tag.Close()
+	}
+}
+
+func (t *protocolTagger) Close() error {
+	err := t.sub.Close()
+	t.closeTags()
+	return err
+}
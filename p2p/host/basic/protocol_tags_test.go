@@ -0,0 +1,102 @@
+package basichost
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/AstaFrode/go-libp2p/core/network"
+	"github.com/AstaFrode/go-libp2p/core/peer"
+	"github.com/AstaFrode/go-libp2p/core/protocol"
+	"github.com/AstaFrode/go-libp2p/p2p/net/connmgr"
+	swarmt "github.com/AstaFrode/go-libp2p/p2p/net/swarm/testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProtocolTags(t *testing.T) {
+	const (
+		supportedProto = protocol.ID("/supported")
+		otherProto     = protocol.ID("/other")
+	)
+	cm, err := connmgr.NewConnManager(10, 20, connmgr.DecayerConfig(&connmgr.DecayerCfg{Resolution: time.Second}))
+	require.NoError(t, err)
+	h1, err := NewHost(swarmt.GenSwarm(t), &HostOpts{
+		ConnManager: cm,
+		ProtocolTags: map[protocol.ID]ProtocolTagWeights{
+			supportedProto: {Supported: 10, Active: 5},
+			otherProto:     {Supported: 20},
+		},
+	})
+	require.NoError(t, err)
+	defer h1.Close()
+	h1.Start()
+	h2, err := NewHost(swarmt.GenSwarm(t), nil)
+	require.NoError(t, err)
+	defer h2.Close()
+	h2.Start()
+	h2.SetStreamHandler(supportedProto, func(s network.Stream) { s.Close() })
+
+	require.NoError(t, h1.Connect(context.Background(), peer.AddrInfo{ID: h2.ID(), Addrs: h2.Addrs()}))
+	require.Eventually(t, func() bool {
+		info := cm.GetTagInfo(h2.ID())
+		return info != nil && info.Tags["protocol:/supported"] == 10
+	}, 5*time.Second, 10*time.Millisecond)
+	require.NotContains(t, cm.GetTagInfo(h2.ID()).Tags, "protocol:/other")
+	require.NotContains(t, cm.GetTagInfo(h2.ID()).Tags, "protocol-active:/supported")
+
+	s, err := h1.NewStream(context.Background(), h2.ID(), supportedProto)
+	require.NoError(t, err)
+	// the tag is only applied once the peer confirmed the protocol
+	_, err = s.Read(make([]byte, 1))
+	require.ErrorIs(t, err, io.EOF)
+	s.Close()
+	require.Eventually(t, func() bool {
+		return cm.GetTagInfo(h2.ID()).Tags["protocol-active:/supported"] == 5
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestProtocolTagsRejectedProtocol(t *testing.T) {
+	const rejectedProto = protocol.ID("/rejected")
+	cm, err := connmgr.NewConnManager(10, 20, connmgr.DecayerConfig(&connmgr.DecayerCfg{Resolution: time.Second}))
+	require.NoError(t, err)
+	h1, err := NewHost(swarmt.GenSwarm(t), &HostOpts{
+		ConnManager:  cm,
+		ProtocolTags: map[protocol.ID]ProtocolTagWeights{rejectedProto: {Active: 5}},
+	})
+	require.NoError(t, err)
+	defer h1.Close()
+	h1.Start()
+	h2, err := NewHost(swarmt.GenSwarm(t), nil)
+	require.NoError(t, err)
+	defer h2.Close()
+	h2.Start()
+
+	require.NoError(t, h1.Connect(context.Background(), peer.AddrInfo{ID: h2.ID(), Addrs: h2.Addrs()}))
+	// make h1 select the protocol optimistically
+	require.NoError(t, h1.Peerstore().AddProtocols(h2.ID(), rejectedProto))
+	s, err := h1.NewStream(context.Background(), h2.ID(), rejectedProto)
+	require.NoError(t, err)
+	_, err = s.Write([]byte("foobar"))
+	require.NoError(t, err)
+	_, err = s.Read(make([]byte, 1))
+	require.Error(t, err)
+	s.Reset()
+	require.NotContains(t, cm.GetTagInfo(h2.ID()).Tags, "protocol-active:/rejected")
+}
+
+func TestProtocolTaggerClosesTags(t *testing.T) {
+	cm, err := connmgr.NewConnManager(10, 20, connmgr.DecayerConfig(&connmgr.DecayerCfg{Resolution: time.Second}))
+	require.NoError(t, err)
+	h, err := NewHost(swarmt.GenSwarm(t), &HostOpts{
+		ConnManager:  cm,
+		ProtocolTags: map[protocol.ID]ProtocolTagWeights{"/proto": {Active: 1}},
+	})
+	require.NoError(t, err)
+
+	tag := h.protocolTagger.active["/proto"]
+	require.NoError(t, tag.Bump("peer", 1))
+	require.NoError(t, h.Close())
+	require.ErrorContains(t, tag.Bump("peer", 1), "had been closed")
+}
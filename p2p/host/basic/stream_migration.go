@@ -0,0 +1,184 @@
+package basichost
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/AstaFrode/go-libp2p/core/event"
+	"github.com/AstaFrode/go-libp2p/core/network"
+	"github.com/AstaFrode/go-libp2p/core/peer"
+	"github.com/AstaFrode/go-libp2p/core/protocol"
+
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// streamMigrationTimeout is the timeout for opening the replacement stream
+// when streams are migrated automatically.
+const streamMigrationTimeout = 30 * time.Second
+
+// ErrNotTransient is returned by MigrateStream if the stream is not on a transient connection.
+var ErrNotTransient = errors.New("stream is not on a transient connection")
+
+// ErrNoDirectConnection is returned by MigrateStream if there's no direct connection to the peer.
+var ErrNoDirectConnection = errors.New("no direct connection to peer")
+
+// StreamMigrationHandler is called when a stream was migrated from a transient
+// connection to a direct connection.
+//
+// oldStream is the stream on the transient connection. Its ID is the ID of the
+// stream returned by NewStream. newStream is a stream to the same peer, on the
+// direct connection, using the same protocol. The remote peer receives newStream
+// as a new incoming stream.
+// The handler takes ownership of both streams, and is responsible for closing oldStream
+// once the application state has been moved to newStream.
+type StreamMigrationHandler func(oldStream, newStream network.Stream)
+
+type streamMigrator struct {
+	mx       sync.Mutex
+	handlers map[protocol.ID]StreamMigrationHandler
+}
+
+// SetStreamMigrationHandler sets the handler that is called when an outbound stream
+// using protocol pid is migrated from a transient connection to a direct connection.
+//
+// Streams are only migrated automatically if stream migration was enabled using
+// HostOpts.EnableStreamMigration. Streams using protocols without a migration handler
+// are never migrated automatically: they stay on the transient connection.
+func (h *BasicHost) SetStreamMigrationHandler(pid protocol.ID, handler StreamMigrationHandler) {
+	h.migrator.mx.Lock()
+	defer h.migrator.mx.Unlock()
+	if h.migrator.handlers == nil {
+		h.migrator.handlers = make(map[protocol.ID]StreamMigrationHandler)
+	}
+	h.migrator.handlers[pid] = handler
+}
+
+// RemoveStreamMigrationHandler removes the stream migration handler for protocol pid.
+func (h *BasicHost) RemoveStreamMigrationHandler(pid protocol.ID) {
+	h.migrator.mx.Lock()
+	defer h.migrator.mx.Unlock()
+	delete(h.migrator.handlers, pid)
+}
+
+func (h *BasicHost) streamMigrationHandler(pid protocol.ID) StreamMigrationHandler {
+	h.migrator.mx.Lock()
+	defer h.migrator.mx.Unlock()
+	return h.migrator.handlers[pid]
+}
+
+// MigrateStream opens a stream replacing s on a direct connection to the same peer,
+// using the same protocol. It doesn't dial: if there's no direct connection to the
+// peer, it returns ErrNoDirectConnection.
+//
+// Streams can't be moved between connections, so s is not modified. It's up to the
+// application to move its state to the new stream, and to close s.
+func (h *BasicHost) MigrateStream(ctx context.Context, s network.Stream) (network.Stream, error) {
+	if !s.Conn().Stat().Transient {
+		return nil, ErrNotTransient
+	}
+	p := s.Conn().RemotePeer()
+	if !h.hasDirectConn(p) {
+		return nil, ErrNoDirectConnection
+	}
+	ns, err := h.NewStream(network.WithNoDial(ctx, "stream migration"), p, s.Protocol())
+	if err != nil {
+		return nil, err
+	}
+	if ns.Conn().Stat().Transient {
+		// the direct connection was closed in the meantime
+		ns.Reset()
+		return nil, ErrNoDirectConnection
+	}
+	return ns, nil
+}
+
+func (h *BasicHost) hasDirectConn(p peer.ID) bool {
+	for _, c := range h.Network().ConnsToPeer(p) {
+		if !c.Stat().Transient {
+			return true
+		}
+	}
+	return false
+}
+
+// migrateStreams migrates the outbound streams on transient connections to p
+// that have a migration handler.
+// Only outbound streams are migrated, so that the two peers don't both open
+// a replacement stream.
+func (h *BasicHost) migrateStreams(p peer.ID) {
+	for _, c := range h.Network().ConnsToPeer(p) {
+		if !c.Stat().Transient {
+			continue
+		}
+		for _, s := range c.GetStreams() {
+			if s.Stat().Direction != network.DirOutbound {
+				continue
+			}
+			handler := h.streamMigrationHandler(s.Protocol())
+			if handler == nil {
+				continue
+			}
+			ctx, cancel := context.WithTimeout(h.ctx, streamMigrationTimeout)
+			ns, err := h.MigrateStream(ctx, s)
+			cancel()
+			if err != nil {
+				h.logger.Debugw("failed to migrate stream", streamFields(s, "protocol", s.Protocol(), "error", err)...)
+				continue
+			}
+			handler(s, ns)
+		}
+	}
+}
+
+// connUpgradeWatcher emits an EvtPeerConnectionUpgraded when a direct connection
+// is established to a peer we're connected to via transient connections only,
+// and migrates streams if enabled.
+type connUpgradeWatcher struct {
+	h       *BasicHost
+	emitter event.Emitter
+	migrate bool
+}
+
+var _ network.Notifiee = &connUpgradeWatcher{}
+
+func (w *connUpgradeWatcher) Listen(network.Network, ma.Multiaddr)       {}
+func (w *connUpgradeWatcher) ListenClose(network.Network, ma.Multiaddr)  {}
+func (w *connUpgradeWatcher) Disconnected(network.Network, network.Conn) {}
+
+func (w *connUpgradeWatcher) Connected(n network.Network, c network.Conn) {
+	if c.Stat().Transient {
+		return
+	}
+	p := c.RemotePeer()
+	var hasTransient bool
+	for _, other := range n.ConnsToPeer(p) {
+		if other == c {
+			continue
+		}
+		if !other.Stat().Transient {
+			// we already had a direct connection
+			return
+		}
+		hasTransient = true
+	}
+	if !hasTransient {
+		return
+	}
+	w.emitter.Emit(event.EvtPeerConnectionUpgraded{Peer: p, Direct: c})
+	if !w.migrate {
+		return
+	}
+
+	w.h.closeMx.RLock()
+	defer w.h.closeMx.RUnlock()
+	if w.h.closed {
+		return
+	}
+	w.h.refCount.Add(1)
+	go func() {
+		defer w.h.refCount.Done()
+		w.h.migrateStreams(p)
+	}()
+}
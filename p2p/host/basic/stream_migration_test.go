@@ -0,0 +1,117 @@
+package basichost
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/AstaFrode/go-libp2p/core/event"
+	"github.com/AstaFrode/go-libp2p/core/network"
+	"github.com/AstaFrode/go-libp2p/core/peer"
+	"github.com/AstaFrode/go-libp2p/core/peerstore"
+	"github.com/AstaFrode/go-libp2p/core/protocol"
+	swarmt "github.com/AstaFrode/go-libp2p/p2p/net/swarm/testing"
+	"github.com/AstaFrode/go-libp2p/p2p/protocol/circuitv2/client"
+	"github.com/AstaFrode/go-libp2p/p2p/protocol/circuitv2/relay"
+
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/require"
+)
+
+func newRelayedHost(t *testing.T, opts *HostOpts) *BasicHost {
+	t.Helper()
+	sw := swarmt.GenSwarm(t, swarmt.OptDisableQUIC)
+	h, err := NewHost(sw, opts)
+	require.NoError(t, err)
+	h.Start()
+	t.Cleanup(func() { h.Close() })
+	require.NoError(t, client.AddTransport(h, swarmt.GenUpgrader(t, sw, nil)))
+	return h
+}
+
+func TestStreamMigration(t *testing.T) {
+	const proto = "/test/migration"
+
+	relayHost, err := NewHost(swarmt.GenSwarm(t, swarmt.OptDisableQUIC), nil)
+	require.NoError(t, err)
+	relayHost.Start()
+	defer relayHost.Close()
+	r, err := relay.New(relayHost)
+	require.NoError(t, err)
+	defer r.Close()
+	relayInfo := peer.AddrInfo{ID: relayHost.ID(), Addrs: relayHost.Addrs()}
+
+	h1 := newRelayedHost(t, &HostOpts{EnableStreamMigration: true})
+	h2 := newRelayedHost(t, nil)
+
+	inbound := make(chan network.Stream, 2)
+	h2.SetStreamHandler(proto, func(s network.Stream) { inbound <- s })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	require.NoError(t, h1.Connect(ctx, relayInfo))
+	require.NoError(t, h2.Connect(ctx, relayInfo))
+	_, err = client.Reserve(ctx, h2, relayInfo)
+	require.NoError(t, err)
+
+	relayAddr := ma.StringCast("/p2p/" + relayHost.ID().String() + "/p2p-circuit")
+	require.NoError(t, h1.Connect(ctx, peer.AddrInfo{
+		ID:    h2.ID(),
+		Addrs: []ma.Multiaddr{relayInfo.Addrs[0].Encapsulate(relayAddr)},
+	}))
+	s, err := h1.NewStream(network.WithUseTransient(ctx, "test"), h2.ID(), proto)
+	require.NoError(t, err)
+	_, err = s.Write([]byte("foo"))
+	require.NoError(t, err)
+	require.True(t, s.Conn().Stat().Transient)
+	<-inbound
+
+	_, err = h1.MigrateStream(ctx, s)
+	require.ErrorIs(t, err, ErrNoDirectConnection)
+
+	type migration struct{ old, new network.Stream }
+	migrated := make(chan migration, 1)
+	h1.SetStreamMigrationHandler(proto, func(oldStream, newStream network.Stream) { migrated <- migration{oldStream, newStream} })
+
+	sub, err := h1.EventBus().Subscribe(new(event.EvtPeerConnectionUpgraded))
+	require.NoError(t, err)
+	defer sub.Close()
+
+	// establish a direct connection
+	h1.Peerstore().AddAddrs(h2.ID(), h2.Network().ListenAddresses(), peerstore.TempAddrTTL)
+	_, err = h1.Network().DialPeer(network.WithForceDirectDial(ctx, "test"), h2.ID())
+	require.NoError(t, err)
+
+	select {
+	case e := <-sub.Out():
+		evt := e.(event.EvtPeerConnectionUpgraded)
+		require.Equal(t, h2.ID(), evt.Peer)
+		require.False(t, evt.Direct.Stat().Transient)
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected an EvtPeerConnectionUpgraded")
+	}
+
+	var ns network.Stream
+	select {
+	case m := <-migrated:
+		require.Equal(t, s.ID(), m.old.ID())
+		require.False(t, m.new.Conn().Stat().Transient)
+		require.Equal(t, protocol.ID(proto), m.new.Protocol())
+		m.old.Close()
+		ns = m.new
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream wasn't migrated")
+	}
+	_, err = ns.Write([]byte("bar"))
+	require.NoError(t, err)
+
+	select {
+	case s := <-inbound:
+		require.False(t, s.Conn().Stat().Transient)
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the migrated stream")
+	}
+
+	_, err = h1.MigrateStream(ctx, ns)
+	require.ErrorIs(t, err, ErrNotTransient)
+}
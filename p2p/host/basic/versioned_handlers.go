@@ -0,0 +1,133 @@
+package basichost
+
+import (
+	"context"
+	"sort"
+
+	"github.com/AstaFrode/go-libp2p/core/network"
+	"github.com/AstaFrode/go-libp2p/core/peer"
+	"github.com/AstaFrode/go-libp2p/core/protocol"
+)
+
+// VersionedStreamHandler handles the streams of a versioned protocol.
+// v is the version negotiated with the peer, which may be lower than the version
+// the handler was registered for.
+type VersionedStreamHandler func(s network.Stream, v protocol.Version)
+
+// SetVersionedStreamHandler sets the handler for the versioned protocol pid, e.g. /myapp/req/1.4.0.
+// Only pid is advertised to other peers, but the handler also handles the streams of
+// all lower versions with the same major version, i.e. /myapp/req/1.x up to 1.4.0.
+//
+// To support multiple major versions of a protocol, register a handler for each of them.
+// The handler is removed using RemoveStreamHandler(pid).
+func (h *BasicHost) SetVersionedStreamHandler(pid protocol.ID, handler VersionedStreamHandler) error {
+	base, v, err := protocol.SplitVersion(pid)
+	if err != nil {
+		return err
+	}
+	h.SetStreamHandlerMatch(pid, func(proposed protocol.ID) bool {
+		b, pv, err := protocol.SplitVersion(proposed)
+		return err == nil && b == base && v.Serves(pv)
+	}, func(s network.Stream) {
+		_, pv, _ := protocol.SplitVersion(s.Protocol())
+		handler(s, pv)
+	})
+	return nil
+}
+
+// NewVersionedStream opens a new stream to peer p, negotiating the highest version of a
+// versioned protocol supported by both peers. pids are the versions implemented locally,
+// e.g. /myapp/req/2.1.0 and /myapp/req/1.4.0.
+//
+// The versions are chosen using the protocols the peer advertised via identify: for each
+// local version, the highest version advertised by the peer that it serves is proposed,
+// or the local version if the peer advertised a higher one with the same major version.
+// Local versions the peer didn't advertise anything for are proposed last, from the
+// highest to the lowest one.
+// It returns the stream and the negotiated version.
+func (h *BasicHost) NewVersionedStream(ctx context.Context, p peer.ID, pids ...protocol.ID) (network.Stream, protocol.Version, error) {
+	// make sure we know the peer's protocols before choosing the versions to propose
+	if nodial, _ := network.GetNoDial(ctx); !nodial {
+		if err := h.Connect(ctx, peer.AddrInfo{ID: p}); err != nil {
+			return nil, protocol.Version{}, err
+		}
+	}
+	if conns := h.Network().ConnsToPeer(p); len(conns) > 0 {
+		select {
+		case <-h.ids.IdentifyWait(conns[0]):
+		case <-ctx.Done():
+			return nil, protocol.Version{}, ctx.Err()
+		}
+	}
+
+	proposals, err := h.versionProposals(p, pids)
+	if err != nil {
+		return nil, protocol.Version{}, err
+	}
+	s, err := h.NewStream(ctx, p, proposals...)
+	if err != nil {
+		return nil, protocol.Version{}, err
+	}
+	_, v, _ := protocol.SplitVersion(s.Protocol())
+	return s, v, nil
+}
+
+type versionedProtocol struct {
+	id      protocol.ID
+	base    protocol.ID
+	version protocol.Version
+}
+
+// versionProposals returns the protocols to propose to p, in order of preference.
+// Protocols advertised by the peer are proposed verbatim, since the peer might only
+// accept the exact protocol ID.
+func (h *BasicHost) versionProposals(p peer.ID, pids []protocol.ID) ([]protocol.ID, error) {
+	local := make([]versionedProtocol, 0, len(pids))
+	for _, pid := range pids {
+		base, v, err := protocol.SplitVersion(pid)
+		if err != nil {
+			return nil, err
+		}
+		local = append(local, versionedProtocol{id: pid, base: base, version: v})
+	}
+	// the peerstore is only an optimization, ignore errors
+	supported, _ := h.Peerstore().GetProtocols(p)
+
+	var known, others []versionedProtocol
+	seen := make(map[protocol.ID]struct{})
+	add := func(list *[]versionedProtocol, vp versionedProtocol) {
+		if _, ok := seen[vp.id]; ok {
+			return
+		}
+		seen[vp.id] = struct{}{}
+		*list = append(*list, vp)
+	}
+	for _, l := range local {
+		var matched bool
+		for _, pid := range supported {
+			base, v, err := protocol.SplitVersion(pid)
+			if err != nil || base != l.base || v.Major != l.version.Major {
+				continue
+			}
+			matched = true
+			// the best mutual version is the lower one of the two
+			if l.version.Serves(v) {
+				add(&known, versionedProtocol{id: pid, base: base, version: v})
+			} else {
+				add(&known, l)
+			}
+		}
+		if !matched {
+			add(&others, l)
+		}
+	}
+
+	proposals := make([]protocol.ID, 0, len(known)+len(others))
+	for _, list := range [][]versionedProtocol{known, others} {
+		sort.SliceStable(list, func(i, j int) bool { return list[i].version.Compare(list[j].version) > 0 })
+		for _, vp := range list {
+			proposals = append(proposals, vp.id)
+		}
+	}
+	return proposals, nil
+}
@@ -0,0 +1,90 @@
+package basichost
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/AstaFrode/go-libp2p/core/network"
+	"github.com/AstaFrode/go-libp2p/core/protocol"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVersionedStreamHandler(t *testing.T) {
+	h1, h2 := getHostPair(t)
+	defer h1.Close()
+	defer h2.Close()
+
+	versions := make(chan protocol.Version, 1)
+	handler := func(s network.Stream, v protocol.Version) {
+		defer s.Close()
+		_, err := io.ReadFull(s, make([]byte, 3))
+		assert.NoError(t, err)
+		versions <- v
+	}
+	require.NoError(t, h2.(*BasicHost).SetVersionedStreamHandler("/test/req/1.2.0", handler))
+	require.NoError(t, h2.(*BasicHost).SetVersionedStreamHandler("/test/req/2.0.1", handler))
+	require.Error(t, h2.(*BasicHost).SetVersionedStreamHandler("/test/req", handler))
+	// wait for h1 to learn the protocols via identify push
+	require.Eventually(t, func() bool {
+		supported, err := h1.Peerstore().SupportsProtocols(h2.ID(), "/test/req/1.2.0", "/test/req/2.0.1")
+		return err == nil && len(supported) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for _, tc := range []struct {
+		local    []protocol.ID
+		expected protocol.Version
+	}{
+		{local: []protocol.ID{"/test/req/1.4.0"}, expected: protocol.Version{Major: 1, Minor: 2}},
+		{local: []protocol.ID{"/test/req/1.1.3"}, expected: protocol.Version{Major: 1, Minor: 1, Patch: 3}},
+		{local: []protocol.ID{"/test/req/1.4.0", "/test/req/2.1.0"}, expected: protocol.Version{Major: 2, Minor: 0, Patch: 1}},
+	} {
+		s, v, err := h1.(*BasicHost).NewVersionedStream(ctx, h2.ID(), tc.local...)
+		require.NoError(t, err)
+		require.Equal(t, tc.expected, v)
+		_, err = s.Write([]byte("foo"))
+		require.NoError(t, err)
+		select {
+		case v := <-versions:
+			require.Equal(t, tc.expected, v)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for stream")
+		}
+		s.Close()
+	}
+
+	// the peer doesn't support this major version
+	s, _, err := h1.(*BasicHost).NewVersionedStream(ctx, h2.ID(), "/test/req/3.0.0")
+	if err == nil {
+		// the stream was opened optimistically
+		_, err = s.Read(make([]byte, 1))
+	}
+	require.Error(t, err)
+}
+
+func TestVersionProposals(t *testing.T) {
+	h1, h2 := getHostPair(t)
+	defer h1.Close()
+	defer h2.Close()
+
+	proposals, err := h1.(*BasicHost).versionProposals(h2.ID(), []protocol.ID{"/test/req/1.2.1", "/test/req/2.0.0"})
+	require.NoError(t, err)
+	require.Equal(t, []protocol.ID{"/test/req/2.0.0", "/test/req/1.2.1"}, proposals)
+
+	require.NoError(t, h1.Peerstore().AddProtocols(h2.ID(), "/test/req/1.1.5", "/test/req/1.3.0", "/other/1.0.0"))
+	proposals, err = h1.(*BasicHost).versionProposals(h2.ID(), []protocol.ID{"/test/req/1.2.1", "/test/req/2.0.0"})
+	require.NoError(t, err)
+	require.Equal(t, []protocol.ID{"/test/req/1.2.1", "/test/req/1.1.5", "/test/req/2.0.0"}, proposals)
+
+	// advertised protocols are proposed verbatim
+	require.NoError(t, h1.Peerstore().SetProtocols(h2.ID(), "/app/1.2"))
+	proposals, err = h1.(*BasicHost).versionProposals(h2.ID(), []protocol.ID{"/app/1.4.0"})
+	require.NoError(t, err)
+	require.Equal(t, []protocol.ID{"/app/1.2"}, proposals)
+}
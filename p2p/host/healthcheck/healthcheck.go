@@ -0,0 +1,286 @@
+// Package healthcheck implements a service verifying the liveness of idle connections.
+//
+// Connections can die without being closed, e.g. when a NAT drops its mapping. Such a
+// connection lingers until a write fails, which can take several minutes. The health
+// checker periodically sends a ping on every idle connection, and closes the connections
+// on which the remote peer stopped responding.
+package healthcheck
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/AstaFrode/go-libp2p/core/event"
+	"github.com/AstaFrode/go-libp2p/core/host"
+	"github.com/AstaFrode/go-libp2p/core/network"
+	"github.com/AstaFrode/go-libp2p/core/protocol"
+	"github.com/AstaFrode/go-libp2p/p2p/protocol/ping"
+
+	logging "github.com/ipfs/go-log/v2"
+	msmux "github.com/multiformats/go-multistream"
+)
+
+var log = logging.Logger("healthcheck")
+
+// ServiceName is the name of the health checker in the resource manager.
+const ServiceName = "libp2p.healthcheck"
+
+// maxConcurrentChecks is the maximum number of connections checked concurrently
+const maxConcurrentChecks = 16
+
+type Option func(*HealthChecker) error
+
+// WithInterval sets the interval between two checks of an idle connection.
+// Default: 30s.
+func WithInterval(d time.Duration) Option {
+	return func(hc *HealthChecker) error {
+		if d <= 0 {
+			return errors.New("health check interval must be positive")
+		}
+		hc.interval = d
+		return nil
+	}
+}
+
+// WithTimeout sets the time the remote peer has to respond to a check.
+// Default: 10s.
+func WithTimeout(d time.Duration) Option {
+	return func(hc *HealthChecker) error {
+		if d <= 0 {
+			return errors.New("health check timeout must be positive")
+		}
+		hc.timeout = d
+		return nil
+	}
+}
+
+// WithMaxFailures sets the number of consecutive failed checks after which a connection
+// is closed. Default: 2.
+func WithMaxFailures(n int) Option {
+	return func(hc *HealthChecker) error {
+		if n <= 0 {
+			return errors.New("max failures must be positive")
+		}
+		hc.maxFailures = n
+		return nil
+	}
+}
+
+// HealthChecker verifies the liveness of idle connections, i.e. connections without
+// any open streams, by sending a ping on a new stream on the connection.
+//
+// A remote peer that doesn't support the ping protocol still responds to the protocol
+// negotiation, which is enough to prove that the connection is alive.
+// Transient (relayed) connections are not checked, to save their limited resources.
+//
+// When a connection is closed because of failed checks, an event.EvtConnectionDead
+// is emitted.
+type HealthChecker struct {
+	host host.Host
+
+	interval    time.Duration
+	timeout     time.Duration
+	maxFailures int
+
+	ctx       context.Context
+	ctxCancel context.CancelFunc
+	refCount  sync.WaitGroup
+	emitter   event.Emitter
+
+	mx       sync.Mutex
+	checking map[network.Conn]struct{}
+	failures map[network.Conn]int
+}
+
+// NewHealthChecker creates a new health checker. Call Start to start checking connections.
+func NewHealthChecker(h host.Host, opts ...Option) (*HealthChecker, error) {
+	hc := &HealthChecker{
+		host:        h,
+		interval:    30 * time.Second,
+		timeout:     10 * time.Second,
+		maxFailures: 2,
+		checking:    make(map[network.Conn]struct{}),
+		failures:    make(map[network.Conn]int),
+	}
+	for _, opt := range opts {
+		if err := opt(hc); err != nil {
+			return nil, err
+		}
+	}
+	emitter, err := h.EventBus().Emitter(new(event.EvtConnectionDead))
+	if err != nil {
+		return nil, err
+	}
+	hc.emitter = emitter
+	hc.ctx, hc.ctxCancel = context.WithCancel(context.Background())
+	return hc, nil
+}
+
+// Start starts checking connections in the background.
+func (hc *HealthChecker) Start() {
+	hc.refCount.Add(1)
+	go hc.background()
+}
+
+// Close stops the health checker.
+func (hc *HealthChecker) Close() error {
+	hc.ctxCancel()
+	hc.refCount.Wait()
+	return hc.emitter.Close()
+}
+
+func (hc *HealthChecker) background() {
+	defer hc.refCount.Done()
+
+	ticker := time.NewTicker(hc.interval)
+	defer ticker.Stop()
+	sem := make(chan struct{}, maxConcurrentChecks)
+	for {
+		select {
+		case <-ticker.C:
+		case <-hc.ctx.Done():
+			return
+		}
+
+		conns := hc.host.Network().Conns()
+		hc.gc(conns)
+		for _, c := range conns {
+			if c.Stat().Transient || len(c.GetStreams()) > 0 {
+				continue
+			}
+			hc.mx.Lock()
+			_, ok := hc.checking[c]
+			if !ok {
+				hc.checking[c] = struct{}{}
+			}
+			hc.mx.Unlock()
+			if ok {
+				continue
+			}
+
+			select {
+			case sem <- struct{}{}:
+			case <-hc.ctx.Done():
+				return
+			}
+			hc.refCount.Add(1)
+			go func(c network.Conn) {
+				defer hc.refCount.Done()
+				defer func() { <-sem }()
+				hc.check(c)
+			}(c)
+		}
+	}
+}
+
+// gc removes the state of connections that were closed.
+func (hc *HealthChecker) gc(conns []network.Conn) {
+	open := make(map[network.Conn]struct{}, len(conns))
+	for _, c := range conns {
+		open[c] = struct{}{}
+	}
+	hc.mx.Lock()
+	defer hc.mx.Unlock()
+	for c := range hc.failures {
+		if _, ok := open[c]; !ok {
+			delete(hc.failures, c)
+		}
+	}
+}
+
+func (hc *HealthChecker) check(c network.Conn) {
+	defer func() {
+		hc.mx.Lock()
+		delete(hc.checking, c)
+		hc.mx.Unlock()
+	}()
+
+	ctx, cancel := context.WithTimeout(hc.ctx, hc.timeout)
+	defer cancel()
+	err := probe(ctx, c)
+	if hc.ctx.Err() != nil {
+		return
+	}
+
+	hc.mx.Lock()
+	if err == nil {
+		delete(hc.failures, c)
+		hc.mx.Unlock()
+		return
+	}
+	hc.failures[c]++
+	failures := hc.failures[c]
+	if failures >= hc.maxFailures {
+		delete(hc.failures, c)
+	}
+	hc.mx.Unlock()
+
+	log.Debugw("health check failed", "peer", c.RemotePeer(), "addr", c.RemoteMultiaddr(), "failures", failures, "error", err)
+	if failures < hc.maxFailures || !hc.isOpen(c) {
+		return
+	}
+	log.Debugw("closing dead connection", "peer", c.RemotePeer(), "addr", c.RemoteMultiaddr())
+	c.Close()
+	hc.emitter.Emit(event.EvtConnectionDead{Peer: c.RemotePeer(), Conn: c, Error: err})
+}
+
+// isOpen returns true if the connection wasn't closed in the meantime.
+func (hc *HealthChecker) isOpen(c network.Conn) bool {
+	for _, conn := range hc.host.Network().ConnsToPeer(c.RemotePeer()) {
+		if conn == c {
+			return true
+		}
+	}
+	return false
+}
+
+// probe sends a ping on a new stream on the connection.
+func probe(ctx context.Context, c network.Conn) error {
+	s, err := c.NewStream(ctx)
+	if err != nil {
+		return err
+	}
+	if err := s.Scope().SetService(ServiceName); err != nil {
+		s.Reset()
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		s.SetDeadline(deadline)
+	}
+
+	if err := msmux.SelectProtoOrFail[protocol.ID](ping.ID, s); err != nil {
+		s.Reset()
+		var errNotSupported msmux.ErrNotSupported[protocol.ID]
+		if errors.As(err, &errNotSupported) {
+			// The peer responded to the negotiation, so the connection is alive.
+			return nil
+		}
+		return err
+	}
+	s.SetProtocol(ping.ID)
+
+	buf := make([]byte, ping.PingSize)
+	if _, err := rand.Read(buf); err != nil {
+		s.Reset()
+		return err
+	}
+	if _, err := s.Write(buf); err != nil {
+		s.Reset()
+		return err
+	}
+	rbuf := make([]byte, ping.PingSize)
+	if _, err := io.ReadFull(s, rbuf); err != nil {
+		s.Reset()
+		return err
+	}
+	s.Close()
+	if !bytes.Equal(buf, rbuf) {
+		return errors.New("ping packet was incorrect")
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package healthcheck_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/AstaFrode/go-libp2p/core/event"
+	"github.com/AstaFrode/go-libp2p/core/network"
+	"github.com/AstaFrode/go-libp2p/core/peer"
+	bhost "github.com/AstaFrode/go-libp2p/p2p/host/basic"
+	"github.com/AstaFrode/go-libp2p/p2p/host/healthcheck"
+	swarmt "github.com/AstaFrode/go-libp2p/p2p/net/swarm/testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newHost(t *testing.T, n *swarmt.SimNet, opts *bhost.HostOpts) *bhost.BasicHost {
+	t.Helper()
+	h, err := bhost.NewHost(swarmt.GenSwarm(t, swarmt.OptSimNet(n)), opts)
+	require.NoError(t, err)
+	h.Start()
+	t.Cleanup(func() { h.Close() })
+	return h
+}
+
+func newHostPair(t *testing.T, n *swarmt.SimNet) (*bhost.BasicHost, *bhost.BasicHost) {
+	a := newHost(t, n, &bhost.HostOpts{
+		EnableHealthCheck: true,
+		HealthCheckOptions: []healthcheck.Option{
+			healthcheck.WithInterval(50 * time.Millisecond),
+			healthcheck.WithTimeout(100 * time.Millisecond),
+			healthcheck.WithMaxFailures(2),
+		},
+	})
+	b := newHost(t, n, &bhost.HostOpts{EnablePing: true})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, a.Connect(ctx, peer.AddrInfo{ID: b.ID(), Addrs: b.Addrs()}))
+	return a, b
+}
+
+func TestHealthyConnection(t *testing.T) {
+	n := swarmt.NewSimNet(1)
+	a, b := newHostPair(t, n)
+
+	sub, err := a.EventBus().Subscribe(new(event.EvtConnectionDead))
+	require.NoError(t, err)
+	defer sub.Close()
+
+	time.Sleep(500 * time.Millisecond)
+	require.Equal(t, network.Connected, a.Network().Connectedness(b.ID()))
+	select {
+	case e := <-sub.Out():
+		t.Fatalf("unexpected event: %v", e)
+	default:
+	}
+}
+
+func TestDeadConnection(t *testing.T) {
+	n := swarmt.NewSimNet(1)
+	a, b := newHostPair(t, n)
+
+	sub, err := a.EventBus().Subscribe(new(event.EvtConnectionDead))
+	require.NoError(t, err)
+	defer sub.Close()
+
+	// wait for identify to finish, so that the connection is idle
+	require.Eventually(t, func() bool {
+		for _, c := range a.Network().ConnsToPeer(b.ID()) {
+			if len(c.GetStreams()) == 0 {
+				return true
+			}
+		}
+		return false
+	}, 5*time.Second, 10*time.Millisecond)
+	conn := a.Network().ConnsToPeer(b.ID())[0]
+
+	// the link stops delivering data
+	n.SetLink(a.ID(), b.ID(), swarmt.LinkSettings{Latency: time.Hour})
+
+	select {
+	case e := <-sub.Out():
+		evt := e.(event.EvtConnectionDead)
+		require.Equal(t, b.ID(), evt.Peer)
+		require.Equal(t, conn, evt.Conn)
+		require.Error(t, evt.Error)
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the connection to be declared dead")
+	}
+	require.Eventually(t, func() bool {
+		return a.Network().Connectedness(b.ID()) != network.Connected
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestInvalidOptions(t *testing.T) {
+	h := newHost(t, swarmt.NewSimNet(1), &bhost.HostOpts{})
+	_, err := healthcheck.NewHealthChecker(h, healthcheck.WithInterval(0))
+	require.Error(t, err)
+	_, err = healthcheck.NewHealthChecker(h, healthcheck.WithTimeout(-time.Second))
+	require.Error(t, err)
+	_, err = healthcheck.NewHealthChecker(h, healthcheck.WithMaxFailures(0))
+	require.Error(t, err)
+}
@@ -0,0 +1,382 @@
+// Package introspect implements a local server that exposes the runtime state
+// of a libp2p host (connections, streams, traffic and events emitted on the
+// event bus), so that a node can be inspected without instrumenting its code.
+//
+// The server exposes two endpoints:
+//   - /state returns a JSON snapshot of the current state.
+//   - /ws is a WebSocket endpoint that periodically pushes the state,
+//     and forwards all events emitted on the host's event bus as they happen.
+//
+// The server is meant to be bound to a local address. It doesn't implement
+// any kind of authentication.
+package introspect
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/AstaFrode/go-libp2p/core/event"
+	"github.com/AstaFrode/go-libp2p/core/host"
+	"github.com/AstaFrode/go-libp2p/core/metrics"
+	"github.com/AstaFrode/go-libp2p/core/network"
+	"github.com/AstaFrode/go-libp2p/core/peer"
+	"github.com/AstaFrode/go-libp2p/p2p/host/eventbus"
+
+	logging "github.com/ipfs/go-log/v2"
+	ws "nhooyr.io/websocket"
+	"nhooyr.io/websocket/wsjson"
+)
+
+var log = logging.Logger("introspect")
+
+const (
+	defaultInterval = time.Second
+	// clientEventQueueLen is the number of events buffered for each client.
+	// Events are dropped for clients that don't keep up.
+	clientEventQueueLen = 64
+	writeTimeout        = 10 * time.Second
+)
+
+// Message types sent on the WebSocket.
+const (
+	MessageTypeState = "state"
+	MessageTypeEvent = "event"
+)
+
+// Message is a message sent to WebSocket clients.
+type Message struct {
+	Type  string    `json:"type"`
+	Time  time.Time `json:"time"`
+	State *State    `json:"state,omitempty"`
+	Event *Event    `json:"event,omitempty"`
+}
+
+// State is a snapshot of the runtime state of the host.
+type State struct {
+	PeerID      peer.ID      `json:"peer_id"`
+	Addrs       []string     `json:"addrs"`
+	Connections []Connection `json:"connections"`
+	// Traffic is only set if a bandwidth reporter was configured.
+	Traffic *Traffic `json:"traffic,omitempty"`
+}
+
+// Connection describes a single connection.
+type Connection struct {
+	ID         string    `json:"id"`
+	Peer       peer.ID   `json:"peer"`
+	Direction  string    `json:"direction"`
+	Opened     time.Time `json:"opened"`
+	Transient  bool      `json:"transient,omitempty"`
+	LocalAddr  string    `json:"local_addr"`
+	RemoteAddr string    `json:"remote_addr"`
+	Transport  string    `json:"transport"`
+	Security   string    `json:"security,omitempty"`
+	Muxer      string    `json:"muxer,omitempty"`
+	Streams    []Stream  `json:"streams"`
+	Traffic    *Traffic  `json:"traffic,omitempty"`
+}
+
+// Stream describes a single stream.
+type Stream struct {
+	ID        string    `json:"id"`
+	Protocol  string    `json:"protocol"`
+	Direction string    `json:"direction"`
+	Opened    time.Time `json:"opened"`
+}
+
+// Traffic is the amount of data sent and received.
+type Traffic struct {
+	TotalIn  int64   `json:"total_in"`
+	TotalOut int64   `json:"total_out"`
+	RateIn   float64 `json:"rate_in"`
+	RateOut  float64 `json:"rate_out"`
+}
+
+// Event is an event emitted on the event bus of the host.
+type Event struct {
+	// Type is the name of the Go type of the event, e.g. "event.EvtPeerConnectednessChanged".
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// Option is an option for the introspection server.
+type Option func(*Server) error
+
+// WithInterval sets the interval at which the state is pushed to WebSocket clients.
+// Defaults to 1s.
+func WithInterval(d time.Duration) Option {
+	return func(s *Server) error {
+		if d <= 0 {
+			return errors.New("interval must be positive")
+		}
+		s.interval = d
+		return nil
+	}
+}
+
+// WithBandwidthReporter sets the reporter used to include traffic statistics in the state.
+func WithBandwidthReporter(r metrics.Reporter) Option {
+	return func(s *Server) error {
+		s.reporter = r
+		return nil
+	}
+}
+
+// WithOriginPatterns sets the origins that are allowed to open a WebSocket
+// connection, e.g. to allow a web UI that is served from a different host.
+// By default, only same-origin requests are allowed.
+// See nhooyr.io/websocket.AcceptOptions for the syntax of the patterns.
+func WithOriginPatterns(patterns ...string) Option {
+	return func(s *Server) error {
+		s.originPatterns = patterns
+		return nil
+	}
+}
+
+// Server is the introspection server.
+type Server struct {
+	host           host.Host
+	interval       time.Duration
+	reporter       metrics.Reporter
+	originPatterns []string
+
+	listener net.Listener
+	server   *http.Server
+	sub      event.Subscription
+
+	ctx       context.Context
+	ctxCancel context.CancelFunc
+	refCount  sync.WaitGroup
+
+	mx      sync.Mutex
+	clients map[*client]struct{}
+}
+
+type client struct {
+	events chan *Event
+}
+
+// New starts an introspection server for the host on the given TCP address,
+// e.g. "127.0.0.1:5001".
+func New(h host.Host, listenAddr string, opts ...Option) (*Server, error) {
+	s := &Server{
+		host:     h,
+		interval: defaultInterval,
+		clients:  make(map[*client]struct{}),
+	}
+	for _, opt := range opts {
+		if err := opt(s); err != nil {
+			return nil, err
+		}
+	}
+
+	sub, err := h.EventBus().Subscribe(event.WildcardSubscription, eventbus.Name("introspect"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to subscribe to events: %w", err)
+	}
+	ln, err := net.Listen("tcp", listenAddr)
+	if err != nil {
+		sub.Close()
+		return nil, err
+	}
+	s.sub = sub
+	s.listener = ln
+	s.ctx, s.ctxCancel = context.WithCancel(context.Background())
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/state", s.handleState)
+	mux.HandleFunc("/ws", s.handleWebSocket)
+	s.server = &http.Server{Handler: mux}
+
+	s.refCount.Add(2)
+	go s.forwardEvents()
+	go func() {
+		defer s.refCount.Done()
+		if err := s.server.Serve(ln); err != nil && err != http.ErrServerClosed {
+			log.Errorw("introspection server failed", "error", err)
+		}
+	}()
+	log.Infow("introspection server listening", "addr", ln.Addr())
+	return s, nil
+}
+
+// Addr returns the address the server is listening on.
+func (s *Server) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// Close stops the server and disconnects all clients.
+func (s *Server) Close() error {
+	s.ctxCancel()
+	err := s.server.Close()
+	s.sub.Close()
+	s.refCount.Wait()
+	return err
+}
+
+// State returns a snapshot of the current state of the host.
+func (s *Server) State() *State {
+	st := &State{
+		PeerID:      s.host.ID(),
+		Connections: []Connection{},
+	}
+	for _, a := range s.host.Addrs() {
+		st.Addrs = append(st.Addrs, a.String())
+	}
+	for _, c := range s.host.Network().Conns() {
+		st.Connections = append(st.Connections, s.connection(c))
+	}
+	sort.Slice(st.Connections, func(i, j int) bool { return st.Connections[i].ID < st.Connections[j].ID })
+	if s.reporter != nil {
+		st.Traffic = toTraffic(s.reporter.GetBandwidthTotals())
+	}
+	return st
+}
+
+func (s *Server) connection(c network.Conn) Connection {
+	stat := c.Stat()
+	state := c.ConnState()
+	conn := Connection{
+		ID:         c.ID(),
+		Peer:       c.RemotePeer(),
+		Direction:  stat.Direction.String(),
+		Opened:     stat.Opened,
+		Transient:  stat.Transient,
+		LocalAddr:  c.LocalMultiaddr().String(),
+		RemoteAddr: c.RemoteMultiaddr().String(),
+		Transport:  state.Transport,
+		Security:   string(state.Security),
+		Muxer:      string(state.StreamMultiplexer),
+		Streams:    []Stream{},
+	}
+	for _, str := range c.GetStreams() {
+		sstat := str.Stat()
+		conn.Streams = append(conn.Streams, Stream{
+			ID:        str.ID(),
+			Protocol:  string(str.Protocol()),
+			Direction: sstat.Direction.String(),
+			Opened:    sstat.Opened,
+		})
+	}
+	sort.Slice(conn.Streams, func(i, j int) bool { return conn.Streams[i].ID < conn.Streams[j].ID })
+	if s.reporter != nil {
+		conn.Traffic = toTraffic(s.reporter.GetBandwidthForPeer(c.RemotePeer()))
+	}
+	return conn
+}
+
+func toTraffic(st metrics.Stats) *Traffic {
+	return &Traffic{
+		TotalIn:  st.TotalIn,
+		TotalOut: st.TotalOut,
+		RateIn:   st.RateIn,
+		RateOut:  st.RateOut,
+	}
+}
+
+func (s *Server) handleState(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(s.State()); err != nil {
+		log.Debugw("failed to write state", "error", err)
+	}
+}
+
+func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	wsconn, err := ws.Accept(w, r, &ws.AcceptOptions{OriginPatterns: s.originPatterns})
+	if err != nil {
+		log.Debugw("failed to accept WebSocket connection", "error", err)
+		return
+	}
+	defer wsconn.Close(ws.StatusNormalClosure, "")
+
+	c := &client{events: make(chan *Event, clientEventQueueLen)}
+	s.mx.Lock()
+	s.clients[c] = struct{}{}
+	s.mx.Unlock()
+	defer func() {
+		s.mx.Lock()
+		delete(s.clients, c)
+		s.mx.Unlock()
+	}()
+
+	ctx, cancel := context.WithCancel(s.ctx)
+	defer cancel()
+	// We don't expect any messages from the client, but we need to read
+	// in order to process control frames, and to notice when the client goes away.
+	ctx = wsconn.CloseRead(ctx)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	if err := s.write(ctx, wsconn, &Message{Type: MessageTypeState, Time: time.Now(), State: s.State()}); err != nil {
+		return
+	}
+	for {
+		var msg *Message
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			msg = &Message{Type: MessageTypeState, Time: time.Now(), State: s.State()}
+		case evt := <-c.events:
+			msg = &Message{Type: MessageTypeEvent, Time: time.Now(), Event: evt}
+		}
+		if err := s.write(ctx, wsconn, msg); err != nil {
+			log.Debugw("failed to write to WebSocket client", "error", err)
+			return
+		}
+	}
+}
+
+func (s *Server) write(ctx context.Context, wsconn *ws.Conn, msg *Message) error {
+	ctx, cancel := context.WithTimeout(ctx, writeTimeout)
+	defer cancel()
+	return wsjson.Write(ctx, wsconn, msg)
+}
+
+func (s *Server) forwardEvents() {
+	defer s.refCount.Done()
+	for {
+		var e interface{}
+		// Closing a wildcard subscription doesn't close its channel.
+		select {
+		case <-s.ctx.Done():
+			return
+		case e = <-s.sub.Out():
+		}
+		evt := toEvent(e)
+		s.mx.Lock()
+		for c := range s.clients {
+			select {
+			case c.events <- evt:
+			default:
+				log.Debugw("dropping event for slow introspection client", "type", evt.Type)
+			}
+		}
+		s.mx.Unlock()
+	}
+}
+
+func toEvent(e interface{}) *Event {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		// Not all events can be serialized as JSON. Fall back to the Go representation.
+		payload, _ = json.Marshal(fmt.Sprintf("%+v", e))
+	}
+	return &Event{
+		Type:    reflect.TypeOf(e).String(),
+		Payload: payload,
+	}
+}
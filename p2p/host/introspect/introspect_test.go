@@ -0,0 +1,98 @@
+package introspect
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/AstaFrode/go-libp2p/core/network"
+	"github.com/AstaFrode/go-libp2p/core/peer"
+	blankhost "github.com/AstaFrode/go-libp2p/p2p/host/blank"
+	swarmt "github.com/AstaFrode/go-libp2p/p2p/net/swarm/testing"
+
+	"github.com/stretchr/testify/require"
+	ws "nhooyr.io/websocket"
+	"nhooyr.io/websocket/wsjson"
+)
+
+type testEvent struct {
+	Value string
+}
+
+func TestState(t *testing.T) {
+	h1 := blankhost.NewBlankHost(swarmt.GenSwarm(t))
+	defer h1.Close()
+	h2 := blankhost.NewBlankHost(swarmt.GenSwarm(t))
+	defer h2.Close()
+
+	s, err := New(h1, "127.0.0.1:0")
+	require.NoError(t, err)
+	defer s.Close()
+
+	h2.SetStreamHandler("/test", func(str network.Stream) {})
+	require.NoError(t, h1.Connect(context.Background(), peer.AddrInfo{ID: h2.ID(), Addrs: h2.Addrs()}))
+	str, err := h1.NewStream(context.Background(), h2.ID(), "/test")
+	require.NoError(t, err)
+	defer str.Close()
+
+	resp, err := http.Get(fmt.Sprintf("http://%s/state", s.Addr()))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var st State
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&st))
+	require.Equal(t, h1.ID(), st.PeerID)
+	require.Len(t, st.Connections, 1)
+	conn := st.Connections[0]
+	require.Equal(t, h2.ID(), conn.Peer)
+	require.Equal(t, "Outbound", conn.Direction)
+	require.Len(t, conn.Streams, 1)
+	require.Equal(t, "/test", conn.Streams[0].Protocol)
+}
+
+func TestWebSocket(t *testing.T) {
+	h := blankhost.NewBlankHost(swarmt.GenSwarm(t))
+	defer h.Close()
+
+	s, err := New(h, "127.0.0.1:0", WithInterval(50*time.Millisecond))
+	require.NoError(t, err)
+	defer s.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, _, err := ws.Dial(ctx, fmt.Sprintf("ws://%s/ws", s.Addr()), nil)
+	require.NoError(t, err)
+	defer conn.Close(ws.StatusNormalClosure, "")
+
+	var msg Message
+	require.NoError(t, wsjson.Read(ctx, conn, &msg))
+	require.Equal(t, MessageTypeState, msg.Type)
+	require.Equal(t, h.ID(), msg.State.PeerID)
+
+	// wait for the client to be registered before emitting the event
+	require.Eventually(t, func() bool {
+		s.mx.Lock()
+		defer s.mx.Unlock()
+		return len(s.clients) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	em, err := h.EventBus().Emitter(new(testEvent))
+	require.NoError(t, err)
+	defer em.Close()
+	require.NoError(t, em.Emit(testEvent{Value: "foobar"}))
+
+	for {
+		var msg Message
+		require.NoError(t, wsjson.Read(ctx, conn, &msg))
+		if msg.Type != MessageTypeEvent {
+			continue
+		}
+		require.Equal(t, "introspect.testEvent", msg.Event.Type)
+		require.JSONEq(t, `{"Value":"foobar"}`, string(msg.Event.Payload))
+		break
+	}
+}
@@ -0,0 +1,163 @@
+package pstoremem
+
+import (
+	"container/list"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/AstaFrode/go-libp2p/core/peer"
+	pstore "github.com/AstaFrode/go-libp2p/core/peerstore"
+
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// maxHandshakeSamples is the number of handshake durations kept per address to
+// compute the median handshake duration.
+const maxHandshakeSamples = 9
+
+// dialHistoryTTL is the time after which the dial history of an address is forgotten
+// if it wasn't dialed again.
+const dialHistoryTTL = 24 * time.Hour
+
+// maxDialHistoryPeers is the number of peers the dial history is kept for. When it's
+// exceeded, the history of the peer that was dialed least recently is forgotten.
+const maxDialHistoryPeers = 10000
+
+type dialRecord struct {
+	successes, failures int
+	// number of failed dials since the last successful dial
+	failuresSinceSuccess int
+	lastSuccess          time.Time
+	lastDial             time.Time
+	// ring buffer of the durations of the most recent successful handshakes
+	handshakes []time.Duration
+	next       int
+}
+
+func (r *dialRecord) medianHandshake() time.Duration {
+	if len(r.handshakes) == 0 {
+		return 0
+	}
+	sorted := make([]time.Duration, len(r.handshakes))
+	copy(sorted, r.handshakes)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted[len(sorted)/2]
+}
+
+type peerDialHistory struct {
+	p        peer.ID
+	addrs    map[string]*dialRecord // addr bytes -> record
+	lastDial time.Time
+}
+
+// memoryDialHistoryBook keeps the dial history independently of the rest of the
+// peerstore: it's not removed when the peer is removed from the peerstore, but
+// expires after dialHistoryTTL, and is bounded to maxDialHistoryPeers peers.
+type memoryDialHistoryBook struct {
+	ttl      time.Duration
+	maxPeers int
+	now      func() time.Time
+
+	mx    sync.Mutex
+	peers map[peer.ID]*list.Element
+	lru   *list.List // of *peerDialHistory, most recently dialed first
+}
+
+var _ pstore.DialHistoryBook = (*memoryDialHistoryBook)(nil)
+
+func NewDialHistoryBook() *memoryDialHistoryBook {
+	return &memoryDialHistoryBook{
+		ttl:      dialHistoryTTL,
+		maxPeers: maxDialHistoryPeers,
+		now:      time.Now,
+		peers:    make(map[peer.ID]*list.Element),
+		lru:      list.New(),
+	}
+}
+
+func (db *memoryDialHistoryBook) RecordDial(p peer.ID, addr ma.Multiaddr, success bool, handshake time.Duration) {
+	now := db.now()
+
+	db.mx.Lock()
+	defer db.mx.Unlock()
+
+	db.gc(now)
+	var h *peerDialHistory
+	if e, ok := db.peers[p]; ok {
+		h = e.Value.(*peerDialHistory)
+		db.lru.MoveToFront(e)
+	} else {
+		h = &peerDialHistory{p: p, addrs: make(map[string]*dialRecord)}
+		db.peers[p] = db.lru.PushFront(h)
+		if db.lru.Len() > db.maxPeers {
+			db.remove(db.lru.Back())
+		}
+	}
+	h.lastDial = now
+
+	key := string(addr.Bytes())
+	r, ok := h.addrs[key]
+	if !ok || now.Sub(r.lastDial) > db.ttl {
+		r = &dialRecord{}
+		h.addrs[key] = r
+	}
+	r.lastDial = now
+	if !success {
+		r.failures++
+		r.failuresSinceSuccess++
+		return
+	}
+	r.successes++
+	r.failuresSinceSuccess = 0
+	r.lastSuccess = now
+	if len(r.handshakes) < maxHandshakeSamples {
+		r.handshakes = append(r.handshakes, handshake)
+	} else {
+		r.handshakes[r.next] = handshake
+	}
+	r.next = (r.next + 1) % maxHandshakeSamples
+}
+
+// gc removes the peers that weren't dialed within the TTL. They're at the back of the LRU list.
+func (db *memoryDialHistoryBook) gc(now time.Time) {
+	for e := db.lru.Back(); e != nil && now.Sub(e.Value.(*peerDialHistory).lastDial) > db.ttl; e = db.lru.Back() {
+		db.remove(e)
+	}
+}
+
+func (db *memoryDialHistoryBook) remove(e *list.Element) {
+	db.lru.Remove(e)
+	delete(db.peers, e.Value.(*peerDialHistory).p)
+}
+
+func (db *memoryDialHistoryBook) DialStats(p peer.ID, addr ma.Multiaddr) pstore.DialStats {
+	now := db.now()
+
+	db.mx.Lock()
+	defer db.mx.Unlock()
+
+	e, ok := db.peers[p]
+	if !ok {
+		return pstore.DialStats{}
+	}
+	r, ok := e.Value.(*peerDialHistory).addrs[string(addr.Bytes())]
+	if !ok || now.Sub(r.lastDial) > db.ttl {
+		return pstore.DialStats{}
+	}
+	return pstore.DialStats{
+		Successes:            r.successes,
+		Failures:             r.failures,
+		FailuresSinceSuccess: r.failuresSinceSuccess,
+		LastSuccess:          r.lastSuccess,
+		MedianHandshake:      r.medianHandshake(),
+	}
+}
+
+func (db *memoryDialHistoryBook) RemovePeer(p peer.ID) {
+	db.mx.Lock()
+	if e, ok := db.peers[p]; ok {
+		db.remove(e)
+	}
+	db.mx.Unlock()
+}
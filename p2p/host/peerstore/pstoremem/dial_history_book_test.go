@@ -0,0 +1,96 @@
+package pstoremem
+
+import (
+	"testing"
+	"time"
+
+	pstore "github.com/AstaFrode/go-libp2p/core/peerstore"
+	"github.com/AstaFrode/go-libp2p/core/test"
+
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDialHistoryBook(t *testing.T) {
+	db := NewDialHistoryBook()
+	p := test.RandPeerIDFatal(t)
+	addr := ma.StringCast("/ip4/1.2.3.4/tcp/1234")
+	other := ma.StringCast("/ip4/1.2.3.4/udp/1234/quic-v1")
+
+	require.Equal(t, pstore.DialStats{}, db.DialStats(p, addr))
+	require.Zero(t, db.DialStats(p, addr).SuccessRate())
+
+	db.RecordDial(p, addr, false, 0)
+	for _, d := range []time.Duration{30, 10, 20} {
+		db.RecordDial(p, addr, true, d*time.Millisecond)
+	}
+	stats := db.DialStats(p, addr)
+	require.Equal(t, 3, stats.Successes)
+	require.Equal(t, 1, stats.Failures)
+	require.Zero(t, stats.FailuresSinceSuccess)
+	require.Equal(t, 0.75, stats.SuccessRate())
+	require.Equal(t, 20*time.Millisecond, stats.MedianHandshake)
+	require.WithinDuration(t, time.Now(), stats.LastSuccess, time.Second)
+	require.Equal(t, pstore.DialStats{}, db.DialStats(p, other))
+
+	// only the most recent handshakes are taken into account
+	for i := 0; i < maxHandshakeSamples; i++ {
+		db.RecordDial(p, addr, true, time.Second)
+	}
+	require.Equal(t, time.Second, db.DialStats(p, addr).MedianHandshake)
+
+	db.RemovePeer(p)
+	require.Equal(t, pstore.DialStats{}, db.DialStats(p, addr))
+}
+
+func TestDialHistoryBookFailuresSinceSuccess(t *testing.T) {
+	db := NewDialHistoryBook()
+	p := test.RandPeerIDFatal(t)
+	addr := ma.StringCast("/ip4/1.2.3.4/tcp/1234")
+
+	db.RecordDial(p, addr, true, time.Millisecond)
+	db.RecordDial(p, addr, false, 0)
+	db.RecordDial(p, addr, false, 0)
+	require.Equal(t, 2, db.DialStats(p, addr).FailuresSinceSuccess)
+	db.RecordDial(p, addr, true, time.Millisecond)
+	require.Zero(t, db.DialStats(p, addr).FailuresSinceSuccess)
+}
+
+func TestDialHistoryBookExpiry(t *testing.T) {
+	db := NewDialHistoryBook()
+	now := time.Now()
+	db.now = func() time.Time { return now }
+	p1 := test.RandPeerIDFatal(t)
+	p2 := test.RandPeerIDFatal(t)
+	addr := ma.StringCast("/ip4/1.2.3.4/tcp/1234")
+
+	db.RecordDial(p1, addr, true, time.Millisecond)
+	now = now.Add(dialHistoryTTL / 2)
+	db.RecordDial(p2, addr, false, 0)
+	require.Equal(t, 1, db.DialStats(p1, addr).Successes)
+
+	now = now.Add(dialHistoryTTL/2 + time.Second)
+	require.Equal(t, pstore.DialStats{}, db.DialStats(p1, addr))
+	require.Equal(t, 1, db.DialStats(p2, addr).Failures)
+	// the expired peer is removed on the next dial
+	db.RecordDial(p2, addr, false, 0)
+	require.Len(t, db.peers, 1)
+}
+
+func TestDialHistoryBookMaxPeers(t *testing.T) {
+	db := NewDialHistoryBook()
+	db.maxPeers = 2
+	addr := ma.StringCast("/ip4/1.2.3.4/tcp/1234")
+	p1 := test.RandPeerIDFatal(t)
+	p2 := test.RandPeerIDFatal(t)
+	p3 := test.RandPeerIDFatal(t)
+
+	db.RecordDial(p1, addr, true, time.Millisecond)
+	db.RecordDial(p2, addr, true, time.Millisecond)
+	db.RecordDial(p1, addr, true, time.Millisecond)
+	// p2 is the least recently dialed peer
+	db.RecordDial(p3, addr, true, time.Millisecond)
+	require.Equal(t, 2, db.DialStats(p1, addr).Successes)
+	require.Equal(t, pstore.DialStats{}, db.DialStats(p2, addr))
+	require.Equal(t, 1, db.DialStats(p3, addr).Successes)
+}
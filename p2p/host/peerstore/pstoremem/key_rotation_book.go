@@ -0,0 +1,90 @@
+package pstoremem
+
+import (
+	"sync"
+
+	"github.com/AstaFrode/go-libp2p/core/peer"
+	pstore "github.com/AstaFrode/go-libp2p/core/peerstore"
+	"github.com/AstaFrode/go-libp2p/core/record"
+)
+
+// maxRotationChain limits the number of rotations followed when resolving a peer ID.
+const maxRotationChain = 32
+
+type keyRotationEntry struct {
+	envelope *record.Envelope
+	rec      *peer.KeyRotationRecord
+}
+
+// memoryKeyRotationBook keeps at most one record rotating to each peer ID. Since old
+// keys are cheap to generate, this prevents a peer from filling the book with records.
+type memoryKeyRotationBook struct {
+	mx sync.RWMutex
+	// rotations maps the old peer ID to the record rotating away from it
+	rotations map[peer.ID]keyRotationEntry
+	// byNewID maps the new peer ID to the old peer ID of the record rotating to it
+	byNewID map[peer.ID]peer.ID
+}
+
+var _ pstore.KeyRotationBook = (*memoryKeyRotationBook)(nil)
+
+func NewKeyRotationBook() *memoryKeyRotationBook {
+	return &memoryKeyRotationBook{
+		rotations: make(map[peer.ID]keyRotationEntry),
+		byNewID:   make(map[peer.ID]peer.ID),
+	}
+}
+
+func (rb *memoryKeyRotationBook) ConsumeKeyRotationRecord(s *record.Envelope) (bool, error) {
+	rec, err := peer.KeyRotationRecordFromEnvelope(s)
+	if err != nil {
+		return false, err
+	}
+
+	rb.mx.Lock()
+	defer rb.mx.Unlock()
+	if e, ok := rb.rotations[rec.OldID]; ok {
+		if e.rec.Seq >= rec.Seq {
+			return false, nil
+		}
+		delete(rb.byNewID, e.rec.NewID)
+	}
+	// replace the previous record rotating to the same peer
+	if oldID, ok := rb.byNewID[rec.NewID]; ok {
+		delete(rb.rotations, oldID)
+	}
+	rb.rotations[rec.OldID] = keyRotationEntry{envelope: s, rec: rec}
+	rb.byNewID[rec.NewID] = rec.OldID
+	return true, nil
+}
+
+// RemovePeer removes the record rotating to p.
+func (rb *memoryKeyRotationBook) RemovePeer(p peer.ID) {
+	rb.mx.Lock()
+	defer rb.mx.Unlock()
+	if oldID, ok := rb.byNewID[p]; ok {
+		delete(rb.rotations, oldID)
+		delete(rb.byNewID, p)
+	}
+}
+
+func (rb *memoryKeyRotationBook) GetKeyRotationRecord(p peer.ID) *record.Envelope {
+	rb.mx.RLock()
+	defer rb.mx.RUnlock()
+	return rb.rotations[p].envelope
+}
+
+func (rb *memoryKeyRotationBook) ResolveRotatedPeer(p peer.ID) peer.ID {
+	rb.mx.RLock()
+	defer rb.mx.RUnlock()
+	// Rotation records are signed by the old key only, so a chain can contain a cycle.
+	// Stop following it after maxRotationChain hops.
+	for i := 0; i < maxRotationChain; i++ {
+		e, ok := rb.rotations[p]
+		if !ok {
+			break
+		}
+		p = e.rec.NewID
+	}
+	return p
+}
@@ -0,0 +1,102 @@
+package pstoremem
+
+import (
+	"testing"
+
+	"github.com/AstaFrode/go-libp2p/core/crypto"
+	"github.com/AstaFrode/go-libp2p/core/peer"
+	"github.com/AstaFrode/go-libp2p/core/record"
+	"github.com/AstaFrode/go-libp2p/core/test"
+
+	"github.com/stretchr/testify/require"
+)
+
+func genRotationKey(t *testing.T) (crypto.PrivKey, peer.ID) {
+	t.Helper()
+	sk, _, err := test.RandTestKeyPair(crypto.Ed25519, 256)
+	require.NoError(t, err)
+	id, err := peer.IDFromPrivateKey(sk)
+	require.NoError(t, err)
+	return sk, id
+}
+
+func signRotation(t *testing.T, oldKey crypto.PrivKey, oldID, newID peer.ID, seq uint64) *record.Envelope {
+	t.Helper()
+	env, err := (&peer.KeyRotationRecord{OldID: oldID, NewID: newID, Seq: seq}).Sign(oldKey)
+	require.NoError(t, err)
+	return env
+}
+
+func TestKeyRotationBook(t *testing.T) {
+	ps, err := NewPeerstore()
+	require.NoError(t, err)
+	defer ps.Close()
+
+	k1, id1 := genRotationKey(t)
+	k2, id2 := genRotationKey(t)
+	_, id3 := genRotationKey(t)
+	_, id4 := genRotationKey(t)
+
+	require.Equal(t, id1, ps.ResolveRotatedPeer(id1))
+	require.Nil(t, ps.GetKeyRotationRecord(id1))
+
+	env := signRotation(t, k1, id1, id2, 2)
+	accepted, err := ps.ConsumeKeyRotationRecord(env)
+	require.NoError(t, err)
+	require.True(t, accepted)
+	require.Equal(t, env, ps.GetKeyRotationRecord(id1))
+	require.Equal(t, id2, ps.ResolveRotatedPeer(id1))
+
+	// follow the chain
+	accepted, err = ps.ConsumeKeyRotationRecord(signRotation(t, k2, id2, id3, 1))
+	require.NoError(t, err)
+	require.True(t, accepted)
+	require.Equal(t, id3, ps.ResolveRotatedPeer(id1))
+	require.Equal(t, id3, ps.ResolveRotatedPeer(id2))
+
+	// older records are ignored
+	accepted, err = ps.ConsumeKeyRotationRecord(signRotation(t, k1, id1, id4, 1))
+	require.NoError(t, err)
+	require.False(t, accepted)
+	require.Equal(t, id3, ps.ResolveRotatedPeer(id1))
+
+	// records not signed by the old key are rejected
+	env, err = record.Seal(&peer.KeyRotationRecord{OldID: id3, NewID: id4, Seq: 1}, k2)
+	require.NoError(t, err)
+	_, err = ps.ConsumeKeyRotationRecord(env)
+	require.ErrorIs(t, err, peer.ErrKeyRotationSignerMismatch)
+	require.Equal(t, id3, ps.ResolveRotatedPeer(id1))
+
+	// cycles don't cause an infinite loop
+	accepted, err = ps.ConsumeKeyRotationRecord(signRotation(t, k2, id2, id1, 2))
+	require.NoError(t, err)
+	require.True(t, accepted)
+	ps.ResolveRotatedPeer(id1)
+}
+
+func TestKeyRotationBookOneRecordPerNewID(t *testing.T) {
+	ps, err := NewPeerstore()
+	require.NoError(t, err)
+	defer ps.Close()
+
+	_, newID := genRotationKey(t)
+	var oldIDs []peer.ID
+	for i := 0; i < 10; i++ {
+		k, id := genRotationKey(t)
+		accepted, err := ps.ConsumeKeyRotationRecord(signRotation(t, k, id, newID, 1))
+		require.NoError(t, err)
+		require.True(t, accepted)
+		oldIDs = append(oldIDs, id)
+	}
+	// only the last record is kept
+	for _, id := range oldIDs[:9] {
+		require.Nil(t, ps.GetKeyRotationRecord(id))
+	}
+	require.Equal(t, newID, ps.ResolveRotatedPeer(oldIDs[9]))
+	require.Len(t, ps.rotations, 1)
+
+	ps.RemovePeer(newID)
+	require.Nil(t, ps.GetKeyRotationRecord(oldIDs[9]))
+	require.Empty(t, ps.rotations)
+	require.Empty(t, ps.byNewID)
+}
@@ -16,6 +16,9 @@ type pstoremem struct {
 	*memoryAddrBook
 	*memoryProtoBook
 	*memoryPeerMetadata
+	*memoryKeyRotationBook
+	*memoryDialHistoryBook
+	*memoryScoreBook
 }
 
 var _ peerstore.Peerstore = &pstoremem{}
@@ -34,12 +37,15 @@ func NewPeerstore(opts ...Option) (ps *pstoremem, err error) {
 	}()
 
 	var protoBookOpts []ProtoBookOption
+	var scoreBookOpts []ScoreBookOption
 	for _, opt := range opts {
 		switch o := opt.(type) {
 		case ProtoBookOption:
 			protoBookOpts = append(protoBookOpts, o)
 		case AddrBookOption:
 			o(ab)
+		case ScoreBookOption:
+			scoreBookOpts = append(scoreBookOpts, o)
 		default:
 			return nil, fmt.Errorf("unexpected peer store option: %v", o)
 		}
@@ -48,12 +54,19 @@ func NewPeerstore(opts ...Option) (ps *pstoremem, err error) {
 	if err != nil {
 		return nil, err
 	}
+	sb, err := NewScoreBook(scoreBookOpts...)
+	if err != nil {
+		return nil, err
+	}
 	return &pstoremem{
-		Metrics:            pstore.NewMetrics(),
-		memoryKeyBook:      NewKeyBook(),
-		memoryAddrBook:     ab,
-		memoryProtoBook:    pb,
-		memoryPeerMetadata: NewPeerMetadata(),
+		Metrics:               pstore.NewMetrics(),
+		memoryKeyBook:         NewKeyBook(),
+		memoryAddrBook:        ab,
+		memoryProtoBook:       pb,
+		memoryPeerMetadata:    NewPeerMetadata(),
+		memoryKeyRotationBook: NewKeyRotationBook(),
+		memoryDialHistoryBook: NewDialHistoryBook(),
+		memoryScoreBook:       sb,
 	}, nil
 }
 
@@ -105,10 +118,13 @@ func (ps *pstoremem) PeerInfo(p peer.ID) peer.AddrInfo {
 // * the ProtoBook
 // * the PeerMetadata
 // * the Metrics
-// It DOES NOT remove the peer from the AddrBook.
+// * the KeyRotationBook, which forgets the rotation to the peer
+// It DOES NOT remove the peer from the AddrBook, nor from the DialHistoryBook and
+// the ScoreBook, whose records expire on their own.
 func (ps *pstoremem) RemovePeer(p peer.ID) {
 	ps.memoryKeyBook.RemovePeer(p)
 	ps.memoryProtoBook.RemovePeer(p)
 	ps.memoryPeerMetadata.RemovePeer(p)
 	ps.Metrics.RemovePeer(p)
+	ps.memoryKeyRotationBook.RemovePeer(p)
 }
@@ -0,0 +1,139 @@
+package pstoremem
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/AstaFrode/go-libp2p/core/peer"
+	pstore "github.com/AstaFrode/go-libp2p/core/peerstore"
+)
+
+// defaultScoreHalfLife is the time after which an observation only counts half.
+const defaultScoreHalfLife = 30 * time.Minute
+
+// minScoreValue is the absolute decayed value below which a score record is pruned.
+const minScoreValue = 0.01
+
+type scoreRecord struct {
+	value   float64
+	updated time.Time
+}
+
+type memoryScoreBook struct {
+	halfLife time.Duration
+	now      func() time.Time
+
+	mx        sync.RWMutex
+	scores    map[peer.ID]map[string]*scoreRecord // peer -> source -> record
+	lastPrune time.Time
+}
+
+var _ pstore.ScoreBook = (*memoryScoreBook)(nil)
+
+type ScoreBookOption func(book *memoryScoreBook) error
+
+// WithScoreHalfLife sets the time after which an observation only counts half.
+func WithScoreHalfLife(d time.Duration) ScoreBookOption {
+	return func(sb *memoryScoreBook) error {
+		if d <= 0 {
+			return errors.New("score half life must be positive")
+		}
+		sb.halfLife = d
+		return nil
+	}
+}
+
+// NewScoreBook creates a ScoreBook. The scores are kept when the peer is removed from the
+// peerstore, such that penalties outlive disconnections. Instead, they decay until they're
+// negligible, and are pruned then.
+func NewScoreBook(opts ...ScoreBookOption) (*memoryScoreBook, error) {
+	sb := &memoryScoreBook{
+		halfLife: defaultScoreHalfLife,
+		now:      time.Now,
+		scores:   make(map[peer.ID]map[string]*scoreRecord),
+	}
+	for _, opt := range opts {
+		if err := opt(sb); err != nil {
+			return nil, err
+		}
+	}
+	return sb, nil
+}
+
+func (sb *memoryScoreBook) decayed(r *scoreRecord, now time.Time) float64 {
+	return r.value * math.Exp2(-float64(now.Sub(r.updated))/float64(sb.halfLife))
+}
+
+func (sb *memoryScoreBook) RecordObservation(p peer.ID, source string, value float64) {
+	now := sb.now()
+
+	sb.mx.Lock()
+	defer sb.mx.Unlock()
+
+	if now.Sub(sb.lastPrune) > sb.halfLife {
+		sb.prune(now)
+	}
+	sources, ok := sb.scores[p]
+	if !ok {
+		sources = make(map[string]*scoreRecord)
+		sb.scores[p] = sources
+	}
+	r, ok := sources[source]
+	if !ok {
+		sources[source] = &scoreRecord{value: value, updated: now}
+		return
+	}
+	r.value = sb.decayed(r, now) + value
+	r.updated = now
+}
+
+// prune removes the records whose decayed value is negligible. It must be called with mx held.
+func (sb *memoryScoreBook) prune(now time.Time) {
+	sb.lastPrune = now
+	for p, sources := range sb.scores {
+		for source, r := range sources {
+			if math.Abs(sb.decayed(r, now)) < minScoreValue {
+				delete(sources, source)
+			}
+		}
+		if len(sources) == 0 {
+			delete(sb.scores, p)
+		}
+	}
+}
+
+func (sb *memoryScoreBook) Score(p peer.ID) float64 {
+	now := sb.now()
+
+	sb.mx.RLock()
+	defer sb.mx.RUnlock()
+
+	var score float64
+	for _, r := range sb.scores[p] {
+		score += sb.decayed(r, now)
+	}
+	return score
+}
+
+func (sb *memoryScoreBook) ScoresBySource(p peer.ID) map[string]float64 {
+	now := sb.now()
+
+	sb.mx.RLock()
+	defer sb.mx.RUnlock()
+
+	scores := make(map[string]float64, len(sb.scores[p]))
+	for source, r := range sb.scores[p] {
+		scores[source] = sb.decayed(r, now)
+	}
+	return scores
+}
+
+// RemovePeer removes the scores of p. It's not called when the peer is removed from
+// the peerstore.
+func (sb *memoryScoreBook) RemovePeer(p peer.ID) {
+	sb.mx.Lock()
+	delete(sb.scores, p)
+	sb.mx.Unlock()
+}
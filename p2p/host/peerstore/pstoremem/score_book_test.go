@@ -0,0 +1,73 @@
+package pstoremem
+
+import (
+	"testing"
+	"time"
+
+	pstore "github.com/AstaFrode/go-libp2p/core/peerstore"
+	"github.com/AstaFrode/go-libp2p/core/test"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestScoreBook(t *testing.T) {
+	sb, err := NewScoreBook(WithScoreHalfLife(time.Minute))
+	require.NoError(t, err)
+	now := time.Now()
+	sb.now = func() time.Time { return now }
+	p := test.RandPeerIDFatal(t)
+
+	require.Zero(t, sb.Score(p))
+	require.Empty(t, sb.ScoresBySource(p))
+
+	sb.RecordObservation(p, pstore.ScoreSourceHolePunch, 4)
+	sb.RecordObservation(p, pstore.ScoreSourceRelay, -1)
+	require.Equal(t, 3.0, sb.Score(p))
+
+	// observations decay over time
+	now = now.Add(time.Minute)
+	require.Equal(t, 1.5, sb.Score(p))
+	sb.RecordObservation(p, pstore.ScoreSourceHolePunch, 1)
+	require.Equal(t, map[string]float64{
+		pstore.ScoreSourceHolePunch: 3,
+		pstore.ScoreSourceRelay:     -0.5,
+	}, sb.ScoresBySource(p))
+
+	sb.RemovePeer(p)
+	require.Zero(t, sb.Score(p))
+}
+
+func TestScoreBookPrune(t *testing.T) {
+	sb, err := NewScoreBook(WithScoreHalfLife(time.Minute))
+	require.NoError(t, err)
+	now := time.Now()
+	sb.now = func() time.Time { return now }
+	p1 := test.RandPeerIDFatal(t)
+	p2 := test.RandPeerIDFatal(t)
+
+	sb.RecordObservation(p1, pstore.ScoreSourceRelay, -1)
+	sb.RecordObservation(p2, pstore.ScoreSourceRelay, -100)
+	// after 10 half lives, the observation about p1 is negligible, the one about p2 isn't
+	now = now.Add(10 * time.Minute)
+	sb.RecordObservation(p2, pstore.ScoreSourceHolePunch, 1)
+	require.NotContains(t, sb.scores, p1)
+	require.Contains(t, sb.scores, p2)
+	require.Contains(t, sb.ScoresBySource(p2), pstore.ScoreSourceRelay)
+}
+
+func TestPeerstoreScoreBook(t *testing.T) {
+	ps, err := NewPeerstore()
+	require.NoError(t, err)
+	defer ps.Close()
+	sb, ok := pstore.GetScoreBook(ps)
+	require.True(t, ok)
+	p := test.RandPeerIDFatal(t)
+	sb.RecordObservation(p, pstore.ScoreSourceAutoNAT, 1)
+	require.Positive(t, sb.Score(p))
+	// the score outlives the removal of the peer, e.g. after it disconnected
+	ps.RemovePeer(p)
+	require.Positive(t, sb.Score(p))
+
+	_, err = NewPeerstore(WithScoreHalfLife(0))
+	require.Error(t, err)
+}
@@ -69,6 +69,16 @@ func (s *resourceScope) SetLimit(limit Limit) {
 	s.rc.limit = limit
 }
 
+// ConnLimit returns the connection limit of the scope, for inbound or outbound connections.
+func (s *resourceScope) ConnLimit(dir network.Direction) int {
+	return s.Limit().GetConnLimit(dir)
+}
+
+// MemoryLimit returns the memory limit of the scope.
+func (s *resourceScope) MemoryLimit() int64 {
+	return s.Limit().GetMemoryLimit()
+}
+
 func (s *protocolScope) SetLimit(limit Limit) {
 	s.rcmgr.setStickyProtocol(s.proto)
 	s.resourceScope.SetLimit(limit)
@@ -0,0 +1,51 @@
+//go:build linux
+
+package watchdog
+
+import (
+	"bytes"
+	"os"
+	"strconv"
+
+	"golang.org/x/sys/unix"
+)
+
+// fdUsage returns the number of open file descriptors and the file descriptor limit.
+func fdUsage() (int, int) {
+	var l unix.Rlimit
+	if err := unix.Getrlimit(unix.RLIMIT_NOFILE, &l); err != nil {
+		log.Debugw("failed to get fd limit", "error", err)
+		return 0, 0
+	}
+	f, err := os.Open("/proc/self/fd")
+	if err != nil {
+		log.Debugw("failed to count open fds", "error", err)
+		return 0, 0
+	}
+	defer f.Close()
+	names, err := f.Readdirnames(-1)
+	if err != nil {
+		log.Debugw("failed to count open fds", "error", err)
+		return 0, 0
+	}
+	// don't count the fd used to read the directory
+	return len(names) - 1, int(l.Cur)
+}
+
+// memoryUsage returns the resident set size of the process.
+func memoryUsage() int64 {
+	b, err := os.ReadFile("/proc/self/statm")
+	if err != nil {
+		log.Debugw("failed to read memory usage", "error", err)
+		return 0
+	}
+	fields := bytes.Fields(b)
+	if len(fields) < 2 {
+		return 0
+	}
+	pages, err := strconv.ParseInt(string(fields[1]), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return pages * int64(os.Getpagesize())
+}
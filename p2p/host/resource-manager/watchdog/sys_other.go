@@ -0,0 +1,18 @@
+//go:build !linux
+
+package watchdog
+
+import "runtime"
+
+// fdUsage is not implemented on other systems: the file descriptor pressure is never detected.
+func fdUsage() (int, int) {
+	return 0, 0
+}
+
+// memoryUsage returns the memory obtained from the operating system by the Go runtime,
+// minus the memory returned to it.
+func memoryUsage() int64 {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	return int64(ms.Sys - ms.HeapReleased)
+}
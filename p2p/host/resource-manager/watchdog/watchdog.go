@@ -0,0 +1,325 @@
+// Package watchdog implements a watchdog adjusting the limits of the resource manager to
+// the resources left to the process.
+//
+// The limits of the resource manager are static, and the resource manager only accounts for
+// the resources it is told about. When the process as a whole approaches its file descriptor
+// limit or its memory limit, e.g. because the application itself uses a lot of them, the
+// watchdog tightens the system limits of the resource manager, so that libp2p stops accepting
+// new connections and streams, and optionally trims connections. Once the pressure subsided,
+// the original limits are restored.
+//
+// File descriptors are only monitored on Linux. On other platforms, only the memory is
+// monitored, provided a memory limit is set.
+package watchdog
+
+import (
+	"context"
+	"errors"
+	"math"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/AstaFrode/go-libp2p/core/connmgr"
+	"github.com/AstaFrode/go-libp2p/core/event"
+	"github.com/AstaFrode/go-libp2p/core/network"
+	"github.com/AstaFrode/go-libp2p/p2p/host/eventbus"
+	rcmgr "github.com/AstaFrode/go-libp2p/p2p/host/resource-manager"
+
+	logging "github.com/ipfs/go-log/v2"
+)
+
+var log = logging.Logger("rcmgr-watchdog")
+
+type Option func(*Watchdog) error
+
+// WithInterval sets the interval between two samples of the resource usage.
+// Default: 10s.
+func WithInterval(d time.Duration) Option {
+	return func(w *Watchdog) error {
+		if d <= 0 {
+			return errors.New("watchdog interval must be positive")
+		}
+		w.interval = d
+		return nil
+	}
+}
+
+// WithWatermarks sets the fractions of the file descriptor and memory limits of the process
+// at which the resource manager limits are tightened (high) and restored (low).
+// Default: low 0.7, high 0.9.
+func WithWatermarks(low, high float64) Option {
+	return func(w *Watchdog) error {
+		if low <= 0 || high > 1 || low >= high {
+			return errors.New("watermarks must satisfy 0 < low < high <= 1")
+		}
+		w.low, w.high = low, high
+		return nil
+	}
+}
+
+// WithTightenFactor sets the factor applied to the system limits of the resource manager
+// when tightening them. Default: 0.5.
+func WithTightenFactor(f float64) Option {
+	return func(w *Watchdog) error {
+		if f <= 0 || f >= 1 {
+			return errors.New("tighten factor must be between 0 and 1")
+		}
+		w.factor = f
+		return nil
+	}
+}
+
+// WithMemoryLimit sets the memory limit of the process, in bytes.
+// Default: the soft memory limit of the Go runtime (GOMEMLIMIT), if one is set.
+// Without a memory limit, only file descriptors are monitored, which is only supported on Linux.
+func WithMemoryLimit(limit int64) Option {
+	return func(w *Watchdog) error {
+		if limit <= 0 {
+			return errors.New("memory limit must be positive")
+		}
+		w.memoryLimit = limit
+		return nil
+	}
+}
+
+// WithConnManager makes the watchdog trim the connections of the connection manager when
+// it tightens the limits.
+func WithConnManager(cm connmgr.ConnManager) Option {
+	return func(w *Watchdog) error {
+		w.connmgr = cm
+		return nil
+	}
+}
+
+// usage is the resource usage of the process.
+type usage struct {
+	FDs, FDLimit        int
+	Memory, MemoryLimit int64
+}
+
+// pressure returns the highest fraction of a limit used.
+func (u usage) pressure() float64 {
+	var p float64
+	if u.FDLimit > 0 {
+		p = float64(u.FDs) / float64(u.FDLimit)
+	}
+	if u.MemoryLimit > 0 {
+		if mp := float64(u.Memory) / float64(u.MemoryLimit); mp > p {
+			p = mp
+		}
+	}
+	return p
+}
+
+// Watchdog monitors the file descriptors and the memory used by the process, and tightens the
+// system limits of the resource manager when they approach the limits of the process.
+//
+// Every adjustment is announced by an event.EvtResourceLimitsAdjusted.
+// Changes made to the system limits while they are tightened are overwritten when they are
+// restored.
+type Watchdog struct {
+	rcmgr   network.ResourceManager
+	connmgr connmgr.ConnManager
+
+	interval    time.Duration
+	low, high   float64
+	factor      float64
+	memoryLimit int64
+
+	// sample returns the current resource usage. It is overridden in tests.
+	sample func() usage
+
+	ctx       context.Context
+	ctxCancel context.CancelFunc
+	refCount  sync.WaitGroup
+	emitter   event.Emitter
+
+	mx       sync.Mutex
+	original rcmgr.Limit // the system limit before it was tightened, nil if not tightened
+}
+
+// NewWatchdog creates a new watchdog for the resource manager. Adjustments are emitted on
+// the event bus. Call Start to start monitoring the resource usage.
+func NewWatchdog(mgr network.ResourceManager, bus event.Bus, opts ...Option) (*Watchdog, error) {
+	w := &Watchdog{
+		rcmgr:    mgr,
+		interval: 10 * time.Second,
+		low:      0.7,
+		high:     0.9,
+		factor:   0.5,
+	}
+	if limit := debug.SetMemoryLimit(-1); limit != math.MaxInt64 {
+		w.memoryLimit = limit
+	}
+	for _, opt := range opts {
+		if err := opt(w); err != nil {
+			return nil, err
+		}
+	}
+	w.sample = w.sampleProcess
+	emitter, err := bus.Emitter(new(event.EvtResourceLimitsAdjusted), eventbus.Stateful)
+	if err != nil {
+		return nil, err
+	}
+	w.emitter = emitter
+	w.ctx, w.ctxCancel = context.WithCancel(context.Background())
+	return w, nil
+}
+
+// Start starts monitoring the resource usage in the background.
+// It does nothing if the limits of the resource manager can't be changed.
+func (w *Watchdog) Start() {
+	if _, err := w.systemLimit(); err != nil {
+		log.Warnw("not starting the resource watchdog", "error", err)
+		return
+	}
+	w.refCount.Add(1)
+	go w.background()
+}
+
+// Close stops the watchdog, and restores the original limits if they were tightened.
+func (w *Watchdog) Close() error {
+	w.ctxCancel()
+	w.refCount.Wait()
+	w.mx.Lock()
+	if w.original != nil {
+		w.setSystemLimit(w.original)
+		w.original = nil
+	}
+	w.mx.Unlock()
+	return w.emitter.Close()
+}
+
+// Tightened returns true if the limits are currently tightened.
+func (w *Watchdog) Tightened() bool {
+	w.mx.Lock()
+	defer w.mx.Unlock()
+	return w.original != nil
+}
+
+func (w *Watchdog) background() {
+	defer w.refCount.Done()
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		w.check()
+		select {
+		case <-ticker.C:
+		case <-w.ctx.Done():
+			return
+		}
+	}
+}
+
+func (w *Watchdog) sampleProcess() usage {
+	u := usage{MemoryLimit: w.memoryLimit}
+	u.FDs, u.FDLimit = fdUsage()
+	if u.MemoryLimit > 0 {
+		u.Memory = memoryUsage()
+	}
+	return u
+}
+
+func (w *Watchdog) check() {
+	u := w.sample()
+	p := u.pressure()
+
+	w.mx.Lock()
+	defer w.mx.Unlock()
+	switch {
+	case w.original == nil && p >= w.high:
+		original, err := w.systemLimit()
+		if err != nil {
+			log.Debugw("cannot tighten resource manager limits", "error", err)
+			return
+		}
+		log.Infow("running out of resources, tightening resource manager limits",
+			"fds", u.FDs, "fd_limit", u.FDLimit, "memory", u.Memory, "memory_limit", u.MemoryLimit)
+		w.original = original
+		w.setSystemLimit(scaleLimit(original, w.factor))
+		if w.connmgr != nil {
+			w.refCount.Add(1)
+			go func() {
+				defer w.refCount.Done()
+				w.connmgr.TrimOpenConns(w.ctx)
+			}()
+		}
+		w.emit(true, u)
+	case w.original != nil && p <= w.low:
+		log.Infow("resource pressure subsided, restoring resource manager limits",
+			"fds", u.FDs, "fd_limit", u.FDLimit, "memory", u.Memory, "memory_limit", u.MemoryLimit)
+		w.setSystemLimit(w.original)
+		w.original = nil
+		w.emit(false, u)
+	}
+}
+
+func (w *Watchdog) emit(tightened bool, u usage) {
+	w.emitter.Emit(event.EvtResourceLimitsAdjusted{
+		Tightened:   tightened,
+		FDs:         u.FDs,
+		FDLimit:     u.FDLimit,
+		Memory:      u.Memory,
+		MemoryLimit: u.MemoryLimit,
+	})
+}
+
+var errNoLimiter = errors.New("the resource manager doesn't allow changing its limits")
+
+func (w *Watchdog) systemLimit() (rcmgr.Limit, error) {
+	var limit rcmgr.Limit
+	err := w.rcmgr.ViewSystem(func(s network.ResourceScope) error {
+		l, ok := s.(rcmgr.ResourceScopeLimiter)
+		if !ok {
+			return errNoLimiter
+		}
+		limit = l.Limit()
+		return nil
+	})
+	return limit, err
+}
+
+func (w *Watchdog) setSystemLimit(limit rcmgr.Limit) {
+	w.rcmgr.ViewSystem(func(s network.ResourceScope) error {
+		if l, ok := s.(rcmgr.ResourceScopeLimiter); ok {
+			l.SetLimit(limit)
+		}
+		return nil
+	})
+}
+
+// scaleLimit applies factor f to all limits. Unlimited and blocked limits are left unchanged.
+func scaleLimit(l rcmgr.Limit, f float64) rcmgr.BaseLimit {
+	return rcmgr.BaseLimit{
+		Streams:         scale(l.GetStreamTotalLimit(), f),
+		StreamsInbound:  scale(l.GetStreamLimit(network.DirInbound), f),
+		StreamsOutbound: scale(l.GetStreamLimit(network.DirOutbound), f),
+		Conns:           scale(l.GetConnTotalLimit(), f),
+		ConnsInbound:    scale(l.GetConnLimit(network.DirInbound), f),
+		ConnsOutbound:   scale(l.GetConnLimit(network.DirOutbound), f),
+		FD:              scale(l.GetFDLimit(), f),
+		Memory:          scale64(l.GetMemoryLimit(), f),
+	}
+}
+
+func scale(n int, f float64) int {
+	if n <= 0 || n == math.MaxInt {
+		return n
+	}
+	if s := int(float64(n) * f); s > 0 {
+		return s
+	}
+	return 1
+}
+
+func scale64(n int64, f float64) int64 {
+	if n <= 0 || n == math.MaxInt64 {
+		return n
+	}
+	if s := int64(float64(n) * f); s > 0 {
+		return s
+	}
+	return 1
+}
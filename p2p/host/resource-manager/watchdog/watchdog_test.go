@@ -0,0 +1,135 @@
+package watchdog
+
+import (
+	"math"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/AstaFrode/go-libp2p/core/event"
+	"github.com/AstaFrode/go-libp2p/core/network"
+	"github.com/AstaFrode/go-libp2p/p2p/host/eventbus"
+	rcmgr "github.com/AstaFrode/go-libp2p/p2p/host/resource-manager"
+
+	"github.com/stretchr/testify/require"
+)
+
+func systemLimit(t *testing.T, mgr network.ResourceManager) rcmgr.Limit {
+	t.Helper()
+	var limit rcmgr.Limit
+	require.NoError(t, mgr.ViewSystem(func(s network.ResourceScope) error {
+		limit = s.(rcmgr.ResourceScopeLimiter).Limit()
+		return nil
+	}))
+	return limit
+}
+
+func nextEvent(t *testing.T, sub event.Subscription) event.EvtResourceLimitsAdjusted {
+	t.Helper()
+	select {
+	case e := <-sub.Out():
+		return e.(event.EvtResourceLimitsAdjusted)
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected an event")
+	}
+	return event.EvtResourceLimitsAdjusted{}
+}
+
+func TestWatchdog(t *testing.T) {
+	limits := rcmgr.DefaultLimits.AutoScale()
+	mgr, err := rcmgr.NewResourceManager(rcmgr.NewFixedLimiter(limits))
+	require.NoError(t, err)
+	defer mgr.Close()
+	original := systemLimit(t, mgr)
+
+	bus := eventbus.NewBus()
+	sub, err := bus.Subscribe(new(event.EvtResourceLimitsAdjusted))
+	require.NoError(t, err)
+	defer sub.Close()
+
+	w, err := NewWatchdog(mgr, bus, WithInterval(10*time.Millisecond))
+	require.NoError(t, err)
+	var fds atomic.Int64
+	fds.Store(100)
+	w.sample = func() usage { return usage{FDs: int(fds.Load()), FDLimit: 1000} }
+	w.Start()
+	defer w.Close()
+
+	time.Sleep(50 * time.Millisecond)
+	require.False(t, w.Tightened())
+
+	// approaching the fd limit
+	fds.Store(950)
+	e := nextEvent(t, sub)
+	require.True(t, e.Tightened)
+	require.Equal(t, 950, e.FDs)
+	require.Equal(t, 1000, e.FDLimit)
+	require.True(t, w.Tightened())
+	tightened := systemLimit(t, mgr)
+	require.Equal(t, original.GetConnTotalLimit()/2, tightened.GetConnTotalLimit())
+	require.Equal(t, original.GetFDLimit()/2, tightened.GetFDLimit())
+	require.Equal(t, original.GetMemoryLimit()/2, tightened.GetMemoryLimit())
+
+	// between the watermarks, nothing changes
+	fds.Store(800)
+	time.Sleep(50 * time.Millisecond)
+	require.True(t, w.Tightened())
+
+	// the pressure subsided
+	fds.Store(500)
+	e = nextEvent(t, sub)
+	require.False(t, e.Tightened)
+	require.False(t, w.Tightened())
+	require.Equal(t, original, systemLimit(t, mgr))
+}
+
+func TestWatchdogRestoresLimitsOnClose(t *testing.T) {
+	mgr, err := rcmgr.NewResourceManager(rcmgr.NewFixedLimiter(rcmgr.DefaultLimits.AutoScale()))
+	require.NoError(t, err)
+	defer mgr.Close()
+	original := systemLimit(t, mgr)
+
+	w, err := NewWatchdog(mgr, eventbus.NewBus(), WithInterval(10*time.Millisecond), WithMemoryLimit(1000))
+	require.NoError(t, err)
+	w.sample = func() usage { return usage{Memory: 1000, MemoryLimit: 1000} }
+	w.Start()
+	require.Eventually(t, w.Tightened, 5*time.Second, 10*time.Millisecond)
+	require.NotEqual(t, original, systemLimit(t, mgr))
+
+	require.NoError(t, w.Close())
+	require.Equal(t, original, systemLimit(t, mgr))
+}
+
+func TestWatchdogWithoutLimiter(t *testing.T) {
+	w, err := NewWatchdog(&network.NullResourceManager{}, eventbus.NewBus(), WithInterval(10*time.Millisecond))
+	require.NoError(t, err)
+	var samples atomic.Int64
+	w.sample = func() usage {
+		samples.Add(1)
+		return usage{}
+	}
+	w.Start()
+	time.Sleep(50 * time.Millisecond)
+	require.NoError(t, w.Close())
+	require.Zero(t, samples.Load())
+}
+
+func TestScaleLimit(t *testing.T) {
+	l := rcmgr.BaseLimit{Streams: 100, StreamsInbound: 1, Conns: 0, ConnsInbound: math.MaxInt, FD: 10}
+	s := scaleLimit(l, 0.5)
+	require.Equal(t, 50, s.Streams)
+	require.Equal(t, 1, s.StreamsInbound)
+	require.Equal(t, 0, s.Conns)
+	require.Equal(t, l.ConnsInbound, s.ConnsInbound)
+	require.Equal(t, 5, s.FD)
+}
+
+func TestInvalidOptions(t *testing.T) {
+	bus := eventbus.NewBus()
+	_, err := NewWatchdog(&network.NullResourceManager{}, bus, WithWatermarks(0.9, 0.7))
+	require.Error(t, err)
+	_, err = NewWatchdog(&network.NullResourceManager{}, bus, WithTightenFactor(1))
+	require.Error(t, err)
+	_, err = NewWatchdog(&network.NullResourceManager{}, bus, WithInterval(0))
+	require.Error(t, err)
+}
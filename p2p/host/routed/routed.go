@@ -46,9 +46,10 @@ func Wrap(h host.Host, r Routing) *RoutedHost {
 // RoutedHost's Connect differs in that if the host has no addresses for a
 // given peer, it will use its routing system to try to find some.
 func (rh *RoutedHost) Connect(ctx context.Context, pi peer.AddrInfo) error {
-	// first, check if we're already connected unless force direct dial.
+	// first, check if we're already connected, unless the dial options require a new connection.
 	forceDirect, _ := network.GetForceDirectDial(ctx)
-	if !forceDirect {
+	forceRelay, _ := network.GetForceRelayDial(ctx)
+	if !forceDirect && !forceRelay && network.GetDialTransports(ctx) == nil {
 		if rh.Network().Connectedness(pi.ID) == network.Connected {
 			return nil
 		}
@@ -0,0 +1,126 @@
+package keystore
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	envelopeVersion = 1
+
+	kdfArgon2id = "argon2id"
+	kdfScrypt   = "scrypt"
+
+	saltSize = 16
+	keySize  = 32 // AES-256
+)
+
+var defaultKDF = kdfParams{Name: kdfArgon2id, Time: 3, Memory: 64 * 1024, Threads: 4}
+
+// kdfParams are the parameters of the key derivation function.
+// Only the fields of the used KDF are set.
+type kdfParams struct {
+	Name string `json:"name"`
+
+	// argon2id
+	Time    uint32 `json:"time,omitempty"`
+	Memory  uint32 `json:"memory,omitempty"`
+	Threads uint8  `json:"threads,omitempty"`
+
+	// scrypt
+	N int `json:"n,omitempty"`
+	R int `json:"r,omitempty"`
+	P int `json:"p,omitempty"`
+}
+
+// envelope is the format of a key file.
+type envelope struct {
+	Version    int       `json:"version"`
+	KDF        kdfParams `json:"kdf"`
+	Salt       []byte    `json:"salt"`
+	Nonce      []byte    `json:"nonce"`
+	Ciphertext []byte    `json:"ciphertext"`
+}
+
+func deriveKey(passphrase, salt []byte, params kdfParams) ([]byte, error) {
+	switch params.Name {
+	case kdfArgon2id:
+		if params.Time == 0 || params.Memory == 0 || params.Threads == 0 {
+			return nil, errors.New("invalid argon2id parameters")
+		}
+		return argon2.IDKey(passphrase, salt, params.Time, params.Memory, params.Threads, keySize), nil
+	case kdfScrypt:
+		return scrypt.Key(passphrase, salt, params.N, params.R, params.P, keySize)
+	default:
+		return nil, fmt.Errorf("unknown key derivation function: %q", params.Name)
+	}
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encrypt encrypts plaintext with a key derived from passphrase.
+// The additional data is authenticated, but not included in the envelope.
+func encrypt(plaintext, passphrase, additionalData []byte, params kdfParams) ([]byte, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	key, err := deriveKey(passphrase, salt, params)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return json.Marshal(envelope{
+		Version:    envelopeVersion,
+		KDF:        params,
+		Salt:       salt,
+		Nonce:      nonce,
+		Ciphertext: aead.Seal(nil, nonce, plaintext, additionalData),
+	})
+}
+
+func decrypt(data, passphrase, additionalData []byte) ([]byte, error) {
+	var env envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return nil, fmt.Errorf("failed to parse key file: %w", err)
+	}
+	if env.Version != envelopeVersion {
+		return nil, fmt.Errorf("unsupported key file version: %d", env.Version)
+	}
+	key, err := deriveKey(passphrase, env.Salt, env.KDF)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(env.Nonce) != aead.NonceSize() {
+		return nil, errors.New("invalid nonce size")
+	}
+	plaintext, err := aead.Open(nil, env.Nonce, env.Ciphertext, additionalData)
+	if err != nil {
+		return nil, ErrInvalidPassphrase
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,291 @@
+// Package keystore stores private keys encrypted at rest.
+//
+// Every key is stored in its own file in the keystore directory, encrypted with
+// AES-256-GCM. The encryption key is derived from a passphrase using argon2id
+// (the default) or scrypt. The KDF parameters are stored alongside the key, so
+// keys remain readable when the defaults change.
+//
+// The host's identity key is conventionally stored under the name HostKeyName.
+// Use libp2p.IdentityFromKeystore to load it when constructing a host.
+package keystore
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/AstaFrode/go-libp2p/core/crypto"
+)
+
+const (
+	// HostKeyName is the name under which the host's identity key is stored.
+	HostKeyName = "self"
+
+	// PreviousKeySuffix is appended to the name of a key when it is replaced by Rotate.
+	PreviousKeySuffix = ".previous"
+
+	keyFileExtension = ".key"
+)
+
+var (
+	// ErrNoSuchKey is returned when a key doesn't exist in the keystore.
+	ErrNoSuchKey = errors.New("no key by the given name was found")
+	// ErrKeyExists is returned when attempting to overwrite a key.
+	ErrKeyExists = errors.New("key by that name already exists, refusing to overwrite")
+	// ErrInvalidPassphrase is returned when a key can't be decrypted with the passphrase.
+	ErrInvalidPassphrase = errors.New("invalid passphrase, or the key file was modified")
+	// ErrInvalidName is returned for key names that can't be used as file names.
+	ErrInvalidName = errors.New("invalid key name: only letters, digits, '.', '-' and '_' are allowed, and it must not start with a '.'")
+)
+
+var validName = regexp.MustCompile(`^[a-zA-Z0-9_-][a-zA-Z0-9._-]*$`)
+
+type Option func(*Keystore) error
+
+// WithArgon2id derives the encryption keys of newly written keys with argon2id,
+// using the given number of iterations, memory in KiB, and parallelism.
+// This is the default, with time=3, memory=64 MiB, threads=4.
+func WithArgon2id(time, memory uint32, threads uint8) Option {
+	return func(ks *Keystore) error {
+		if time == 0 || memory == 0 || threads == 0 {
+			return errors.New("invalid argon2id parameters")
+		}
+		ks.kdf = kdfParams{Name: kdfArgon2id, Time: time, Memory: memory, Threads: threads}
+		return nil
+	}
+}
+
+// WithScrypt derives the encryption keys of newly written keys with scrypt,
+// using the given cost parameters.
+func WithScrypt(n, r, p int) Option {
+	return func(ks *Keystore) error {
+		if n <= 1 || n&(n-1) != 0 || r <= 0 || p <= 0 {
+			return errors.New("invalid scrypt parameters")
+		}
+		ks.kdf = kdfParams{Name: kdfScrypt, N: n, R: r, P: p}
+		return nil
+	}
+}
+
+// Keystore is a directory of encrypted keys.
+type Keystore struct {
+	dir        string
+	passphrase []byte
+	kdf        kdfParams
+
+	mx sync.Mutex
+}
+
+// Open opens the keystore in dir, creating the directory if it doesn't exist.
+// All keys are encrypted with a key derived from passphrase.
+func Open(dir string, passphrase []byte, opts ...Option) (*Keystore, error) {
+	if len(passphrase) == 0 {
+		return nil, errors.New("empty passphrase")
+	}
+	ks := &Keystore{
+		dir:        dir,
+		passphrase: append([]byte(nil), passphrase...),
+		kdf:        defaultKDF,
+	}
+	for _, opt := range opts {
+		if err := opt(ks); err != nil {
+			return nil, err
+		}
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create keystore directory: %w", err)
+	}
+	return ks, nil
+}
+
+// Has returns whether or not a key exists in the keystore.
+func (ks *Keystore) Has(name string) (bool, error) {
+	path, err := ks.path(name)
+	if err != nil {
+		return false, err
+	}
+	if _, err := os.Stat(path); err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// Put stores a key in the keystore. If a key with the same name already exists,
+// ErrKeyExists is returned.
+func (ks *Keystore) Put(name string, sk crypto.PrivKey) error {
+	ks.mx.Lock()
+	defer ks.mx.Unlock()
+
+	if ok, err := ks.Has(name); err != nil {
+		return err
+	} else if ok {
+		return ErrKeyExists
+	}
+	return ks.write(name, sk, ks.passphrase)
+}
+
+// Get retrieves a key from the keystore.
+func (ks *Keystore) Get(name string) (crypto.PrivKey, error) {
+	ks.mx.Lock()
+	defer ks.mx.Unlock()
+	return ks.read(name, ks.passphrase)
+}
+
+// Delete removes a key from the keystore.
+func (ks *Keystore) Delete(name string) error {
+	path, err := ks.path(name)
+	if err != nil {
+		return err
+	}
+
+	ks.mx.Lock()
+	defer ks.mx.Unlock()
+	if err := os.Remove(path); err != nil {
+		if os.IsNotExist(err) {
+			return ErrNoSuchKey
+		}
+		return err
+	}
+	return nil
+}
+
+// List returns the names of all keys in the keystore, in lexical order.
+// This includes keys replaced by Rotate.
+func (ks *Keystore) List() ([]string, error) {
+	entries, err := os.ReadDir(ks.dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, keyFileExtension) {
+			continue
+		}
+		name = strings.TrimSuffix(name, keyFileExtension)
+		if validName.MatchString(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Rotate replaces the key stored under name with sk. The replaced key is kept
+// under name + PreviousKeySuffix, overwriting the key previously stored there,
+// so that it's still available while references to it are migrated.
+func (ks *Keystore) Rotate(name string, sk crypto.PrivKey) error {
+	ks.mx.Lock()
+	defer ks.mx.Unlock()
+
+	old, err := ks.read(name, ks.passphrase)
+	if err != nil {
+		return err
+	}
+	if err := ks.write(name+PreviousKeySuffix, old, ks.passphrase); err != nil {
+		return fmt.Errorf("failed to keep the previous key: %w", err)
+	}
+	return ks.write(name, sk, ks.passphrase)
+}
+
+// ChangePassphrase re-encrypts all keys in the keystore with a new passphrase.
+// If it fails, some keys might already be encrypted with the new passphrase.
+func (ks *Keystore) ChangePassphrase(passphrase []byte) error {
+	if len(passphrase) == 0 {
+		return errors.New("empty passphrase")
+	}
+	names, err := ks.List()
+	if err != nil {
+		return err
+	}
+
+	ks.mx.Lock()
+	defer ks.mx.Unlock()
+
+	// decrypt all keys first, so we don't end up with a mix of passphrases
+	// if one of the keys can't be decrypted
+	keys := make([]crypto.PrivKey, 0, len(names))
+	for _, name := range names {
+		sk, err := ks.read(name, ks.passphrase)
+		if err != nil {
+			return fmt.Errorf("failed to read key %s: %w", name, err)
+		}
+		keys = append(keys, sk)
+	}
+	for i, name := range names {
+		if err := ks.write(name, keys[i], passphrase); err != nil {
+			return fmt.Errorf("failed to write key %s: %w", name, err)
+		}
+	}
+	ks.passphrase = append([]byte(nil), passphrase...)
+	return nil
+}
+
+func (ks *Keystore) path(name string) (string, error) {
+	if !validName.MatchString(name) {
+		return "", ErrInvalidName
+	}
+	return filepath.Join(ks.dir, name+keyFileExtension), nil
+}
+
+func (ks *Keystore) read(name string, passphrase []byte) (crypto.PrivKey, error) {
+	path, err := ks.path(name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNoSuchKey
+		}
+		return nil, err
+	}
+	b, err := decrypt(data, passphrase, []byte(name))
+	if err != nil {
+		return nil, err
+	}
+	return crypto.UnmarshalPrivateKey(b)
+}
+
+// write atomically writes the encrypted key to its file.
+func (ks *Keystore) write(name string, sk crypto.PrivKey, passphrase []byte) error {
+	path, err := ks.path(name)
+	if err != nil {
+		return err
+	}
+	b, err := crypto.MarshalPrivateKey(sk)
+	if err != nil {
+		return err
+	}
+	data, err := encrypt(b, passphrase, []byte(name), ks.kdf)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(ks.dir, "."+name+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	defer os.Remove(tmp)
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,141 @@
+package keystore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/AstaFrode/go-libp2p/core/crypto"
+
+	"github.com/stretchr/testify/require"
+)
+
+// use cheap parameters, to keep the tests fast
+var testKDF = WithArgon2id(1, 64, 1)
+
+func newKey(t *testing.T) crypto.PrivKey {
+	t.Helper()
+	sk, _, err := crypto.GenerateEd25519Key(nil)
+	require.NoError(t, err)
+	return sk
+}
+
+func TestKeystore(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "keystore")
+	ks, err := Open(dir, []byte("secret"), testKDF)
+	require.NoError(t, err)
+
+	_, err = ks.Get("foo")
+	require.ErrorIs(t, err, ErrNoSuchKey)
+
+	sk := newKey(t)
+	require.NoError(t, ks.Put("foo", sk))
+	require.ErrorIs(t, ks.Put("foo", newKey(t)), ErrKeyExists)
+	ok, err := ks.Has("foo")
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	got, err := ks.Get("foo")
+	require.NoError(t, err)
+	require.True(t, sk.Equals(got))
+
+	// the key is not stored in plaintext
+	data, err := os.ReadFile(filepath.Join(dir, "foo.key"))
+	require.NoError(t, err)
+	raw, err := sk.Raw()
+	require.NoError(t, err)
+	require.NotContains(t, string(data), string(raw))
+	fi, err := os.Stat(filepath.Join(dir, "foo.key"))
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+
+	require.NoError(t, ks.Put("bar", newKey(t)))
+	names, err := ks.List()
+	require.NoError(t, err)
+	require.Equal(t, []string{"bar", "foo"}, names)
+
+	require.NoError(t, ks.Delete("bar"))
+	require.ErrorIs(t, ks.Delete("bar"), ErrNoSuchKey)
+
+	for _, name := range []string{"", ".hidden", "../escape", "a/b"} {
+		require.ErrorIs(t, ks.Put(name, sk), ErrInvalidName, name)
+	}
+}
+
+func TestWrongPassphrase(t *testing.T) {
+	dir := t.TempDir()
+	ks, err := Open(dir, []byte("secret"), testKDF)
+	require.NoError(t, err)
+	require.NoError(t, ks.Put("foo", newKey(t)))
+
+	ks, err = Open(dir, []byte("wrong"))
+	require.NoError(t, err)
+	_, err = ks.Get("foo")
+	require.ErrorIs(t, err, ErrInvalidPassphrase)
+}
+
+func TestRenamedKeyFile(t *testing.T) {
+	dir := t.TempDir()
+	ks, err := Open(dir, []byte("secret"), testKDF)
+	require.NoError(t, err)
+	require.NoError(t, ks.Put("foo", newKey(t)))
+
+	// the name is authenticated, so a key can't be swapped for another one
+	require.NoError(t, os.Rename(filepath.Join(dir, "foo.key"), filepath.Join(dir, "bar.key")))
+	_, err = ks.Get("bar")
+	require.ErrorIs(t, err, ErrInvalidPassphrase)
+}
+
+func TestScrypt(t *testing.T) {
+	dir := t.TempDir()
+	ks, err := Open(dir, []byte("secret"), WithScrypt(1<<10, 8, 1))
+	require.NoError(t, err)
+	sk := newKey(t)
+	require.NoError(t, ks.Put("foo", sk))
+
+	// the KDF parameters are read from the key file
+	ks, err = Open(dir, []byte("secret"), testKDF)
+	require.NoError(t, err)
+	got, err := ks.Get("foo")
+	require.NoError(t, err)
+	require.True(t, sk.Equals(got))
+
+	_, err = Open(dir, []byte("secret"), WithScrypt(1000, 8, 1))
+	require.Error(t, err)
+}
+
+func TestRotate(t *testing.T) {
+	ks, err := Open(t.TempDir(), []byte("secret"), testKDF)
+	require.NoError(t, err)
+	require.ErrorIs(t, ks.Rotate(HostKeyName, newKey(t)), ErrNoSuchKey)
+
+	old, next := newKey(t), newKey(t)
+	require.NoError(t, ks.Put(HostKeyName, old))
+	require.NoError(t, ks.Rotate(HostKeyName, next))
+
+	got, err := ks.Get(HostKeyName)
+	require.NoError(t, err)
+	require.True(t, next.Equals(got))
+	got, err = ks.Get(HostKeyName + PreviousKeySuffix)
+	require.NoError(t, err)
+	require.True(t, old.Equals(got))
+}
+
+func TestChangePassphrase(t *testing.T) {
+	dir := t.TempDir()
+	ks, err := Open(dir, []byte("secret"), testKDF)
+	require.NoError(t, err)
+	sk := newKey(t)
+	require.NoError(t, ks.Put("foo", sk))
+	require.NoError(t, ks.Put("bar", newKey(t)))
+
+	require.NoError(t, ks.ChangePassphrase([]byte("new secret")))
+	got, err := ks.Get("foo")
+	require.NoError(t, err)
+	require.True(t, sk.Equals(got))
+
+	ks, err = Open(dir, []byte("secret"))
+	require.NoError(t, err)
+	_, err = ks.Get("foo")
+	require.ErrorIs(t, err, ErrInvalidPassphrase)
+}
@@ -0,0 +1,96 @@
+package raw
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"sync"
+
+	"github.com/AstaFrode/go-libp2p/core/network"
+)
+
+var (
+	// ErrStreamOpened is returned when trying to open a second stream on a connection.
+	ErrStreamOpened = errors.New("raw connection already carries a stream")
+	// ErrListenerCantOpen is returned when the listening side tries to open a stream.
+	ErrListenerCantOpen = errors.New("only the dialer can open the stream of a raw connection")
+	errConnClosed       = errors.New("raw connection closed")
+)
+
+type conn struct {
+	nc       net.Conn
+	br       *bufio.Reader
+	isServer bool
+
+	mx         sync.Mutex
+	hasStream  bool
+	closeOnce  sync.Once
+	closed     chan struct{}
+	closeError error
+}
+
+var _ network.MuxedConn = &conn{}
+
+func (c *conn) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.closed)
+		c.closeError = c.nc.Close()
+	})
+	return c.closeError
+}
+
+func (c *conn) IsClosed() bool {
+	select {
+	case <-c.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+// OpenStream returns the stream of the connection. It can only be called once, by
+// the dialer.
+func (c *conn) OpenStream(ctx context.Context) (network.MuxedStream, error) {
+	if c.isServer {
+		return nil, ErrListenerCantOpen
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.takeStream()
+}
+
+// AcceptStream returns the stream of the connection on the listening side, as soon as
+// the dialer starts using it. Otherwise, or once the stream was accepted, it blocks
+// until the connection is closed.
+func (c *conn) AcceptStream() (network.MuxedStream, error) {
+	c.mx.Lock()
+	hasStream := c.hasStream
+	c.mx.Unlock()
+	if !c.isServer || hasStream {
+		<-c.closed
+		return nil, errConnClosed
+	}
+
+	// Wait for the dialer to send data, so that the stream isn't handled (and its
+	// protocol negotiation doesn't time out) before the dialer opens it.
+	if _, err := c.br.Peek(1); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c.takeStream()
+}
+
+func (c *conn) takeStream() (network.MuxedStream, error) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	if c.IsClosed() {
+		return nil, errConnClosed
+	}
+	if c.hasStream {
+		return nil, ErrStreamOpened
+	}
+	c.hasStream = true
+	return &stream{conn: c}, nil
+}
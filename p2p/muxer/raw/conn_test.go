@@ -0,0 +1,181 @@
+package raw
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/AstaFrode/go-libp2p/core/network"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newConnPair(t *testing.T) (client, server network.MuxedConn) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			close(accepted)
+			return
+		}
+		accepted <- c
+	}()
+	cc, err := net.Dial("tcp", ln.Addr().String())
+	require.NoError(t, err)
+	sc, ok := <-accepted
+	require.True(t, ok)
+
+	client, err = DefaultTransport.NewConn(cc, false, nil)
+	require.NoError(t, err)
+	server, err = DefaultTransport.NewConn(sc, true, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+	return client, server
+}
+
+// openStreamPair opens the stream on the client, and accepts it on the server.
+func openStreamPair(t *testing.T) (client, server network.MuxedStream, cconn, sconn network.MuxedConn) {
+	t.Helper()
+	cconn, sconn = newConnPair(t)
+	client, err := cconn.OpenStream(context.Background())
+	require.NoError(t, err)
+	// the server only accepts the stream once the client sends data
+	_, err = client.Write([]byte("hello"))
+	require.NoError(t, err)
+	server, err = sconn.AcceptStream()
+	require.NoError(t, err)
+	b := make([]byte, 5)
+	_, err = io.ReadFull(server, b)
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(b))
+	return client, server, cconn, sconn
+}
+
+func TestOpenAccept(t *testing.T) {
+	client, server, _, _ := openStreamPair(t)
+
+	_, err := server.Write([]byte("world"))
+	require.NoError(t, err)
+	b := make([]byte, 5)
+	_, err = io.ReadFull(client, b)
+	require.NoError(t, err)
+	require.Equal(t, "world", string(b))
+}
+
+func TestSingleStream(t *testing.T) {
+	_, _, cconn, sconn := openStreamPair(t)
+
+	_, err := cconn.OpenStream(context.Background())
+	require.ErrorIs(t, err, ErrStreamOpened)
+	_, err = sconn.OpenStream(context.Background())
+	require.ErrorIs(t, err, ErrListenerCantOpen)
+
+	// further calls to AcceptStream block until the connection is closed
+	errChan := make(chan error, 1)
+	go func() {
+		_, err := sconn.AcceptStream()
+		errChan <- err
+	}()
+	select {
+	case <-errChan:
+		t.Fatal("AcceptStream should block")
+	case <-time.After(50 * time.Millisecond):
+	}
+	require.NoError(t, sconn.Close())
+	select {
+	case err := <-errChan:
+		require.Error(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("AcceptStream should return when the connection is closed")
+	}
+	require.True(t, sconn.IsClosed())
+}
+
+func TestOpenStreamCanceled(t *testing.T) {
+	cconn, _ := newConnPair(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := cconn.OpenStream(ctx)
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestHalfClose(t *testing.T) {
+	client, server, _, _ := openStreamPair(t)
+
+	require.NoError(t, client.CloseWrite())
+	data, err := io.ReadAll(server)
+	require.NoError(t, err)
+	require.Empty(t, data)
+
+	// the other direction still works
+	_, err = server.Write([]byte("response"))
+	require.NoError(t, err)
+	require.NoError(t, server.CloseWrite())
+	data, err = io.ReadAll(client)
+	require.NoError(t, err)
+	require.Equal(t, "response", string(data))
+}
+
+func TestCloseRead(t *testing.T) {
+	client, server, _, _ := openStreamPair(t)
+
+	errChan := make(chan error, 1)
+	go func() {
+		_, err := server.Read(make([]byte, 1))
+		errChan <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	require.NoError(t, server.CloseRead())
+	select {
+	case err := <-errChan:
+		require.Error(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("CloseRead should interrupt the read")
+	}
+	_, err := server.Read(make([]byte, 1))
+	require.ErrorIs(t, err, errReadClosed)
+
+	// writing still works
+	_, err = server.Write([]byte("foo"))
+	require.NoError(t, err)
+	b := make([]byte, 3)
+	_, err = io.ReadFull(client, b)
+	require.NoError(t, err)
+}
+
+func TestReset(t *testing.T) {
+	client, server, cconn, _ := openStreamPair(t)
+
+	require.NoError(t, client.Reset())
+	require.True(t, cconn.IsClosed())
+	_, err := client.Write([]byte("foo"))
+	require.Error(t, err)
+	_, err = io.ReadAll(server)
+	// the remote peer sees the connection being closed
+	require.NoError(t, err)
+}
+
+func TestDeadlines(t *testing.T) {
+	client, _, _, _ := openStreamPair(t)
+
+	require.NoError(t, client.SetReadDeadline(time.Now().Add(10*time.Millisecond)))
+	_, err := client.Read(make([]byte, 1))
+	require.True(t, errors.Is(err, os.ErrDeadlineExceeded), "expected a deadline error, got %v", err)
+
+	require.NoError(t, client.SetDeadline(time.Time{}))
+	require.NoError(t, client.SetWriteDeadline(time.Now().Add(-time.Second)))
+	_, err = client.Write([]byte("foo"))
+	require.True(t, errors.Is(err, os.ErrDeadlineExceeded), "expected a deadline error, got %v", err)
+}
@@ -0,0 +1,67 @@
+package raw
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/AstaFrode/go-libp2p/core/network"
+)
+
+var errReadClosed = errors.New("raw stream closed for reading")
+
+// stream is the single stream of a raw connection.
+type stream struct {
+	conn       *conn
+	readClosed atomic.Bool
+}
+
+var _ network.MuxedStream = &stream{}
+
+func (s *stream) Read(b []byte) (int, error) {
+	if s.readClosed.Load() {
+		return 0, errReadClosed
+	}
+	return s.conn.br.Read(b)
+}
+
+func (s *stream) Write(b []byte) (int, error) {
+	return s.conn.nc.Write(b)
+}
+
+// Close closes the stream, and with it the connection.
+func (s *stream) Close() error {
+	return s.conn.Close()
+}
+
+// CloseWrite closes the connection for writing, if the security protocol supports it.
+func (s *stream) CloseWrite() error {
+	if cw, ok := s.conn.nc.(interface{ CloseWrite() error }); ok {
+		return cw.CloseWrite()
+	}
+	return errors.New("the secure connection doesn't support closing for writing")
+}
+
+// CloseRead closes the stream for reading, interrupting in-progress reads.
+func (s *stream) CloseRead() error {
+	s.readClosed.Store(true)
+	return s.conn.nc.SetReadDeadline(time.Now())
+}
+
+// Reset closes the connection. The remote peer's reads fail with a connection error
+// instead of network.ErrReset.
+func (s *stream) Reset() error {
+	return s.conn.Close()
+}
+
+func (s *stream) SetDeadline(t time.Time) error {
+	return s.conn.nc.SetDeadline(t)
+}
+
+func (s *stream) SetReadDeadline(t time.Time) error {
+	return s.conn.nc.SetReadDeadline(t)
+}
+
+func (s *stream) SetWriteDeadline(t time.Time) error {
+	return s.conn.nc.SetWriteDeadline(t)
+}
@@ -0,0 +1,39 @@
+// Package raw implements an experimental stream "multiplexer" that doesn't multiplex:
+// the secured connection carries a single stream, opened by the dialer.
+//
+// It saves the memory used by a real stream multiplexer and, the stream not being
+// negotiated separately, a round trip. It's meant for constrained devices that run a
+// single protocol. As the muxer is negotiated like any other, it is only used between
+// hosts that both enabled it.
+//
+// Closing or resetting the stream closes the connection. Since the connection can't
+// carry another stream, hosts using this muxer must not run services that open streams
+// on their own, like identify. The libp2p.EnableRawStreamMuxer option enables the muxer
+// and takes care of that; using the muxer without it is an error.
+package raw
+
+import (
+	"bufio"
+	"net"
+
+	"github.com/AstaFrode/go-libp2p/core/network"
+)
+
+const ID = "/x/raw-stream/1.0.0"
+
+var DefaultTransport = &Transport{}
+
+// Transport implements network.Multiplexer, constructing connections that carry a
+// single stream.
+type Transport struct{}
+
+var _ network.Multiplexer = &Transport{}
+
+func (t *Transport) NewConn(nc net.Conn, isServer bool, scope network.PeerScope) (network.MuxedConn, error) {
+	return &conn{
+		nc:       nc,
+		br:       bufio.NewReader(nc),
+		isServer: isServer,
+		closed:   make(chan struct{}),
+	}, nil
+}
@@ -0,0 +1,185 @@
+package conngater
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+
+	"github.com/AstaFrode/go-libp2p/core/connmgr"
+	"github.com/AstaFrode/go-libp2p/core/control"
+	"github.com/AstaFrode/go-libp2p/core/network"
+	"github.com/AstaFrode/go-libp2p/core/peer"
+
+	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+// AddressFilters filters the addresses we dial and accept connections from by IP range.
+// An address is blocked if it is contained in a denied subnet, or if there are allowed
+// subnets and it is not contained in any of them. Addresses that don't contain an IP
+// address (e.g. DNS addresses before resolution) are never blocked.
+//
+// The subnets can be updated at any time, e.g. from a threat feed. Updates apply to new
+// connections only: existing connections are not closed.
+type AddressFilters struct {
+	mx      sync.RWMutex
+	allowed []*net.IPNet
+	denied  []*net.IPNet
+
+	blockedDials   atomic.Uint64
+	blockedAccepts atomic.Uint64
+}
+
+// AddressFiltersStats are the number of connection attempts blocked by the AddressFilters.
+type AddressFiltersStats struct {
+	// BlockedDials is the number of addresses we didn't dial.
+	BlockedDials uint64
+	// BlockedAccepts is the number of inbound connections we rejected.
+	BlockedAccepts uint64
+}
+
+// NewAddressFilters creates new AddressFilters, allowing and denying the given subnets.
+func NewAddressFilters(allowed, denied []*net.IPNet) *AddressFilters {
+	f := &AddressFilters{}
+	f.SetAllowed(allowed)
+	f.SetDenied(denied)
+	return f
+}
+
+// SetAllowed replaces the allowed subnets. If empty, all addresses that are not denied are allowed.
+func (f *AddressFilters) SetAllowed(subnets []*net.IPNet) {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	f.allowed = append([]*net.IPNet(nil), subnets...)
+}
+
+// SetDenied replaces the denied subnets.
+func (f *AddressFilters) SetDenied(subnets []*net.IPNet) {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	f.denied = append([]*net.IPNet(nil), subnets...)
+}
+
+// Allow adds a subnet to the allowed subnets.
+func (f *AddressFilters) Allow(ipnet *net.IPNet) {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	f.allowed = addSubnet(f.allowed, ipnet)
+}
+
+// Deny adds a subnet to the denied subnets.
+func (f *AddressFilters) Deny(ipnet *net.IPNet) {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	f.denied = addSubnet(f.denied, ipnet)
+}
+
+// RemoveAllowed removes a subnet from the allowed subnets.
+func (f *AddressFilters) RemoveAllowed(ipnet *net.IPNet) {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	f.allowed = removeSubnet(f.allowed, ipnet)
+}
+
+// RemoveDenied removes a subnet from the denied subnets.
+func (f *AddressFilters) RemoveDenied(ipnet *net.IPNet) {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	f.denied = removeSubnet(f.denied, ipnet)
+}
+
+// ListAllowed returns the allowed subnets.
+func (f *AddressFilters) ListAllowed() []*net.IPNet {
+	f.mx.RLock()
+	defer f.mx.RUnlock()
+	return append([]*net.IPNet(nil), f.allowed...)
+}
+
+// ListDenied returns the denied subnets.
+func (f *AddressFilters) ListDenied() []*net.IPNet {
+	f.mx.RLock()
+	defer f.mx.RUnlock()
+	return append([]*net.IPNet(nil), f.denied...)
+}
+
+// AddrBlocked returns true if a is blocked by the filters.
+func (f *AddressFilters) AddrBlocked(a ma.Multiaddr) bool {
+	ip, err := manet.ToIP(a)
+	if err != nil {
+		return false
+	}
+
+	f.mx.RLock()
+	defer f.mx.RUnlock()
+
+	for _, ipnet := range f.denied {
+		if ipnet.Contains(ip) {
+			return true
+		}
+	}
+	if len(f.allowed) == 0 {
+		return false
+	}
+	for _, ipnet := range f.allowed {
+		if ipnet.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// Stats returns the number of connection attempts blocked so far.
+func (f *AddressFilters) Stats() AddressFiltersStats {
+	return AddressFiltersStats{
+		BlockedDials:   f.blockedDials.Load(),
+		BlockedAccepts: f.blockedAccepts.Load(),
+	}
+}
+
+func addSubnet(subnets []*net.IPNet, ipnet *net.IPNet) []*net.IPNet {
+	for _, s := range subnets {
+		if s.String() == ipnet.String() {
+			return subnets
+		}
+	}
+	return append(subnets, ipnet)
+}
+
+func removeSubnet(subnets []*net.IPNet, ipnet *net.IPNet) []*net.IPNet {
+	res := make([]*net.IPNet, 0, len(subnets))
+	for _, s := range subnets {
+		if s.String() != ipnet.String() {
+			res = append(res, s)
+		}
+	}
+	return res
+}
+
+// ConnectionGater interface
+var _ connmgr.ConnectionGater = (*AddressFilters)(nil)
+
+func (f *AddressFilters) InterceptPeerDial(peer.ID) (allow bool) { return true }
+
+func (f *AddressFilters) InterceptAddrDial(_ peer.ID, a ma.Multiaddr) (allow bool) {
+	if f.AddrBlocked(a) {
+		f.blockedDials.Add(1)
+		return false
+	}
+	return true
+}
+
+func (f *AddressFilters) InterceptAccept(cma network.ConnMultiaddrs) (allow bool) {
+	if f.AddrBlocked(cma.RemoteMultiaddr()) {
+		f.blockedAccepts.Add(1)
+		return false
+	}
+	return true
+}
+
+func (f *AddressFilters) InterceptSecured(network.Direction, peer.ID, network.ConnMultiaddrs) (allow bool) {
+	return true
+}
+
+func (f *AddressFilters) InterceptUpgraded(network.Conn) (allow bool, reason control.DisconnectReason) {
+	return true, 0
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/AstaFrode/go-libp2p/core/control"
 	"github.com/AstaFrode/go-libp2p/core/network"
 	"github.com/AstaFrode/go-libp2p/core/peer"
+	"github.com/AstaFrode/go-libp2p/core/peerstore"
 
 	ma "github.com/multiformats/go-multiaddr"
 	manet "github.com/multiformats/go-multiaddr/net"
@@ -28,6 +29,9 @@ type BasicConnectionGater struct {
 	blockedAddrs   map[string]struct{}
 	blockedSubnets map[string]*net.IPNet
 
+	scores   peerstore.ScoreBook
+	minScore float64
+
 	ds datastore.Datastore
 }
 
@@ -271,6 +275,25 @@ func (cg *BasicConnectionGater) ListBlockedSubnets() []*net.IPNet {
 	return result
 }
 
+// BlockPeersBelowScore blocks the peers whose score, as reported by scores, is below minScore.
+// Passing a nil ScoreBook removes the score threshold.
+// Unlike the other rules, the score threshold is not persisted to the datastore.
+func (cg *BasicConnectionGater) BlockPeersBelowScore(scores peerstore.ScoreBook, minScore float64) {
+	cg.Lock()
+	defer cg.Unlock()
+
+	cg.scores = scores
+	cg.minScore = minScore
+}
+
+// isPeerBlocked must be called with the lock held.
+func (cg *BasicConnectionGater) isPeerBlocked(p peer.ID) bool {
+	if _, block := cg.blockedPeers[p]; block {
+		return true
+	}
+	return cg.scores != nil && cg.scores.Score(p) < cg.minScore
+}
+
 // ConnectionGater interface
 var _ connmgr.ConnectionGater = (*BasicConnectionGater)(nil)
 
@@ -278,8 +301,7 @@ func (cg *BasicConnectionGater) InterceptPeerDial(p peer.ID) (allow bool) {
 	cg.RLock()
 	defer cg.RUnlock()
 
-	_, block := cg.blockedPeers[p]
-	return !block
+	return !cg.isPeerBlocked(p)
 }
 
 func (cg *BasicConnectionGater) InterceptAddrDial(p peer.ID, a ma.Multiaddr) (allow bool) {
@@ -343,8 +365,7 @@ func (cg *BasicConnectionGater) InterceptSecured(dir network.Direction, p peer.I
 	cg.RLock()
 	defer cg.RUnlock()
 
-	_, block := cg.blockedPeers[p]
-	return !block
+	return !cg.isPeerBlocked(p)
 }
 
 func (cg *BasicConnectionGater) InterceptUpgraded(network.Conn) (allow bool, reason control.DisconnectReason) {
@@ -6,6 +6,7 @@ import (
 
 	"github.com/AstaFrode/go-libp2p/core/network"
 	"github.com/AstaFrode/go-libp2p/core/peer"
+	"github.com/AstaFrode/go-libp2p/p2p/host/peerstore/pstoremem"
 	"github.com/ipfs/go-datastore"
 
 	ma "github.com/multiformats/go-multiaddr"
@@ -361,6 +362,91 @@ func TestConnectionGater(t *testing.T) {
 	}
 }
 
+func TestConnectionGaterScores(t *testing.T) {
+	scores, err := pstoremem.NewScoreBook()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cg, err := NewBasicConnectionGater(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	peerA := peer.ID("A")
+	peerB := peer.ID("B")
+	scores.RecordObservation(peerA, "test", -2)
+	scores.RecordObservation(peerB, "test", -0.5)
+
+	cg.BlockPeersBelowScore(scores, -1)
+	if cg.InterceptPeerDial(peerA) {
+		t.Fatal("expected gater to deny peerA")
+	}
+	if cg.InterceptSecured(network.DirInbound, peerA, nil) {
+		t.Fatal("expected gater to deny peerA")
+	}
+	if !cg.InterceptPeerDial(peerB) {
+		t.Fatal("expected gater to allow peerB")
+	}
+	if !cg.InterceptSecured(network.DirInbound, peerB, nil) {
+		t.Fatal("expected gater to allow peerB")
+	}
+
+	cg.BlockPeersBelowScore(nil, 0)
+	if !cg.InterceptPeerDial(peerA) {
+		t.Fatal("expected gater to allow peerA")
+	}
+}
+
+func TestAddressFilters(t *testing.T) {
+	_, allowed, err := net.ParseCIDR("10.0.0.0/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, denied, err := net.ParseCIDR("10.1.0.0/16")
+	if err != nil {
+		t.Fatal(err)
+	}
+	peerA := peer.ID("A")
+	addr1 := ma.StringCast("/ip4/1.2.3.4/tcp/1234")
+	addr2 := ma.StringCast("/ip4/10.2.3.4/tcp/1234")
+	addr3 := ma.StringCast("/ip4/10.1.3.4/tcp/1234")
+
+	f := NewAddressFilters(nil, []*net.IPNet{denied})
+	if !f.InterceptAddrDial(peerA, addr1) {
+		t.Fatal("expected gater to allow addr1")
+	}
+	if f.InterceptAddrDial(peerA, addr3) {
+		t.Fatal("expected gater to deny addr3")
+	}
+	if !f.InterceptAddrDial(peerA, ma.StringCast("/dns4/example.com/tcp/1234")) {
+		t.Fatal("expected gater to allow DNS addresses")
+	}
+
+	f.Allow(allowed)
+	if f.InterceptAddrDial(peerA, addr1) {
+		t.Fatal("expected gater to deny addr1")
+	}
+	if !f.InterceptAccept(&mockConnMultiaddrs{remote: addr2}) {
+		t.Fatal("expected gater to accept addr2")
+	}
+	if f.InterceptAccept(&mockConnMultiaddrs{remote: addr3}) {
+		t.Fatal("expected gater to deny addr3")
+	}
+
+	f.RemoveDenied(denied)
+	if !f.InterceptAccept(&mockConnMultiaddrs{remote: addr3}) {
+		t.Fatal("expected gater to accept addr3")
+	}
+	f.SetAllowed(nil)
+	if !f.InterceptAddrDial(peerA, addr1) {
+		t.Fatal("expected gater to allow addr1")
+	}
+
+	if stats := f.Stats(); stats.BlockedDials != 2 || stats.BlockedAccepts != 1 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+}
+
 type mockConnMultiaddrs struct {
 	local, remote ma.Multiaddr
 }
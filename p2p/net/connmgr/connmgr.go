@@ -246,6 +246,7 @@ type peerInfo struct {
 	decaying map[*decayingTag]*connmgr.DecayingValue // decaying tags
 
 	value int  // cached sum of all tag values
+	score int  // weighted peer score, updated before sorting peers
 	temp  bool // this is a temporary entry holding early tags, and awaiting connections
 
 	conns map[network.Conn]time.Time // start time of each connection
@@ -284,8 +285,8 @@ func (p peerInfos) SortByValueAndStreams(segments *segments, sortByMoreStreams b
 			return left.temp
 		}
 		// otherwise, compare by value.
-		if left.value != right.value {
-			return left.value < right.value
+		if lv, rv := left.value+left.score, right.value+right.score; lv != rv {
+			return lv < rv
 		}
 		incomingAndStreams := func(m map[network.Conn]time.Time) (incoming bool, numStreams int) {
 			for c := range m {
@@ -317,6 +318,21 @@ func (p peerInfos) SortByValueAndStreams(segments *segments, sortByMoreStreams b
 	})
 }
 
+// updateScores updates the weighted peer scores of the candidates, if the connection
+// manager was configured to take peer scores into account.
+func (cm *BasicConnMgr) updateScores(candidates peerInfos) {
+	if cm.cfg.scores == nil {
+		return
+	}
+	for _, inf := range candidates {
+		score := int(cm.cfg.scoreWeight * cm.cfg.scores.Score(inf.id))
+		s := cm.segments.get(inf.id)
+		s.Lock()
+		inf.score = score
+		s.Unlock()
+	}
+}
+
 // TrimOpenConns closes the connections of as many peers as needed to make the peer count
 // equal the low watermark. Peers are sorted in ascending order based on their total value,
 // pruning those peers with the lowest scores first, as long as they are not within their
@@ -397,6 +413,7 @@ func (cm *BasicConnMgr) getConnsToCloseEmergency(target int) []network.Conn {
 	}
 	cm.plk.RUnlock()
 
+	cm.updateScores(candidates)
 	// Sort peers according to their value.
 	candidates.SortByValueAndStreams(&cm.segments, true)
 
@@ -431,6 +448,7 @@ func (cm *BasicConnMgr) getConnsToCloseEmergency(target int) []network.Conn {
 	}
 	cm.plk.RUnlock()
 
+	cm.updateScores(candidates)
 	candidates.SortByValueAndStreams(&cm.segments, true)
 	for _, inf := range candidates {
 		if target <= 0 {
@@ -495,6 +513,7 @@ func (cm *BasicConnMgr) getConnsToClose() []network.Conn {
 		return nil
 	}
 
+	cm.updateScores(candidates)
 	// Sort peers according to their value.
 	candidates.SortByValueAndStreams(&cm.segments, false)
 
@@ -7,11 +7,12 @@ import (
 	"testing"
 	"time"
 
-	"github.com/benbjohnson/clock"
 	"github.com/AstaFrode/go-libp2p/core/crypto"
 	"github.com/AstaFrode/go-libp2p/core/network"
 	"github.com/AstaFrode/go-libp2p/core/peer"
 	tu "github.com/AstaFrode/go-libp2p/core/test"
+	"github.com/AstaFrode/go-libp2p/p2p/host/peerstore/pstoremem"
+	"github.com/benbjohnson/clock"
 
 	ma "github.com/multiformats/go-multiaddr"
 	"github.com/stretchr/testify/require"
@@ -166,6 +167,31 @@ func TestConnTrimming(t *testing.T) {
 	}
 }
 
+func TestConnTrimmingWithPeerScores(t *testing.T) {
+	scores, err := pstoremem.NewScoreBook()
+	require.NoError(t, err)
+	cm, err := NewConnManager(1, 2, WithGracePeriod(0), WithPeerScores(scores, 10))
+	require.NoError(t, err)
+	defer cm.Close()
+	not := cm.Notifee()
+
+	var conns []network.Conn
+	for i := 0; i < 3; i++ {
+		rc := randConn(t, nil)
+		conns = append(conns, rc)
+		not.Connected(nil, rc)
+	}
+	// the score of the first peer outweighs the tag of the second one
+	scores.RecordObservation(conns[0].RemotePeer(), "test", 1)
+	cm.TagPeer(conns[1].RemotePeer(), "foo", 5)
+
+	cm.TrimOpenConns(context.Background())
+
+	require.False(t, conns[0].(*tconn).isClosed())
+	require.True(t, conns[1].(*tconn).isClosed())
+	require.True(t, conns[2].(*tconn).isClosed())
+}
+
 func TestConnsToClose(t *testing.T) {
 	addConns := func(cm *BasicConnMgr, n int) {
 		not := cm.Notifee()
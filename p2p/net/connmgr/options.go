@@ -4,6 +4,8 @@ import (
 	"errors"
 	"time"
 
+	"github.com/AstaFrode/go-libp2p/core/peerstore"
+
 	"github.com/benbjohnson/clock"
 )
 
@@ -16,6 +18,9 @@ type config struct {
 	decayer       *DecayerCfg
 	emergencyTrim bool
 	clock         clock.Clock
+
+	scores      peerstore.ScoreBook
+	scoreWeight float64
 }
 
 // Option represents an option for the basic connection manager.
@@ -62,3 +67,18 @@ func WithSilencePeriod(p time.Duration) Option {
 		return nil
 	}
 }
+
+// WithPeerScores makes the connection manager take the peer scores into account when
+// trimming connections: the score of a peer, multiplied by weight, is added to the
+// sum of its tag values.
+// The scores are usually the peerstore's ScoreBook, see peerstore.GetScoreBook.
+func WithPeerScores(scores peerstore.ScoreBook, weight float64) Option {
+	return func(cfg *config) error {
+		if weight <= 0 {
+			return errors.New("score weight must be positive")
+		}
+		cfg.scores = scores
+		cfg.scoreWeight = weight
+		return nil
+	}
+}
@@ -79,6 +79,12 @@ func (c *conn) Close() error {
 	return nil
 }
 
+// CloseWithError closes the connection. Mock connections don't transmit error codes,
+// so errCode and reason are dropped.
+func (c *conn) CloseWithError(errCode network.ConnErrorCode, reason string) error {
+	return c.Close()
+}
+
 func (c *conn) teardown() error {
 	for _, s := range c.allStreams() {
 		s.Reset()
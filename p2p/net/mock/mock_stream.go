@@ -130,9 +130,25 @@ func (s *stream) Close() error {
 }
 
 func (s *stream) Reset() error {
+	return s.resetWithError(network.ErrReset, network.ErrReset)
+}
+
+func (s *stream) ResetWithError(errCode network.StreamErrorCode) error {
+	if errCode == 0 {
+		return s.Reset()
+	}
+	return s.resetWithError(
+		&network.StreamError{ErrorCode: errCode},
+		&network.StreamError{ErrorCode: errCode, Remote: true},
+	)
+}
+
+// resetWithError resets the stream. Pending and future reads fail with localErr,
+// and reads on the remote end fail with remoteErr.
+func (s *stream) resetWithError(localErr, remoteErr error) error {
 	// Cancel any pending reads/writes with an error.
-	s.write.CloseWithError(network.ErrReset)
-	s.read.CloseWithError(network.ErrReset)
+	s.write.CloseWithError(remoteErr)
+	s.read.CloseWithError(localErr)
 
 	select {
 	case s.reset <- struct{}{}:
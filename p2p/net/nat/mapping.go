@@ -24,6 +24,15 @@ type Mapping interface {
 	// established, port will be 0
 	ExternalPort() int
 
+	// Expiry returns the time when the lease of the mapping expires, unless it
+	// is renewed. It returns the zero value if the mapping is not established,
+	// or if the NAT device doesn't support leases.
+	Expiry() time.Time
+
+	// Err returns the error encountered during the last attempt to establish
+	// or renew the mapping, if any.
+	Err() error
+
 	// ExternalAddr returns the external facing address. If the mapping is not
 	// established, addr will be nil, and and ErrNoMapping will be returned.
 	ExternalAddr() (addr net.Addr, err error)
@@ -40,6 +49,8 @@ type mapping struct {
 	proto   string
 	intport int
 	extport int
+	expiry  time.Time
+	err     error
 
 	cached    net.IP
 	cacheTime time.Time
@@ -70,10 +81,33 @@ func (m *mapping) ExternalPort() int {
 	return m.extport
 }
 
-func (m *mapping) setExternalPort(p int) {
+func (m *mapping) setExternalPort(p int, expiry time.Time) {
 	m.Lock()
 	defer m.Unlock()
 	m.extport = p
+	m.expiry = expiry
+}
+
+func (m *mapping) Expiry() time.Time {
+	m.Lock()
+	defer m.Unlock()
+	return m.expiry
+}
+
+func (m *mapping) Err() error {
+	m.Lock()
+	defer m.Unlock()
+	return m.err
+}
+
+func (m *mapping) setErr(err error) {
+	m.Lock()
+	defer m.Unlock()
+	m.err = err
+}
+
+func (m *mapping) failed() bool {
+	return m.Err() != nil
 }
 
 func (m *mapping) ExternalAddr() (net.Addr, error) {
@@ -4,9 +4,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net"
 	"sync"
 	"time"
 
+	"github.com/AstaFrode/go-libp2p/core/event"
+
 	logging "github.com/ipfs/go-log/v2"
 
 	"github.com/libp2p/go-nat"
@@ -58,6 +61,15 @@ type NAT struct {
 	mappingmu sync.RWMutex // guards mappings
 	closed    bool
 	mappings  map[*mapping]struct{}
+
+	notifymu sync.Mutex
+	notify   func(event.EvtNATPortMappingChanged)
+}
+
+// NewNAT returns an object that manages port mappings on a NAT device that
+// was discovered using go-nat.
+func NewNAT(realNAT nat.NAT) *NAT {
+	return newNAT(realNAT)
 }
 
 func newNAT(realNAT nat.NAT) *NAT {
@@ -81,6 +93,36 @@ func (nat *NAT) Close() error {
 	return nil
 }
 
+// Type returns the port mapping protocol used by the NAT device, e.g. "NAT-PMP".
+func (nat *NAT) Type() string {
+	nat.natmu.Lock()
+	defer nat.natmu.Unlock()
+	return nat.nat.Type()
+}
+
+// DeviceAddress returns the internal address of the NAT device.
+func (nat *NAT) DeviceAddress() (net.IP, error) {
+	nat.natmu.Lock()
+	defer nat.natmu.Unlock()
+	return nat.nat.GetDeviceAddress()
+}
+
+// SetNotifier sets a function that is called every time the status of a
+// mapping changes. It must not block.
+func (nat *NAT) SetNotifier(f func(event.EvtNATPortMappingChanged)) {
+	nat.notifymu.Lock()
+	defer nat.notifymu.Unlock()
+	nat.notify = f
+}
+
+func (nat *NAT) emit(evt event.EvtNATPortMappingChanged) {
+	nat.notifymu.Lock()
+	defer nat.notifymu.Unlock()
+	if nat.notify != nil {
+		nat.notify(evt)
+	}
+}
+
 // Mappings returns a slice of all NAT mappings
 func (nat *NAT) Mappings() []Mapping {
 	nat.mappingmu.Lock()
@@ -140,6 +182,11 @@ func (nat *NAT) removeMapping(m *mapping) {
 	nat.natmu.Lock()
 	nat.nat.DeletePortMapping(m.Protocol(), m.InternalPort())
 	nat.natmu.Unlock()
+	nat.emit(event.EvtNATPortMappingChanged{
+		Protocol:     m.Protocol(),
+		InternalPort: m.InternalPort(),
+		Status:       event.NATPortMappingRemoved,
+	})
 }
 
 func (nat *NAT) refreshMappings(m *mapping) {
@@ -160,34 +207,63 @@ func (nat *NAT) refreshMappings(m *mapping) {
 
 func (nat *NAT) establishMapping(m *mapping) {
 	oldport := m.ExternalPort()
+	failedBefore := m.failed()
 
 	log.Debugf("Attempting port map: %s/%d", m.Protocol(), m.InternalPort())
 	const comment = "libp2p"
 
 	nat.natmu.Lock()
+	var expiry time.Time
 	newport, err := nat.nat.AddPortMapping(m.Protocol(), m.InternalPort(), comment, MappingDuration)
-	if err != nil {
+	if err == nil {
+		expiry = time.Now().Add(MappingDuration)
+	} else {
 		// Some hardware does not support mappings with timeout, so try that
 		newport, err = nat.nat.AddPortMapping(m.Protocol(), m.InternalPort(), comment, 0)
 	}
 	nat.natmu.Unlock()
 
 	if err != nil || newport == 0 {
-		m.setExternalPort(0) // clear mapping
-		// TODO: log.Event
+		m.setExternalPort(0, time.Time{}) // clear mapping
 		if err != nil {
 			log.Warnf("failed to establish port mapping: %s", err)
 		} else {
+			err = errors.New("NAT device returned external port 0")
 			log.Warnf("failed to establish port mapping: newport = 0")
 		}
+		m.setErr(err)
+		// Only notify about the first failure, we'll retry every time the mapping is refreshed.
+		if !failedBefore {
+			nat.emit(event.EvtNATPortMappingChanged{
+				Protocol:     m.Protocol(),
+				InternalPort: m.InternalPort(),
+				Status:       event.NATPortMappingFailed,
+				Error:        err,
+			})
+		}
 		// we do not close if the mapping failed,
 		// because it may work again next time.
 		return
 	}
 
-	m.setExternalPort(newport)
+	m.setExternalPort(newport, expiry)
+	m.setErr(nil)
 	log.Debugf("NAT Mapping: %d --> %d (%s)", m.ExternalPort(), m.InternalPort(), m.Protocol())
 	if oldport != 0 && newport != oldport {
 		log.Debugf("failed to renew same port mapping: ch %d -> %d", oldport, newport)
 	}
+	status := event.NATPortMappingRenewed
+	if oldport == 0 {
+		status = event.NATPortMappingEstablished
+	} else if newport == oldport {
+		// a regular renewal, nothing changed
+		return
+	}
+	nat.emit(event.EvtNATPortMappingChanged{
+		Protocol:     m.Protocol(),
+		InternalPort: m.InternalPort(),
+		ExternalPort: newport,
+		Status:       status,
+		Expiry:       expiry,
+	})
 }
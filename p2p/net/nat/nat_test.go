@@ -0,0 +1,91 @@
+package nat
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AstaFrode/go-libp2p/core/event"
+
+	"github.com/stretchr/testify/require"
+)
+
+type mockNAT struct {
+	mx       sync.Mutex
+	err      error
+	mappings map[int]int
+}
+
+func (n *mockNAT) Type() string                        { return "mock" }
+func (n *mockNAT) GetDeviceAddress() (net.IP, error)   { return net.IPv4(192, 168, 0, 1), nil }
+func (n *mockNAT) GetExternalAddress() (net.IP, error) { return net.IPv4(1, 2, 3, 4), nil }
+func (n *mockNAT) GetInternalAddress() (net.IP, error) { return net.IPv4(192, 168, 0, 2), nil }
+
+func (n *mockNAT) AddPortMapping(_ string, internalPort int, _ string, _ time.Duration) (int, error) {
+	n.mx.Lock()
+	defer n.mx.Unlock()
+	if n.err != nil {
+		return 0, n.err
+	}
+	n.mappings[internalPort] = internalPort + 10000
+	return internalPort + 10000, nil
+}
+
+func (n *mockNAT) DeletePortMapping(_ string, internalPort int) error {
+	n.mx.Lock()
+	defer n.mx.Unlock()
+	delete(n.mappings, internalPort)
+	return nil
+}
+
+func (n *mockNAT) setErr(err error) {
+	n.mx.Lock()
+	defer n.mx.Unlock()
+	n.err = err
+}
+
+func TestMappingEvents(t *testing.T) {
+	mock := &mockNAT{mappings: make(map[int]int)}
+	nat := newNAT(mock)
+	defer nat.Close()
+
+	var evts []event.EvtNATPortMappingChanged
+	nat.SetNotifier(func(evt event.EvtNATPortMappingChanged) { evts = append(evts, evt) })
+
+	m, err := nat.NewMapping("tcp", 1234)
+	require.NoError(t, err)
+	require.Equal(t, 11234, m.ExternalPort())
+	require.WithinDuration(t, time.Now().Add(MappingDuration), m.Expiry(), time.Second)
+	require.Len(t, evts, 1)
+	require.Equal(t, event.NATPortMappingEstablished, evts[0].Status)
+	require.Equal(t, 11234, evts[0].ExternalPort)
+	require.Equal(t, m.Expiry(), evts[0].Expiry)
+
+	// a successful renewal doesn't trigger an event
+	nat.establishMapping(m.(*mapping))
+	require.Len(t, evts, 1)
+
+	// renewal failures are only reported once
+	mock.setErr(errors.New("gateway error"))
+	nat.establishMapping(m.(*mapping))
+	nat.establishMapping(m.(*mapping))
+	require.Len(t, evts, 2)
+	require.Equal(t, event.NATPortMappingFailed, evts[1].Status)
+	require.EqualError(t, evts[1].Error, "gateway error")
+	require.Zero(t, m.ExternalPort())
+	require.Zero(t, m.Expiry())
+	require.Error(t, m.Err())
+
+	mock.setErr(nil)
+	nat.establishMapping(m.(*mapping))
+	require.Len(t, evts, 3)
+	require.Equal(t, event.NATPortMappingEstablished, evts[2].Status)
+	require.NoError(t, m.Err())
+
+	require.NoError(t, m.Close())
+	require.Len(t, evts, 4)
+	require.Equal(t, event.NATPortMappingRemoved, evts[3].Status)
+	require.Empty(t, nat.Mappings())
+}
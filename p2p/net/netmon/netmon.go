@@ -0,0 +1,214 @@
+// Package netmon watches the network interfaces of the local machine, and signals
+// when their addresses change, e.g. when a laptop switches networks or an interface
+// is removed.
+//
+// Where supported (Linux, and the BSDs including macOS), the Monitor subscribes to
+// the operating system's interface change notifications. On all platforms, the
+// interface addresses are also polled periodically, in case a notification was missed.
+package netmon
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	logging "github.com/ipfs/go-log/v2"
+	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+var log = logging.Logger("netmon")
+
+const (
+	// DefaultPollInterval is the default interval at which interface addresses are polled.
+	DefaultPollInterval = time.Minute
+
+	// settleDelay is the time we wait after an OS notification before checking the
+	// interface addresses. A single change usually causes a burst of notifications.
+	settleDelay = 250 * time.Millisecond
+)
+
+var errNotSupported = errors.New("interface change notifications not supported on this platform")
+
+// osWatcher delivers the operating system's interface change notifications.
+type osWatcher interface {
+	Events() <-chan struct{}
+	Close() error
+}
+
+type Option func(*Monitor) error
+
+// WithPollInterval sets the interval at which interface addresses are polled.
+// Defaults to DefaultPollInterval.
+func WithPollInterval(d time.Duration) Option {
+	return func(m *Monitor) error {
+		if d <= 0 {
+			return errors.New("poll interval must be positive")
+		}
+		m.pollInterval = d
+		return nil
+	}
+}
+
+// Monitor watches the network interfaces for address changes.
+type Monitor struct {
+	pollInterval   time.Duration
+	interfaceAddrs func() ([]ma.Multiaddr, error)
+	newOSWatcher   func() (osWatcher, error)
+
+	changes chan struct{}
+
+	ctx       context.Context
+	ctxCancel context.CancelFunc
+	refCount  sync.WaitGroup
+
+	mx    sync.Mutex
+	addrs []ma.Multiaddr
+}
+
+// New creates a new Monitor and starts watching the network interfaces.
+func New(opts ...Option) (*Monitor, error) {
+	m := &Monitor{
+		pollInterval:   DefaultPollInterval,
+		interfaceAddrs: manet.InterfaceMultiaddrs,
+		newOSWatcher:   newOSWatcher,
+	}
+	for _, opt := range opts {
+		if err := opt(m); err != nil {
+			return nil, err
+		}
+	}
+	m.start()
+	return m, nil
+}
+
+func (m *Monitor) start() {
+	m.changes = make(chan struct{}, 1)
+	m.ctx, m.ctxCancel = context.WithCancel(context.Background())
+	m.addrs, _ = m.interfaceAddrs()
+
+	var events <-chan struct{}
+	w, err := m.newOSWatcher()
+	switch {
+	case err == errNotSupported:
+		log.Debug("interface change notifications not supported, polling interface addresses")
+		w = nil
+	case err != nil:
+		log.Warnw("failed to subscribe to interface change notifications, polling interface addresses", "error", err)
+		w = nil
+	default:
+		events = w.Events()
+	}
+
+	m.refCount.Add(1)
+	go m.background(w, events)
+}
+
+// Changes returns a channel that receives a value every time the interface addresses change.
+// Notifications are coalesced: if the receiver falls behind, only one pending notification
+// is delivered. Use Addrs to get the current addresses.
+func (m *Monitor) Changes() <-chan struct{} {
+	return m.changes
+}
+
+// Addrs returns the interface addresses seen by the last check.
+func (m *Monitor) Addrs() []ma.Multiaddr {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	return append([]ma.Multiaddr(nil), m.addrs...)
+}
+
+// Close stops watching the network interfaces.
+func (m *Monitor) Close() error {
+	m.ctxCancel()
+	m.refCount.Wait()
+	return nil
+}
+
+func (m *Monitor) background(w osWatcher, events <-chan struct{}) {
+	defer m.refCount.Done()
+	if w != nil {
+		defer w.Close()
+	}
+
+	ticker := time.NewTicker(m.pollInterval)
+	defer ticker.Stop()
+
+	// settle is armed when an OS notification arrives
+	settle := time.NewTimer(0)
+	if !settle.Stop() {
+		<-settle.C
+	}
+	defer settle.Stop()
+	var settling bool
+
+	for {
+		select {
+		case _, ok := <-events:
+			if !ok {
+				log.Debug("interface change notifications stopped, polling interface addresses")
+				events = nil
+				continue
+			}
+			if !settling {
+				settling = true
+				settle.Reset(settleDelay)
+			}
+		case <-settle.C:
+			settling = false
+			m.check()
+		case <-ticker.C:
+			m.check()
+		case <-m.ctx.Done():
+			return
+		}
+	}
+}
+
+// check compares the current interface addresses to the last seen ones,
+// and signals a change if they differ.
+func (m *Monitor) check() {
+	addrs, err := m.interfaceAddrs()
+	if err != nil {
+		log.Debugw("failed to get interface addresses", "error", err)
+		return
+	}
+
+	m.mx.Lock()
+	changed := !sameAddrs(addrs, m.addrs)
+	m.addrs = addrs
+	m.mx.Unlock()
+	if !changed {
+		return
+	}
+
+	log.Debugw("interface addresses changed", "addrs", addrs)
+	select {
+	case m.changes <- struct{}{}:
+	default:
+	}
+}
+
+func sameAddrs(a, b []ma.Multiaddr) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	as := make([]string, 0, len(a))
+	for _, addr := range a {
+		as = append(as, string(addr.Bytes()))
+	}
+	bs := make([]string, 0, len(b))
+	for _, addr := range b {
+		bs = append(bs, string(addr.Bytes()))
+	}
+	sort.Strings(as)
+	sort.Strings(bs)
+	for i := range as {
+		if as[i] != bs[i] {
+			return false
+		}
+	}
+	return true
+}
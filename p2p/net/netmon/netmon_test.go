@@ -0,0 +1,142 @@
+package netmon
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/require"
+)
+
+type mockWatcher struct {
+	events chan struct{}
+	closed chan struct{}
+}
+
+func newMockWatcher() *mockWatcher {
+	return &mockWatcher{events: make(chan struct{}, 10), closed: make(chan struct{})}
+}
+
+func (w *mockWatcher) Events() <-chan struct{} { return w.events }
+func (w *mockWatcher) Close() error {
+	close(w.closed)
+	return nil
+}
+
+type mockInterfaces struct {
+	mx    sync.Mutex
+	addrs []ma.Multiaddr
+}
+
+func (i *mockInterfaces) set(addrs ...string) {
+	i.mx.Lock()
+	defer i.mx.Unlock()
+	i.addrs = nil
+	for _, a := range addrs {
+		i.addrs = append(i.addrs, ma.StringCast(a))
+	}
+}
+
+func (i *mockInterfaces) get() ([]ma.Multiaddr, error) {
+	i.mx.Lock()
+	defer i.mx.Unlock()
+	return append([]ma.Multiaddr(nil), i.addrs...), nil
+}
+
+func newMockMonitor(t *testing.T, w *mockWatcher, ifaces *mockInterfaces, pollInterval time.Duration) *Monitor {
+	m := &Monitor{
+		pollInterval:   pollInterval,
+		interfaceAddrs: ifaces.get,
+		newOSWatcher:   func() (osWatcher, error) { return w, nil },
+	}
+	if w == nil {
+		m.newOSWatcher = func() (osWatcher, error) { return nil, errNotSupported }
+	}
+	m.start()
+	t.Cleanup(func() { m.Close() })
+	return m
+}
+
+func requireChange(t *testing.T, m *Monitor) {
+	t.Helper()
+	select {
+	case <-m.Changes():
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected a change notification")
+	}
+}
+
+func requireNoChange(t *testing.T, m *Monitor, d time.Duration) {
+	t.Helper()
+	select {
+	case <-m.Changes():
+		t.Fatal("unexpected change notification")
+	case <-time.After(d):
+	}
+}
+
+func TestOSNotifications(t *testing.T) {
+	ifaces := &mockInterfaces{}
+	ifaces.set("/ip4/127.0.0.1", "/ip4/192.168.1.2")
+	w := newMockWatcher()
+	m := newMockMonitor(t, w, ifaces, time.Hour)
+
+	// a notification without address change doesn't signal a change
+	w.events <- struct{}{}
+	requireNoChange(t, m, 2*settleDelay)
+
+	// a burst of notifications results in a single change
+	ifaces.set("/ip4/127.0.0.1", "/ip4/10.0.0.5")
+	for i := 0; i < 5; i++ {
+		w.events <- struct{}{}
+	}
+	requireChange(t, m)
+	requireNoChange(t, m, 2*settleDelay)
+	require.Equal(t, []ma.Multiaddr{ma.StringCast("/ip4/127.0.0.1"), ma.StringCast("/ip4/10.0.0.5")}, m.Addrs())
+
+	// the order of the addresses doesn't matter
+	ifaces.set("/ip4/10.0.0.5", "/ip4/127.0.0.1")
+	w.events <- struct{}{}
+	requireNoChange(t, m, 2*settleDelay)
+
+	require.NoError(t, m.Close())
+	select {
+	case <-w.closed:
+	default:
+		t.Fatal("expected the watcher to be closed")
+	}
+}
+
+func TestPolling(t *testing.T) {
+	ifaces := &mockInterfaces{}
+	ifaces.set("/ip4/127.0.0.1", "/ip4/192.168.1.2")
+	m := newMockMonitor(t, nil, ifaces, 20*time.Millisecond)
+
+	requireNoChange(t, m, 100*time.Millisecond)
+	ifaces.set("/ip4/127.0.0.1")
+	requireChange(t, m)
+	require.Equal(t, []ma.Multiaddr{ma.StringCast("/ip4/127.0.0.1")}, m.Addrs())
+}
+
+func TestWatcherStopped(t *testing.T) {
+	ifaces := &mockInterfaces{}
+	ifaces.set("/ip4/127.0.0.1")
+	w := newMockWatcher()
+	m := newMockMonitor(t, w, ifaces, 20*time.Millisecond)
+
+	// the monitor falls back to polling
+	close(w.events)
+	ifaces.set("/ip4/127.0.0.1", "/ip6/::1")
+	requireChange(t, m)
+}
+
+func TestNew(t *testing.T) {
+	_, err := New(WithPollInterval(0))
+	require.Error(t, err)
+
+	m, err := New()
+	require.NoError(t, err)
+	require.NotEmpty(t, m.Addrs())
+	require.NoError(t, m.Close())
+}
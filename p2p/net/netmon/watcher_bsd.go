@@ -0,0 +1,17 @@
+//go:build darwin || freebsd || netbsd || openbsd
+
+package netmon
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// newOSWatcher opens a routing socket, which receives messages for interface and address changes.
+func newOSWatcher() (osWatcher, error) {
+	fd, err := unix.Socket(unix.AF_ROUTE, unix.SOCK_RAW, unix.AF_UNSPEC)
+	if err != nil {
+		return nil, err
+	}
+	unix.CloseOnExec(fd)
+	return newSocketWatcher(fd)
+}
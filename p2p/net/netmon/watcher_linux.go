@@ -0,0 +1,22 @@
+package netmon
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// newOSWatcher subscribes to netlink notifications for link and address changes.
+func newOSWatcher() (osWatcher, error) {
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_RAW|unix.SOCK_CLOEXEC, unix.NETLINK_ROUTE)
+	if err != nil {
+		return nil, err
+	}
+	sa := &unix.SockaddrNetlink{
+		Family: unix.AF_NETLINK,
+		Groups: unix.RTMGRP_LINK | unix.RTMGRP_IPV4_IFADDR | unix.RTMGRP_IPV6_IFADDR,
+	}
+	if err := unix.Bind(fd, sa); err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+	return newSocketWatcher(fd)
+}
@@ -0,0 +1,7 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd
+
+package netmon
+
+func newOSWatcher() (osWatcher, error) {
+	return nil, errNotSupported
+}
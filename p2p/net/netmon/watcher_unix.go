@@ -0,0 +1,84 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd
+
+package netmon
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// readTimeout bounds how long a read on the notification socket blocks,
+// so that the read loop notices when the watcher is closed.
+const readTimeout = 500 * time.Millisecond
+
+// socketWatcher reads interface change notifications from a netlink or routing socket.
+// The content of the messages is ignored: every message triggers an address check.
+type socketWatcher struct {
+	fd     int
+	events chan struct{}
+
+	closeOnce sync.Once
+	closing   chan struct{}
+	done      chan struct{}
+}
+
+func newSocketWatcher(fd int) (*socketWatcher, error) {
+	tv := unix.NsecToTimeval(readTimeout.Nanoseconds())
+	if err := unix.SetsockoptTimeval(fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &tv); err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+	w := &socketWatcher{
+		fd:      fd,
+		events:  make(chan struct{}, 1),
+		closing: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go w.readLoop()
+	return w, nil
+}
+
+func (w *socketWatcher) readLoop() {
+	defer close(w.done)
+	defer close(w.events)
+
+	buf := make([]byte, 1<<16)
+	for {
+		select {
+		case <-w.closing:
+			return
+		default:
+		}
+
+		_, err := unix.Read(w.fd, buf)
+		switch {
+		case err == nil, errors.Is(err, unix.ENOBUFS):
+			// ENOBUFS means that the kernel dropped notifications, so something changed.
+			select {
+			case w.events <- struct{}{}:
+			default:
+			}
+		case errors.Is(err, unix.EAGAIN), errors.Is(err, unix.EINTR):
+		default:
+			log.Debugw("reading interface change notifications failed", "error", err)
+			return
+		}
+	}
+}
+
+func (w *socketWatcher) Events() <-chan struct{} {
+	return w.events
+}
+
+func (w *socketWatcher) Close() error {
+	var err error
+	w.closeOnce.Do(func() {
+		close(w.closing)
+		<-w.done
+		err = unix.Close(w.fd)
+	})
+	return err
+}
@@ -0,0 +1,312 @@
+package swarm
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/AstaFrode/go-libp2p/core/event"
+
+	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+// BlackHoleConfig configures a black hole filter.
+//
+// The filter keeps track of the results of the last N dials. If fewer than MinSuccesses of them
+// succeeded, the addresses are considered black holed, and only one in N dials is allowed,
+// to probe if the situation changed.
+type BlackHoleConfig struct {
+	Enabled      bool
+	N            int
+	MinSuccesses int
+}
+
+// The black hole filters are disabled by default. To enable them with the default parameters,
+// set Enabled on a copy of these configurations.
+var (
+	// DefaultUDPBlackHoleConfig is the default configuration of the UDP black hole filter.
+	DefaultUDPBlackHoleConfig = BlackHoleConfig{Enabled: false, N: 100, MinSuccesses: 5}
+	// DefaultIPv6BlackHoleConfig is the default configuration of the IPv6 black hole filter.
+	DefaultIPv6BlackHoleConfig = BlackHoleConfig{Enabled: false, N: 100, MinSuccesses: 5}
+)
+
+func (c BlackHoleConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.N <= 0 {
+		return errors.New("black hole filter: N must be positive")
+	}
+	if c.MinSuccesses <= 0 || c.MinSuccesses > c.N {
+		return errors.New("black hole filter: MinSuccesses must be between 1 and N")
+	}
+	return nil
+}
+
+// BlackHoleStatus is the status of a black hole filter.
+type BlackHoleStatus struct {
+	// Name is the name of the filter, "UDP" or "IPv6".
+	Name  string
+	State event.BlackHoleState
+	// SuccessfulDials is the number of successful dials in the current window.
+	SuccessfulDials int
+	// TotalDials is the number of dials in the current window, at most N.
+	TotalDials int
+}
+
+type blackHoleResult int
+
+const (
+	blackHoleResultAllowed blackHoleResult = iota
+	blackHoleResultProbing
+	blackHoleResultBlocked
+)
+
+// blackHoleFilter provides black hole filtering for dials to a class of addresses.
+// In a black holed environment, dial requests are blocked and only periodic probes
+// to check the state of the black hole are allowed.
+type blackHoleFilter struct {
+	name         string
+	n            int
+	minSuccesses int
+
+	mu sync.Mutex
+	// requests counts the dial requests to peers with addresses matching the filter,
+	// since the last state change. Every n-th request is allowed as a probe while blocked.
+	requests int
+	// dialResults is a sliding window of the last n dial results
+	dialResults []bool
+	successes   int
+	state       event.BlackHoleState
+}
+
+func newBlackHoleFilter(name string, cfg BlackHoleConfig) *blackHoleFilter {
+	return &blackHoleFilter{name: name, n: cfg.N, minSuccesses: cfg.MinSuccesses}
+}
+
+// RecordResult records the outcome of a dial. It returns true if the state of the filter changed.
+func (b *blackHoleFilter) RecordResult(success bool) (BlackHoleStatus, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	old := b.state
+	if b.state == event.BlackHoleStateBlocked && success {
+		// If a dial succeeds in the blocked state, we start over. This is better than slowly
+		// accumulating successes until we cross the threshold, since a black hole is a binary property.
+		b.resetLocked()
+		return b.statusLocked(), b.state != old
+	}
+
+	if success {
+		b.successes++
+	}
+	b.dialResults = append(b.dialResults, success)
+	if len(b.dialResults) > b.n {
+		if b.dialResults[0] {
+			b.successes--
+		}
+		b.dialResults = b.dialResults[1:]
+	}
+	b.updateStateLocked()
+	return b.statusLocked(), b.state != old
+}
+
+// HandleRequest returns the result of applying the filter to a dial request.
+func (b *blackHoleFilter) HandleRequest() blackHoleResult {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.requests++
+	switch {
+	case b.state == event.BlackHoleStateAllowed:
+		return blackHoleResultAllowed
+	case b.state == event.BlackHoleStateProbing || b.requests%b.n == 0:
+		return blackHoleResultProbing
+	default:
+		return blackHoleResultBlocked
+	}
+}
+
+// Reset forgets all dial results, forcing the filter to probe again.
+// It returns true if the state of the filter changed.
+func (b *blackHoleFilter) Reset() (BlackHoleStatus, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	old := b.state
+	b.resetLocked()
+	return b.statusLocked(), b.state != old
+}
+
+func (b *blackHoleFilter) Status() BlackHoleStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.statusLocked()
+}
+
+func (b *blackHoleFilter) resetLocked() {
+	b.successes = 0
+	b.dialResults = b.dialResults[:0]
+	b.requests = 0
+	b.updateStateLocked()
+}
+
+func (b *blackHoleFilter) updateStateLocked() {
+	old := b.state
+	switch {
+	case len(b.dialResults) < b.n:
+		b.state = event.BlackHoleStateProbing
+	case b.successes >= b.minSuccesses:
+		b.state = event.BlackHoleStateAllowed
+	default:
+		b.state = event.BlackHoleStateBlocked
+	}
+	if old != b.state {
+		b.requests = 0
+	}
+}
+
+func (b *blackHoleFilter) statusLocked() BlackHoleStatus {
+	return BlackHoleStatus{
+		Name:            b.name,
+		State:           b.state,
+		SuccessfulDials: b.successes,
+		TotalDials:      len(b.dialResults),
+	}
+}
+
+// blackHoleDetector provides UDP and IPv6 black hole detection using a blackHoleFilter
+// for each. Only dials to public addresses are considered.
+type blackHoleDetector struct {
+	udp, ipv6 *blackHoleFilter // nil if disabled
+	emitter   event.Emitter    // may be nil
+}
+
+func newBlackHoleDetector(udp, ipv6 BlackHoleConfig) *blackHoleDetector {
+	d := &blackHoleDetector{}
+	if udp.Enabled {
+		d.udp = newBlackHoleFilter("UDP", udp)
+	}
+	if ipv6.Enabled {
+		d.ipv6 = newBlackHoleFilter("IPv6", ipv6)
+	}
+	return d
+}
+
+// FilterAddrs removes the addresses that are currently considered black holed.
+func (d *blackHoleDetector) FilterAddrs(addrs []ma.Multiaddr) []ma.Multiaddr {
+	hasUDP, hasIPv6 := false, false
+	for _, a := range addrs {
+		if !manet.IsPublicAddr(a) {
+			continue
+		}
+		if isProtocolAddr(a, ma.P_UDP) {
+			hasUDP = true
+		}
+		if isProtocolAddr(a, ma.P_IP6) {
+			hasIPv6 = true
+		}
+	}
+
+	udpRes := blackHoleResultAllowed
+	if d.udp != nil && hasUDP {
+		udpRes = d.udp.HandleRequest()
+	}
+	ipv6Res := blackHoleResultAllowed
+	if d.ipv6 != nil && hasIPv6 {
+		ipv6Res = d.ipv6.HandleRequest()
+	}
+
+	return ma.FilterAddrs(addrs, func(a ma.Multiaddr) bool {
+		if !manet.IsPublicAddr(a) {
+			return true
+		}
+		isUDP, isIPv6 := isProtocolAddr(a, ma.P_UDP), isProtocolAddr(a, ma.P_IP6)
+		// allow all UDP addresses while probing, irrespective of the IPv6 black hole state
+		if udpRes == blackHoleResultProbing && isUDP {
+			return true
+		}
+		// allow all IPv6 addresses while probing, irrespective of the UDP black hole state
+		if ipv6Res == blackHoleResultProbing && isIPv6 {
+			return true
+		}
+		if udpRes == blackHoleResultBlocked && isUDP {
+			return false
+		}
+		if ipv6Res == blackHoleResultBlocked && isIPv6 {
+			return false
+		}
+		return true
+	})
+}
+
+// RecordResult updates the filters matching addr with the outcome of a dial.
+func (d *blackHoleDetector) RecordResult(addr ma.Multiaddr, success bool) {
+	if !manet.IsPublicAddr(addr) {
+		return
+	}
+	if d.udp != nil && isProtocolAddr(addr, ma.P_UDP) {
+		d.notify(d.udp.RecordResult(success))
+	}
+	if d.ipv6 != nil && isProtocolAddr(addr, ma.P_IP6) {
+		d.notify(d.ipv6.RecordResult(success))
+	}
+}
+
+// Reset forgets the dial results of all filters, forcing them to probe again.
+func (d *blackHoleDetector) Reset() {
+	for _, f := range d.filters() {
+		d.notify(f.Reset())
+	}
+}
+
+func (d *blackHoleDetector) Status() []BlackHoleStatus {
+	filters := d.filters()
+	status := make([]BlackHoleStatus, 0, len(filters))
+	for _, f := range filters {
+		status = append(status, f.Status())
+	}
+	return status
+}
+
+func (d *blackHoleDetector) filters() []*blackHoleFilter {
+	var filters []*blackHoleFilter
+	if d.udp != nil {
+		filters = append(filters, d.udp)
+	}
+	if d.ipv6 != nil {
+		filters = append(filters, d.ipv6)
+	}
+	return filters
+}
+
+func (d *blackHoleDetector) notify(status BlackHoleStatus, changed bool) {
+	if !changed {
+		return
+	}
+	log.Debugw("black hole filter state changed", "filter", status.Name, "state", status.State,
+		"successes", status.SuccessfulDials, "dials", status.TotalDials)
+	if d.emitter == nil {
+		return
+	}
+	if err := d.emitter.Emit(event.EvtBlackHoleStateChanged{
+		Name:            status.Name,
+		State:           status.State,
+		SuccessfulDials: status.SuccessfulDials,
+		TotalDials:      status.TotalDials,
+	}); err != nil {
+		log.Debugw("failed to emit black hole state change", "error", err)
+	}
+}
+
+func isProtocolAddr(a ma.Multiaddr, p int) bool {
+	found := false
+	ma.ForEach(a, func(c ma.Component) bool {
+		if c.Protocol().Code == p {
+			found = true
+			return false
+		}
+		return true
+	})
+	return found
+}
@@ -0,0 +1,171 @@
+package swarm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/AstaFrode/go-libp2p/core/event"
+	"github.com/AstaFrode/go-libp2p/p2p/host/eventbus"
+
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlackHoleFilterReset(t *testing.T) {
+	n := 10
+	bhf := newBlackHoleFilter("test", BlackHoleConfig{Enabled: true, N: n, MinSuccesses: 2})
+	var i int
+	// calls up to n should be probing
+	for i = 1; i <= n; i++ {
+		if bhf.HandleRequest() != blackHoleResultProbing {
+			t.Fatalf("expected calls up to n to be probes")
+		}
+		bhf.RecordResult(false)
+	}
+	require.Equal(t, event.BlackHoleStateBlocked, bhf.Status().State)
+
+	// after threshold calls every nth call should be a probe
+	for i = n + 1; i < 42; i++ {
+		result := bhf.HandleRequest()
+		if (i%n == 0 && result != blackHoleResultProbing) || (i%n != 0 && result != blackHoleResultBlocked) {
+			t.Fatalf("expected every nth dial to be a probe")
+		}
+	}
+
+	// a success in the blocked state resets the filter
+	_, changed := bhf.RecordResult(true)
+	require.True(t, changed)
+	status := bhf.Status()
+	require.Equal(t, event.BlackHoleStateProbing, status.State)
+	require.Zero(t, status.TotalDials)
+	for i = 1; i <= n; i++ {
+		require.Equal(t, blackHoleResultProbing, bhf.HandleRequest())
+	}
+}
+
+func TestBlackHoleFilterSuccessFraction(t *testing.T) {
+	n := 10
+	tests := []struct {
+		success, fail int
+		result        blackHoleResult
+	}{
+		{success: 5, fail: 5, result: blackHoleResultAllowed},
+		{success: 3, fail: 7, result: blackHoleResultAllowed},
+		{success: 2, fail: 8, result: blackHoleResultAllowed},
+		{success: 1, fail: 9, result: blackHoleResultBlocked},
+		{success: 0, fail: 10, result: blackHoleResultBlocked},
+	}
+	for _, tc := range tests {
+		bhf := newBlackHoleFilter("test", BlackHoleConfig{Enabled: true, N: n, MinSuccesses: 2})
+		for i := 0; i < tc.fail; i++ {
+			bhf.RecordResult(false)
+		}
+		for i := 0; i < tc.success; i++ {
+			bhf.RecordResult(true)
+		}
+		require.Equal(t, tc.result, bhf.HandleRequest(), "success: %d, fail: %d", tc.success, tc.fail)
+	}
+}
+
+func TestBlackHoleDetectorFilterAddrs(t *testing.T) {
+	udpConfig := BlackHoleConfig{Enabled: true, N: 10, MinSuccesses: 5}
+	ipv6Config := BlackHoleConfig{Enabled: true, N: 10, MinSuccesses: 5}
+	bhd := newBlackHoleDetector(udpConfig, ipv6Config)
+
+	publicAddrs := []ma.Multiaddr{
+		ma.StringCast("/ip4/1.2.3.4/udp/1/quic-v1"),
+		ma.StringCast("/ip4/1.2.3.4/tcp/1"),
+		ma.StringCast("/ip6/2001::1/tcp/1"),
+		ma.StringCast("/ip6/2001::1/udp/1/quic-v1"),
+	}
+	privAddrs := []ma.Multiaddr{
+		ma.StringCast("/ip4/192.168.1.5/udp/1/quic-v1"),
+		ma.StringCast("/ip6/::1/udp/1/quic-v1"),
+	}
+	allAddrs := append(append([]ma.Multiaddr{}, publicAddrs...), privAddrs...)
+
+	for i := 0; i < 10; i++ {
+		bhd.RecordResult(publicAddrs[0], false) // UDP
+		bhd.RecordResult(publicAddrs[2], false) // IPv6
+	}
+	// private addresses are never filtered
+	require.ElementsMatch(t, append([]ma.Multiaddr{publicAddrs[1]}, privAddrs...), bhd.FilterAddrs(allAddrs))
+
+	// results for private addresses are ignored
+	for i := 0; i < 10; i++ {
+		bhd.RecordResult(privAddrs[0], true)
+	}
+	for _, s := range bhd.Status() {
+		require.Equal(t, event.BlackHoleStateBlocked, s.State)
+	}
+
+	// reset forces re-probing
+	bhd.Reset()
+	require.ElementsMatch(t, allAddrs, bhd.FilterAddrs(allAddrs))
+}
+
+func TestBlackHoleDetectorDisabled(t *testing.T) {
+	bhd := newBlackHoleDetector(DefaultUDPBlackHoleConfig, BlackHoleConfig{Enabled: true, N: 100, MinSuccesses: 5})
+	require.Len(t, bhd.Status(), 1)
+	require.Equal(t, "IPv6", bhd.Status()[0].Name)
+
+	addr := ma.StringCast("/ip4/1.2.3.4/udp/1/quic-v1")
+	for i := 0; i < 1000; i++ {
+		bhd.RecordResult(addr, false)
+	}
+	require.Equal(t, []ma.Multiaddr{addr}, bhd.FilterAddrs([]ma.Multiaddr{addr}))
+}
+
+func TestBlackHoleDetectorEvents(t *testing.T) {
+	bus := eventbus.NewBus()
+	sub, err := bus.Subscribe(new(event.EvtBlackHoleStateChanged))
+	require.NoError(t, err)
+	defer sub.Close()
+
+	bhd := newBlackHoleDetector(BlackHoleConfig{Enabled: true, N: 2, MinSuccesses: 1}, BlackHoleConfig{Enabled: false})
+	bhd.emitter, err = bus.Emitter(new(event.EvtBlackHoleStateChanged))
+	require.NoError(t, err)
+	defer bhd.emitter.Close()
+
+	next := func() event.EvtBlackHoleStateChanged {
+		t.Helper()
+		select {
+		case e := <-sub.Out():
+			return e.(event.EvtBlackHoleStateChanged)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for event")
+		}
+		return event.EvtBlackHoleStateChanged{}
+	}
+
+	addr := ma.StringCast("/ip4/1.2.3.4/udp/1/quic-v1")
+	bhd.RecordResult(addr, false)
+	bhd.RecordResult(addr, false)
+	require.Equal(t, event.EvtBlackHoleStateChanged{Name: "UDP", State: event.BlackHoleStateBlocked, TotalDials: 2}, next())
+
+	bhd.Reset()
+	require.Equal(t, event.EvtBlackHoleStateChanged{Name: "UDP", State: event.BlackHoleStateProbing}, next())
+
+	bhd.RecordResult(addr, true)
+	bhd.RecordResult(addr, true)
+	require.Equal(t, event.EvtBlackHoleStateChanged{Name: "UDP", State: event.BlackHoleStateAllowed, SuccessfulDials: 2, TotalDials: 2}, next())
+
+	select {
+	case e := <-sub.Out():
+		t.Fatalf("unexpected event: %v", e)
+	default:
+	}
+}
+
+func TestBlackHoleConfigValidation(t *testing.T) {
+	require.NoError(t, BlackHoleConfig{Enabled: false}.validate())
+	require.NoError(t, DefaultUDPBlackHoleConfig.validate())
+	require.Error(t, BlackHoleConfig{Enabled: true, N: 0, MinSuccesses: 1}.validate())
+	require.Error(t, BlackHoleConfig{Enabled: true, N: 10, MinSuccesses: 11}.validate())
+}
+
+func TestBlackHoleDetectorDisabledByDefault(t *testing.T) {
+	s := makeSwarm(t)
+	defer s.Close()
+	require.Empty(t, s.BlackHoleStatus())
+}
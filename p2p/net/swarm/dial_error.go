@@ -25,14 +25,15 @@ func (e *DialError) Timeout() bool {
 	return os.IsTimeout(e.Cause)
 }
 
-func (e *DialError) recordErr(addr ma.Multiaddr, err error) {
+func (e *DialError) recordErr(addr, synthesizedFrom ma.Multiaddr, err error) {
 	if len(e.DialErrors) >= maxDialDialErrors {
 		e.Skipped++
 		return
 	}
 	e.DialErrors = append(e.DialErrors, TransportError{
-		Address: addr,
-		Cause:   err,
+		Address:         addr,
+		SynthesizedFrom: synthesizedFrom,
+		Cause:           err,
 	})
 }
 
@@ -43,6 +44,10 @@ func (e *DialError) Error() string {
 		fmt.Fprintf(&builder, " %s", e.Cause)
 	}
 	for _, te := range e.DialErrors {
+		if te.SynthesizedFrom != nil {
+			fmt.Fprintf(&builder, "\n  * [%s (NAT64 from %s)] %s", te.Address, te.SynthesizedFrom, te.Cause)
+			continue
+		}
 		fmt.Fprintf(&builder, "\n  * [%s] %s", te.Address, te.Cause)
 	}
 	if e.Skipped > 0 {
@@ -61,10 +66,16 @@ var _ error = (*DialError)(nil)
 // TransportError is the error returned when dialing a specific address.
 type TransportError struct {
 	Address ma.Multiaddr
-	Cause   error
+	// SynthesizedFrom is set if Address is a NAT64 address that was
+	// synthesized from this IPv4 address.
+	SynthesizedFrom ma.Multiaddr
+	Cause           error
 }
 
 func (e *TransportError) Error() string {
+	if e.SynthesizedFrom != nil {
+		return fmt.Sprintf("failed to dial %s (NAT64 from %s): %s", e.Address, e.SynthesizedFrom, e.Cause)
+	}
 	return fmt.Sprintf("failed to dial %s: %s", e.Address, e.Cause)
 }
 
@@ -47,9 +47,24 @@ func (ad *activeDial) dial(ctx context.Context) (*Conn, error) {
 	if forceDirect, reason := network.GetForceDirectDial(ctx); forceDirect {
 		dialCtx = network.WithForceDirectDial(dialCtx, reason)
 	}
+	if forceRelay, reason := network.GetForceRelayDial(ctx); forceRelay {
+		dialCtx = network.WithForceRelayDial(dialCtx, reason)
+	}
+	if protocols := network.GetDialTransports(ctx); protocols != nil {
+		dialCtx = network.WithDialTransports(dialCtx, protocols...)
+	}
+	if limit := network.GetDialAddrLimit(ctx); limit > 0 {
+		dialCtx = network.WithDialAddrLimit(dialCtx, limit)
+	}
 	if simConnect, isClient, reason := network.GetSimultaneousConnect(ctx); simConnect {
 		dialCtx = network.WithSimultaneousConnect(dialCtx, isClient, reason)
 	}
+	if userAgent := network.GetUserAgent(ctx); userAgent != "" {
+		dialCtx = network.WithUserAgent(dialCtx, userAgent)
+	}
+	if protocolVersion := network.GetProtocolVersion(ctx); protocolVersion != "" {
+		dialCtx = network.WithProtocolVersion(dialCtx, protocolVersion)
+	}
 
 	resch := make(chan dialResponse, 1)
 	select {
@@ -0,0 +1,123 @@
+package swarm
+
+import (
+	"errors"
+	"time"
+
+	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+// AddrScope is the scope of an address, used to select the dial timeout for the address.
+type AddrScope int
+
+const (
+	// AddrScopeAny matches addresses of any scope.
+	AddrScopeAny AddrScope = iota
+	// AddrScopePrivate matches non-relayed addresses that are not publicly routable,
+	// e.g. LAN and loopback addresses.
+	AddrScopePrivate
+	// AddrScopePublic matches non-relayed, publicly routable addresses.
+	AddrScopePublic
+	// AddrScopeRelay matches relayed (p2p-circuit) addresses.
+	AddrScopeRelay
+)
+
+func (s AddrScope) String() string {
+	switch s {
+	case AddrScopeAny:
+		return "any"
+	case AddrScopePrivate:
+		return "private"
+	case AddrScopePublic:
+		return "public"
+	case AddrScopeRelay:
+		return "relay"
+	default:
+		return "unknown"
+	}
+}
+
+// DialTimeout is the timeout for dialing addresses of a transport and scope.
+type DialTimeout struct {
+	// Transport is the multiaddr protocol code identifying the transport, e.g.
+	// ma.P_TCP, ma.P_QUIC_V1, ma.P_WS or ma.P_CIRCUIT.
+	// If 0, the timeout applies to all transports.
+	Transport int
+	// Scope is the scope of the addresses the timeout applies to.
+	Scope AddrScope
+	// Timeout is the dial timeout.
+	Timeout time.Duration
+}
+
+type dialTimeoutKey struct {
+	transport int
+	scope     AddrScope
+}
+
+// WithDialTimeouts sets dial timeouts for specific transports and address scopes.
+// They take precedence over the timeouts set using WithDialTimeout and WithDialTimeoutLocal.
+//
+// The timeout for an address is selected by walking its protocols from the outermost to the
+// innermost one (e.g. ws, then tcp for /ip4/1.2.3.4/tcp/1/ws), and using the first timeout
+// configured for that protocol, preferring a timeout for the address' scope over one for
+// AddrScopeAny. For relayed addresses, only the protocols following p2p-circuit are
+// considered. If no timeout matches any protocol, a timeout with Transport 0 for the
+// address' scope is used. Otherwise, the default timeouts apply.
+func WithDialTimeouts(timeouts ...DialTimeout) Option {
+	return func(s *Swarm) error {
+		for _, t := range timeouts {
+			if t.Timeout <= 0 {
+				return errors.New("dial timeout must be positive")
+			}
+			if t.Transport == 0 && t.Scope == AddrScopeAny {
+				return errors.New("use WithDialTimeout to set the dial timeout for all addresses")
+			}
+			if s.dialTimeouts == nil {
+				s.dialTimeouts = make(map[dialTimeoutKey]time.Duration)
+			}
+			s.dialTimeouts[dialTimeoutKey{transport: t.Transport, scope: t.Scope}] = t.Timeout
+		}
+		return nil
+	}
+}
+
+func addrScope(a ma.Multiaddr) AddrScope {
+	if isRelayAddr(a) {
+		return AddrScopeRelay
+	}
+	if manet.IsPublicAddr(a) {
+		return AddrScopePublic
+	}
+	return AddrScopePrivate
+}
+
+// dialTimeoutFor returns the timeout for dialing the address.
+func (s *Swarm) dialTimeoutFor(a ma.Multiaddr) time.Duration {
+	if len(s.dialTimeouts) > 0 {
+		scope := addrScope(a)
+		protos := a.Protocols()
+		for i := len(protos) - 1; i >= 0; i-- {
+			code := protos[i].Code
+			if t, ok := s.dialTimeouts[dialTimeoutKey{transport: code, scope: scope}]; ok {
+				return t
+			}
+			if t, ok := s.dialTimeouts[dialTimeoutKey{transport: code, scope: AddrScopeAny}]; ok {
+				return t
+			}
+			// the protocols below the circuit are used to dial the relay, not the peer
+			if code == ma.P_CIRCUIT {
+				break
+			}
+		}
+		if t, ok := s.dialTimeouts[dialTimeoutKey{scope: scope}]; ok {
+			return t
+		}
+	}
+
+	timeout := s.dialTimeout
+	if lowTimeoutFilters.AddrBlocked(a) && s.dialTimeoutLocal < s.dialTimeout {
+		timeout = s.dialTimeoutLocal
+	}
+	return timeout
+}
@@ -0,0 +1,51 @@
+package swarm
+
+import (
+	"testing"
+	"time"
+
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddrScope(t *testing.T) {
+	require.Equal(t, AddrScopePublic, addrScope(ma.StringCast("/ip4/1.2.3.4/tcp/1")))
+	require.Equal(t, AddrScopePrivate, addrScope(ma.StringCast("/ip4/192.168.1.2/udp/1/quic-v1")))
+	require.Equal(t, AddrScopePrivate, addrScope(ma.StringCast("/ip6/::1/tcp/1")))
+	require.Equal(t, AddrScopeRelay, addrScope(ma.StringCast("/ip4/1.2.3.4/tcp/1/p2p/QmcgpsyWgH8Y8ajJz1Cu72KnS5uo2Aa2LpzU7kinSupYUx/p2p-circuit")))
+}
+
+func TestDialTimeoutFor(t *testing.T) {
+	s := newTestSwarmWithResolver(t, nil)
+	s.dialTimeout = 10 * time.Second
+	s.dialTimeoutLocal = 2 * time.Second
+
+	publicTCP := ma.StringCast("/ip4/1.2.3.4/tcp/1")
+	publicWS := ma.StringCast("/ip4/1.2.3.4/tcp/1/ws")
+	privateTCP := ma.StringCast("/ip4/192.168.1.2/tcp/1")
+	publicQUIC := ma.StringCast("/ip4/1.2.3.4/udp/1/quic-v1")
+	privateQUIC := ma.StringCast("/ip4/192.168.1.2/udp/1/quic-v1")
+	relay := ma.StringCast("/ip4/1.2.3.4/tcp/1/p2p/QmcgpsyWgH8Y8ajJz1Cu72KnS5uo2Aa2LpzU7kinSupYUx/p2p-circuit")
+
+	// defaults
+	require.Equal(t, 10*time.Second, s.dialTimeoutFor(publicTCP))
+	require.Equal(t, 2*time.Second, s.dialTimeoutFor(privateTCP))
+
+	require.NoError(t, WithDialTimeouts(
+		DialTimeout{Transport: ma.P_QUIC_V1, Scope: AddrScopePrivate, Timeout: time.Second},
+		DialTimeout{Transport: ma.P_QUIC_V1, Timeout: 3 * time.Second},
+		DialTimeout{Transport: ma.P_WS, Timeout: 4 * time.Second},
+		DialTimeout{Transport: ma.P_TCP, Timeout: 5 * time.Second},
+		DialTimeout{Scope: AddrScopeRelay, Timeout: 30 * time.Second},
+	)(s))
+
+	require.Equal(t, time.Second, s.dialTimeoutFor(privateQUIC))
+	require.Equal(t, 3*time.Second, s.dialTimeoutFor(publicQUIC))
+	require.Equal(t, 4*time.Second, s.dialTimeoutFor(publicWS))
+	require.Equal(t, 30*time.Second, s.dialTimeoutFor(relay))
+	require.Equal(t, 5*time.Second, s.dialTimeoutFor(publicTCP))
+	require.Equal(t, 5*time.Second, s.dialTimeoutFor(privateTCP))
+
+	require.Error(t, WithDialTimeouts(DialTimeout{Transport: ma.P_TCP})(s))
+	require.Error(t, WithDialTimeouts(DialTimeout{Timeout: time.Second})(s))
+}
@@ -2,10 +2,13 @@ package swarm
 
 import (
 	"context"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/AstaFrode/go-libp2p/core/network"
 	"github.com/AstaFrode/go-libp2p/core/peer"
+	"github.com/AstaFrode/go-libp2p/core/peerstore"
 
 	ma "github.com/multiformats/go-multiaddr"
 	manet "github.com/multiformats/go-multiaddr/net"
@@ -39,6 +42,9 @@ type addrDial struct {
 	err      error
 	requests []int
 	dialed   bool
+
+	// the IPv4 address addr was synthesized from, if it is a NAT64 address
+	synthesizedFrom ma.Multiaddr
 }
 
 type dialWorker struct {
@@ -104,6 +110,12 @@ loop:
 			// at this point, len(addrs) > 0 or else it would be error from addrsForDial
 			// ranke them to process in order
 			addrs = w.rankAddrs(addrs)
+			if limit := w.s.dialAddrLimit(req.ctx); limit > 0 && len(addrs) > limit {
+				if w.s.dialCapTracer != nil {
+					w.s.dialCapTracer.DialCapped(dialCapAddrs, len(addrs)-limit)
+				}
+				addrs = addrs[:limit]
+			}
 
 			// create the pending request object
 			pr := &pendRequest{
@@ -135,7 +147,7 @@ loop:
 
 				if ad.err != nil {
 					// dial to this addr errored, accumulate the error
-					pr.err.recordErr(a, ad.err)
+					pr.err.recordErr(a, ad.synthesizedFrom, ad.err)
 					delete(pr.addrs, a)
 					continue
 				}
@@ -167,7 +179,11 @@ loop:
 
 			if len(todial) > 0 {
 				for _, a := range todial {
-					w.pending[a] = &addrDial{addr: a, ctx: req.ctx, requests: []int{w.reqno}}
+					ad := &addrDial{addr: a, ctx: req.ctx, requests: []int{w.reqno}}
+					if w.s.nat64 != nil {
+						ad.synthesizedFrom = w.s.nat64.origin(a)
+					}
+					w.pending[a] = ad
 				}
 
 				w.nextDial = append(w.nextDial, todial...)
@@ -199,7 +215,10 @@ loop:
 
 			if res.Conn != nil {
 				// we got a connection, add it to the swarm
-				conn, err := w.s.addConn(res.Conn, network.DirOutbound)
+				conn, err := w.s.addConn(res.Conn, network.DirOutbound, identifyOverrides{
+					userAgent:       network.GetUserAgent(ad.ctx),
+					protocolVersion: network.GetProtocolVersion(ad.ctx),
+				})
 				if err != nil {
 					// oops no, we failed to add it to the swarm
 					res.Conn.Close()
@@ -222,6 +241,10 @@ loop:
 				ad.conn = conn
 				ad.requests = nil
 
+				if ad.synthesizedFrom != nil {
+					log.Debugw("connected using NAT64 address", "peer", w.peer, "addr", ad.addr, "synthesizedFrom", ad.synthesizedFrom)
+				}
+
 				continue loop
 			}
 
@@ -248,7 +271,7 @@ func (w *dialWorker) dispatchError(ad *addrDial, err error) {
 		}
 
 		// accumulate the error
-		pr.err.recordErr(ad.addr, err)
+		pr.err.recordErr(ad.addr, ad.synthesizedFrom, err)
 
 		delete(pr.addrs, ad.addr)
 		if len(pr.addrs) == 0 {
@@ -311,6 +334,96 @@ func (w *dialWorker) rankAddrs(addrs []ma.Multiaddr) []ma.Multiaddr {
 	for _, tier := range tiers {
 		result = append(result, tier...)
 	}
+	w.rankByDialHistory(result)
+	w.rankRelaysByScore(result)
 
 	return result
 }
+
+// recentDialSuccess is the time during which a successful dial to an address makes
+// it preferred over the addresses that were never dialed.
+const recentDialSuccess = time.Hour
+
+// rankByDialHistory reorders addresses ranked by rankAddrs using the outcome of the
+// previous dials, keeping relay addresses after the other addresses:
+// Last dial succeeded recently > Never dialed, or succeeded long ago > Last dial failed
+// Among the addresses that succeeded, the ones with a higher success rate come first,
+// then the ones with the shortest handshake.
+func (w *dialWorker) rankByDialHistory(addrs []ma.Multiaddr) {
+	if w.s.dialHistory == nil {
+		return
+	}
+
+	type rankedAddr struct {
+		addr  ma.Multiaddr
+		relay bool
+		class int
+		stats peerstore.DialStats
+	}
+	now := time.Now()
+	ranked := make([]rankedAddr, 0, len(addrs))
+	for _, a := range addrs {
+		r := rankedAddr{addr: a, relay: isRelayAddr(a), class: 1, stats: w.s.dialHistory.DialStats(w.peer, a)}
+		if r.stats.FailuresSinceSuccess > 0 {
+			r.class = 2
+		} else if r.stats.Successes > 0 && now.Sub(r.stats.LastSuccess) < recentDialSuccess {
+			r.class = 0
+		}
+		ranked = append(ranked, r)
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		a, b := ranked[i], ranked[j]
+		if a.relay != b.relay {
+			return !a.relay
+		}
+		if a.class != b.class {
+			return a.class < b.class
+		}
+		if a.class != 0 {
+			return false
+		}
+		if ra, rb := a.stats.SuccessRate(), b.stats.SuccessRate(); ra != rb {
+			return ra > rb
+		}
+		return a.stats.MedianHandshake < b.stats.MedianHandshake
+	})
+	for i, r := range ranked {
+		addrs[i] = r.addr
+	}
+}
+
+// rankRelaysByScore reorders the relay addresses, which come last, such that the
+// relays with a higher score are dialed first. The order of relay addresses with
+// the same score is kept.
+func (w *dialWorker) rankRelaysByScore(addrs []ma.Multiaddr) {
+	if w.s.scores == nil {
+		return
+	}
+
+	first := len(addrs)
+	for first > 0 && isRelayAddr(addrs[first-1]) {
+		first--
+	}
+	relayAddrs := addrs[first:]
+	if len(relayAddrs) < 2 {
+		return
+	}
+
+	scores := make(map[peer.ID]float64)
+	relayScore := func(a ma.Multiaddr) float64 {
+		relayAddr, _ := ma.SplitFunc(a, func(c ma.Component) bool { return c.Protocol().Code == ma.P_CIRCUIT })
+		_, relay := peer.SplitAddr(relayAddr)
+		if relay == "" {
+			return 0
+		}
+		score, ok := scores[relay]
+		if !ok {
+			score = w.s.scores.Score(relay)
+			scores[relay] = score
+		}
+		return score
+	}
+	sort.SliceStable(relayAddrs, func(i, j int) bool {
+		return relayScore(relayAddrs[i]) > relayScore(relayAddrs[j])
+	})
+}
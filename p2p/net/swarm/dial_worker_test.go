@@ -134,6 +134,95 @@ func TestDialWorkerLoopBasic(t *testing.T) {
 	worker.wg.Wait()
 }
 
+func TestDialRecordsDialHistory(t *testing.T) {
+	s1 := makeSwarm(t)
+	s2 := makeSwarm(t)
+	defer s1.Close()
+	defer s2.Close()
+
+	addr := s2.ListenAddresses()[0]
+	s1.Peerstore().AddAddrs(s2.LocalPeer(), []ma.Multiaddr{addr}, peerstore.PermanentAddrTTL)
+	_, err := s1.DialPeer(context.Background(), s2.LocalPeer())
+	require.NoError(t, err)
+
+	dhb, ok := peerstore.GetDialHistoryBook(s1.Peerstore())
+	require.True(t, ok)
+	stats := dhb.DialStats(s2.LocalPeer(), addr)
+	require.Equal(t, 1, stats.Successes)
+	require.Positive(t, stats.MedianHandshake)
+}
+
+func TestRankAddrsByDialHistory(t *testing.T) {
+	_, p := newPeer(t)
+	dhb := pstoremem.NewDialHistoryBook()
+	w := &dialWorker{s: &Swarm{dialHistory: dhb}, peer: p}
+
+	quicAddr := ma.StringCast("/ip4/1.2.3.4/udp/1/quic-v1")
+	failed := ma.StringCast("/ip4/1.2.3.4/udp/2/quic-v1")
+	tcpAddr := ma.StringCast("/ip4/1.2.3.4/tcp/1")
+	fastTCPAddr := ma.StringCast("/ip4/1.2.3.4/tcp/2")
+	relayAddr := ma.StringCast("/ip4/1.2.3.5/tcp/1/p2p/QmcgpsyWgH8Y8ajJz1Cu72KnS5uo2Aa2LpzU7kinSupNKC/p2p-circuit")
+	addrs := []ma.Multiaddr{relayAddr, tcpAddr, fastTCPAddr, failed, quicAddr}
+
+	// without history, QUIC is preferred over TCP, and relay addresses come last
+	require.Equal(t, []ma.Multiaddr{failed, quicAddr, tcpAddr, fastTCPAddr, relayAddr}, w.rankAddrs(addrs))
+
+	dhb.RecordDial(p, failed, false, 0)
+	dhb.RecordDial(p, tcpAddr, true, 100*time.Millisecond)
+	dhb.RecordDial(p, fastTCPAddr, true, 10*time.Millisecond)
+	dhb.RecordDial(p, relayAddr, true, 10*time.Millisecond)
+	require.Equal(t, []ma.Multiaddr{fastTCPAddr, tcpAddr, quicAddr, failed, relayAddr}, w.rankAddrs(addrs))
+
+	// a higher success rate wins over a faster handshake
+	dhb.RecordDial(p, fastTCPAddr, false, 0)
+	dhb.RecordDial(p, fastTCPAddr, true, 10*time.Millisecond)
+	require.Equal(t, []ma.Multiaddr{tcpAddr, fastTCPAddr, quicAddr, failed, relayAddr}, w.rankAddrs(addrs))
+
+	// an address that failed since it last succeeded comes after the addresses that were never dialed
+	dhb.RecordDial(p, tcpAddr, false, 0)
+	require.Equal(t, []ma.Multiaddr{fastTCPAddr, quicAddr, failed, tcpAddr, relayAddr}, w.rankAddrs(addrs))
+}
+
+type staticDialHistory map[string]peerstore.DialStats
+
+func (h staticDialHistory) RecordDial(peer.ID, ma.Multiaddr, bool, time.Duration) {}
+func (h staticDialHistory) DialStats(_ peer.ID, a ma.Multiaddr) peerstore.DialStats {
+	return h[string(a.Bytes())]
+}
+
+func TestRankAddrsByDialHistoryOldSuccess(t *testing.T) {
+	_, p := newPeer(t)
+	quicAddr := ma.StringCast("/ip4/1.2.3.4/udp/1/quic-v1")
+	tcpAddr := ma.StringCast("/ip4/1.2.3.4/tcp/1")
+	history := staticDialHistory{
+		string(tcpAddr.Bytes()): {Successes: 10, LastSuccess: time.Now().Add(-2 * recentDialSuccess)},
+	}
+	w := &dialWorker{s: &Swarm{dialHistory: history}, peer: p}
+
+	// a success long ago doesn't make the address preferred over a never dialed address
+	require.Equal(t, []ma.Multiaddr{quicAddr, tcpAddr}, w.rankAddrs([]ma.Multiaddr{tcpAddr, quicAddr}))
+	history[string(tcpAddr.Bytes())] = peerstore.DialStats{Successes: 10, LastSuccess: time.Now()}
+	require.Equal(t, []ma.Multiaddr{tcpAddr, quicAddr}, w.rankAddrs([]ma.Multiaddr{tcpAddr, quicAddr}))
+}
+
+func TestRankRelayAddrsByScore(t *testing.T) {
+	_, p := newPeer(t)
+	scores, err := pstoremem.NewScoreBook()
+	require.NoError(t, err)
+	w := &dialWorker{s: &Swarm{scores: scores}, peer: p}
+
+	_, relay1 := newPeer(t)
+	_, relay2 := newPeer(t)
+	tcpAddr := ma.StringCast("/ip4/1.2.3.4/tcp/1")
+	relay1Addr := ma.StringCast("/ip4/1.2.3.5/tcp/1/p2p/" + relay1.String() + "/p2p-circuit")
+	relay2Addr := ma.StringCast("/ip4/1.2.3.6/tcp/1/p2p/" + relay2.String() + "/p2p-circuit")
+	addrs := []ma.Multiaddr{relay1Addr, relay2Addr, tcpAddr}
+
+	require.Equal(t, []ma.Multiaddr{tcpAddr, relay1Addr, relay2Addr}, w.rankAddrs(addrs))
+	scores.RecordObservation(relay2, peerstore.ScoreSourceRelay, 1)
+	require.Equal(t, []ma.Multiaddr{tcpAddr, relay2Addr, relay1Addr}, w.rankAddrs(addrs))
+}
+
 func TestDialWorkerLoopConcurrent(t *testing.T) {
 	s1 := makeSwarm(t)
 	s2 := makeSwarm(t)
@@ -43,18 +43,27 @@ type dialLimiter struct {
 	activePerPeer      map[peer.ID]int
 	perPeerLimit       int
 	waitingOnPeerLimit map[peer.ID][]*dialJob
+
+	dialCapTracer DialCapMetricsTracer // may be nil
 }
 
 type dialfunc func(context.Context, peer.ID, ma.Multiaddr) (transport.CapableConn, error)
 
-func newDialLimiter(df dialfunc) *dialLimiter {
-	fd := ConcurrentFdDials
-	if env := os.Getenv("LIBP2P_SWARM_FD_LIMIT"); env != "" {
-		if n, err := strconv.ParseInt(env, 10, 32); err == nil {
-			fd = int(n)
+// newDialLimiter creates a dial limiter. If fdLimit or perPeerLimit are 0,
+// the defaults are used.
+func newDialLimiter(df dialfunc, fdLimit, perPeerLimit int) *dialLimiter {
+	if fdLimit == 0 {
+		fdLimit = ConcurrentFdDials
+		if env := os.Getenv("LIBP2P_SWARM_FD_LIMIT"); env != "" {
+			if n, err := strconv.ParseInt(env, 10, 32); err == nil {
+				fdLimit = int(n)
+			}
 		}
 	}
-	return newDialLimiterWithParams(df, fd, DefaultPerPeerRateLimit)
+	if perPeerLimit == 0 {
+		perPeerLimit = DefaultPerPeerRateLimit
+	}
+	return newDialLimiterWithParams(df, fdLimit, perPeerLimit)
 }
 
 func newDialLimiterWithParams(df dialfunc, fdLimit, perPeerLimit int) *dialLimiter {
@@ -85,6 +94,7 @@ func (dl *dialLimiter) freeFDToken() {
 
 		// Skip over canceled dials instead of queuing up a goroutine.
 		if next.cancelled() {
+			dl.capped(dialCapFd, 1)
 			dl.freePeerToken(next)
 			continue
 		}
@@ -118,6 +128,7 @@ func (dl *dialLimiter) freePeerToken(dj *dialJob) {
 		}
 
 		if next.cancelled() {
+			dl.capped(dialCapPeer, 1)
 			continue
 		}
 
@@ -197,6 +208,9 @@ func (dl *dialLimiter) AddDialJob(dj *dialJob) {
 func (dl *dialLimiter) clearAllPeerDials(p peer.ID) {
 	dl.lk.Lock()
 	defer dl.lk.Unlock()
+	if n := len(dl.waitingOnPeerLimit[p]); n > 0 {
+		dl.capped(dialCapPeer, n)
+	}
 	delete(dl.waitingOnPeerLimit, p)
 	log.Debugf("[limiter] clearing all peer dials: %v", p)
 	// NB: the waitingOnFd list doesn't need to be cleaned out here, we will
@@ -204,6 +218,13 @@ func (dl *dialLimiter) clearAllPeerDials(p peer.ID) {
 	// point
 }
 
+// capped records n dials that were canceled while waiting on the given limit.
+func (dl *dialLimiter) capped(reason string, n int) {
+	if dl.dialCapTracer != nil {
+		dl.dialCapTracer.DialCapped(reason, n)
+	}
+}
+
 // executeDial calls the dialFunc, and reports the result through the response
 // channel when finished. Once the response is sent it also releases all tokens
 // it held during the dial.
@@ -16,6 +16,7 @@ import (
 
 	ma "github.com/multiformats/go-multiaddr"
 	mafmt "github.com/multiformats/go-multiaddr-fmt"
+	"github.com/stretchr/testify/require"
 )
 
 func addrWithPort(p int) ma.Multiaddr {
@@ -395,3 +396,54 @@ func TestFDLimitUnderflow(t *testing.T) {
 		t.Fatalf("l.fdConsuming < 0")
 	}
 }
+
+func TestNewDialLimiterLimits(t *testing.T) {
+	l := newDialLimiter(nil, 0, 0)
+	require.Equal(t, DefaultPerPeerRateLimit, l.perPeerLimit)
+
+	l = newDialLimiter(nil, 5, 2)
+	require.Equal(t, 5, l.fdLimit)
+	require.Equal(t, 2, l.perPeerLimit)
+}
+
+type cappedDialsTracer struct {
+	mx     sync.Mutex
+	capped map[string]int
+}
+
+func (t *cappedDialsTracer) DialCapped(reason string, n int) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	t.capped[reason] += n
+}
+
+func (t *cappedDialsTracer) get(reason string) int {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	return t.capped[reason]
+}
+
+func TestLimiterReportsCappedDials(t *testing.T) {
+	hang := make(chan struct{})
+	defer close(hang)
+	df := func(ctx context.Context, p peer.ID, a ma.Multiaddr) (transport.CapableConn, error) {
+		<-hang
+		return nil, fmt.Errorf("test bad dial")
+	}
+	tracer := &cappedDialsTracer{capped: make(map[string]int)}
+	l := newDialLimiterWithParams(df, 10, 1)
+	l.dialCapTracer = tracer
+
+	pid := peer.ID("testpeer")
+	resch := make(chan dialResult, 3)
+	tryDialAddrs(context.Background(), l, pid, []ma.Multiaddr{addrWithPort(1), addrWithPort(2), addrWithPort(3)}, resch)
+
+	// the first dial is in progress, the other two wait on the per-peer limit
+	l.lk.Lock()
+	require.Len(t, l.waitingOnPeerLimit[pid], 2)
+	l.lk.Unlock()
+
+	l.clearAllPeerDials(pid)
+	require.Equal(t, 2, tracer.get(dialCapPeer))
+	require.Zero(t, tracer.get(dialCapFd))
+}
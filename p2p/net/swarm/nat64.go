@@ -0,0 +1,323 @@
+package swarm
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"sync"
+	"time"
+
+	ma "github.com/multiformats/go-multiaddr"
+	madns "github.com/multiformats/go-multiaddr-dns"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+// nat64DiscoveryName is the well-known name used to discover the NAT64 prefix
+// of the local network, see RFC 7050.
+const nat64DiscoveryName = "ipv4only.arpa"
+
+// nat64RefreshInterval is how long we cache the result of IPv6-only detection
+// and prefix discovery. Mobile devices change networks frequently, so we
+// don't want to cache this forever.
+const nat64RefreshInterval = 5 * time.Minute
+
+// WellKnownNAT64Prefix is the Well-Known Prefix defined in RFC 6052.
+var WellKnownNAT64Prefix = netip.MustParsePrefix("64:ff9b::/96")
+
+// The well-known IPv4 addresses that ipv4only.arpa resolves to (RFC 7050).
+var nat64WellKnownIPv4 = []netip.Addr{
+	netip.MustParseAddr("192.0.0.170"),
+	netip.MustParseAddr("192.0.0.171"),
+}
+
+var errInvalidNAT64Prefix = errors.New("invalid NAT64 prefix: length must be one of 32, 40, 48, 56, 64 or 96")
+
+// SynthesizeNAT64Addr embeds an IPv4 address into the given NAT64 prefix,
+// as described in RFC 6052, section 2.2.
+func SynthesizeNAT64Addr(prefix netip.Prefix, ip netip.Addr) (netip.Addr, error) {
+	if !prefix.Addr().Is6() || !isValidNAT64PrefixLen(prefix.Bits()) {
+		return netip.Addr{}, errInvalidNAT64Prefix
+	}
+	if !ip.Is4() {
+		return netip.Addr{}, errors.New("not an IPv4 address")
+	}
+	out := prefix.Masked().Addr().As16()
+	v4 := ip.As4()
+	idx := prefix.Bits() / 8
+	for _, b := range v4 {
+		// bits 64 to 71 (the "u" octet) must be zero
+		if idx == 8 {
+			idx++
+		}
+		out[idx] = b
+		idx++
+	}
+	return netip.AddrFrom16(out), nil
+}
+
+// extractNAT64Addr is the inverse of SynthesizeNAT64Addr.
+func extractNAT64Addr(prefix netip.Prefix, ip netip.Addr) (netip.Addr, bool) {
+	if !ip.Is6() || !prefix.Contains(ip) || !isValidNAT64PrefixLen(prefix.Bits()) {
+		return netip.Addr{}, false
+	}
+	in := ip.As16()
+	var v4 [4]byte
+	idx := prefix.Bits() / 8
+	for i := range v4 {
+		if idx == 8 {
+			idx++
+		}
+		v4[i] = in[idx]
+		idx++
+	}
+	return netip.AddrFrom4(v4), true
+}
+
+func isValidNAT64PrefixLen(l int) bool {
+	switch l {
+	case 32, 40, 48, 56, 64, 96:
+		return true
+	default:
+		return false
+	}
+}
+
+// DiscoverNAT64Prefixes discovers the NAT64 prefixes used by the local network
+// by resolving the AAAA records of ipv4only.arpa, as described in RFC 7050.
+// It returns an empty list if the network doesn't use DNS64.
+func DiscoverNAT64Prefixes(ctx context.Context, resolver *madns.Resolver) ([]netip.Prefix, error) {
+	addrs, err := resolver.LookupIPAddr(ctx, nat64DiscoveryName)
+	if err != nil {
+		return nil, err
+	}
+	var prefixes []netip.Prefix
+	for _, a := range addrs {
+		ip, ok := netip.AddrFromSlice(a.IP)
+		if !ok || ip.Is4() || ip.Is4In6() {
+			continue
+		}
+		if p, ok := nat64PrefixFromAddr(ip); ok && !containsPrefix(prefixes, p) {
+			prefixes = append(prefixes, p)
+		}
+	}
+	return prefixes, nil
+}
+
+// nat64PrefixFromAddr finds the prefix used to synthesize ip from one of the
+// well-known IPv4 addresses. The longest matching prefix wins.
+func nat64PrefixFromAddr(ip netip.Addr) (netip.Prefix, bool) {
+	for _, l := range []int{96, 64, 56, 48, 40, 32} {
+		p, err := ip.Prefix(l)
+		if err != nil {
+			continue
+		}
+		v4, ok := extractNAT64Addr(p, ip)
+		if !ok {
+			continue
+		}
+		for _, wk := range nat64WellKnownIPv4 {
+			if v4 == wk {
+				return p, true
+			}
+		}
+	}
+	return netip.Prefix{}, false
+}
+
+func containsPrefix(prefixes []netip.Prefix, p netip.Prefix) bool {
+	for _, pp := range prefixes {
+		if pp == p {
+			return true
+		}
+	}
+	return false
+}
+
+// isIPv6Only checks if the given interface addresses describe an IPv6-only
+// network: there's at least one global IPv6 address, and no IPv4 address that
+// could be used to reach the internet.
+func isIPv6Only(addrs []ma.Multiaddr) bool {
+	var hasIPv4, hasIPv6 bool
+	for _, a := range addrs {
+		ip, err := manet.ToIP(a)
+		if err != nil {
+			continue
+		}
+		if ip.IsLoopback() || ip.IsLinkLocalUnicast() {
+			continue
+		}
+		if ip.To4() != nil {
+			hasIPv4 = true
+		} else if ip.IsGlobalUnicast() {
+			hasIPv6 = true
+		}
+	}
+	return hasIPv6 && !hasIPv4
+}
+
+// nat64 synthesizes IPv6 addresses for IPv4-only peers when we're on an
+// IPv6-only network.
+type nat64 struct {
+	resolver       *madns.Resolver
+	interfaceAddrs func() ([]ma.Multiaddr, error)
+	static         []netip.Prefix
+
+	mx        sync.Mutex
+	prefixes  []netip.Prefix
+	ipv6Only  bool
+	checkedAt time.Time
+	// refresh is the detection currently running, if any.
+	// Concurrent callers wait for it instead of starting their own.
+	refresh *nat64Refresh
+}
+
+type nat64Refresh struct {
+	done     chan struct{}
+	prefixes []netip.Prefix // only valid once done is closed
+}
+
+func newNAT64(resolver *madns.Resolver, static []netip.Prefix) *nat64 {
+	return &nat64{
+		resolver:       resolver,
+		interfaceAddrs: manet.InterfaceMultiaddrs,
+		static:         static,
+	}
+}
+
+// state returns the prefixes to use for synthesis.
+// It returns nil if we're not on an IPv6-only network.
+func (n *nat64) state(ctx context.Context) []netip.Prefix {
+	n.mx.Lock()
+	if !n.checkedAt.IsZero() && time.Since(n.checkedAt) < nat64RefreshInterval {
+		defer n.mx.Unlock()
+		if !n.ipv6Only {
+			return nil
+		}
+		return n.prefixes
+	}
+	if r := n.refresh; r != nil {
+		n.mx.Unlock()
+		select {
+		case <-r.done:
+			return r.prefixes
+		case <-ctx.Done():
+			return nil
+		}
+	}
+	r := &nat64Refresh{done: make(chan struct{})}
+	n.refresh = r
+	n.mx.Unlock()
+
+	// Detection involves a DNS query, so we run it without holding the lock.
+	ipv6Only, prefixes, ok := n.detect(ctx)
+
+	n.mx.Lock()
+	// Failures are not cached: we'll try again on the next dial.
+	if ok {
+		n.ipv6Only = ipv6Only
+		n.prefixes = prefixes
+		n.checkedAt = time.Now()
+	}
+	n.refresh = nil
+	n.mx.Unlock()
+
+	r.prefixes = prefixes
+	close(r.done)
+	return prefixes
+}
+
+// detect checks if we're on an IPv6-only network, and if so, which NAT64 prefixes
+// to use. It returns false if the detection failed.
+func (n *nat64) detect(ctx context.Context) (ipv6Only bool, prefixes []netip.Prefix, ok bool) {
+	ifaddrs, err := n.interfaceAddrs()
+	if err != nil {
+		log.Debugw("failed to get interface addresses for NAT64 detection", "error", err)
+		return false, nil, false
+	}
+	if !isIPv6Only(ifaddrs) {
+		return false, nil, true
+	}
+	if len(n.static) > 0 {
+		return true, n.static, true
+	}
+	prefixes, err = DiscoverNAT64Prefixes(ctx, n.resolver)
+	if err != nil {
+		log.Debugw("NAT64 prefix discovery failed", "error", err)
+		return true, nil, false
+	}
+	if len(prefixes) > 0 {
+		log.Debugw("detected IPv6-only network with NAT64", "prefixes", prefixes)
+	}
+	return true, prefixes, true
+}
+
+// synthesize replaces all public IPv4 addresses with NAT64 addresses when
+// we're on an IPv6-only network. Otherwise, addrs is returned unchanged.
+func (n *nat64) synthesize(ctx context.Context, addrs []ma.Multiaddr) []ma.Multiaddr {
+	prefixes := n.state(ctx)
+	if len(prefixes) == 0 {
+		return addrs
+	}
+
+	out := make([]ma.Multiaddr, 0, len(addrs))
+	for _, a := range addrs {
+		first, rest := ma.SplitFirst(a)
+		if first == nil || first.Protocol().Code != ma.P_IP4 || !manet.IsPublicAddr(a) {
+			out = append(out, a)
+			continue
+		}
+		ip, ok := netip.AddrFromSlice(first.RawValue())
+		if !ok {
+			out = append(out, a)
+			continue
+		}
+		synth, err := SynthesizeNAT64Addr(prefixes[0], ip)
+		if err != nil {
+			out = append(out, a)
+			continue
+		}
+		c, err := ma.NewComponent("ip6", synth.String())
+		if err != nil {
+			out = append(out, a)
+			continue
+		}
+		if rest == nil {
+			out = append(out, c)
+		} else {
+			out = append(out, c.Encapsulate(rest))
+		}
+	}
+	return out
+}
+
+// origin returns the IPv4 address that addr was synthesized from, or nil if
+// it wasn't synthesized.
+func (n *nat64) origin(addr ma.Multiaddr) ma.Multiaddr {
+	n.mx.Lock()
+	prefixes := n.prefixes
+	n.mx.Unlock()
+
+	first, rest := ma.SplitFirst(addr)
+	if first == nil || first.Protocol().Code != ma.P_IP6 {
+		return nil
+	}
+	ip, ok := netip.AddrFromSlice(first.RawValue())
+	if !ok {
+		return nil
+	}
+	for _, p := range prefixes {
+		v4, ok := extractNAT64Addr(p, ip)
+		if !ok {
+			continue
+		}
+		c, err := ma.NewComponent("ip4", v4.String())
+		if err != nil {
+			return nil
+		}
+		if rest == nil {
+			return c
+		}
+		return c.Encapsulate(rest)
+	}
+	return nil
+}
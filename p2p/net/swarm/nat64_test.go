@@ -0,0 +1,183 @@
+package swarm
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/AstaFrode/go-libp2p/core/test"
+
+	ma "github.com/multiformats/go-multiaddr"
+	madns "github.com/multiformats/go-multiaddr-dns"
+	"github.com/stretchr/testify/require"
+)
+
+// Test vectors from RFC 6052, section 2.4.
+func TestSynthesizeNAT64Addr(t *testing.T) {
+	ip := netip.MustParseAddr("192.0.2.33")
+	for _, tc := range []struct {
+		prefix, expected string
+	}{
+		{"2001:db8::/32", "2001:db8:c000:221::"},
+		{"2001:db8:100::/40", "2001:db8:1c0:2:21::"},
+		{"2001:db8:122::/48", "2001:db8:122:c000:2:2100::"},
+		{"2001:db8:122:300::/56", "2001:db8:122:3c0:0:221::"},
+		{"2001:db8:122:344::/64", "2001:db8:122:344:c0:2:2100:0"},
+		{"2001:db8:122:344::/96", "2001:db8:122:344::c000:221"},
+	} {
+		t.Run(tc.prefix, func(t *testing.T) {
+			prefix := netip.MustParsePrefix(tc.prefix)
+			synth, err := SynthesizeNAT64Addr(prefix, ip)
+			require.NoError(t, err)
+			require.Equal(t, netip.MustParseAddr(tc.expected), synth)
+
+			orig, ok := extractNAT64Addr(prefix, synth)
+			require.True(t, ok)
+			require.Equal(t, ip, orig)
+		})
+	}
+
+	_, err := SynthesizeNAT64Addr(netip.MustParsePrefix("2001:db8::/72"), ip)
+	require.Error(t, err)
+}
+
+func TestDiscoverNAT64Prefixes(t *testing.T) {
+	mockResolver := madns.MockResolver{IP: map[string][]net.IPAddr{
+		nat64DiscoveryName: {
+			{IP: net.ParseIP("64:ff9b::c000:aa")},
+			{IP: net.ParseIP("64:ff9b::c000:ab")},
+			{IP: net.ParseIP("2001:db8:1c0:0:aa::")},
+		},
+	}}
+	resolver, err := madns.NewResolver(madns.WithDefaultResolver(&mockResolver))
+	require.NoError(t, err)
+
+	prefixes, err := DiscoverNAT64Prefixes(context.Background(), resolver)
+	require.NoError(t, err)
+	require.Equal(t, []netip.Prefix{
+		WellKnownNAT64Prefix,
+		netip.MustParsePrefix("2001:db8:100::/40"),
+	}, prefixes)
+}
+
+func TestIsIPv6Only(t *testing.T) {
+	require.True(t, isIPv6Only([]ma.Multiaddr{
+		ma.StringCast("/ip4/127.0.0.1"),
+		ma.StringCast("/ip6/::1"),
+		ma.StringCast("/ip6/fe80::1"),
+		ma.StringCast("/ip6/2001:db8::1"),
+	}))
+	require.False(t, isIPv6Only([]ma.Multiaddr{
+		ma.StringCast("/ip4/192.168.1.2"),
+		ma.StringCast("/ip6/2001:db8::1"),
+	}))
+	require.False(t, isIPv6Only([]ma.Multiaddr{ma.StringCast("/ip6/::1")}))
+}
+
+func TestNAT64Synthesis(t *testing.T) {
+	n := newNAT64(nil, []netip.Prefix{WellKnownNAT64Prefix})
+	ifaddrs := []ma.Multiaddr{ma.StringCast("/ip6/2001:db8::1")}
+	n.interfaceAddrs = func() ([]ma.Multiaddr, error) { return ifaddrs, nil }
+
+	addrs := []ma.Multiaddr{
+		ma.StringCast("/ip4/1.2.3.4/tcp/1234"),
+		ma.StringCast("/ip4/192.168.1.2/tcp/1234"),
+		ma.StringCast("/ip6/2001:db8::2/udp/1234/quic-v1"),
+	}
+	synth := n.synthesize(context.Background(), addrs)
+	require.Equal(t, []ma.Multiaddr{
+		ma.StringCast("/ip6/64:ff9b::102:304/tcp/1234"),
+		ma.StringCast("/ip4/192.168.1.2/tcp/1234"),
+		ma.StringCast("/ip6/2001:db8::2/udp/1234/quic-v1"),
+	}, synth)
+	require.Equal(t, ma.StringCast("/ip4/1.2.3.4/tcp/1234"), n.origin(synth[0]))
+	require.Nil(t, n.origin(synth[2]))
+
+	// we're not on an IPv6-only network any more
+	ifaddrs = append(ifaddrs, ma.StringCast("/ip4/1.1.1.1"))
+	n.checkedAt = time.Now().Add(-nat64RefreshInterval)
+	require.Equal(t, addrs, n.synthesize(context.Background(), addrs))
+}
+
+type nat64TestResolver struct {
+	madns.MockResolver
+	calls   atomic.Int32
+	fail    atomic.Bool
+	release chan struct{}
+}
+
+func (r *nat64TestResolver) LookupIPAddr(ctx context.Context, name string) ([]net.IPAddr, error) {
+	r.calls.Add(1)
+	<-r.release
+	if r.fail.Load() {
+		return nil, errors.New("lookup failed")
+	}
+	return r.MockResolver.LookupIPAddr(ctx, name)
+}
+
+func TestNAT64Discovery(t *testing.T) {
+	mock := &nat64TestResolver{
+		MockResolver: madns.MockResolver{IP: map[string][]net.IPAddr{
+			nat64DiscoveryName: {{IP: net.ParseIP("64:ff9b::c000:aa")}},
+		}},
+		release: make(chan struct{}),
+	}
+	mock.fail.Store(true)
+	resolver, err := madns.NewResolver(madns.WithDefaultResolver(mock))
+	require.NoError(t, err)
+	n := newNAT64(resolver, nil)
+	n.interfaceAddrs = func() ([]ma.Multiaddr, error) {
+		return []ma.Multiaddr{ma.StringCast("/ip6/2001:db8::1")}, nil
+	}
+
+	// concurrent callers share a single discovery
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			require.Empty(t, n.state(context.Background()))
+		}()
+	}
+	require.Eventually(t, func() bool { return mock.calls.Load() == 1 }, time.Second, 10*time.Millisecond)
+	time.Sleep(50 * time.Millisecond) // give the other callers time to join
+	require.EqualValues(t, 1, mock.calls.Load())
+	close(mock.release)
+	wg.Wait()
+
+	// the failure is not cached
+	mock.fail.Store(false)
+	calls := mock.calls.Load()
+	require.Equal(t, []netip.Prefix{WellKnownNAT64Prefix}, n.state(context.Background()))
+	require.Equal(t, calls+1, mock.calls.Load())
+	// but the result of a successful discovery is
+	require.Equal(t, []netip.Prefix{WellKnownNAT64Prefix}, n.state(context.Background()))
+	require.Equal(t, calls+1, mock.calls.Load())
+}
+
+func TestDialErrorRecordsNAT64Synthesis(t *testing.T) {
+	s := newTestSwarmWithResolver(t, nil)
+	s.dialTimeout = 100 * time.Millisecond
+	s.nat64 = newNAT64(nil, []netip.Prefix{WellKnownNAT64Prefix})
+	s.nat64.interfaceAddrs = func() ([]ma.Multiaddr, error) {
+		return []ma.Multiaddr{ma.StringCast("/ip6/2001:db8::1")}, nil
+	}
+
+	p := test.RandPeerIDFatal(t)
+	s.Peerstore().AddAddr(p, ma.StringCast("/ip4/1.2.3.4/tcp/1234"), time.Hour)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := s.DialPeer(ctx, p)
+	require.Error(t, err)
+
+	var dialErr *DialError
+	require.ErrorAs(t, err, &dialErr)
+	require.Len(t, dialErr.DialErrors, 1)
+	require.Equal(t, ma.StringCast("/ip6/64:ff9b::102:304/tcp/1234"), dialErr.DialErrors[0].Address)
+	require.Equal(t, ma.StringCast("/ip4/1.2.3.4/tcp/1234"), dialErr.DialErrors[0].SynthesizedFrom)
+}
@@ -5,12 +5,14 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net/netip"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/AstaFrode/go-libp2p/core/connmgr"
+	"github.com/AstaFrode/go-libp2p/core/event"
 	"github.com/AstaFrode/go-libp2p/core/metrics"
 	"github.com/AstaFrode/go-libp2p/core/network"
 	"github.com/AstaFrode/go-libp2p/core/peer"
@@ -20,6 +22,8 @@ import (
 	logging "github.com/ipfs/go-log/v2"
 	ma "github.com/multiformats/go-multiaddr"
 	madns "github.com/multiformats/go-multiaddr-dns"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
@@ -49,6 +53,14 @@ type Option func(*Swarm) error
 
 // WithConnectionGater sets a connection gater
 func WithConnectionGater(gater connmgr.ConnectionGater) Option {
+	return func(s *Swarm) error {
+		s.gater = connmgr.AsGaterV2(gater)
+		return nil
+	}
+}
+
+// WithConnectionGaterV2 sets a context-aware connection gater
+func WithConnectionGaterV2(gater connmgr.ConnectionGaterV2) Option {
 	return func(s *Swarm) error {
 		s.gater = gater
 		return nil
@@ -92,6 +104,110 @@ func WithDialTimeoutLocal(t time.Duration) Option {
 	}
 }
 
+// WithDialConcurrency sets the maximum number of concurrent outbound dials over
+// transports that consume file descriptors, across all peers.
+// Defaults to ConcurrentFdDials, unless overridden by the LIBP2P_SWARM_FD_LIMIT
+// environment variable.
+func WithDialConcurrency(n int) Option {
+	return func(s *Swarm) error {
+		if n <= 0 {
+			return errors.New("dial concurrency must be positive")
+		}
+		s.dialConcurrency = n
+		return nil
+	}
+}
+
+// WithMaxDialsPerPeer sets the maximum number of concurrent outbound dials to a
+// single peer. Defaults to DefaultPerPeerRateLimit.
+func WithMaxDialsPerPeer(n int) Option {
+	return func(s *Swarm) error {
+		if n <= 0 {
+			return errors.New("max dials per peer must be positive")
+		}
+		s.maxDialsPerPeer = n
+		return nil
+	}
+}
+
+// WithMaxDialAddrs sets the maximum number of addresses attempted when dialing a
+// peer. The addresses are ranked first, so the best addresses are attempted.
+// By default, all addresses are attempted.
+// A lower limit can be set for a single dial using network.WithDialAddrLimit.
+func WithMaxDialAddrs(n int) Option {
+	return func(s *Swarm) error {
+		if n <= 0 {
+			return errors.New("max dial addresses must be positive")
+		}
+		s.maxDialAddrs = n
+		return nil
+	}
+}
+
+// WithNAT64 enables dialing IPv4-only peers from IPv6-only networks.
+// When the swarm detects that it's on an IPv6-only network, it synthesizes
+// NAT64 addresses (RFC 6052) for public IPv4 addresses at dial time.
+// If no prefixes are given, the NAT64 prefix is discovered using RFC 7050.
+func WithNAT64(prefixes ...netip.Prefix) Option {
+	return func(s *Swarm) error {
+		for _, p := range prefixes {
+			if !p.Addr().Is6() || !isValidNAT64PrefixLen(p.Bits()) {
+				return errInvalidNAT64Prefix
+			}
+		}
+		s.nat64Prefixes = prefixes
+		s.enableNAT64 = true
+		return nil
+	}
+}
+
+// WithEventBus sets the event bus used by the swarm to emit events.
+func WithEventBus(bus event.Bus) Option {
+	return func(s *Swarm) error {
+		s.eventBus = bus
+		return nil
+	}
+}
+
+// WithUDPBlackHoleConfig configures the black hole filter for UDP addresses.
+// Defaults to DefaultUDPBlackHoleConfig, which is disabled.
+func WithUDPBlackHoleConfig(cfg BlackHoleConfig) Option {
+	return func(s *Swarm) error {
+		if err := cfg.validate(); err != nil {
+			return err
+		}
+		s.udpBlackHoleConfig = cfg
+		return nil
+	}
+}
+
+// WithIPv6BlackHoleConfig configures the black hole filter for IPv6 addresses.
+// Defaults to DefaultIPv6BlackHoleConfig, which is disabled.
+func WithIPv6BlackHoleConfig(cfg BlackHoleConfig) Option {
+	return func(s *Swarm) error {
+		if err := cfg.validate(); err != nil {
+			return err
+		}
+		s.ipv6BlackHoleConfig = cfg
+		return nil
+	}
+}
+
+// WithListenRetry makes the swarm retry listening on an address when its listener
+// is closed unexpectedly, e.g. because the network interface went away. Retries use
+// an exponential backoff, starting at minBackoff and capped at maxBackoff, and
+// continue until listening succeeds or the swarm is closed.
+func WithListenRetry(minBackoff, maxBackoff time.Duration) Option {
+	return func(s *Swarm) error {
+		if minBackoff <= 0 || maxBackoff < minBackoff {
+			return errors.New("invalid listen retry backoff")
+		}
+		s.listenRetryMinBackoff = minBackoff
+		s.listenRetryMaxBackoff = maxBackoff
+		return nil
+	}
+}
+
 func WithResourceManager(m network.ResourceManager) Option {
 	return func(s *Swarm) error {
 		s.rcmgr = m
@@ -118,6 +234,8 @@ type Swarm struct {
 
 	dialTimeout      time.Duration
 	dialTimeoutLocal time.Duration
+	// dialTimeouts are the timeouts for specific transports and address scopes
+	dialTimeouts map[dialTimeoutKey]time.Duration
 
 	conns struct {
 		sync.RWMutex
@@ -145,6 +263,39 @@ type Swarm struct {
 
 	maResolver *madns.Resolver
 
+	enableNAT64   bool
+	nat64Prefixes []netip.Prefix
+	nat64         *nat64 // nil if NAT64 synthesis is disabled
+
+	udpBlackHoleConfig  BlackHoleConfig
+	ipv6BlackHoleConfig BlackHoleConfig
+	bhd                 *blackHoleDetector
+
+	// nil if the peerstore doesn't record the outcome of dials
+	dialHistory peerstore.DialHistoryBook
+	// nil if the peerstore doesn't keep track of peer scores
+	scores peerstore.ScoreBook
+
+	// dial caps, 0 means the default
+	dialConcurrency int
+	maxDialsPerPeer int
+	maxDialAddrs    int
+
+	// listenRetryMinBackoff and listenRetryMaxBackoff are 0 if listen retries are disabled
+	listenRetryMinBackoff time.Duration
+	listenRetryMaxBackoff time.Duration
+
+	eventBus event.Bus // may be nil
+
+	streamStats *streamStats
+	// emitters for stream and listener events, nil if eventBus is nil
+	emitters struct {
+		streamOpened   event.Emitter
+		streamClosed   event.Emitter
+		listenFailure  event.Emitter
+		listenerClosed event.Emitter
+	}
+
 	// stream handlers
 	streamh atomic.Pointer[network.StreamHandler]
 
@@ -152,7 +303,7 @@ type Swarm struct {
 	dsync   *dialSync
 	backf   DialBackoff
 	limiter *dialLimiter
-	gater   connmgr.ConnectionGater
+	gater   connmgr.ConnectionGaterV2
 
 	closeOnce sync.Once
 	ctx       context.Context // is canceled when Close is called
@@ -160,6 +311,9 @@ type Swarm struct {
 
 	bwc           metrics.Reporter
 	metricsTracer MetricsTracer
+	streamTracer  StreamMetricsTracer  // may be nil
+	dialCapTracer DialCapMetricsTracer // may be nil
+	tracer        trace.Tracer
 }
 
 // NewSwarm constructs a Swarm.
@@ -173,6 +327,11 @@ func NewSwarm(local peer.ID, peers peerstore.Peerstore, opts ...Option) (*Swarm,
 		dialTimeout:      defaultDialTimeout,
 		dialTimeoutLocal: defaultDialTimeoutLocal,
 		maResolver:       madns.DefaultResolver,
+		tracer:           trace.NewNoopTracerProvider().Tracer(tracerName),
+
+		udpBlackHoleConfig:  DefaultUDPBlackHoleConfig,
+		ipv6BlackHoleConfig: DefaultIPv6BlackHoleConfig,
+		streamStats:         newStreamStats(),
 	}
 
 	s.conns.m = make(map[peer.ID][]*Conn)
@@ -188,9 +347,39 @@ func NewSwarm(local peer.ID, peers peerstore.Peerstore, opts ...Option) (*Swarm,
 	if s.rcmgr == nil {
 		s.rcmgr = &network.NullResourceManager{}
 	}
+	s.streamTracer, _ = s.metricsTracer.(StreamMetricsTracer)
+	s.dialCapTracer, _ = s.metricsTracer.(DialCapMetricsTracer)
+
+	if s.enableNAT64 {
+		s.nat64 = newNAT64(s.maResolver, s.nat64Prefixes)
+	}
+
+	s.bhd = newBlackHoleDetector(s.udpBlackHoleConfig, s.ipv6BlackHoleConfig)
+	s.dialHistory, _ = peerstore.GetDialHistoryBook(peers)
+	s.scores, _ = peerstore.GetScoreBook(peers)
+	if s.eventBus != nil {
+		em, err := s.eventBus.Emitter(new(event.EvtBlackHoleStateChanged))
+		if err != nil {
+			return nil, err
+		}
+		s.bhd.emitter = em
+		if s.emitters.streamOpened, err = s.eventBus.Emitter(new(event.EvtStreamOpened)); err != nil {
+			return nil, err
+		}
+		if s.emitters.streamClosed, err = s.eventBus.Emitter(new(event.EvtStreamClosed)); err != nil {
+			return nil, err
+		}
+		if s.emitters.listenFailure, err = s.eventBus.Emitter(new(event.EvtListenFailure)); err != nil {
+			return nil, err
+		}
+		if s.emitters.listenerClosed, err = s.eventBus.Emitter(new(event.EvtListenerClosed)); err != nil {
+			return nil, err
+		}
+	}
 
 	s.dsync = newDialSync(s.dialWorkerLoop)
-	s.limiter = newDialLimiter(s.dialAddr)
+	s.limiter = newDialLimiter(s.dialAddr, s.dialConcurrency, s.maxDialsPerPeer)
+	s.limiter.dialCapTracer = s.dialCapTracer
 	s.backf.init(s.ctx)
 	return s, nil
 }
@@ -264,9 +453,19 @@ func (s *Swarm) close() {
 		}
 	}
 	wg.Wait()
+
+	if s.bhd.emitter != nil {
+		s.bhd.emitter.Close()
+	}
+	if s.emitters.streamOpened != nil {
+		s.emitters.streamOpened.Close()
+		s.emitters.streamClosed.Close()
+		s.emitters.listenFailure.Close()
+		s.emitters.listenerClosed.Close()
+	}
 }
 
-func (s *Swarm) addConn(tc transport.CapableConn, dir network.Direction) (*Conn, error) {
+func (s *Swarm) addConn(tc transport.CapableConn, dir network.Direction, overrides identifyOverrides) (*Conn, error) {
 	var (
 		p    = tc.RemotePeer()
 		addr = tc.RemoteMultiaddr()
@@ -282,22 +481,26 @@ func (s *Swarm) addConn(tc transport.CapableConn, dir network.Direction) (*Conn,
 
 	// Wrap and register the connection.
 	c := &Conn{
-		conn:  tc,
-		swarm: s,
-		stat:  stat,
-		id:    atomic.AddUint64(&s.nextConnID, 1),
+		conn:      tc,
+		swarm:     s,
+		stat:      stat,
+		id:        atomic.AddUint64(&s.nextConnID, 1),
+		overrides: overrides,
+	}
+	if s.metricsTracer != nil {
+		c.transport = transportName(addr)
 	}
 
 	// we ONLY check upgraded connections here so we can send them a Disconnect message.
 	// If we do this in the Upgrader, we will not be able to do this.
 	if s.gater != nil {
-		if allow, _ := s.gater.InterceptUpgraded(c); !allow {
+		if v := s.gater.InterceptUpgraded(s.ctx, c); !v.Wait(s.ctx) {
 			// TODO Send disconnect with reason here
 			err := tc.Close()
 			if err != nil {
 				log.Warnf("failed to close connection with peer %s and addr %s; err: %s", p.Pretty(), addr, err)
 			}
-			return nil, ErrGaterDisallowedConnection
+			return nil, gaterError(v)
 		}
 	}
 
@@ -361,9 +564,12 @@ func (s *Swarm) StreamHandler() network.StreamHandler {
 
 // NewStream creates a new stream on any available connection to peer, dialing
 // if necessary.
-func (s *Swarm) NewStream(ctx context.Context, p peer.ID) (network.Stream, error) {
+func (s *Swarm) NewStream(ctx context.Context, p peer.ID) (_ network.Stream, err error) {
 	log.Debugf("[%s] opening stream to peer [%s]", s.local, p)
 
+	ctx, span := s.startSpan(ctx, "swarm.NewStream", attribute.Stringer("peer", p))
+	defer func() { endSpan(span, err) }()
+
 	// Algorithm:
 	// 1. Find the best connection, otherwise, dial.
 	// 2. Try opening a stream.
@@ -484,24 +690,67 @@ func (s *Swarm) bestConnToPeer(p peer.ID) *Conn {
 // - Returns nothing if no such connection exists, but if we should try dialing anyways.
 // - Returns an error if no such connection exists, but we should not try dialing.
 func (s *Swarm) bestAcceptableConnToPeer(ctx context.Context, p peer.ID) (*Conn, error) {
-	conn := s.bestConnToPeer(p)
+	forceDirect, _ := network.GetForceDirectDial(ctx)
+	forceRelay, _ := network.GetForceRelayDial(ctx)
+	if forceDirect && forceRelay {
+		return nil, errors.New("cannot force both a direct and a relayed connection")
+	}
+	protocols := network.GetDialTransports(ctx)
+
+	var conn *Conn
+	if !forceRelay && protocols == nil {
+		conn = s.bestConnToPeer(p)
+	} else {
+		conn = s.bestConnToPeerFunc(p, func(c *Conn) bool {
+			if forceRelay && isDirectConn(c) {
+				return false
+			}
+			return protocols == nil || hasAnyProtocol(c.RemoteMultiaddr(), protocols)
+		})
+	}
 	if conn == nil {
 		return nil, nil
 	}
 
-	forceDirect, _ := network.GetForceDirectDial(ctx)
 	if forceDirect && !isDirectConn(conn) {
 		return nil, nil
 	}
 
 	useTransient, _ := network.GetUseTransient(ctx)
-	if useTransient || !conn.Stat().Transient {
+	if useTransient || forceRelay || !conn.Stat().Transient {
 		return conn, nil
 	}
 
 	return nil, network.ErrTransientConn
 }
 
+// bestConnToPeerFunc is like bestConnToPeer, but only considers the connections accepted by f.
+func (s *Swarm) bestConnToPeerFunc(p peer.ID, f func(*Conn) bool) *Conn {
+	s.conns.RLock()
+	defer s.conns.RUnlock()
+
+	var best *Conn
+	for _, c := range s.conns.m[p] {
+		if c.conn.IsClosed() || !f(c) {
+			continue
+		}
+		if best == nil || isBetterConn(c, best) {
+			best = c
+		}
+	}
+	return best
+}
+
+// hasAnyProtocol returns true if addr contains any of the multiaddr protocol codes.
+func hasAnyProtocol(addr ma.Multiaddr, protocols []int) bool {
+	for _, p := range protocols {
+		if isProtocolAddr(addr, p) {
+			return true
+		}
+	}
+	return false
+}
+
 func isDirectConn(c *Conn) bool {
 	return c != nil && !c.conn.Transport().Proxy()
 }
@@ -616,6 +865,7 @@ func (s *Swarm) removeConn(c *Conn) {
 		if ci == c {
 			if len(cs) == 1 {
 				delete(s.conns.m, p)
+				s.streamStats.removePeer(p)
 			} else {
 				// NOTE: We're intentionally preserving order.
 				// This way, connections to a peer are always
@@ -663,3 +913,14 @@ func (c connWithMetrics) Close() error {
 	c.metricsTracer.ClosedConnection(c.dir, time.Since(c.opened), c.ConnState(), c.LocalMultiaddr())
 	return c.CapableConn.Close()
 }
+
+// BlackHoleStatus returns the status of the enabled black hole filters.
+func (s *Swarm) BlackHoleStatus() []BlackHoleStatus {
+	return s.bhd.Status()
+}
+
+// ResetBlackHoleDetection forgets the results of past dials, forcing the black hole
+// filters to probe again. This is useful after a network change.
+func (s *Swarm) ResetBlackHoleDetection() {
+	s.bhd.Reset()
+}
@@ -14,6 +14,8 @@ import (
 	"github.com/AstaFrode/go-libp2p/core/transport"
 
 	ma "github.com/multiformats/go-multiaddr"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // TODO: Put this elsewhere.
@@ -39,9 +41,28 @@ type Conn struct {
 	}
 
 	stat network.ConnStats
+
+	// the name of the transport, used as a label for metrics
+	transport string
+
+	overrides identifyOverrides
+}
+
+// identifyOverrides are the values announced via identify on a connection, overriding
+// the host-wide values. Empty values are not overridden.
+type identifyOverrides struct {
+	userAgent       string
+	protocolVersion string
 }
 
 var _ network.Conn = &Conn{}
+var _ network.ConnIdentifyOverrides = &Conn{}
+
+// IdentifyOverrides returns the user agent and the protocol version set using
+// network.WithUserAgent and network.WithProtocolVersion when dialing the connection.
+func (c *Conn) IdentifyOverrides() (userAgent, protocolVersion string) {
+	return c.overrides.userAgent, c.overrides.protocolVersion
+}
 
 func (c *Conn) ID() string {
 	// format: <first 10 chars of peer id>-<global conn ordinal>
@@ -55,11 +76,20 @@ func (c *Conn) ID() string {
 // open notifications must finish before we can fire off the close
 // notifications).
 func (c *Conn) Close() error {
-	c.closeOnce.Do(c.doClose)
+	c.closeOnce.Do(func() { c.doClose(c.conn.Close) })
 	return c.err
 }
 
-func (c *Conn) doClose() {
+// CloseWithError closes the connection like Close, sending errCode and reason to
+// the remote peer if the transport supports it.
+func (c *Conn) CloseWithError(errCode network.ConnErrorCode, reason string) error {
+	c.closeOnce.Do(func() {
+		c.doClose(func() error { return network.CloseWithError(c.conn, errCode, reason) })
+	})
+	return c.err
+}
+
+func (c *Conn) doClose(closeConn func() error) {
 	c.swarm.removeConn(c)
 
 	// Prevent new streams from opening.
@@ -68,7 +98,7 @@ func (c *Conn) doClose() {
 	c.streams.m = nil
 	c.streams.Unlock()
 
-	c.err = c.conn.Close()
+	c.err = closeConn()
 
 	// This is just for cleaning up state. The connection has already been closed.
 	// We *could* optimize this but it really isn't worth it.
@@ -118,7 +148,7 @@ func (c *Conn) start() {
 			}
 			c.swarm.refs.Add(1)
 			go func() {
-				s, err := c.addStream(ts, network.DirInbound, scope)
+				s, err := c.addStream(context.Background(), ts, network.DirInbound, scope)
 
 				// Don't defer this. We don't want to block
 				// swarm shutdown on the connection handler.
@@ -217,10 +247,10 @@ func (c *Conn) openAndAddStream(ctx context.Context, scope network.StreamManagem
 	if err != nil {
 		return nil, err
 	}
-	return c.addStream(ts, network.DirOutbound, scope)
+	return c.addStream(ctx, ts, network.DirOutbound, scope)
 }
 
-func (c *Conn) addStream(ts network.MuxedStream, dir network.Direction, scope network.StreamManagementScope) (*Stream, error) {
+func (c *Conn) addStream(ctx context.Context, ts network.MuxedStream, dir network.Direction, scope network.StreamManagementScope) (*Stream, error) {
 	c.streams.Lock()
 	// Are we still online?
 	if c.streams.m == nil {
@@ -238,11 +268,20 @@ func (c *Conn) addStream(ts network.MuxedStream, dir network.Direction, scope ne
 			Direction: dir,
 			Opened:    time.Now(),
 		},
-		id: atomic.AddUint64(&c.swarm.nextStreamID, 1),
+		id:           atomic.AddUint64(&c.swarm.nextStreamID, 1),
+		peerCounters: c.swarm.streamStats.openStream(c.RemotePeer()),
 	}
 	c.stat.NumStreams++
 	c.streams.m[s] = struct{}{}
 
+	// The stream span outlives the context used to open the stream, so we only
+	// keep the span, not the deadline or cancellation of ctx.
+	_, s.span = c.swarm.startSpan(ctx, "swarm.Stream",
+		attribute.Stringer("peer", c.RemotePeer()),
+		attribute.Stringer("direction", dir),
+	)
+	s.ctx = trace.ContextWithSpan(context.Background(), s.span)
+
 	// Released once the stream disconnect notifications have finished
 	// firing (in Swarm.remove).
 	c.swarm.refs.Add(1)
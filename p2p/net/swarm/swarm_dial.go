@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/AstaFrode/go-libp2p/core/canonicallog"
+	"github.com/AstaFrode/go-libp2p/core/connmgr"
 	"github.com/AstaFrode/go-libp2p/core/network"
 	"github.com/AstaFrode/go-libp2p/core/peer"
 	"github.com/AstaFrode/go-libp2p/core/peerstore"
@@ -16,6 +17,7 @@ import (
 	madns "github.com/multiformats/go-multiaddr-dns"
 	manet "github.com/multiformats/go-multiaddr/net"
 	"github.com/quic-go/quic-go"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // The maximum number of address resolution steps we'll perform for a single
@@ -61,6 +63,15 @@ var (
 	ErrGaterDisallowedConnection = errors.New("gater disallows connection to peer")
 )
 
+// gaterError returns the error for a connection rejected by the gater,
+// including the reason given by the gater, if any.
+func gaterError(v connmgr.Verdict) error {
+	if v.Reason == "" {
+		return ErrGaterDisallowedConnection
+	}
+	return fmt.Errorf("%w: %s", ErrGaterDisallowedConnection, v.Reason)
+}
+
 // DialAttempts governs how many times a goroutine will try to dial a given peer.
 // Note: this is down to one, as we have _too many dials_ atm. To add back in,
 // add loop back in Dial(.)
@@ -74,6 +85,27 @@ const ConcurrentFdDials = 160
 // per peer
 var DefaultPerPeerRateLimit = 8
 
+// Reasons for dials being capped, as reported to the MetricsTracer.
+const (
+	// dialCapAddrs is used for addresses not dialed because of the address limit
+	dialCapAddrs = "addr_limit"
+	// dialCapPeer is used for dials canceled while waiting on the per-peer limit
+	dialCapPeer = "peer_limit"
+	// dialCapFd is used for dials canceled while waiting on the file descriptor limit
+	dialCapFd = "fd_limit"
+)
+
+// dialAddrLimit returns the maximum number of addresses to dial for a dial
+// request, taking into account both the swarm's limit and the limit set on ctx.
+// It returns 0 if there's no limit.
+func (s *Swarm) dialAddrLimit(ctx context.Context) int {
+	limit := s.maxDialAddrs
+	if l := network.GetDialAddrLimit(ctx); l > 0 && (limit == 0 || l < limit) {
+		limit = l
+	}
+	return limit
+}
+
 // dialbackoff is a struct used to avoid over-dialing the same, dead peers.
 // Whenever we totally time out on a peer (all three attempts), we add them
 // to dialbackoff. Then, whenevers goroutines would _wait_ (dialsync), they
@@ -239,13 +271,16 @@ func (s *Swarm) DialPeer(ctx context.Context, p peer.ID) (network.Conn, error) {
 //
 // It is gated by the swarm's dial synchronization systems: dialsync and
 // dialbackoff.
-func (s *Swarm) dialPeer(ctx context.Context, p peer.ID) (*Conn, error) {
+func (s *Swarm) dialPeer(ctx context.Context, p peer.ID) (_ *Conn, err error) {
 	log.Debugw("dialing peer", "from", s.local, "to", p)
-	err := p.Validate()
+	err = p.Validate()
 	if err != nil {
 		return nil, err
 	}
 
+	ctx, span := s.startSpan(ctx, "swarm.DialPeer", attribute.Stringer("peer", p))
+	defer func() { endSpan(span, err) }()
+
 	if p == s.local {
 		return nil, ErrDialToSelf
 	}
@@ -257,9 +292,11 @@ func (s *Swarm) dialPeer(ctx context.Context, p peer.ID) (*Conn, error) {
 		return conn, err
 	}
 
-	if s.gater != nil && !s.gater.InterceptPeerDial(p) {
-		log.Debugf("gater disallowed outbound connection to peer %s", p.Pretty())
-		return nil, &DialError{Peer: p, Cause: ErrGaterDisallowedConnection}
+	if s.gater != nil {
+		if v := s.gater.InterceptPeerDial(ctx, p); !v.Wait(ctx) {
+			log.Debugf("gater disallowed outbound connection to peer %s: %s", p.Pretty(), v)
+			return nil, &DialError{Peer: p, Cause: gaterError(v)}
+		}
 	}
 
 	// apply the DialPeer timeout
@@ -299,7 +336,10 @@ func (s *Swarm) dialWorkerLoop(p peer.ID, reqch <-chan dialRequest) {
 	w.loop()
 }
 
-func (s *Swarm) addrsForDial(ctx context.Context, p peer.ID) ([]ma.Multiaddr, error) {
+func (s *Swarm) addrsForDial(ctx context.Context, p peer.ID) (_ []ma.Multiaddr, err error) {
+	ctx, span := s.startSpan(ctx, "swarm.ResolveAddrs", attribute.Stringer("peer", p))
+	defer func() { endSpan(span, err) }()
+
 	peerAddrs := s.peers.Addrs(p)
 	if len(peerAddrs) == 0 {
 		return nil, ErrNoAddresses
@@ -330,10 +370,25 @@ func (s *Swarm) addrsForDial(ctx context.Context, p peer.ID) ([]ma.Multiaddr, er
 		return nil, err
 	}
 
-	goodAddrs := s.filterKnownUndialables(p, resolved)
+	if s.nat64 != nil {
+		resolved = s.nat64.synthesize(ctx, resolved)
+	}
+
+	goodAddrs := s.filterKnownUndialables(ctx, p, resolved)
 	if forceDirect, _ := network.GetForceDirectDial(ctx); forceDirect {
 		goodAddrs = ma.FilterAddrs(goodAddrs, s.nonProxyAddr)
+	} else {
+		// Direct dials are used for hole punching, which is expected to fail often.
+		// Don't apply the black hole filters to them.
+		goodAddrs = s.bhd.FilterAddrs(goodAddrs)
 	}
+	if forceRelay, _ := network.GetForceRelayDial(ctx); forceRelay {
+		goodAddrs = ma.FilterAddrs(goodAddrs, isRelayAddr)
+	}
+	if protocols := network.GetDialTransports(ctx); protocols != nil {
+		goodAddrs = ma.FilterAddrs(goodAddrs, func(addr ma.Multiaddr) bool { return hasAnyProtocol(addr, protocols) })
+	}
+	span.SetAttributes(attribute.Int("addrs.known", len(peerAddrs)), attribute.Int("addrs.dialable", len(goodAddrs)))
 
 	if len(goodAddrs) == 0 {
 		return nil, ErrNoGoodAddresses
@@ -434,8 +489,9 @@ func (s *Swarm) nonProxyAddr(addr ma.Multiaddr) bool {
 // that we definitely don't want to dial: addresses configured to be blocked,
 // IPv6 link-local addresses, addresses without a dial-capable transport,
 // and addresses that we know to be our own.
+// Delays requested by the connection gater are applied here, concurrently for all addresses.
 // This is an optimization to avoid wasting time on dials that we know are going to fail.
-func (s *Swarm) filterKnownUndialables(p peer.ID, addrs []ma.Multiaddr) []ma.Multiaddr {
+func (s *Swarm) filterKnownUndialables(ctx context.Context, p peer.ID, addrs []ma.Multiaddr) []ma.Multiaddr {
 	lisAddrs, _ := s.InterfaceListenAddresses()
 	var ourAddrs []ma.Multiaddr
 	for _, addr := range lisAddrs {
@@ -448,42 +504,65 @@ func (s *Swarm) filterKnownUndialables(p peer.ID, addrs []ma.Multiaddr) []ma.Mul
 
 	return maybeRemoveWebTransportAddrs(
 		maybeRemoveQUICDraft29(
-			ma.FilterAddrs(addrs,
-				func(addr ma.Multiaddr) bool { return !ma.Contains(ourAddrs, addr) },
-				s.canDial,
-				// TODO: Consider allowing link-local addresses
-				func(addr ma.Multiaddr) bool { return !manet.IsIP6LinkLocal(addr) },
-				func(addr ma.Multiaddr) bool {
-					return s.gater == nil || s.gater.InterceptAddrDial(p, addr)
-				},
-			)))
+			s.gateAddrDials(ctx, p,
+				ma.FilterAddrs(addrs,
+					func(addr ma.Multiaddr) bool { return !ma.Contains(ourAddrs, addr) },
+					s.canDial,
+					// TODO: Consider allowing link-local addresses
+					func(addr ma.Multiaddr) bool { return !manet.IsIP6LinkLocal(addr) },
+				))))
+}
+
+// gateAddrDials consults the connection gater about every address, and removes the
+// addresses it doesn't allow us to dial. The gater may delay addresses: these delays are
+// waited for concurrently, so that the total delay is that of the longest one.
+func (s *Swarm) gateAddrDials(ctx context.Context, p peer.ID, addrs []ma.Multiaddr) []ma.Multiaddr {
+	if s.gater == nil || len(addrs) == 0 {
+		return addrs
+	}
+	allowed := make([]bool, len(addrs))
+	var wg sync.WaitGroup
+	wg.Add(len(addrs))
+	for i, addr := range addrs {
+		go func(i int, addr ma.Multiaddr) {
+			defer wg.Done()
+			allowed[i] = s.gater.InterceptAddrDial(ctx, p, addr).Wait(ctx)
+		}(i, addr)
+	}
+	wg.Wait()
+	res := addrs[:0]
+	for i, addr := range addrs {
+		if allowed[i] {
+			res = append(res, addr)
+		}
+	}
+	return res
 }
 
 // limitedDial will start a dial to the given peer when
 // it is able, respecting the various different types of rate
 // limiting that occur without using extra goroutines per addr
 func (s *Swarm) limitedDial(ctx context.Context, p peer.ID, a ma.Multiaddr, resp chan dialResult) {
-	timeout := s.dialTimeout
-	if lowTimeoutFilters.AddrBlocked(a) && s.dialTimeoutLocal < s.dialTimeout {
-		timeout = s.dialTimeoutLocal
-	}
 	s.limiter.AddDialJob(&dialJob{
 		addr:    a,
 		peer:    p,
 		resp:    resp,
 		ctx:     ctx,
-		timeout: timeout,
+		timeout: s.dialTimeoutFor(a),
 	})
 }
 
 // dialAddr is the actual dial for an addr, indirectly invoked through the limiter
-func (s *Swarm) dialAddr(ctx context.Context, p peer.ID, addr ma.Multiaddr) (transport.CapableConn, error) {
+func (s *Swarm) dialAddr(ctx context.Context, p peer.ID, addr ma.Multiaddr) (_ transport.CapableConn, err error) {
 	// Just to double check. Costs nothing.
 	if s.local == p {
 		return nil, ErrDialToSelf
 	}
 	log.Debugf("%s swarm dialing %s %s", s.local, p, addr)
 
+	ctx, span := s.startSpan(ctx, "swarm.DialAddr", attribute.Stringer("peer", p), attribute.Stringer("addr", addr))
+	defer func() { endSpan(span, err) }()
+
 	tpt := s.TransportForDialing(addr)
 	if tpt == nil {
 		return nil, ErrNoTransport
@@ -491,6 +570,14 @@ func (s *Swarm) dialAddr(ctx context.Context, p peer.ID, addr ma.Multiaddr) (tra
 
 	start := time.Now()
 	connC, err := tpt.Dial(ctx, addr, p)
+	// Canceled dials (e.g. because a dial to another address succeeded) don't
+	// tell us anything about the reachability of the address.
+	if err == nil || ctx.Err() == nil {
+		s.bhd.RecordResult(addr, err == nil)
+		if s.dialHistory != nil {
+			s.dialHistory.RecordDial(p, addr, err == nil, time.Since(start))
+		}
+	}
 	if err != nil {
 		if s.metricsTracer != nil {
 			s.metricsTracer.FailedDialing(addr, err)
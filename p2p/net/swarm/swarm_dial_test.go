@@ -3,6 +3,7 @@ package swarm
 import (
 	"context"
 	"crypto/rand"
+	"fmt"
 	"net"
 	"testing"
 	"time"
@@ -216,3 +217,62 @@ func TestRemoveQuicDraft29(t *testing.T) {
 	require.Equal(t, []ma.Multiaddr{quicV1Addr}, maybeRemoveQUICDraft29([]ma.Multiaddr{quicV1Addr, quicDraft29Addr}))
 	require.Equal(t, []ma.Multiaddr{quicDraft29Addr}, maybeRemoveQUICDraft29([]ma.Multiaddr{quicDraft29Addr}))
 }
+
+func TestAddrsForDialOptions(t *testing.T) {
+	resolver, err := madns.NewResolver()
+	require.NoError(t, err)
+	s := newTestSwarmWithResolver(t, resolver)
+
+	p := test.RandPeerIDFatal(t)
+	addr := ma.StringCast("/ip4/1.2.3.4/tcp/1234")
+	s.peers.AddAddr(p, addr, time.Hour)
+
+	ctx := context.Background()
+	mas, err := s.addrsForDial(network.WithDialTransports(ctx, ma.P_TCP), p)
+	require.NoError(t, err)
+	require.Equal(t, []ma.Multiaddr{addr}, mas)
+
+	_, err = s.addrsForDial(network.WithDialTransports(ctx, ma.P_QUIC_V1), p)
+	require.ErrorIs(t, err, ErrNoGoodAddresses)
+
+	_, err = s.addrsForDial(network.WithForceRelayDial(ctx, "test"), p)
+	require.ErrorIs(t, err, ErrNoGoodAddresses)
+}
+
+func TestDialAddrLimit(t *testing.T) {
+	s := newTestSwarmWithResolver(t, nil)
+	require.Zero(t, s.dialAddrLimit(context.Background()))
+	require.Equal(t, 3, s.dialAddrLimit(network.WithDialAddrLimit(context.Background(), 3)))
+
+	s.maxDialAddrs = 2
+	require.Equal(t, 2, s.dialAddrLimit(context.Background()))
+	require.Equal(t, 2, s.dialAddrLimit(network.WithDialAddrLimit(context.Background(), 3)))
+	require.Equal(t, 1, s.dialAddrLimit(network.WithDialAddrLimit(context.Background(), 1)))
+}
+
+func TestMaxDialAddrs(t *testing.T) {
+	s := newTestSwarmWithResolver(t, nil)
+	s.maxDialAddrs = 2
+
+	p := test.RandPeerIDFatal(t)
+	for i := 1; i <= 4; i++ {
+		s.Peerstore().AddAddr(p, ma.StringCast(fmt.Sprintf("/ip4/127.0.0.1/tcp/%d", i)), time.Hour)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := s.DialPeer(ctx, p)
+	require.Error(t, err)
+
+	var dialErr *DialError
+	require.ErrorAs(t, err, &dialErr)
+	require.Len(t, dialErr.DialErrors, 2)
+}
+
+func TestMaxDialAddrsOption(t *testing.T) {
+	_, err := NewSwarm(test.RandPeerIDFatal(t), nil, WithMaxDialAddrs(0))
+	require.Error(t, err)
+	_, err = NewSwarm(test.RandPeerIDFatal(t), nil, WithMaxDialsPerPeer(-1))
+	require.Error(t, err)
+	_, err = NewSwarm(test.RandPeerIDFatal(t), nil, WithDialConcurrency(0))
+	require.Error(t, err)
+}
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/AstaFrode/go-libp2p/core/canonicallog"
+	"github.com/AstaFrode/go-libp2p/core/event"
 	"github.com/AstaFrode/go-libp2p/core/network"
 	"github.com/AstaFrode/go-libp2p/core/transport"
 
@@ -82,6 +83,7 @@ func (s *Swarm) AddListenAddr(a ma.Multiaddr) error {
 
 	list, err := tpt.Listen(a)
 	if err != nil {
+		s.emitListenFailure(a, err)
 		return err
 	}
 
@@ -104,6 +106,7 @@ func (s *Swarm) AddListenAddr(a ma.Multiaddr) error {
 	})
 
 	go func() {
+		var acceptErr error
 		defer func() {
 			s.listeners.Lock()
 			_, ok := s.listeners.m[list]
@@ -113,20 +116,28 @@ func (s *Swarm) AddListenAddr(a ma.Multiaddr) error {
 			}
 			s.listeners.Unlock()
 
+			var closeErr error
 			if ok {
 				list.Close()
-				log.Errorf("swarm listener unintentionally closed")
+				log.Errorw("swarm listener unintentionally closed", "addr", maddr, "error", acceptErr)
+				closeErr = acceptErr
+				if s.listenRetryMinBackoff > 0 {
+					s.refs.Add(1)
+					go s.retryListen(a)
+				}
 			}
 
 			// signal to our notifiees on listen close.
 			s.notifyAll(func(n network.Notifiee) {
 				n.ListenClose(s, maddr)
 			})
+			s.emitListenerClosed(maddr, closeErr)
 			s.refs.Done()
 		}()
 		for {
 			c, err := list.Accept()
 			if err != nil {
+				acceptErr = err
 				return
 			}
 			canonicallog.LogPeerStatus(100, c.RemotePeer(), c.RemoteMultiaddr(), "connection_status", "established", "dir", "inbound")
@@ -138,7 +149,7 @@ func (s *Swarm) AddListenAddr(a ma.Multiaddr) error {
 			s.refs.Add(1)
 			go func() {
 				defer s.refs.Done()
-				_, err := s.addConn(c, network.DirInbound)
+				_, err := s.addConn(c, network.DirInbound, identifyOverrides{})
 				switch err {
 				case nil:
 				case ErrSwarmClosed:
@@ -154,6 +165,58 @@ func (s *Swarm) AddListenAddr(a ma.Multiaddr) error {
 	return nil
 }
 
+// retryListen tries to listen on a, with exponential backoff, until it succeeds or the swarm is closed.
+//
+// The caller must take a swarm ref before calling. This function decrements the
+// swarm ref count.
+func (s *Swarm) retryListen(a ma.Multiaddr) {
+	defer s.refs.Done()
+
+	backoff := s.listenRetryMinBackoff
+	for {
+		timer := time.NewTimer(backoff)
+		select {
+		case <-s.ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		err := s.AddListenAddr(a)
+		switch err {
+		case nil:
+			log.Infow("listening again after listener was closed", "addr", a)
+			return
+		case ErrSwarmClosed, ErrNoTransport:
+			return
+		}
+		log.Debugw("retrying to listen failed", "addr", a, "error", err, "backoff", backoff)
+
+		backoff *= 2
+		if backoff > s.listenRetryMaxBackoff {
+			backoff = s.listenRetryMaxBackoff
+		}
+	}
+}
+
+func (s *Swarm) emitListenFailure(a ma.Multiaddr, err error) {
+	if s.emitters.listenFailure == nil {
+		return
+	}
+	if err := s.emitters.listenFailure.Emit(event.EvtListenFailure{Addr: a, Error: err}); err != nil {
+		log.Debugw("failed to emit listen failure event", "error", err)
+	}
+}
+
+func (s *Swarm) emitListenerClosed(a ma.Multiaddr, err error) {
+	if s.emitters.listenerClosed == nil {
+		return
+	}
+	if err := s.emitters.listenerClosed.Emit(event.EvtListenerClosed{Addr: a, Error: err}); err != nil {
+		log.Debugw("failed to emit listener closed event", "error", err)
+	}
+}
+
 func containsMultiaddr(addrs []ma.Multiaddr, addr ma.Multiaddr) bool {
 	for _, a := range addrs {
 		if addr == a {
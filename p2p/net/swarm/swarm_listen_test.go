@@ -0,0 +1,137 @@
+package swarm_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AstaFrode/go-libp2p/core/event"
+	"github.com/AstaFrode/go-libp2p/core/peer"
+	"github.com/AstaFrode/go-libp2p/core/transport"
+	"github.com/AstaFrode/go-libp2p/p2p/host/eventbus"
+	"github.com/AstaFrode/go-libp2p/p2p/net/swarm"
+	swarmt "github.com/AstaFrode/go-libp2p/p2p/net/swarm/testing"
+
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/require"
+)
+
+type failingListener struct {
+	addr      ma.Multiaddr
+	closeOnce sync.Once
+	closed    chan struct{}
+	err       chan error
+}
+
+func (l *failingListener) Accept() (transport.CapableConn, error) {
+	select {
+	case err := <-l.err:
+		return nil, err
+	case <-l.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *failingListener) Close() error {
+	l.closeOnce.Do(func() { close(l.closed) })
+	return nil
+}
+
+func (l *failingListener) Addr() net.Addr          { return &net.TCPAddr{} }
+func (l *failingListener) Multiaddr() ma.Multiaddr { return l.addr }
+
+// listenTransport is a transport whose listeners can be killed, and that fails to
+// listen a configurable number of times.
+type listenTransport struct {
+	dummyTransport
+
+	mx        sync.Mutex
+	failures  int
+	listeners []*failingListener
+}
+
+func (t *listenTransport) Listen(laddr ma.Multiaddr) (transport.Listener, error) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	if t.failures > 0 {
+		t.failures--
+		return nil, errors.New("address in use")
+	}
+	l := &failingListener{addr: laddr, closed: make(chan struct{}), err: make(chan error, 1)}
+	t.listeners = append(t.listeners, l)
+	return l, nil
+}
+
+func (t *listenTransport) Dial(context.Context, ma.Multiaddr, peer.ID) (transport.CapableConn, error) {
+	return nil, errors.New("not supported")
+}
+
+func (t *listenTransport) CanDial(ma.Multiaddr) bool { return false }
+
+func (t *listenTransport) setFailures(n int) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	t.failures = n
+}
+
+func (t *listenTransport) lastListener() *failingListener {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	return t.listeners[len(t.listeners)-1]
+}
+
+func TestListenerEvents(t *testing.T) {
+	bus := eventbus.NewBus()
+	sub, err := bus.Subscribe([]interface{}{new(event.EvtListenFailure), new(event.EvtListenerClosed)})
+	require.NoError(t, err)
+	defer sub.Close()
+
+	s := swarmt.GenSwarm(t,
+		swarmt.OptDisableTCP,
+		swarmt.OptDisableQUIC,
+		swarmt.WithSwarmOpts(swarm.WithEventBus(bus), swarm.WithListenRetry(10*time.Millisecond, 50*time.Millisecond)),
+	)
+	tpt := &listenTransport{dummyTransport: dummyTransport{protocols: []int{ma.P_TCP}}}
+	require.NoError(t, s.AddTransport(tpt))
+
+	next := func() interface{} {
+		t.Helper()
+		select {
+		case e := <-sub.Out():
+			return e
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for event")
+		}
+		return nil
+	}
+
+	addr := ma.StringCast("/ip4/1.2.3.4/tcp/1234")
+	tpt.setFailures(1)
+	require.Error(t, s.Listen(addr))
+	failure := next().(event.EvtListenFailure)
+	require.Equal(t, addr, failure.Addr)
+	require.EqualError(t, failure.Error, "address in use")
+
+	require.NoError(t, s.Listen(addr))
+	require.Equal(t, []ma.Multiaddr{addr}, s.ListenAddresses())
+
+	// kill the listener, the first retry fails
+	tpt.setFailures(1)
+	tpt.lastListener().err <- errors.New("interface removed")
+	closed := next().(event.EvtListenerClosed)
+	require.Equal(t, addr, closed.Addr)
+	require.EqualError(t, closed.Error, "interface removed")
+	require.IsType(t, event.EvtListenFailure{}, next())
+	require.Eventually(t, func() bool { return len(s.ListenAddresses()) == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	// closing the listener intentionally doesn't trigger a retry
+	s.ListenClose(addr)
+	closed = next().(event.EvtListenerClosed)
+	require.Equal(t, addr, closed.Addr)
+	require.NoError(t, closed.Error)
+	time.Sleep(100 * time.Millisecond)
+	require.Empty(t, s.ListenAddresses())
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/AstaFrode/go-libp2p/core/crypto"
 	"github.com/AstaFrode/go-libp2p/core/network"
+	"github.com/AstaFrode/go-libp2p/core/protocol"
 	"github.com/AstaFrode/go-libp2p/p2p/metricshelper"
 
 	ma "github.com/multiformats/go-multiaddr"
@@ -69,6 +70,22 @@ var (
 		},
 		[]string{"transport", "security", "muxer", "early_muxer", "ip_version"},
 	)
+	streamBytes = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Name:      "stream_bytes_total",
+			Help:      "Bytes transferred on streams, by direction of the data",
+		},
+		[]string{"dir", "transport", "protocol"},
+	)
+	dialsCapped = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Name:      "dials_capped_total",
+			Help:      "Dials not attempted or canceled due to dial caps",
+		},
+		[]string{"reason"},
+	)
 	collectors = []prometheus.Collector{
 		connsOpened,
 		keyTypes,
@@ -76,6 +93,8 @@ var (
 		dialError,
 		connDuration,
 		connHandshakeLatency,
+		streamBytes,
+		dialsCapped,
 	}
 )
 
@@ -86,12 +105,35 @@ type MetricsTracer interface {
 	FailedDialing(ma.Multiaddr, error)
 }
 
-type metricsTracer struct{}
+// StreamMetricsTracer is an optional interface a MetricsTracer can implement
+// to record the bytes transferred on streams.
+type StreamMetricsTracer interface {
+	// BytesTransferred is called when n bytes are sent (DirOutbound) or
+	// received (DirInbound) on a stream.
+	BytesTransferred(dir network.Direction, transport string, proto protocol.ID, n int)
+}
 
-var _ MetricsTracer = &metricsTracer{}
+// DialCapMetricsTracer is an optional interface a MetricsTracer can implement
+// to record the dials skipped because of a dial cap.
+type DialCapMetricsTracer interface {
+	// DialCapped is called when n dials are not attempted, or canceled while waiting,
+	// because of a dial cap. The reason is one of "addr_limit", "peer_limit" or "fd_limit".
+	DialCapped(reason string, n int)
+}
+
+type metricsTracer struct {
+	protocolLabels bool
+}
+
+var (
+	_ MetricsTracer        = &metricsTracer{}
+	_ StreamMetricsTracer  = &metricsTracer{}
+	_ DialCapMetricsTracer = &metricsTracer{}
+)
 
 type metricsTracerSetting struct {
-	reg prometheus.Registerer
+	reg            prometheus.Registerer
+	protocolLabels bool
 }
 
 type MetricsTracerOption func(*metricsTracerSetting)
@@ -104,13 +146,22 @@ func WithRegisterer(reg prometheus.Registerer) MetricsTracerOption {
 	}
 }
 
+// WithProtocolLabels breaks down the stream bandwidth metrics by protocol ID.
+// Note that this increases the cardinality of the metrics, as the number of
+// protocols is controlled by the application.
+func WithProtocolLabels() MetricsTracerOption {
+	return func(s *metricsTracerSetting) {
+		s.protocolLabels = true
+	}
+}
+
 func NewMetricsTracer(opts ...MetricsTracerOption) MetricsTracer {
 	setting := &metricsTracerSetting{reg: prometheus.DefaultRegisterer}
 	for _, opt := range opts {
 		opt(setting)
 	}
 	metricshelper.RegisterCollectors(setting.reg, collectors...)
-	return &metricsTracer{}
+	return &metricsTracer{protocolLabels: setting.protocolLabels}
 }
 
 func appendConnectionState(tags []string, cs network.ConnectionState) []string {
@@ -183,6 +234,39 @@ func (m *metricsTracer) CompletedHandshake(t time.Duration, cs network.Connectio
 	connHandshakeLatency.WithLabelValues(*tags...).Observe(t.Seconds())
 }
 
+// transportName returns the name of the transport used for a connection to addr.
+// The transports are checked in order, such that the outermost transport wins,
+// e.g. for a relayed connection via a TCP relay, "p2p-circuit" is returned.
+func transportName(addr ma.Multiaddr) string {
+	for _, t := range transports {
+		if _, err := addr.ValueForProtocol(t); err == nil {
+			return ma.ProtocolWithCode(t).Name
+		}
+	}
+	return "other"
+}
+
+func (m *metricsTracer) BytesTransferred(dir network.Direction, transport string, proto protocol.ID, n int) {
+	tags := metricshelper.GetStringSlice()
+	defer metricshelper.PutStringSlice(tags)
+
+	*tags = append(*tags, metricshelper.GetDirection(dir), transport)
+	if m.protocolLabels {
+		*tags = append(*tags, string(proto))
+	} else {
+		*tags = append(*tags, "")
+	}
+	streamBytes.WithLabelValues(*tags...).Add(float64(n))
+}
+
+func (m *metricsTracer) DialCapped(reason string, n int) {
+	tags := metricshelper.GetStringSlice()
+	defer metricshelper.PutStringSlice(tags)
+
+	*tags = append(*tags, reason)
+	dialsCapped.WithLabelValues(*tags...).Add(float64(n))
+}
+
 var transports = [...]int{ma.P_CIRCUIT, ma.P_WEBRTC, ma.P_WEBTRANSPORT, ma.P_QUIC, ma.P_QUIC_V1, ma.P_WSS, ma.P_WS, ma.P_TCP}
 
 func (m *metricsTracer) FailedDialing(addr ma.Multiaddr, err error) {
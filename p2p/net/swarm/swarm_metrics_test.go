@@ -12,6 +12,7 @@ import (
 
 	"github.com/AstaFrode/go-libp2p/core/crypto"
 	"github.com/AstaFrode/go-libp2p/core/network"
+	"github.com/AstaFrode/go-libp2p/core/protocol"
 	ma "github.com/multiformats/go-multiaddr"
 
 	mrand "math/rand"
@@ -78,6 +79,10 @@ func TestMetricsNoAllocNoCover(t *testing.T) {
 		ma.StringCast("/ip4/1.2.3.4/udp/2345"),
 	}
 
+	transportNames := []string{"tcp", "quic-v1", "p2p-circuit"}
+	protocols := []protocol.ID{"/ipfs/ping/1.0.0", "/ipfs/id/1.0.0"}
+	capReasons := []string{dialCapAddrs, dialCapPeer, dialCapFd}
+
 	tests := map[string]func(){
 		"OpenedConnection": func() {
 			mt.OpenedConnection(randItem(directions), randItem(keys), randItem(connections), randItem(addrs))
@@ -89,6 +94,10 @@ func TestMetricsNoAllocNoCover(t *testing.T) {
 			mt.CompletedHandshake(time.Duration(mrand.Intn(100))*time.Second, randItem(connections), randItem(addrs))
 		},
 		"FailedDialing": func() { mt.FailedDialing(randItem(addrs), randItem(errors)) },
+		"BytesTransferred": func() {
+			mt.(StreamMetricsTracer).BytesTransferred(randItem(directions), randItem(transportNames), randItem(protocols), mrand.Intn(1000))
+		},
+		"DialCapped": func() { mt.(DialCapMetricsTracer).DialCapped(randItem(capReasons), 1+mrand.Intn(10)) },
 	}
 
 	for method, f := range tests {
@@ -0,0 +1,118 @@
+package swarm
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/AstaFrode/go-libp2p/core/network"
+	"github.com/AstaFrode/go-libp2p/core/peer"
+	"github.com/AstaFrode/go-libp2p/core/protocol"
+)
+
+// streamCounters are the counters of the streams of a peer or of a protocol.
+// Streams keep a pointer to the counters of their peer and protocol, so that counting
+// the bytes read and written doesn't require a lookup.
+type streamCounters struct {
+	streams           atomic.Int64
+	bytesIn, bytesOut atomic.Int64
+}
+
+func (c *streamCounters) stats() network.StreamStats {
+	return network.StreamStats{
+		Streams:  int(c.streams.Load()),
+		BytesIn:  c.bytesIn.Load(),
+		BytesOut: c.bytesOut.Load(),
+	}
+}
+
+// streamStats accounts for the streams of the swarm, by peer and by protocol.
+// The counters of a peer are removed once it has no connections and no streams left.
+type streamStats struct {
+	mx     sync.Mutex
+	peers  map[peer.ID]*streamCounters
+	protos map[protocol.ID]*streamCounters
+}
+
+func newStreamStats() *streamStats {
+	return &streamStats{
+		peers:  make(map[peer.ID]*streamCounters),
+		protos: make(map[protocol.ID]*streamCounters),
+	}
+}
+
+// openStream accounts for a new stream to peer p, and returns the counters of p.
+func (s *streamStats) openStream(p peer.ID) *streamCounters {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	c, ok := s.peers[p]
+	if !ok {
+		c = &streamCounters{}
+		s.peers[p] = c
+	}
+	c.streams.Add(1)
+	return c
+}
+
+// setProtocol moves a stream from the counters of its previous protocol, if any,
+// to the counters of protocol p, and returns them.
+func (s *streamStats) setProtocol(prev *streamCounters, p protocol.ID) *streamCounters {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	c, ok := s.protos[p]
+	if !ok {
+		c = &streamCounters{}
+		s.protos[p] = c
+	}
+	if prev != nil {
+		prev.streams.Add(-1)
+	}
+	c.streams.Add(1)
+	return c
+}
+
+// removePeer removes the counters of peer p, unless it still has streams.
+func (s *streamStats) removePeer(p peer.ID) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if c, ok := s.peers[p]; ok && c.streams.Load() == 0 {
+		delete(s.peers, p)
+	}
+}
+
+// NetworkStats returns the statistics of the streams of the swarm, grouped by peer and
+// by protocol. The statistics of a peer are kept as long as we're connected to it.
+func (s *Swarm) NetworkStats() network.NetworkStats {
+	st := s.streamStats
+	// Remove the peers whose streams were closed after their last connection.
+	for _, p := range st.peerIDs() {
+		if s.Connectedness(p) != network.Connected {
+			st.removePeer(p)
+		}
+	}
+
+	st.mx.Lock()
+	defer st.mx.Unlock()
+	stats := network.NetworkStats{
+		Peers:     make(map[peer.ID]network.StreamStats, len(st.peers)),
+		Protocols: make(map[protocol.ID]network.StreamStats, len(st.protos)),
+	}
+	for p, c := range st.peers {
+		stats.Peers[p] = c.stats()
+	}
+	for p, c := range st.protos {
+		stats.Protocols[p] = c.stats()
+	}
+	return stats
+}
+
+func (s *streamStats) peerIDs() []peer.ID {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	peers := make([]peer.ID, 0, len(s.peers))
+	for p := range s.peers {
+		peers = append(peers, p)
+	}
+	return peers
+}
+
+var _ network.NetworkStatsReporter = (*Swarm)(nil)
@@ -1,13 +1,18 @@
 package swarm
 
 import (
+	"context"
 	"fmt"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/AstaFrode/go-libp2p/core/event"
 	"github.com/AstaFrode/go-libp2p/core/network"
 	"github.com/AstaFrode/go-libp2p/core/protocol"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Validate Stream conforms to the go-libp2p-net Stream interface
@@ -27,6 +32,18 @@ type Stream struct {
 	protocol atomic.Pointer[protocol.ID]
 
 	stat network.Stats
+
+	ctx           context.Context
+	span          trace.Span
+	readFirstByte atomic.Bool
+
+	// opened is set once the EvtStreamOpened event has been emitted
+	opened                  atomic.Bool
+	bytesRead, bytesWritten atomic.Int64
+
+	// counters of the peer and of the protocol of the stream, see NetworkStats
+	peerCounters  *streamCounters
+	protoCounters atomic.Pointer[streamCounters]
 }
 
 func (s *Stream) ID() string {
@@ -53,22 +70,41 @@ func (s *Stream) Conn() network.Conn {
 // Read reads bytes from a stream.
 func (s *Stream) Read(p []byte) (int, error) {
 	n, err := s.stream.Read(p)
+	if n > 0 && !s.readFirstByte.Load() && s.readFirstByte.CompareAndSwap(false, true) {
+		s.span.AddEvent("first byte")
+	}
+	s.bytesRead.Add(int64(n))
+	s.peerCounters.bytesIn.Add(int64(n))
+	if pc := s.protoCounters.Load(); pc != nil {
+		pc.bytesIn.Add(int64(n))
+	}
 	// TODO: push this down to a lower level for better accuracy.
 	if s.conn.swarm.bwc != nil {
 		s.conn.swarm.bwc.LogRecvMessage(int64(n))
 		s.conn.swarm.bwc.LogRecvMessageStream(int64(n), s.Protocol(), s.Conn().RemotePeer())
 	}
+	if n > 0 && s.conn.swarm.streamTracer != nil {
+		s.conn.swarm.streamTracer.BytesTransferred(network.DirInbound, s.conn.transport, s.Protocol(), n)
+	}
 	return n, err
 }
 
 // Write writes bytes to a stream, flushing for each call.
 func (s *Stream) Write(p []byte) (int, error) {
 	n, err := s.stream.Write(p)
+	s.bytesWritten.Add(int64(n))
+	s.peerCounters.bytesOut.Add(int64(n))
+	if pc := s.protoCounters.Load(); pc != nil {
+		pc.bytesOut.Add(int64(n))
+	}
 	// TODO: push this down to a lower level for better accuracy.
 	if s.conn.swarm.bwc != nil {
 		s.conn.swarm.bwc.LogSentMessage(int64(n))
 		s.conn.swarm.bwc.LogSentMessageStream(int64(n), s.Protocol(), s.Conn().RemotePeer())
 	}
+	if n > 0 && s.conn.swarm.streamTracer != nil {
+		s.conn.swarm.streamTracer.BytesTransferred(network.DirOutbound, s.conn.transport, s.Protocol(), n)
+	}
 	return n, err
 }
 
@@ -76,7 +112,7 @@ func (s *Stream) Write(p []byte) (int, error) {
 // resources.
 func (s *Stream) Close() error {
 	err := s.stream.Close()
-	s.closeOnce.Do(s.remove)
+	s.closeOnce.Do(func() { s.remove(false) })
 	return err
 }
 
@@ -84,7 +120,15 @@ func (s *Stream) Close() error {
 // associated resources.
 func (s *Stream) Reset() error {
 	err := s.stream.Reset()
-	s.closeOnce.Do(s.remove)
+	s.closeOnce.Do(func() { s.remove(true) })
+	return err
+}
+
+// ResetWithError resets the stream like Reset, sending errCode to the remote peer
+// if the stream multiplexer supports it.
+func (s *Stream) ResetWithError(errCode network.StreamErrorCode) error {
+	err := network.ResetWithError(s.stream, errCode)
+	s.closeOnce.Do(func() { s.remove(true) })
 	return err
 }
 
@@ -101,11 +145,52 @@ func (s *Stream) CloseRead() error {
 	return s.stream.CloseRead()
 }
 
-func (s *Stream) remove() {
+func (s *Stream) remove(reset bool) {
+	s.span.End()
 	s.conn.removeStream(s)
+	s.peerCounters.streams.Add(-1)
+	if pc := s.protoCounters.Load(); pc != nil {
+		pc.streams.Add(-1)
+	}
+	if s.opened.Load() {
+		s.emitClosed(reset)
+	}
 	s.conn.swarm.refs.Done()
 }
 
+func (s *Stream) emitOpened(p protocol.ID) {
+	em := s.conn.swarm.emitters.streamOpened
+	if em == nil {
+		return
+	}
+	if err := em.Emit(event.EvtStreamOpened{
+		Peer:      s.conn.RemotePeer(),
+		Protocol:  p,
+		Direction: s.stat.Direction,
+		Stream:    s,
+	}); err != nil {
+		log.Debugw("failed to emit stream opened event", "error", err)
+	}
+}
+
+func (s *Stream) emitClosed(reset bool) {
+	em := s.conn.swarm.emitters.streamClosed
+	if em == nil {
+		return
+	}
+	if err := em.Emit(event.EvtStreamClosed{
+		Peer:         s.conn.RemotePeer(),
+		Protocol:     s.Protocol(),
+		Direction:    s.stat.Direction,
+		Duration:     time.Since(s.stat.Opened),
+		BytesRead:    s.bytesRead.Load(),
+		BytesWritten: s.bytesWritten.Load(),
+		Reset:        reset,
+	}); err != nil {
+		log.Debugw("failed to emit stream closed event", "error", err)
+	}
+}
+
 // Protocol returns the protocol negotiated on this stream (if set).
 func (s *Stream) Protocol() protocol.ID {
 	p := s.protocol.Load()
@@ -126,9 +211,21 @@ func (s *Stream) SetProtocol(p protocol.ID) error {
 	}
 
 	s.protocol.Store(&p)
+	s.protoCounters.Store(s.conn.swarm.streamStats.setProtocol(s.protoCounters.Load(), p))
+	s.span.SetAttributes(attribute.String("protocol", string(p)))
+	if !s.opened.Load() && s.opened.CompareAndSwap(false, true) {
+		s.emitOpened(p)
+	}
 	return nil
 }
 
+// Context returns a context carrying the trace span of this stream.
+// It is never canceled. Use network.StreamContext to access it from a
+// stream handler.
+func (s *Stream) Context() context.Context {
+	return s.ctx
+}
+
 // SetDeadline sets the read and write deadlines for this stream.
 func (s *Stream) SetDeadline(t time.Time) error {
 	return s.stream.SetDeadline(t)
@@ -11,13 +11,16 @@ import (
 	"testing"
 	"time"
 
+	"github.com/AstaFrode/go-libp2p/core/connmgr"
 	"github.com/AstaFrode/go-libp2p/core/control"
+	"github.com/AstaFrode/go-libp2p/core/event"
 	"github.com/AstaFrode/go-libp2p/core/network"
 	mocknetwork "github.com/AstaFrode/go-libp2p/core/network/mocks"
 	"github.com/AstaFrode/go-libp2p/core/peer"
 	"github.com/AstaFrode/go-libp2p/core/peerstore"
 	"github.com/AstaFrode/go-libp2p/core/protocol"
 	"github.com/AstaFrode/go-libp2p/core/test"
+	"github.com/AstaFrode/go-libp2p/p2p/host/eventbus"
 	"github.com/AstaFrode/go-libp2p/p2p/net/swarm"
 	. "github.com/AstaFrode/go-libp2p/p2p/net/swarm/testing"
 
@@ -356,6 +359,65 @@ func TestConnectionGating(t *testing.T) {
 	}
 }
 
+type peerDialGaterV2 struct {
+	connmgr.ConnectionGaterV2
+	verdict connmgr.Verdict
+}
+
+func (g *peerDialGaterV2) InterceptPeerDial(context.Context, peer.ID) connmgr.Verdict {
+	return g.verdict
+}
+
+func TestConnectionGatingV2(t *testing.T) {
+	gater := &peerDialGaterV2{
+		ConnectionGaterV2: connmgr.AsGaterV2(DefaultMockConnectionGater()),
+		verdict:           connmgr.Deny("peer is banned"),
+	}
+	sw1 := GenSwarm(t, OptConnGater(connmgr.AsGater(gater)))
+	sw2 := GenSwarm(t)
+	sw1.Peerstore().AddAddrs(sw2.LocalPeer(), sw2.ListenAddresses(), peerstore.PermanentAddrTTL)
+
+	_, err := sw1.DialPeer(context.Background(), sw2.LocalPeer())
+	require.ErrorIs(t, err, swarm.ErrGaterDisallowedConnection)
+	require.Contains(t, err.Error(), "peer is banned")
+
+	gater.verdict = connmgr.Delay(200 * time.Millisecond)
+	start := time.Now()
+	_, err = sw1.DialPeer(context.Background(), sw2.LocalPeer())
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond)
+}
+
+type addrDialGaterV2 struct {
+	connmgr.ConnectionGaterV2
+	delay time.Duration
+}
+
+func (g *addrDialGaterV2) InterceptAddrDial(context.Context, peer.ID, ma.Multiaddr) connmgr.Verdict {
+	return connmgr.Delay(g.delay)
+}
+
+func TestConnectionGatingV2AddrDelaysConcurrent(t *testing.T) {
+	const delay = 300 * time.Millisecond
+	gater := &addrDialGaterV2{
+		ConnectionGaterV2: connmgr.AsGaterV2(DefaultMockConnectionGater()),
+		delay:             delay,
+	}
+	sw1 := GenSwarm(t, OptConnGater(connmgr.AsGater(gater)))
+	sw2 := GenSwarm(t)
+	addrs := sw2.ListenAddresses()
+	require.Greater(t, len(addrs), 1)
+	sw1.Peerstore().AddAddrs(sw2.LocalPeer(), addrs, peerstore.PermanentAddrTTL)
+
+	start := time.Now()
+	_, err := sw1.DialPeer(context.Background(), sw2.LocalPeer())
+	require.NoError(t, err)
+	took := time.Since(start)
+	require.GreaterOrEqual(t, took, delay)
+	// the delays of the different addresses are not added up
+	require.Less(t, took, 2*delay)
+}
+
 func TestNoDial(t *testing.T) {
 	swarms := makeSwarms(t, 2)
 
@@ -559,3 +621,131 @@ func TestListenCloseCount(t *testing.T) {
 	remainingAddrs := s.ListenAddresses()
 	require.Equal(t, 0, len(remainingAddrs))
 }
+
+func TestDialOptions(t *testing.T) {
+	swarms := makeSwarms(t, 2)
+	s1, s2 := swarms[0], swarms[1]
+	s1.Peerstore().AddAddrs(s2.LocalPeer(), s2.ListenAddresses(), peerstore.PermanentAddrTTL)
+
+	ctx := context.Background()
+	c, err := s1.DialPeer(network.WithDialTransports(ctx, ma.P_TCP), s2.LocalPeer())
+	require.NoError(t, err)
+	require.True(t, isTCP(c.RemoteMultiaddr()))
+
+	// the existing TCP connection doesn't match, so a QUIC connection is dialed
+	str, err := s1.NewStream(network.WithDialTransports(ctx, ma.P_QUIC), s2.LocalPeer())
+	require.NoError(t, err)
+	str.Close()
+	require.False(t, isTCP(str.Conn().RemoteMultiaddr()))
+	require.Len(t, s1.ConnsToPeer(s2.LocalPeer()), 2)
+
+	// there are no relay addresses to dial
+	_, err = s1.NewStream(network.WithForceRelayDial(ctx, "test"), s2.LocalPeer())
+	require.Error(t, err)
+
+	_, err = s1.DialPeer(network.WithForceRelayDial(network.WithForceDirectDial(ctx, "test"), "test"), s2.LocalPeer())
+	require.Error(t, err)
+}
+
+func isTCP(addr ma.Multiaddr) bool {
+	_, err := addr.ValueForProtocol(ma.P_TCP)
+	return err == nil
+}
+
+func TestStreamEvents(t *testing.T) {
+	bus := eventbus.NewBus()
+	sub, err := bus.Subscribe([]interface{}{new(event.EvtStreamOpened), new(event.EvtStreamClosed)})
+	require.NoError(t, err)
+	defer sub.Close()
+
+	s1 := GenSwarm(t, WithSwarmOpts(swarm.WithEventBus(bus)))
+	s2 := makeSwarms(t, 1)[0]
+	s1.Peerstore().AddAddrs(s2.LocalPeer(), s2.ListenAddresses(), peerstore.PermanentAddrTTL)
+
+	next := func() interface{} {
+		t.Helper()
+		select {
+		case e := <-sub.Out():
+			return e
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for event")
+		}
+		return nil
+	}
+
+	str, err := s1.NewStream(context.Background(), s2.LocalPeer())
+	require.NoError(t, err)
+	// no event is emitted before the protocol is set
+	select {
+	case e := <-sub.Out():
+		t.Fatalf("unexpected event: %v", e)
+	case <-time.After(50 * time.Millisecond):
+	}
+	require.NoError(t, str.SetProtocol("/test"))
+	opened := next().(event.EvtStreamOpened)
+	require.Equal(t, s2.LocalPeer(), opened.Peer)
+	require.Equal(t, protocol.ID("/test"), opened.Protocol)
+	require.Equal(t, network.DirOutbound, opened.Direction)
+	require.Equal(t, str, opened.Stream)
+
+	_, err = str.Write([]byte("ping"))
+	require.NoError(t, err)
+	_, err = io.ReadFull(str, make([]byte, 4))
+	require.NoError(t, err)
+	require.NoError(t, str.Close())
+
+	closed := next().(event.EvtStreamClosed)
+	require.Equal(t, protocol.ID("/test"), closed.Protocol)
+	require.Equal(t, network.DirOutbound, closed.Direction)
+	require.Equal(t, int64(4), closed.BytesRead)
+	require.Equal(t, int64(4), closed.BytesWritten)
+	require.NotZero(t, closed.Duration)
+	require.False(t, closed.Reset)
+
+	// resetting a stream without a protocol doesn't emit an event
+	str, err = s1.NewStream(context.Background(), s2.LocalPeer())
+	require.NoError(t, err)
+	require.NoError(t, str.Reset())
+	select {
+	case e := <-sub.Out():
+		t.Fatalf("unexpected event: %v", e)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestNetworkStats(t *testing.T) {
+	swarms := makeSwarms(t, 2)
+	s1, s2 := swarms[0], swarms[1]
+	s1.Peerstore().AddAddrs(s2.LocalPeer(), s2.ListenAddresses(), peerstore.PermanentAddrTTL)
+
+	str, err := s1.NewStream(context.Background(), s2.LocalPeer())
+	require.NoError(t, err)
+	require.NoError(t, str.SetProtocol("/test"))
+	_, err = str.Write([]byte("ping"))
+	require.NoError(t, err)
+	_, err = io.ReadFull(str, make([]byte, 4))
+	require.NoError(t, err)
+
+	stats := s1.NetworkStats()
+	require.Equal(t, network.StreamStats{Streams: 1, BytesIn: 4, BytesOut: 4}, stats.Peers[s2.LocalPeer()])
+	require.Equal(t, network.StreamStats{Streams: 1, BytesIn: 4, BytesOut: 4}, stats.Protocols["/test"])
+
+	// bytes of closed streams are still accounted for
+	require.NoError(t, str.Close())
+	str, err = s1.NewStream(context.Background(), s2.LocalPeer())
+	require.NoError(t, err)
+	_, err = str.Write([]byte("ping"))
+	require.NoError(t, err)
+	stats = s1.NetworkStats()
+	require.Equal(t, network.StreamStats{Streams: 1, BytesIn: 4, BytesOut: 8}, stats.Peers[s2.LocalPeer()])
+	require.Equal(t, network.StreamStats{Streams: 0, BytesIn: 4, BytesOut: 4}, stats.Protocols["/test"])
+	require.NoError(t, str.Reset())
+
+	// the statistics of a peer are removed once we disconnect
+	require.NoError(t, s1.ClosePeer(s2.LocalPeer()))
+	require.Eventually(t, func() bool {
+		_, ok := s1.NetworkStats().Peers[s2.LocalPeer()]
+		return !ok
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Contains(t, s1.NetworkStats().Protocols, protocol.ID("/test"))
+}
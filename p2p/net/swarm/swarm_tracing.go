@@ -0,0 +1,34 @@
+package swarm
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/AstaFrode/go-libp2p/p2p/net/swarm"
+
+// WithTracerProvider sets the OpenTelemetry TracerProvider used to create spans
+// for address resolution, dials and streams.
+// By default, no spans are recorded.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(s *Swarm) error {
+		s.tracer = tp.Tracer(tracerName)
+		return nil
+	}
+}
+
+func (s *Swarm) startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return s.tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// endSpan ends the span, recording the error (if any).
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
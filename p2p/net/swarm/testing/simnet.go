@@ -0,0 +1,488 @@
+package testing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/AstaFrode/go-libp2p/core/network"
+	"github.com/AstaFrode/go-libp2p/core/peer"
+	"github.com/AstaFrode/go-libp2p/core/transport"
+
+	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+// LinkSettings are the properties of the simulated link between two peers.
+// All settings apply to each direction separately.
+type LinkSettings struct {
+	// Latency is the one-way latency.
+	Latency time.Duration
+	// Jitter is the maximum random delay added to the latency of every write.
+	// Data is never reordered.
+	Jitter time.Duration
+	// Bandwidth is the bandwidth in bytes per second. 0 means unlimited.
+	Bandwidth int64
+	// PacketLoss is the probability that a write is lost. Since the simulated connections
+	// are reliable, a lost write is retransmitted, i.e. delivered after an additional
+	// round trip time.
+	PacketLoss float64
+	// StreamReset is the probability that a stream is reset when writing to it.
+	StreamReset float64
+}
+
+// SimNet simulates a network between swarms in the same process.
+// Connections go through the upgrader, like TCP connections do, but the data is
+// exchanged in memory, using the configured latency, jitter, bandwidth and loss.
+//
+// Random decisions (jitter, losses and resets) are taken using a source seeded by the
+// seed passed to NewSimNet. Runs are not reproducible though: the source is shared by
+// all links, so the decisions depend on the order in which goroutines write, and
+// delays are measured using the real clock.
+//
+// Use OptSimNet to create test swarms using a SimNet.
+type SimNet struct {
+	mx        sync.Mutex
+	rng       *rand.Rand
+	nextAddr  uint32
+	listeners map[string]*simListener
+	defaults  LinkSettings
+	links     map[[2]peer.ID]LinkSettings
+}
+
+// NewSimNet creates a new simulated network.
+func NewSimNet(seed int64) *SimNet {
+	return &SimNet{
+		rng:       rand.New(rand.NewSource(seed)),
+		listeners: make(map[string]*simListener),
+		links:     make(map[[2]peer.ID]LinkSettings),
+	}
+}
+
+// SetLinkDefaults sets the settings of the links without specific settings.
+// It applies to existing connections.
+func (n *SimNet) SetLinkDefaults(s LinkSettings) {
+	n.mx.Lock()
+	defer n.mx.Unlock()
+	n.defaults = s
+}
+
+// SetLink sets the settings of the link between peers a and b.
+// It applies to existing connections.
+func (n *SimNet) SetLink(a, b peer.ID, s LinkSettings) {
+	n.mx.Lock()
+	defer n.mx.Unlock()
+	n.links[linkKey(a, b)] = s
+}
+
+func linkKey(a, b peer.ID) [2]peer.ID {
+	if a > b {
+		a, b = b, a
+	}
+	return [2]peer.ID{a, b}
+}
+
+func (n *SimNet) link(a, b peer.ID) LinkSettings {
+	n.mx.Lock()
+	defer n.mx.Unlock()
+	if s, ok := n.links[linkKey(a, b)]; ok {
+		return s
+	}
+	return n.defaults
+}
+
+// random returns a random number in [0, 1).
+func (n *SimNet) random() float64 {
+	n.mx.Lock()
+	defer n.mx.Unlock()
+	return n.rng.Float64()
+}
+
+// NewAddr returns a new, unused address.
+func (n *SimNet) NewAddr() ma.Multiaddr {
+	n.mx.Lock()
+	defer n.mx.Unlock()
+	n.nextAddr++
+	return ma.StringCast(fmt.Sprintf("/ip4/10.%d.%d.%d/tcp/4001", byte(n.nextAddr>>16), byte(n.nextAddr>>8), byte(n.nextAddr)))
+}
+
+// NewTransport creates a new transport for the peer local, using this network.
+// It handles TCP addresses, so it can't be used together with the TCP transport.
+func (n *SimNet) NewTransport(local peer.ID, upgrader transport.Upgrader, rcmgr network.ResourceManager) transport.Transport {
+	if rcmgr == nil {
+		rcmgr = &network.NullResourceManager{}
+	}
+	return &simTransport{net: n, local: local, upgrader: upgrader, rcmgr: rcmgr}
+}
+
+type simTransport struct {
+	net      *SimNet
+	local    peer.ID
+	upgrader transport.Upgrader
+	rcmgr    network.ResourceManager
+
+	mx        sync.Mutex
+	listeners []*simListener
+}
+
+var _ transport.Transport = &simTransport{}
+
+func (t *simTransport) Dial(ctx context.Context, raddr ma.Multiaddr, p peer.ID) (transport.CapableConn, error) {
+	t.net.mx.Lock()
+	l, ok := t.net.listeners[string(raddr.Bytes())]
+	t.net.mx.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("dial %s: connection refused", raddr)
+	}
+
+	scope, err := t.rcmgr.OpenConnection(network.DirOutbound, false, raddr)
+	if err != nil {
+		return nil, err
+	}
+	laddr := t.net.NewAddr()
+	t.mx.Lock()
+	if len(t.listeners) > 0 {
+		laddr = t.listeners[0].addr
+	}
+	t.mx.Unlock()
+
+	local, remote := newSimConnPair(t.net, t.local, laddr, l.t.local, raddr)
+	select {
+	case l.incoming <- remote:
+	case <-l.closed:
+		scope.Done()
+		return nil, fmt.Errorf("dial %s: connection refused", raddr)
+	case <-ctx.Done():
+		scope.Done()
+		return nil, ctx.Err()
+	}
+	c, err := t.upgrader.Upgrade(ctx, t, local, network.DirOutbound, p, scope)
+	if err != nil {
+		return nil, err
+	}
+	return &simCapableConn{CapableConn: c, net: t.net}, nil
+}
+
+func (t *simTransport) CanDial(addr ma.Multiaddr) bool {
+	t.net.mx.Lock()
+	defer t.net.mx.Unlock()
+	_, ok := t.net.listeners[string(addr.Bytes())]
+	return ok
+}
+
+func (t *simTransport) Listen(laddr ma.Multiaddr) (transport.Listener, error) {
+	t.net.mx.Lock()
+	defer t.net.mx.Unlock()
+	if _, ok := t.net.listeners[string(laddr.Bytes())]; ok {
+		return nil, fmt.Errorf("listen %s: address already in use", laddr)
+	}
+	l := &simListener{
+		t:        t,
+		addr:     laddr,
+		incoming: make(chan *simConn),
+		closed:   make(chan struct{}),
+	}
+	t.net.listeners[string(laddr.Bytes())] = l
+	t.mx.Lock()
+	t.listeners = append(t.listeners, l)
+	t.mx.Unlock()
+	return &simUpgradedListener{Listener: t.upgrader.UpgradeListener(t, l), net: t.net}, nil
+}
+
+func (t *simTransport) Protocols() []int { return []int{ma.P_TCP} }
+func (t *simTransport) Proxy() bool      { return false }
+func (t *simTransport) String() string   { return "SimNet" }
+
+// simListener is the raw listener, wrapped by the upgrader.
+type simListener struct {
+	t         *simTransport
+	addr      ma.Multiaddr
+	incoming  chan *simConn
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+var _ manet.Listener = &simListener{}
+
+func (l *simListener) Accept() (manet.Conn, error) {
+	select {
+	case c := <-l.incoming:
+		return c, nil
+	case <-l.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *simListener) Close() error {
+	l.closeOnce.Do(func() {
+		close(l.closed)
+		l.t.net.mx.Lock()
+		delete(l.t.net.listeners, string(l.addr.Bytes()))
+		l.t.net.mx.Unlock()
+		l.t.mx.Lock()
+		for i, sl := range l.t.listeners {
+			if sl == l {
+				l.t.listeners = append(l.t.listeners[:i], l.t.listeners[i+1:]...)
+				break
+			}
+		}
+		l.t.mx.Unlock()
+	})
+	return nil
+}
+
+func (l *simListener) Addr() net.Addr          { return simAddr(l.addr.String()) }
+func (l *simListener) Multiaddr() ma.Multiaddr { return l.addr }
+
+type simUpgradedListener struct {
+	transport.Listener
+	net *SimNet
+}
+
+func (l *simUpgradedListener) Accept() (transport.CapableConn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &simCapableConn{CapableConn: c, net: l.net}, nil
+}
+
+// simCapableConn injects stream resets.
+type simCapableConn struct {
+	transport.CapableConn
+	net *SimNet
+}
+
+func (c *simCapableConn) OpenStream(ctx context.Context) (network.MuxedStream, error) {
+	s, err := c.CapableConn.OpenStream(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &simStream{MuxedStream: s, conn: c}, nil
+}
+
+func (c *simCapableConn) AcceptStream() (network.MuxedStream, error) {
+	s, err := c.CapableConn.AcceptStream()
+	if err != nil {
+		return nil, err
+	}
+	return &simStream{MuxedStream: s, conn: c}, nil
+}
+
+type simStream struct {
+	network.MuxedStream
+	conn *simCapableConn
+}
+
+func (s *simStream) Write(b []byte) (int, error) {
+	link := s.conn.net.link(s.conn.LocalPeer(), s.conn.RemotePeer())
+	if link.StreamReset > 0 && s.conn.net.random() < link.StreamReset {
+		s.MuxedStream.Reset()
+		return 0, network.ErrReset
+	}
+	return s.MuxedStream.Write(b)
+}
+
+type simAddr string
+
+func (a simAddr) Network() string { return "simnet" }
+func (a simAddr) String() string  { return string(a) }
+
+type simChunk struct {
+	data []byte
+	at   time.Time // when the data is delivered
+}
+
+// simPipe is one direction of a simulated connection.
+type simPipe struct {
+	mx           sync.Mutex
+	chunks       []simChunk
+	writeClosed  bool
+	readClosed   bool
+	busyUntil    time.Time // when the link finished sending the data written so far
+	lastDelivery time.Time
+	notify       chan struct{}
+}
+
+func newSimPipe() *simPipe {
+	return &simPipe{notify: make(chan struct{}, 1)}
+}
+
+func (p *simPipe) signal() {
+	select {
+	case p.notify <- struct{}{}:
+	default:
+	}
+}
+
+type simConn struct {
+	net                 *SimNet
+	localPeer, remote   peer.ID
+	laddr, raddr        ma.Multiaddr
+	in, out             *simPipe
+	closeOnce           sync.Once
+	deadlineMx          sync.Mutex
+	readDeadline        time.Time
+	writeDeadline       time.Time
+	deadlineChangedRead chan struct{}
+}
+
+var _ manet.Conn = &simConn{}
+
+func newSimConnPair(n *SimNet, local peer.ID, laddr ma.Multiaddr, remote peer.ID, raddr ma.Multiaddr) (*simConn, *simConn) {
+	p1, p2 := newSimPipe(), newSimPipe()
+	c1 := &simConn{net: n, localPeer: local, remote: remote, laddr: laddr, raddr: raddr, in: p1, out: p2, deadlineChangedRead: make(chan struct{}, 1)}
+	c2 := &simConn{net: n, localPeer: remote, remote: local, laddr: raddr, raddr: laddr, in: p2, out: p1, deadlineChangedRead: make(chan struct{}, 1)}
+	return c1, c2
+}
+
+func (c *simConn) Read(b []byte) (int, error) {
+	for {
+		c.deadlineMx.Lock()
+		deadline := c.readDeadline
+		c.deadlineMx.Unlock()
+		now := time.Now()
+		if !deadline.IsZero() && !now.Before(deadline) {
+			return 0, os.ErrDeadlineExceeded
+		}
+
+		c.in.mx.Lock()
+		if c.in.readClosed {
+			c.in.mx.Unlock()
+			return 0, net.ErrClosed
+		}
+		var wait time.Duration
+		if len(c.in.chunks) > 0 {
+			chunk := &c.in.chunks[0]
+			if wait = chunk.at.Sub(now); wait <= 0 {
+				n := copy(b, chunk.data)
+				chunk.data = chunk.data[n:]
+				if len(chunk.data) == 0 {
+					c.in.chunks = c.in.chunks[1:]
+				}
+				c.in.mx.Unlock()
+				return n, nil
+			}
+		} else if c.in.writeClosed {
+			c.in.mx.Unlock()
+			return 0, io.EOF
+		}
+		c.in.mx.Unlock()
+
+		if !deadline.IsZero() && (wait == 0 || deadline.Sub(now) < wait) {
+			wait = deadline.Sub(now)
+		}
+		var timer *time.Timer
+		var timerC <-chan time.Time
+		if wait > 0 {
+			timer = time.NewTimer(wait)
+			timerC = timer.C
+		}
+		select {
+		case <-c.in.notify:
+		case <-c.deadlineChangedRead:
+		case <-timerC:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+func (c *simConn) Write(b []byte) (int, error) {
+	c.deadlineMx.Lock()
+	deadline := c.writeDeadline
+	c.deadlineMx.Unlock()
+	if !deadline.IsZero() && !time.Now().Before(deadline) {
+		return 0, os.ErrDeadlineExceeded
+	}
+
+	link := c.net.link(c.localPeer, c.remote)
+	delay := link.Latency
+	if link.Jitter > 0 {
+		delay += time.Duration(c.net.random() * float64(link.Jitter))
+	}
+	if link.PacketLoss > 0 && c.net.random() < link.PacketLoss {
+		delay += 2 * link.Latency
+	}
+
+	c.out.mx.Lock()
+	if c.out.writeClosed {
+		c.out.mx.Unlock()
+		return 0, net.ErrClosed
+	}
+	if c.out.readClosed {
+		c.out.mx.Unlock()
+		return 0, io.ErrClosedPipe
+	}
+	now := time.Now()
+	start := c.out.busyUntil
+	if start.Before(now) {
+		start = now
+	}
+	c.out.busyUntil = start
+	if link.Bandwidth > 0 {
+		c.out.busyUntil = start.Add(time.Duration(float64(len(b)) / float64(link.Bandwidth) * float64(time.Second)))
+	}
+	at := c.out.busyUntil.Add(delay)
+	if at.Before(c.out.lastDelivery) {
+		at = c.out.lastDelivery
+	}
+	c.out.lastDelivery = at
+	c.out.chunks = append(c.out.chunks, simChunk{data: append([]byte(nil), b...), at: at})
+	busyUntil := c.out.busyUntil
+	c.out.mx.Unlock()
+	c.out.signal()
+
+	// apply backpressure while the link is busy sending the data
+	time.Sleep(time.Until(busyUntil))
+	return len(b), nil
+}
+
+func (c *simConn) Close() error {
+	c.closeOnce.Do(func() {
+		c.out.mx.Lock()
+		c.out.writeClosed = true
+		c.out.mx.Unlock()
+		c.out.signal()
+		c.in.mx.Lock()
+		c.in.readClosed = true
+		c.in.chunks = nil
+		c.in.mx.Unlock()
+		c.in.signal()
+	})
+	return nil
+}
+
+func (c *simConn) LocalAddr() net.Addr           { return simAddr(c.laddr.String()) }
+func (c *simConn) RemoteAddr() net.Addr          { return simAddr(c.raddr.String()) }
+func (c *simConn) LocalMultiaddr() ma.Multiaddr  { return c.laddr }
+func (c *simConn) RemoteMultiaddr() ma.Multiaddr { return c.raddr }
+
+func (c *simConn) SetDeadline(t time.Time) error {
+	c.SetReadDeadline(t)
+	return c.SetWriteDeadline(t)
+}
+
+func (c *simConn) SetReadDeadline(t time.Time) error {
+	c.deadlineMx.Lock()
+	c.readDeadline = t
+	c.deadlineMx.Unlock()
+	select {
+	case c.deadlineChangedRead <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func (c *simConn) SetWriteDeadline(t time.Time) error {
+	c.deadlineMx.Lock()
+	c.writeDeadline = t
+	c.deadlineMx.Unlock()
+	return nil
+}
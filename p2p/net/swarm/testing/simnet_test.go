@@ -0,0 +1,92 @@
+package testing
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/AstaFrode/go-libp2p/core/network"
+	"github.com/AstaFrode/go-libp2p/p2p/net/swarm"
+
+	"github.com/stretchr/testify/require"
+)
+
+func connectSimNet(t *testing.T, n *SimNet) (*swarm.Swarm, *swarm.Swarm) {
+	t.Helper()
+	s1 := GenSwarm(t, OptSimNet(n))
+	s2 := GenSwarm(t, OptSimNet(n))
+	t.Cleanup(func() {
+		s1.Close()
+		s2.Close()
+	})
+	DivulgeAddresses(s2, s1)
+	s2.SetStreamHandler(func(s network.Stream) {
+		defer s.Close()
+		io.Copy(s, s)
+	})
+	_, err := s1.DialPeer(context.Background(), s2.LocalPeer())
+	require.NoError(t, err)
+	return s1, s2
+}
+
+// echo sends data on a new stream, and returns how long it took until it was echoed.
+func echo(t *testing.T, s1, s2 *swarm.Swarm, data []byte) (time.Duration, error) {
+	t.Helper()
+	str, err := s1.NewStream(context.Background(), s2.LocalPeer())
+	require.NoError(t, err)
+	defer str.Close()
+	start := time.Now()
+	if _, err := str.Write(data); err != nil {
+		return 0, err
+	}
+	buf := make([]byte, len(data))
+	if _, err := io.ReadFull(str, buf); err != nil {
+		return 0, err
+	}
+	require.Equal(t, data, buf)
+	return time.Since(start), nil
+}
+
+func TestSimNetLatency(t *testing.T) {
+	n := NewSimNet(1)
+	s1, s2 := connectSimNet(t, n)
+
+	n.SetLink(s1.LocalPeer(), s2.LocalPeer(), LinkSettings{Latency: 50 * time.Millisecond, Jitter: 10 * time.Millisecond})
+	rtt, err := echo(t, s1, s2, []byte("foobar"))
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, rtt, 100*time.Millisecond)
+	require.Less(t, rtt, time.Second)
+}
+
+func TestSimNetBandwidth(t *testing.T) {
+	n := NewSimNet(1)
+	s1, s2 := connectSimNet(t, n)
+
+	n.SetLinkDefaults(LinkSettings{Bandwidth: 100 << 10})
+	// 20K in each direction take 200ms each
+	d, err := echo(t, s1, s2, make([]byte, 20<<10))
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, d, 200*time.Millisecond)
+}
+
+func TestSimNetStreamReset(t *testing.T) {
+	n := NewSimNet(1)
+	s1, s2 := connectSimNet(t, n)
+
+	n.SetLinkDefaults(LinkSettings{StreamReset: 1})
+	_, err := echo(t, s1, s2, []byte("foobar"))
+	require.ErrorIs(t, err, network.ErrReset)
+
+	// the connection is still usable
+	n.SetLinkDefaults(LinkSettings{})
+	_, err = echo(t, s1, s2, []byte("foobar"))
+	require.NoError(t, err)
+}
+
+func TestSimNetSeed(t *testing.T) {
+	n1, n2 := NewSimNet(42), NewSimNet(42)
+	for i := 0; i < 10; i++ {
+		require.Equal(t, n1.random(), n2.random())
+	}
+}
@@ -36,6 +36,7 @@ type config struct {
 	connectionGater  connmgr.ConnectionGater
 	sk               crypto.PrivKey
 	swarmOpts        []swarm.Option
+	simnet           *SimNet
 	clock
 }
 
@@ -52,6 +53,10 @@ func (rc realclock) Now() time.Time {
 // Option is an option that can be passed when constructing a test swarm.
 type Option func(*testing.T, *config)
 
+// TBOption is an option that can be passed when constructing a test swarm with GenSwarmTB,
+// e.g. from a fuzz target or a benchmark.
+type TBOption func(testing.TB, *config)
+
 // WithClock sets the clock to use for this swarm
 func WithClock(clock clock) Option {
 	return func(_ *testing.T, c *config) {
@@ -85,6 +90,14 @@ var OptDisableQUIC Option = func(_ *testing.T, c *config) {
 	c.disableQUIC = true
 }
 
+// OptSimNet connects the test swarm to the simulated network, instead of using
+// TCP and QUIC.
+func OptSimNet(n *SimNet) Option {
+	return func(_ *testing.T, c *config) {
+		c.simnet = n
+	}
+}
+
 // OptConnGater configures the given connection gater on the test
 func OptConnGater(cg connmgr.ConnectionGater) Option {
 	return func(_ *testing.T, c *config) {
@@ -101,6 +114,10 @@ func OptPeerPrivateKey(sk crypto.PrivKey) Option {
 
 // GenUpgrader creates a new connection upgrader for use with this swarm.
 func GenUpgrader(t *testing.T, n *swarm.Swarm, connGater connmgr.ConnectionGater, opts ...tptu.Option) transport.Upgrader {
+	return genUpgrader(t, n, connGater, opts...)
+}
+
+func genUpgrader(t testing.TB, n *swarm.Swarm, connGater connmgr.ConnectionGater, opts ...tptu.Option) transport.Upgrader {
 	id := n.LocalPeer()
 	pk := n.Peerstore().PrivKey(id)
 	st := insecure.NewWithIdentity(insecure.ID, id, pk)
@@ -112,6 +129,16 @@ func GenUpgrader(t *testing.T, n *swarm.Swarm, connGater connmgr.ConnectionGater
 
 // GenSwarm generates a new test swarm.
 func GenSwarm(t *testing.T, opts ...Option) *swarm.Swarm {
+	tbOpts := make([]TBOption, 0, len(opts))
+	for _, o := range opts {
+		o := o
+		tbOpts = append(tbOpts, func(_ testing.TB, c *config) { o(t, c) })
+	}
+	return GenSwarmTB(t, tbOpts...)
+}
+
+// GenSwarmTB generates a new test swarm. Unlike GenSwarm, it can be used with a testing.TB.
+func GenSwarmTB(t testing.TB, opts ...TBOption) *swarm.Swarm {
 	var cfg config
 	cfg.clock = realclock{}
 	for _, o := range opts {
@@ -143,9 +170,19 @@ func GenSwarm(t *testing.T, opts ...Option) *swarm.Swarm {
 	s, err := swarm.NewSwarm(id, ps, swarmOpts...)
 	require.NoError(t, err)
 
-	upgrader := GenUpgrader(t, s, cfg.connectionGater)
+	upgrader := genUpgrader(t, s, cfg.connectionGater)
 
-	if !cfg.disableTCP {
+	if cfg.simnet != nil {
+		if err := s.AddTransport(cfg.simnet.NewTransport(id, upgrader, nil)); err != nil {
+			t.Fatal(err)
+		}
+		if !cfg.dialOnly {
+			if err := s.Listen(cfg.simnet.NewAddr()); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if !cfg.disableTCP && cfg.simnet == nil {
 		var tcpOpts []tcp.Option
 		if cfg.disableReuseport {
 			tcpOpts = append(tcpOpts, tcp.DisableReuseport())
@@ -161,7 +198,7 @@ func GenSwarm(t *testing.T, opts ...Option) *swarm.Swarm {
 			}
 		}
 	}
-	if !cfg.disableQUIC {
+	if !cfg.disableQUIC && cfg.simnet == nil {
 		reuse, err := quicreuse.NewConnManager([32]byte{})
 		if err != nil {
 			t.Fatal(err)
@@ -0,0 +1,173 @@
+package upgrader
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/AstaFrode/go-libp2p/core/network"
+
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// Load is the load of the host when an inbound connection is accepted, passed to
+// the AdmissionFunc.
+type Load struct {
+	// PendingHandshakes is the number of inbound connections being upgraded.
+	PendingHandshakes int
+	// InboundConns and OutboundConns are the numbers of connections (including the
+	// ones being upgraded) in the system scope of the resource manager.
+	InboundConns, OutboundConns int
+	// Memory is the memory reserved in the system scope of the resource manager.
+	Memory int64
+	// InboundConnsHeadroom and MemoryHeadroom are the numbers of inbound connections
+	// and the memory that can still be reserved before reaching the system limits of
+	// the resource manager. They are -1 if the resource manager doesn't expose its
+	// limits.
+	InboundConnsHeadroom int
+	MemoryHeadroom       int64
+}
+
+// AdmissionDecision is the decision of an AdmissionFunc.
+type AdmissionDecision int
+
+const (
+	// Admit upgrades the connection.
+	Admit AdmissionDecision = iota
+	// Reject closes the connection, before any resources are allocated for it.
+	Reject
+	// Queue stops accepting connections on the listener until one of the pending
+	// handshakes completes, and then asks the AdmissionFunc again. If there's no
+	// pending handshake, the connection is admitted. If the listener is closed while
+	// waiting, the connection is closed.
+	Queue
+)
+
+// AdmissionFunc decides whether an inbound connection is upgraded, given the current
+// load. It is called before the resource manager is asked to open the connection,
+// and before the security handshake, which is the most expensive step of the upgrade.
+type AdmissionFunc func(remote ma.Multiaddr, load Load) AdmissionDecision
+
+// WithAdmission sets the AdmissionFunc invoked for every inbound connection.
+func WithAdmission(f AdmissionFunc) Option {
+	return func(u *upgrader) error {
+		if f == nil {
+			return errors.New("admission func cannot be nil")
+		}
+		u.admission.f = f
+		return nil
+	}
+}
+
+// WithLoadShedding makes the upgrader reject inbound connections as long as
+// maxPendingHandshakes inbound connections are being upgraded, or the resource
+// manager has no headroom for another inbound connection. This prevents floods of
+// handshakes from saturating the CPU. Load shedding applies before the AdmissionFunc,
+// if any.
+func WithLoadShedding(maxPendingHandshakes int) Option {
+	return func(u *upgrader) error {
+		if maxPendingHandshakes <= 0 {
+			return errors.New("max pending handshakes needs to be positive")
+		}
+		u.admission.maxPending = maxPendingHandshakes
+		return nil
+	}
+}
+
+// scopeLimits is implemented by the scopes of the resource manager
+// in p2p/host/resource-manager, giving access to their limits.
+type scopeLimits interface {
+	ConnLimit(dir network.Direction) int
+	MemoryLimit() int64
+}
+
+// admission tracks the pending inbound handshakes of an upgrader, and decides which
+// inbound connections are upgraded.
+type admission struct {
+	f          AdmissionFunc
+	maxPending int
+
+	mx      sync.Mutex
+	pending int
+	// closed and replaced whenever a pending handshake completes
+	handshakeDone chan struct{}
+}
+
+func newAdmission() *admission {
+	return &admission{handshakeDone: make(chan struct{})}
+}
+
+func (a *admission) enabled() bool {
+	return a.f != nil || a.maxPending > 0
+}
+
+// admit decides whether the connection from remote is upgraded, blocking while the
+// AdmissionFunc queues it, until ctx is canceled. If it returns true, the caller must
+// call done once the upgrade completes.
+func (a *admission) admit(ctx context.Context, remote ma.Multiaddr, rcm network.ResourceManager) bool {
+	a.mx.Lock()
+	defer a.mx.Unlock()
+	for {
+		load := a.loadLocked(rcm)
+		d := Admit
+		if a.maxPending > 0 && (load.PendingHandshakes >= a.maxPending || load.InboundConnsHeadroom == 0) {
+			d = Reject
+		} else if a.f != nil {
+			a.mx.Unlock()
+			d = a.f(remote, load)
+			a.mx.Lock()
+		}
+		switch d {
+		case Reject:
+			return false
+		case Queue:
+			if a.pending > 0 {
+				handshakeDone := a.handshakeDone
+				a.mx.Unlock()
+				select {
+				case <-handshakeDone:
+				case <-ctx.Done():
+					a.mx.Lock()
+					return false
+				}
+				a.mx.Lock()
+				continue
+			}
+		}
+		a.pending++
+		return true
+	}
+}
+
+func (a *admission) done() {
+	a.mx.Lock()
+	defer a.mx.Unlock()
+	a.pending--
+	close(a.handshakeDone)
+	a.handshakeDone = make(chan struct{})
+}
+
+func (a *admission) loadLocked(rcm network.ResourceManager) Load {
+	load := Load{PendingHandshakes: a.pending, InboundConnsHeadroom: -1, MemoryHeadroom: -1}
+	if rcm == nil {
+		return load
+	}
+	rcm.ViewSystem(func(s network.ResourceScope) error {
+		stat := s.Stat()
+		load.InboundConns = stat.NumConnsInbound
+		load.OutboundConns = stat.NumConnsOutbound
+		load.Memory = stat.Memory
+		if l, ok := s.(scopeLimits); ok {
+			load.InboundConnsHeadroom = l.ConnLimit(network.DirInbound) - stat.NumConnsInbound
+			if load.InboundConnsHeadroom < 0 {
+				load.InboundConnsHeadroom = 0
+			}
+			load.MemoryHeadroom = l.MemoryLimit() - stat.Memory
+			if load.MemoryHeadroom < 0 {
+				load.MemoryHeadroom = 0
+			}
+		}
+		return nil
+	})
+	return load
+}
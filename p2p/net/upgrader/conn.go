@@ -2,6 +2,7 @@ package upgrader
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/AstaFrode/go-libp2p/core/network"
 	"github.com/AstaFrode/go-libp2p/core/protocol"
@@ -19,6 +20,10 @@ type transportConn struct {
 	muxer                     protocol.ID
 	security                  protocol.ID
 	usedEarlyMuxerNegotiation bool
+	cipherSuite               string
+	resumed                   bool
+	handshakeDuration         time.Duration
+	metadata                  map[string]string
 }
 
 var _ transport.CapableConn = &transportConn{}
@@ -61,5 +66,9 @@ func (t *transportConn) ConnState() network.ConnectionState {
 		Security:                  t.security,
 		Transport:                 "tcp",
 		UsedEarlyMuxerNegotiation: t.usedEarlyMuxerNegotiation,
+		CipherSuite:               t.cipherSuite,
+		Resumed:                   t.resumed,
+		HandshakeDuration:         t.handshakeDuration,
+		Metadata:                  t.metadata,
 	}
 }
@@ -1,6 +1,7 @@
 package upgrader_test
 
 import (
+	"context"
 	"sync"
 
 	"github.com/AstaFrode/go-libp2p/core/connmgr"
@@ -58,3 +59,51 @@ func (t *testGater) InterceptSecured(direction network.Direction, id peer.ID, mu
 func (t *testGater) InterceptUpgraded(conn network.Conn) (allow bool, reason control.DisconnectReason) {
 	panic("not implemented")
 }
+
+type testGaterV2 struct {
+	sync.Mutex
+
+	accept, secured connmgr.Verdict
+}
+
+var _ connmgr.ConnectionGaterV2 = (*testGaterV2)(nil)
+
+func (t *testGaterV2) SetAccept(v connmgr.Verdict) {
+	t.Lock()
+	defer t.Unlock()
+
+	t.accept = v
+}
+
+func (t *testGaterV2) SetSecured(v connmgr.Verdict) {
+	t.Lock()
+	defer t.Unlock()
+
+	t.secured = v
+}
+
+func (t *testGaterV2) InterceptPeerDial(context.Context, peer.ID) connmgr.Verdict {
+	panic("not implemented")
+}
+
+func (t *testGaterV2) InterceptAddrDial(context.Context, peer.ID, ma.Multiaddr) connmgr.Verdict {
+	panic("not implemented")
+}
+
+func (t *testGaterV2) InterceptAccept(context.Context, network.ConnMultiaddrs) connmgr.Verdict {
+	t.Lock()
+	defer t.Unlock()
+
+	return t.accept
+}
+
+func (t *testGaterV2) InterceptSecured(context.Context, network.Direction, peer.ID, network.ConnMultiaddrs) connmgr.Verdict {
+	t.Lock()
+	defer t.Unlock()
+
+	return t.secured
+}
+
+func (t *testGaterV2) InterceptUpgraded(context.Context, network.Conn) connmgr.Verdict {
+	panic("not implemented")
+}
@@ -0,0 +1,52 @@
+package upgrader
+
+import (
+	"context"
+
+	"github.com/AstaFrode/go-libp2p/core/crypto"
+	"github.com/AstaFrode/go-libp2p/core/network"
+	"github.com/AstaFrode/go-libp2p/core/peer"
+	"github.com/AstaFrode/go-libp2p/core/protocol"
+	"github.com/AstaFrode/go-libp2p/core/transport"
+
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+// UpgradeInfo describes a connection that is being upgraded.
+// Its fields are filled in as the upgrade progresses.
+type UpgradeInfo struct {
+	Direction network.Direction
+	Transport transport.Transport
+	// Conn is the raw connection, before the security handshake.
+	// Interceptors must not read from or write to it.
+	Conn manet.Conn
+
+	// Set after the security handshake.
+	RemotePeer      peer.ID
+	RemotePublicKey crypto.PubKey
+	Security        protocol.ID
+
+	// Set after the muxer negotiation.
+	Muxer protocol.ID
+
+	// Metadata is attached to the upgraded connection, and is available
+	// as the Metadata of its network.ConnectionState.
+	// Interceptors may modify it at any step.
+	Metadata map[string]string
+}
+
+// Interceptor is invoked at every step of the connection upgrade.
+// Returning an error from any of the methods aborts the upgrade and closes
+// the connection.
+//
+// Interceptors are called from the goroutine performing the upgrade, and must not block
+// for a long time.
+type Interceptor interface {
+	// InterceptRaw is called when the raw connection was established, before the security handshake.
+	InterceptRaw(context.Context, *UpgradeInfo) error
+	// InterceptSecured is called after the security handshake, once the remote peer is authenticated.
+	InterceptSecured(context.Context, *UpgradeInfo) error
+	// InterceptMuxed is called after the stream multiplexer was negotiated,
+	// right before the connection is returned by the upgrader.
+	InterceptMuxed(context.Context, *UpgradeInfo) error
+}
@@ -4,7 +4,10 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/AstaFrode/go-libp2p/core/connmgr"
 	"github.com/AstaFrode/go-libp2p/core/network"
 	"github.com/AstaFrode/go-libp2p/core/transport"
 
@@ -15,6 +18,10 @@ import (
 
 var log = logging.Logger("upgrader")
 
+// maxTarpittedConns is the maximum number of connections tarpitted by a listener at
+// the same time. Once reached, connections that should be tarpitted are closed right away.
+const maxTarpittedConns = 64
+
 type listener struct {
 	manet.Listener
 
@@ -28,6 +35,9 @@ type listener struct {
 	// Used for backpressure
 	threshold *threshold
 
+	// number of connections currently tarpitted
+	tarpitted atomic.Int32
+
 	// Canceling this context isn't sufficient to tear down the listener.
 	// Call close.
 	ctx    context.Context
@@ -84,11 +94,31 @@ func (l *listener) handleIncoming() {
 		catcher.Reset()
 
 		// gate the connection if applicable
-		if l.upgrader.connGater != nil && !l.upgrader.connGater.InterceptAccept(maconn) {
-			log.Debugf("gater blocked incoming connection on local addr %s from %s",
-				maconn.LocalMultiaddr(), maconn.RemoteMultiaddr())
+		var gaterDelay time.Duration
+		if l.upgrader.connGater != nil {
+			v := l.upgrader.connGater.InterceptAccept(l.ctx, maconn)
+			if !v.Allowed() {
+				log.Debugf("gater blocked incoming connection on local addr %s from %s: %s",
+					maconn.LocalMultiaddr(), maconn.RemoteMultiaddr(), v)
+				if v.Kind == connmgr.VerdictTarpit && l.tarpit(maconn, v.Delay, &wg) {
+					continue
+				}
+				if err := maconn.Close(); err != nil {
+					log.Warnf("failed to close incoming connection rejected by gater: %s", err)
+				}
+				continue
+			}
+			if v.Kind == connmgr.VerdictDelay {
+				gaterDelay = v.Delay
+			}
+		}
+
+		admission := l.upgrader.admission
+		admitted := admission.enabled()
+		if admitted && !admission.admit(l.ctx, maconn.RemoteMultiaddr(), l.rcmgr) {
+			log.Debugw("rejected incoming connection due to load", "remote", maconn.RemoteMultiaddr())
 			if err := maconn.Close(); err != nil {
-				log.Warnf("failed to close incoming connection rejected by gater: %s", err)
+				log.Warnf("failed to close incoming connection rejected due to load: %s", err)
 			}
 			continue
 		}
@@ -99,6 +129,9 @@ func (l *listener) handleIncoming() {
 			if err := maconn.Close(); err != nil {
 				log.Warnf("failed to incoming connection rejected by resource manager: %s", err)
 			}
+			if admitted {
+				admission.done()
+			}
 			continue
 		}
 
@@ -118,7 +151,27 @@ func (l *listener) handleIncoming() {
 			ctx, cancel := context.WithTimeout(l.ctx, l.upgrader.acceptTimeout)
 			defer cancel()
 
+			// The handshake is no longer pending once the upgrade completed.
+			upgradeDone := func() {
+				if admitted {
+					admission.done()
+				}
+			}
+
+			if gaterDelay > 0 {
+				if !connmgr.Delay(gaterDelay).Wait(ctx) {
+					log.Debugf("accept timed out while delayed by gater (%s <--> %s)",
+						maconn.LocalMultiaddr(),
+						maconn.RemoteMultiaddr())
+					maconn.Close()
+					connScope.Done()
+					upgradeDone()
+					return
+				}
+			}
+
 			conn, err := l.upgrader.Upgrade(ctx, l.transport, maconn, network.DirInbound, "", connScope)
+			upgradeDone()
 			if err != nil {
 				// Don't bother bubbling this up. We just failed
 				// to completely negotiate the connection.
@@ -158,6 +211,35 @@ func (l *listener) handleIncoming() {
 }
 
 // Accept accepts a connection.
+// tarpit holds maconn open without reading from it, until d has elapsed or the listener
+// is closed. It returns false if the connection can't be tarpitted, because too many
+// connections are tarpitted already or the resource manager doesn't allow it.
+func (l *listener) tarpit(maconn manet.Conn, d time.Duration, wg *sync.WaitGroup) bool {
+	if l.tarpitted.Load() >= maxTarpittedConns {
+		return false
+	}
+	connScope, err := l.rcmgr.OpenConnection(network.DirInbound, true, maconn.RemoteMultiaddr())
+	if err != nil {
+		return false
+	}
+	l.tarpitted.Add(1)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer l.tarpitted.Add(-1)
+		defer connScope.Done()
+		defer maconn.Close()
+
+		t := time.NewTimer(d)
+		defer t.Stop()
+		select {
+		case <-t.C:
+		case <-l.ctx.Done():
+		}
+	}()
+	return true
+}
+
 func (l *listener) Accept() (transport.CapableConn, error) {
 	for c := range l.incoming {
 		// Could have been sitting there for a while.
@@ -10,12 +10,14 @@ import (
 	"testing"
 	"time"
 
+	"github.com/AstaFrode/go-libp2p/core/connmgr"
 	"github.com/AstaFrode/go-libp2p/core/network"
 	mocknetwork "github.com/AstaFrode/go-libp2p/core/network/mocks"
 	"github.com/AstaFrode/go-libp2p/core/peer"
 	"github.com/AstaFrode/go-libp2p/core/sec"
 	"github.com/AstaFrode/go-libp2p/core/sec/insecure"
 	"github.com/AstaFrode/go-libp2p/core/transport"
+	rcmgr "github.com/AstaFrode/go-libp2p/p2p/host/resource-manager"
 	"github.com/AstaFrode/go-libp2p/p2p/net/upgrader"
 
 	"github.com/golang/mock/gomock"
@@ -415,3 +417,194 @@ func TestNoCommonSecurityProto(t *testing.T) {
 	ln.Close()
 	<-done
 }
+
+func TestListenerConnectionGaterV2(t *testing.T) {
+	require := require.New(t)
+
+	testGater := &testGaterV2{}
+	id, priv := newPeer(t)
+	u, err := upgrader.New(
+		[]sec.SecureTransport{insecure.NewWithIdentity(insecure.ID, id, priv)},
+		[]upgrader.StreamMuxer{{ID: "negotiate", Muxer: &negotiatingMuxer{}}},
+		nil, nil, nil,
+		upgrader.WithConnectionGaterV2(testGater),
+	)
+	require.NoError(err)
+
+	ln := createListener(t, u)
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	// delaying the connection
+	testGater.SetAccept(connmgr.Delay(200 * time.Millisecond))
+	start := time.Now()
+	conn, err := dial(t, u, ln.Multiaddr(), id, &network.NullScope{})
+	require.NoError(err)
+	require.GreaterOrEqual(time.Since(start), 200*time.Millisecond)
+	_ = conn.Close()
+
+	// tarpitting the connection
+	testGater.SetAccept(connmgr.Tarpit(200*time.Millisecond, "tarpit"))
+	start = time.Now()
+	_, err = dial(t, u, ln.Multiaddr(), id, &network.NullScope{})
+	require.Error(err)
+	require.GreaterOrEqual(time.Since(start), 200*time.Millisecond)
+
+	// rejecting after handshake, with a reason
+	testGater.SetAccept(connmgr.Allow())
+	testGater.SetSecured(connmgr.Deny("not on the list"))
+	_, err = dial(t, u, ln.Multiaddr(), id, &network.NullScope{})
+	require.Error(err)
+	require.Contains(err.Error(), "not on the list")
+}
+
+func TestListenerTarpitClosedOnClose(t *testing.T) {
+	testGater := &testGaterV2{}
+	testGater.SetAccept(connmgr.Tarpit(time.Hour, "tarpit"))
+	id, priv := newPeer(t)
+	u, err := upgrader.New(
+		[]sec.SecureTransport{insecure.NewWithIdentity(insecure.ID, id, priv)},
+		[]upgrader.StreamMuxer{{ID: "negotiate", Muxer: &negotiatingMuxer{}}},
+		nil, nil, nil,
+		upgrader.WithConnectionGaterV2(testGater),
+	)
+	require.NoError(t, err)
+	ln := createListener(t, u)
+
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := dial(t, u, ln.Multiaddr(), id, &network.NullScope{})
+		errCh <- err
+	}()
+	select {
+	case err := <-errCh:
+		t.Fatalf("expected the connection to be tarpitted, got: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	require.NoError(t, ln.Close())
+	select {
+	case err := <-errCh:
+		require.Error(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the tarpitted connection to be closed with the listener")
+	}
+}
+
+func TestListenerAdmission(t *testing.T) {
+	var mx sync.Mutex
+	decision := upgrader.Reject
+	var loads []upgrader.Load
+	id, u := createUpgraderWithOpts(t, upgrader.WithAdmission(func(_ ma.Multiaddr, load upgrader.Load) upgrader.AdmissionDecision {
+		mx.Lock()
+		defer mx.Unlock()
+		loads = append(loads, load)
+		return decision
+	}))
+	ln := createListener(t, u)
+	defer ln.Close()
+
+	_, err := dial(t, u, ln.Multiaddr(), id, &network.NullScope{})
+	require.Error(t, err)
+
+	mx.Lock()
+	decision = upgrader.Admit
+	mx.Unlock()
+	conn, err := dial(t, u, ln.Multiaddr(), id, &network.NullScope{})
+	require.NoError(t, err)
+	defer conn.Close()
+	sconn, err := ln.Accept()
+	require.NoError(t, err)
+	defer sconn.Close()
+
+	mx.Lock()
+	defer mx.Unlock()
+	require.Len(t, loads, 2)
+	for _, l := range loads {
+		require.Zero(t, l.PendingHandshakes)
+		// the NullResourceManager doesn't expose its limits
+		require.Equal(t, -1, l.InboundConnsHeadroom)
+	}
+}
+
+func TestListenerAdmissionResourceManagerHeadroom(t *testing.T) {
+	mgr, err := rcmgr.NewResourceManager(rcmgr.NewFixedLimiter(rcmgr.DefaultLimits.AutoScale()))
+	require.NoError(t, err)
+	defer mgr.Close()
+	loads := make(chan upgrader.Load, 1)
+	id, u := createUpgraderWithMuxers(t, []upgrader.StreamMuxer{{ID: "negotiate", Muxer: &negotiatingMuxer{}}}, mgr, nil,
+		upgrader.WithAdmission(func(_ ma.Multiaddr, load upgrader.Load) upgrader.AdmissionDecision {
+			loads <- load
+			return upgrader.Reject
+		}),
+	)
+	ln := createListener(t, u)
+	defer ln.Close()
+
+	_, err = dial(t, u, ln.Multiaddr(), id, &network.NullScope{})
+	require.Error(t, err)
+	load := <-loads
+	require.Positive(t, load.InboundConnsHeadroom)
+	require.Positive(t, load.MemoryHeadroom)
+}
+
+func TestListenerAdmissionQueueClose(t *testing.T) {
+	_, u := createUpgraderWithOpts(t, upgrader.WithAdmission(func(ma.Multiaddr, upgrader.Load) upgrader.AdmissionDecision {
+		return upgrader.Queue
+	}))
+	ln := createListener(t, u)
+
+	// The first connection is admitted, since there's no pending handshake.
+	// It never completes the handshake, so the second one is queued.
+	for i := 0; i < 2; i++ {
+		c, err := manet.Dial(ln.Multiaddr())
+		require.NoError(t, err)
+		defer c.Close()
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ln.Close()
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("closing the listener blocked on the queued connection")
+	}
+}
+
+func TestListenerLoadShedding(t *testing.T) {
+	id, u := createUpgraderWithOpts(t, upgrader.WithLoadShedding(1))
+	ln := createListener(t, u)
+	defer ln.Close()
+
+	// a connection that never completes the handshake
+	stalled, err := manet.Dial(ln.Multiaddr())
+	require.NoError(t, err)
+	// give the listener some time to accept it
+	time.Sleep(100 * time.Millisecond)
+
+	_, err = dial(t, u, ln.Multiaddr(), id, &network.NullScope{})
+	require.Error(t, err)
+
+	// once the pending handshake fails, connections are accepted again
+	stalled.Close()
+	require.Eventually(t, func() bool {
+		conn, err := dial(t, u, ln.Multiaddr(), id, &network.NullScope{})
+		if err != nil {
+			return false
+		}
+		conn.Close()
+		return true
+	}, 5*time.Second, 50*time.Millisecond)
+}
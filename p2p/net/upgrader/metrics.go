@@ -0,0 +1,133 @@
+package upgrader
+
+import (
+	"time"
+
+	"github.com/AstaFrode/go-libp2p/core/network"
+	"github.com/AstaFrode/go-libp2p/core/protocol"
+	"github.com/AstaFrode/go-libp2p/p2p/metricshelper"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const metricNamespace = "libp2p_upgrader"
+
+var (
+	securityHandshakeLatency = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metricNamespace,
+			Name:      "security_handshake_latency_seconds",
+			Help:      "Duration of the security protocol negotiation and handshake",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 1.3, 35),
+		},
+		[]string{"dir", "security"},
+	)
+	muxerNegotiationLatency = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metricNamespace,
+			Name:      "muxer_negotiation_latency_seconds",
+			Help:      "Duration of the stream multiplexer negotiation",
+			Buckets:   prometheus.ExponentialBuckets(0.0001, 1.5, 30),
+		},
+		[]string{"dir", "muxer", "early_muxer"},
+	)
+	throttledBytes = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Name:      "throttled_bytes_total",
+			Help:      "Number of bytes delayed by the bandwidth limits",
+		},
+		[]string{"dir"},
+	)
+	throttleDelay = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Name:      "throttle_delay_seconds_total",
+			Help:      "Time reads and writes were delayed by the bandwidth limits",
+		},
+		[]string{"dir"},
+	)
+	collectors = []prometheus.Collector{
+		securityHandshakeLatency,
+		muxerNegotiationLatency,
+		throttledBytes,
+		throttleDelay,
+	}
+)
+
+// MetricsTracer tracks metrics for the upgrader.
+type MetricsTracer interface {
+	// SecurityHandshakeCompleted is called after the security protocol was
+	// negotiated and the handshake completed successfully.
+	SecurityHandshakeCompleted(dir network.Direction, security protocol.ID, d time.Duration)
+	// MuxerNegotiationCompleted is called after the stream multiplexer was
+	// selected, either using early muxer negotiation or multistream.
+	MuxerNegotiationCompleted(dir network.Direction, muxer protocol.ID, earlyMuxer bool, d time.Duration)
+}
+
+// ThrottleMetricsTracer is an optional interface a MetricsTracer can implement
+// to record the reads and writes delayed by the Throttler.
+type ThrottleMetricsTracer interface {
+	// BytesThrottled is called when reading or writing n bytes was delayed by the
+	// Throttler. dir is DirInbound for reads, and DirOutbound for writes.
+	BytesThrottled(dir network.Direction, n int, d time.Duration)
+}
+
+type metricsTracer struct{}
+
+var (
+	_ MetricsTracer         = &metricsTracer{}
+	_ ThrottleMetricsTracer = &metricsTracer{}
+)
+
+type metricsTracerSetting struct {
+	reg prometheus.Registerer
+}
+
+type MetricsTracerOption func(*metricsTracerSetting)
+
+func WithRegisterer(reg prometheus.Registerer) MetricsTracerOption {
+	return func(s *metricsTracerSetting) {
+		if reg != nil {
+			s.reg = reg
+		}
+	}
+}
+
+func NewMetricsTracer(opts ...MetricsTracerOption) MetricsTracer {
+	setting := &metricsTracerSetting{reg: prometheus.DefaultRegisterer}
+	for _, opt := range opts {
+		opt(setting)
+	}
+	metricshelper.RegisterCollectors(setting.reg, collectors...)
+	return &metricsTracer{}
+}
+
+func (m *metricsTracer) SecurityHandshakeCompleted(dir network.Direction, security protocol.ID, d time.Duration) {
+	tags := metricshelper.GetStringSlice()
+	defer metricshelper.PutStringSlice(tags)
+
+	*tags = append(*tags, metricshelper.GetDirection(dir), string(security))
+	securityHandshakeLatency.WithLabelValues(*tags...).Observe(d.Seconds())
+}
+
+func (m *metricsTracer) MuxerNegotiationCompleted(dir network.Direction, muxer protocol.ID, earlyMuxer bool, d time.Duration) {
+	tags := metricshelper.GetStringSlice()
+	defer metricshelper.PutStringSlice(tags)
+
+	early := "false"
+	if earlyMuxer {
+		early = "true"
+	}
+	*tags = append(*tags, metricshelper.GetDirection(dir), string(muxer), early)
+	muxerNegotiationLatency.WithLabelValues(*tags...).Observe(d.Seconds())
+}
+
+func (m *metricsTracer) BytesThrottled(dir network.Direction, n int, d time.Duration) {
+	tags := metricshelper.GetStringSlice()
+	defer metricshelper.PutStringSlice(tags)
+
+	*tags = append(*tags, metricshelper.GetDirection(dir))
+	throttledBytes.WithLabelValues(*tags...).Add(float64(n))
+	throttleDelay.WithLabelValues(*tags...).Add(d.Seconds())
+}
@@ -0,0 +1,411 @@
+package upgrader
+
+import (
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/AstaFrode/go-libp2p/core/network"
+	"github.com/AstaFrode/go-libp2p/core/peer"
+)
+
+// Limit is a bandwidth limit, enforced using a token bucket.
+type Limit struct {
+	// Rate is the sustained rate, in bytes per second.
+	// A Rate of 0 means unlimited.
+	Rate int64
+	// Burst is the number of bytes that can be transferred at once, after the bucket
+	// filled up. If unset, it defaults to Rate.
+	Burst int64
+}
+
+func (l Limit) burst() int64 {
+	if l.Burst > 0 {
+		return l.Burst
+	}
+	return l.Rate
+}
+
+// ThrottleLimits are the bandwidth limits applied by a Throttler.
+// Every limit is applied separately to the data read and the data written.
+type ThrottleLimits struct {
+	// Global limits the bandwidth of all connections combined.
+	Global Limit
+	// Peer limits the bandwidth of all connections to the same peer combined.
+	Peer Limit
+	// Conn limits the bandwidth of every connection.
+	Conn Limit
+}
+
+// ThrottleStats are the statistics of a Throttler.
+type ThrottleStats struct {
+	// ThrottledBytesIn and ThrottledBytesOut are the number of bytes read and written that
+	// were delayed by a limit.
+	ThrottledBytesIn, ThrottledBytesOut int64
+	// DelayIn and DelayOut are the total time reads and writes were delayed.
+	DelayIn, DelayOut time.Duration
+}
+
+// after this duration without traffic, the buckets of a peer are garbage collected,
+// unless they're still used by a connection or haven't filled up yet
+const peerBucketsIdleTimeout = time.Minute
+
+// Throttler limits the bandwidth of the connections upgraded by an upgrader.
+// It is passed to the upgrader using WithThrottler.
+//
+// The limits apply to the raw connection, i.e. they include the overhead of the security
+// protocol and the stream multiplexer. They only apply to connections upgraded by the
+// upgrader: QUIC and WebTransport connections are not throttled.
+type Throttler struct {
+	limits atomic.Pointer[ThrottleLimits]
+
+	globalIn, globalOut tokenBucket
+
+	mx        sync.Mutex
+	peers     map[peer.ID]*peerBuckets
+	lastSweep time.Time
+
+	throttledIn, throttledOut atomic.Int64
+	delayIn, delayOut         atomic.Int64
+	metricsTracer             ThrottleMetricsTracer // may be nil
+}
+
+type peerBuckets struct {
+	in, out  tokenBucket
+	lastUsed atomic.Int64 // unix nanoseconds
+	conns    int          // number of connections using the buckets, guarded by Throttler.mx
+}
+
+// ThrottlerOption is an option for NewThrottler.
+type ThrottlerOption func(*Throttler)
+
+// WithThrottlerMetricsTracer sets the MetricsTracer that is notified about throttled
+// reads and writes. It's only used if it implements ThrottleMetricsTracer.
+func WithThrottlerMetricsTracer(mt MetricsTracer) ThrottlerOption {
+	return func(t *Throttler) {
+		t.metricsTracer, _ = mt.(ThrottleMetricsTracer)
+	}
+}
+
+// NewThrottler creates a new Throttler.
+func NewThrottler(limits ThrottleLimits, opts ...ThrottlerOption) *Throttler {
+	t := &Throttler{peers: make(map[peer.ID]*peerBuckets)}
+	t.limits.Store(&limits)
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// SetLimits updates the limits. It applies to existing connections.
+func (t *Throttler) SetLimits(limits ThrottleLimits) {
+	t.limits.Store(&limits)
+}
+
+// Limits returns the current limits.
+func (t *Throttler) Limits() ThrottleLimits {
+	return *t.limits.Load()
+}
+
+// Stats returns the statistics of the throttler.
+func (t *Throttler) Stats() ThrottleStats {
+	return ThrottleStats{
+		ThrottledBytesIn:  t.throttledIn.Load(),
+		ThrottledBytesOut: t.throttledOut.Load(),
+		DelayIn:           time.Duration(t.delayIn.Load()),
+		DelayOut:          time.Duration(t.delayOut.Load()),
+	}
+}
+
+func (t *Throttler) wrap(c net.Conn) *throttledConn {
+	return &throttledConn{Conn: c, throttler: t, closed: make(chan struct{})}
+}
+
+// getPeerBuckets returns the buckets of peer p, and garbage collects the buckets
+// of peers that were idle for a while. The caller must call releasePeerBuckets once
+// it stops using the buckets.
+func (t *Throttler) getPeerBuckets(p peer.ID) *peerBuckets {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	now := time.Now()
+	if now.Sub(t.lastSweep) > peerBucketsIdleTimeout {
+		t.lastSweep = now
+		limit := t.limits.Load().Peer
+		for q, b := range t.peers {
+			// Only collect buckets that are equivalent to new ones,
+			// otherwise the per-peer limit could be exceeded.
+			if b.conns == 0 &&
+				now.Sub(time.Unix(0, b.lastUsed.Load())) > peerBucketsIdleTimeout &&
+				b.in.full(now, limit) && b.out.full(now, limit) {
+				delete(t.peers, q)
+			}
+		}
+	}
+	b, ok := t.peers[p]
+	if !ok {
+		b = &peerBuckets{}
+		t.peers[p] = b
+	}
+	b.conns++
+	b.lastUsed.Store(now.UnixNano())
+	return b
+}
+
+func (t *Throttler) releasePeerBuckets(b *peerBuckets) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	b.conns--
+}
+
+// wait blocks until n bytes may be transferred, and records the delay.
+// It returns early if the deadline is exceeded or the connection is closed.
+func (t *Throttler) wait(dir network.Direction, d time.Duration, n int, dl *deadline, closed <-chan struct{}) error {
+	if d <= 0 {
+		return nil
+	}
+	start := time.Now()
+	err := waitFor(d, dl, closed)
+	d = time.Since(start)
+	if dir == network.DirInbound {
+		t.throttledIn.Add(int64(n))
+		t.delayIn.Add(int64(d))
+	} else {
+		t.throttledOut.Add(int64(n))
+		t.delayOut.Add(int64(d))
+	}
+	if t.metricsTracer != nil {
+		t.metricsTracer.BytesThrottled(dir, n, d)
+	}
+	return err
+}
+
+func waitFor(d time.Duration, dl *deadline, closed <-chan struct{}) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	for {
+		t, changed := dl.get()
+		var deadlineTimer *time.Timer
+		var deadlineC <-chan time.Time
+		if !t.IsZero() {
+			until := time.Until(t)
+			if until <= 0 {
+				return os.ErrDeadlineExceeded
+			}
+			deadlineTimer = time.NewTimer(until)
+			deadlineC = deadlineTimer.C
+		}
+		select {
+		case <-timer.C:
+			return nil
+		case <-deadlineC:
+			return os.ErrDeadlineExceeded
+		case <-closed:
+			return net.ErrClosed
+		case <-changed:
+			// the deadline was updated
+			if deadlineTimer != nil {
+				deadlineTimer.Stop()
+			}
+		}
+	}
+}
+
+// deadline is a read or write deadline. Waiters are notified when it changes.
+type deadline struct {
+	mx      sync.Mutex
+	t       time.Time
+	changed chan struct{}
+}
+
+func (d *deadline) set(t time.Time) {
+	d.mx.Lock()
+	defer d.mx.Unlock()
+	d.t = t
+	if d.changed != nil {
+		close(d.changed)
+		d.changed = nil
+	}
+}
+
+func (d *deadline) get() (time.Time, <-chan struct{}) {
+	d.mx.Lock()
+	defer d.mx.Unlock()
+	if d.changed == nil {
+		d.changed = make(chan struct{})
+	}
+	return d.t, d.changed
+}
+
+// throttledConn is a net.Conn throttled by a Throttler.
+// The per-peer limit is applied once the peer is known, i.e. after the security handshake.
+type throttledConn struct {
+	net.Conn
+	throttler *Throttler
+
+	connIn, connOut tokenBucket
+	peer            atomic.Pointer[peerBuckets]
+
+	readDeadline, writeDeadline deadline
+	closeOnce                   sync.Once
+	closed                      chan struct{}
+}
+
+func (c *throttledConn) setPeer(p peer.ID) {
+	b := c.throttler.getPeerBuckets(p)
+	c.peer.Store(b)
+	select {
+	case <-c.closed:
+		// the connection was closed concurrently
+		if c.peer.CompareAndSwap(b, nil) {
+			c.throttler.releasePeerBuckets(b)
+		}
+	default:
+	}
+}
+
+// maxChunk returns the maximum number of bytes read or written at once,
+// so that a single read or write doesn't exceed the burst of any limit.
+func maxChunk(limits *ThrottleLimits, n int) int {
+	for _, l := range []Limit{limits.Global, limits.Peer, limits.Conn} {
+		if l.Rate > 0 && int64(n) > l.burst() {
+			n = int(l.burst())
+		}
+	}
+	if n == 0 {
+		n = 1
+	}
+	return n
+}
+
+// reserve takes n bytes from all buckets, and returns how long to wait before
+// transferring them.
+func (c *throttledConn) reserve(dir network.Direction, limits *ThrottleLimits, n int) time.Duration {
+	global, conn := &c.throttler.globalIn, &c.connIn
+	if dir == network.DirOutbound {
+		global, conn = &c.throttler.globalOut, &c.connOut
+	}
+	now := time.Now()
+	d := global.reserve(now, limits.Global, n)
+	if pd := conn.reserve(now, limits.Conn, n); pd > d {
+		d = pd
+	}
+	if pb := c.peer.Load(); pb != nil {
+		pb.lastUsed.Store(now.UnixNano())
+		b := &pb.in
+		if dir == network.DirOutbound {
+			b = &pb.out
+		}
+		if pd := b.reserve(now, limits.Peer, n); pd > d {
+			d = pd
+		}
+	}
+	return d
+}
+
+func (c *throttledConn) Read(b []byte) (int, error) {
+	limits := c.throttler.limits.Load()
+	if len(b) > 0 {
+		b = b[:maxChunk(limits, len(b))]
+	}
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		// We can't know in advance how many bytes we'll read,
+		// so we delay returning the data instead.
+		// If the wait is interrupted, return the data anyway: it was already read.
+		c.throttler.wait(network.DirInbound, c.reserve(network.DirInbound, limits, n), n, &c.readDeadline, c.closed)
+	}
+	return n, err
+}
+
+func (c *throttledConn) Write(b []byte) (int, error) {
+	var written int
+	for len(b) > 0 {
+		limits := c.throttler.limits.Load()
+		chunk := maxChunk(limits, len(b))
+		if err := c.throttler.wait(network.DirOutbound, c.reserve(network.DirOutbound, limits, chunk), chunk, &c.writeDeadline, c.closed); err != nil {
+			return written, err
+		}
+		n, err := c.Conn.Write(b[:chunk])
+		written += n
+		if err != nil {
+			return written, err
+		}
+		b = b[chunk:]
+	}
+	return written, nil
+}
+
+func (c *throttledConn) SetDeadline(t time.Time) error {
+	c.readDeadline.set(t)
+	c.writeDeadline.set(t)
+	return c.Conn.SetDeadline(t)
+}
+
+func (c *throttledConn) SetReadDeadline(t time.Time) error {
+	c.readDeadline.set(t)
+	return c.Conn.SetReadDeadline(t)
+}
+
+func (c *throttledConn) SetWriteDeadline(t time.Time) error {
+	c.writeDeadline.set(t)
+	return c.Conn.SetWriteDeadline(t)
+}
+
+func (c *throttledConn) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.closed)
+		if b := c.peer.Swap(nil); b != nil {
+			c.throttler.releasePeerBuckets(b)
+		}
+	})
+	return c.Conn.Close()
+}
+
+// tokenBucket is a token bucket. The limit is passed on every call, so that it can be
+// updated at runtime.
+type tokenBucket struct {
+	mx     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// full says if the bucket filled up, i.e. it's equivalent to a new bucket.
+func (b *tokenBucket) full(now time.Time, l Limit) bool {
+	if l.Rate <= 0 {
+		return true
+	}
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	if b.last.IsZero() {
+		return true
+	}
+	return b.tokens+now.Sub(b.last).Seconds()*float64(l.Rate) >= float64(l.burst())
+}
+
+// reserve takes n tokens from the bucket, and returns how long to wait until
+// the bucket is not in debt anymore.
+func (b *tokenBucket) reserve(now time.Time, l Limit, n int) time.Duration {
+	if l.Rate <= 0 {
+		return 0
+	}
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	burst := float64(l.burst())
+	if b.last.IsZero() {
+		b.tokens = burst
+		b.last = now
+	} else if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens += elapsed.Seconds() * float64(l.Rate)
+		b.last = now
+	}
+	if b.tokens > burst {
+		b.tokens = burst
+	}
+	b.tokens -= float64(n)
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / float64(l.Rate) * float64(time.Second))
+}
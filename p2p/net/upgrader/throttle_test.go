@@ -0,0 +1,103 @@
+package upgrader
+
+import (
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/AstaFrode/go-libp2p/core/peer"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newThrottledPipe(t *testing.T) *throttledConn {
+	t.Helper()
+	throttler := NewThrottler(ThrottleLimits{Conn: Limit{Rate: 1000, Burst: 100}})
+	a, b := net.Pipe()
+	t.Cleanup(func() { b.Close() })
+	go io.Copy(io.Discard, b)
+	c := throttler.wrap(a)
+	t.Cleanup(func() { c.Close() })
+	// use up the burst
+	_, err := c.Write(make([]byte, 100))
+	require.NoError(t, err)
+	return c
+}
+
+func TestThrottledWriteDeadline(t *testing.T) {
+	c := newThrottledPipe(t)
+	require.NoError(t, c.SetWriteDeadline(time.Now().Add(50*time.Millisecond)))
+	start := time.Now()
+	_, err := c.Write(make([]byte, 100))
+	require.ErrorIs(t, err, os.ErrDeadlineExceeded)
+	require.Less(t, time.Since(start), 80*time.Millisecond)
+}
+
+func TestThrottledWriteDeadlineUpdated(t *testing.T) {
+	c := newThrottledPipe(t)
+	done := make(chan error, 1)
+	go func() {
+		_, err := c.Write(make([]byte, 100))
+		done <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	// setting a deadline in the past interrupts the pending write
+	require.NoError(t, c.SetWriteDeadline(time.Now()))
+	select {
+	case err := <-done:
+		require.ErrorIs(t, err, os.ErrDeadlineExceeded)
+	case <-time.After(50 * time.Millisecond):
+		t.Fatal("write wasn't interrupted")
+	}
+}
+
+func TestThrottledWriteClose(t *testing.T) {
+	c := newThrottledPipe(t)
+	done := make(chan error, 1)
+	go func() {
+		_, err := c.Write(make([]byte, 100))
+		done <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	require.NoError(t, c.Close())
+	select {
+	case err := <-done:
+		require.ErrorIs(t, err, net.ErrClosed)
+	case <-time.After(50 * time.Millisecond):
+		t.Fatal("write wasn't interrupted")
+	}
+}
+
+func TestThrottlerPeerBucketsGC(t *testing.T) {
+	throttler := NewThrottler(ThrottleLimits{Peer: Limit{Rate: 1000, Burst: 100}})
+	p := peer.ID("p")
+	a, b := net.Pipe()
+	defer b.Close()
+	c := throttler.wrap(a)
+	c.setPeer(p)
+	pb := c.peer.Load()
+
+	sweep := func() {
+		pb.lastUsed.Store(time.Now().Add(-2 * peerBucketsIdleTimeout).UnixNano())
+		throttler.lastSweep = time.Time{}
+		throttler.releasePeerBuckets(throttler.getPeerBuckets("other"))
+	}
+
+	// the buckets are still used by a connection
+	sweep()
+	require.Same(t, pb, throttler.peers[p])
+
+	// the buckets didn't fill up yet
+	require.NoError(t, c.Close())
+	pb.out.reserve(time.Now(), throttler.Limits().Peer, 1000)
+	sweep()
+	require.Same(t, pb, throttler.peers[p])
+
+	pb.out.mx.Lock()
+	pb.out.last = time.Now().Add(-2 * time.Second)
+	pb.out.mx.Unlock()
+	sweep()
+	require.NotContains(t, throttler.peers, p)
+}
@@ -18,8 +18,13 @@ import (
 
 	manet "github.com/multiformats/go-multiaddr/net"
 	mss "github.com/multiformats/go-multistream"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
+const tracerName = "github.com/AstaFrode/go-libp2p/p2p/net/upgrader"
+
 // ErrNilPeer is returned when attempting to upgrade an outbound connection
 // without specifying a peer ID.
 var ErrNilPeer = errors.New("nil peer")
@@ -41,6 +46,80 @@ func WithAcceptTimeout(t time.Duration) Option {
 	}
 }
 
+// WithSecurityHandshakeTimeout sets the maximum duration of the security protocol
+// negotiation and handshake. By default, only the timeout of the whole upgrade applies.
+func WithSecurityHandshakeTimeout(t time.Duration) Option {
+	return func(u *upgrader) error {
+		if t < 0 {
+			return errors.New("security handshake timeout must not be negative")
+		}
+		u.securityTimeout = t
+		return nil
+	}
+}
+
+// WithMuxerNegotiationTimeout sets the maximum duration of the stream multiplexer
+// negotiation. Defaults to 60s.
+func WithMuxerNegotiationTimeout(t time.Duration) Option {
+	return func(u *upgrader) error {
+		if t < 0 {
+			return errors.New("muxer negotiation timeout must not be negative")
+		}
+		u.muxerTimeout = t
+		return nil
+	}
+}
+
+// WithConnectionGaterV2 sets a context-aware connection gater.
+// It takes precedence over the connection gater passed to New.
+func WithConnectionGaterV2(g connmgr.ConnectionGaterV2) Option {
+	return func(u *upgrader) error {
+		u.connGater = g
+		return nil
+	}
+}
+
+// WithTracerProvider sets the OpenTelemetry TracerProvider used to create spans
+// for the security handshake and the muxer negotiation.
+// By default, no spans are recorded.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(u *upgrader) error {
+		u.tracer = tp.Tracer(tracerName)
+		return nil
+	}
+}
+
+// WithMetricsTracer sets the tracer used to record the duration of the
+// security handshake and the muxer negotiation.
+func WithMetricsTracer(mt MetricsTracer) Option {
+	return func(u *upgrader) error {
+		u.metricsTracer = mt
+		return nil
+	}
+}
+
+// WithInterceptor adds an Interceptor that is invoked at every step of the upgrade.
+// Interceptors are called in the order they were added.
+func WithInterceptor(i Interceptor) Option {
+	return func(u *upgrader) error {
+		if i == nil {
+			return errors.New("interceptor cannot be nil")
+		}
+		u.interceptors = append(u.interceptors, i)
+		return nil
+	}
+}
+
+// WithThrottler sets a Throttler limiting the bandwidth of the upgraded connections.
+// The Throttler may be shared between upgraders. Its metrics are reported to the
+// MetricsTracer passed to NewThrottler, not to the upgrader's.
+func WithThrottler(t *Throttler) Option {
+	return func(u *upgrader) error {
+		u.throttler = t
+		return nil
+	}
+}
+
 type StreamMuxer struct {
 	ID    protocol.ID
 	Muxer network.Multiplexer
@@ -50,7 +129,7 @@ type StreamMuxer struct {
 // to a full transport connection (secure and multiplexed).
 type upgrader struct {
 	psk       ipnet.PSK
-	connGater connmgr.ConnectionGater
+	connGater connmgr.ConnectionGaterV2
 	rcmgr     network.ResourceManager
 
 	muxerMuxer *mss.MultistreamMuxer[protocol.ID]
@@ -67,6 +146,16 @@ type upgrader struct {
 	//
 	// If unset, the default value (15s) is used.
 	acceptTimeout time.Duration
+	// securityTimeout and muxerTimeout limit the duration of the individual
+	// upgrade stages. They are bounded by the timeout of the whole upgrade.
+	securityTimeout time.Duration
+	muxerTimeout    time.Duration
+
+	tracer        trace.Tracer
+	metricsTracer MetricsTracer
+	interceptors  []Interceptor
+	throttler     *Throttler
+	admission     *admission
 }
 
 var _ transport.Upgrader = &upgrader{}
@@ -74,13 +163,16 @@ var _ transport.Upgrader = &upgrader{}
 func New(security []sec.SecureTransport, muxers []StreamMuxer, psk ipnet.PSK, rcmgr network.ResourceManager, connGater connmgr.ConnectionGater, opts ...Option) (transport.Upgrader, error) {
 	u := &upgrader{
 		acceptTimeout: defaultAcceptTimeout,
+		muxerTimeout:  defaultNegotiateTimeout,
 		rcmgr:         rcmgr,
-		connGater:     connGater,
+		connGater:     connmgr.AsGaterV2(connGater),
 		psk:           psk,
 		muxerMuxer:    mss.NewMultistreamMuxer[protocol.ID](),
 		muxers:        muxers,
 		security:      security,
 		securityMuxer: mss.NewMultistreamMuxer[protocol.ID](),
+		tracer:        trace.NewNoopTracerProvider().Tracer(tracerName),
+		admission:     newAdmission(),
 	}
 	for _, opt := range opts {
 		if err := opt(u); err != nil {
@@ -139,7 +231,20 @@ func (u *upgrader) upgrade(ctx context.Context, t transport.Transport, maconn ma
 		stat = cs.Stat()
 	}
 
+	info := &UpgradeInfo{Direction: dir, Transport: t, Conn: maconn, Metadata: make(map[string]string)}
+	for _, i := range u.interceptors {
+		if err := i.InterceptRaw(ctx, info); err != nil {
+			maconn.Close()
+			return nil, fmt.Errorf("interceptor rejected raw connection: %w", err)
+		}
+	}
+
 	var conn net.Conn = maconn
+	var tconn *throttledConn
+	if u.throttler != nil {
+		tconn = u.throttler.wrap(conn)
+		conn = tconn
+	}
 	if u.psk != nil {
 		pconn, err := pnet.NewProtectedConn(u.psk, conn)
 		if err != nil {
@@ -152,20 +257,50 @@ func (u *upgrader) upgrade(ctx context.Context, t transport.Transport, maconn ma
 		return nil, ipnet.ErrNotInPrivateNetwork
 	}
 
-	sconn, security, server, err := u.setupSecurity(ctx, conn, p, dir)
+	secStart := time.Now()
+	secCtx := ctx
+	if u.securityTimeout > 0 {
+		var cancel context.CancelFunc
+		secCtx, cancel = context.WithTimeout(ctx, u.securityTimeout)
+		defer cancel()
+	}
+	secCtx, span := u.tracer.Start(secCtx, "upgrader.SecurityHandshake", trace.WithAttributes(attribute.Stringer("direction", dir)))
+	sconn, security, server, err := u.setupSecurity(secCtx, conn, p, dir)
 	if err != nil {
+		endSpan(span, err)
 		conn.Close()
 		return nil, fmt.Errorf("failed to negotiate security protocol: %w", err)
 	}
+	secDuration := time.Since(secStart)
+	span.SetAttributes(attribute.String("security", string(security)), attribute.Stringer("peer", sconn.RemotePeer()))
+	span.End()
+	if u.metricsTracer != nil {
+		u.metricsTracer.SecurityHandshakeCompleted(dir, security, secDuration)
+	}
 
 	// call the connection gater, if one is registered.
-	if u.connGater != nil && !u.connGater.InterceptSecured(dir, sconn.RemotePeer(), maconn) {
-		if err := maconn.Close(); err != nil {
-			log.Errorw("failed to close connection", "peer", p, "addr", maconn.RemoteMultiaddr(), "error", err)
+	if u.connGater != nil {
+		if v := u.connGater.InterceptSecured(ctx, dir, sconn.RemotePeer(), maconn); !v.Wait(ctx) {
+			if err := maconn.Close(); err != nil {
+				log.Errorw("failed to close connection", "peer", p, "addr", maconn.RemoteMultiaddr(), "error", err)
+			}
+			return nil, fmt.Errorf("gater rejected connection with peer %s and addr %s with direction %d: %s",
+				sconn.RemotePeer(), maconn.RemoteMultiaddr(), dir, v)
 		}
-		return nil, fmt.Errorf("gater rejected connection with peer %s and addr %s with direction %d",
-			sconn.RemotePeer(), maconn.RemoteMultiaddr(), dir)
 	}
+	if tconn != nil {
+		tconn.setPeer(sconn.RemotePeer())
+	}
+	info.RemotePeer = sconn.RemotePeer()
+	info.RemotePublicKey = sconn.RemotePublicKey()
+	info.Security = security
+	for _, i := range u.interceptors {
+		if err := i.InterceptSecured(ctx, info); err != nil {
+			sconn.Close()
+			return nil, fmt.Errorf("interceptor rejected secured connection with peer %s: %w", sconn.RemotePeer(), err)
+		}
+	}
+
 	// Only call SetPeer if it hasn't already been set -- this can happen when we don't know
 	// the peer in advance and in some bug scenarios.
 	if connScope.PeerScope() == nil {
@@ -179,12 +314,38 @@ func (u *upgrader) upgrade(ctx context.Context, t transport.Transport, maconn ma
 		}
 	}
 
-	muxer, smconn, err := u.setupMuxer(ctx, sconn, server, connScope.PeerScope())
+	muxStart := time.Now()
+	muxCtx := ctx
+	if u.muxerTimeout > 0 {
+		var cancel context.CancelFunc
+		muxCtx, cancel = context.WithTimeout(ctx, u.muxerTimeout)
+		defer cancel()
+	}
+	muxCtx, span = u.tracer.Start(muxCtx, "upgrader.MuxerNegotiation", trace.WithAttributes(
+		attribute.Stringer("direction", dir),
+		attribute.Bool("early_muxer", sconn.ConnState().UsedEarlyMuxerNegotiation),
+	))
+	muxer, smconn, err := u.setupMuxer(muxCtx, sconn, server, connScope.PeerScope())
 	if err != nil {
+		endSpan(span, err)
 		sconn.Close()
 		return nil, fmt.Errorf("failed to negotiate stream multiplexer: %w", err)
 	}
+	span.SetAttributes(attribute.String("muxer", string(muxer)))
+	span.End()
+	if u.metricsTracer != nil {
+		u.metricsTracer.MuxerNegotiationCompleted(dir, muxer, sconn.ConnState().UsedEarlyMuxerNegotiation, time.Since(muxStart))
+	}
 
+	info.Muxer = muxer
+	for _, i := range u.interceptors {
+		if err := i.InterceptMuxed(ctx, info); err != nil {
+			smconn.Close()
+			return nil, fmt.Errorf("interceptor rejected muxed connection with peer %s: %w", sconn.RemotePeer(), err)
+		}
+	}
+
+	secState := sconn.ConnState()
 	tc := &transportConn{
 		MuxedConn:                 smconn,
 		ConnMultiaddrs:            maconn,
@@ -194,7 +355,13 @@ func (u *upgrader) upgrade(ctx context.Context, t transport.Transport, maconn ma
 		scope:                     connScope,
 		muxer:                     muxer,
 		security:                  security,
-		usedEarlyMuxerNegotiation: sconn.ConnState().UsedEarlyMuxerNegotiation,
+		usedEarlyMuxerNegotiation: secState.UsedEarlyMuxerNegotiation,
+		cipherSuite:               secState.CipherSuite,
+		resumed:                   secState.Resumed,
+		handshakeDuration:         secDuration,
+	}
+	if len(info.Metadata) > 0 {
+		tc.metadata = info.Metadata
 	}
 	return tc, nil
 }
@@ -216,8 +383,10 @@ func (u *upgrader) setupSecurity(ctx context.Context, conn net.Conn, p peer.ID,
 }
 
 func (u *upgrader) negotiateMuxer(nc net.Conn, isServer bool) (*StreamMuxer, error) {
-	if err := nc.SetDeadline(time.Now().Add(defaultNegotiateTimeout)); err != nil {
-		return nil, err
+	if u.muxerTimeout > 0 {
+		if err := nc.SetDeadline(time.Now().Add(u.muxerTimeout)); err != nil {
+			return nil, err
+		}
 	}
 
 	var proto protocol.ID
@@ -346,3 +515,10 @@ func (u *upgrader) negotiateSecurity(ctx context.Context, insecure net.Conn, ser
 		return nil, false, ctx.Err()
 	}
 }
+
+// endSpan ends the span, recording the error.
+func endSpan(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+	span.End()
+}
@@ -4,16 +4,21 @@ import (
 	"context"
 	"crypto/rand"
 	"errors"
+	"io"
 	"net"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/AstaFrode/go-libp2p/core/connmgr"
 	"github.com/AstaFrode/go-libp2p/core/crypto"
 	"github.com/AstaFrode/go-libp2p/core/network"
 	mocknetwork "github.com/AstaFrode/go-libp2p/core/network/mocks"
 	"github.com/AstaFrode/go-libp2p/core/peer"
+	"github.com/AstaFrode/go-libp2p/core/protocol"
 	"github.com/AstaFrode/go-libp2p/core/sec"
 	"github.com/AstaFrode/go-libp2p/core/sec/insecure"
+	"github.com/AstaFrode/go-libp2p/core/test"
 	"github.com/AstaFrode/go-libp2p/core/transport"
 	"github.com/AstaFrode/go-libp2p/p2p/muxer/yamux"
 	"github.com/AstaFrode/go-libp2p/p2p/net/upgrader"
@@ -202,3 +207,246 @@ func TestOutboundResourceManagement(t *testing.T) {
 		require.Error(t, err)
 	})
 }
+
+type handshake struct {
+	dir        network.Direction
+	proto      protocol.ID
+	earlyMuxer bool
+}
+
+type recordingMetricsTracer struct {
+	mx             sync.Mutex
+	security       []handshake
+	muxers         []handshake
+	throttledBytes int
+}
+
+var _ upgrader.MetricsTracer = &recordingMetricsTracer{}
+
+func (m *recordingMetricsTracer) SecurityHandshakeCompleted(dir network.Direction, security protocol.ID, _ time.Duration) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	m.security = append(m.security, handshake{dir: dir, proto: security})
+}
+
+func (m *recordingMetricsTracer) MuxerNegotiationCompleted(dir network.Direction, muxer protocol.ID, earlyMuxer bool, _ time.Duration) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	m.muxers = append(m.muxers, handshake{dir: dir, proto: muxer, earlyMuxer: earlyMuxer})
+}
+
+func (m *recordingMetricsTracer) BytesThrottled(_ network.Direction, n int, _ time.Duration) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	m.throttledBytes += n
+}
+
+func TestUpgraderMetrics(t *testing.T) {
+	serverTracer := &recordingMetricsTracer{}
+	id, u := createUpgraderWithOpts(t, upgrader.WithMetricsTracer(serverTracer))
+	ln := createListener(t, u)
+	defer ln.Close()
+
+	clientTracer := &recordingMetricsTracer{}
+	_, cu := createUpgraderWithOpts(t, upgrader.WithMetricsTracer(clientTracer))
+	cconn, err := dial(t, cu, ln.Multiaddr(), id, &network.NullScope{})
+	require.NoError(t, err)
+	defer cconn.Close()
+	sconn, err := ln.Accept()
+	require.NoError(t, err)
+	defer sconn.Close()
+
+	require.Equal(t, []handshake{{dir: network.DirOutbound, proto: insecure.ID}}, clientTracer.security)
+	require.Equal(t, []handshake{{dir: network.DirOutbound, proto: "negotiate"}}, clientTracer.muxers)
+	serverTracer.mx.Lock()
+	defer serverTracer.mx.Unlock()
+	require.Equal(t, []handshake{{dir: network.DirInbound, proto: insecure.ID}}, serverTracer.security)
+	require.Equal(t, []handshake{{dir: network.DirInbound, proto: "negotiate"}}, serverTracer.muxers)
+}
+
+func TestSecurityHandshakeTimeout(t *testing.T) {
+	// a listener that accepts connections, but never responds
+	ln, err := manet.Listen(ma.StringCast("/ip4/127.0.0.1/tcp/0"))
+	require.NoError(t, err)
+	defer ln.Close()
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer c.Close()
+		}
+	}()
+
+	_, u := createUpgraderWithOpts(t, upgrader.WithSecurityHandshakeTimeout(100*time.Millisecond))
+	start := time.Now()
+	_, err = dial(t, u, ln.Multiaddr(), test.RandPeerIDFatal(t), &network.NullScope{})
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Less(t, time.Since(start), 5*time.Second)
+}
+
+type recordingInterceptor struct {
+	mx        sync.Mutex
+	steps     []string
+	rejectAt  string
+	remote    peer.ID
+	muxer     protocol.ID
+	security  protocol.ID
+	direction network.Direction
+}
+
+func (i *recordingInterceptor) record(step string, info *upgrader.UpgradeInfo) error {
+	i.mx.Lock()
+	defer i.mx.Unlock()
+	i.steps = append(i.steps, step)
+	i.remote = info.RemotePeer
+	i.security = info.Security
+	i.muxer = info.Muxer
+	i.direction = info.Direction
+	info.Metadata[step] = "ok"
+	if step == i.rejectAt {
+		return errors.New("rejected")
+	}
+	return nil
+}
+
+func (i *recordingInterceptor) InterceptRaw(_ context.Context, info *upgrader.UpgradeInfo) error {
+	return i.record("raw", info)
+}
+
+func (i *recordingInterceptor) InterceptSecured(_ context.Context, info *upgrader.UpgradeInfo) error {
+	return i.record("secured", info)
+}
+
+func (i *recordingInterceptor) InterceptMuxed(_ context.Context, info *upgrader.UpgradeInfo) error {
+	return i.record("muxed", info)
+}
+
+func TestInterceptor(t *testing.T) {
+	id, u := createUpgrader(t)
+	ln := createListener(t, u)
+	defer ln.Close()
+
+	interceptor := &recordingInterceptor{}
+	_, cu := createUpgraderWithOpts(t, upgrader.WithInterceptor(interceptor))
+	cconn, err := dial(t, cu, ln.Multiaddr(), id, &network.NullScope{})
+	require.NoError(t, err)
+	defer cconn.Close()
+	sconn, err := ln.Accept()
+	require.NoError(t, err)
+	defer sconn.Close()
+
+	require.Equal(t, []string{"raw", "secured", "muxed"}, interceptor.steps)
+	require.Equal(t, id, interceptor.remote)
+	require.Equal(t, protocol.ID(insecure.ID), interceptor.security)
+	require.Equal(t, protocol.ID("negotiate"), interceptor.muxer)
+	require.Equal(t, network.DirOutbound, interceptor.direction)
+	require.Equal(t, map[string]string{"raw": "ok", "secured": "ok", "muxed": "ok"}, cconn.ConnState().Metadata)
+	require.Empty(t, sconn.ConnState().Metadata)
+}
+
+func TestConnStateHandshakeDuration(t *testing.T) {
+	id, u := createUpgrader(t)
+	ln := createListener(t, u)
+	defer ln.Close()
+
+	_, cu := createUpgrader(t)
+	cconn, err := dial(t, cu, ln.Multiaddr(), id, &network.NullScope{})
+	require.NoError(t, err)
+	defer cconn.Close()
+	sconn, err := ln.Accept()
+	require.NoError(t, err)
+	defer sconn.Close()
+
+	require.Positive(t, cconn.ConnState().HandshakeDuration)
+	require.Positive(t, sconn.ConnState().HandshakeDuration)
+	require.False(t, cconn.ConnState().Resumed)
+}
+
+func TestInterceptorReject(t *testing.T) {
+	for _, step := range []string{"raw", "secured", "muxed"} {
+		t.Run(step, func(t *testing.T) {
+			interceptor := &recordingInterceptor{rejectAt: step}
+			id, u := createUpgraderWithOpts(t, upgrader.WithInterceptor(interceptor))
+			ln := createListener(t, u)
+			defer ln.Close()
+
+			_, cu := createUpgrader(t)
+			// the client might succeed, if the server aborts after the muxer negotiation
+			if conn, err := dial(t, cu, ln.Multiaddr(), id, &network.NullScope{}); err == nil {
+				defer conn.Close()
+			}
+
+			require.Eventually(t, func() bool {
+				interceptor.mx.Lock()
+				defer interceptor.mx.Unlock()
+				return len(interceptor.steps) > 0 && interceptor.steps[len(interceptor.steps)-1] == step
+			}, 5*time.Second, 10*time.Millisecond)
+
+			acceptErr := make(chan error, 1)
+			go func() {
+				_, err := ln.Accept()
+				acceptErr <- err
+			}()
+			select {
+			case err := <-acceptErr:
+				t.Fatalf("accepted a rejected connection: %v", err)
+			case <-time.After(200 * time.Millisecond):
+			}
+		})
+	}
+}
+
+func TestThrottler(t *testing.T) {
+	id, u := createUpgrader(t)
+	ln := createListener(t, u)
+	defer ln.Close()
+
+	tracer := &recordingMetricsTracer{}
+	throttler := upgrader.NewThrottler(
+		upgrader.ThrottleLimits{Conn: upgrader.Limit{Rate: 100 << 10, Burst: 10 << 10}},
+		upgrader.WithThrottlerMetricsTracer(tracer),
+	)
+	_, cu := createUpgraderWithOpts(t, upgrader.WithThrottler(throttler))
+	cconn, err := dial(t, cu, ln.Multiaddr(), id, &network.NullScope{})
+	require.NoError(t, err)
+	defer cconn.Close()
+	sconn, err := ln.Accept()
+	require.NoError(t, err)
+	defer sconn.Close()
+
+	transfer := func(size int) time.Duration {
+		t.Helper()
+		str, err := cconn.OpenStream(context.Background())
+		require.NoError(t, err)
+		defer str.Close()
+		start := time.Now()
+		done := make(chan error, 1)
+		go func() {
+			_, err := str.Write(make([]byte, size))
+			done <- err
+		}()
+		sstr, err := sconn.AcceptStream()
+		require.NoError(t, err)
+		defer sstr.Close()
+		_, err = io.ReadFull(sstr, make([]byte, size))
+		require.NoError(t, err)
+		require.NoError(t, <-done)
+		return time.Since(start)
+	}
+
+	// 10K can be sent right away, the remaining 40K take 400ms
+	require.GreaterOrEqual(t, transfer(50<<10), 300*time.Millisecond)
+	stats := throttler.Stats()
+	require.NotZero(t, stats.ThrottledBytesOut)
+	require.NotZero(t, stats.DelayOut)
+	require.Zero(t, stats.ThrottledBytesIn)
+	tracer.mx.Lock()
+	require.Equal(t, int(stats.ThrottledBytesOut), tracer.throttledBytes)
+	tracer.mx.Unlock()
+
+	// limits can be updated at runtime
+	throttler.SetLimits(upgrader.ThrottleLimits{})
+	require.Less(t, transfer(1<<20), 2*time.Second)
+}
@@ -0,0 +1,79 @@
+package client
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/AstaFrode/go-libp2p/core/crypto"
+	"github.com/AstaFrode/go-libp2p/core/peer"
+	"github.com/AstaFrode/go-libp2p/core/record"
+	pbv2 "github.com/AstaFrode/go-libp2p/p2p/protocol/circuitv2/pb"
+	"github.com/AstaFrode/go-libp2p/p2p/protocol/circuitv2/proto"
+	"github.com/AstaFrode/go-libp2p/p2p/protocol/circuitv2/util"
+
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/require"
+)
+
+// FuzzReservationResponse parses arbitrary reservation responses, as read from the wire.
+func FuzzReservationResponse(f *testing.F) {
+	priv, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	require.NoError(f, err)
+	relay, err := peer.IDFromPrivateKey(priv)
+	require.NoError(f, err)
+	expire := time.Now().Add(time.Hour)
+	env, err := record.Seal(&proto.ReservationVoucher{Relay: relay, Peer: relay, Expiration: expire}, priv)
+	require.NoError(f, err)
+	voucher, err := env.Marshal()
+	require.NoError(f, err)
+	expireUnix := uint64(expire.Unix())
+	duration, data := uint32(120), uint64(1<<17)
+
+	var buf bytes.Buffer
+	require.NoError(f, util.NewDelimitedWriter(&buf).WriteMsg(&pbv2.HopMessage{
+		Type:   pbv2.HopMessage_STATUS.Enum(),
+		Status: pbv2.Status_OK.Enum(),
+		Reservation: &pbv2.Reservation{
+			Expire:  &expireUnix,
+			Addrs:   [][]byte{ma.StringCast("/ip4/1.2.3.4/tcp/1").Bytes()},
+			Voucher: voucher,
+		},
+		Limit: &pbv2.Limit{Duration: &duration, Data: &data},
+	}))
+	f.Add(buf.Bytes())
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		rd := util.NewDelimitedReader(bytes.NewReader(b), maxMessageSize)
+		defer rd.Close()
+		var msg pbv2.HopMessage
+		if err := rd.ReadMsg(&msg); err != nil {
+			return
+		}
+		reservationFromMessage(&msg)
+	})
+}
+
+// FuzzStopMessage parses arbitrary STOP messages, as read from the wire.
+func FuzzStopMessage(f *testing.F) {
+	var buf bytes.Buffer
+	require.NoError(f, util.NewDelimitedWriter(&buf).WriteMsg(&pbv2.StopMessage{
+		Type: pbv2.StopMessage_CONNECT.Enum(),
+		Peer: util.PeerInfoToPeerV2(peer.AddrInfo{
+			ID:    "QmcgpsyWgH8Y8ajJz1Cu72KnS5uo2Aa2LpzU7kinSupNKC",
+			Addrs: []ma.Multiaddr{ma.StringCast("/ip4/1.2.3.4/tcp/1")},
+		}),
+	}))
+	f.Add(buf.Bytes())
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		rd := util.NewDelimitedReader(bytes.NewReader(b), maxMessageSize)
+		defer rd.Close()
+		var msg pbv2.StopMessage
+		if err := rd.ReadMsg(&msg); err != nil {
+			return
+		}
+		util.PeerToPeerInfoV2(msg.GetPeer())
+	})
+}
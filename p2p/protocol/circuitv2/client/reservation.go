@@ -18,6 +18,12 @@ import (
 
 var ReserveTimeout = time.Minute
 
+// The observations about relays reported to the peerstore.ScoreBook.
+const (
+	scoreReservationSuccess = 1
+	scoreReservationFailure = -1
+)
+
 // Reservation is a struct carrying information about a relay/v2 slot reservation.
 type Reservation struct {
 	// Expiration is the expiration time of the reservation
@@ -39,7 +45,22 @@ type Reservation struct {
 
 // Reserve reserves a slot in a relay and returns the reservation information.
 // Clients must reserve slots in order for the relay to relay connections to them.
+// The outcome is reported to the peerstore's ScoreBook, if it has one.
 func Reserve(ctx context.Context, h host.Host, ai peer.AddrInfo) (*Reservation, error) {
+	rsvp, err := reserve(ctx, h, ai)
+	if sb, ok := peerstore.GetScoreBook(h.Peerstore()); ok {
+		switch {
+		case err == nil:
+			sb.RecordObservation(ai.ID, peerstore.ScoreSourceRelay, scoreReservationSuccess)
+		case ctx.Err() == nil:
+			// don't penalize the relay if we gave up on the reservation
+			sb.RecordObservation(ai.ID, peerstore.ScoreSourceRelay, scoreReservationFailure)
+		}
+	}
+	return rsvp, err
+}
+
+func reserve(ctx context.Context, h host.Host, ai peer.AddrInfo) (*Reservation, error) {
 	if len(ai.Addrs) > 0 {
 		h.Peerstore().AddAddrs(ai.ID, ai.Addrs, peerstore.TempAddrTTL)
 	}
@@ -71,6 +92,11 @@ func Reserve(ctx context.Context, h host.Host, ai peer.AddrInfo) (*Reservation,
 		return nil, fmt.Errorf("error reading reservation response message: %w", err)
 	}
 
+	return reservationFromMessage(&msg)
+}
+
+// reservationFromMessage parses the reservation from the relay's response.
+func reservationFromMessage(msg *pbv2.HopMessage) (*Reservation, error) {
 	if msg.GetType() != pbv2.HopMessage_STATUS {
 		return nil, fmt.Errorf("unexpected relay response: not a status message (%d)", msg.GetType())
 	}
@@ -66,3 +66,39 @@ func TestReservationVoucher(t *testing.T) {
 		t.Fatal("expirations don't match")
 	}
 }
+
+func FuzzReservationVoucher(f *testing.F) {
+	priv, _, err := crypto.GenerateKeyPair(crypto.Ed25519, 0)
+	if err != nil {
+		f.Fatal(err)
+	}
+	id, err := peer.IDFromPrivateKey(priv)
+	if err != nil {
+		f.Fatal(err)
+	}
+	rsvp := &ReservationVoucher{Relay: id, Peer: id, Expiration: time.Now()}
+	blob, err := rsvp.MarshalRecord()
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(blob)
+	env, err := record.Seal(rsvp, priv)
+	if err != nil {
+		f.Fatal(err)
+	}
+	envBytes, err := env.Marshal()
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(envBytes)
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var rv ReservationVoucher
+		if err := rv.UnmarshalRecord(data); err == nil {
+			if _, err := rv.MarshalRecord(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		record.ConsumeEnvelope(data, RecordDomain)
+	})
+}
@@ -25,3 +25,27 @@ type AddrFilter interface {
 	// FilterRemote filters the multi addresses received from the remote peer.
 	FilterRemote(remoteID peer.ID, maddrs []ma.Multiaddr) []ma.Multiaddr
 }
+
+// WithAddrPrioritizer is a Service option that orders the multi addresses
+// exchanged during hole punching. Our observed addresses are sent to the remote
+// peer, and the addresses announced by the remote peer are dialed, in order of
+// decreasing priority. E.g., prefer QUIC addresses, which are more likely to
+// punch through a NAT than TCP addresses.
+// Addresses are prioritized after being filtered by the AddrFilter, if any.
+// Note that the dialer still applies its own ranking, so priorities are a hint.
+func WithAddrPrioritizer(p AddrPrioritizer) Option {
+	return func(hps *Service) error {
+		hps.prioritizer = p
+		return nil
+	}
+}
+
+// AddrPrioritizer defines the interface for prioritizing multi addresses.
+// Addresses with a higher priority come first. Addresses with the same
+// priority keep their original order.
+type AddrPrioritizer interface {
+	// PriorityLocal returns the priority of an address sent to the remote peer.
+	PriorityLocal(remoteID peer.ID, maddr ma.Multiaddr) int
+	// PriorityRemote returns the priority of an address received from the remote peer.
+	PriorityRemote(remoteID peer.ID, maddr ma.Multiaddr) int
+}
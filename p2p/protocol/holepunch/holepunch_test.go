@@ -0,0 +1,172 @@
+package holepunch
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/AstaFrode/go-libp2p/core/network"
+	"github.com/AstaFrode/go-libp2p/core/peer"
+	"github.com/AstaFrode/go-libp2p/p2p/protocol/identify"
+
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/require"
+)
+
+type mockIDService struct {
+	identify.IDService
+	addrs []ma.Multiaddr
+}
+
+func (s *mockIDService) OwnObservedAddrs() []ma.Multiaddr {
+	return append([]ma.Multiaddr(nil), s.addrs...)
+}
+
+type mockConn struct {
+	network.Conn
+	remote     peer.ID
+	remoteAddr ma.Multiaddr
+}
+
+func (c *mockConn) RemotePeer() peer.ID           { return c.remote }
+func (c *mockConn) RemoteMultiaddr() ma.Multiaddr { return c.remoteAddr }
+
+type mockStream struct {
+	network.Stream
+	pipe net.Conn
+	conn *mockConn
+}
+
+func (s *mockStream) Read(b []byte) (int, error)    { return s.pipe.Read(b) }
+func (s *mockStream) Write(b []byte) (int, error)   { return s.pipe.Write(b) }
+func (s *mockStream) Close() error                  { return s.pipe.Close() }
+func (s *mockStream) Reset() error                  { return s.pipe.Close() }
+func (s *mockStream) Scope() network.StreamScope    { return &network.NullScope{} }
+func (s *mockStream) SetDeadline(t time.Time) error { return s.pipe.SetDeadline(t) }
+func (s *mockStream) Conn() network.Conn            { return s.conn }
+
+func newMockStreams(t *testing.T) (initiator, receiver *mockStream) {
+	relay := ma.StringCast("/ip4/1.1.1.1/tcp/1/p2p/QmZoW8y1o4SAQp1nrqXzHk4S9vMZ6gFRNPMnL9i2GSxMvx/p2p-circuit")
+	a, b := net.Pipe()
+	t.Cleanup(func() {
+		a.Close()
+		b.Close()
+	})
+	initiator = &mockStream{pipe: a, conn: &mockConn{remote: "receiver", remoteAddr: relay}}
+	receiver = &mockStream{pipe: b, conn: &mockConn{remote: "initiator", remoteAddr: relay}}
+	return initiator, receiver
+}
+
+// preferQUIC gives QUIC addresses a higher priority and records the addresses it saw.
+type preferQUIC struct {
+	local, remote bool
+	seen          []ma.Multiaddr
+}
+
+func (p *preferQUIC) priority(enabled bool, a ma.Multiaddr) int {
+	p.seen = append(p.seen, a)
+	if !enabled {
+		return 0
+	}
+	if _, err := a.ValueForProtocol(ma.P_QUIC_V1); err == nil {
+		return 1
+	}
+	return 0
+}
+
+func (p *preferQUIC) PriorityLocal(_ peer.ID, a ma.Multiaddr) int  { return p.priority(p.local, a) }
+func (p *preferQUIC) PriorityRemote(_ peer.ID, a ma.Multiaddr) int { return p.priority(p.remote, a) }
+
+// dropAddr filters out a single address, both when sending and receiving.
+type dropAddr struct{ addr ma.Multiaddr }
+
+func (f *dropAddr) filter(addrs []ma.Multiaddr) []ma.Multiaddr {
+	out := make([]ma.Multiaddr, 0, len(addrs))
+	for _, a := range addrs {
+		if !a.Equal(f.addr) {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+func (f *dropAddr) FilterLocal(_ peer.ID, addrs []ma.Multiaddr) []ma.Multiaddr {
+	return f.filter(addrs)
+}
+func (f *dropAddr) FilterRemote(_ peer.ID, addrs []ma.Multiaddr) []ma.Multiaddr {
+	return f.filter(addrs)
+}
+
+func TestPrioritizeAddrs(t *testing.T) {
+	tcp1 := ma.StringCast("/ip4/1.2.3.4/tcp/1")
+	tcp2 := ma.StringCast("/ip4/1.2.3.4/tcp/2")
+	quic1 := ma.StringCast("/ip4/1.2.3.4/udp/1/quic-v1")
+	quic2 := ma.StringCast("/ip4/1.2.3.4/udp/2/quic-v1")
+
+	p := &preferQUIC{local: true}
+	addrs := prioritizeAddrs([]ma.Multiaddr{tcp1, quic1, tcp2, quic2}, "peer", p.PriorityLocal)
+	require.Equal(t, []ma.Multiaddr{quic1, quic2, tcp1, tcp2}, addrs)
+}
+
+func runHolePunchExchange(t *testing.T, initAddrs, recvAddrs []ma.Multiaddr, filter AddrFilter, initPrio, recvPrio AddrPrioritizer) (initGot, recvGot []ma.Multiaddr) {
+	t.Helper()
+	initStr, recvStr := newMockStreams(t)
+	hp := &holePuncher{ids: &mockIDService{addrs: initAddrs}, filter: filter, prioritizer: initPrio}
+	s := &Service{ids: &mockIDService{addrs: recvAddrs}, filter: filter, prioritizer: recvPrio}
+
+	type result struct {
+		addrs []ma.Multiaddr
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		_, addrs, err := s.incomingHolePunch(recvStr)
+		done <- result{addrs, err}
+	}()
+	initGot, _, err := hp.initiateHolePunchImpl(initStr)
+	require.NoError(t, err)
+	res := <-done
+	require.NoError(t, res.err)
+	return initGot, res.addrs
+}
+
+func TestAddrPrioritizer(t *testing.T) {
+	tcp := ma.StringCast("/ip4/1.2.3.4/tcp/1")
+	quic := ma.StringCast("/ip4/1.2.3.4/udp/1/quic-v1")
+	tcp2 := ma.StringCast("/ip4/5.6.7.8/tcp/1")
+	quic2 := ma.StringCast("/ip4/5.6.7.8/udp/1/quic-v1")
+
+	t.Run("local", func(t *testing.T) {
+		// The remote priority is flat, so the received order is the order the sender chose.
+		initGot, recvGot := runHolePunchExchange(t,
+			[]ma.Multiaddr{tcp, quic}, []ma.Multiaddr{tcp2, quic2}, nil,
+			&preferQUIC{local: true}, &preferQUIC{local: true},
+		)
+		require.Equal(t, []ma.Multiaddr{quic2, tcp2}, initGot)
+		require.Equal(t, []ma.Multiaddr{quic, tcp}, recvGot)
+	})
+
+	t.Run("remote", func(t *testing.T) {
+		initGot, recvGot := runHolePunchExchange(t,
+			[]ma.Multiaddr{tcp, quic}, []ma.Multiaddr{tcp2, quic2}, nil,
+			&preferQUIC{remote: true}, &preferQUIC{remote: true},
+		)
+		require.Equal(t, []ma.Multiaddr{quic2, tcp2}, initGot)
+		require.Equal(t, []ma.Multiaddr{quic, tcp}, recvGot)
+	})
+
+	t.Run("filter before prioritize", func(t *testing.T) {
+		filter := &dropAddr{addr: quic}
+		initPrio := &preferQUIC{local: true, remote: true}
+		recvPrio := &preferQUIC{local: true, remote: true}
+		initGot, recvGot := runHolePunchExchange(t,
+			[]ma.Multiaddr{tcp, quic}, []ma.Multiaddr{tcp2, quic, quic2}, filter,
+			initPrio, recvPrio,
+		)
+		require.Equal(t, []ma.Multiaddr{quic2, tcp2}, initGot)
+		require.Equal(t, []ma.Multiaddr{tcp}, recvGot)
+		for _, a := range append(initPrio.seen, recvPrio.seen...) {
+			require.False(t, a.Equal(quic), "prioritizer saw a filtered address")
+		}
+	})
+}
@@ -25,6 +25,10 @@ var ErrHolePunchActive = errors.New("another hole punching attempt to this peer
 const (
 	dialTimeout = 5 * time.Second
 	maxRetries  = 3
+
+	// the observations about the remote peer reported to the peerstore.ScoreBook
+	scoreHolePunchSuccess = 1
+	scoreHolePunchFailure = -0.5
 )
 
 // The holePuncher is run on the peer that's behind a NAT / Firewall.
@@ -48,17 +52,19 @@ type holePuncher struct {
 	closeMx sync.RWMutex
 	closed  bool
 
-	tracer *tracer
-	filter AddrFilter
+	tracer      *tracer
+	filter      AddrFilter
+	prioritizer AddrPrioritizer
 }
 
-func newHolePuncher(h host.Host, ids identify.IDService, tracer *tracer, filter AddrFilter) *holePuncher {
+func newHolePuncher(h host.Host, ids identify.IDService, tracer *tracer, filter AddrFilter, prioritizer AddrPrioritizer) *holePuncher {
 	hp := &holePuncher{
-		host:   h,
-		ids:    ids,
-		active: make(map[peer.ID]struct{}),
-		tracer: tracer,
-		filter: filter,
+		host:        h,
+		ids:         ids,
+		active:      make(map[peer.ID]struct{}),
+		tracer:      tracer,
+		filter:      filter,
+		prioritizer: prioritizer,
 	}
 	hp.ctx, hp.ctxCancel = context.WithCancel(context.Background())
 	h.Network().Notify((*netNotifiee)(hp))
@@ -159,6 +165,7 @@ func (hp *holePuncher) directConnect(rp peer.ID) error {
 			hp.tracer.EndHolePunch(rp, dt, err)
 			if err == nil {
 				log.Debugw("hole punching with successful", "peer", rp, "time", dt)
+				recordScore(hp.host, rp, scoreHolePunchSuccess)
 				return nil
 			}
 		case <-hp.ctx.Done():
@@ -166,6 +173,7 @@ func (hp *holePuncher) directConnect(rp peer.ID) error {
 			return hp.ctx.Err()
 		}
 	}
+	recordScore(hp.host, rp, scoreHolePunchFailure)
 	return fmt.Errorf("all retries for hole punch with peer %s failed", rp)
 }
 
@@ -210,6 +218,9 @@ func (hp *holePuncher) initiateHolePunchImpl(str network.Stream) ([]ma.Multiaddr
 	if hp.filter != nil {
 		obsAddrs = hp.filter.FilterLocal(str.Conn().RemotePeer(), obsAddrs)
 	}
+	if hp.prioritizer != nil {
+		obsAddrs = prioritizeAddrs(obsAddrs, str.Conn().RemotePeer(), hp.prioritizer.PriorityLocal)
+	}
 	if len(obsAddrs) == 0 {
 		return nil, 0, errors.New("aborting hole punch initiation as we have no public address")
 	}
@@ -237,6 +248,9 @@ func (hp *holePuncher) initiateHolePunchImpl(str network.Stream) ([]ma.Multiaddr
 	if hp.filter != nil {
 		addrs = hp.filter.FilterRemote(str.Conn().RemotePeer(), addrs)
 	}
+	if hp.prioritizer != nil {
+		addrs = prioritizeAddrs(addrs, str.Conn().RemotePeer(), hp.prioritizer.PriorityRemote)
+	}
 
 	if len(addrs) == 0 {
 		return nil, 0, errors.New("didn't receive any public addresses in CONNECT")
@@ -53,8 +53,9 @@ type Service struct {
 
 	hasPublicAddrsChan chan struct{}
 
-	tracer *tracer
-	filter AddrFilter
+	tracer      *tracer
+	filter      AddrFilter
+	prioritizer AddrPrioritizer
 
 	refCount sync.WaitGroup
 }
@@ -142,7 +143,7 @@ func (s *Service) watchForPublicAddr() {
 				continue
 			}
 			s.holePuncherMx.Lock()
-			s.holePuncher = newHolePuncher(s.host, s.ids, s.tracer, s.filter)
+			s.holePuncher = newHolePuncher(s.host, s.ids, s.tracer, s.filter, s.prioritizer)
 			s.holePuncherMx.Unlock()
 			close(s.hasPublicAddrsChan)
 			return
@@ -174,6 +175,9 @@ func (s *Service) incomingHolePunch(str network.Stream) (rtt time.Duration, addr
 	if s.filter != nil {
 		ownAddrs = s.filter.FilterLocal(str.Conn().RemotePeer(), ownAddrs)
 	}
+	if s.prioritizer != nil {
+		ownAddrs = prioritizeAddrs(ownAddrs, str.Conn().RemotePeer(), s.prioritizer.PriorityLocal)
+	}
 
 	// If we can't tell the peer where to dial us, there's no point in starting the hole punching.
 	if len(ownAddrs) == 0 {
@@ -205,6 +209,9 @@ func (s *Service) incomingHolePunch(str network.Stream) (rtt time.Duration, addr
 	if s.filter != nil {
 		obsDial = s.filter.FilterRemote(str.Conn().RemotePeer(), obsDial)
 	}
+	if s.prioritizer != nil {
+		obsDial = prioritizeAddrs(obsDial, str.Conn().RemotePeer(), s.prioritizer.PriorityRemote)
+	}
 
 	log.Debugw("received hole punch request", "peer", str.Conn().RemotePeer(), "addrs", obsDial)
 	if len(obsDial) == 0 {
@@ -270,6 +277,11 @@ func (s *Service) handleNewStream(str network.Stream) {
 	err = holePunchConnect(s.ctx, s.host, pi, false)
 	dt := time.Since(start)
 	s.tracer.EndHolePunch(rp, dt, err)
+	if err == nil {
+		recordScore(s.host, rp, scoreHolePunchSuccess)
+	} else {
+		recordScore(s.host, rp, scoreHolePunchFailure)
+	}
 }
 
 // DirectConnect is only exposed for testing purposes.
@@ -2,10 +2,12 @@ package holepunch
 
 import (
 	"context"
+	"sort"
 
 	"github.com/AstaFrode/go-libp2p/core/host"
 	"github.com/AstaFrode/go-libp2p/core/network"
 	"github.com/AstaFrode/go-libp2p/core/peer"
+	"github.com/AstaFrode/go-libp2p/core/peerstore"
 
 	ma "github.com/multiformats/go-multiaddr"
 	manet "github.com/multiformats/go-multiaddr/net"
@@ -31,6 +33,19 @@ func removeRelayAddrs(addrs []ma.Multiaddr) []ma.Multiaddr {
 	return result
 }
 
+// prioritizeAddrs sorts addrs by decreasing priority, keeping the order of
+// addresses with the same priority.
+func prioritizeAddrs(addrs []ma.Multiaddr, remoteID peer.ID, priority func(peer.ID, ma.Multiaddr) int) []ma.Multiaddr {
+	prios := make(map[string]int, len(addrs))
+	for _, a := range addrs {
+		prios[string(a.Bytes())] = priority(remoteID, a)
+	}
+	sort.SliceStable(addrs, func(i, j int) bool {
+		return prios[string(addrs[i].Bytes())] > prios[string(addrs[j].Bytes())]
+	})
+	return addrs
+}
+
 func isRelayAddress(a ma.Multiaddr) bool {
 	_, err := a.ValueForProtocol(ma.P_CIRCUIT)
 	return err == nil
@@ -68,3 +83,10 @@ func holePunchConnect(ctx context.Context, host host.Host, pi peer.AddrInfo, isC
 	log.Debugw("hole punch successful", "peer", pi.ID)
 	return nil
 }
+
+// recordScore reports the outcome of a hole punch with p to the peerstore's ScoreBook, if it has one.
+func recordScore(h host.Host, p peer.ID, value float64) {
+	if sb, ok := peerstore.GetScoreBook(h.Peerstore()); ok {
+		sb.RecordObservation(p, peerstore.ScoreSourceHolePunch, value)
+	}
+}
@@ -0,0 +1,66 @@
+package identify
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/AstaFrode/go-libp2p/core/crypto"
+	"github.com/AstaFrode/go-libp2p/core/peer"
+	"github.com/AstaFrode/go-libp2p/core/record"
+	blhost "github.com/AstaFrode/go-libp2p/p2p/host/blank"
+	swarmt "github.com/AstaFrode/go-libp2p/p2p/net/swarm/testing"
+	"github.com/AstaFrode/go-libp2p/p2p/protocol/identify/pb"
+
+	"github.com/libp2p/go-msgio/pbio"
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+// FuzzIdentifyMessage feeds arbitrary identify messages, as read from the wire,
+// to the identify service.
+func FuzzIdentifyMessage(f *testing.F) {
+	h1 := blhost.NewBlankHost(swarmt.GenSwarmTB(f))
+	defer h1.Close()
+	h2 := blhost.NewBlankHost(swarmt.GenSwarmTB(f))
+	defer h2.Close()
+	ids, err := NewIDService(h1)
+	require.NoError(f, err)
+	defer ids.Close()
+	require.NoError(f, h2.Connect(context.Background(), peer.AddrInfo{ID: h1.ID(), Addrs: h1.Addrs()}))
+	require.Eventually(f, func() bool { return len(h1.Network().ConnsToPeer(h2.ID())) > 0 }, 5*time.Second, 10*time.Millisecond)
+	c := h1.Network().ConnsToPeer(h2.ID())[0]
+
+	// seed the corpus with a valid message
+	rec := peer.PeerRecordFromAddrInfo(peer.AddrInfo{ID: h2.ID(), Addrs: h2.Addrs()})
+	env, err := record.Seal(rec, h2.Peerstore().PrivKey(h2.ID()))
+	require.NoError(f, err)
+	signedRecord, err := env.Marshal()
+	require.NoError(f, err)
+	pubKey, err := crypto.MarshalPublicKey(h2.Peerstore().PubKey(h2.ID()))
+	require.NoError(f, err)
+	var buf bytes.Buffer
+	w := pbio.NewDelimitedWriter(&buf)
+	require.NoError(f, w.WriteMsg(&pb.Identify{
+		ProtocolVersion:  proto.String("ipfs/0.1.0"),
+		AgentVersion:     proto.String("fuzz"),
+		PublicKey:        pubKey,
+		ListenAddrs:      [][]byte{ma.StringCast("/ip4/1.2.3.4/tcp/1").Bytes()},
+		ObservedAddr:     ma.StringCast("/ip4/1.2.3.4/tcp/2").Bytes(),
+		Protocols:        []string{"/foo"},
+		SignedPeerRecord: signedRecord,
+	}))
+	f.Add(buf.Bytes())
+	f.Add([]byte{})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		mes := &pb.Identify{}
+		if err := readAllIDMessages(pbio.NewDelimitedReader(bytes.NewReader(data), signedIDSize), mes); err != nil {
+			return
+		}
+		ids.consumeMessage(mes, c, false)
+		ids.consumeMessage(mes, c, true)
+	})
+}
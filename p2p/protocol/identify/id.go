@@ -77,6 +77,9 @@ type IDService interface {
 	// ObservedAddrsFor returns the addresses peers have reported we've dialed from,
 	// for a specific local address.
 	ObservedAddrsFor(local ma.Multiaddr) []ma.Multiaddr
+	// OwnObservedAddrInfos returns the evidence collected for the addresses peers
+	// have reported we've dialed from, including the ones not advertised yet.
+	OwnObservedAddrInfos() []ObservedAddrInfo
 	Start()
 	io.Closer
 }
@@ -124,6 +127,12 @@ type idService struct {
 
 	disableSignedPeerRecord bool
 
+	// skipConn returns true for connections we don't run identify on
+	skipConn func(network.Conn) bool
+
+	// keyRotationRecord is the marshaled signed key rotation record sent to peers, if any
+	keyRotationRecord []byte
+
 	connsMu sync.RWMutex
 	// The conns map contains all connections we're currently handling.
 	// Connections are inserted as soon as they're available in the swarm, and - crucially -
@@ -141,6 +150,7 @@ type idService struct {
 		evtPeerProtocolsUpdated        event.Emitter
 		evtPeerIdentificationCompleted event.Emitter
 		evtPeerIdentificationFailed    event.Emitter
+		evtPeerKeyRotated              event.Emitter
 	}
 
 	currentSnapshot struct {
@@ -178,6 +188,21 @@ func NewIDService(h host.Host, opts ...Option) (*idService, error) {
 		disableSignedPeerRecord: cfg.disableSignedPeerRecord,
 		setupCompleted:          make(chan struct{}),
 		metricsTracer:           cfg.metricsTracer,
+		skipConn:                cfg.skipConn,
+	}
+
+	if cfg.keyRotationRecord != nil {
+		rec, err := peer.KeyRotationRecordFromEnvelope(cfg.keyRotationRecord)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key rotation record: %w", err)
+		}
+		if rec.NewID != h.ID() {
+			return nil, fmt.Errorf("key rotation record rotates to %s, not to the host's peer ID %s", rec.NewID, h.ID())
+		}
+		s.keyRotationRecord, err = cfg.keyRotationRecord.Marshal()
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal key rotation record: %w", err)
+		}
 	}
 
 	observedAddrs, err := NewObservedAddrManager(h)
@@ -198,6 +223,10 @@ func NewIDService(h host.Host, opts ...Option) (*idService, error) {
 	if err != nil {
 		log.Warnf("identify service not emitting identification failed events; err: %s", err)
 	}
+	s.emitters.evtPeerKeyRotated, err = h.EventBus().Emitter(&event.EvtPeerKeyRotated{})
+	if err != nil {
+		log.Warnf("identify service not emitting peer key rotation events; err: %s", err)
+	}
 	return s, nil
 }
 
@@ -333,6 +362,10 @@ func (ids *idService) ObservedAddrsFor(local ma.Multiaddr) []ma.Multiaddr {
 	return ids.observedAddrs.AddrsFor(local)
 }
 
+func (ids *idService) OwnObservedAddrInfos() []ObservedAddrInfo {
+	return ids.observedAddrs.ObservedAddrInfos()
+}
+
 // IdentifyConn runs the Identify protocol on a connection.
 // It returns when we've received the peer's Identify message (or the request fails).
 // If successful, the peer store will contain the peer's addresses and supported protocols.
@@ -607,6 +640,17 @@ func (ids *idService) createBaseIdentifyResponse(conn network.Conn, snapshot *id
 	// set protocol versions
 	mes.ProtocolVersion = &ids.ProtocolVersion
 	mes.AgentVersion = &ids.UserAgent
+	if o, ok := conn.(network.ConnIdentifyOverrides); ok {
+		userAgent, protocolVersion := o.IdentifyOverrides()
+		if protocolVersion != "" {
+			mes.ProtocolVersion = &protocolVersion
+		}
+		if userAgent != "" {
+			mes.AgentVersion = &userAgent
+		}
+	}
+
+	mes.KeyRotationRecord = ids.keyRotationRecord
 
 	return mes
 }
@@ -743,6 +787,46 @@ func (ids *idService) consumeMessage(mes *pb.Identify, c network.Conn, isPush bo
 
 	// get the key from the other side. we may not have it (no-auth transport)
 	ids.consumeReceivedPubKey(c, mes.PublicKey)
+
+	ids.consumeKeyRotationRecord(c, mes.KeyRotationRecord)
+}
+
+// consumeKeyRotationRecord stores the key rotation record sent by the remote peer.
+// The record is signed by the peer's old key, and we only accept records that rotate
+// to the peer we're connected to, which proves possession of the new key.
+func (ids *idService) consumeKeyRotationRecord(c network.Conn, b []byte) {
+	if len(b) == 0 {
+		return
+	}
+	krb, ok := peerstore.GetKeyRotationBook(ids.Host.Peerstore())
+	if !ok {
+		return
+	}
+
+	env, _, err := record.ConsumeEnvelope(b, peer.KeyRotationRecordEnvelopeDomain)
+	if err != nil {
+		log.Debugw("failed to consume key rotation record", "peer", c.RemotePeer(), "error", err)
+		return
+	}
+	rec, err := peer.KeyRotationRecordFromEnvelope(env)
+	if err != nil {
+		log.Debugw("invalid key rotation record", "peer", c.RemotePeer(), "error", err)
+		return
+	}
+	if rec.NewID != c.RemotePeer() {
+		log.Debugw("key rotation record doesn't rotate to the remote peer", "peer", c.RemotePeer(), "new", rec.NewID)
+		return
+	}
+	accepted, err := krb.ConsumeKeyRotationRecord(env)
+	if err != nil {
+		log.Debugw("failed to store key rotation record", "peer", c.RemotePeer(), "error", err)
+		return
+	}
+	if !accepted {
+		return
+	}
+	log.Debugw("peer rotated its identity key", "old", rec.OldID, "new", rec.NewID)
+	ids.emitters.evtPeerKeyRotated.Emit(event.EvtPeerKeyRotated{OldPeer: rec.OldID, NewPeer: rec.NewID})
 }
 
 func (ids *idService) consumeReceivedPubKey(c network.Conn, kb []byte) {
@@ -884,6 +968,11 @@ func (nn *netNotifiee) Connected(_ network.Network, c network.Conn) {
 
 	<-ids.setupCompleted
 
+	// Connections that aren't tracked are neither identified nor pushed to.
+	if ids.skipConn != nil && ids.skipConn(c) {
+		return
+	}
+
 	ids.connsMu.Lock()
 	ids.conns[c] = entry{}
 	ids.connsMu.Unlock()
@@ -2,6 +2,7 @@ package identify_test
 
 import (
 	"context"
+	"crypto/rand"
 	"fmt"
 	"sync"
 	"testing"
@@ -544,6 +545,35 @@ func TestUserAgent(t *testing.T) {
 	}
 }
 
+func TestUserAgentPerConnection(t *testing.T) {
+	h1, err := libp2p.New(libp2p.UserAgent("foo"), libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+	require.NoError(t, err)
+	defer h1.Close()
+	h2, err := libp2p.New(libp2p.UserAgent("bar"), libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+	require.NoError(t, err)
+	defer h2.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	ctx = network.WithUserAgent(ctx, "foo-client")
+	ctx = network.WithProtocolVersion(ctx, "client/1.0.0")
+	require.NoError(t, h1.Connect(ctx, peer.AddrInfo{ID: h2.ID(), Addrs: h2.Addrs()}))
+
+	// h2 sees the overridden values
+	require.Eventually(t, func() bool {
+		av, err := h2.Peerstore().Get(h1.ID(), "AgentVersion")
+		return err == nil && av.(string) == "foo-client"
+	}, 5*time.Second, 10*time.Millisecond)
+	pv, err := h2.Peerstore().Get(h1.ID(), "ProtocolVersion")
+	require.NoError(t, err)
+	require.Equal(t, "client/1.0.0", pv)
+
+	// the overrides only apply in one direction
+	av, err := h1.Peerstore().Get(h2.ID(), "AgentVersion")
+	require.NoError(t, err)
+	require.Equal(t, "bar", av)
+}
+
 func TestNotListening(t *testing.T) {
 	// Make sure we don't panic if we're not listening on any addresses.
 	//
@@ -927,3 +957,67 @@ func waitForDisconnectNotification(swarm *swarm.Swarm) <-chan struct{} {
 
 	return done
 }
+
+func TestKeyRotationRecord(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	oldKey, _, err := ic.GenerateEd25519Key(rand.Reader)
+	require.NoError(t, err)
+	newKey, _, err := ic.GenerateEd25519Key(rand.Reader)
+	require.NoError(t, err)
+	oldID, err := peer.IDFromPrivateKey(oldKey)
+	require.NoError(t, err)
+
+	h1, err := libp2p.New(
+		libp2p.Identity(newKey),
+		libp2p.RotatedIdentity(oldKey),
+		libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"),
+	)
+	require.NoError(t, err)
+	defer h1.Close()
+
+	h2, err := libp2p.New(libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+	require.NoError(t, err)
+	defer h2.Close()
+
+	sub, err := h2.EventBus().Subscribe(&event.EvtPeerKeyRotated{})
+	require.NoError(t, err)
+	defer sub.Close()
+
+	require.NoError(t, h2.Connect(ctx, peer.AddrInfo{ID: h1.ID(), Addrs: h1.Addrs()}))
+	select {
+	case e := <-sub.Out():
+		evt := e.(event.EvtPeerKeyRotated)
+		require.Equal(t, oldID, evt.OldPeer)
+		require.Equal(t, h1.ID(), evt.NewPeer)
+	case <-time.After(5 * time.Second):
+		t.Fatal("didn't receive key rotation event")
+	}
+
+	krb, ok := peerstore.GetKeyRotationBook(h2.Peerstore())
+	require.True(t, ok)
+	require.Equal(t, h1.ID(), krb.ResolveRotatedPeer(oldID))
+	require.NotNil(t, krb.GetKeyRotationRecord(oldID))
+
+	// h1 knows about its own rotation
+	krb, ok = peerstore.GetKeyRotationBook(h1.Peerstore())
+	require.True(t, ok)
+	require.Equal(t, h1.ID(), krb.ResolveRotatedPeer(oldID))
+}
+
+func TestKeyRotationRecordForOtherPeer(t *testing.T) {
+	oldKey, _, err := ic.GenerateEd25519Key(rand.Reader)
+	require.NoError(t, err)
+	oldID, err := peer.IDFromPrivateKey(oldKey)
+	require.NoError(t, err)
+
+	h := blhost.NewBlankHost(swarmt.GenSwarm(t))
+	defer h.Close()
+
+	// the record rotates to a peer that's not the host
+	env, err := (&peer.KeyRotationRecord{OldID: oldID, NewID: "foobar", Seq: 1}).Sign(oldKey)
+	require.NoError(t, err)
+	_, err = identify.NewIDService(h, identify.KeyRotationRecord(env))
+	require.Error(t, err)
+}
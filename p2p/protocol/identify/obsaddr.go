@@ -57,6 +57,7 @@ type observation struct {
 type observedAddr struct {
 	addr       ma.Multiaddr
 	seenBy     map[string]observation // peer(observer) address -> observation info
+	firstSeen  time.Time
 	lastSeen   time.Time
 	numInbound int
 }
@@ -89,6 +90,24 @@ func (oa *observedAddr) groupKey() string {
 	return string(key)
 }
 
+// ObservedAddrInfo is the evidence collected for an address of ours observed by our peers.
+type ObservedAddrInfo struct {
+	Addr ma.Multiaddr
+	// Observers is the number of distinct observers that reported the address recently.
+	// Peers with IP addresses in the same group count as a single observer.
+	Observers int
+	// InboundObservers is the number of observers that reported the address on an
+	// inbound connection, i.e. that successfully dialed us.
+	InboundObservers int
+	// FirstSeen is the time the address was first observed. It's reset when the
+	// address is forgotten because nobody observed it for a while.
+	FirstSeen time.Time
+	LastSeen  time.Time
+	// Activated is true if the address was observed by enough observers to be
+	// advertised to other peers (see ActivationThresh).
+	Activated bool
+}
+
 type newObservation struct {
 	conn     network.Conn
 	observed ma.Multiaddr
@@ -193,6 +212,32 @@ func (oas *ObservedAddrManager) Addrs() []ma.Multiaddr {
 	return oas.filter(allObserved)
 }
 
+// ObservedAddrInfos returns the evidence collected for all the addresses observed
+// recently, activated or not.
+func (oas *ObservedAddrManager) ObservedAddrInfos() []ObservedAddrInfo {
+	oas.mu.RLock()
+	defer oas.mu.RUnlock()
+
+	now := time.Now()
+	var infos []ObservedAddrInfo
+	for _, addrs := range oas.addrs {
+		for _, a := range addrs {
+			if now.Sub(a.lastSeen) > oas.ttl {
+				continue
+			}
+			infos = append(infos, ObservedAddrInfo{
+				Addr:             a.addr,
+				Observers:        len(a.seenBy),
+				InboundObservers: a.numInbound,
+				FirstSeen:        a.firstSeen,
+				LastSeen:         a.lastSeen,
+				Activated:        a.activated(),
+			})
+		}
+	}
+	return infos
+}
+
 func (oas *ObservedAddrManager) filter(observedAddrs []*observedAddr) []ma.Multiaddr {
 	pmap := make(map[string][]*observedAddr)
 	now := time.Now()
@@ -441,7 +486,8 @@ func (oas *ObservedAddrManager) recordObservationUnlocked(conn network.Conn, obs
 		seenBy: map[string]observation{
 			observerString: ob,
 		},
-		lastSeen: now,
+		firstSeen: now,
+		lastSeen:  now,
 	}
 	if ob.inbound {
 		oa.numInbound++
@@ -324,6 +324,40 @@ func TestObservedAddrFiltering(t *testing.T) {
 	require.Contains(t, addrs, it3)
 }
 
+func TestObservedAddrInfos(t *testing.T) {
+	harness := newHarness(t)
+	require.Empty(t, harness.oas.ObservedAddrInfos())
+
+	observed := ma.StringCast("/ip4/1.2.3.4/tcp/1231")
+	peers := []peer.ID{
+		harness.add(ma.StringCast("/ip4/1.2.3.6/tcp/1236")),
+		harness.add(ma.StringCast("/ip4/1.2.3.7/tcp/1237")),
+		harness.add(ma.StringCast("/ip4/1.2.3.8/tcp/1237")),
+		harness.add(ma.StringCast("/ip4/1.2.3.9/tcp/1237")),
+	}
+	start := time.Now()
+	harness.observe(observed, peers[0])
+	harness.observeInbound(observed, peers[1])
+
+	infos := harness.oas.ObservedAddrInfos()
+	require.Len(t, infos, 1)
+	info := infos[0]
+	require.True(t, info.Addr.Equal(observed))
+	require.Equal(t, 2, info.Observers)
+	require.Equal(t, 1, info.InboundObservers)
+	require.False(t, info.Activated)
+	require.True(t, info.FirstSeen.After(start))
+	require.True(t, info.LastSeen.After(info.FirstSeen))
+
+	harness.observe(observed, peers[2])
+	harness.observe(observed, peers[3])
+	infos = harness.oas.ObservedAddrInfos()
+	require.Len(t, infos, 1)
+	require.Equal(t, 4, infos[0].Observers)
+	require.True(t, infos[0].Activated)
+	require.Equal(t, info.FirstSeen, infos[0].FirstSeen)
+}
+
 func TestEmitNATDeviceTypeSymmetric(t *testing.T) {
 	harness := newHarness(t)
 	require.Empty(t, harness.oas.Addrs())
@@ -1,10 +1,17 @@
 package identify
 
+import (
+	"github.com/AstaFrode/go-libp2p/core/network"
+	"github.com/AstaFrode/go-libp2p/core/record"
+)
+
 type config struct {
 	protocolVersion         string
 	userAgent               string
 	disableSignedPeerRecord bool
+	keyRotationRecord       *record.Envelope
 	metricsTracer           MetricsTracer
+	skipConn                func(network.Conn) bool
 }
 
 // Option is an option function for identify.
@@ -33,6 +40,24 @@ func DisableSignedPeerRecord() Option {
 	}
 }
 
+// KeyRotationRecord sets a signed peer.KeyRotationRecord that is sent to peers on the Identify
+// response, announcing that this node rotated its identity key. The record's NewID must be the
+// host's peer ID.
+func KeyRotationRecord(env *record.Envelope) Option {
+	return func(cfg *config) {
+		cfg.keyRotationRecord = env
+	}
+}
+
+// SkipConns disables identify on the connections for which skip returns true, e.g.
+// connections that can't carry more than one stream. Identify requests aren't sent
+// on these connections, nor are identify pushes.
+func SkipConns(skip func(network.Conn) bool) Option {
+	return func(cfg *config) {
+		cfg.skipConn = skip
+	}
+}
+
 func WithMetricsTracer(tr MetricsTracer) Option {
 	return func(cfg *config) {
 		cfg.metricsTracer = tr
@@ -48,6 +48,10 @@ type Identify struct {
 	// see github.com/libp2p/go-libp2p/core/record/pb/envelope.proto and
 	// github.com/libp2p/go-libp2p/core/peer/pb/peer_record.proto for message definitions.
 	SignedPeerRecord []byte `protobuf:"bytes,8,opt,name=signedPeerRecord" json:"signedPeerRecord,omitempty"`
+	// keyRotationRecord contains a serialized SignedEnvelope containing a KeyRotationRecord,
+	// signed by the key the sending node used before it rotated to its current key.
+	// see github.com/libp2p/go-libp2p/core/peer/pb/key_rotation_record.proto for the message definition.
+	KeyRotationRecord []byte `protobuf:"bytes,9,opt,name=keyRotationRecord" json:"keyRotationRecord,omitempty"`
 }
 
 func (x *Identify) Reset() {
@@ -131,12 +135,19 @@ func (x *Identify) GetSignedPeerRecord() []byte {
 	return nil
 }
 
+func (x *Identify) GetKeyRotationRecord() []byte {
+	if x != nil {
+		return x.KeyRotationRecord
+	}
+	return nil
+}
+
 var File_pb_identify_proto protoreflect.FileDescriptor
 
 var file_pb_identify_proto_rawDesc = []byte{
 	0x0a, 0x11, 0x70, 0x62, 0x2f, 0x69, 0x64, 0x65, 0x6e, 0x74, 0x69, 0x66, 0x79, 0x2e, 0x70, 0x72,
 	0x6f, 0x74, 0x6f, 0x12, 0x0b, 0x69, 0x64, 0x65, 0x6e, 0x74, 0x69, 0x66, 0x79, 0x2e, 0x70, 0x62,
-	0x22, 0x86, 0x02, 0x0a, 0x08, 0x49, 0x64, 0x65, 0x6e, 0x74, 0x69, 0x66, 0x79, 0x12, 0x28, 0x0a,
+	0x22, 0xb4, 0x02, 0x0a, 0x08, 0x49, 0x64, 0x65, 0x6e, 0x74, 0x69, 0x66, 0x79, 0x12, 0x28, 0x0a,
 	0x0f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e,
 	0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c,
 	0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x22, 0x0a, 0x0c, 0x61, 0x67, 0x65, 0x6e, 0x74,
@@ -152,7 +163,10 @@ var file_pb_identify_proto_rawDesc = []byte{
 	0x28, 0x09, 0x52, 0x09, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x73, 0x12, 0x2a, 0x0a,
 	0x10, 0x73, 0x69, 0x67, 0x6e, 0x65, 0x64, 0x50, 0x65, 0x65, 0x72, 0x52, 0x65, 0x63, 0x6f, 0x72,
 	0x64, 0x18, 0x08, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x10, 0x73, 0x69, 0x67, 0x6e, 0x65, 0x64, 0x50,
-	0x65, 0x65, 0x72, 0x52, 0x65, 0x63, 0x6f, 0x72, 0x64,
+	0x65, 0x65, 0x72, 0x52, 0x65, 0x63, 0x6f, 0x72, 0x64, 0x12, 0x2c, 0x0a, 0x11, 0x6b, 0x65, 0x79,
+	0x52, 0x6f, 0x74, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x63, 0x6f, 0x72, 0x64, 0x18, 0x09,
+	0x20, 0x01, 0x28, 0x0c, 0x52, 0x11, 0x6b, 0x65, 0x79, 0x52, 0x6f, 0x74, 0x61, 0x74, 0x69, 0x6f,
+	0x6e, 0x52, 0x65, 0x63, 0x6f, 0x72, 0x64,
 }
 
 var (
@@ -0,0 +1,54 @@
+package peerexchange
+
+import (
+	"errors"
+	"time"
+)
+
+type Option func(*Service) error
+
+// WithMaxRecords sets the maximum number of peer records sent in, and accepted from, a
+// single exchange.
+func WithMaxRecords(n int) Option {
+	return func(s *Service) error {
+		if n <= 0 {
+			return errors.New("max records must be positive")
+		}
+		s.maxRecords = n
+		return nil
+	}
+}
+
+// WithAddrTTL sets the TTL of the addresses learned from other peers.
+func WithAddrTTL(ttl time.Duration) Option {
+	return func(s *Service) error {
+		if ttl <= 0 {
+			return errors.New("address TTL must be positive")
+		}
+		s.addrTTL = ttl
+		return nil
+	}
+}
+
+// WithExchangeInterval sets the minimum interval between two exchanges with the same peer.
+// Peer records are requested from peers when they are identified, at most once per interval.
+func WithExchangeInterval(d time.Duration) Option {
+	return func(s *Service) error {
+		if d <= 0 {
+			return errors.New("exchange interval must be positive")
+		}
+		s.exchangeInterval = d
+		return nil
+	}
+}
+
+// WithRecentPeers sets the number of recently seen peers whose records are shared.
+func WithRecentPeers(n int) Option {
+	return func(s *Service) error {
+		if n <= 0 {
+			return errors.New("number of recent peers must be positive")
+		}
+		s.maxRecentPeers = n
+		return nil
+	}
+}
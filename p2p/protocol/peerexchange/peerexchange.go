@@ -0,0 +1,337 @@
+// Package peerexchange implements a protocol to exchange the signed peer records of recently
+// seen peers with connected peers. It helps bootstrapping connectivity in networks
+// without a DHT or another discovery mechanism.
+//
+// The records are signed by the peers they describe, so a peer can't announce forged
+// addresses for another peer. Only the records we hold in the certified address book are shared.
+package peerexchange
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/AstaFrode/go-libp2p/core/event"
+	"github.com/AstaFrode/go-libp2p/core/host"
+	"github.com/AstaFrode/go-libp2p/core/network"
+	"github.com/AstaFrode/go-libp2p/core/peer"
+	"github.com/AstaFrode/go-libp2p/core/peerstore"
+	"github.com/AstaFrode/go-libp2p/core/protocol"
+	"github.com/AstaFrode/go-libp2p/core/record"
+	"github.com/AstaFrode/go-libp2p/p2p/host/eventbus"
+
+	logging "github.com/ipfs/go-log/v2"
+	"github.com/libp2p/go-msgio"
+)
+
+// Protocol is the libp2p protocol for peer exchange.
+const Protocol protocol.ID = "/libp2p/peer-exchange/1.0.0"
+
+// ServiceName is the name of the peer exchange service in the resource manager.
+const ServiceName = "libp2p.peerexchange"
+
+var log = logging.Logger("p2p-peerexchange")
+
+// StreamTimeout is the timeout for a peer exchange.
+var StreamTimeout = 30 * time.Second
+
+const (
+	maxRecordSize     = 4 << 10 // 4K
+	maxAddrsPerRecord = 32
+	// minimum interval between two requests served to the same peer
+	minRequestInterval = 10 * time.Second
+
+	defaultMaxRecords       = 16
+	defaultMaxRecentPeers   = 64
+	defaultExchangeInterval = 10 * time.Minute
+)
+
+// Service exchanges the signed peer records of recently seen peers with connected peers
+// that support the peer exchange protocol.
+//
+// It serves the records of the peers we recently connected to, and requests records
+// from peers once they are identified, at most once per exchange interval.
+// The addresses learned are added to the peerstore.
+type Service struct {
+	ctx       context.Context
+	ctxCancel context.CancelFunc
+
+	host host.Host
+	cab  peerstore.CertifiedAddrBook
+	nb   *network.NotifyBundle
+	sub  event.Subscription
+
+	maxRecords       int
+	maxRecentPeers   int
+	addrTTL          time.Duration
+	exchangeInterval time.Duration
+
+	mx           sync.Mutex
+	recent       []peer.ID // most recently connected first
+	lastExchange map[peer.ID]time.Time
+	lastRequest  map[peer.ID]time.Time
+
+	refCount sync.WaitGroup
+}
+
+// NewService creates a new peer exchange service.
+// The host's peerstore must support signed peer records.
+func NewService(h host.Host, opts ...Option) (*Service, error) {
+	cab, ok := peerstore.GetCertifiedAddrBook(h.Peerstore())
+	if !ok {
+		return nil, errors.New("peerstore doesn't support signed peer records")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Service{
+		ctx:              ctx,
+		ctxCancel:        cancel,
+		host:             h,
+		cab:              cab,
+		maxRecords:       defaultMaxRecords,
+		maxRecentPeers:   defaultMaxRecentPeers,
+		addrTTL:          peerstore.RecentlyConnectedAddrTTL,
+		exchangeInterval: defaultExchangeInterval,
+		lastExchange:     make(map[peer.ID]time.Time),
+		lastRequest:      make(map[peer.ID]time.Time),
+	}
+	for _, opt := range opts {
+		if err := opt(s); err != nil {
+			cancel()
+			return nil, err
+		}
+	}
+
+	sub, err := h.EventBus().Subscribe(new(event.EvtPeerIdentificationCompleted), eventbus.Name("peerexchange"))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	s.sub = sub
+
+	s.nb = &network.NotifyBundle{
+		ConnectedF: func(_ network.Network, c network.Conn) { s.addRecentPeer(c.RemotePeer()) },
+	}
+	h.Network().Notify(s.nb)
+	h.SetStreamHandler(Protocol, s.handleNewStream)
+
+	s.refCount.Add(1)
+	go s.background()
+	return s, nil
+}
+
+func (s *Service) background() {
+	defer s.refCount.Done()
+	for {
+		select {
+		case e, ok := <-s.sub.Out():
+			if !ok {
+				return
+			}
+			p := e.(event.EvtPeerIdentificationCompleted).Peer
+			if !s.shouldExchange(p) {
+				continue
+			}
+			s.refCount.Add(1)
+			go func() {
+				defer s.refCount.Done()
+				ctx, cancel := context.WithTimeout(s.ctx, StreamTimeout)
+				defer cancel()
+				n, err := s.exchange(network.WithNoDial(ctx, "peer exchange"), p)
+				if err != nil {
+					log.Debugw("peer exchange failed", "peer", p, "error", err)
+					return
+				}
+				log.Debugw("peer exchange completed", "peer", p, "records", n)
+			}()
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// shouldExchange returns true if p supports the protocol, and we didn't exchange
+// records with it during the exchange interval.
+func (s *Service) shouldExchange(p peer.ID) bool {
+	if protos, err := s.host.Peerstore().SupportsProtocols(p, Protocol); err != nil || len(protos) == 0 {
+		return false
+	}
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	now := time.Now()
+	for q, t := range s.lastExchange {
+		if now.Sub(t) >= s.exchangeInterval {
+			delete(s.lastExchange, q)
+		}
+	}
+	_, ok := s.lastExchange[p]
+	return !ok
+}
+
+func (s *Service) addRecentPeer(p peer.ID) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	for i, q := range s.recent {
+		if q == p {
+			s.recent = append(s.recent[:i], s.recent[i+1:]...)
+			break
+		}
+	}
+	s.recent = append([]peer.ID{p}, s.recent...)
+	if len(s.recent) > s.maxRecentPeers {
+		s.recent = s.recent[:s.maxRecentPeers]
+	}
+}
+
+// Exchange requests the signed peer records of the peers recently seen by p,
+// and adds the addresses to the peerstore.
+// It returns the number of records accepted.
+func (s *Service) Exchange(ctx context.Context, p peer.ID) (int, error) {
+	return s.exchange(ctx, p)
+}
+
+func (s *Service) exchange(ctx context.Context, p peer.ID) (int, error) {
+	s.mx.Lock()
+	s.lastExchange[p] = time.Now()
+	s.mx.Unlock()
+
+	str, err := s.host.NewStream(ctx, p, Protocol)
+	if err != nil {
+		return 0, err
+	}
+	if err := str.Scope().SetService(ServiceName); err != nil {
+		str.Reset()
+		return 0, err
+	}
+	str.SetDeadline(time.Now().Add(StreamTimeout))
+	// the request is empty
+	if err := str.CloseWrite(); err != nil {
+		str.Reset()
+		return 0, err
+	}
+
+	r := msgio.NewVarintReaderSize(str, maxRecordSize)
+	var n int
+	for i := 0; i < s.maxRecords; i++ {
+		msg, err := r.ReadMsg()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			str.Reset()
+			return n, err
+		}
+		if s.consumeRecord(msg, p) {
+			n++
+		}
+	}
+	str.Close()
+	return n, nil
+}
+
+// consumeRecord validates a record received from peer from, and adds it to the peerstore.
+func (s *Service) consumeRecord(data []byte, from peer.ID) bool {
+	env, rec, err := record.ConsumeEnvelope(data, peer.PeerRecordEnvelopeDomain)
+	if err != nil {
+		log.Debugw("invalid peer record", "from", from, "error", err)
+		return false
+	}
+	prec, ok := rec.(*peer.PeerRecord)
+	if !ok {
+		return false
+	}
+	// We got the record of the peer itself via identify.
+	if prec.PeerID == s.host.ID() || prec.PeerID == from {
+		return false
+	}
+	if len(prec.Addrs) == 0 || len(prec.Addrs) > maxAddrsPerRecord {
+		return false
+	}
+	// ConsumePeerRecord checks that the record was signed by the peer,
+	// and ignores records older than the one we already have.
+	added, err := s.cab.ConsumePeerRecord(env, s.addrTTL)
+	if err != nil {
+		log.Debugw("failed to consume peer record", "from", from, "peer", prec.PeerID, "error", err)
+		return false
+	}
+	return added
+}
+
+func (s *Service) handleNewStream(str network.Stream) {
+	if err := str.Scope().SetService(ServiceName); err != nil {
+		log.Debugf("error attaching stream to peer exchange service: %s", err)
+		str.Reset()
+		return
+	}
+	p := str.Conn().RemotePeer()
+	if !s.allowRequest(p) {
+		log.Debugw("rejecting peer exchange request, peer is requesting too often", "peer", p)
+		str.Reset()
+		return
+	}
+	str.SetDeadline(time.Now().Add(StreamTimeout))
+
+	w := msgio.NewVarintWriter(str)
+	for _, env := range s.records(p) {
+		data, err := env.Marshal()
+		if err != nil || len(data) > maxRecordSize {
+			continue
+		}
+		if err := w.WriteMsg(data); err != nil {
+			log.Debugw("error writing peer record", "peer", p, "error", err)
+			str.Reset()
+			return
+		}
+	}
+	str.Close()
+}
+
+func (s *Service) allowRequest(p peer.ID) bool {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	now := time.Now()
+	for q, t := range s.lastRequest {
+		if now.Sub(t) >= minRequestInterval {
+			delete(s.lastRequest, q)
+		}
+	}
+	if _, ok := s.lastRequest[p]; ok {
+		return false
+	}
+	s.lastRequest[p] = now
+	return true
+}
+
+// records returns the signed peer records of the recently seen peers, except p.
+func (s *Service) records(p peer.ID) []*record.Envelope {
+	s.mx.Lock()
+	recent := make([]peer.ID, len(s.recent))
+	copy(recent, s.recent)
+	s.mx.Unlock()
+
+	envs := make([]*record.Envelope, 0, s.maxRecords)
+	for _, q := range recent {
+		if len(envs) == s.maxRecords {
+			break
+		}
+		if q == p || q == s.host.ID() {
+			continue
+		}
+		if env := s.cab.GetPeerRecord(q); env != nil {
+			envs = append(envs, env)
+		}
+	}
+	return envs
+}
+
+// Close stops the service.
+func (s *Service) Close() error {
+	s.ctxCancel()
+	s.host.RemoveStreamHandler(Protocol)
+	s.host.Network().StopNotify(s.nb)
+	err := s.sub.Close()
+	s.refCount.Wait()
+	return err
+}
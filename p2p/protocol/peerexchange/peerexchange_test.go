@@ -0,0 +1,110 @@
+package peerexchange_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/AstaFrode/go-libp2p/core/peer"
+	"github.com/AstaFrode/go-libp2p/core/peerstore"
+	bhost "github.com/AstaFrode/go-libp2p/p2p/host/basic"
+	swarmt "github.com/AstaFrode/go-libp2p/p2p/net/swarm/testing"
+	"github.com/AstaFrode/go-libp2p/p2p/protocol/peerexchange"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newHost(t *testing.T, enablePeerExchange bool) *bhost.BasicHost {
+	t.Helper()
+	h, err := bhost.NewHost(swarmt.GenSwarm(t), &bhost.HostOpts{EnablePeerExchange: enablePeerExchange})
+	require.NoError(t, err)
+	h.Start()
+	t.Cleanup(func() { h.Close() })
+	return h
+}
+
+func connect(t *testing.T, a, b *bhost.BasicHost) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, a.Connect(ctx, peer.AddrInfo{ID: b.ID(), Addrs: b.Addrs()}))
+}
+
+func certifiedAddrs(h *bhost.BasicHost, p peer.ID) int {
+	cab, _ := peerstore.GetCertifiedAddrBook(h.Peerstore())
+	env := cab.GetPeerRecord(p)
+	if env == nil {
+		return 0
+	}
+	return len(h.Peerstore().Addrs(p))
+}
+
+func TestPeerExchangeOnIdentify(t *testing.T) {
+	a := newHost(t, true)
+	b := newHost(t, true)
+	c := newHost(t, false)
+
+	connect(t, b, c)
+	require.Eventually(t, func() bool { return certifiedAddrs(b, c.ID()) > 0 }, 5*time.Second, 10*time.Millisecond)
+
+	// a learns c's record from b once it identified b
+	connect(t, a, b)
+	require.Eventually(t, func() bool { return certifiedAddrs(a, c.ID()) > 0 }, 5*time.Second, 10*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, a.Connect(ctx, peer.AddrInfo{ID: c.ID()}))
+}
+
+// newServiceAfterIdentify connects a to b, and starts a peer exchange service on a
+// once identify completed, so that no exchange is triggered automatically.
+func newServiceAfterIdentify(t *testing.T, a, b *bhost.BasicHost, opts ...peerexchange.Option) *peerexchange.Service {
+	t.Helper()
+	connect(t, a, b)
+	require.Eventually(t, func() bool {
+		protos, err := a.Peerstore().SupportsProtocols(b.ID(), peerexchange.Protocol)
+		return err == nil && len(protos) > 0
+	}, 5*time.Second, 10*time.Millisecond)
+	pex, err := peerexchange.NewService(a, opts...)
+	require.NoError(t, err)
+	t.Cleanup(func() { pex.Close() })
+	return pex
+}
+
+func TestPeerExchangeMaxRecords(t *testing.T) {
+	b := newHost(t, true)
+	for i := 0; i < 3; i++ {
+		o := newHost(t, false)
+		connect(t, b, o)
+		require.Eventually(t, func() bool { return certifiedAddrs(b, o.ID()) > 0 }, 5*time.Second, 10*time.Millisecond)
+	}
+
+	a := newHost(t, false)
+	pex := newServiceAfterIdentify(t, a, b, peerexchange.WithMaxRecords(2))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	n, err := pex.Exchange(ctx, b.ID())
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+}
+
+func TestPeerExchangeRateLimit(t *testing.T) {
+	b := newHost(t, true)
+	c := newHost(t, false)
+	connect(t, b, c)
+	require.Eventually(t, func() bool { return certifiedAddrs(b, c.ID()) > 0 }, 5*time.Second, 10*time.Millisecond)
+
+	a := newHost(t, false)
+	pex := newServiceAfterIdentify(t, a, b)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	n, err := pex.Exchange(ctx, b.ID())
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+
+	// b refuses to serve a again right away
+	_, err = pex.Exchange(ctx, b.ID())
+	require.Error(t, err)
+}
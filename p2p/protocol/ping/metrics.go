@@ -0,0 +1,78 @@
+package ping
+
+import (
+	"time"
+
+	"github.com/AstaFrode/go-libp2p/p2p/metricshelper"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const metricNamespace = "libp2p_ping"
+
+var (
+	rtt = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Namespace: metricNamespace,
+			Name:      "rtt_seconds",
+			Help:      "Ping round trip time",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 2, 15),
+		},
+	)
+	failures = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Name:      "failures_total",
+			Help:      "Failed pings",
+		},
+	)
+	collectors = []prometheus.Collector{
+		rtt,
+		failures,
+	}
+)
+
+// MetricsTracer tracks the pings sent by the PingService.
+// Per-peer RTTs are not exported as metrics, to keep the cardinality low.
+// Use PingService.PeerRTT to query them.
+type MetricsTracer interface {
+	// PingSucceeded tracks a successful ping.
+	PingSucceeded(rtt time.Duration)
+	// PingFailed tracks a failed ping.
+	PingFailed()
+}
+
+type metricsTracer struct{}
+
+var _ MetricsTracer = &metricsTracer{}
+
+type metricsTracerSetting struct {
+	reg prometheus.Registerer
+}
+
+type MetricsTracerOption func(*metricsTracerSetting)
+
+func WithRegisterer(reg prometheus.Registerer) MetricsTracerOption {
+	return func(s *metricsTracerSetting) {
+		if reg != nil {
+			s.reg = reg
+		}
+	}
+}
+
+func NewMetricsTracer(opts ...MetricsTracerOption) MetricsTracer {
+	setting := &metricsTracerSetting{reg: prometheus.DefaultRegisterer}
+	for _, opt := range opts {
+		opt(setting)
+	}
+	metricshelper.RegisterCollectors(setting.reg, collectors...)
+	return &metricsTracer{}
+}
+
+func (t *metricsTracer) PingSucceeded(d time.Duration) {
+	rtt.Observe(d.Seconds())
+}
+
+func (t *metricsTracer) PingFailed() {
+	failures.Inc()
+}
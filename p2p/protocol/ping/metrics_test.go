@@ -0,0 +1,23 @@
+//go:build nocover
+
+package ping
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestMetricsNoAllocNoCover(t *testing.T) {
+	tr := NewMetricsTracer()
+	tests := map[string]func(){
+		"PingSucceeded": func() { tr.PingSucceeded(time.Duration(rand.Intn(1000)) * time.Millisecond) },
+		"PingFailed":    func() { tr.PingFailed() },
+	}
+	for method, f := range tests {
+		allocs := testing.AllocsPerRun(1000, f)
+		if allocs > 0 {
+			t.Fatalf("Alloc Test: %s, got: %0.2f, expected: 0 allocs", method, allocs)
+		}
+	}
+}
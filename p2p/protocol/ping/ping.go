@@ -6,8 +6,11 @@ import (
 	"crypto/rand"
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"io"
 	mrand "math/rand"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	logging "github.com/ipfs/go-log/v2"
@@ -23,6 +26,9 @@ const (
 	PingSize    = 32
 	pingTimeout = time.Second * 60
 
+	// MaxPayloadSize is the maximum payload size that can be set with WithPayloadSize.
+	MaxPayloadSize = 64 * 1024
+
 	ID = "/ipfs/ping/1.0.0"
 
 	ServiceName = "libp2p.ping"
@@ -30,14 +36,47 @@ const (
 
 type PingService struct {
 	Host host.Host
+
+	interval      time.Duration // 0 if background pings are disabled
+	metricsTracer MetricsTracer
+
+	ctx       context.Context
+	ctxCancel context.CancelFunc
+	refCount  sync.WaitGroup
+
+	notifiee *network.NotifyBundle
+
+	mx   sync.Mutex
+	rtts map[peer.ID]*rttWindow // only contains connected peers
 }
 
-func NewPingService(h host.Host) *PingService {
-	ps := &PingService{h}
+func NewPingService(h host.Host, opts ...Option) *PingService {
+	ps := &PingService{
+		Host: h,
+		rtts: make(map[peer.ID]*rttWindow),
+	}
+	for _, opt := range opts {
+		opt(ps)
+	}
+	ps.ctx, ps.ctxCancel = context.WithCancel(context.Background())
+	ps.notifiee = &network.NotifyBundle{DisconnectedF: ps.disconnected}
+	h.Network().Notify(ps.notifiee)
 	h.SetStreamHandler(ID, ps.PingHandler)
+	if ps.interval > 0 {
+		ps.refCount.Add(1)
+		go ps.background()
+	}
 	return ps
 }
 
+// Close stops the background pings, if enabled.
+func (ps *PingService) Close() error {
+	ps.Host.Network().StopNotify(ps.notifiee)
+	ps.ctxCancel()
+	ps.refCount.Wait()
+	return nil
+}
+
 func (p *PingService) PingHandler(s network.Stream) {
 	if err := s.Scope().SetService(ServiceName); err != nil {
 		log.Debugf("error attaching stream to ping service: %s", err)
@@ -97,8 +136,57 @@ type Result struct {
 	Error error
 }
 
-func (ps *PingService) Ping(ctx context.Context, p peer.ID) <-chan Result {
-	return Ping(ctx, ps.Host, p)
+// Ping pings the remote peer until the context is canceled, returning a stream
+// of RTTs or errors. The RTTs are included in the statistics returned by PeerRTT.
+func (ps *PingService) Ping(ctx context.Context, p peer.ID, opts ...PingOption) <-chan Result {
+	return pingPeer(ctx, ps.Host, p, ps.record, opts...)
+}
+
+type pingConfig struct {
+	payloadSize int
+	count       int
+	interval    time.Duration
+}
+
+// PingOption configures a call to Ping.
+type PingOption func(*pingConfig) error
+
+// WithPayloadSize sets the number of bytes sent in each ping, e.g. to probe the path MTU.
+// The ping protocol echoes data in chunks of PingSize, so size must be a multiple of
+// PingSize, and at most MaxPayloadSize. Defaults to PingSize.
+// The RTT is measured until the whole payload has been echoed.
+func WithPayloadSize(size int) PingOption {
+	return func(cfg *pingConfig) error {
+		if size <= 0 || size%PingSize != 0 || size > MaxPayloadSize {
+			return fmt.Errorf("invalid ping payload size %d: must be a positive multiple of %d, at most %d", size, PingSize, MaxPayloadSize)
+		}
+		cfg.payloadSize = size
+		return nil
+	}
+}
+
+// WithCount sets the number of pings to send. The result channel is closed after
+// count pings. By default, pings are sent until the context is canceled.
+func WithCount(count int) PingOption {
+	return func(cfg *pingConfig) error {
+		if count <= 0 {
+			return errors.New("ping count must be positive")
+		}
+		cfg.count = count
+		return nil
+	}
+}
+
+// WithInterval sets the time between two pings.
+// By default, the next ping is sent as soon as the previous result has been received.
+func WithInterval(interval time.Duration) PingOption {
+	return func(cfg *pingConfig) error {
+		if interval < 0 {
+			return errors.New("ping interval must not be negative")
+		}
+		cfg.interval = interval
+		return nil
+	}
 }
 
 func pingError(err error) chan Result {
@@ -108,38 +196,74 @@ func pingError(err error) chan Result {
 	return ch
 }
 
-// Ping pings the remote peer until the context is canceled, returning a stream
-// of RTTs or errors.
-func Ping(ctx context.Context, h host.Host, p peer.ID) <-chan Result {
+// Ping pings the remote peer until the context is canceled, or until the number
+// of pings set with WithCount has been sent, returning a stream of RTTs or errors.
+func Ping(ctx context.Context, h host.Host, p peer.ID, opts ...PingOption) <-chan Result {
+	return pingPeer(ctx, h, p, nil, opts...)
+}
+
+// pingPeer implements Ping. If record is set, it is called with every result.
+func pingPeer(ctx context.Context, h host.Host, p peer.ID, record func(peer.ID, Result), opts ...PingOption) <-chan Result {
+	cfg := pingConfig{payloadSize: PingSize}
+	for _, opt := range opts {
+		if err := opt(&cfg); err != nil {
+			return pingError(err)
+		}
+	}
+
+	fail := func(err error) <-chan Result {
+		if record != nil {
+			record(p, Result{Error: err})
+		}
+		return pingError(err)
+	}
+
 	s, err := h.NewStream(network.WithUseTransient(ctx, "ping"), p, ID)
 	if err != nil {
-		return pingError(err)
+		return fail(err)
 	}
 
 	if err := s.Scope().SetService(ServiceName); err != nil {
 		log.Debugf("error attaching stream to ping service: %s", err)
 		s.Reset()
-		return pingError(err)
+		return fail(err)
 	}
 
 	b := make([]byte, 8)
 	if _, err := rand.Read(b); err != nil {
 		log.Errorf("failed to get cryptographic random: %s", err)
 		s.Reset()
-		return pingError(err)
+		return fail(err)
 	}
 	ra := mrand.New(mrand.NewSource(int64(binary.BigEndian.Uint64(b))))
 
 	ctx, cancel := context.WithCancel(ctx)
 
+	// finished is set when all pings were sent, and the stream was closed
+	var finished atomic.Bool
 	out := make(chan Result)
 	go func() {
 		defer close(out)
 		defer cancel()
 
-		for ctx.Err() == nil {
+		for i := 0; ctx.Err() == nil; i++ {
+			if cfg.count > 0 && i == cfg.count {
+				finished.Store(true)
+				s.Close()
+				return
+			}
+			if i > 0 && cfg.interval > 0 {
+				t := time.NewTimer(cfg.interval)
+				select {
+				case <-t.C:
+				case <-ctx.Done():
+					t.Stop()
+					return
+				}
+			}
+
 			var res Result
-			res.RTT, res.Error = ping(s, ra)
+			res.RTT, res.Error = ping(s, ra, cfg.payloadSize)
 
 			// canceled, ignore everything.
 			if ctx.Err() != nil {
@@ -150,6 +274,9 @@ func Ping(ctx context.Context, h host.Host, p peer.ID) <-chan Result {
 			if res.Error == nil {
 				h.Peerstore().RecordLatency(p, res.RTT)
 			}
+			if record != nil {
+				record(p, res)
+			}
 
 			select {
 			case out <- res:
@@ -161,21 +288,23 @@ func Ping(ctx context.Context, h host.Host, p peer.ID) <-chan Result {
 	go func() {
 		// forces the ping to abort.
 		<-ctx.Done()
-		s.Reset()
+		if !finished.Load() {
+			s.Reset()
+		}
 	}()
 
 	return out
 }
 
-func ping(s network.Stream, randReader io.Reader) (time.Duration, error) {
-	if err := s.Scope().ReserveMemory(2*PingSize, network.ReservationPriorityAlways); err != nil {
+func ping(s network.Stream, randReader io.Reader, size int) (time.Duration, error) {
+	if err := s.Scope().ReserveMemory(2*size, network.ReservationPriorityAlways); err != nil {
 		log.Debugf("error reserving memory for ping stream: %s", err)
 		s.Reset()
 		return 0, err
 	}
-	defer s.Scope().ReleaseMemory(2 * PingSize)
+	defer s.Scope().ReleaseMemory(2 * size)
 
-	buf := pool.Get(PingSize)
+	buf := pool.Get(size)
 	defer pool.Put(buf)
 
 	if _, err := io.ReadFull(randReader, buf); err != nil {
@@ -187,7 +316,7 @@ func ping(s network.Stream, randReader io.Reader) (time.Duration, error) {
 		return 0, err
 	}
 
-	rbuf := pool.Get(PingSize)
+	rbuf := pool.Get(size)
 	defer pool.Put(rbuf)
 
 	if _, err := io.ReadFull(s, rbuf); err != nil {
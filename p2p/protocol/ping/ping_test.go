@@ -35,6 +35,15 @@ func TestPing(t *testing.T) {
 
 	testPing(t, ps1, h2.ID())
 	testPing(t, ps2, h1.ID())
+
+	_, ok := ps1.PeerRTT(h2.ID())
+	require.True(t, ok)
+	// the RTTs of disconnected peers are forgotten
+	require.NoError(t, h1.Network().ClosePeer(h2.ID()))
+	require.Eventually(t, func() bool {
+		_, ok := ps1.PeerRTT(h2.ID())
+		return !ok
+	}, 5*time.Second, 10*time.Millisecond)
 }
 
 func testPing(t *testing.T, ps *ping.PingService, p peer.ID) {
@@ -53,3 +62,72 @@ func testPing(t *testing.T, ps *ping.PingService, p peer.ID) {
 	}
 
 }
+
+func TestBackgroundPing(t *testing.T) {
+	h1, err := bhost.NewHost(swarmt.GenSwarm(t), nil)
+	require.NoError(t, err)
+	defer h1.Close()
+	h2, err := bhost.NewHost(swarmt.GenSwarm(t), nil)
+	require.NoError(t, err)
+	defer h2.Close()
+
+	ps1 := ping.NewPingService(h1, ping.WithBackgroundPing(50*time.Millisecond))
+	defer ps1.Close()
+	ping.NewPingService(h2)
+
+	_, ok := ps1.PeerRTT(h2.ID())
+	require.False(t, ok)
+
+	require.NoError(t, h1.Connect(context.Background(), peer.AddrInfo{ID: h2.ID(), Addrs: h2.Addrs()}))
+	require.Eventually(t, func() bool {
+		stats, ok := ps1.PeerRTT(h2.ID())
+		return ok && stats.Samples >= 3
+	}, 5*time.Second, 10*time.Millisecond)
+
+	stats, _ := ps1.PeerRTT(h2.ID())
+	require.NotZero(t, stats.Latest)
+	require.LessOrEqual(t, stats.P50, stats.P90)
+	require.LessOrEqual(t, stats.P90, stats.P99)
+	require.NotZero(t, h1.Peerstore().LatencyEWMA(h2.ID()))
+
+	// the RTTs of disconnected peers are forgotten
+	require.NoError(t, h1.Network().ClosePeer(h2.ID()))
+	require.Eventually(t, func() bool {
+		_, ok := ps1.PeerRTT(h2.ID())
+		return !ok
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestPingOptions(t *testing.T) {
+	h1, err := bhost.NewHost(swarmt.GenSwarm(t), nil)
+	require.NoError(t, err)
+	defer h1.Close()
+	h2, err := bhost.NewHost(swarmt.GenSwarm(t), nil)
+	require.NoError(t, err)
+	defer h2.Close()
+	ping.NewPingService(h2)
+	require.NoError(t, h1.Connect(context.Background(), peer.AddrInfo{ID: h2.ID(), Addrs: h2.Addrs()}))
+
+	start := time.Now()
+	var n int
+	for res := range ping.Ping(context.Background(), h1, h2.ID(),
+		ping.WithCount(3),
+		ping.WithPayloadSize(32*ping.PingSize),
+		ping.WithInterval(50*time.Millisecond),
+	) {
+		require.NoError(t, res.Error)
+		n++
+	}
+	require.Equal(t, 3, n)
+	require.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+
+	for _, opt := range []ping.PingOption{
+		ping.WithPayloadSize(ping.PingSize + 1),
+		ping.WithPayloadSize(2 * ping.MaxPayloadSize),
+		ping.WithCount(0),
+		ping.WithInterval(-time.Second),
+	} {
+		res := <-ping.Ping(context.Background(), h1, h2.ID(), opt)
+		require.Error(t, res.Error)
+	}
+}
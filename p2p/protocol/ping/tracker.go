@@ -0,0 +1,194 @@
+package ping
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/AstaFrode/go-libp2p/core/network"
+	"github.com/AstaFrode/go-libp2p/core/peer"
+)
+
+const (
+	// rttWindowSize is the number of RTT samples per peer used to calculate the percentiles
+	rttWindowSize = 100
+	// maxConcurrentPings is the maximum number of peers pinged concurrently in the background
+	maxConcurrentPings = 16
+	// maxBackgroundPingTimeout bounds the time we wait for a background ping
+	maxBackgroundPingTimeout = 30 * time.Second
+)
+
+type Option func(*PingService)
+
+// WithBackgroundPing makes the PingService ping all connected peers every interval.
+// The RTTs are recorded in the peerstore (see peerstore.Metrics), and can be queried
+// using PeerRTT. Peers that are only connected via transient (relayed) connections
+// are not pinged, to save the limited resources of these connections.
+func WithBackgroundPing(interval time.Duration) Option {
+	return func(ps *PingService) {
+		ps.interval = interval
+	}
+}
+
+// WithMetricsTracer sets the metrics tracer for the pings sent by the PingService.
+func WithMetricsTracer(mt MetricsTracer) Option {
+	return func(ps *PingService) {
+		ps.metricsTracer = mt
+	}
+}
+
+// RTTStats are statistics about the RTT samples of a peer.
+type RTTStats struct {
+	// Samples is the number of samples, up to the last 100 pings.
+	Samples int
+	// Latest is the RTT of the last successful ping.
+	Latest        time.Duration
+	P50, P90, P99 time.Duration
+}
+
+// rttWindow is a ring buffer of the last rttWindowSize RTT samples.
+type rttWindow struct {
+	samples []time.Duration
+	next    int
+	latest  time.Duration
+}
+
+func (w *rttWindow) add(rtt time.Duration) {
+	w.latest = rtt
+	if len(w.samples) < rttWindowSize {
+		w.samples = append(w.samples, rtt)
+		return
+	}
+	w.samples[w.next] = rtt
+	w.next = (w.next + 1) % rttWindowSize
+}
+
+func (w *rttWindow) stats() RTTStats {
+	sorted := append([]time.Duration(nil), w.samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	percentile := func(p int) time.Duration {
+		return sorted[(len(sorted)-1)*p/100]
+	}
+	return RTTStats{
+		Samples: len(sorted),
+		Latest:  w.latest,
+		P50:     percentile(50),
+		P90:     percentile(90),
+		P99:     percentile(99),
+	}
+}
+
+// PeerRTT returns the RTT statistics of a peer, from the pings sent by this service.
+// It returns false if no ping to the peer succeeded yet. The statistics are forgotten
+// when the peer disconnects.
+func (ps *PingService) PeerRTT(p peer.ID) (RTTStats, bool) {
+	ps.mx.Lock()
+	defer ps.mx.Unlock()
+	w, ok := ps.rtts[p]
+	if !ok {
+		return RTTStats{}, false
+	}
+	return w.stats(), true
+}
+
+func (ps *PingService) record(p peer.ID, res Result) {
+	if res.Error != nil {
+		if ps.metricsTracer != nil {
+			ps.metricsTracer.PingFailed()
+		}
+		return
+	}
+	if ps.metricsTracer != nil {
+		ps.metricsTracer.PingSucceeded(res.RTT)
+	}
+
+	ps.mx.Lock()
+	defer ps.mx.Unlock()
+	// Checked with the lock held, so that we don't race with disconnected.
+	if ps.Host.Network().Connectedness(p) != network.Connected {
+		return
+	}
+	w, ok := ps.rtts[p]
+	if !ok {
+		w = &rttWindow{}
+		ps.rtts[p] = w
+	}
+	w.add(res.RTT)
+}
+
+// disconnected forgets the RTTs of a peer once we're not connected to it anymore.
+func (ps *PingService) disconnected(n network.Network, c network.Conn) {
+	p := c.RemotePeer()
+	if n.Connectedness(p) == network.Connected {
+		return
+	}
+	ps.mx.Lock()
+	delete(ps.rtts, p)
+	ps.mx.Unlock()
+}
+
+func (ps *PingService) background() {
+	defer ps.refCount.Done()
+
+	ticker := time.NewTicker(ps.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			ps.pingAll()
+		case <-ps.ctx.Done():
+			return
+		}
+	}
+}
+
+// pingAll pings all connected peers once.
+func (ps *PingService) pingAll() {
+	n := ps.Host.Network()
+	peers := n.Peers()
+
+	timeout := ps.interval
+	if timeout > maxBackgroundPingTimeout {
+		timeout = maxBackgroundPingTimeout
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, maxConcurrentPings)
+	for _, p := range peers {
+		if !hasDirectConn(n, p) {
+			continue
+		}
+		select {
+		case sem <- struct{}{}:
+		case <-ps.ctx.Done():
+			wg.Wait()
+			return
+		}
+		wg.Add(1)
+		go func(p peer.ID) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			ctx, cancel := context.WithTimeout(ps.ctx, timeout)
+			defer cancel()
+			ctx = network.WithNoDial(ctx, "background ping")
+			res, ok := <-pingPeer(ctx, ps.Host, p, ps.record)
+			if ok && res.Error != nil {
+				log.Debugw("background ping failed", "peer", p, "error", res.Error)
+			}
+		}(p)
+	}
+	wg.Wait()
+}
+
+// hasDirectConn returns true if we have a non-transient connection to p.
+func hasDirectConn(n network.Network, p peer.ID) bool {
+	for _, c := range n.ConnsToPeer(p) {
+		if !c.Stat().Transient {
+			return true
+		}
+	}
+	return false
+}
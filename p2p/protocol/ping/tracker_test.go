@@ -0,0 +1,29 @@
+package ping
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRTTWindow(t *testing.T) {
+	var w rttWindow
+	for i := 1; i <= 100; i++ {
+		w.add(time.Duration(i) * time.Millisecond)
+	}
+	stats := w.stats()
+	require.Equal(t, 100, stats.Samples)
+	require.Equal(t, 100*time.Millisecond, stats.Latest)
+	require.Equal(t, 50*time.Millisecond, stats.P50)
+	require.Equal(t, 90*time.Millisecond, stats.P90)
+	require.Equal(t, 99*time.Millisecond, stats.P99)
+
+	// old samples are dropped
+	for i := 0; i < 100; i++ {
+		w.add(time.Second)
+	}
+	stats = w.stats()
+	require.Equal(t, 100, stats.Samples)
+	require.Equal(t, time.Second, stats.P50)
+}
@@ -0,0 +1,267 @@
+// Package delegated implements a delegated routing client, resolving peer
+// addresses through an HTTP gateway implementing the Delegated Routing V1 HTTP API
+// (see https://specs.ipfs.tech/routing/http-routing-v1/).
+//
+// This allows lightweight nodes to find peers without running a DHT:
+//
+//	h, err := libp2p.New(libp2p.Routing(delegated.Constructor("https://delegated-ipfs.dev")))
+package delegated
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/AstaFrode/go-libp2p/core/host"
+	"github.com/AstaFrode/go-libp2p/core/peer"
+	"github.com/AstaFrode/go-libp2p/core/routing"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+	logging "github.com/ipfs/go-log/v2"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+var log = logging.Logger("routing/delegated")
+
+const (
+	defaultCacheSize = 1024
+	defaultCacheTTL  = 5 * time.Minute
+	defaultTimeout   = 30 * time.Second
+
+	// maxResponseSize limits the size of the response body we're willing to read.
+	maxResponseSize = 1 << 20
+
+	mediaTypeJSON = "application/json"
+)
+
+// Option is an option for the delegated routing client.
+type Option func(*Client) error
+
+// WithHTTPClient sets the HTTP client used to send requests.
+// By default, a client with a 30s timeout is used.
+func WithHTTPClient(c *http.Client) Option {
+	return func(cl *Client) error {
+		if c == nil {
+			return errors.New("HTTP client cannot be nil")
+		}
+		cl.httpClient = c
+		return nil
+	}
+}
+
+// WithUserAgent sets the User-Agent header sent with every request.
+func WithUserAgent(ua string) Option {
+	return func(cl *Client) error {
+		cl.userAgent = ua
+		return nil
+	}
+}
+
+// WithCache configures the cache of resolved peers.
+// Setting size to 0 disables caching. Defaults to 1024 entries, cached for 5 minutes.
+func WithCache(size int, ttl time.Duration) Option {
+	return func(cl *Client) error {
+		if size < 0 || ttl < 0 {
+			return errors.New("cache size and TTL must not be negative")
+		}
+		cl.cacheSize = size
+		cl.cacheTTL = ttl
+		return nil
+	}
+}
+
+// WithFallback sets a local router (e.g. a DHT) that is queried in parallel
+// with the delegated routing endpoint. The first successful response is used.
+func WithFallback(r routing.PeerRouting) Option {
+	return func(cl *Client) error {
+		cl.fallback = r
+		return nil
+	}
+}
+
+type cacheEntry struct {
+	info   peer.AddrInfo
+	expiry time.Time
+}
+
+// Client is a routing.PeerRouting that resolves peers using a delegated routing endpoint.
+type Client struct {
+	endpoint   string
+	httpClient *http.Client
+	userAgent  string
+	fallback   routing.PeerRouting
+
+	cacheSize int
+	cacheTTL  time.Duration
+	cache     *lru.Cache[peer.ID, cacheEntry] // nil if caching is disabled
+}
+
+var _ routing.PeerRouting = &Client{}
+
+// New creates a new delegated routing client, sending requests to endpoint,
+// e.g. "https://delegated-ipfs.dev".
+func New(endpoint string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid endpoint: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid endpoint: unsupported scheme %q", u.Scheme)
+	}
+	c := &Client{
+		endpoint:   strings.TrimSuffix(endpoint, "/"),
+		httpClient: &http.Client{Timeout: defaultTimeout},
+		cacheSize:  defaultCacheSize,
+		cacheTTL:   defaultCacheTTL,
+	}
+	for _, opt := range opts {
+		if err := opt(c); err != nil {
+			return nil, err
+		}
+	}
+	if c.cacheSize > 0 && c.cacheTTL > 0 {
+		c.cache, err = lru.New[peer.ID, cacheEntry](c.cacheSize)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// Constructor returns a constructor that can be passed to libp2p.Routing.
+func Constructor(endpoint string, opts ...Option) func(host.Host) (routing.PeerRouting, error) {
+	return func(host.Host) (routing.PeerRouting, error) {
+		return New(endpoint, opts...)
+	}
+}
+
+// FindPeer resolves the addresses of peer p.
+// It returns routing.ErrNotFound if neither the delegated routing endpoint
+// nor the fallback router know the peer.
+func (c *Client) FindPeer(ctx context.Context, p peer.ID) (peer.AddrInfo, error) {
+	if c.cache != nil {
+		if e, ok := c.cache.Get(p); ok {
+			if time.Now().Before(e.expiry) {
+				return e.info, nil
+			}
+			c.cache.Remove(p)
+		}
+	}
+
+	info, err := c.findPeer(ctx, p)
+	if err != nil {
+		return peer.AddrInfo{}, err
+	}
+	if c.cache != nil {
+		c.cache.Add(p, cacheEntry{info: info, expiry: time.Now().Add(c.cacheTTL)})
+	}
+	return info, nil
+}
+
+func (c *Client) findPeer(ctx context.Context, p peer.ID) (peer.AddrInfo, error) {
+	if c.fallback == nil {
+		return c.findPeerDelegated(ctx, p)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		info peer.AddrInfo
+		err  error
+	}
+	results := make(chan result, 2)
+	go func() {
+		info, err := c.findPeerDelegated(ctx, p)
+		results <- result{info: info, err: err}
+	}()
+	go func() {
+		info, err := c.fallback.FindPeer(ctx, p)
+		if err == nil && len(info.Addrs) == 0 {
+			err = routing.ErrNotFound
+		}
+		results <- result{info: info, err: err}
+	}()
+
+	var errs []error
+	for i := 0; i < 2; i++ {
+		r := <-results
+		if r.err == nil {
+			return r.info, nil
+		}
+		errs = append(errs, r.err)
+	}
+	if errors.Is(errs[0], routing.ErrNotFound) && errors.Is(errs[1], routing.ErrNotFound) {
+		return peer.AddrInfo{}, routing.ErrNotFound
+	}
+	return peer.AddrInfo{}, errors.Join(errs...)
+}
+
+type peerRecord struct {
+	Schema string
+	ID     string
+	Addrs  []string
+}
+
+type peersResponse struct {
+	Peers []peerRecord
+}
+
+func (c *Client) findPeerDelegated(ctx context.Context, p peer.ID) (peer.AddrInfo, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.endpoint+"/routing/v1/peers/"+peer.ToCid(p).String(), nil)
+	if err != nil {
+		return peer.AddrInfo{}, err
+	}
+	req.Header.Set("Accept", mediaTypeJSON)
+	if c.userAgent != "" {
+		req.Header.Set("User-Agent", c.userAgent)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return peer.AddrInfo{}, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return peer.AddrInfo{}, routing.ErrNotFound
+	default:
+		return peer.AddrInfo{}, fmt.Errorf("delegated routing request failed: %s", resp.Status)
+	}
+
+	var pr peersResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&pr); err != nil {
+		return peer.AddrInfo{}, fmt.Errorf("failed to decode delegated routing response: %w", err)
+	}
+
+	info := peer.AddrInfo{ID: p}
+	for _, rec := range pr.Peers {
+		if rec.Schema != "peer" {
+			continue
+		}
+		id, err := peer.Decode(rec.ID)
+		if err != nil || id != p {
+			continue
+		}
+		for _, s := range rec.Addrs {
+			addr, err := ma.NewMultiaddr(s)
+			if err != nil {
+				log.Debugw("ignoring invalid address in delegated routing response", "peer", p, "addr", s, "error", err)
+				continue
+			}
+			info.Addrs = append(info.Addrs, addr)
+		}
+	}
+	if len(info.Addrs) == 0 {
+		return peer.AddrInfo{}, routing.ErrNotFound
+	}
+	return info, nil
+}
@@ -0,0 +1,115 @@
+package delegated
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/AstaFrode/go-libp2p/core/peer"
+	"github.com/AstaFrode/go-libp2p/core/routing"
+	"github.com/AstaFrode/go-libp2p/core/test"
+
+	"github.com/ipfs/go-cid"
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/require"
+)
+
+func newServer(t *testing.T, known peer.ID, addrs ...string) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var requests atomic.Int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		c, err := cid.Decode(strings.TrimPrefix(r.URL.Path, "/routing/v1/peers/"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if p, err := peer.FromCid(c); err != nil || p != known {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", mediaTypeJSON)
+		json.NewEncoder(w).Encode(peersResponse{Peers: []peerRecord{{Schema: "peer", ID: known.String(), Addrs: addrs}}})
+	}))
+	t.Cleanup(s.Close)
+	return s, &requests
+}
+
+type mockRouter struct {
+	info peer.AddrInfo
+	err  error
+}
+
+func (r *mockRouter) FindPeer(ctx context.Context, p peer.ID) (peer.AddrInfo, error) {
+	return r.info, r.err
+}
+
+func TestFindPeer(t *testing.T) {
+	p := test.RandPeerIDFatal(t)
+	s, requests := newServer(t, p, "/ip4/1.2.3.4/tcp/1234", "invalid")
+
+	c, err := New(s.URL)
+	require.NoError(t, err)
+
+	info, err := c.FindPeer(context.Background(), p)
+	require.NoError(t, err)
+	require.Equal(t, p, info.ID)
+	require.Equal(t, []ma.Multiaddr{ma.StringCast("/ip4/1.2.3.4/tcp/1234")}, info.Addrs)
+
+	// the second lookup is served from the cache
+	_, err = c.FindPeer(context.Background(), p)
+	require.NoError(t, err)
+	require.Equal(t, int32(1), requests.Load())
+
+	_, err = c.FindPeer(context.Background(), test.RandPeerIDFatal(t))
+	require.ErrorIs(t, err, routing.ErrNotFound)
+}
+
+func TestCacheExpiry(t *testing.T) {
+	p := test.RandPeerIDFatal(t)
+	s, requests := newServer(t, p, "/ip4/1.2.3.4/tcp/1234")
+
+	c, err := New(s.URL, WithCache(10, 50*time.Millisecond))
+	require.NoError(t, err)
+	_, err = c.FindPeer(context.Background(), p)
+	require.NoError(t, err)
+	time.Sleep(100 * time.Millisecond)
+	_, err = c.FindPeer(context.Background(), p)
+	require.NoError(t, err)
+	require.Equal(t, int32(2), requests.Load())
+}
+
+func TestFallback(t *testing.T) {
+	p := test.RandPeerIDFatal(t)
+	s, _ := newServer(t, test.RandPeerIDFatal(t))
+
+	addr := ma.StringCast("/ip4/5.6.7.8/udp/1234/quic-v1")
+	c, err := New(s.URL, WithFallback(&mockRouter{info: peer.AddrInfo{ID: p, Addrs: []ma.Multiaddr{addr}}}))
+	require.NoError(t, err)
+	info, err := c.FindPeer(context.Background(), p)
+	require.NoError(t, err)
+	require.Equal(t, []ma.Multiaddr{addr}, info.Addrs)
+
+	// if both routers don't know the peer, ErrNotFound is returned
+	c, err = New(s.URL, WithFallback(&mockRouter{err: routing.ErrNotFound}))
+	require.NoError(t, err)
+	_, err = c.FindPeer(context.Background(), p)
+	require.Equal(t, routing.ErrNotFound, err)
+
+	// other errors are passed through
+	c, err = New(s.URL, WithFallback(&mockRouter{err: errors.New("dht failed")}))
+	require.NoError(t, err)
+	_, err = c.FindPeer(context.Background(), p)
+	require.ErrorContains(t, err, "dht failed")
+}
+
+func TestInvalidEndpoint(t *testing.T) {
+	_, err := New("ftp://example.com")
+	require.Error(t, err)
+}
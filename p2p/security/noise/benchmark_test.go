@@ -2,12 +2,15 @@ package noise
 
 import (
 	"context"
+	crand "crypto/rand"
 	"io"
 	"math/rand"
 	"net"
 	"testing"
 	"time"
 
+	"github.com/flynn/noise"
+	pool "github.com/libp2p/go-buffer-pool"
 	"golang.org/x/crypto/chacha20poly1305"
 
 	"github.com/AstaFrode/go-libp2p/core/crypto"
@@ -249,3 +252,51 @@ func BenchmarkHandshakeXX(b *testing.B) {
 	env := setupEnv(b)
 	env.benchHandshake()
 }
+
+func BenchmarkHandshakePayload(b *testing.B) {
+	env := setupEnv(b)
+	initSession, respSession := env.connect(true)
+	defer initSession.Close()
+	defer respSession.Close()
+
+	kp, err := noise.DH25519.GenerateKeypair(crand.Reader)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Run("generate", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			payload, err := initSession.generateHandshakePayload(kp, nil)
+			if err != nil {
+				b.Fatal(err)
+			}
+			pool.Put(payload)
+		}
+	})
+	b.Run("verify", func(b *testing.B) {
+		payload, err := initSession.generateHandshakePayload(kp, nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.ResetTimer()
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := respSession.handleRemoteHandshakePayload(payload, kp.Public); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkHandshakeXXParallel(b *testing.B) {
+	env := setupEnv(b)
+	b.ResetTimer()
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			i, r := env.connect(false)
+			i.Close()
+			r.Close()
+		}
+	})
+}
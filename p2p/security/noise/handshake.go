@@ -90,6 +90,7 @@ func (s *secureSession) runHandshake(ctx context.Context) (err error) {
 			return fmt.Errorf("error reading handshake message: %w", err)
 		}
 		rcvdEd, err := s.handleRemoteHandshakePayload(plaintext, hs.PeerStatic())
+		pool.Put(plaintext)
 		if err != nil {
 			return err
 		}
@@ -109,15 +110,19 @@ func (s *secureSession) runHandshake(ctx context.Context) (err error) {
 		if err != nil {
 			return err
 		}
-		if err := s.sendHandshakeMessage(hs, payload, hbuf); err != nil {
+		err = s.sendHandshakeMessage(hs, payload, hbuf)
+		pool.Put(payload)
+		if err != nil {
 			return fmt.Errorf("error sending handshake message: %w", err)
 		}
 		return nil
 	} else {
 		// stage 0 //
-		if _, err := s.readHandshakeMessage(hs); err != nil {
+		plaintext, err := s.readHandshakeMessage(hs)
+		if err != nil {
 			return fmt.Errorf("error reading handshake message: %w", err)
 		}
+		pool.Put(plaintext)
 
 		// stage 1 //
 		// Handshake Msg Len = len(DH ephemeral key) + len(DHT static key) +  MAC(static key is encrypted) + len(Payload) +
@@ -130,16 +135,19 @@ func (s *secureSession) runHandshake(ctx context.Context) (err error) {
 		if err != nil {
 			return err
 		}
-		if err := s.sendHandshakeMessage(hs, payload, hbuf); err != nil {
+		err = s.sendHandshakeMessage(hs, payload, hbuf)
+		pool.Put(payload)
+		if err != nil {
 			return fmt.Errorf("error sending handshake message: %w", err)
 		}
 
 		// stage 2 //
-		plaintext, err := s.readHandshakeMessage(hs)
+		plaintext, err = s.readHandshakeMessage(hs)
 		if err != nil {
 			return fmt.Errorf("error reading handshake message: %w", err)
 		}
 		rcvdEd, err := s.handleRemoteHandshakePayload(plaintext, hs.PeerStatic())
+		pool.Put(plaintext)
 		if err != nil {
 			return err
 		}
@@ -198,6 +206,8 @@ func (s *secureSession) sendHandshakeMessage(hs *noise.HandshakeState, payload [
 // process it as the expected next message in the handshake sequence.
 //
 // If the message contains a payload, it will be decrypted and returned.
+// The payload is allocated from the buffer pool, and must be returned to
+// the pool by the caller.
 //
 // If this is the final message in the sequence, it calls setCipherStates
 // to initialize cipher states.
@@ -214,7 +224,9 @@ func (s *secureSession) readHandshakeMessage(hs *noise.HandshakeState) ([]byte,
 		return nil, err
 	}
 
-	msg, cs1, cs2, err := hs.ReadMessage(nil, buf)
+	// The payload is shorter than the message, so this buffer is large enough
+	// to decrypt it without allocating.
+	msg, cs1, cs2, err := hs.ReadMessage(pool.Get(l)[:0], buf)
 	if err != nil {
 		return nil, err
 	}
@@ -226,33 +238,49 @@ func (s *secureSession) readHandshakeMessage(hs *noise.HandshakeState) ([]byte,
 
 // generateHandshakePayload creates a libp2p handshake payload with a
 // signature of our static noise key.
+// The payload is allocated from the buffer pool, and must be returned to
+// the pool by the caller.
 func (s *secureSession) generateHandshakePayload(localStatic noise.DHKey, ext *pb.NoiseExtensions) ([]byte, error) {
-	// obtain the public key from the handshake session, so we can sign it with
+	// obtain the public key from the transport, so we can sign it with
 	// our libp2p secret key.
-	localKeyRaw, err := crypto.MarshalPublicKey(s.LocalPublicKey())
+	localKeyRaw, err := s.tpt.marshaledPublicKey()
 	if err != nil {
 		return nil, fmt.Errorf("error serializing libp2p identity key: %w", err)
 	}
 
 	// prepare payload to sign; perform signature.
-	toSign := append([]byte(payloadSigPrefix), localStatic.Public...)
+	toSign := signedStaticKey(localStatic.Public)
 	signedPayload, err := s.localKey.Sign(toSign)
+	pool.Put(toSign)
 	if err != nil {
 		return nil, fmt.Errorf("error sigining handshake payload: %w", err)
 	}
 
 	// create payload
-	payloadEnc, err := proto.Marshal(&pb.NoiseHandshakePayload{
+	payload := &pb.NoiseHandshakePayload{
 		IdentityKey: localKeyRaw,
 		IdentitySig: signedPayload,
 		Extensions:  ext,
-	})
+	}
+	buf := pool.Get(proto.Size(payload))
+	payloadEnc, err := proto.MarshalOptions{}.MarshalAppend(buf[:0], payload)
 	if err != nil {
+		pool.Put(buf)
 		return nil, fmt.Errorf("error marshaling handshake payload: %w", err)
 	}
 	return payloadEnc, nil
 }
 
+// signedStaticKey returns the data signed with the libp2p identity key in the
+// handshake payload: the static noise key, prefixed with payloadSigPrefix.
+// The returned buffer is allocated from the buffer pool.
+func signedStaticKey(static []byte) []byte {
+	buf := pool.Get(len(payloadSigPrefix) + len(static))
+	n := copy(buf, payloadSigPrefix)
+	copy(buf[n:], static)
+	return buf
+}
+
 // handleRemoteHandshakePayload unmarshals the handshake payload object sent
 // by the remote peer and validates the signature against the peer's static Noise key.
 // It returns the data attached to the payload.
@@ -281,8 +309,9 @@ func (s *secureSession) handleRemoteHandshakePayload(payload []byte, remoteStati
 
 	// verify payload is signed by asserted remote libp2p key.
 	sig := nhp.GetIdentitySig()
-	msg := append([]byte(payloadSigPrefix), remoteStatic...)
+	msg := signedStaticKey(remoteStatic)
 	ok, err := remotePubKey.Verify(msg, sig)
+	pool.Put(msg)
 	if err != nil {
 		return nil, fmt.Errorf("error verifying signature: %w", err)
 	} else if !ok {
@@ -19,6 +19,8 @@ type secureSession struct {
 	initiator   bool
 	checkPeerID bool
 
+	tpt *Transport
+
 	localID   peer.ID
 	localKey  crypto.PrivKey
 	remoteID  peer.ID
@@ -51,6 +53,7 @@ type secureSession struct {
 // the libp2p identity keypair from the given Transport.
 func newSecureSession(tpt *Transport, ctx context.Context, insecure net.Conn, remote peer.ID, prologue []byte, initiatorEDH, responderEDH EarlyDataHandler, initiator, checkPeerID bool) (*secureSession, error) {
 	s := &secureSession{
+		tpt:                       tpt,
 		insecureConn:              insecure,
 		insecureReader:            bufio.NewReader(insecure),
 		initiator:                 initiator,
@@ -61,6 +64,7 @@ func newSecureSession(tpt *Transport, ctx context.Context, insecure net.Conn, re
 		initiatorEarlyDataHandler: initiatorEDH,
 		responderEarlyDataHandler: responderEDH,
 		checkPeerID:               checkPeerID,
+		connectionState:           network.ConnectionState{CipherSuite: "Noise_XX_" + string(cipherSuite.Name())},
 	}
 
 	// the go-routine we create to run the handshake will
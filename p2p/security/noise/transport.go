@@ -3,6 +3,7 @@ package noise
 import (
 	"context"
 	"net"
+	"sync"
 
 	"github.com/AstaFrode/go-libp2p/core/canonicallog"
 	"github.com/AstaFrode/go-libp2p/core/crypto"
@@ -24,6 +25,11 @@ type Transport struct {
 	localID    peer.ID
 	privateKey crypto.PrivKey
 	muxers     []protocol.ID
+
+	// the marshaled public identity key, sent in every handshake
+	pubKeyOnce sync.Once
+	pubKeyRaw  []byte
+	pubKeyErr  error
 }
 
 var _ sec.SecureTransport = &Transport{}
@@ -73,6 +79,15 @@ func (t *Transport) SecureOutbound(ctx context.Context, insecure net.Conn, p pee
 	return SessionWithConnState(c, initiatorEDH.MatchMuxers(true)), err
 }
 
+// marshaledPublicKey returns the marshaled public identity key.
+// It's only marshaled once, as it's the same for all handshakes.
+func (t *Transport) marshaledPublicKey() ([]byte, error) {
+	t.pubKeyOnce.Do(func() {
+		t.pubKeyRaw, t.pubKeyErr = crypto.MarshalPublicKey(t.privateKey.GetPublic())
+	})
+	return t.pubKeyRaw, t.pubKeyErr
+}
+
 func (t *Transport) WithSessionOptions(opts ...SessionOption) (*SessionTransport, error) {
 	st := &SessionTransport{t: t, protocolID: t.protocolID}
 	for _, opt := range opts {
@@ -3,14 +3,18 @@ package noise
 import (
 	"bytes"
 	"context"
+	crand "crypto/rand"
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"io"
 	"math/rand"
 	"net"
 	"testing"
 	"time"
 
+	"github.com/flynn/noise"
+	pool "github.com/libp2p/go-buffer-pool"
 	"golang.org/x/crypto/chacha20poly1305"
 
 	"github.com/AstaFrode/go-libp2p/core/crypto"
@@ -171,6 +175,25 @@ func TestKeys(t *testing.T) {
 	}
 }
 
+func TestHandshakeExtendedKeyTypes(t *testing.T) {
+	require.NoError(t, crypto.EnableExperimentalKeyTypes(1000, 1001))
+	for _, typ := range []int{crypto.Ed448, crypto.BLS12381} {
+		t.Run(fmt.Sprint(typ), func(t *testing.T) {
+			initTransport := newTestTransport(t, typ, 0)
+			respTransport := newTestTransport(t, typ, 0)
+
+			initConn, respConn := connect(t, initTransport, respTransport)
+			defer initConn.Close()
+			defer respConn.Close()
+
+			require.True(t, initConn.RemotePublicKey().Equals(respTransport.privateKey.GetPublic()))
+			require.True(t, respConn.RemotePublicKey().Equals(initTransport.privateKey.GetPublic()))
+			require.Equal(t, respTransport.localID, initConn.RemotePeer())
+			require.Equal(t, initTransport.localID, respConn.RemotePeer())
+		})
+	}
+}
+
 func TestPeerIDMatch(t *testing.T) {
 	initTransport := newTestTransport(t, crypto.Ed25519, 2048)
 	respTransport := newTestTransport(t, crypto.Ed25519, 2048)
@@ -689,6 +712,8 @@ func TestHandshakeWithTransportEarlyData(t *testing.T) {
 		require.Equal(t, expectedProto != "", initConn.connectionState.UsedEarlyMuxerNegotiation)
 		require.Equal(t, expectedProto, respConn.connectionState.StreamMultiplexer)
 		require.Equal(t, expectedProto != "", respConn.connectionState.UsedEarlyMuxerNegotiation)
+		require.Equal(t, "Noise_XX_25519_ChaChaPoly_SHA256", initConn.ConnState().CipherSuite)
+		require.Equal(t, "Noise_XX_25519_ChaChaPoly_SHA256", respConn.ConnState().CipherSuite)
 
 		initData := []byte("Test data for noise transport")
 		_, err := initConn.Write(initData)
@@ -706,3 +731,58 @@ func TestHandshakeWithTransportEarlyData(t *testing.T) {
 		})
 	}
 }
+
+// The handshake is performed for every connection, so it should allocate as little as possible.
+// These limits catch allocation regressions. They leave some slack for buffers that the buffer pool
+// doesn't retain. See BenchmarkHandshakeXX and BenchmarkHandshakePayload for more details.
+func TestHandshakeAllocs(t *testing.T) {
+	initTransport := newTestTransport(t, crypto.Ed25519, 2048)
+	respTransport := newTestTransport(t, crypto.Ed25519, 2048)
+
+	t.Run("handshake", func(t *testing.T) {
+		allocs := testing.AllocsPerRun(20, func() {
+			init, resp := net.Pipe()
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				if _, err := initTransport.SecureOutbound(context.Background(), init, respTransport.localID); err != nil {
+					t.Error(err)
+				}
+			}()
+			if _, err := respTransport.SecureInbound(context.Background(), resp, ""); err != nil {
+				t.Error(err)
+			}
+			<-done
+			init.Close()
+			resp.Close()
+		})
+		require.LessOrEqual(t, allocs, 300.0)
+	})
+
+	initConn, respConn := connect(t, initTransport, respTransport)
+	defer initConn.Close()
+	defer respConn.Close()
+	kp, err := noise.DH25519.GenerateKeypair(crand.Reader)
+	require.NoError(t, err)
+	payload, err := initConn.generateHandshakePayload(kp, nil)
+	require.NoError(t, err)
+
+	t.Run("payload generation", func(t *testing.T) {
+		allocs := testing.AllocsPerRun(100, func() {
+			payload, err := initConn.generateHandshakePayload(kp, nil)
+			if err != nil {
+				t.Error(err)
+			}
+			pool.Put(payload)
+		})
+		require.LessOrEqual(t, allocs, 5.0)
+	})
+	t.Run("payload verification", func(t *testing.T) {
+		allocs := testing.AllocsPerRun(100, func() {
+			if _, err := respConn.handleRemoteHandshakePayload(payload, kp.Public); err != nil {
+				t.Error(err)
+			}
+		})
+		require.LessOrEqual(t, allocs, 16.0)
+	})
+}
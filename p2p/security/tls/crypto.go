@@ -34,12 +34,22 @@ type signedKey struct {
 
 // Identity is used to secure connections
 type Identity struct {
-	config tls.Config
+	config       tls.Config
+	certVerifier CertVerifier
 }
 
+// CertVerifier verifies the certificate presented by a peer during the handshake.
+// It's called after the certificate was verified to carry a valid libp2p key extension,
+// pubKey being the peer's libp2p public key. Returning an error aborts the handshake.
+type CertVerifier func(cert *x509.Certificate, pubKey ic.PubKey) error
+
 // IdentityConfig is used to configure an Identity
 type IdentityConfig struct {
 	CertTemplate *x509.Certificate
+	// Extensions are embedded in the certificate, in addition to the libp2p key extension.
+	Extensions []pkix.Extension
+	// CertVerifier, if set, is used to verify the certificates presented by peers.
+	CertVerifier CertVerifier
 }
 
 // IdentityOption transforms an IdentityConfig to apply optional settings.
@@ -52,6 +62,25 @@ func WithCertTemplate(template *x509.Certificate) IdentityOption {
 	}
 }
 
+// WithCertExtensions embeds additional extensions in the certificate, e.g. to carry
+// organization-level attestations alongside the libp2p key extension.
+//
+// The extensions should not be marked critical: peers that don't handle them would
+// fail to verify the certificate.
+func WithCertExtensions(exts ...pkix.Extension) IdentityOption {
+	return func(c *IdentityConfig) {
+		c.Extensions = append(c.Extensions, exts...)
+	}
+}
+
+// WithCertVerifier sets a callback to verify the certificates presented by peers,
+// e.g. to check the extensions added using WithCertExtensions.
+func WithCertVerifier(verifier CertVerifier) IdentityOption {
+	return func(c *IdentityConfig) {
+		c.CertVerifier = verifier
+	}
+}
+
 // NewIdentity creates a new identity
 func NewIdentity(privKey ic.PrivKey, opts ...IdentityOption) (*Identity, error) {
 	config := IdentityConfig{}
@@ -66,12 +95,19 @@ func NewIdentity(privKey ic.PrivKey, opts ...IdentityOption) (*Identity, error)
 			return nil, err
 		}
 	}
+	for _, ext := range config.Extensions {
+		if extensionIDEqual(ext.Id, extensionID) {
+			return nil, errors.New("extension conflicts with the libp2p key extension")
+		}
+		config.CertTemplate.ExtraExtensions = append(config.CertTemplate.ExtraExtensions, ext)
+	}
 
 	cert, err := keyToCertificate(privKey, config.CertTemplate)
 	if err != nil {
 		return nil, err
 	}
 	return &Identity{
+		certVerifier: config.CertVerifier,
 		config: tls.Config{
 			MinVersion:         tls.VersionTLS13,
 			InsecureSkipVerify: true, // This is not insecure here. We will verify the cert chain ourselves.
@@ -131,6 +167,11 @@ func (i *Identity) ConfigForPeer(remote peer.ID) (*tls.Config, <-chan ic.PubKey)
 			}
 			return fmt.Errorf("peer IDs don't match: expected %s, got %s", remote, peerID)
 		}
+		if i.certVerifier != nil {
+			if err := i.certVerifier(chain[0], pubKey); err != nil {
+				return fmt.Errorf("certificate rejected: %w", err)
+			}
+		}
 		keyCh <- pubKey
 		return nil
 	}
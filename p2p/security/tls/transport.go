@@ -35,8 +35,9 @@ type Transport struct {
 
 var _ sec.SecureTransport = &Transport{}
 
-// New creates a TLS encrypted transport
-func New(id protocol.ID, key ci.PrivKey, muxers []tptu.StreamMuxer) (*Transport, error) {
+// New creates a TLS encrypted transport.
+// The options are applied to the transport's Identity.
+func New(id protocol.ID, key ci.PrivKey, muxers []tptu.StreamMuxer, opts ...IdentityOption) (*Transport, error) {
 	localPeer, err := peer.IDFromPrivateKey(key)
 	if err != nil {
 		return nil, err
@@ -52,7 +53,7 @@ func New(id protocol.ID, key ci.PrivKey, muxers []tptu.StreamMuxer) (*Transport,
 		muxers:     muxerIDs,
 	}
 
-	identity, err := NewIdentity(key)
+	identity, err := NewIdentity(key, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -155,7 +156,8 @@ func (t *Transport) setupConn(tlsConn *tls.Conn, remotePubKey ci.PubKey) (sec.Se
 		return nil, err
 	}
 
-	nextProto := tlsConn.ConnectionState().NegotiatedProtocol
+	state := tlsConn.ConnectionState()
+	nextProto := state.NegotiatedProtocol
 	// The special ALPN extension value "libp2p" is used by libp2p versions
 	// that don't support early muxer negotiation. If we see this sepcial
 	// value selected, that means we are handshaking with a version that does
@@ -174,6 +176,8 @@ func (t *Transport) setupConn(tlsConn *tls.Conn, remotePubKey ci.PubKey) (sec.Se
 		connectionState: network.ConnectionState{
 			StreamMultiplexer:         protocol.ID(nextProto),
 			UsedEarlyMuxerNegotiation: nextProto != "",
+			CipherSuite:               tls.CipherSuiteName(state.CipherSuite),
+			Resumed:                   state.DidResume,
 		},
 	}, nil
 }
@@ -11,7 +11,9 @@ import (
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/asn1"
+	"errors"
 	"fmt"
+	"io"
 	"math/big"
 	mrand "math/rand"
 	"net"
@@ -177,6 +179,106 @@ func TestHandshakeSucceeds(t *testing.T) {
 	})
 }
 
+func TestHandshakeExtendedKeyTypes(t *testing.T) {
+	require.NoError(t, ic.EnableExperimentalKeyTypes(1000, 1001))
+	for _, typ := range []int{ic.Ed448, ic.BLS12381} {
+		t.Run(fmt.Sprint(typ), func(t *testing.T) {
+			clientKey, _, err := ic.GenerateKeyPair(typ, 0)
+			require.NoError(t, err)
+			serverKey, _, err := ic.GenerateKeyPair(typ, 0)
+			require.NoError(t, err)
+			serverID, err := peer.IDFromPrivateKey(serverKey)
+			require.NoError(t, err)
+			clientTransport, err := New(ID, clientKey, nil)
+			require.NoError(t, err)
+			serverTransport, err := New(ID, serverKey, nil)
+			require.NoError(t, err)
+
+			clientInsecureConn, serverInsecureConn := connect(t)
+			serverConnChan := make(chan sec.SecureConn, 1)
+			go func() {
+				serverConn, err := serverTransport.SecureInbound(context.Background(), serverInsecureConn, "")
+				assert.NoError(t, err)
+				serverConnChan <- serverConn
+			}()
+
+			clientConn, err := clientTransport.SecureOutbound(context.Background(), clientInsecureConn, serverID)
+			require.NoError(t, err)
+			defer clientConn.Close()
+			serverConn := <-serverConnChan
+			require.NotNil(t, serverConn)
+			defer serverConn.Close()
+
+			require.True(t, clientConn.RemotePublicKey().Equals(serverKey.GetPublic()), "server public key mismatch")
+			require.True(t, serverConn.RemotePublicKey().Equals(clientKey.GetPublic()), "client public key mismatch")
+		})
+	}
+}
+
+func TestHandshakeCustomExtensions(t *testing.T) {
+	_, clientKey := createPeer(t)
+	serverID, serverKey := createPeer(t)
+
+	attestationID := asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 99999, 1}
+	attestation := pkix.Extension{Id: attestationID, Value: []byte("attested")}
+
+	verifyAttestation := func(cert *x509.Certificate, _ ic.PubKey) error {
+		for _, ext := range cert.Extensions {
+			if ext.Id.Equal(attestationID) && string(ext.Value) == "attested" {
+				return nil
+			}
+		}
+		return errors.New("missing attestation")
+	}
+
+	handshake := func(t *testing.T, clientTransport, serverTransport *Transport) (clientErr, serverErr error) {
+		clientInsecureConn, serverInsecureConn := connect(t)
+		serverErrChan := make(chan error, 1)
+		go func() {
+			serverConn, err := serverTransport.SecureInbound(context.Background(), serverInsecureConn, "")
+			if err == nil {
+				serverConn.Close()
+			}
+			serverErrChan <- err
+		}()
+		clientConn, err := clientTransport.SecureOutbound(context.Background(), clientInsecureConn, serverID)
+		if err == nil {
+			// the server verifies the client's certificate after the client completed the handshake
+			_, err = clientConn.Read([]byte{0})
+			if err == io.EOF {
+				err = nil
+			}
+			clientConn.Close()
+		}
+		return err, <-serverErrChan
+	}
+
+	t.Run("extension present", func(t *testing.T) {
+		clientTransport, err := New(ID, clientKey, nil, WithCertExtensions(attestation))
+		require.NoError(t, err)
+		serverTransport, err := New(ID, serverKey, nil, WithCertExtensions(attestation), WithCertVerifier(verifyAttestation))
+		require.NoError(t, err)
+		clientErr, serverErr := handshake(t, clientTransport, serverTransport)
+		require.NoError(t, clientErr)
+		require.NoError(t, serverErr)
+	})
+
+	t.Run("extension missing", func(t *testing.T) {
+		clientTransport, err := New(ID, clientKey, nil)
+		require.NoError(t, err)
+		serverTransport, err := New(ID, serverKey, nil, WithCertVerifier(verifyAttestation))
+		require.NoError(t, err)
+		clientErr, serverErr := handshake(t, clientTransport, serverTransport)
+		require.Error(t, clientErr)
+		require.ErrorContains(t, serverErr, "missing attestation")
+	})
+
+	t.Run("conflicting extension", func(t *testing.T) {
+		_, err := New(ID, clientKey, nil, WithCertExtensions(pkix.Extension{Id: extensionID}))
+		require.Error(t, err)
+	})
+}
+
 type testcase struct {
 	clientProtos   []protocol.ID
 	serverProtos   []protocol.ID
@@ -257,6 +359,9 @@ func TestHandshakeWithNextProtoSucceeds(t *testing.T) {
 		require.True(t, serverConn.RemotePublicKey().Equals(clientKey.GetPublic()), "client public key mismatch")
 		require.Equal(t, clientConn.ConnState().StreamMultiplexer, expectedMuxer)
 		require.Equal(t, clientConn.ConnState().UsedEarlyMuxerNegotiation, expectedMuxer != "")
+		require.NotEmpty(t, clientConn.ConnState().CipherSuite)
+		require.Equal(t, clientConn.ConnState().CipherSuite, serverConn.ConnState().CipherSuite)
+		require.False(t, clientConn.ConnState().Resumed)
 		// exchange some data
 		_, err = serverConn.Write([]byte("foobar"))
 		require.NoError(t, err)
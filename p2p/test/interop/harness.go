@@ -0,0 +1,167 @@
+// Package interop is a test harness running a standard matrix of scenarios (connecting,
+// opening streams and transferring data) between hosts using every combination of
+// transport, security protocol and stream muxer, so that changes to any of them are
+// validated against all the others. The matrix is run by `go test`, see RunMatrix.
+//
+// Runs are deterministic: the host identities and the transferred data are derived
+// from fixed seeds, and the hosts only listen on the loopback interface.
+package interop
+
+import (
+	"fmt"
+	mrand "math/rand"
+	"testing"
+	"time"
+
+	"github.com/AstaFrode/go-libp2p"
+	"github.com/AstaFrode/go-libp2p/core/crypto"
+	"github.com/AstaFrode/go-libp2p/core/host"
+	"github.com/AstaFrode/go-libp2p/p2p/muxer/mplex"
+	"github.com/AstaFrode/go-libp2p/p2p/muxer/yamux"
+	"github.com/AstaFrode/go-libp2p/p2p/security/noise"
+	tls "github.com/AstaFrode/go-libp2p/p2p/security/tls"
+	"github.com/AstaFrode/go-libp2p/p2p/transport/memory"
+	quic "github.com/AstaFrode/go-libp2p/p2p/transport/quic"
+	"github.com/AstaFrode/go-libp2p/p2p/transport/tcp"
+	"github.com/AstaFrode/go-libp2p/p2p/transport/websocket"
+	webtransport "github.com/AstaFrode/go-libp2p/p2p/transport/webtransport"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Transport is a transport under test.
+type Transport struct {
+	Name string
+	// Option adds the transport to a host.
+	Option libp2p.Option
+	// ListenAddr is the address the listening host listens on.
+	ListenAddr string
+	// Builtin is set for transports that come with their own security protocol and
+	// stream muxer, like QUIC. They are only tested once, and not with every security
+	// protocol and stream muxer.
+	Builtin bool
+}
+
+// Security is a security protocol under test.
+type Security struct {
+	Name   string
+	ID     string
+	Option libp2p.Option
+}
+
+// Muxer is a stream muxer under test.
+type Muxer struct {
+	Name   string
+	ID     string
+	Option libp2p.Option
+}
+
+// Transports are the transports under test.
+var Transports = []Transport{
+	{Name: "tcp", Option: libp2p.Transport(tcp.NewTCPTransport), ListenAddr: "/ip4/127.0.0.1/tcp/0"},
+	{Name: "websocket", Option: libp2p.Transport(websocket.New), ListenAddr: "/ip4/127.0.0.1/tcp/0/ws"},
+	{Name: "memory", Option: libp2p.Transport(memory.NewTransport), ListenAddr: "/memory/0"},
+	{Name: "quic-v1", Option: libp2p.Transport(quic.NewTransport), ListenAddr: "/ip4/127.0.0.1/udp/0/quic-v1", Builtin: true},
+	{Name: "webtransport", Option: libp2p.Transport(webtransport.New), ListenAddr: "/ip4/127.0.0.1/udp/0/quic-v1/webtransport", Builtin: true},
+}
+
+// SecurityProtocols are the security protocols under test.
+var SecurityProtocols = []Security{
+	{Name: "noise", ID: noise.ID, Option: libp2p.Security(noise.ID, noise.New)},
+	{Name: "tls", ID: tls.ID, Option: libp2p.Security(tls.ID, tls.New)},
+}
+
+// Muxers are the stream muxers under test.
+var Muxers = []Muxer{
+	{Name: "yamux", ID: yamux.ID, Option: libp2p.Muxer(yamux.ID, yamux.DefaultTransport)},
+	{Name: "mplex", ID: mplex.ID, Option: libp2p.Muxer(mplex.ID, mplex.DefaultTransport)},
+}
+
+// A Combination of a transport, a security protocol and a stream muxer.
+// Security and Muxer are nil for builtin transports.
+type Combination struct {
+	Transport Transport
+	Security  *Security
+	Muxer     *Muxer
+}
+
+func (c Combination) Name() string {
+	if c.Transport.Builtin {
+		return c.Transport.Name
+	}
+	return fmt.Sprintf("%s-%s-%s", c.Transport.Name, c.Security.Name, c.Muxer.Name)
+}
+
+// Combinations returns all combinations of the transports, security protocols and
+// stream muxers under test.
+func Combinations() []Combination {
+	var combinations []Combination
+	for _, tpt := range Transports {
+		if tpt.Builtin {
+			combinations = append(combinations, Combination{Transport: tpt})
+			continue
+		}
+		for i := range SecurityProtocols {
+			for j := range Muxers {
+				combinations = append(combinations, Combination{
+					Transport: tpt,
+					Security:  &SecurityProtocols[i],
+					Muxer:     &Muxers[j],
+				})
+			}
+		}
+	}
+	return combinations
+}
+
+// NewHost creates a host using the combination. The identity of the host is derived
+// from seed. If listen is set, the host listens on the transport's listen address.
+// The host is closed when the test completes.
+func (c Combination) NewHost(t testing.TB, seed int64, listen bool) host.Host {
+	t.Helper()
+	priv, _, err := crypto.GenerateEd25519Key(mrand.New(mrand.NewSource(seed)))
+	require.NoError(t, err)
+
+	opts := []libp2p.Option{
+		libp2p.Identity(priv),
+		c.Transport.Option,
+		libp2p.DisableRelay(),
+	}
+	if c.Security != nil {
+		opts = append(opts, c.Security.Option)
+	}
+	if c.Muxer != nil {
+		opts = append(opts, c.Muxer.Option)
+	}
+	if listen {
+		opts = append(opts, libp2p.ListenAddrStrings(c.Transport.ListenAddr))
+	} else {
+		opts = append(opts, libp2p.NoListenAddrs)
+	}
+	h, err := libp2p.New(opts...)
+	require.NoError(t, err)
+	t.Cleanup(func() { h.Close() })
+	return h
+}
+
+// RunMatrix runs the scenarios for every combination, each on a fresh pair of hosts,
+// and fails the test if a scenario takes longer than its MaxDuration.
+func RunMatrix(t *testing.T, scenarios ...Scenario) {
+	for _, c := range Combinations() {
+		c := c
+		t.Run(c.Name(), func(t *testing.T) {
+			for i, s := range scenarios {
+				s := s
+				seed := int64(2 * i)
+				t.Run(s.Name, func(t *testing.T) {
+					listener := c.NewHost(t, seed, true)
+					dialer := c.NewHost(t, seed+1, false)
+
+					start := time.Now()
+					s.Run(t, c, dialer, listener)
+					require.Less(t, time.Since(start), s.MaxDuration, "scenario took too long")
+				})
+			}
+		})
+	}
+}
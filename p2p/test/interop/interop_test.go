@@ -0,0 +1,7 @@
+package interop
+
+import "testing"
+
+func TestInterop(t *testing.T) {
+	RunMatrix(t, Scenarios...)
+}
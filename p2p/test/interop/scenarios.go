@@ -0,0 +1,142 @@
+package interop
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	mrand "math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AstaFrode/go-libp2p/core/host"
+	"github.com/AstaFrode/go-libp2p/core/network"
+	"github.com/AstaFrode/go-libp2p/core/peer"
+	"github.com/AstaFrode/go-libp2p/core/protocol"
+
+	"github.com/stretchr/testify/require"
+)
+
+// EchoProtocol is the protocol used by the scenarios. The listener echoes back
+// everything it reads.
+const EchoProtocol protocol.ID = "/libp2p/interop/echo/1.0.0"
+
+// A Scenario is run between a dialing and a listening host.
+type Scenario struct {
+	Name string
+	// MaxDuration is the time the scenario is allowed to take on the loopback interface.
+	MaxDuration time.Duration
+	Run         func(t *testing.T, c Combination, dialer, listener host.Host)
+}
+
+// Scenarios are the standard scenarios.
+var Scenarios = []Scenario{
+	{Name: "connect", MaxDuration: 5 * time.Second, Run: runConnect},
+	{Name: "streams", MaxDuration: 10 * time.Second, Run: runStreams},
+	{Name: "transfer", MaxDuration: 15 * time.Second, Run: runTransfer},
+}
+
+const (
+	numStreams     = 50
+	streamDataSize = 1 << 10
+	transferSize   = 8 << 20
+)
+
+// Connect connects dialer to listener, and checks that the connection uses the
+// security protocol and stream muxer of the combination.
+func Connect(t *testing.T, c Combination, dialer, listener host.Host) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, dialer.Connect(ctx, peer.AddrInfo{ID: listener.ID(), Addrs: listener.Addrs()}))
+
+	conns := dialer.Network().ConnsToPeer(listener.ID())
+	require.Len(t, conns, 1)
+	state := conns[0].ConnState()
+	if c.Security != nil {
+		require.Equal(t, protocol.ID(c.Security.ID), state.Security)
+	}
+	if c.Muxer != nil {
+		require.Equal(t, protocol.ID(c.Muxer.ID), state.StreamMultiplexer)
+	}
+}
+
+func setEchoHandler(h host.Host) {
+	h.SetStreamHandler(EchoProtocol, func(s network.Stream) {
+		defer s.Close()
+		if _, err := io.Copy(s, s); err != nil {
+			s.Reset()
+		}
+	})
+}
+
+// echo sends data on a new stream to listener, and checks that it is echoed back.
+func echo(dialer, listener host.Host, data []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	s, err := dialer.NewStream(ctx, listener.ID(), EchoProtocol)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	// write concurrently, so that flow control doesn't block the echo
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := s.Write(data)
+		if err == nil {
+			err = s.CloseWrite()
+		}
+		errCh <- err
+	}()
+	received, err := io.ReadAll(s)
+	if err != nil {
+		s.Reset()
+		<-errCh
+		return err
+	}
+	if err := <-errCh; err != nil {
+		return err
+	}
+	if !bytes.Equal(data, received) {
+		return errors.New("received data doesn't match")
+	}
+	return nil
+}
+
+func runConnect(t *testing.T, c Combination, dialer, listener host.Host) {
+	Connect(t, c, dialer, listener)
+}
+
+func runStreams(t *testing.T, c Combination, dialer, listener host.Host) {
+	setEchoHandler(listener)
+	Connect(t, c, dialer, listener)
+
+	rng := mrand.New(mrand.NewSource(1))
+	var wg sync.WaitGroup
+	errs := make(chan error, numStreams)
+	for i := 0; i < numStreams; i++ {
+		data := make([]byte, streamDataSize)
+		rng.Read(data)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- echo(dialer, listener, data)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+}
+
+func runTransfer(t *testing.T, c Combination, dialer, listener host.Host) {
+	setEchoHandler(listener)
+	Connect(t, c, dialer, listener)
+
+	data := make([]byte, transferSize)
+	mrand.New(mrand.NewSource(2)).Read(data)
+	require.NoError(t, echo(dialer, listener, data))
+}
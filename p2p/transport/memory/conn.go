@@ -0,0 +1,233 @@
+package memory
+
+import (
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+// maxBufferSize is the amount of data buffered in each direction of a connection.
+// Writes block while the buffer is full.
+const maxBufferSize = 256 << 10
+
+type addr string
+
+func (a addr) Network() string { return "memory" }
+func (a addr) String() string  { return string(a) }
+
+// pipe is one direction of a connection.
+type pipe struct {
+	mx          sync.Mutex
+	buf         []byte
+	writeClosed bool // the writer closed the connection
+	readClosed  bool // the reader closed the connection
+
+	readable chan struct{}
+	writable chan struct{}
+}
+
+func newPipe() *pipe {
+	return &pipe{
+		readable: make(chan struct{}, 1),
+		writable: make(chan struct{}, 1),
+	}
+}
+
+func signal(c chan struct{}) {
+	select {
+	case c <- struct{}{}:
+	default:
+	}
+}
+
+type conn struct {
+	in, out      *pipe
+	laddr, raddr ma.Multiaddr
+	closeOnce    sync.Once
+
+	readDeadline, writeDeadline *deadline
+}
+
+var _ manet.Conn = &conn{}
+
+func newConnPair(laddr, raddr ma.Multiaddr) (*conn, *conn) {
+	p1, p2 := newPipe(), newPipe()
+	c1 := &conn{in: p1, out: p2, laddr: laddr, raddr: raddr, readDeadline: newDeadline(), writeDeadline: newDeadline()}
+	c2 := &conn{in: p2, out: p1, laddr: raddr, raddr: laddr, readDeadline: newDeadline(), writeDeadline: newDeadline()}
+	return c1, c2
+}
+
+func (c *conn) Read(b []byte) (int, error) {
+	for {
+		expired := c.readDeadline.wait()
+		if isClosedChan(expired) {
+			return 0, os.ErrDeadlineExceeded
+		}
+		c.in.mx.Lock()
+		if c.in.readClosed {
+			c.in.mx.Unlock()
+			return 0, net.ErrClosed
+		}
+		if len(c.in.buf) > 0 {
+			n := copy(b, c.in.buf)
+			c.in.buf = c.in.buf[n:]
+			c.in.mx.Unlock()
+			signal(c.in.writable)
+			return n, nil
+		}
+		if c.in.writeClosed {
+			c.in.mx.Unlock()
+			return 0, io.EOF
+		}
+		c.in.mx.Unlock()
+
+		select {
+		case <-c.in.readable:
+		case <-expired:
+			return 0, os.ErrDeadlineExceeded
+		}
+	}
+}
+
+func (c *conn) Write(b []byte) (int, error) {
+	var written int
+	for {
+		expired := c.writeDeadline.wait()
+		if isClosedChan(expired) {
+			return written, os.ErrDeadlineExceeded
+		}
+		c.out.mx.Lock()
+		if c.out.writeClosed {
+			c.out.mx.Unlock()
+			return written, net.ErrClosed
+		}
+		if c.out.readClosed {
+			c.out.mx.Unlock()
+			return written, io.ErrClosedPipe
+		}
+		if n := maxBufferSize - len(c.out.buf); n > 0 {
+			if n > len(b) {
+				n = len(b)
+			}
+			c.out.buf = append(c.out.buf, b[:n]...)
+			b = b[n:]
+			written += n
+			c.out.mx.Unlock()
+			signal(c.out.readable)
+			if len(b) == 0 {
+				return written, nil
+			}
+			continue
+		}
+		c.out.mx.Unlock()
+
+		select {
+		case <-c.out.writable:
+		case <-expired:
+			return written, os.ErrDeadlineExceeded
+		}
+	}
+}
+
+// Close closes both directions of the connection.
+// The remote peer reads the data written so far, followed by an io.EOF.
+func (c *conn) Close() error {
+	c.closeOnce.Do(func() {
+		c.out.mx.Lock()
+		c.out.writeClosed = true
+		c.out.mx.Unlock()
+		signal(c.out.readable)
+		signal(c.out.writable)
+
+		c.in.mx.Lock()
+		c.in.readClosed = true
+		c.in.buf = nil
+		c.in.mx.Unlock()
+		signal(c.in.readable)
+		signal(c.in.writable)
+	})
+	return nil
+}
+
+func (c *conn) LocalAddr() net.Addr           { return addr(c.laddr.String()) }
+func (c *conn) RemoteAddr() net.Addr          { return addr(c.raddr.String()) }
+func (c *conn) LocalMultiaddr() ma.Multiaddr  { return c.laddr }
+func (c *conn) RemoteMultiaddr() ma.Multiaddr { return c.raddr }
+
+func (c *conn) SetDeadline(t time.Time) error {
+	c.readDeadline.set(t)
+	c.writeDeadline.set(t)
+	return nil
+}
+
+func (c *conn) SetReadDeadline(t time.Time) error {
+	c.readDeadline.set(t)
+	return nil
+}
+
+func (c *conn) SetWriteDeadline(t time.Time) error {
+	c.writeDeadline.set(t)
+	return nil
+}
+
+// deadline is a deadline, signaled by closing a channel.
+// This is the same mechanism as the one used by net.Pipe.
+type deadline struct {
+	mx      sync.Mutex
+	timer   *time.Timer
+	expired chan struct{}
+}
+
+func newDeadline() *deadline {
+	return &deadline{expired: make(chan struct{})}
+}
+
+func (d *deadline) set(t time.Time) {
+	d.mx.Lock()
+	defer d.mx.Unlock()
+
+	if d.timer != nil && !d.timer.Stop() {
+		<-d.expired // wait for the timer callback to finish and close the channel
+	}
+	d.timer = nil
+
+	closed := isClosedChan(d.expired)
+	if t.IsZero() {
+		if closed {
+			d.expired = make(chan struct{})
+		}
+		return
+	}
+	if dur := time.Until(t); dur > 0 {
+		if closed {
+			d.expired = make(chan struct{})
+		}
+		expired := d.expired
+		d.timer = time.AfterFunc(dur, func() { close(expired) })
+		return
+	}
+	if !closed {
+		close(d.expired)
+	}
+}
+
+// wait returns a channel that is closed when the deadline expires.
+func (d *deadline) wait() chan struct{} {
+	d.mx.Lock()
+	defer d.mx.Unlock()
+	return d.expired
+}
+
+func isClosedChan(c <-chan struct{}) bool {
+	select {
+	case <-c:
+		return true
+	default:
+		return false
+	}
+}
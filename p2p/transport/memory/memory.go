@@ -0,0 +1,187 @@
+// Package memory implements a transport connecting hosts running in the same process.
+//
+// Listen addresses have the form /memory/<id>. Listening on /memory/0 picks an unused id.
+// Connections go through the upgrader, like TCP connections do: they are secured and
+// multiplexed, and accounted for in the resource manager. The data never leaves the
+// process, which makes it possible to run many hosts in tests without using loopback
+// sockets, or to embed a client and a server in the same binary.
+package memory
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"strconv"
+	"sync"
+
+	"github.com/AstaFrode/go-libp2p/core/network"
+	"github.com/AstaFrode/go-libp2p/core/peer"
+	"github.com/AstaFrode/go-libp2p/core/transport"
+
+	logging "github.com/ipfs/go-log/v2"
+	ma "github.com/multiformats/go-multiaddr"
+	mafmt "github.com/multiformats/go-multiaddr-fmt"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+var log = logging.Logger("memory-tpt")
+
+// listeners are all the memory listeners of the process, by id.
+var listeners = struct {
+	sync.Mutex
+	m map[uint64]*listener
+}{m: make(map[uint64]*listener)}
+
+var dialMatcher = mafmt.Base(P_MEMORY)
+
+// Transport is the memory transport.
+type Transport struct {
+	upgrader transport.Upgrader
+	rcmgr    network.ResourceManager
+}
+
+var _ transport.Transport = &Transport{}
+
+// NewTransport creates a memory transport.
+func NewTransport(upgrader transport.Upgrader, rcmgr network.ResourceManager) (*Transport, error) {
+	if rcmgr == nil {
+		rcmgr = &network.NullResourceManager{}
+	}
+	return &Transport{upgrader: upgrader, rcmgr: rcmgr}, nil
+}
+
+func memoryID(addr ma.Multiaddr) (uint64, error) {
+	if !dialMatcher.Matches(addr) {
+		return 0, fmt.Errorf("not a memory address: %s", addr)
+	}
+	s, err := addr.ValueForProtocol(P_MEMORY)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(s, 10, 64)
+}
+
+func memoryAddr(id uint64) ma.Multiaddr {
+	return ma.StringCast("/memory/" + strconv.FormatUint(id, 10))
+}
+
+// CanDial returns true if the address is a memory address.
+func (t *Transport) CanDial(addr ma.Multiaddr) bool {
+	return dialMatcher.Matches(addr)
+}
+
+// Dial dials the peer at the remote address.
+func (t *Transport) Dial(ctx context.Context, raddr ma.Multiaddr, p peer.ID) (transport.CapableConn, error) {
+	id, err := memoryID(raddr)
+	if err != nil {
+		return nil, err
+	}
+	connScope, err := t.rcmgr.OpenConnection(network.DirOutbound, false, raddr)
+	if err != nil {
+		log.Debugw("resource manager blocked outgoing connection", "peer", p, "addr", raddr, "error", err)
+		return nil, err
+	}
+	c, err := t.dial(ctx, id, raddr)
+	if err != nil {
+		connScope.Done()
+		return nil, err
+	}
+	return t.upgrader.Upgrade(ctx, t, c, network.DirOutbound, p, connScope)
+}
+
+func (t *Transport) dial(ctx context.Context, id uint64, raddr ma.Multiaddr) (manet.Conn, error) {
+	listeners.Lock()
+	l, ok := listeners.m[id]
+	listeners.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("dial %s: connection refused", raddr)
+	}
+	// The local address is not used for anything, it just needs to be different
+	// from the addresses of other connections.
+	local, remote := newConnPair(memoryAddr(rand.Uint64()), raddr)
+	select {
+	case l.incoming <- remote:
+		return local, nil
+	case <-l.closed:
+		return nil, fmt.Errorf("dial %s: connection refused", raddr)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Listen listens on the memory address. Listening on /memory/0 picks an unused id.
+func (t *Transport) Listen(laddr ma.Multiaddr) (transport.Listener, error) {
+	id, err := memoryID(laddr)
+	if err != nil {
+		return nil, err
+	}
+	listeners.Lock()
+	defer listeners.Unlock()
+	if id == 0 {
+		for id == 0 || listeners.m[id] != nil {
+			id = rand.Uint64()
+		}
+	} else if _, ok := listeners.m[id]; ok {
+		return nil, fmt.Errorf("listen %s: address already in use", laddr)
+	}
+	l := &listener{
+		id:       id,
+		addr:     memoryAddr(id),
+		incoming: make(chan *conn),
+		closed:   make(chan struct{}),
+	}
+	listeners.m[id] = l
+	return t.upgrader.UpgradeListener(t, l), nil
+}
+
+// Protocols returns the list of terminal protocols this transport can dial.
+func (t *Transport) Protocols() []int {
+	return []int{P_MEMORY}
+}
+
+// Proxy always returns false for the memory transport.
+func (t *Transport) Proxy() bool {
+	return false
+}
+
+func (t *Transport) String() string {
+	return "MemoryTransport"
+}
+
+type listener struct {
+	id        uint64
+	addr      ma.Multiaddr
+	incoming  chan *conn
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+var _ manet.Listener = &listener{}
+
+func (l *listener) Accept() (manet.Conn, error) {
+	select {
+	case c := <-l.incoming:
+		return c, nil
+	case <-l.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *listener) Close() error {
+	l.closeOnce.Do(func() {
+		listeners.Lock()
+		delete(listeners.m, l.id)
+		listeners.Unlock()
+		close(l.closed)
+	})
+	return nil
+}
+
+func (l *listener) Addr() net.Addr {
+	return addr(l.addr.String())
+}
+
+func (l *listener) Multiaddr() ma.Multiaddr {
+	return l.addr
+}
@@ -0,0 +1,129 @@
+package memory
+
+import (
+	"context"
+	"crypto/rand"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/AstaFrode/go-libp2p/core/crypto"
+	"github.com/AstaFrode/go-libp2p/core/network"
+	"github.com/AstaFrode/go-libp2p/core/peer"
+	"github.com/AstaFrode/go-libp2p/core/sec"
+	"github.com/AstaFrode/go-libp2p/core/sec/insecure"
+	"github.com/AstaFrode/go-libp2p/core/transport"
+	"github.com/AstaFrode/go-libp2p/p2p/muxer/yamux"
+	tptu "github.com/AstaFrode/go-libp2p/p2p/net/upgrader"
+
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/require"
+)
+
+func newTransport(t *testing.T) (peer.ID, *Transport) {
+	t.Helper()
+	priv, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	require.NoError(t, err)
+	id, err := peer.IDFromPrivateKey(priv)
+	require.NoError(t, err)
+	u, err := tptu.New([]sec.SecureTransport{insecure.NewWithIdentity(insecure.ID, id, priv)}, []tptu.StreamMuxer{{ID: yamux.ID, Muxer: yamux.DefaultTransport}}, nil, nil, nil)
+	require.NoError(t, err)
+	tr, err := NewTransport(u, nil)
+	require.NoError(t, err)
+	return id, tr
+}
+
+func TestMultiaddr(t *testing.T) {
+	addr, err := ma.NewMultiaddr("/memory/1234")
+	require.NoError(t, err)
+	require.Equal(t, "/memory/1234", addr.String())
+	_, err = ma.NewMultiaddr("/memory/foo")
+	require.Error(t, err)
+}
+
+func TestDialListen(t *testing.T) {
+	serverID, server := newTransport(t)
+	_, client := newTransport(t)
+
+	ln, err := server.Listen(ma.StringCast("/memory/0"))
+	require.NoError(t, err)
+	defer ln.Close()
+	require.True(t, client.CanDial(ln.Multiaddr()))
+	require.NotEqual(t, "/memory/0", ln.Multiaddr().String())
+	require.False(t, client.CanDial(ma.StringCast("/ip4/127.0.0.1/tcp/1234")))
+
+	_, err = server.Listen(ln.Multiaddr())
+	require.Error(t, err)
+
+	accepted := make(chan transport.CapableConn, 1)
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		accepted <- c
+		str, err := c.AcceptStream()
+		if err != nil {
+			return
+		}
+		defer str.Close()
+		io.Copy(str, str)
+	}()
+
+	c, err := client.Dial(context.Background(), ln.Multiaddr(), serverID)
+	require.NoError(t, err)
+	defer c.Close()
+	require.Equal(t, serverID, c.RemotePeer())
+	str, err := c.OpenStream(context.Background())
+	require.NoError(t, err)
+	// more than the buffer size
+	data := make([]byte, 3*maxBufferSize/2)
+	rand.Read(data)
+	go func() {
+		str.Write(data)
+		str.CloseWrite()
+	}()
+	echoed, err := io.ReadAll(str)
+	require.NoError(t, err)
+	require.Equal(t, data, echoed)
+	(<-accepted).Close()
+
+	ln.Close()
+	_, err = client.Dial(context.Background(), ln.Multiaddr(), serverID)
+	require.Error(t, err)
+}
+
+func TestConnDeadline(t *testing.T) {
+	c1, c2 := newConnPair(memoryAddr(1), memoryAddr(2))
+	defer c1.Close()
+	defer c2.Close()
+
+	require.NoError(t, c1.SetReadDeadline(time.Now().Add(50*time.Millisecond)))
+	_, err := c1.Read(make([]byte, 1))
+	require.ErrorIs(t, err, os.ErrDeadlineExceeded)
+
+	require.NoError(t, c1.SetReadDeadline(time.Time{}))
+	_, err = c2.Write([]byte("foo"))
+	require.NoError(t, err)
+	b := make([]byte, 3)
+	_, err = io.ReadFull(c1, b)
+	require.NoError(t, err)
+	require.Equal(t, "foo", string(b))
+
+	// writes block once the buffer is full
+	require.NoError(t, c2.SetWriteDeadline(time.Now().Add(50*time.Millisecond)))
+	n, err := c2.Write(make([]byte, 2*maxBufferSize))
+	require.ErrorIs(t, err, os.ErrDeadlineExceeded)
+	require.Equal(t, maxBufferSize, n)
+
+	c2.Close()
+	_, err = io.ReadFull(c1, make([]byte, maxBufferSize))
+	require.NoError(t, err)
+	_, err = c1.Read(b)
+	require.Equal(t, io.EOF, err)
+	_, err = c1.Write(b)
+	require.Error(t, err)
+}
+
+var _ network.ConnMultiaddrs = &conn{}
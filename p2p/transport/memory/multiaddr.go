@@ -0,0 +1,53 @@
+package memory
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// P_MEMORY is the multiaddr code of the memory protocol: /memory/<id>,
+// where id is an unsigned 64 bit integer.
+const P_MEMORY = 777
+
+func init() {
+	if ma.ProtocolWithCode(P_MEMORY).Code != 0 {
+		// already registered by go-multiaddr
+		return
+	}
+	if err := ma.AddProtocol(ma.Protocol{
+		Name:       "memory",
+		Code:       P_MEMORY,
+		VCode:      ma.CodeToVarint(P_MEMORY),
+		Size:       64,
+		Transcoder: ma.NewTranscoderFromFunctions(memoryStB, memoryBtS, memoryValidate),
+	}); err != nil {
+		panic(err)
+	}
+}
+
+func memoryStB(s string) ([]byte, error) {
+	id, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid memory id %q: %w", s, err)
+	}
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, id)
+	return b, nil
+}
+
+func memoryBtS(b []byte) (string, error) {
+	if err := memoryValidate(b); err != nil {
+		return "", err
+	}
+	return strconv.FormatUint(binary.BigEndian.Uint64(b), 10), nil
+}
+
+func memoryValidate(b []byte) error {
+	if len(b) != 8 {
+		return fmt.Errorf("invalid length for memory id: %d", len(b))
+	}
+	return nil
+}
@@ -2,6 +2,8 @@ package libp2pquic
 
 import (
 	"context"
+	"crypto/tls"
+	"time"
 
 	ic "github.com/AstaFrode/go-libp2p/core/crypto"
 	"github.com/AstaFrode/go-libp2p/core/network"
@@ -24,6 +26,9 @@ type conn struct {
 	remotePeerID    peer.ID
 	remotePubKey    ic.PubKey
 	remoteMultiaddr ma.Multiaddr
+
+	// only known for dialed connections
+	handshakeDuration time.Duration
 }
 
 var _ tpt.CapableConn = &conn{}
@@ -35,6 +40,12 @@ func (c *conn) Close() error {
 	return c.closeWithError(0, "")
 }
 
+// CloseWithError closes the connection, sending errCode and reason to the remote peer
+// in the QUIC CONNECTION_CLOSE frame.
+func (c *conn) CloseWithError(errCode network.ConnErrorCode, reason string) error {
+	return c.closeWithError(quic.ApplicationErrorCode(errCode), reason)
+}
+
 func (c *conn) closeWithError(errCode quic.ApplicationErrorCode, errString string) error {
 	c.transport.removeConn(c.quicConn)
 	err := c.quicConn.CloseWithError(errCode, errString)
@@ -54,13 +65,13 @@ func (c *conn) allowWindowIncrease(size uint64) bool {
 // OpenStream creates a new stream.
 func (c *conn) OpenStream(ctx context.Context) (network.MuxedStream, error) {
 	qstr, err := c.quicConn.OpenStreamSync(ctx)
-	return &stream{Stream: qstr}, err
+	return &stream{Stream: qstr}, parseStreamError(err)
 }
 
 // AcceptStream accepts a stream opened by the other side.
 func (c *conn) AcceptStream() (network.MuxedStream, error) {
 	qstr, err := c.quicConn.AcceptStream(context.Background())
-	return &stream{Stream: qstr}, err
+	return &stream{Stream: qstr}, parseStreamError(err)
 }
 
 // LocalPeer returns our peer ID
@@ -91,5 +102,11 @@ func (c *conn) ConnState() network.ConnectionState {
 	if _, err := c.LocalMultiaddr().ValueForProtocol(ma.P_QUIC); err == nil {
 		t = "quic"
 	}
-	return network.ConnectionState{Transport: t}
+	tlsState := c.quicConn.ConnectionState().TLS
+	return network.ConnectionState{
+		Transport:         t,
+		CipherSuite:       tls.CipherSuiteName(tlsState.CipherSuite),
+		Resumed:           tlsState.DidResume || tlsState.Used0RTT,
+		HandshakeDuration: c.handshakeDuration,
+	}
 }
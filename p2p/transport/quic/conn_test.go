@@ -4,6 +4,9 @@ import (
 	"bytes"
 	"context"
 	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
 	"errors"
 	"fmt"
 	"io"
@@ -18,6 +21,7 @@ import (
 	mocknetwork "github.com/AstaFrode/go-libp2p/core/network/mocks"
 	"github.com/AstaFrode/go-libp2p/core/peer"
 	tpt "github.com/AstaFrode/go-libp2p/core/transport"
+	p2ptls "github.com/AstaFrode/go-libp2p/p2p/security/tls"
 	"github.com/AstaFrode/go-libp2p/p2p/transport/quicreuse"
 
 	"github.com/golang/mock/gomock"
@@ -111,6 +115,10 @@ func testHandshake(t *testing.T, tc *connTestCase) {
 		require.True(t, serverConn.LocalPrivateKey().Equals(serverKey), "local private key doesn't match")
 		require.Equal(t, serverConn.RemotePeer(), clientID)
 		require.True(t, serverConn.RemotePublicKey().Equals(clientKey.GetPublic()), "remote public key doesn't match")
+
+		require.NotEmpty(t, conn.ConnState().CipherSuite)
+		require.Equal(t, conn.ConnState().CipherSuite, serverConn.ConnState().CipherSuite)
+		require.Positive(t, conn.ConnState().HandshakeDuration)
 	}
 
 	t.Run("on IPv4", func(t *testing.T) {
@@ -307,6 +315,105 @@ func testStreams(t *testing.T, tc *connTestCase) {
 	require.Equal(t, data, []byte("foobar"))
 }
 
+func TestStreamResetWithError(t *testing.T) {
+	for _, tc := range connTestCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			testStreamResetWithError(t, tc)
+		})
+	}
+}
+
+func testStreamResetWithError(t *testing.T, tc *connTestCase) {
+	serverID, serverKey := createPeer(t)
+	_, clientKey := createPeer(t)
+
+	serverTransport, err := NewTransport(serverKey, newConnManager(t, tc.Options...), nil, nil, nil)
+	require.NoError(t, err)
+	defer serverTransport.(io.Closer).Close()
+	ln := runServer(t, serverTransport, "/ip4/127.0.0.1/udp/0/quic-v1")
+	defer ln.Close()
+
+	clientTransport, err := NewTransport(clientKey, newConnManager(t, tc.Options...), nil, nil, nil)
+	require.NoError(t, err)
+	defer clientTransport.(io.Closer).Close()
+	conn, err := clientTransport.Dial(context.Background(), ln.Multiaddr(), serverID)
+	require.NoError(t, err)
+	defer conn.Close()
+	serverConn, err := ln.Accept()
+	require.NoError(t, err)
+	defer serverConn.Close()
+
+	str, err := conn.OpenStream(context.Background())
+	require.NoError(t, err)
+	_, err = str.Write([]byte("foobar"))
+	require.NoError(t, err)
+	sstr, err := serverConn.AcceptStream()
+	require.NoError(t, err)
+	require.NoError(t, network.ResetWithError(str, 42))
+
+	_, err = io.ReadAll(sstr)
+	require.ErrorIs(t, err, &network.StreamError{ErrorCode: 42, Remote: true})
+	require.ErrorIs(t, err, network.ErrReset)
+	_, err = str.Read([]byte{0})
+	require.ErrorIs(t, err, &network.StreamError{ErrorCode: 42})
+
+	// a plain reset returns network.ErrReset itself
+	str, err = conn.OpenStream(context.Background())
+	require.NoError(t, err)
+	_, err = str.Write([]byte("foobar"))
+	require.NoError(t, err)
+	sstr, err = serverConn.AcceptStream()
+	require.NoError(t, err)
+	require.NoError(t, str.Reset())
+	_, err = io.ReadAll(sstr)
+	require.Equal(t, network.ErrReset, err)
+}
+
+func TestConnCloseWithError(t *testing.T) {
+	for _, tc := range connTestCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			testConnCloseWithError(t, tc)
+		})
+	}
+}
+
+func testConnCloseWithError(t *testing.T, tc *connTestCase) {
+	serverID, serverKey := createPeer(t)
+	_, clientKey := createPeer(t)
+
+	serverTransport, err := NewTransport(serverKey, newConnManager(t, tc.Options...), nil, nil, nil)
+	require.NoError(t, err)
+	defer serverTransport.(io.Closer).Close()
+	ln := runServer(t, serverTransport, "/ip4/127.0.0.1/udp/0/quic-v1")
+	defer ln.Close()
+
+	clientTransport, err := NewTransport(clientKey, newConnManager(t, tc.Options...), nil, nil, nil)
+	require.NoError(t, err)
+	defer clientTransport.(io.Closer).Close()
+	conn, err := clientTransport.Dial(context.Background(), ln.Multiaddr(), serverID)
+	require.NoError(t, err)
+	serverConn, err := ln.Accept()
+	require.NoError(t, err)
+	defer serverConn.Close()
+
+	str, err := conn.OpenStream(context.Background())
+	require.NoError(t, err)
+	_, err = str.Write([]byte("foobar"))
+	require.NoError(t, err)
+	sstr, err := serverConn.AcceptStream()
+	require.NoError(t, err)
+	require.NoError(t, network.CloseWithError(conn, 42, "going away"))
+
+	_, err = io.ReadAll(sstr)
+	var cerr *network.ConnError
+	require.ErrorAs(t, err, &cerr)
+	require.Equal(t, &network.ConnError{ErrorCode: 42, Reason: "going away", Remote: true}, cerr)
+	_, err = serverConn.AcceptStream()
+	require.ErrorIs(t, err, &network.ConnError{ErrorCode: 42, Remote: true})
+	_, err = str.Read([]byte{0})
+	require.ErrorIs(t, err, &network.ConnError{ErrorCode: 42})
+}
+
 func TestHandshakeFailPeerIDMismatch(t *testing.T) {
 	for _, tc := range connTestCases {
 		t.Run(tc.Name, func(t *testing.T) {
@@ -349,6 +456,46 @@ func testHandshakeFailPeerIDMismatch(t *testing.T, tc *connTestCase) {
 	require.Error(t, <-acceptErr)
 }
 
+func TestHandshakeFailCertVerifier(t *testing.T) {
+	for _, tc := range connTestCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			testHandshakeFailCertVerifier(t, tc)
+		})
+	}
+}
+
+func testHandshakeFailCertVerifier(t *testing.T, tc *connTestCase) {
+	serverID, serverKey := createPeer(t)
+	_, clientKey := createPeer(t)
+
+	extID := asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 53594, 1, 2}
+	serverTransport, err := NewTransport(serverKey, newConnManager(t, tc.Options...), nil, nil, nil,
+		WithIdentityOptions(p2ptls.WithCertExtensions(pkix.Extension{Id: extID, Value: []byte("attestation")})),
+	)
+	require.NoError(t, err)
+	defer serverTransport.(io.Closer).Close()
+	ln := runServer(t, serverTransport, "/ip4/127.0.0.1/udp/0/quic-v1")
+	defer ln.Close()
+
+	var sawExtension atomic.Bool
+	clientTransport, err := NewTransport(clientKey, newConnManager(t, tc.Options...), nil, nil, nil,
+		WithIdentityOptions(p2ptls.WithCertVerifier(func(cert *x509.Certificate, _ ic.PubKey) error {
+			for _, ext := range cert.Extensions {
+				if ext.Id.Equal(extID) {
+					sawExtension.Store(true)
+				}
+			}
+			return errors.New("rejected")
+		})),
+	)
+	require.NoError(t, err)
+	defer clientTransport.(io.Closer).Close()
+	_, err = clientTransport.Dial(context.Background(), ln.Multiaddr(), serverID)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "CRYPTO_ERROR")
+	require.True(t, sawExtension.Load())
+}
+
 func TestConnectionGating(t *testing.T) {
 	for _, tc := range connTestCases {
 		t.Run(tc.Name, func(t *testing.T) {
@@ -20,18 +20,12 @@ var _ network.MuxedStream = &stream{}
 
 func (s *stream) Read(b []byte) (n int, err error) {
 	n, err = s.Stream.Read(b)
-	if err != nil && errors.Is(err, &quic.StreamError{}) {
-		err = network.ErrReset
-	}
-	return n, err
+	return n, parseStreamError(err)
 }
 
 func (s *stream) Write(b []byte) (n int, err error) {
 	n, err = s.Stream.Write(b)
-	if err != nil && errors.Is(err, &quic.StreamError{}) {
-		err = network.ErrReset
-	}
-	return n, err
+	return n, parseStreamError(err)
 }
 
 func (s *stream) Reset() error {
@@ -40,6 +34,12 @@ func (s *stream) Reset() error {
 	return nil
 }
 
+func (s *stream) ResetWithError(errCode network.StreamErrorCode) error {
+	s.Stream.CancelRead(quic.StreamErrorCode(errCode))
+	s.Stream.CancelWrite(quic.StreamErrorCode(errCode))
+	return nil
+}
+
 func (s *stream) Close() error {
 	s.Stream.CancelRead(reset)
 	return s.Stream.Close()
@@ -53,3 +53,23 @@ func (s *stream) CloseRead() error {
 func (s *stream) CloseWrite() error {
 	return s.Stream.Close()
 }
+
+// parseStreamError converts the errors returned when the stream was reset to network.ErrReset, or to
+// a *network.StreamError if it was reset with an error code, and the errors returned when the connection was closed by the application to a *network.ConnError.
+func parseStreamError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var se *quic.StreamError
+	if errors.As(err, &se) {
+		if se.ErrorCode == reset {
+			return network.ErrReset
+		}
+		return &network.StreamError{ErrorCode: network.StreamErrorCode(se.ErrorCode), Remote: se.Remote}
+	}
+	var ae *quic.ApplicationError
+	if errors.As(err, &ae) {
+		return &network.ConnError{ErrorCode: network.ConnErrorCode(ae.ErrorCode), Reason: ae.ErrorMessage, Remote: ae.Remote}
+	}
+	return err
+}
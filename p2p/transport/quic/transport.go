@@ -34,14 +34,29 @@ var HolePunchTimeout = 5 * time.Second
 
 const errorCodeConnectionGating = 0x47415445 // GATE in ASCII
 
+type Option func(*transport) error
+
+// WithIdentityOptions sets the options used to create the TLS identity,
+// e.g. to embed certificate extensions or to verify the peers' certificates.
+// When constructing a libp2p node, pass it to the transport:
+//
+//	libp2p.Transport(libp2pquic.NewTransport, libp2pquic.WithIdentityOptions(...))
+func WithIdentityOptions(opts ...p2ptls.IdentityOption) Option {
+	return func(t *transport) error {
+		t.identityOpts = append(t.identityOpts, opts...)
+		return nil
+	}
+}
+
 // The Transport implements the tpt.Transport interface for QUIC connections.
 type transport struct {
-	privKey     ic.PrivKey
-	localPeer   peer.ID
-	identity    *p2ptls.Identity
-	connManager *quicreuse.ConnManager
-	gater       connmgr.ConnectionGater
-	rcmgr       network.ResourceManager
+	privKey      ic.PrivKey
+	localPeer    peer.ID
+	identityOpts []p2ptls.IdentityOption
+	identity     *p2ptls.Identity
+	connManager  *quicreuse.ConnManager
+	gater        connmgr.ConnectionGater
+	rcmgr        network.ResourceManager
 
 	holePunchingMx sync.Mutex
 	holePunching   map[holePunchKey]*activeHolePunch
@@ -68,7 +83,7 @@ type activeHolePunch struct {
 }
 
 // NewTransport creates a new QUIC transport
-func NewTransport(key ic.PrivKey, connManager *quicreuse.ConnManager, psk pnet.PSK, gater connmgr.ConnectionGater, rcmgr network.ResourceManager) (tpt.Transport, error) {
+func NewTransport(key ic.PrivKey, connManager *quicreuse.ConnManager, psk pnet.PSK, gater connmgr.ConnectionGater, rcmgr network.ResourceManager, opts ...Option) (tpt.Transport, error) {
 	if len(psk) > 0 {
 		log.Error("QUIC doesn't support private networks yet.")
 		return nil, errors.New("QUIC doesn't support private networks yet")
@@ -77,19 +92,14 @@ func NewTransport(key ic.PrivKey, connManager *quicreuse.ConnManager, psk pnet.P
 	if err != nil {
 		return nil, err
 	}
-	identity, err := p2ptls.NewIdentity(key)
-	if err != nil {
-		return nil, err
-	}
 
 	if rcmgr == nil {
 		rcmgr = &network.NullResourceManager{}
 	}
 
-	return &transport{
+	t := &transport{
 		privKey:      key,
 		localPeer:    localPeer,
-		identity:     identity,
 		connManager:  connManager,
 		gater:        gater,
 		rcmgr:        rcmgr,
@@ -98,7 +108,17 @@ func NewTransport(key ic.PrivKey, connManager *quicreuse.ConnManager, psk pnet.P
 		rnd:          *rand.New(rand.NewSource(time.Now().UnixNano())),
 
 		listeners: make(map[string][]*virtualListener),
-	}, nil
+	}
+	for _, opt := range opts {
+		if err := opt(t); err != nil {
+			return nil, err
+		}
+	}
+	t.identity, err = p2ptls.NewIdentity(key, t.identityOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
 }
 
 // Dial dials a new QUIC connection
@@ -128,6 +148,7 @@ func (t *transport) dialWithScope(ctx context.Context, raddr ma.Multiaddr, p pee
 	}
 
 	tlsConf, keyCh := t.identity.ConfigForPeer(p)
+	start := time.Now()
 	pconn, err := t.connManager.DialQUIC(ctx, raddr, tlsConf, t.allowWindowIncrease)
 	if err != nil {
 		return nil, err
@@ -150,15 +171,16 @@ func (t *transport) dialWithScope(ctx context.Context, raddr ma.Multiaddr, p pee
 		return nil, err
 	}
 	c := &conn{
-		quicConn:        pconn,
-		transport:       t,
-		scope:           scope,
-		privKey:         t.privKey,
-		localPeer:       t.localPeer,
-		localMultiaddr:  localMultiaddr,
-		remotePubKey:    remotePubKey,
-		remotePeerID:    p,
-		remoteMultiaddr: raddr,
+		quicConn:          pconn,
+		transport:         t,
+		scope:             scope,
+		privKey:           t.privKey,
+		localPeer:         t.localPeer,
+		localMultiaddr:    localMultiaddr,
+		remotePubKey:      remotePubKey,
+		remotePeerID:      p,
+		remoteMultiaddr:   raddr,
+		handshakeDuration: time.Since(start),
 	}
 	if t.gater != nil && !t.gater.InterceptSecured(network.DirOutbound, p, c) {
 		pconn.CloseWithError(errorCodeConnectionGating, "connection gated")
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"net"
 	"sync"
+	"time"
 
 	ma "github.com/multiformats/go-multiaddr"
 	manet "github.com/multiformats/go-multiaddr/net"
@@ -22,6 +23,12 @@ type ConnManager struct {
 	enableReuseport bool
 	enableMetrics   bool
 
+	idleTimeout                    time.Duration
+	initialStreamReceiveWindow     uint64
+	maxStreamReceiveWindow         uint64
+	initialConnectionReceiveWindow uint64
+	maxConnectionReceiveWindow     uint64
+
 	serverConfig *quic.Config
 	clientConfig *quic.Config
 
@@ -48,6 +55,17 @@ func NewConnManager(statelessResetKey quic.StatelessResetKey, opts ...Option) (*
 
 	quicConf := quicConfig.Clone()
 	quicConf.StatelessResetKey = &statelessResetKey
+	if cm.idleTimeout > 0 {
+		quicConf.MaxIdleTimeout = cm.idleTimeout
+	}
+	if cm.maxStreamReceiveWindow > 0 {
+		quicConf.InitialStreamReceiveWindow = cm.initialStreamReceiveWindow
+		quicConf.MaxStreamReceiveWindow = cm.maxStreamReceiveWindow
+	}
+	if cm.maxConnectionReceiveWindow > 0 {
+		quicConf.InitialConnectionReceiveWindow = cm.initialConnectionReceiveWindow
+		quicConf.MaxConnectionReceiveWindow = cm.maxConnectionReceiveWindow
+	}
 
 	var tracers []quiclogging.Tracer
 	if qlogTracer != nil {
@@ -160,27 +178,15 @@ func (c *ConnManager) listen(network string, laddr *net.UDPAddr) (pConn, error)
 }
 
 func (c *ConnManager) DialQUIC(ctx context.Context, raddr ma.Multiaddr, tlsConf *tls.Config, allowWindowIncrease func(conn quic.Connection, delta uint64) bool) (quic.Connection, error) {
-	naddr, v, err := FromQuicMultiaddr(raddr)
+	naddr, host, quicConf, err := c.dialArgs(raddr, allowWindowIncrease)
 	if err != nil {
 		return nil, err
 	}
-	netw, host, err := manet.DialArgs(raddr)
+	netw, _, err := manet.DialArgs(raddr)
 	if err != nil {
 		return nil, err
 	}
 
-	quicConf := c.clientConfig.Clone()
-	quicConf.AllowConnectionWindowIncrease = allowWindowIncrease
-
-	if v == quic.Version1 {
-		// The endpoint has explicit support for QUIC v1, so we'll only use that version.
-		quicConf.Versions = []quic.VersionNumber{quic.Version1}
-	} else if v == quic.VersionDraft29 {
-		quicConf.Versions = []quic.VersionNumber{quic.VersionDraft29}
-	} else {
-		return nil, errors.New("unknown QUIC version")
-	}
-
 	pconn, err := c.Dial(netw, naddr)
 	if err != nil {
 		return nil, err
@@ -193,6 +199,41 @@ func (c *ConnManager) DialQUIC(ctx context.Context, raddr ma.Multiaddr, tlsConf
 	return conn, nil
 }
 
+// DialQUICOverConn dials a QUIC connection to raddr using pconn instead of one of the
+// UDP sockets managed by the ConnManager, e.g. to tunnel the connection through a proxy.
+// pconn is not closed when the QUIC connection is closed.
+func (c *ConnManager) DialQUICOverConn(ctx context.Context, pconn net.PacketConn, raddr ma.Multiaddr, tlsConf *tls.Config, allowWindowIncrease func(conn quic.Connection, delta uint64) bool) (quic.Connection, error) {
+	naddr, host, quicConf, err := c.dialArgs(raddr, allowWindowIncrease)
+	if err != nil {
+		return nil, err
+	}
+	return quicDialContext(ctx, pconn, naddr, host, tlsConf, quicConf)
+}
+
+func (c *ConnManager) dialArgs(raddr ma.Multiaddr, allowWindowIncrease func(conn quic.Connection, delta uint64) bool) (*net.UDPAddr, string, *quic.Config, error) {
+	naddr, v, err := FromQuicMultiaddr(raddr)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	_, host, err := manet.DialArgs(raddr)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	quicConf := c.clientConfig.Clone()
+	quicConf.AllowConnectionWindowIncrease = allowWindowIncrease
+
+	if v == quic.Version1 {
+		// The endpoint has explicit support for QUIC v1, so we'll only use that version.
+		quicConf.Versions = []quic.VersionNumber{quic.Version1}
+	} else if v == quic.VersionDraft29 {
+		quicConf.Versions = []quic.VersionNumber{quic.VersionDraft29}
+	} else {
+		return nil, "", nil, errors.New("unknown QUIC version")
+	}
+	return naddr, host, quicConf, nil
+}
+
 func (c *ConnManager) Dial(network string, raddr *net.UDPAddr) (pConn, error) {
 	if c.enableReuseport {
 		reuse, err := c.getReuse(network)
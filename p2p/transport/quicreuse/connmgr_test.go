@@ -277,3 +277,36 @@ func testListener(t *testing.T, enableReuseport bool) {
 
 	checkClosed(t, cm)
 }
+
+func TestQUICConfigOptions(t *testing.T) {
+	origQuicDialContext := quicDialContext
+	defer func() { quicDialContext = origQuicDialContext }()
+
+	var conf *quic.Config
+	quicDialContext = func(_ context.Context, _ net.PacketConn, _ net.Addr, _ string, _ *tls.Config, c *quic.Config) (quic.Connection, error) {
+		conf = c
+		return nil, errors.New("dial error")
+	}
+
+	cm, err := NewConnManager([32]byte{},
+		DisableReuseport(),
+		WithIdleTimeout(time.Minute),
+		WithStreamReceiveWindow(1<<20, 32<<20),
+		WithConnectionReceiveWindow(2<<20, 64<<20),
+	)
+	require.NoError(t, err)
+	defer cm.Close()
+
+	_, err = cm.DialQUIC(context.Background(), ma.StringCast("/ip4/127.0.0.1/udp/1234/quic-v1"), &tls.Config{}, nil)
+	require.EqualError(t, err, "dial error")
+	for _, c := range []*quic.Config{conf, cm.serverConfig} {
+		require.Equal(t, time.Minute, c.MaxIdleTimeout)
+		require.Equal(t, uint64(1<<20), c.InitialStreamReceiveWindow)
+		require.Equal(t, uint64(32<<20), c.MaxStreamReceiveWindow)
+		require.Equal(t, uint64(2<<20), c.InitialConnectionReceiveWindow)
+		require.Equal(t, uint64(64<<20), c.MaxConnectionReceiveWindow)
+	}
+
+	_, err = NewConnManager([32]byte{}, WithStreamReceiveWindow(2<<20, 1<<20))
+	require.Error(t, err)
+}
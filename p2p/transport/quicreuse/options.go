@@ -1,5 +1,11 @@
 package quicreuse
 
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
 type Option func(*ConnManager) error
 
 func DisableReuseport() Option {
@@ -26,3 +32,43 @@ func EnableMetrics() Option {
 		return nil
 	}
 }
+
+// WithIdleTimeout sets the time after which a QUIC connection is closed if the peer
+// becomes unresponsive. It defaults to 30s.
+// It applies to all connections of the ConnManager, dialed or accepted on any listener.
+func WithIdleTimeout(d time.Duration) Option {
+	return func(m *ConnManager) error {
+		if d <= 0 {
+			return errors.New("idle timeout must be positive")
+		}
+		m.idleTimeout = d
+		return nil
+	}
+}
+
+// WithStreamReceiveWindow sets the initial and the maximum flow control window of streams.
+// The window starts at initial and grows up to max, as the transfer rate requires.
+// Increasing them improves the throughput on networks with a large bandwidth-delay product.
+// They apply to all connections of the ConnManager, dialed or accepted on any listener.
+func WithStreamReceiveWindow(initial, max uint64) Option {
+	return func(m *ConnManager) error {
+		if initial == 0 || initial > max {
+			return fmt.Errorf("invalid stream receive window: initial %d, max %d", initial, max)
+		}
+		m.initialStreamReceiveWindow, m.maxStreamReceiveWindow = initial, max
+		return nil
+	}
+}
+
+// WithConnectionReceiveWindow sets the initial and the maximum flow control window of
+// connections, that is the window shared by all streams of a connection.
+// See WithStreamReceiveWindow.
+func WithConnectionReceiveWindow(initial, max uint64) Option {
+	return func(m *ConnManager) error {
+		if initial == 0 || initial > max {
+			return fmt.Errorf("invalid connection receive window: initial %d, max %d", initial, max)
+		}
+		m.initialConnectionReceiveWindow, m.maxConnectionReceiveWindow = initial, max
+		return nil
+	}
+}
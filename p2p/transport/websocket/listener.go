@@ -23,6 +23,9 @@ type listener struct {
 
 	laddr ma.Multiaddr
 
+	compressionMode      ws.CompressionMode
+	compressionThreshold int
+
 	closed   chan struct{}
 	incoming chan net.Conn
 }
@@ -103,7 +106,9 @@ func (l *listener) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	c, err := ws.Accept(w, r, &ws.AcceptOptions{
 		// Allow requests from *all* origins.
-		InsecureSkipVerify: true,
+		InsecureSkipVerify:   true,
+		CompressionMode:      l.compressionMode,
+		CompressionThreshold: l.compressionThreshold,
 	})
 	if err != nil {
 		// The upgrader writes a response for us.
@@ -75,6 +75,44 @@ func WithTLSConfig(conf *tls.Config) Option {
 	}
 }
 
+// CompressionMode is the mode of the permessage-deflate extension (RFC 7692).
+type CompressionMode int
+
+const (
+	// CompressionNoContextTakeover compresses every message independently.
+	// This is the default.
+	CompressionNoContextTakeover CompressionMode = iota
+	// CompressionContextTakeover reuses the sliding window of previous messages,
+	// at the cost of keeping a compressor per connection. The peer may still
+	// negotiate no context takeover.
+	CompressionContextTakeover
+	// CompressionDisabled doesn't negotiate the extension.
+	CompressionDisabled
+)
+
+// WithCompression configures the permessage-deflate extension, negotiated with the
+// peer on both dialed and accepted connections. Messages smaller than threshold bytes
+// aren't compressed. A threshold of 0 uses the default of 512 bytes (128 bytes with
+// context takeover).
+// The window size can't be configured, the maximum window of 32 kB is always used.
+//
+// Note that connections are secured (using TLS or Noise) on top of the WebSocket,
+// so messages are encrypted before being compressed, and compression barely
+// reduces their size. Disabling compression saves CPU.
+func WithCompression(mode CompressionMode, threshold int) Option {
+	return func(t *WebsocketTransport) error {
+		if mode < CompressionNoContextTakeover || mode > CompressionDisabled {
+			return fmt.Errorf("invalid compression mode: %d", mode)
+		}
+		if threshold < 0 {
+			return fmt.Errorf("invalid compression threshold: %d", threshold)
+		}
+		t.compressionMode = mode
+		t.compressionThreshold = threshold
+		return nil
+	}
+}
+
 // WebsocketTransport is the actual go-libp2p transport
 type WebsocketTransport struct {
 	upgrader transport.Upgrader
@@ -82,6 +120,9 @@ type WebsocketTransport struct {
 
 	tlsClientConf *tls.Config
 	tlsConf       *tls.Config
+
+	compressionMode      CompressionMode
+	compressionThreshold int
 }
 
 var _ transport.Transport = (*WebsocketTransport)(nil)
@@ -237,7 +278,9 @@ func (t *WebsocketTransport) maDial(ctx context.Context, raddr ma.Multiaddr) (ma
 	}
 
 	wscon, _, err := ws.Dial(ctx, wsurl.String(), &ws.DialOptions{
-		HTTPClient: &dialer,
+		HTTPClient:           &dialer,
+		CompressionMode:      t.wsCompressionMode(),
+		CompressionThreshold: t.compressionThreshold,
 	})
 	if err != nil {
 		return nil, err
@@ -271,10 +314,23 @@ func (t *WebsocketTransport) maListen(a ma.Multiaddr) (manet.Listener, error) {
 	if err != nil {
 		return nil, err
 	}
+	l.compressionMode = t.wsCompressionMode()
+	l.compressionThreshold = t.compressionThreshold
 	go l.serve()
 	return l, nil
 }
 
+func (t *WebsocketTransport) wsCompressionMode() ws.CompressionMode {
+	switch t.compressionMode {
+	case CompressionContextTakeover:
+		return ws.CompressionContextTakeover
+	case CompressionDisabled:
+		return ws.CompressionDisabled
+	default:
+		return ws.CompressionNoContextTakeover
+	}
+}
+
 func (t *WebsocketTransport) Listen(a ma.Multiaddr) (transport.Listener, error) {
 	malist, err := t.maListen(a)
 	if err != nil {
@@ -0,0 +1,48 @@
+package websocket
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompression(t *testing.T) {
+	for _, mode := range []CompressionMode{CompressionNoContextTakeover, CompressionContextTakeover, CompressionDisabled} {
+		tpt, err := New(nil, nil, WithCompression(mode, 16))
+		require.NoError(t, err)
+		ln, err := tpt.maListen(ma.StringCast("/ip4/127.0.0.1/tcp/0/ws"))
+		require.NoError(t, err)
+
+		msg := bytes.Repeat([]byte("compressible "), 100)
+		done := make(chan error, 1)
+		go func() {
+			c, err := ln.Accept()
+			if err != nil {
+				done <- err
+				return
+			}
+			defer c.Close()
+			_, err = c.Write(msg)
+			done <- err
+		}()
+
+		c, err := tpt.maDial(context.Background(), ln.Multiaddr())
+		require.NoError(t, err)
+		b := make([]byte, len(msg))
+		_, err = io.ReadFull(c, b)
+		require.NoError(t, err)
+		require.Equal(t, msg, b)
+		require.NoError(t, <-done)
+		c.Close()
+		ln.Close()
+	}
+
+	_, err := New(nil, nil, WithCompression(CompressionMode(42), 0))
+	require.Error(t, err)
+	_, err = New(nil, nil, WithCompression(CompressionNoContextTakeover, -1))
+	require.Error(t, err)
+}
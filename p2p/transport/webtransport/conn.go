@@ -2,6 +2,8 @@ package libp2pwebtransport
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 
 	"github.com/AstaFrode/go-libp2p/core/network"
 	tpt "github.com/AstaFrode/go-libp2p/core/transport"
@@ -47,17 +49,17 @@ func newConn(tr *transport, sess *webtransport.Session, sconn *connSecurityMulti
 func (c *conn) OpenStream(ctx context.Context) (network.MuxedStream, error) {
 	str, err := c.session.OpenStreamSync(ctx)
 	if err != nil {
-		return nil, err
+		return nil, parseSessionError(err)
 	}
-	return &stream{str}, nil
+	return &stream{Stream: str, session: c.session}, nil
 }
 
 func (c *conn) AcceptStream() (network.MuxedStream, error) {
 	str, err := c.session.AcceptStream(context.Background())
 	if err != nil {
-		return nil, err
+		return nil, parseSessionError(err)
 	}
-	return &stream{str}, nil
+	return &stream{Stream: str, session: c.session}, nil
 }
 
 func (c *conn) allowWindowIncrease(size uint64) bool {
@@ -72,10 +74,42 @@ func (c *conn) Close() error {
 	return c.session.CloseWithError(0, "")
 }
 
+// CloseWithError closes the connection, sending errCode and reason to the remote peer
+// in the CLOSE_WEBTRANSPORT_SESSION capsule.
+func (c *conn) CloseWithError(errCode network.ConnErrorCode, reason string) error {
+	c.transport.removeConn(c.session)
+	return c.session.CloseWithError(webtransport.SessionErrorCode(errCode), reason)
+}
+
 func (c *conn) IsClosed() bool           { return c.session.Context().Err() != nil }
 func (c *conn) Scope() network.ConnScope { return c.scope }
 func (c *conn) Transport() tpt.Transport { return c.transport }
 
 func (c *conn) ConnState() network.ConnectionState {
-	return network.ConnectionState{Transport: "webtransport"}
+	tlsState := c.session.ConnectionState().TLS
+	return network.ConnectionState{
+		Transport:   "webtransport",
+		CipherSuite: tls.CipherSuiteName(tlsState.CipherSuite),
+		Resumed:     tlsState.DidResume || tlsState.Used0RTT,
+	}
+}
+
+// sessionCloseError returns the error the session was closed with, converted to a
+// *network.ConnError, or nil if the session is not closed.
+func sessionCloseError(sess *webtransport.Session) error {
+	if sess.Context().Err() == nil {
+		return nil
+	}
+	// webtransport-go doesn't expose the close error, but once the session is closed,
+	// AcceptStream returns it without accepting a stream.
+	_, err := sess.AcceptStream(context.Background())
+	return parseSessionError(err)
+}
+
+func parseSessionError(err error) error {
+	var ce *webtransport.ConnectionError
+	if err != nil && errors.As(err, &ce) {
+		return &network.ConnError{ErrorCode: network.ConnErrorCode(ce.ErrorCode), Reason: ce.Message, Remote: ce.Remote}
+	}
+	return err
 }
@@ -0,0 +1,336 @@
+package libp2pwebtransport
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+	"github.com/quic-go/quic-go/quicvarint"
+)
+
+// The MASQUE proxy support implements Proxying UDP in HTTP (RFC 9298).
+// UDP payloads are carried in DATAGRAM capsules (RFC 9297) on the request stream, since
+// the QUIC packets we tunnel don't fit into QUIC DATAGRAM frames.
+
+const (
+	capsuleTypeDatagram = 0x00
+	// the context ID of UDP payloads, see Section 4 of RFC 9298
+	contextIDUDPPayload = 0
+
+	connectUDPProtocol = "connect-udp"
+
+	// the time we wait for the proxy to respond via HTTP/3 before falling back to TCP
+	proxyHTTP3Timeout = 5 * time.Second
+)
+
+// MASQUEProxy configures dialing through a MASQUE proxy, for clients in networks that block
+// UDP traffic to arbitrary hosts.
+// The connection to the proxy is established using HTTP/3. If that fails, e.g. because UDP
+// traffic to the proxy is blocked as well, HTTP/1.1 over TLS over TCP is used instead.
+type MASQUEProxy struct {
+	// URITemplate is the URI template of the proxy. It must use the https scheme and contain
+	// the target_host and target_port variables, e.g.
+	// "https://proxy.example.com/.well-known/masque/udp/{target_host}/{target_port}/".
+	URITemplate string
+	// TLSConfig is the tls.Config used for the connection to the proxy.
+	TLSConfig *tls.Config
+	// Header contains additional headers sent to the proxy, e.g. for authentication.
+	Header http.Header
+	// DisableHTTP3 makes the transport connect to the proxy using TCP only.
+	DisableHTTP3 bool
+}
+
+// WithMASQUEProxy makes the transport dial all connections through a MASQUE proxy.
+// Listening is not affected by this option.
+func WithMASQUEProxy(p MASQUEProxy) Option {
+	return func(t *transport) error {
+		u, err := url.Parse(p.URITemplate)
+		if err != nil {
+			return fmt.Errorf("invalid MASQUE proxy URI template: %w", err)
+		}
+		if u.Scheme != "https" {
+			return errors.New("MASQUE proxy URI template must use the https scheme")
+		}
+		if !strings.Contains(p.URITemplate, "{target_host}") || !strings.Contains(p.URITemplate, "{target_port}") {
+			return errors.New("MASQUE proxy URI template must contain {target_host} and {target_port}")
+		}
+		t.proxy = &p
+		return nil
+	}
+}
+
+var proxiedConnCounter atomic.Uint64
+
+// dial opens a tunnel to target through the proxy.
+func (p *MASQUEProxy) dial(ctx context.Context, target *net.UDPAddr) (*proxiedConn, error) {
+	u, err := url.Parse(expandURITemplate(p.URITemplate, target))
+	if err != nil {
+		return nil, err
+	}
+	if !p.DisableHTTP3 {
+		h3ctx, cancel := context.WithTimeout(ctx, proxyHTTP3Timeout)
+		c, err := p.dialHTTP3(h3ctx, u, target)
+		cancel()
+		if err == nil {
+			return c, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		log.Debugw("connecting to the MASQUE proxy using HTTP/3 failed, falling back to TCP", "proxy", u.Host, "error", err)
+	}
+	return p.dialTCP(ctx, u, target)
+}
+
+func (p *MASQUEProxy) tlsConfig(nextProto string) *tls.Config {
+	var tlsConf *tls.Config
+	if p.TLSConfig != nil {
+		tlsConf = p.TLSConfig.Clone()
+	} else {
+		tlsConf = &tls.Config{}
+	}
+	tlsConf.NextProtos = []string{nextProto}
+	return tlsConf
+}
+
+func (p *MASQUEProxy) header() http.Header {
+	hdr := p.Header.Clone()
+	if hdr == nil {
+		hdr = http.Header{}
+	}
+	hdr.Set("Capsule-Protocol", "?1")
+	return hdr
+}
+
+func (p *MASQUEProxy) dialHTTP3(ctx context.Context, u *url.URL, target *net.UDPAddr) (*proxiedConn, error) {
+	qconn, err := quic.DialAddrEarlyContext(ctx, proxyAuthority(u), p.tlsConfig(http3.NextProtoH3), nil)
+	if err != nil {
+		return nil, err
+	}
+	rt := &http3.RoundTripper{
+		Dial: func(context.Context, string, *tls.Config, *quic.Config) (quic.EarlyConnection, error) {
+			return qconn, nil
+		},
+	}
+	closeConn := func() error {
+		rt.Close()
+		return qconn.CloseWithError(0, "")
+	}
+	req := &http.Request{
+		Method: http.MethodConnect,
+		Proto:  connectUDPProtocol,
+		Host:   u.Host,
+		URL:    u,
+		Header: p.header(),
+	}
+	rsp, err := rt.RoundTripOpt(req.WithContext(ctx), http3.RoundTripOpt{DontCloseRequestStream: true})
+	if err != nil {
+		closeConn()
+		return nil, err
+	}
+	if rsp.StatusCode < 200 || rsp.StatusCode > 299 {
+		rsp.Body.Close()
+		closeConn()
+		return nil, fmt.Errorf("MASQUE proxy returned status code %d", rsp.StatusCode)
+	}
+	// Writes to the HTTP/3 stream are sent in DATA frames.
+	str := rsp.Body.(http3.HTTPStreamer).HTTPStream()
+	return newProxiedConn(u.Host, target, bufio.NewReader(rsp.Body), str, func() error {
+		rsp.Body.Close()
+		str.Close()
+		return closeConn()
+	}), nil
+}
+
+func (p *MASQUEProxy) dialTCP(ctx context.Context, u *url.URL, target *net.UDPAddr) (*proxiedConn, error) {
+	dialer := &tls.Dialer{Config: p.tlsConfig("http/1.1")}
+	conn, err := dialer.DialContext(ctx, "tcp", proxyAuthority(u))
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	hdr := p.header()
+	hdr.Set("Connection", "Upgrade")
+	hdr.Set("Upgrade", connectUDPProtocol)
+	req := &http.Request{
+		Method: http.MethodGet,
+		Host:   u.Host,
+		URL:    u,
+		Header: hdr,
+	}
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	br := bufio.NewReader(conn)
+	rsp, err := http.ReadResponse(br, req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if rsp.StatusCode != http.StatusSwitchingProtocols {
+		conn.Close()
+		return nil, fmt.Errorf("MASQUE proxy returned status code %d", rsp.StatusCode)
+	}
+	conn.SetDeadline(time.Time{})
+	return newProxiedConn(u.Host, target, br, conn, conn.Close), nil
+}
+
+// expandURITemplate expands the target_host and target_port variables of a URI template,
+// see Section 2 of RFC 9298.
+func expandURITemplate(tmpl string, target *net.UDPAddr) string {
+	return strings.NewReplacer(
+		"{target_host}", escapeURITemplateValue(target.IP.String()),
+		"{target_port}", fmt.Sprintf("%d", target.Port),
+	).Replace(tmpl)
+}
+
+// escapeURITemplateValue percent-encodes all characters except the unreserved ones,
+// as required for a simple string expansion (Section 3.2.2 of RFC 6570).
+func escapeURITemplateValue(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '-' || c == '.' || c == '_' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func proxyAuthority(u *url.URL) string {
+	if u.Port() == "" {
+		return net.JoinHostPort(u.Hostname(), "443")
+	}
+	return u.Host
+}
+
+// proxiedAddr is the local address of a proxiedConn.
+// It needs to be unique, since quic-go keeps track of packet conns by their local address.
+type proxiedAddr struct {
+	proxy string
+	id    uint64
+	// the unspecified address of the address family of the target
+	unspecified *net.UDPAddr
+}
+
+func (a *proxiedAddr) Network() string { return "masque" }
+func (a *proxiedAddr) String() string  { return fmt.Sprintf("%s#%d", a.proxy, a.id) }
+
+// proxiedConn is a net.PacketConn tunneling UDP packets to a single target through a MASQUE proxy.
+type proxiedConn struct {
+	r *bufio.Reader
+
+	writeMx sync.Mutex
+	w       io.Writer
+
+	local  *proxiedAddr
+	remote *net.UDPAddr
+
+	closeOnce sync.Once
+	closeErr  error
+	close     func() error
+}
+
+var _ net.PacketConn = &proxiedConn{}
+
+func newProxiedConn(proxy string, target *net.UDPAddr, r *bufio.Reader, w io.Writer, close func() error) *proxiedConn {
+	unspecified := &net.UDPAddr{IP: net.IPv4zero}
+	if target.IP.To4() == nil {
+		unspecified.IP = net.IPv6unspecified
+	}
+	return &proxiedConn{
+		r:      r,
+		w:      w,
+		local:  &proxiedAddr{proxy: proxy, id: proxiedConnCounter.Add(1), unspecified: unspecified},
+		remote: target,
+		close:  close,
+	}
+}
+
+func (c *proxiedConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	for {
+		typ, err := quicvarint.Read(c.r)
+		if err != nil {
+			return 0, nil, err
+		}
+		length, err := quicvarint.Read(c.r)
+		if err != nil {
+			return 0, nil, err
+		}
+		if typ != capsuleTypeDatagram {
+			// skip unknown capsules
+			if _, err := io.CopyN(io.Discard, c.r, int64(length)); err != nil {
+				return 0, nil, err
+			}
+			continue
+		}
+		contextID, err := quicvarint.Read(c.r)
+		if err != nil {
+			return 0, nil, err
+		}
+		length -= uint64(quicvarint.Len(contextID))
+		if contextID != contextIDUDPPayload {
+			if _, err := io.CopyN(io.Discard, c.r, int64(length)); err != nil {
+				return 0, nil, err
+			}
+			continue
+		}
+		n := len(b)
+		if uint64(n) > length {
+			n = int(length)
+		}
+		if _, err := io.ReadFull(c.r, b[:n]); err != nil {
+			return 0, nil, err
+		}
+		// Like for a UDP socket, the remainder of a packet that doesn't fit into b is discarded.
+		if _, err := io.CopyN(io.Discard, c.r, int64(length)-int64(n)); err != nil {
+			return 0, nil, err
+		}
+		return n, c.remote, nil
+	}
+}
+
+func (c *proxiedConn) WriteTo(p []byte, _ net.Addr) (int, error) {
+	capsuleLen := uint64(quicvarint.Len(contextIDUDPPayload)) + uint64(len(p))
+	b := make([]byte, 0, len(p)+16)
+	b = quicvarint.Append(b, capsuleTypeDatagram)
+	b = quicvarint.Append(b, capsuleLen)
+	b = quicvarint.Append(b, contextIDUDPPayload)
+	b = append(b, p...)
+
+	c.writeMx.Lock()
+	defer c.writeMx.Unlock()
+	if _, err := c.w.Write(b); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (c *proxiedConn) Close() error {
+	c.closeOnce.Do(func() { c.closeErr = c.close() })
+	return c.closeErr
+}
+
+func (c *proxiedConn) LocalAddr() net.Addr { return c.local }
+
+// Deadlines are not supported.
+func (c *proxiedConn) SetDeadline(time.Time) error      { return nil }
+func (c *proxiedConn) SetReadDeadline(time.Time) error  { return nil }
+func (c *proxiedConn) SetWriteDeadline(time.Time) error { return nil }
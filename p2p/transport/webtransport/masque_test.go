@@ -0,0 +1,186 @@
+package libp2pwebtransport_test
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	libp2pwebtransport "github.com/AstaFrode/go-libp2p/p2p/transport/webtransport"
+
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/quic-go/quic-go/http3"
+	"github.com/quic-go/quic-go/quicvarint"
+	"github.com/stretchr/testify/require"
+)
+
+// masqueProxy is a minimal MASQUE proxy, supporting HTTP/3 and HTTP/1.1.
+type masqueProxy struct {
+	tunnels atomic.Int32
+}
+
+func (p *masqueProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// the path is /masque/{target_host}/{target_port}/
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) != 3 || r.Header.Get("Capsule-Protocol") != "?1" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	target, err := net.ResolveUDPAddr("udp", net.JoinHostPort(parts[1], parts[2]))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	udpConn, err := net.DialUDP("udp", nil, target)
+	if err != nil {
+		w.WriteHeader(http.StatusBadGateway)
+		return
+	}
+	defer udpConn.Close()
+
+	var capsules io.Reader
+	var writeCapsule func([]byte) error
+	switch {
+	case r.Method == http.MethodConnect && r.Proto == "connect-udp":
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		capsules = r.Body
+		writeCapsule = func(b []byte) error {
+			if _, err := w.Write(b); err != nil {
+				return err
+			}
+			w.(http.Flusher).Flush()
+			return nil
+		}
+	case r.Header.Get("Upgrade") == "connect-udp":
+		conn, brw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		fmt.Fprint(conn, "HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: connect-udp\r\nCapsule-Protocol: ?1\r\n\r\n")
+		capsules = brw.Reader
+		writeCapsule = func(b []byte) error {
+			_, err := conn.Write(b)
+			return err
+		}
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	p.tunnels.Add(1)
+
+	go func() {
+		b := make([]byte, 1500)
+		for {
+			n, err := udpConn.Read(b)
+			if err != nil {
+				return
+			}
+			capsule := quicvarint.Append(nil, 0)
+			capsule = quicvarint.Append(capsule, uint64(n+1))
+			capsule = quicvarint.Append(capsule, 0)
+			if err := writeCapsule(append(capsule, b[:n]...)); err != nil {
+				return
+			}
+		}
+	}()
+	br := bufio.NewReader(capsules)
+	for {
+		typ, err := quicvarint.Read(br)
+		if err != nil {
+			return
+		}
+		length, err := quicvarint.Read(br)
+		if err != nil {
+			return
+		}
+		b := make([]byte, length)
+		if _, err := io.ReadFull(br, b); err != nil {
+			return
+		}
+		if typ != 0 || b[0] != 0 {
+			continue
+		}
+		udpConn.Write(b[1:])
+	}
+}
+
+func TestMASQUEProxy(t *testing.T) {
+	for _, useHTTP3 := range []bool{true, false} {
+		t.Run(fmt.Sprintf("HTTP/3: %t", useHTTP3), func(t *testing.T) {
+			proxy := &masqueProxy{}
+			srv := httptest.NewUnstartedServer(proxy)
+			srv.StartTLS()
+			defer srv.Close()
+			proxyAddr := srv.Listener.Addr().String()
+			if useHTTP3 {
+				udpConn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+				require.NoError(t, err)
+				h3srv := &http3.Server{Handler: proxy, TLSConfig: http3.ConfigureTLSConfig(srv.TLS)}
+				go h3srv.Serve(udpConn)
+				defer h3srv.Close()
+				proxyAddr = udpConn.LocalAddr().String()
+			}
+			certPool := x509.NewCertPool()
+			certPool.AddCert(srv.Certificate())
+
+			serverID, serverKey := newIdentity(t)
+			tr, err := libp2pwebtransport.New(serverKey, nil, newConnManager(t), nil, nil)
+			require.NoError(t, err)
+			defer tr.(io.Closer).Close()
+			ln, err := tr.Listen(ma.StringCast("/ip4/127.0.0.1/udp/0/quic-v1/webtransport"))
+			require.NoError(t, err)
+			defer ln.Close()
+
+			_, clientKey := newIdentity(t)
+			cl, err := libp2pwebtransport.New(clientKey, nil, newConnManager(t), nil, nil, libp2pwebtransport.WithMASQUEProxy(libp2pwebtransport.MASQUEProxy{
+				URITemplate:  fmt.Sprintf("https://%s/masque/{target_host}/{target_port}/", proxyAddr),
+				TLSConfig:    &tls.Config{RootCAs: certPool},
+				DisableHTTP3: !useHTTP3,
+			}))
+			require.NoError(t, err)
+			defer cl.(io.Closer).Close()
+
+			conn, err := cl.Dial(context.Background(), ln.Multiaddr(), serverID)
+			require.NoError(t, err)
+			defer conn.Close()
+			require.Equal(t, int32(1), proxy.tunnels.Load())
+			require.Equal(t, ma.StringCast("/ip4/0.0.0.0/udp/0/quic-v1/webtransport"), conn.LocalMultiaddr())
+
+			str, err := conn.OpenStream(context.Background())
+			require.NoError(t, err)
+			_, err = str.Write([]byte("foobar"))
+			require.NoError(t, err)
+			require.NoError(t, str.Close())
+
+			sconn, err := ln.Accept()
+			require.NoError(t, err)
+			defer sconn.Close()
+			sstr, err := sconn.AcceptStream()
+			require.NoError(t, err)
+			data, err := io.ReadAll(sstr)
+			require.NoError(t, err)
+			require.Equal(t, "foobar", string(data))
+		})
+	}
+}
+
+func TestMASQUEProxyURITemplate(t *testing.T) {
+	_, key := newIdentity(t)
+	for _, tmpl := range []string{
+		"http://proxy.example.com/{target_host}/{target_port}/",
+		"https://proxy.example.com/{target_host}/",
+	} {
+		_, err := libp2pwebtransport.New(key, nil, newConnManager(t), nil, nil, libp2pwebtransport.WithMASQUEProxy(libp2pwebtransport.MASQUEProxy{URITemplate: tmpl}))
+		require.Error(t, err, tmpl)
+	}
+}
@@ -18,6 +18,10 @@ var webtransportMA = ma.StringCast("/quic-v1/webtransport")
 var webtransportMatcher = mafmt.And(mafmt.IP, mafmt.Base(ma.P_UDP), mafmt.Base(ma.P_QUIC_V1), mafmt.Base(ma.P_WEBTRANSPORT))
 
 func toWebtransportMultiaddr(na net.Addr) (ma.Multiaddr, error) {
+	if a, ok := na.(*proxiedAddr); ok {
+		// We don't know which address the MASQUE proxy uses for our connection.
+		na = a.unspecified
+	}
 	addr, err := manet.FromNetAddr(na)
 	if err != nil {
 		return nil, err
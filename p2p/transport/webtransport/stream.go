@@ -2,10 +2,17 @@ package libp2pwebtransport
 
 import (
 	"errors"
+	"fmt"
+	"io"
+	"math"
 	"net"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/AstaFrode/go-libp2p/core/network"
 
+	"github.com/quic-go/quic-go"
 	"github.com/quic-go/webtransport-go"
 )
 
@@ -13,6 +20,10 @@ const (
 	reset webtransport.StreamErrorCode = 0
 )
 
+// sessionCloseWait is how long a failed stream operation waits for the session to be closed,
+// to return the error the session was closed with.
+const sessionCloseWait = time.Second
+
 type webtransportStream struct {
 	webtransport.Stream
 	wsess *webtransport.Session
@@ -30,42 +41,116 @@ func (s *webtransportStream) RemoteAddr() net.Addr {
 
 type stream struct {
 	webtransport.Stream
+	session *webtransport.Session
+
+	// webtransport.StreamError doesn't tell which side canceled the stream
+	readCanceled, writeCanceled atomic.Bool
 }
 
 var _ network.MuxedStream = &stream{}
 
 func (s *stream) Read(b []byte) (n int, err error) {
 	n, err = s.Stream.Read(b)
-	if err != nil && errors.Is(err, &webtransport.StreamError{}) {
-		err = network.ErrReset
-	}
-	return n, err
+	return n, s.parseError(err, !s.readCanceled.Load())
 }
 
 func (s *stream) Write(b []byte) (n int, err error) {
 	n, err = s.Stream.Write(b)
-	if err != nil && errors.Is(err, &webtransport.StreamError{}) {
-		err = network.ErrReset
-	}
-	return n, err
+	return n, s.parseError(err, !s.writeCanceled.Load())
 }
 
 func (s *stream) Reset() error {
-	s.Stream.CancelRead(reset)
-	s.Stream.CancelWrite(reset)
+	s.cancelRead(reset)
+	s.cancelWrite(reset)
+	return nil
+}
+
+func (s *stream) ResetWithError(errCode network.StreamErrorCode) error {
+	if errCode > math.MaxUint8 {
+		s.Reset()
+		return fmt.Errorf("error code %d too large for WebTransport", errCode)
+	}
+	s.cancelRead(webtransport.StreamErrorCode(errCode))
+	s.cancelWrite(webtransport.StreamErrorCode(errCode))
 	return nil
 }
 
 func (s *stream) Close() error {
-	s.Stream.CancelRead(reset)
+	s.cancelRead(reset)
 	return s.Stream.Close()
 }
 
 func (s *stream) CloseRead() error {
-	s.Stream.CancelRead(reset)
+	s.cancelRead(reset)
 	return nil
 }
 
 func (s *stream) CloseWrite() error {
 	return s.Stream.Close()
 }
+
+func (s *stream) cancelRead(errCode webtransport.StreamErrorCode) {
+	s.readCanceled.Store(true)
+	s.Stream.CancelRead(errCode)
+}
+
+func (s *stream) cancelWrite(errCode webtransport.StreamErrorCode) {
+	s.writeCanceled.Store(true)
+	s.Stream.CancelWrite(errCode)
+}
+
+// parseError converts the errors returned when the stream was canceled to network.ErrReset, or to
+// a *network.StreamError if it was canceled with an error code, and the errors returned when the session was closed to a *network.ConnError.
+func (s *stream) parseError(err error, remote bool) error {
+	if err == nil {
+		return nil
+	}
+	var se *webtransport.StreamError
+	if errors.As(err, &se) {
+		if se.ErrorCode == reset {
+			return network.ErrReset
+		}
+		return &network.StreamError{ErrorCode: network.StreamErrorCode(se.ErrorCode), Remote: remote}
+	}
+	if err == io.EOF {
+		return err
+	}
+	if nerr, ok := err.(net.Error); ok && nerr.Timeout() {
+		return err
+	}
+	if !s.sessionGoingAway(err) {
+		return err
+	}
+	// The remote's reset may arrive before the session is closed: give the close
+	// capsule a chance to arrive.
+	select {
+	case <-s.session.Context().Done():
+	case <-time.After(sessionCloseWait):
+	}
+	if cerr := sessionCloseError(s.session); cerr != nil {
+		return cerr
+	}
+	return err
+}
+
+// sessionGoingAway says if err was caused by the session (or the underlying QUIC connection)
+// being closed, as opposed to a local error on this stream.
+func (s *stream) sessionGoingAway(err error) bool {
+	if s.session.Context().Err() != nil {
+		return true
+	}
+	var (
+		connErr      *webtransport.ConnectionError
+		appErr       *quic.ApplicationError
+		transportErr *quic.TransportError
+		idleErr      *quic.IdleTimeoutError
+		resetErr     *quic.StatelessResetError
+	)
+	if errors.As(err, &connErr) || errors.As(err, &appErr) || errors.As(err, &transportErr) ||
+		errors.As(err, &idleErr) || errors.As(err, &resetErr) {
+		return true
+	}
+	// When the session is closed, its streams are reset with an error code that is not a
+	// WebTransport error code. webtransport-go doesn't wrap the QUIC stream error in that case.
+	return strings.HasPrefix(err.Error(), "stream reset, but failed to convert stream error")
+}
@@ -73,6 +73,7 @@ type transport struct {
 	certManager   *certManager
 	staticTLSConf *tls.Config
 	tlsClientConf *tls.Config
+	proxy         *MASQUEProxy
 
 	noise *noise.Transport
 
@@ -196,7 +197,13 @@ func (t *transport) dial(ctx context.Context, addr ma.Multiaddr, url, sni string
 			return verifyRawCerts(rawCerts, certHashes)
 		}
 	}
-	conn, err := t.connManager.DialQUIC(ctx, addr, tlsConf, t.allowWindowIncrease)
+	var conn quic.Connection
+	var err error
+	if t.proxy != nil {
+		conn, err = t.dialProxied(ctx, addr, tlsConf)
+	} else {
+		conn, err = t.connManager.DialQUIC(ctx, addr, tlsConf, t.allowWindowIncrease)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -217,6 +224,28 @@ func (t *transport) dial(ctx context.Context, addr ma.Multiaddr, url, sni string
 	return sess, err
 }
 
+// dialProxied dials a QUIC connection to addr through the MASQUE proxy.
+func (t *transport) dialProxied(ctx context.Context, addr ma.Multiaddr, tlsConf *tls.Config) (quic.Connection, error) {
+	naddr, _, err := quicreuse.FromQuicMultiaddr(addr)
+	if err != nil {
+		return nil, err
+	}
+	pconn, err := t.proxy.dial(ctx, naddr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to MASQUE proxy: %w", err)
+	}
+	conn, err := t.connManager.DialQUICOverConn(ctx, pconn, addr, tlsConf, t.allowWindowIncrease)
+	if err != nil {
+		pconn.Close()
+		return nil, err
+	}
+	go func() {
+		<-conn.Context().Done()
+		pconn.Close()
+	}()
+	return conn, nil
+}
+
 func (t *transport) upgrade(ctx context.Context, sess *webtransport.Session, p peer.ID, certHashes []multihash.DecodedMultihash) (*connSecurityMultiaddrs, error) {
 	local, err := toWebtransportMultiaddr(sess.LocalAddr())
 	if err != nil {
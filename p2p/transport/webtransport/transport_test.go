@@ -149,6 +149,109 @@ func TestTransport(t *testing.T) {
 	require.True(t, conn.IsClosed())
 }
 
+func TestStreamResetWithError(t *testing.T) {
+	serverID, serverKey := newIdentity(t)
+	tr, err := libp2pwebtransport.New(serverKey, nil, newConnManager(t), nil, nil)
+	require.NoError(t, err)
+	defer tr.(io.Closer).Close()
+	ln, err := tr.Listen(ma.StringCast("/ip4/127.0.0.1/udp/0/quic-v1/webtransport"))
+	require.NoError(t, err)
+	defer ln.Close()
+
+	_, clientKey := newIdentity(t)
+	tr2, err := libp2pwebtransport.New(clientKey, nil, newConnManager(t), nil, nil)
+	require.NoError(t, err)
+	defer tr2.(io.Closer).Close()
+	conn, err := tr2.Dial(context.Background(), ln.Multiaddr(), serverID)
+	require.NoError(t, err)
+	defer conn.Close()
+	sconn, err := ln.Accept()
+	require.NoError(t, err)
+	defer sconn.Close()
+
+	str, err := conn.OpenStream(context.Background())
+	require.NoError(t, err)
+	_, err = str.Write([]byte("foobar"))
+	require.NoError(t, err)
+	sstr, err := sconn.AcceptStream()
+	require.NoError(t, err)
+	require.NoError(t, network.ResetWithError(str, 42))
+
+	_, err = io.ReadAll(sstr)
+	require.ErrorIs(t, err, &network.StreamError{ErrorCode: 42, Remote: true})
+	require.ErrorIs(t, err, network.ErrReset)
+	_, err = str.Read([]byte{0})
+	require.ErrorIs(t, err, &network.StreamError{ErrorCode: 42})
+
+	// WebTransport error codes are limited to 8 bits
+	str, err = conn.OpenStream(context.Background())
+	require.NoError(t, err)
+	require.Error(t, network.ResetWithError(str, 256))
+}
+
+func TestWriteAfterCloseWriteDoesntBlock(t *testing.T) {
+	serverID, serverKey := newIdentity(t)
+	tr, err := libp2pwebtransport.New(serverKey, nil, newConnManager(t), nil, nil)
+	require.NoError(t, err)
+	defer tr.(io.Closer).Close()
+	ln, err := tr.Listen(ma.StringCast("/ip4/127.0.0.1/udp/0/quic-v1/webtransport"))
+	require.NoError(t, err)
+	defer ln.Close()
+
+	_, clientKey := newIdentity(t)
+	tr2, err := libp2pwebtransport.New(clientKey, nil, newConnManager(t), nil, nil)
+	require.NoError(t, err)
+	defer tr2.(io.Closer).Close()
+	conn, err := tr2.Dial(context.Background(), ln.Multiaddr(), serverID)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	str, err := conn.OpenStream(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, str.CloseWrite())
+	start := time.Now()
+	_, err = str.Write([]byte("foobar"))
+	require.Error(t, err)
+	require.Less(t, time.Since(start), 500*time.Millisecond)
+}
+
+func TestConnCloseWithError(t *testing.T) {
+	serverID, serverKey := newIdentity(t)
+	tr, err := libp2pwebtransport.New(serverKey, nil, newConnManager(t), nil, nil)
+	require.NoError(t, err)
+	defer tr.(io.Closer).Close()
+	ln, err := tr.Listen(ma.StringCast("/ip4/127.0.0.1/udp/0/quic-v1/webtransport"))
+	require.NoError(t, err)
+	defer ln.Close()
+
+	_, clientKey := newIdentity(t)
+	tr2, err := libp2pwebtransport.New(clientKey, nil, newConnManager(t), nil, nil)
+	require.NoError(t, err)
+	defer tr2.(io.Closer).Close()
+	conn, err := tr2.Dial(context.Background(), ln.Multiaddr(), serverID)
+	require.NoError(t, err)
+	sconn, err := ln.Accept()
+	require.NoError(t, err)
+	defer sconn.Close()
+
+	str, err := conn.OpenStream(context.Background())
+	require.NoError(t, err)
+	_, err = str.Write([]byte("foobar"))
+	require.NoError(t, err)
+	sstr, err := sconn.AcceptStream()
+	require.NoError(t, err)
+	require.NoError(t, network.CloseWithError(conn, 42, "going away"))
+
+	_, err = io.ReadAll(sstr)
+	var cerr *network.ConnError
+	require.ErrorAs(t, err, &cerr)
+	require.Equal(t, &network.ConnError{ErrorCode: 42, Reason: "going away", Remote: true}, cerr)
+	_, err = sconn.AcceptStream()
+	require.ErrorIs(t, err, &network.ConnError{ErrorCode: 42, Remote: true})
+	_, err = str.Read([]byte{0})
+	require.ErrorIs(t, err, &network.ConnError{ErrorCode: 42})
+}
+
 func TestHashVerification(t *testing.T) {
 	serverID, serverKey := newIdentity(t)
 	tr, err := libp2pwebtransport.New(serverKey, nil, newConnManager(t), nil, &network.NullResourceManager{})
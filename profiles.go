@@ -0,0 +1,178 @@
+package libp2p
+
+// This file contains the configuration profiles.
+
+import (
+	"errors"
+	"time"
+
+	rcmgr "github.com/AstaFrode/go-libp2p/p2p/host/resource-manager"
+	"github.com/AstaFrode/go-libp2p/p2p/net/connmgr"
+	quic "github.com/AstaFrode/go-libp2p/p2p/transport/quic"
+	"github.com/AstaFrode/go-libp2p/p2p/transport/tcp"
+	ws "github.com/AstaFrode/go-libp2p/p2p/transport/websocket"
+	webtransport "github.com/AstaFrode/go-libp2p/p2p/transport/webtransport"
+)
+
+// The profiles set defaults suited to the environment the node runs in. They are applied
+// by New together with the defaults, and only for the settings that weren't configured
+// using other options, so that any option overrides the profile:
+//
+//	libp2p.New(libp2p.ProfileServer(), libp2p.ConnectionManager(cm))
+//
+// Features enabled by a profile can be disabled using the corresponding option, e.g.
+// DisableHolePunching.
+const (
+	profileServer         = "server"
+	profileEdge           = "edge"
+	profileBrowserGateway = "browser-gateway"
+	profileMobile         = "mobile"
+)
+
+// ProfileServer configures the node for a publicly reachable server. It keeps many
+// connections open, and helps other peers by running the AutoNAT service and a relay.
+func ProfileServer() Option {
+	return profile(profileServer)
+}
+
+// ProfileEdge configures the node for a machine behind a NAT, like a desktop computer.
+// It maps ports on the NAT device and establishes direct connections using hole punching.
+func ProfileEdge() Option {
+	return profile(profileEdge)
+}
+
+// ProfileBrowserGateway configures the node for a publicly reachable server that browsers
+// connect to. In addition to the defaults of ProfileServer, it listens on WebSocket.
+func ProfileBrowserGateway() Option {
+	return profile(profileBrowserGateway)
+}
+
+// ProfileMobile configures the node for a mobile device. It keeps few connections open,
+// uses little memory, closes idle connections and reacts quickly to network changes.
+func ProfileMobile() Option {
+	return profile(profileMobile)
+}
+
+func profile(name string) Option {
+	return func(cfg *Config) error {
+		if cfg.Profile != "" && cfg.Profile != name {
+			return errors.New("cannot apply multiple profiles")
+		}
+		cfg.Profile = name
+		return nil
+	}
+}
+
+type fallbackOption struct {
+	fallback func(cfg *Config) bool
+	opt      Option
+}
+
+func connectionManager(low, hi int, opts ...connmgr.Option) Option {
+	return func(cfg *Config) error {
+		mgr, err := connmgr.NewConnManager(low, hi, opts...)
+		if err != nil {
+			return err
+		}
+		cfg.AddCloser(mgr)
+		return cfg.Apply(ConnectionManager(mgr))
+	}
+}
+
+var serverDefaults = []fallbackOption{
+	{
+		fallback: func(cfg *Config) bool { return cfg.ConnManager == nil },
+		opt:      connectionManager(800, 1000),
+	},
+	{
+		fallback: func(cfg *Config) bool { return !cfg.NATServiceCustom },
+		opt:      EnableNATService(),
+	},
+	{
+		fallback: func(cfg *Config) bool { return !cfg.RelayServiceCustom },
+		opt:      EnableRelayService(),
+	},
+}
+
+var profiles = map[string][]fallbackOption{
+	profileServer: serverDefaults,
+	profileEdge: {
+		{
+			fallback: func(cfg *Config) bool { return cfg.ConnManager == nil },
+			opt:      connectionManager(100, 150),
+		},
+		{
+			fallback: func(cfg *Config) bool { return cfg.NATManager == nil },
+			opt:      NATPortMap(),
+		},
+		{
+			fallback: func(cfg *Config) bool { return !cfg.HolePunchingCustom },
+			opt:      EnableHolePunching(),
+		},
+	},
+	profileBrowserGateway: append([]fallbackOption{
+		{
+			fallback: func(cfg *Config) bool { return cfg.Transports == nil && cfg.PSK == nil },
+			opt: ChainOptions(
+				Transport(tcp.NewTCPTransport),
+				Transport(quic.NewTransport),
+				Transport(ws.New),
+				Transport(webtransport.New),
+			),
+		},
+		{
+			fallback: func(cfg *Config) bool { return cfg.ListenAddrs == nil },
+			opt: ListenAddrStrings(
+				"/ip4/0.0.0.0/tcp/0",
+				"/ip4/0.0.0.0/tcp/0/ws",
+				"/ip4/0.0.0.0/udp/0/quic-v1",
+				"/ip4/0.0.0.0/udp/0/quic-v1/webtransport",
+				"/ip6/::/tcp/0",
+				"/ip6/::/tcp/0/ws",
+				"/ip6/::/udp/0/quic-v1",
+				"/ip6/::/udp/0/quic-v1/webtransport",
+			),
+		},
+	}, serverDefaults...),
+	profileMobile: {
+		{
+			fallback: func(cfg *Config) bool { return cfg.ConnManager == nil },
+			opt:      connectionManager(20, 40, connmgr.WithGracePeriod(30*time.Second)),
+		},
+		{
+			fallback: func(cfg *Config) bool { return cfg.ResourceManager == nil },
+			opt: func(cfg *Config) error {
+				// use the base limits, suited to devices with little memory
+				limits := rcmgr.DefaultLimits
+				SetDefaultServiceLimits(&limits)
+				mgr, err := rcmgr.NewResourceManager(rcmgr.NewFixedLimiter(limits.Scale(0, 0)))
+				if err != nil {
+					return err
+				}
+				cfg.AddCloser(mgr)
+				return cfg.Apply(ResourceManager(mgr))
+			},
+		},
+		{
+			fallback: func(cfg *Config) bool { return cfg.DialTimeout == 0 },
+			// mobile networks have high latencies
+			opt: WithDialTimeout(30 * time.Second),
+		},
+		{
+			fallback: func(cfg *Config) bool { return cfg.IdleConnTimeout == 0 },
+			opt:      IdleConnTimeout(5 * time.Minute),
+		},
+		{
+			fallback: func(cfg *Config) bool { return !cfg.HolePunchingCustom },
+			opt:      EnableHolePunching(),
+		},
+		{
+			fallback: func(cfg *Config) bool { return !cfg.HealthCheckCustom },
+			opt:      EnableHealthCheck(),
+		},
+		{
+			fallback: func(cfg *Config) bool { return !cfg.AddrChangeMonitorCustom },
+			opt:      EnableAddrChangeMonitor(),
+		},
+	},
+}